@@ -0,0 +1,96 @@
+package catalog
+
+import "time"
+
+// TimeFormat 数据库中时间的格式：UTC 的 YYYY-MM-DD HH:MM:SS，可直接用于 SQLite 的 datetime()、strftime() 等函数
+const TimeFormat = "2006-01-02 15:04:05"
+
+// schema 数据库结构：files 为备份目标中的当前版本，versions 为历史目录中的旧版本，runs 为运行历史
+const schema = `
+CREATE TABLE meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT
+);
+CREATE TABLE files (
+	path        TEXT PRIMARY KEY, -- 备份目标下的相对路径（/ 分隔；路径过长、改存到哈希目录的文件为原始路径）
+	stored_path TEXT NOT NULL,    -- 实际存放的相对路径
+	source      TEXT,             -- 原始位置（导出时指定了搜索根目录才有）
+	size        INTEGER NOT NULL, -- 文件大小（分块存储的文件为还原后的大小）
+	mtime       TEXT NOT NULL,    -- 修改时间（UTC）
+	hash        TEXT,             -- 存放内容的哈希（算法见 meta.hash_algorithm），未知时为空
+	chunked     INTEGER NOT NULL  -- 是否按分块存储（存放的是配方）
+);
+CREATE TABLE versions (
+	path         TEXT NOT NULL,
+	version      TEXT NOT NULL,    -- 历史目录中的时间戳目录名
+	version_time TEXT NOT NULL,    -- 时间戳对应的时间（UTC），即该版本被替换或删除的运行时间
+	stored_path  TEXT NOT NULL,    -- 相对于历史目录的存放路径
+	source       TEXT,
+	size         INTEGER NOT NULL,
+	mtime        TEXT NOT NULL,
+	hash         TEXT,
+	chunked      INTEGER NOT NULL,
+	PRIMARY KEY (path, version)
+);
+CREATE INDEX versions_time ON versions (version_time);
+CREATE TABLE runs (
+	started_at       TEXT NOT NULL,
+	finished_at      TEXT NOT NULL,
+	duration_seconds REAL NOT NULL,
+	success          INTEGER NOT NULL,
+	fatal_error      TEXT,
+	copied           INTEGER NOT NULL,
+	skipped          INTEGER NOT NULL,
+	errors           INTEGER NOT NULL,
+	conflicts        INTEGER NOT NULL,
+	copied_bytes     INTEGER NOT NULL,
+	skipped_bytes    INTEGER NOT NULL,
+	backup_bytes     INTEGER
+);
+CREATE VIEW all_versions AS
+	SELECT path, NULL AS version, NULL AS version_time, source, size, mtime, hash FROM files
+	UNION ALL
+	SELECT path, version, version_time, source, size, mtime, hash FROM versions;
+`
+
+// File 备份目标中的一个文件：当前版本（Version 为空）或历史目录中的旧版本
+type File struct {
+	Path        string    // 备份目标下的相对路径（/ 分隔）
+	StoredPath  string    // 实际存放的相对路径（/ 分隔）
+	Source      string    // 原始位置，未知时为空
+	Size        int64     // 文件大小（分块存储的文件为还原后的大小）
+	ModTime     time.Time // 修改时间
+	Hash        string    // 存放内容的哈希，未知时为空
+	Chunked     bool      // 是否按分块存储
+	Version     string    // 历史目录中的时间戳目录名，当前版本为空
+	VersionTime time.Time // 时间戳对应的时间
+}
+
+// Run 运行历史中的一次运行
+type Run struct {
+	StartedAt    time.Time
+	FinishedAt   time.Time
+	Duration     float64
+	Success      bool
+	FatalError   string
+	Copied       int
+	Skipped      int
+	Errors       int
+	Conflicts    int
+	CopiedBytes  int64
+	SkippedBytes int64
+	BackupBytes  int64 // 运行结束时备份根目录的总大小，未统计时为 0
+}
+
+// formatTime 将时间转为数据库中的格式
+func formatTime(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
+
+// nullString 空字符串写为 NULL
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
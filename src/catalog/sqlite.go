@@ -0,0 +1,121 @@
+package catalog
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "modernc.org/sqlite"
+)
+
+// Writer 写入 SQLite 数据库的备份目录（所有写入在一个事务中，Close 时提交）
+type Writer struct {
+	db    *sql.DB
+	tx    *sql.Tx
+	file  *sql.Stmt
+	ver   *sql.Stmt
+	run   *sql.Stmt
+	meta  *sql.Stmt
+	stmts []*sql.Stmt
+}
+
+// Create 在 path 创建数据库并建立表结构（path 应为新文件，已有的表会导致建表失败）
+func Create(path string) (*Writer, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// 只用一个连接，连接级的设置才对所有语句生效
+	db.SetMaxOpenConns(1)
+	w := &Writer{db: db}
+	if err := w.init(); err != nil {
+		w.Abort()
+		return nil, err
+	}
+	return w, nil
+}
+
+// init 建表并准备插入语句
+func (w *Writer) init() error {
+	// 导出的是一次性写入的新文件，写入失败时整个删除，不需要日志和同步
+	if _, err := w.db.Exec("PRAGMA journal_mode = OFF; PRAGMA synchronous = OFF"); err != nil {
+		return fmt.Errorf("打开数据库失败: %w", err)
+	}
+	if _, err := w.db.Exec(schema); err != nil {
+		return fmt.Errorf("创建表结构失败: %w", err)
+	}
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	w.tx = tx
+	for _, s := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&w.file, "INSERT INTO files (path, stored_path, source, size, mtime, hash, chunked) VALUES (?, ?, ?, ?, ?, ?, ?)"},
+		{&w.ver, "INSERT INTO versions (path, version, version_time, stored_path, source, size, mtime, hash, chunked) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"},
+		{&w.run, "INSERT INTO runs (started_at, finished_at, duration_seconds, success, fatal_error, copied, skipped, errors, conflicts, copied_bytes, skipped_bytes, backup_bytes) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"},
+		{&w.meta, "INSERT OR REPLACE INTO meta (key, value) VALUES (?, ?)"},
+	} {
+		if *s.stmt, err = tx.Prepare(s.query); err != nil {
+			return err
+		}
+		w.stmts = append(w.stmts, *s.stmt)
+	}
+	return nil
+}
+
+// SetMeta 记录导出的元信息（备份根目录、导出时间、哈希算法等）
+func (w *Writer) SetMeta(key, value string) error {
+	_, err := w.meta.Exec(key, value)
+	return err
+}
+
+// AddFile 写入一个文件，Version 不为空时写入 versions 表
+func (w *Writer) AddFile(f File) error {
+	var err error
+	if f.Version == "" {
+		_, err = w.file.Exec(f.Path, f.StoredPath, nullString(f.Source), f.Size, formatTime(f.ModTime), nullString(f.Hash), f.Chunked)
+	} else {
+		_, err = w.ver.Exec(f.Path, f.Version, formatTime(f.VersionTime), f.StoredPath, nullString(f.Source), f.Size,
+			formatTime(f.ModTime), nullString(f.Hash), f.Chunked)
+	}
+	if err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", f.Path, err)
+	}
+	return nil
+}
+
+// AddRun 写入一次运行
+func (w *Writer) AddRun(r Run) error {
+	var backupBytes any
+	if r.BackupBytes > 0 {
+		backupBytes = r.BackupBytes
+	}
+	_, err := w.run.Exec(formatTime(r.StartedAt), formatTime(r.FinishedAt), r.Duration, r.Success, nullString(r.FatalError),
+		r.Copied, r.Skipped, r.Errors, r.Conflicts, r.CopiedBytes, r.SkippedBytes, backupBytes)
+	return err
+}
+
+// Close 提交所有写入并关闭数据库
+func (w *Writer) Close() error {
+	for _, s := range w.stmts {
+		s.Close()
+	}
+	err := w.tx.Commit()
+	if cerr := w.db.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Abort 放弃写入并关闭数据库（调用方负责删除不完整的文件）
+func (w *Writer) Abort() {
+	for _, s := range w.stmts {
+		s.Close()
+	}
+	if w.tx != nil {
+		w.tx.Rollback()
+	}
+	w.db.Close()
+}
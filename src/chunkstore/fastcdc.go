@@ -0,0 +1,123 @@
+package chunkstore
+
+import (
+	"io"
+)
+
+// 分块大小参数（FastCDC 归一化分块）
+const (
+	MinChunkSize = 256 << 10 // 最小块
+	AvgChunkSize = 1 << 20   // 平均块
+	MaxChunkSize = 4 << 20   // 最大块
+)
+
+// 归一化分块使用的掩码：未达到平均大小前使用更严格的掩码（更难切分），之后使用更宽松的掩码
+var (
+	maskSmall = spreadMask(22)
+	maskLarge = spreadMask(18)
+)
+
+// spreadMask 生成含 bits 个 1 的掩码，1 均匀分布在高 48 位中（Gear 哈希的高位更随机）
+func spreadMask(bits int) uint64 {
+	var mask uint64
+	for i := 0; i < bits; i++ {
+		mask |= 1 << (63 - i*48/bits)
+	}
+	return mask
+}
+
+// gearTable Gear 哈希表，使用固定种子生成，保证不同版本间分块结果一致
+var gearTable = func() [256]uint64 {
+	var table [256]uint64
+	seed := uint64(0x9e3779b97f4a7c15)
+	for i := range table {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// Chunker 基于内容的分块器（FastCDC），相同内容无论出现在文件什么位置都会切出相同的块
+type Chunker struct {
+	r   io.Reader
+	buf []byte
+	pos int
+	end int
+	eof bool
+}
+
+// NewChunker 创建分块器
+func NewChunker(r io.Reader) *Chunker {
+	return &Chunker{r: r, buf: make([]byte, 2*MaxChunkSize)}
+}
+
+// Next 返回下一个块的数据，数据在下次调用前有效；读取完毕时返回 io.EOF
+func (c *Chunker) Next() ([]byte, error) {
+	if err := c.fill(); err != nil {
+		return nil, err
+	}
+	if c.pos == c.end {
+		return nil, io.EOF
+	}
+
+	data := c.buf[c.pos:c.end]
+	n := cutPoint(data)
+	chunk := data[:n]
+	c.pos += n
+	return chunk, nil
+}
+
+// fill 保证缓冲区中至少有一个最大块的数据（文件末尾除外）
+func (c *Chunker) fill() error {
+	if c.eof || c.end-c.pos >= MaxChunkSize {
+		return nil
+	}
+	copy(c.buf, c.buf[c.pos:c.end])
+	c.end -= c.pos
+	c.pos = 0
+	for c.end < len(c.buf) && !c.eof {
+		n, err := c.r.Read(c.buf[c.end:])
+		c.end += n
+		if err == io.EOF {
+			c.eof = true
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cutPoint 计算 data 中第一个切分点的位置
+func cutPoint(data []byte) int {
+	n := len(data)
+	if n <= MinChunkSize {
+		return n
+	}
+	if n > MaxChunkSize {
+		n = MaxChunkSize
+	}
+	normal := AvgChunkSize
+	if n < normal {
+		normal = n
+	}
+
+	var fp uint64
+	i := MinChunkSize
+	for ; i < normal; i++ {
+		fp = (fp << 1) + gearTable[data[i]]
+		if fp&maskSmall == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + gearTable[data[i]]
+		if fp&maskLarge == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
@@ -0,0 +1,277 @@
+package chunkstore
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/tempname"
+)
+
+// recipeMagic 配方文件的文件头，用于和普通文件区分
+const recipeMagic = "COPY-IGNORE-CDC 1\n"
+
+// Recipe 分块存储的文件配方：按顺序列出组成文件内容的块
+type Recipe struct {
+	Size   int64    `json:"size"`
+	Chunks []string `json:"chunks"` // 块的 SHA-256（十六进制）
+}
+
+// Store 块池，同一内容的块只保存一次，供所有仓库和所有历史版本共享
+type Store struct {
+	dir string
+}
+
+// Open 打开备份根目录下的块池
+func Open(backupRoot string) *Store {
+	return &Store{dir: filepath.Join(backupRoot, config.ChunkDirName)}
+}
+
+// Dir 返回块池目录
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// chunkPath 返回块文件路径（按哈希前两位分目录）
+func (s *Store) chunkPath(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash)
+}
+
+// Put 将文件内容分块写入块池，返回配方和新写入的字节数（已存在的块不会重复写入）
+func (s *Store) Put(r io.Reader) (*Recipe, int64, error) {
+	recipe := &Recipe{}
+	var written int64
+	chunker := NewChunker(r)
+
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, written, err
+		}
+
+		sum := sha256.Sum256(chunk)
+		hash := hex.EncodeToString(sum[:])
+		recipe.Chunks = append(recipe.Chunks, hash)
+		recipe.Size += int64(len(chunk))
+
+		path := s.chunkPath(hash)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		if err := writeChunk(path, chunk); err != nil {
+			return nil, written, fmt.Errorf("写入块失败: %v", err)
+		}
+		written += int64(len(chunk))
+	}
+
+	return recipe, written, nil
+}
+
+// writeChunk 原子写入单个块
+// 块池由所有工作协程和所有运行共享，不同文件中的相同内容可能同时写入同一个块，因此每次写入使用各自的临时文件（见 tempname.Path）
+func writeChunk(path string, data []byte) error {
+	if err := fsguard.MkdirAll(filepath.Dir(path), 0755, "写入块池"); err != nil {
+		return err
+	}
+	tempPath := tempname.Path(path)
+	if err := fsguard.WriteFile(tempPath, data, 0644, "写入块池：写入临时块"); err != nil {
+		fsguard.Remove(tempPath, "删除写入失败的临时块")
+		return err
+	}
+	if err := fsguard.Rename(tempPath, path, "写入块池"); err != nil {
+		fsguard.Remove(tempPath, "删除重命名失败的临时块")
+		// 同一个块已由其他写入完成（如 Windows 上目标正被读取、无法替换），内容相同，不算失败
+		if _, statErr := os.Stat(path); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Restore 按配方将文件内容写入 w
+func (s *Store) Restore(recipe *Recipe, w io.Writer) error {
+	for _, hash := range recipe.Chunks {
+		data, err := os.ReadFile(s.chunkPath(hash))
+		if err != nil {
+			return fmt.Errorf("读取块 %s 失败: %v", hash, err)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != hash {
+			return fmt.Errorf("块 %s 已损坏", hash)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteRecipe 将配方写入文件
+func WriteRecipe(path string, recipe *Recipe) error {
+	data, err := json.Marshal(recipe)
+	if err != nil {
+		return err
+	}
+	return fsguard.WriteFile(path, append([]byte(recipeMagic), data...), 0644, "写入分块配方")
+}
+
+// ReadRecipe 读取配方文件，文件不是配方时返回 nil
+func ReadRecipe(path string) (*Recipe, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	head, err := br.Peek(len(recipeMagic))
+	if err != nil || !bytes.Equal(head, []byte(recipeMagic)) {
+		return nil, nil
+	}
+	br.Discard(len(recipeMagic))
+
+	recipe := &Recipe{}
+	if err := json.NewDecoder(br).Decode(recipe); err != nil {
+		return nil, fmt.Errorf("解析配方失败: %v", err)
+	}
+	return recipe, nil
+}
+
+// GCResult 垃圾回收结果
+type GCResult struct {
+	Referenced int   // 被引用的块数
+	Removed    int   // 删除的块数
+	Freed      int64 // 释放的字节数
+	Recent     int   // 未被引用、但在保护期内写入而保留的块和临时块
+}
+
+// GC 删除未被 roots 下任何配方引用的块；dryRun 时只统计不删除
+// roots 应包含备份根目录以及单独配置的历史目录，以免误删历史版本引用的块
+// 复制一个文件时先写入块、最后才写入配方，正在进行的复制写入的块暂时没有配方引用：
+// grace 之内有修改的块和临时块一律保留，留给之后的回收
+func (s *Store) GC(roots []string, dryRun bool, grace time.Duration) (*GCResult, error) {
+	referenced, err := s.Referenced(roots, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &GCResult{Referenced: len(referenced)}
+	err = filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if _, ok := referenced[info.Name()]; info.IsDir() || ok {
+			return nil
+		}
+		// 未完成的临时块以及未被引用的块都可删除
+		if !strings.HasSuffix(info.Name(), ".tmp") && len(info.Name()) != sha256.Size*2 {
+			return nil
+		}
+		if time.Since(info.ModTime()) < grace {
+			result.Recent++
+			return nil
+		}
+		result.Removed++
+		result.Freed += info.Size()
+		if dryRun {
+			return nil
+		}
+		return fsguard.Remove(path, "回收块池中未引用的块")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Referenced 返回 roots 下（跳过块池和 skipDirs）所有配方引用的块：哈希 -> 第一个引用它的配方路径
+func (s *Store) Referenced(roots, skipDirs []string) (map[string]string, error) {
+	referenced := make(map[string]string)
+	for _, root := range roots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if info.IsDir() {
+				if path == s.dir {
+					return filepath.SkipDir
+				}
+				for _, skip := range skipDirs {
+					if path == skip {
+						return filepath.SkipDir
+					}
+				}
+				return nil
+			}
+			if info.Size() < int64(len(recipeMagic)) {
+				return nil
+			}
+			recipe, err := ReadRecipe(path)
+			if err != nil || recipe == nil {
+				return nil
+			}
+			for _, hash := range recipe.Chunks {
+				if _, ok := referenced[hash]; !ok {
+					referenced[hash] = path
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return referenced, nil
+}
+
+// readChunk 读取一个块并校验其内容与哈希一致
+func (s *Store) readChunk(hash string) ([]byte, error) {
+	if len(hash) != sha256.Size*2 {
+		return nil, fmt.Errorf("无效的块哈希: %q", hash)
+	}
+	data, err := os.ReadFile(s.chunkPath(hash))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != hash {
+		return nil, fmt.Errorf("块 %s 已损坏", hash)
+	}
+	return data, nil
+}
+
+// Check 校验块池中的一个块：存在且内容与哈希一致时返回 nil
+func (s *Store) Check(hash string) error {
+	_, err := s.readChunk(hash)
+	return err
+}
+
+// CopyFrom 从另一个块池获取一个块：只有源块的内容与哈希一致时才写入，写入方式与 Put 相同
+func (s *Store) CopyFrom(src *Store, hash string) error {
+	data, err := src.readChunk(hash)
+	if err != nil {
+		return err
+	}
+	return writeChunk(s.chunkPath(hash), data)
+}
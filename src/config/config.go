@@ -1,20 +1,220 @@
 package config
 
-import "path/filepath"
+import (
+	"path/filepath"
+	"time"
+)
+
+// ManifestFileName 备份根目录下的清单文件名（由工具维护，清理阶段不会处理）
+const ManifestFileName = ".copy-ignore-manifest.json"
+
+// RepoMapFileName 备份根目录下的仓库名映射文件（按仓库名布局时使用）
+const RepoMapFileName = ".copy-ignore-repos.json"
+
+// RepoIdentityFileName 备份根目录下的仓库身份记录（用于识别被移动的仓库）
+const RepoIdentityFileName = ".copy-ignore-identities.json"
+
+// CleanedSourcesFileName 备份根目录下记录已被 clean-source 从源仓库删除的文件（清理阶段保留其备份）
+const CleanedSourcesFileName = ".copy-ignore-cleaned.json"
+
+// SyncedSourcesFileName 备份根目录下按主机记录上次运行结束时源位置存在的双向同步文件（--sync），
+// 用于区分“本机删除了该文件”和“本机从未有过该文件（在其他机器上新增）”
+const SyncedSourcesFileName = ".copy-ignore-synced.json"
+
+// LastRunFileName 备份根目录下的运行摘要文件（未指定 --last-run 时使用）
+const LastRunFileName = "last-run.json"
+
+// RunHistoryFileName 备份根目录下的运行历史（每次运行追加一行 JSON，stats 子命令读取）
+const RunHistoryFileName = ".copy-ignore-runs.jsonl"
+
+// 队列缓冲大小的默认值
+// 各队列的消费者都与生产者并发运行，下游跟不上时上游阻塞等待（背压），缓冲大小只影响内存占用和吞吐的平滑程度，不影响正确性
+const (
+	DefaultScanQueueSize = 10000
+	DefaultJobQueueSize  = 1000
+)
+
+// RunTimingsFileName 备份根目录下记录上次运行各仓库耗时的文件（用于估计剩余时间）
+const RunTimingsFileName = ".copy-ignore-timings.json"
+
+// ChangeStateFileName 备份根目录下记录上次运行开始时的变更位置（USN 日志位置、开始时间）的文件（--skip-unchanged）
+const ChangeStateFileName = ".copy-ignore-changes.json"
+
+// ChangeLogFileName 备份根目录下 watch 子命令记录的变更日志（每行一条 JSON）
+const ChangeLogFileName = ".copy-ignore-watch.jsonl"
+
+// HostMarkerFileName 按主机分隔（--per-host）时，每台机器备份子树根目录下的主机标记文件
+const HostMarkerFileName = ".copy-ignore-host"
+
+// DestMarkerFileName 备份目标根目录下的标记文件（首次使用时创建，缺失时可能是未挂载的挂载点）
+const DestMarkerFileName = ".copy-ignore-dest"
+
+// IsManagedFile 判断备份根目录下的文件是否由工具自身维护（清理和比较时跳过）
+func IsManagedFile(name string) bool {
+	return name == ManifestFileName || name == RepoMapFileName || name == RepoIdentityFileName ||
+		name == CleanedSourcesFileName || name == LastRunFileName || name == RunHistoryFileName ||
+		name == HostMarkerFileName || name == DestMarkerFileName || name == RunTimingsFileName ||
+		name == ChangeStateFileName || name == ChangeLogFileName || name == SyncedSourcesFileName
+}
+
+// ChunkDirName 备份根目录下的块池目录名（分块存储模式使用）
+const ChunkDirName = ".copy-ignore-chunks"
+
+// JournalDirName 备份根目录下的移动日志目录（移入历史目录前记录意图，用于崩溃后修复）
+const JournalDirName = ".copy-ignore-journal"
+
+// LockDirName 共享备份根目录下的租约目录（多台机器备份到同一目标时协调）
+const LockDirName = ".copy-ignore-locks"
+
+// LongPathDirName 备份根目录下存放路径过长文件的目录（按相对路径哈希分片）
+const LongPathDirName = ".copy-ignore-long"
+
+// LongPathRecordSuffix 路径过长的文件旁记录原始相对路径的文件后缀
+const LongPathRecordSuffix = ".path"
+
+// 目标文件名转义模式（--sanitize-names）
+const (
+	SanitizeAuto   = "auto"   // Windows 上或目标拒绝含 ":" 的文件名时转义（默认）
+	SanitizeAlways = "always" // 总是转义
+	SanitizeNever  = "never"  // 从不转义
+)
+
+// PlaceholderStubSuffix 云端占位文件只记录元数据时（--placeholders metadata），备份目标中记录文件的后缀
+const PlaceholderStubSuffix = ".copy-ignore-placeholder.json"
+
+// 云端占位文件（OneDrive、iCloud 等只在云端、本地未下载的文件）的处理策略（--placeholders）
+const (
+	PlaceholderSkip     = "skip"     // 跳过（默认），已有的备份保持不变
+	PlaceholderHydrate  = "hydrate"  // 下载后照常复制
+	PlaceholderMetadata = "metadata" // 不下载，只在备份目标记录大小、修改时间等元数据
+)
+
+// 扫描和复制时遇到目录链接（符号链接、Windows 目录联接等）的处理策略（--reparse-points）
+const (
+	ReparseSkip   = "skip"   // 跳过（默认），避免 Application Data 等联接造成环路或扫描到范围外
+	ReparseFollow = "follow" // 跟随，按真实路径检测环路和重复
+)
+
+// 干运行和 scan 结果的输出格式（--output）
+const (
+	OutputText = "text" // 面向人阅读的文本（默认）
+	OutputJSON = "json" // 标准输出只有一个 JSON 文档，其余消息输出到标准错误
+)
+
+// .gitignore 有未提交修改的仓库的处理策略（--dirty-gitignore）
+const (
+	DirtyGitignoreAllow = "allow" // 照常备份（默认）
+	DirtyGitignoreWarn  = "warn"  // 照常备份，输出警告
+	DirtyGitignoreSkip  = "skip"  // 跳过该仓库，已有的备份保持不变
+)
+
+// 被过滤文件（源文件仍在，但因排除规则等不再复制）在清理阶段的处理策略
+const (
+	FilteredKeep    = "keep"    // 保留已有备份（默认）
+	FilteredHistory = "history" // 与源文件已删除一样移入历史目录
+)
+
+// OverwritePolicy 复制时目标文件已存在且需要更新，旧版本的处理策略（--overwrite）
+type OverwritePolicy string
+
+const (
+	OverwriteHistory      OverwritePolicy = "history"       // 移入历史目录 <历史目录>/<时间戳>/<相对路径>，按 --backup-keep 轮换（默认）
+	OverwriteSuffixRename OverwritePolicy = "suffix-rename" // 在原位置重命名为 <文件名>.<时间戳>.copy-ignore-old，按 --backup-keep 轮换
+	OverwriteNone         OverwritePolicy = "none"          // 直接覆盖，不保留旧版本
+)
+
+// 清单、校验和历史目录合并使用的内容哈希算法（--hash），使用的算法记录在清单中
+const (
+	HashSHA256 = "sha256" // SHA-256（默认），兼容旧版本的清单
+	HashBLAKE3 = "blake3" // BLAKE3：与 SHA-256 同样适合校验完整性，速度快得多
+	HashXXH3   = "xxh3"   // XXH3 128 位：最快，但不是密码学哈希，只能发现意外损坏
+)
+
+// HashAlgorithms 支持的内容哈希算法
+var HashAlgorithms = []string{HashSHA256, HashBLAKE3, HashXXH3}
+
+// OverwriteVersionSuffix --overwrite suffix-rename 时旧版本文件名的后缀
+const OverwriteVersionSuffix = ".copy-ignore-old"
 
 // Config 包含程序的所有配置
 type Config struct {
-	SearchRoot   string   // 开始搜索的根目录
-	BackupRoot   string   // 备份目标根目录
-	Excludes     []string // 排除模式列表
-	DryRun       bool     // 仅显示要复制的文件，不实际复制
-	Concurrency  int      // 并行复制的并发数
-	Verbose      bool     // 详细输出
-	BackupDirs   []string // 备份目录列表（逗号分隔），默认会将 BackupRoot 添加到列表中
-	BackupKeep   int      // 每个备份目录保留的备份数
-	BackupSubdir string   // 在备份目录下创建的子目录名称
-	HistoryDir   string   // 备份历史记录目录
-	Timestamp    string   // 备份时间戳（在 main 入口处生成）
+	SearchRoot          string   // 开始搜索的根目录（有多个搜索根目录时为它们共同的上级目录，备份路径相对于它）
+	ScanRoots           []string // 实际扫描的目录（搜索根目录参数含通配符或有多个时展开得到），为空时扫描整个 SearchRoot
+	BackupRoot          string   // 备份目标根目录
+	Excludes            []string // 排除模式列表
+	DryRun              bool     // 仅显示要复制的文件，不实际复制
+	Explain             bool     // 干运行时说明每个文件被列出或被过滤的原因
+	Output              string   // 干运行结果的输出格式：text 或 json
+	PrintConfig         bool     // 开始运行前输出解析后生效的完整配置
+	Concurrency         int      // 并行复制的并发数
+	Verbose             bool     // 详细输出
+	BackupDirs          []string // 备份目录列表（逗号分隔），默认会将 BackupRoot 添加到列表中
+	BackupKeep          int      // 每个备份目录保留的备份数
+	KeepDryRun          bool     // 轮换预演：只列出超出保留数、将被删除的旧版本及其大小，不删除
+	Yes                 bool     // 确认轮换删除旧版本（交互式运行时需要，计划任务等无人值守的运行不需要）
+	BackupSubdir        string   // 在备份目录下创建的子目录名称
+	HistoryDir          string   // 备份历史记录目录
+	Timestamp           string   // 备份时间戳（在 main 入口处生成）
+	TimestampFormat     string   // 历史目录名的时间戳格式（Go 时间格式或预置名称 default、rfc3339、iso）
+	TimestampZone       string   // 生成时间戳使用的时区：local、UTC 或 IANA 时区名
+	HealFrom            string   // 校验失败时用于修复的副本备份目标
+	Dests               []string // 多目标复制的全部备份目标（命令行的备份根目录和 --dest 指定的目标），只有一个目标时为空
+	DestRetries         int      // 多目标复制时失败的目标单独重试的次数
+	Snapshot            string   // 运行成功后为备份目标创建快照的预设（vss、btrfs、zfs、synology、http、command）
+	SnapshotOptions     []string // 快照预设的选项（key=value）
+	BandwidthLimit      string   // 按时间段的带宽限制（如 "09:00-18:00=5M,0"），空表示不限速
+	AdaptiveConcurrency bool     // 根据目标端延迟和错误率自动调整并发数
+	MaxConcurrency      int      // 自适应并发的上限
+	DeltaThreshold      int64    // 不小于该大小（字节）的已存在文件使用增量更新，0 表示关闭
+	ChunkThreshold      int64    // 不小于该大小（字节）的文件以内容分块方式存入块池，0 表示关闭
+	WarnSize            int64    // 不小于该大小（字节）的文件照常复制，但在汇总中醒目列出，0 表示关闭
+	MaxPathLen          int      // 备份目标路径的长度上限（字节），超过时改存到哈希目录，0 表示按操作系统默认
+	MaxErrors           int      // 出错的文件数超过该值时中止运行，0 表示不限制
+	MaxFilesPerRepo     int      // 每个仓库最多处理的被忽略条目数，超过时只处理前面的条目并警告，0 表示不限制
+	ScanQueueSize       int      // 扫描结果队列的缓冲大小（扫描可领先复制的文件数），0 表示不缓冲
+	JobQueueSize        int      // 复制任务队列和结果队列的缓冲大小，0 表示不缓冲
+	SkipBinary          bool     // 按文件头识别二进制文件并跳过，只备份文本文件
+	SkipCaches          bool     // 跳过已知的可重建缓存目录（node_modules、cargo target、venv 等）
+	IgnoreBackupMarkers bool     // 不理会 CACHEDIR.TAG、.nobackup 标记，照常复制带标记的目录
+	RecheckDirs         bool     // 不整体复制被忽略的目录，目录中的内容逐个按 git 的判断（git ls-files）复制
+	DirtyGitignore      string   // .gitignore 有未提交修改的仓库的处理策略：allow、warn 或 skip
+	Layout              string   // 备份目录布局：path（按搜索根目录下的完整路径）或 repo（按仓库名）
+	ReadOnlySource      bool     // 只读保护：拒绝任何对搜索根目录之内（备份根目录等除外）的修改
+	AuditLog            string   // 审计日志路径：逐条记录本次运行对文件系统的修改及原因，空表示不记录
+	PreserveACL         bool     // 同时复制所有者和访问控制列表（Windows 为 NTFS 安全描述符，Linux 为权限位和 POSIX ACL）
+	Placeholders        string   // 云端占位文件的处理策略：skip、hydrate 或 metadata
+	ReparsePoints       string   // 目录链接（符号链接、目录联接、挂载点）的处理策略：skip 或 follow
+	FastDiscovery       bool     // Windows 上整个 NTFS 驱动器通过 MFT 查找仓库，代替逐个目录读取
+	SkipUnchanged       bool     // 跳过自上次运行以来没有变化的仓库（Windows 上读取 USN 日志，其他系统上读取 watch 的变更日志）
+	SanitizeNames       string   // 转义目标路径中 Windows/exFAT 不兼容的文件名：auto（按目标探测）、always、never
+	MigrateMoved        bool     // 检测到仓库被移动时，将旧备份子树重命名到新位置
+	Protect             []string // 清理阶段永不处理的备份目标路径模式
+	Sync                []string // 双向同步的文件模式：备份较新时取回到源位置
+	Priority            []string // 优先复制的文件模式：匹配的文件在待派发的任务中排在最前
+	NoOverwrite         bool     // 不覆盖：已有的目标文件不修改、不删除，新版本直接写入历史目录
+	AppendOnly          bool     // 只追加：在不覆盖的基础上，也不改写清单等工具自身的记录文件
+	DeleteDryRun        bool     // 清理预演：只列出清理阶段将移入历史的文件及原因，不做修改
+	DeleteReport        string   // 清理预演报告的写入路径，空表示不写文件
+	FilteredPolicy      string   // 被过滤文件在清理阶段的处理策略：keep 或 history
+	LastRunFile         string   // 运行摘要的写入路径，空表示备份根目录下的 last-run.json
+	PerHost             bool     // 按主机分隔：实际写入 <备份根目录>/<主机名>
+	HostName            string   // 本机名称（用于租约和 --per-host），默认取系统主机名
+	SharedRoot          string   // 多台机器共享的备份根目录（未按主机分隔时与 BackupRoot 相同）
+	Background          bool     // 后台模式：降低 CPU 和 IO 优先级，避免影响前台工作
+	InitDest            bool     // 备份目标缺少标记文件时重新初始化（确认目标已正确挂载后使用）
+	SharedInUse         bool     // 运行时：其他机器正在写入重叠的目录，本次不清理、不轮换历史、不修复中断的移动
+	SanitizeActive      bool     // 运行时：本次是否转义目标路径中不兼容的文件名（由 SanitizeNames 和目标探测决定）
+	Interactive         bool     // 运行时：是否在交互式终端中运行（未指定 --yes 时不轮换删除旧版本）
+	FanOutChild         bool     // 运行时：作为多目标复制的子进程运行，只复制到 BackupRoot
+
+	ProgressInterval time.Duration   // 终端状态行（当前扫描的目录、复制进度）的刷新间隔，0 表示默认
+	Overwrite        OverwritePolicy // 覆盖已有目标文件时旧版本的处理策略：history、suffix-rename 或 none，空表示 history
+	Hash             string          // 清单使用的内容哈希算法：sha256、blake3 或 xxh3，空表示 sha256
+	DebugAddr        string          // 调试服务（pprof、运行时统计）的监听地址，空表示不启动
+	StallTimeout     time.Duration   // 超过该时间没有任何进展时警告可能卡住，0 表示不检测
+	StallAbort       bool            // 卡住时放弃正在复制的文件（记为出错），继续处理其余文件
+	FileTimeout      time.Duration   // 单个文件操作（获取信息、打开、读写、同步、重命名）的超时，超时的文件记为出错，0 表示不限制
+	DestRetryDelay   time.Duration   // 多目标复制时失败的目标重试前等待的时间
 }
 
 // 全局配置实例
@@ -39,3 +239,18 @@ func (c *Config) HandleHistoryDir(currentDir string) string {
 	}
 	return filepath.Join(baseDir, c.Timestamp)
 }
+
+// ManagedDirs 返回备份目标下由工具自身管理的目录（历史子目录、块池、移动日志、租约），
+// 生成清单、比较目标和清理时都应跳过这些目录
+func (c *Config) ManagedDirs(root string) []string {
+	return []string{filepath.Join(root, c.BackupSubdir), filepath.Join(root, ChunkDirName), filepath.Join(root, JournalDirName),
+		filepath.Join(root, LockDirName)}
+}
+
+// Roots 返回实际扫描的目录：展开后的多个搜索根目录，或只有 SearchRoot 本身
+func (c *Config) Roots() []string {
+	if len(c.ScanRoots) > 0 {
+		return c.ScanRoots
+	}
+	return []string{c.SearchRoot}
+}
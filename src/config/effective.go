@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// WriteEffective 按字段的顺序输出解析后的完整配置（路径已归一化，--per-host 等已展开），每行以 prefix 开头
+// 用于 --print-config、config lint 和清理预演报告
+func (c *Config) WriteEffective(w io.Writer, prefix string) {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	width := 0
+	for i := 0; i < t.NumField(); i++ {
+		if n := len(t.Field(i).Name); n > width {
+			width = n
+		}
+	}
+	fmt.Fprintf(w, "%s生效的配置:\n", prefix)
+	for i := 0; i < t.NumField(); i++ {
+		fmt.Fprintf(w, "%s  %-*s  %s\n", prefix, width, t.Field(i).Name, formatValue(v.Field(i)))
+	}
+}
+
+// formatValue 格式化单个配置项：字符串加引号（能看出空值和首尾空格），列表逐项加引号
+func formatValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("%q", v.String())
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = formatValue(v.Index(i))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
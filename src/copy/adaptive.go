@@ -0,0 +1,149 @@
+package copy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// adaptiveInterval 自适应并发的调整周期
+const adaptiveInterval = 2 * time.Second
+
+// adaptiveMinBytes 统计窗口内复制的数据少于该值时不按延迟调整（小文件的耗时主要是打开、重命名等固定开销，按字节折算波动太大）
+const adaptiveMinBytes = 1 << 20
+
+// adaptiveBaselineWeight 每个窗口的延迟计入基线的权重（指数移动平均），
+// 个别异常快的窗口不会把基线永久压低，目标端的正常延迟变化后基线也会随之调整
+const adaptiveBaselineWeight = 0.2
+
+// ConcurrencyController 根据目标端延迟和错误率动态调整同时执行的复制任务数
+// 采用加性增、乘性减（AIMD）策略：延迟接近基线时逐步增加并发，
+// 延迟明显升高或错误率过高时减半并发。延迟按每字节的耗时计算，只统计实际复制了数据的任务
+type ConcurrencyController struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int // 当前允许的并发数
+	active int // 正在执行的任务数
+	min    int
+	max    int
+
+	// 当前统计窗口
+	ops          int           // 完成的任务数（含跳过的文件，用于计算错误率）
+	errs         int           // 出错的任务数
+	bytes        int64         // 实际复制的字节数
+	totalLatency time.Duration // 实际复制数据的任务的总耗时
+
+	baseline float64 // 每字节耗时（纳秒）的指数移动平均，0 表示还没有样本
+	verbose  bool
+}
+
+// NewConcurrencyController 创建自适应并发控制器，initial 为初始并发数，max 为上限
+func NewConcurrencyController(initial, max int, verbose bool) *ConcurrencyController {
+	if max < initial {
+		max = initial
+	}
+	c := &ConcurrencyController{limit: initial, min: 1, max: max, verbose: verbose}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Acquire 等待直到可以开始一个新任务，控制器为 nil 时不做限制
+func (c *ConcurrencyController) Acquire() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	for c.active >= c.limit {
+		c.cond.Wait()
+	}
+	c.active++
+	c.mu.Unlock()
+}
+
+// Release 结束一个任务并记录其耗时、实际复制的字节数和是否出错
+// bytes 为 0（跳过的文件、整体复制的目录、限速期间的任务）时只计入错误率，不参与延迟统计
+func (c *ConcurrencyController) Release(latency time.Duration, bytes int64, failed bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.active--
+	c.ops++
+	if failed {
+		c.errs++
+	} else if bytes > 0 {
+		c.bytes += bytes
+		c.totalLatency += latency
+	}
+	c.mu.Unlock()
+	c.cond.Signal()
+}
+
+// run 周期性调整并发数，直到 stop 被关闭
+func (c *ConcurrencyController) run(stop <-chan struct{}) {
+	if c == nil {
+		return
+	}
+	ticker := time.NewTicker(adaptiveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.Adjust()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Adjust 根据上一个统计窗口的数据调整并发数
+func (c *ConcurrencyController) Adjust() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ops == 0 {
+		return
+	}
+	errorRate := float64(c.errs) / float64(c.ops)
+	var cost float64 // 每字节耗时（纳秒），0 表示本窗口复制的数据太少
+	if c.bytes >= adaptiveMinBytes {
+		cost = float64(c.totalLatency) / float64(c.bytes)
+	}
+	c.ops, c.errs, c.bytes, c.totalLatency = 0, 0, 0, 0
+
+	baseline := c.baseline
+	if cost > 0 {
+		if c.baseline == 0 {
+			c.baseline, baseline = cost, cost
+		} else {
+			c.baseline += (cost - c.baseline) * adaptiveBaselineWeight
+		}
+	}
+
+	old := c.limit
+	switch {
+	case errorRate > 0.1 || cost > baseline*2:
+		c.limit = c.limit / 2
+		if c.limit < c.min {
+			c.limit = c.min
+		}
+	case cost > 0 && cost <= baseline*3/2 && c.limit < c.max:
+		c.limit++
+	}
+
+	if c.limit != old {
+		if c.verbose {
+			ui.Printf("自适应并发: %d -> %d（每 MB 耗时 %v，基线 %v，错误率 %.0f%%）\n", old, c.limit,
+				time.Duration(cost*(1<<20)), time.Duration(baseline*(1<<20)), errorRate*100)
+		}
+		c.cond.Broadcast()
+	}
+}
+
+// Limit 返回当前并发数
+func (c *ConcurrencyController) Limit() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limit
+}
@@ -0,0 +1,93 @@
+package copy
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/manifest"
+)
+
+// Conflict 源文件和备份自上次运行后都被修改的文件
+type Conflict struct {
+	SrcPath    string // 源文件
+	DestPath   string // 备份文件
+	Resolution string // 本次运行的处理方式
+}
+
+// conflictTracker 基于上次运行的清单检测冲突，所有复制协程共享
+type conflictTracker struct {
+	mu        sync.Mutex
+	manifest  *manifest.Manifest
+	conflicts []Conflict
+}
+
+// conflicts 当前运行的冲突检测器，nil 表示没有上次运行的清单，不做检测
+var conflicts *conflictTracker
+
+// newConflictTracker 读取备份根目录的清单创建冲突检测器，没有清单时返回 nil
+func newConflictTracker(backupRoot string) *conflictTracker {
+	m, err := manifest.Load(backupRoot)
+	if err != nil || m == nil {
+		return nil
+	}
+	return &conflictTracker{manifest: m}
+}
+
+// check 判断源文件和备份是否自上次运行后都被修改，是则记录冲突
+// 备份在复制时会同步源文件的修改时间，因此清单中的修改时间同时代表上次运行时的源文件和备份
+func (c *conflictTracker) check(srcPath, destPath string, srcInfo, destInfo os.FileInfo) {
+	if c == nil || !srcInfo.Mode().IsRegular() || !destInfo.Mode().IsRegular() {
+		return
+	}
+	rel, err := filepath.Rel(config.GetGlobalConfig().BackupRoot, destPath)
+	if err != nil {
+		return
+	}
+	entry, ok := c.manifest.Entries[filepath.ToSlash(rel)]
+	if !ok {
+		return
+	}
+
+	// 备份：与清单记录逐字段比较（同一文件系统，精度一致）
+	destChanged := destInfo.Size() != entry.Size || !destInfo.ModTime().Equal(entry.ModTime)
+	// 源文件：只比较修改时间（分块存储时备份大小与源文件不同），按秒比较以兼容目标文件系统的时间精度
+	srcChanged := !srcInfo.ModTime().Truncate(time.Second).Equal(entry.ModTime.Truncate(time.Second))
+	if !destChanged || !srcChanged {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conflicts = append(c.conflicts, Conflict{
+		SrcPath:    srcPath,
+		DestPath:   destPath,
+		Resolution: conflictResolution(srcPath, srcInfo, destInfo),
+	})
+}
+
+// conflictResolution 描述本次运行对冲突文件的处理方式
+func conflictResolution(srcPath string, srcInfo, destInfo os.FileInfo) string {
+	cfg := config.GetGlobalConfig()
+	switch {
+	case srcInfo.ModTime().After(destInfo.ModTime()) && cfg.NoOverwrite:
+		return "源文件较新，新版本写入历史目录，备份保持不变"
+	case srcInfo.ModTime().After(destInfo.ModTime()):
+		return "源文件较新，覆盖备份，备份上的修改已移入历史目录"
+	case syncMatcher != nil && syncMatcher.ShouldExclude(srcPath) && srcInfo.ModTime().Before(destInfo.ModTime()):
+		return "备份较新，已取回到源位置，源文件上的修改已移入历史目录"
+	}
+	return "备份较新或相同，源文件上的修改未复制"
+}
+
+// list 返回记录的冲突
+func (c *conflictTracker) list() []Conflict {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Conflict(nil), c.conflicts...)
+}
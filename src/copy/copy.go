@@ -1,25 +1,144 @@
 package copy
 
 import (
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/aogg/copy-ignore/src/chunkstore"
 	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/errs"
 	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/fsguard"
 	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/layout"
 	"github.com/aogg/copy-ignore/src/scanner"
+	"github.com/aogg/copy-ignore/src/ui"
 )
 
+// bandwidthLimiter 所有复制协程共享的带宽限制器，nil 表示不限速
+var bandwidthLimiter *helpers.RateLimiter
+
+// Pause 运行时暂停/继续复制的开关（由信号或键盘命令控制），暂停期间不开始新任务，正在复制的文件也会停下
+var Pause = helpers.NewPauseGate()
+
+// throttle 为源文件读取加上暂停控制、带宽限制和中止检查
+func throttle(r io.Reader) io.Reader {
+	return bandwidthLimiter.Reader(Pause.Reader(abortableReader{r}))
+}
+
+// errAborted 出错数超过 --max-errors 后，正在复制的文件读取时返回的错误
+var errAborted = errors.New("运行已中止")
+
+// runAborted 出错数超过 --max-errors 后置位：不再派发新任务，正在复制的文件尽快结束并删除临时文件
+var runAborted atomic.Bool
+
+// abortCause 备份目标空间不足而中止时记录的错误，为 nil 表示因出错数超过 --max-errors 而中止
+var abortCause atomic.Pointer[errs.DestinationFullError]
+
+// runErrorCount 本次运行出错的文件数（由工作协程累加，用于 --max-errors 判断）
+var runErrorCount atomic.Int64
+
+// noteCopyError 记录一个出错的文件；超过 maxErrors 时中止运行（如备份目标在运行中途消失，继续尝试剩余文件没有意义）
+// 备份目标空间不足时剩余文件同样无法写入，不论出错数立即中止
+func noteCopyError(maxErrors int, err error) {
+	var full *errs.DestinationFullError
+	if errors.As(err, &full) {
+		if runAborted.CompareAndSwap(false, true) {
+			abortCause.Store(full)
+			ui.Errorf("%v，中止运行\n", err)
+		}
+		return
+	}
+	if maxErrors <= 0 || runErrorCount.Add(1) <= int64(maxErrors) {
+		return
+	}
+	if runAborted.CompareAndSwap(false, true) {
+		ui.Errorf("出错数超过 %d（最近的错误: %v），中止运行\n", maxErrors, err)
+	}
+}
+
+// abortError 返回运行中止的原因（没有记录原因时返回 nil，避免返回包含 nil 指针的接口值）
+func abortError() error {
+	if full := abortCause.Load(); full != nil {
+		return full
+	}
+	return nil
+}
+
+// aclWarned 本次运行是否已提示过复制访问控制列表失败（只提示一次，避免刷屏）
+var aclWarned atomic.Bool
+
+// preserveACL 按 --preserve-acl 将源文件的所有者和访问控制列表复制到目标
+func preserveACL(srcPath, destPath string) {
+	if !config.GetGlobalConfig().PreserveACL {
+		return
+	}
+	if err := helpers.CopyACL(srcPath, destPath); err != nil && aclWarned.CompareAndSwap(false, true) {
+		ui.Errorf("警告: 复制访问控制列表失败 %s: %v（之后的失败不再提示）\n", destPath, err)
+	}
+}
+
+// abortableReader 运行中止后读取立即返回 errAborted
+type abortableReader struct {
+	r io.Reader
+}
+
+func (a abortableReader) Read(p []byte) (int, error) {
+	if runAborted.Load() {
+		return 0, errAborted
+	}
+	return a.r.Read(p)
+}
+
+// syncMatcher 双向同步的文件模式（--sync），备份比源文件新时取回到源位置，nil 表示不同步
+var syncMatcher *exclude.Matcher
+
 // CopyResult 复制操作的结果统计
 type CopyResult struct {
-	Copied  int      // 实际复制的文件数
-	Skipped int      // 跳过的文件数（目标文件较新或相同）
-	Errors  int      // 复制出错的文件数
-	Logs    []string // 复制日志（延迟输出）
+	Copied      int        // 实际复制的文件数
+	Skipped     int        // 跳过的文件数（目标文件较新或相同）
+	Errors      int        // 复制出错的文件数
+	Logs        []string   // 复制日志（延迟输出）
+	Conflicts   []Conflict // 源文件和备份自上次运行后都被修改的文件
+	Stats       *RunStats  // 文件级统计（最大文件等）
+	Failures    []Failure  // 复制出错的文件（最多记录 maxRecordedFailures 个）
+	Aborted     bool       // 出错数超过 --max-errors，运行已中止
+	Unprocessed int        // 中止后未处理的文件数（未派发或复制被打断）
+	Panics      int        // 复制时发生 panic 的文件数（已恢复，同时计入出错数）
+	AbortCause  error      // 中止的原因：备份目标空间不足时为 *errs.DestinationFullError，出错数超过 --max-errors 时为 nil
+
+	Cleanup    *helpers.CleanupStats // 清理阶段的统计，未执行清理时为 nil
+	History    helpers.HistoryStats  // 覆盖和清理时保留的旧版本、轮换删除的旧版本占用的空间
+	Throughput ThroughputStats       // 复制各环节（获取信息、读取、写入、同步、重命名）的耗时
+}
+
+// maxRecordedFailures 结果中最多记录的出错文件数
+const maxRecordedFailures = 100
+
+// recordFailure 记录一个出错的文件（最多 maxRecordedFailures 个），并统计其中的 panic
+func (r *CopyResult) recordFailure(srcPath string, err error) {
+	var panicErr *errs.PanicError
+	if errors.As(err, &panicErr) {
+		r.Panics++
+	}
+	if len(r.Failures) < maxRecordedFailures {
+		r.Failures = append(r.Failures, Failure{SrcPath: srcPath, Error: err.Error(), Kind: errs.Kind(err)})
+	}
+}
+
+// Failure 单个文件的复制错误
+type Failure struct {
+	SrcPath string `json:"src"`
+	Error   string `json:"error"`
+	Kind    string `json:"kind,omitempty"` // 错误类别（permission、destination-full 等），见 errs.Kind
 }
 
 // RealTimeCopyResult 支持实时统计的复制结果
@@ -54,85 +173,159 @@ func (r *RealTimeCopyResult) SetTotal(total int) {
 	r.Total = total
 }
 
+// ProgressFunc 复制进度回调：每个文件处理完后调用，参数为当前的累计数和刚处理的文件
+type ProgressFunc func(copied, skipped, errors, total int, lastSrc, lastDest string)
+
 // CopyFiles 并行复制文件列表到指定目录
-func CopyFiles(files []scanner.IgnoredFileInfo, destRoot string, concurrency int, verbose bool, excluder *exclude.Matcher) (*CopyResult, error) {
+// 与 CopyFilesStreamWithProgress 使用同一条复制流水线（历史备份、排除规则、冲突检测、日志等行为一致），
+// 只是目标目录、并发数和详细模式由参数指定；destRoot 与配置的备份根目录相同时，仓库迁移和清理阶段同样进行
+func CopyFiles(files []scanner.IgnoredFileInfo, destRoot string, concurrency int, verbose bool, excluder exclude.Excluder) (*CopyResult, error) {
 	if len(files) == 0 {
 		return &CopyResult{}, nil
 	}
-
-	// 创建工作池
-	jobs := make(chan copyJob, len(files))
-	results := make(chan copyResult, len(files))
-
-	// 启动工作协程
-	var wg sync.WaitGroup
-	for i := 0; i < concurrency; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			copyWorker(jobs, results, excluder)
-		}()
-	}
-
-	// 发送复制任务
+	fileChan := make(chan scanner.IgnoredFileInfo, len(files))
 	for _, file := range files {
-		destPath := filepath.Join(destRoot, file.RelativePath)
-		jobs <- copyJob{
-			srcPath:  file.AbsPath,
-			destPath: destPath,
-			verbose:  verbose,
-		}
+		fileChan <- file
 	}
-	close(jobs)
+	close(fileChan)
+	return runPipeline(fileChan, pipelineOptions{
+		destRoot:    destRoot,
+		concurrency: concurrency,
+		verbose:     verbose,
+		excluder:    excluder,
+	})
+}
 
-	// 等待所有工作完成
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+// CopyFilesStreamWithProgress 从channel接收文件并异步复制到配置的备份根目录，支持实时进度反馈
+func CopyFilesStreamWithProgress(
+	fileChan <-chan scanner.IgnoredFileInfo,
+	onProgress ProgressFunc, // 进度回调，为 nil 时不回调
+	excluder exclude.Excluder,
+) (*CopyResult, error) {
+	cfg := config.GetGlobalConfig()
+	return runPipeline(fileChan, pipelineOptions{
+		destRoot:    cfg.BackupRoot,
+		concurrency: cfg.Concurrency,
+		verbose:     cfg.Verbose,
+		onProgress:  onProgress,
+		excluder:    excluder,
+	})
+}
 
-	// 收集结果
-	result := &CopyResult{}
-	for res := range results {
-		if res.err != nil {
-			if verbose {
-				fmt.Fprintf(os.Stderr, "复制失败 %s: %v\n", res.srcPath, res.err)
-			}
-			result.Errors++
-		} else if res.skipped {
-			result.Skipped++
-		} else {
-			result.Copied++
-		}
+// CopyScanStream 与 CopyFilesStreamWithProgress 相同，用于边扫描边复制：
+// scanFailed 被关闭表示扫描失败，此后不再派发尚未开始的任务（正在复制的文件照常完成），
+// 扫描结果不完整，也不执行清理阶段（不能据此移动或删除备份）。调用方应先关闭 scanFailed 再关闭 fileChan
+func CopyScanStream(
+	fileChan <-chan scanner.IgnoredFileInfo,
+	scanFailed <-chan struct{},
+	onProgress ProgressFunc, // 进度回调，为 nil 时不回调
+	excluder exclude.Excluder,
+) (*CopyResult, error) {
+	cfg := config.GetGlobalConfig()
+	return runPipeline(fileChan, pipelineOptions{
+		destRoot:    cfg.BackupRoot,
+		concurrency: cfg.Concurrency,
+		verbose:     cfg.Verbose,
+		onProgress:  onProgress,
+		excluder:    excluder,
+		scanFailed:  scanFailed,
+	})
+}
+
+// pipelineOptions 复制流水线的参数，其余行为（历史备份、同步、优先复制、带宽限制等）取自全局配置
+type pipelineOptions struct {
+	destRoot    string // 备份根目录
+	concurrency int    // 工作协程数（启用自适应并发时为初始并发数）
+	verbose     bool
+	onProgress  ProgressFunc // 为 nil 时不回调
+	excluder    exclude.Excluder
+	scanFailed  <-chan struct{} // 被关闭表示扫描失败（见 CopyScanStream），为 nil 时不检查
+}
+
+// closed 判断通道是否已关闭（nil 通道视为未关闭）
+func closed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
 	}
+}
 
-	return result, nil
+// sameDir 判断两个路径是否指向同一目录（只比较清理后的路径）
+func sameDir(a, b string) bool {
+	return a != "" && b != "" && filepath.Clean(a) == filepath.Clean(b)
 }
 
-// CopyFilesStreamWithProgress 从channel接收文件并异步复制，支持实时进度反馈
-func CopyFilesStreamWithProgress(
-	fileChan <-chan scanner.IgnoredFileInfo,
-	onProgress func(copied, skipped, errors, total int, lastSrc, lastDest string), // 进度回调
-	excluder *exclude.Matcher,
-) (*CopyResult, error) {
+// runPipeline 复制流水线：从 fileChan 接收文件，计算目标路径后按仓库轮流派发给工作协程，
+// 收集结果并回调进度，最后（复制到配置的备份根目录时）执行清理阶段
+func runPipeline(fileChan <-chan scanner.IgnoredFileInfo, opts pipelineOptions) (*CopyResult, error) {
 	cfg := config.GetGlobalConfig()
+	opts.concurrency = max(opts.concurrency, 1)
+
+	// 初始化带宽限制（配置已在参数验证阶段校验）
+	if schedule, err := helpers.ParseBandwidthSchedule(cfg.BandwidthLimit); err == nil {
+		bandwidthLimiter = helpers.NewRateLimiter(schedule)
+	}
+	runStats = newRunStats(cfg.WarnSize)
+	helpers.ResetHistoryStats()
+	helpers.ResetCopiedHashes()
+	resetThroughput()
+	started := time.Now()
+	runAborted.Store(false)
+	abortCause.Store(nil)
+	runErrorCount.Store(0)
+	aclWarned.Store(false)
+	Timer.reset()
+
+	// 基于上次运行的清单检测源文件和备份的冲突修改
+	conflicts = newConflictTracker(opts.destRoot)
+
+	syncMatcher = nil
+	if len(cfg.Sync) > 0 {
+		if m, err := exclude.NewMatcher(cfg.Sync); err == nil {
+			syncMatcher = m
+		}
+	}
+
+	// 优先复制的文件模式（--priority）
+	var priorityMatcher *exclude.Matcher
+	if len(cfg.Priority) > 0 {
+		if m, err := exclude.NewMatcher(cfg.Priority); err == nil {
+			priorityMatcher = m
+		}
+	}
 
 	result := &RealTimeCopyResult{}
 	var logMutex sync.Mutex
 	var logs []string
 
-	// 创建工作池，使用更大的缓冲区避免死锁
-	jobs := make(chan copyJob, 1000)
-	results := make(chan copyResult, 1000)
+	// 自适应并发：按上限启动工作协程，由控制器限制同时执行的任务数
+	workerCount := opts.concurrency
+	var controller *ConcurrencyController
+	if cfg.AdaptiveConcurrency {
+		controller = NewConcurrencyController(opts.concurrency, cfg.MaxConcurrency, opts.verbose)
+		workerCount = controller.max
+		stopController := make(chan struct{})
+		defer close(stopController)
+		go controller.run(stopController)
+	}
+
+	// 创建工作池：派发、复制和结果收集并发进行，队列满时上游等待下游（背压），
+	// 缓冲大小（--job-queue）只影响吞吐的平滑程度，不影响正确性。
+	// 待派发的任务按仓库排队、轮流派发（见 repoScheduler），任务队列只需让工作协程不空闲
+	jobs := make(chan copyJob, workerCount)
+	results := make(chan copyResult, cfg.JobQueueSize)
 
-	// 启动工作协程
+	// 启动工作协程，各自记录执行和等待任务的时间
 	var wg sync.WaitGroup
-	for i := 0; i < cfg.Concurrency; i++ {
+	workerTimes := make([]WorkerTime, workerCount)
+	for i := 0; i < workerCount; i++ {
 		wg.Add(1)
-		go func() {
+		go func(id int, t *WorkerTime) {
 			defer wg.Done()
-			copyWorker(jobs, results, excluder)
-		}()
+			copyWorker(id, jobs, results, opts.excluder, controller, t)
+		}(i+1, &workerTimes[i])
 	}
 
 	// 启动结果收集器
@@ -141,42 +334,149 @@ func CopyFilesStreamWithProgress(
 		close(results)
 	}()
 
-	// 从文件channel接收并发送到jobs，同时更新总数
+	// 备份目录布局映射
+	mapper, err := layout.Load(opts.destRoot, cfg.Layout)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SanitizeActive {
+		mapper.SanitizeNames()
+	}
+
+	// 被移动仓库的识别与备份迁移、清理阶段都依赖配置的搜索根目录，只在复制到配置的备份根目录时进行
+	managed := sameDir(opts.destRoot, cfg.BackupRoot)
+	migrator, err := layout.LoadMigrator(opts.destRoot, cfg.SearchRoot, mapper, cfg.MigrateMoved)
+	if err != nil {
+		return nil, err
+	}
+	seenRepos := make(map[string]bool)
+	cleanupScopes := []string{} // 本次扫描到的仓库在备份目标下的目录，清理只在其中进行
+
+	// 从文件channel接收，按仓库轮流发送到jobs，同时更新总数
+	var notDispatched int
+	var cleanup *helpers.CleanupStats
 	go func() {
 		fileCount := 0
 		targetPaths := make(map[string]string) // destPath -> srcPath，用于清理检查
 
-		for file := range fileChan {
-			destPath := filepath.Join(cfg.BackupRoot, file.RelativePath)
-			jobs <- copyJob{
+		// enqueue 计算文件的目标路径，加入所属仓库的待派发队列
+		sched := newRepoScheduler()
+		enqueue := func(file scanner.IgnoredFileInfo) {
+			// 仓库的第一个文件派发前，先处理仓库移动迁移
+			if managed && file.RepoRoot != "" && !seenRepos[file.RepoRoot] {
+				seenRepos[file.RepoRoot] = true
+				migrator.Observe(file.RepoRoot)
+				cleanupScopes = append(cleanupScopes, filepath.Join(opts.destRoot, mapper.RepoSubtree(file.RepoRoot, cfg.SearchRoot)))
+			}
+
+			rel := mapper.Resolve(file)
+			destPath := filepath.Join(opts.destRoot, rel)
+			// 路径超过文件系统限制（如 exFAT U 盘）时改存到哈希目录，原始路径记录在旁边
+			if helpers.DestPathTooLong(destPath, cfg.MaxPathLen) {
+				longPath := helpers.LongPathTarget(opts.destRoot, rel)
+				if err := helpers.WriteLongPathRecord(longPath, rel); err != nil {
+					ui.Errorf("记录过长路径失败 %s: %v\n", destPath, err)
+				} else {
+					if opts.verbose {
+						logMutex.Lock()
+						logs = append(logs, fmt.Sprintf("路径过长，改存到: %s -> %s", file.AbsPath, longPath))
+						logMutex.Unlock()
+					}
+					destPath = longPath
+				}
+			}
+			sched.push(copyJob{
 				srcPath:  file.AbsPath,
 				destPath: destPath,
-				verbose:  cfg.Verbose,
+				repoRoot: file.RepoRoot,
+				verbose:  opts.verbose,
 				logWriter: func(msg string) {
 					logMutex.Lock()
 					logs = append(logs, msg)
 					logMutex.Unlock()
 				},
-			}
+			}, priorityMatcher != nil && priorityMatcher.ShouldExclude(file.AbsPath))
 			fileCount++
 			result.SetTotal(fileCount)
 			targetPaths[destPath] = file.AbsPath
 		}
 
-		// 清理已删除的源文件对应的目标文件
-		if len(cfg.BackupDirs) > 0 {
-			helpers.CleanupDeletedSrcFiles(targetPaths)
+		// 待派发的任务最多 --job-queue 个，满时暂停接收扫描结果（背压）
+		pendingLimit := max(cfg.JobQueueSize, 1)
+		in := fileChan
+		for in != nil || sched.len() > 0 {
+			// 运行已中止或扫描失败：丢弃待派发的任务，继续接收扫描结果（避免扫描阻塞），但不再派发
+			if runAborted.Load() || closed(opts.scanFailed) {
+				notDispatched += sched.drop()
+				if in == nil {
+					break
+				}
+				if _, ok := <-in; ok {
+					notDispatched++
+				} else {
+					in = nil
+				}
+				continue
+			}
+
+			recv := in
+			if sched.len() >= pendingLimit {
+				recv = nil
+			}
+			var send chan<- copyJob
+			var next copyJob
+			if sched.len() > 0 {
+				send, next = jobs, sched.peek()
+			}
+			select {
+			case file, ok := <-recv:
+				if !ok {
+					in = nil
+					continue
+				}
+				enqueue(file)
+			case send <- next:
+				sched.pop()
+			case <-opts.scanFailed:
+			}
+		}
+
+		if err := mapper.Save(); err != nil {
+			ui.Errorf("保存仓库名映射失败: %v\n", err)
+		}
+		if managed && !cfg.AppendOnly {
+			if err := migrator.Save(); err != nil {
+				ui.Errorf("保存仓库身份记录失败: %v\n", err)
+			}
+		}
+
+		// 清理已删除的源文件对应的目标文件（不覆盖模式下已有文件不会被移动或删除）
+		// 运行中止或扫描失败时目标路径不完整，不能据此清理
+		if managed && len(cfg.BackupDirs) > 0 && !cfg.NoOverwrite && !runAborted.Load() && !closed(opts.scanFailed) {
+			// 达到 --max-files-per-repo 上限的仓库扫描结果不完整，不在其中清理
+			cleanupScopes = mapper.DropRepoScopes(cleanupScopes, scanner.TruncatedRepos(), opts.destRoot, cfg.SearchRoot)
+			stats := helpers.CleanupDeletedSrcFiles(targetPaths, cleanupScopes, func(rel string) string {
+				return mapper.Source(rel, cfg.SearchRoot)
+			})
+			cleanup = &stats
 		}
 
 		close(jobs)
 	}()
 
-	// 收集结果并实时反馈
+	// 收集结果并实时反馈（failed 只用于记录出错的文件）
+	failed := &CopyResult{}
+	interrupted := 0
 	for res := range results {
+		if res.aborted {
+			interrupted++
+			continue
+		}
 		if res.err != nil {
 			result.AddResult(0, 0, 1)
-			if cfg.Verbose {
-				fmt.Fprintf(os.Stderr, "复制失败 %s: %v\n", res.srcPath, res.err)
+			failed.recordFailure(res.srcPath, res.err)
+			if opts.verbose {
+				ui.Errorf("复制失败 %s: %v\n", res.srcPath, res.err)
 			}
 		} else if res.skipped {
 			result.AddResult(0, 1, 0)
@@ -185,19 +485,33 @@ func CopyFilesStreamWithProgress(
 		}
 
 		// 实时调用进度回调
-		if onProgress != nil {
+		if opts.onProgress != nil {
 			copied, skipped, errors, total := result.GetCurrentStats()
-			onProgress(copied, skipped, errors, total, res.srcPath, res.destPath)
+			opts.onProgress(copied, skipped, errors, total, res.srcPath, res.destPath)
 		}
 	}
 
+	if controller != nil && opts.verbose {
+		ui.Printf("自适应并发结束时的并发数: %d\n", controller.Limit())
+	}
+
 	// 返回最终结果
 	finalCopied, finalSkipped, finalErrors, _ := result.GetCurrentStats()
 	return &CopyResult{
-		Copied:  finalCopied,
-		Skipped: finalSkipped,
-		Errors:  finalErrors,
-		Logs:    logs,
+		Copied:      finalCopied,
+		Skipped:     finalSkipped,
+		Errors:      finalErrors,
+		Logs:        logs,
+		Conflicts:   conflicts.list(),
+		Stats:       runStats,
+		Failures:    failed.Failures,
+		Panics:      failed.Panics,
+		Aborted:     runAborted.Load(),
+		Unprocessed: interrupted + notDispatched,
+		AbortCause:  abortError(),
+		Cleanup:     cleanup,
+		History:     helpers.CurrentHistoryStats(),
+		Throughput:  throughputSnapshot(time.Since(started), workerTimes),
 	}, nil
 }
 
@@ -205,6 +519,7 @@ func CopyFilesStreamWithProgress(
 type copyJob struct {
 	srcPath   string
 	destPath  string
+	repoRoot  string
 	verbose   bool
 	logWriter func(string)
 }
@@ -215,34 +530,136 @@ type copyResult struct {
 	destPath string
 	skipped  bool
 	err      error
+	aborted  bool // 运行中止后未执行或被打断的任务
 }
 
-// copyWorker 执行复制工作的协程
-func copyWorker(jobs <-chan copyJob, results chan<- copyResult, excluder *exclude.Matcher) {
-	for job := range jobs {
-		skipped, err := copyFile(job.srcPath, job.destPath, job.verbose, job.logWriter, excluder)
+// copyWorker 执行复制工作的协程（编号 id），执行和等待任务的时间记入 t，正在执行的任务记入 activeJobs 供卡住检测
+// controller 不为 nil 时，每个任务执行前需获取并发配额，并上报耗时、复制的字节数和错误
+func copyWorker(id int, jobs <-chan copyJob, results chan<- copyResult, excluder exclude.Excluder, controller *ConcurrencyController, t *WorkerTime) {
+	for {
+		waitStart := time.Now()
+		job, ok := <-jobs
+		t.Idle += time.Since(waitStart)
+		if !ok {
+			return
+		}
+		// 运行已中止：排队中的任务不再执行
+		if runAborted.Load() {
+			results <- copyResult{srcPath: job.srcPath, destPath: job.destPath, aborted: true}
+			continue
+		}
+		Pause.Wait()
+		controller.Acquire()
+		start := time.Now()
+		skipped, err := runJobAbandonable(job, excluder, beginJob(id, job.srcPath))
+		endJob(id)
+		err = errs.Classify(err)
+		elapsed := time.Since(start)
+		t.Busy += elapsed
+		t.Files++
+		if controller != nil {
+			controller.Release(elapsed, transferSize(job, skipped, err), err != nil)
+		}
+		Timer.add(job.repoRoot, elapsed)
+		aborted := err != nil && runAborted.Load()
+		if err != nil && !aborted {
+			noteCopyError(config.GetGlobalConfig().MaxErrors, err)
+		}
 		results <- copyResult{
 			srcPath:  job.srcPath,
 			destPath: job.destPath,
 			skipped:  skipped,
 			err:      err,
+			aborted:  aborted,
 		}
 	}
 }
 
+// transferSize 返回任务实际复制的字节数，供自适应并发按吞吐量判断目标端的负载
+// 跳过的文件、出错的任务、整体复制的目录和限速时段中的任务（耗时主要是 --bwlimit 的等待）返回 0，不参与延迟统计
+func transferSize(job copyJob, skipped bool, err error) int64 {
+	if skipped || err != nil || bandwidthLimiter.Limiting() {
+		return 0
+	}
+	info, statErr := os.Lstat(job.srcPath)
+	if statErr != nil || !info.Mode().IsRegular() {
+		return 0
+	}
+	return info.Size()
+}
+
+// runJob 执行单个复制任务；复制过程中的 panic 被恢复并转换为该文件的错误，工作协程继续处理其余任务
+func runJob(job copyJob, excluder exclude.Excluder) (skipped bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr := &errs.PanicError{Value: r, Stack: debug.Stack()}
+			ui.Errorf("内部错误: 复制 %s 时发生 panic: %v\n%s\n", job.srcPath, r, panicErr.Stack)
+			skipped, err = false, panicErr
+		}
+	}()
+	return copyFile(job.srcPath, job.destPath, job.verbose, job.logWriter, excluder)
+}
+
 // copyFile 复制单个文件，如果目标文件存在且较新则跳过
-func copyFile(srcPath, destPath string, verbose bool, logWriter func(string), excluder *exclude.Matcher) (skipped bool, err error) {
+func copyFile(srcPath, destPath string, verbose bool, logWriter func(string), excluder exclude.Excluder) (skipped bool, err error) {
 	cfg := config.GetGlobalConfig()
 
 	// 获取源文件信息
-	srcInfo, err := os.Stat(srcPath)
+	statStart := time.Now()
+	srcInfo, err := statBounded(srcPath)
+	observe(phaseStat, statStart)
 	if err != nil {
-		return false, fmt.Errorf("获取源文件信息失败: %v", err)
+		return false, fmt.Errorf("获取源文件信息失败: %w", err)
+	}
+	// 云端占位文件：读取内容会触发下载，按 --placeholders 策略跳过或只记录元数据（需在读取文件头之前判断）
+	if cfg.Placeholders != config.PlaceholderHydrate && srcInfo.Mode().IsRegular() && helpers.IsCloudPlaceholder(srcInfo) {
+		runStats.recordPlaceholder(srcInfo.Size())
+		// 已有完整备份（文件被移回云端之前复制过）时保留备份，不再另外记录
+		if _, err := os.Stat(destPath); cfg.Placeholders == config.PlaceholderMetadata && os.IsNotExist(err) {
+			if err := helpers.WritePlaceholderStub(destPath, srcInfo); err != nil {
+				return false, fmt.Errorf("写入占位文件元数据失败: %w", err)
+			}
+		}
+		if verbose {
+			logWriter(fmt.Sprintf("跳过云端占位文件（未下载）: %s", srcPath))
+		}
+		return true, nil
+	}
+	// 只备份文本文件：按文件头识别出的二进制文件（构建产物、压缩包等）直接跳过
+	if cfg.SkipBinary && srcInfo.Mode().IsRegular() {
+		if binary, err := helpers.IsBinaryFile(srcPath); err == nil && binary {
+			if verbose {
+				logWriter(fmt.Sprintf("跳过二进制文件: %s", srcPath))
+			}
+			return true, nil
+		}
 	}
+	// 统计处理成功的普通文件（目录中的文件由递归调用各自统计）
+	defer func() {
+		if err == nil && srcInfo.Mode().IsRegular() {
+			runStats.record(srcPath, srcInfo.Size(), skipped)
+		}
+	}()
 
 	// 检查目标文件是否存在
-	destInfo, err := os.Stat(destPath)
+	statStart = time.Now()
+	destInfo, err := statBounded(destPath)
+	observe(phaseStat, statStart)
 	if err == nil {
+		conflicts.check(srcPath, destPath, srcInfo, destInfo)
+
+		// 双向同步的文件：备份比源文件新（在另一台机器上修改过），取回到源位置
+		if syncMatcher != nil && srcInfo.Mode().IsRegular() && destInfo.Mode().IsRegular() &&
+			srcInfo.ModTime().Before(destInfo.ModTime()) && syncMatcher.ShouldExclude(srcPath) {
+			if err := helpers.PullBackToSource(destPath, srcPath); err != nil {
+				return false, fmt.Errorf("从备份取回失败: %w", err)
+			}
+			if verbose {
+				logWriter(fmt.Sprintf("已从备份取回: %s -> %s", destPath, srcPath))
+			}
+			return false, nil
+		}
+
 		// 目标文件存在，比较修改时间
 		if srcInfo.ModTime().Before(destInfo.ModTime()) ||
 			srcInfo.ModTime().Equal(destInfo.ModTime()) {
@@ -253,55 +670,97 @@ func copyFile(srcPath, destPath string, verbose bool, logWriter func(string), ex
 			return true, nil
 		}
 
-		// 源文件比目标文件新，需要覆盖，先备份目标文件
-		if len(cfg.BackupDirs) > 0 {
-			if err := helpers.BackupFileBeforeOverwrite(destPath); err != nil {
-				// 备份失败不应该阻止复制，只记录错误
+		// 不覆盖模式：已有的目标文件保持不变，新版本直接写入历史目录
+		// 目录则逐个文件处理（只新增文件，已存在的文件同样写入历史目录）
+		if cfg.NoOverwrite && !srcInfo.IsDir() {
+			historyPath, ok := noOverwriteTarget(srcInfo, destPath)
+			if !ok {
+				return true, nil
+			}
+			if verbose {
+				logWriter(fmt.Sprintf("目标已存在，新版本写入历史目录: %s -> %s", srcPath, historyPath))
+			}
+			destPath = historyPath
+		} else if cfg.DeltaThreshold > 0 && !useChunkStore(srcInfo) && !srcInfo.IsDir() && destInfo.Mode().IsRegular() && srcInfo.Size() >= cfg.DeltaThreshold {
+			// 大文件增量更新：只从源文件读取变化的分块，其余从旧版本复制到临时文件（旧版本按 --overwrite 策略保留）
+			return deltaCopyFile(srcPath, destPath, srcInfo, verbose, logWriter)
+		}
+
+		// 源文件比目标文件新，需要覆盖，先按 --overwrite 策略保留目标文件的旧版本
+		if !cfg.NoOverwrite {
+			kept, err := helpers.PreserveBeforeOverwrite(destPath)
+			if err != nil {
+				// 保留旧版本失败不应该阻止复制，只记录错误
 				if verbose {
-					fmt.Fprintf(os.Stderr, "备份失败 %s: %v\n", destPath, err)
+					ui.Errorf("保留旧版本失败 %s: %v\n", destPath, err)
 				}
 			}
+			runStats.recordOverwrite(kept, err)
 		}
 	} else if !os.IsNotExist(err) {
 		// 其他错误
-		return false, fmt.Errorf("检查目标文件失败: %v", err)
+		return false, fmt.Errorf("检查目标文件失败: %w", err)
 	}
 
 	// 如果是目录，递归复制整个目录
 	if srcInfo.IsDir() {
-		return copyDir(srcPath, destPath, verbose, logWriter, excluder)
+		var followed []string
+		if cfg.ReparsePoints == config.ReparseFollow {
+			if real, err := scanner.RealPath(srcPath); err == nil {
+				followed = []string{real}
+			}
+		}
+		return copyDir(srcPath, destPath, verbose, logWriter, excluder, followed)
 	}
 
 	// 需要复制：创建目标目录
 	destDir := filepath.Dir(destPath)
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return false, fmt.Errorf("创建目标目录失败: %v", err)
+	if err := fsguard.MkdirAll(destDir, 0755, "创建目标目录"); err != nil {
+		return false, fmt.Errorf("创建目标目录失败: %w", err)
+	}
+
+	// 大文件存入块池，目标位置只保存配方
+	if useChunkStore(srcInfo) {
+		return chunkCopyFile(srcPath, destPath, srcInfo, verbose, logWriter)
 	}
 
 	// 原子复制：先写入临时文件，再重命名
-	tempPath := destPath + ".tmp"
-	if err := copyFileContent(srcPath, tempPath); err != nil {
+	tempPath := helpers.TempPath(destPath)
+	hash, err := copyFileContent(srcPath, tempPath)
+	if err != nil {
 		// 清理临时文件
-		os.Remove(tempPath)
-		return false, fmt.Errorf("复制文件内容失败: %v", err)
+		fsguard.Remove(tempPath, "删除复制失败的临时文件")
+		return false, fmt.Errorf("复制文件内容失败: %w", err)
 	}
 
 	// 原子重命名
-	if err := os.Rename(tempPath, destPath); err != nil {
+	renameStart := time.Now()
+	err = boundedErr("重命名", destPath, func() error {
+		return fsguard.Rename(tempPath, destPath, "复制完成，替换为新版本")
+	})
+	observe(phaseRename, renameStart)
+	if err != nil {
 		// 清理临时文件
-		os.Remove(tempPath)
-		return false, fmt.Errorf("重命名文件失败: %v", err)
+		fsguard.Remove(tempPath, "删除重命名失败的临时文件")
+		return false, fmt.Errorf("重命名文件失败: %w", err)
 	}
 
 	// 设置目标文件的修改时间为源文件的修改时间
 	now := time.Now()
-	if err := os.Chtimes(destPath, now, srcInfo.ModTime()); err != nil {
+	if err := fsguard.Chtimes(destPath, now, srcInfo.ModTime(), "同步修改时间"); err != nil {
 		// 这不是致命错误，只是记录警告
 		if verbose {
-			fmt.Fprintf(os.Stderr, "警告: 设置文件时间失败 %s: %v\n", destPath, err)
+			ui.Errorf("警告: 设置文件时间失败 %s: %v\n", destPath, err)
 		}
 	}
 
+	preserveACL(srcPath, destPath)
+
+	// 记录写入时计算的哈希，更新清单时不必再读一遍文件
+	if info, err := os.Stat(destPath); err == nil {
+		helpers.RecordCopiedHash(destPath, info, config.GetGlobalConfig().Hash, hash)
+	}
+
 	if verbose {
 		logWriter(fmt.Sprintf("已复制: %s -> %s", srcPath, destPath))
 	}
@@ -309,46 +768,165 @@ func copyFile(srcPath, destPath string, verbose bool, logWriter func(string), ex
 	return false, nil
 }
 
-// copyFileContent 复制文件内容
-func copyFileContent(srcPath, destPath string) error {
-	srcFile, err := os.Open(srcPath)
+// noOverwriteTarget 不覆盖模式下返回新版本在历史目录中的写入位置
+// 最新的历史版本已与源文件一致（上次运行已写入）时返回 false，避免每次运行重复写入
+func noOverwriteTarget(srcInfo os.FileInfo, destPath string) (string, bool) {
+	cfg := config.GetGlobalConfig()
+	relPath, err := filepath.Rel(cfg.BackupRoot, destPath)
+	if err != nil {
+		relPath = filepath.Base(destPath)
+	}
+	if latest := helpers.LatestHistoryVersion(relPath); latest != nil && !srcInfo.ModTime().After(latest.ModTime()) {
+		return "", false
+	}
+	return filepath.Join(cfg.HandleHistoryDir(cfg.BackupRoot), relPath), true
+}
+
+// useChunkStore 判断文件是否使用分块存储
+func useChunkStore(srcInfo os.FileInfo) bool {
+	cfg := config.GetGlobalConfig()
+	return cfg.ChunkThreshold > 0 && srcInfo.Mode().IsRegular() && srcInfo.Size() >= cfg.ChunkThreshold
+}
+
+// chunkCopyFile 将文件内容分块写入块池（已有的块不重复写入），并在目标位置写入配方文件
+func chunkCopyFile(srcPath, destPath string, srcInfo os.FileInfo, verbose bool, logWriter func(string)) (skipped bool, err error) {
+	src, err := bounded("打开", srcPath, func() (*os.File, error) { return os.Open(srcPath) })
 	if err != nil {
-		return err
+		return false, err
+	}
+	defer src.Close()
+
+	store := chunkstore.Open(config.GetGlobalConfig().BackupRoot)
+	recipe, written, err := store.Put(throttle(timedReader{timeoutReader{src, srcPath}}))
+	if err != nil {
+		return false, fmt.Errorf("分块存储失败: %w", err)
+	}
+
+	tempPath := helpers.TempPath(destPath)
+	if err := chunkstore.WriteRecipe(tempPath, recipe); err != nil {
+		fsguard.Remove(tempPath, "删除写入失败的临时配方")
+		return false, fmt.Errorf("写入配方失败: %w", err)
+	}
+	if err := fsguard.Rename(tempPath, destPath, "分块存储完成，替换为新配方"); err != nil {
+		fsguard.Remove(tempPath, "删除重命名失败的临时配方")
+		return false, fmt.Errorf("重命名配方失败: %w", err)
+	}
+
+	if err := fsguard.Chtimes(destPath, time.Now(), srcInfo.ModTime(), "同步修改时间"); err != nil {
+		if verbose {
+			ui.Errorf("警告: 设置文件时间失败 %s: %v\n", destPath, err)
+		}
+	}
+
+	preserveACL(srcPath, destPath)
+
+	if verbose {
+		logWriter(fmt.Sprintf("已分块存储: %s -> %s（%d 块，新写入 %s / %s）", srcPath, destPath, len(recipe.Chunks), helpers.FormatSize(written), helpers.FormatSize(srcInfo.Size())))
+	}
+	return false, nil
+}
+
+// deltaCopyFile 以增量方式更新已存在的目标文件：新版本在临时文件中完整生成后，
+// 按 --overwrite 策略保留旧版本，再原子地替换并同步修改时间
+func deltaCopyFile(srcPath, destPath string, srcInfo os.FileInfo, verbose bool, logWriter func(string)) (skipped bool, err error) {
+	tempPath := helpers.TempPath(destPath)
+	written, err := deltaBuildFile(srcPath, destPath, tempPath)
+	if err != nil {
+		fsguard.Remove(tempPath, "删除增量更新失败的临时文件")
+		return false, fmt.Errorf("增量更新失败: %w", err)
+	}
+
+	kept, err := helpers.PreserveBeforeOverwrite(destPath)
+	if err != nil {
+		// 与完整复制相同，保留旧版本失败不阻止更新
+		if verbose {
+			ui.Errorf("保留旧版本失败 %s: %v\n", destPath, err)
+		}
+	}
+	runStats.recordOverwrite(kept, err)
+
+	renameStart := time.Now()
+	err = boundedErr("重命名", destPath, func() error {
+		return fsguard.Rename(tempPath, destPath, "增量更新完成，替换为新版本")
+	})
+	observe(phaseRename, renameStart)
+	if err != nil {
+		fsguard.Remove(tempPath, "删除重命名失败的临时文件")
+		return false, fmt.Errorf("重命名文件失败: %w", err)
+	}
+
+	if err := fsguard.Chtimes(destPath, time.Now(), srcInfo.ModTime(), "增量更新完成，同步修改时间"); err != nil {
+		if verbose {
+			ui.Errorf("警告: 设置文件时间失败 %s: %v\n", destPath, err)
+		}
+	}
+
+	preserveACL(srcPath, destPath)
+
+	if verbose {
+		logWriter(fmt.Sprintf("已增量更新: %s -> %s（读取新数据 %s / %s）", srcPath, destPath, helpers.FormatSize(written), helpers.FormatSize(srcInfo.Size())))
+	}
+	return false, nil
+}
+
+// copyFileContent 复制文件内容，同时按 --hash 的算法计算写入内容的哈希（十六进制），避免生成清单时再读一遍
+func copyFileContent(srcPath, destPath string) (string, error) {
+	srcFile, err := bounded("打开", srcPath, func() (*os.File, error) { return os.Open(srcPath) })
+	if err != nil {
+		return "", err
 	}
 	defer srcFile.Close()
 
-	destFile, err := os.Create(destPath)
+	destFile, err := bounded("创建", destPath, func() (*os.File, error) {
+		return fsguard.Create(destPath, "写入临时文件")
+	})
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer destFile.Close()
 
-	_, err = io.Copy(destFile, srcFile)
+	h, err := helpers.NewHasher(config.GetGlobalConfig().Hash)
 	if err != nil {
-		return err
+		return "", err
+	}
+	_, err = io.Copy(io.MultiWriter(timedWriter{timeoutWriter{destFile, destPath}}, h), throttle(timedReader{timeoutReader{srcFile, srcPath}}))
+	if err != nil {
+		return "", err
 	}
 
 	// 确保数据写入磁盘
-	return destFile.Sync()
+	syncStart := time.Now()
+	err = boundedErr("同步", destPath, destFile.Sync)
+	observe(phaseFsync, syncStart)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 // copyDir 递归复制目录
-func copyDir(srcPath, destPath string, verbose bool, logWriter func(string), excluder *exclude.Matcher) (skipped bool, err error) {
+// followed 是沿当前路径已跟随过的目录链接目标（真实路径），用于检测 --reparse-points follow 时的环路
+func copyDir(srcPath, destPath string, verbose bool, logWriter func(string), excluder exclude.Excluder, followed []string) (skipped bool, err error) {
 	// 创建目标目录
-	if err := os.MkdirAll(destPath, 0755); err != nil {
-		return false, fmt.Errorf("创建目标目录失败: %v", err)
+	if err := fsguard.MkdirAll(destPath, 0755, "创建目标目录"); err != nil {
+		return false, fmt.Errorf("创建目标目录失败: %w", err)
 	}
 
+	preserveACL(srcPath, destPath)
+
 	// 读取源目录内容
 	entries, err := os.ReadDir(srcPath)
 	if err != nil {
-		return false, fmt.Errorf("读取源目录失败: %v", err)
+		return false, fmt.Errorf("读取源目录失败: %w", err)
 	}
 
 	// 递归复制所有文件和子目录
 	for _, entry := range entries {
 		srcEntryPath := filepath.Join(srcPath, entry.Name())
 		destEntryPath := filepath.Join(destPath, entry.Name())
+		if config.GetGlobalConfig().SanitizeActive {
+			destEntryPath = filepath.Join(destPath, helpers.SanitizePath(entry.Name()))
+		}
 
 		// 检查是否应该排除此路径
 		if excluder != nil && excluder.ShouldExclude(srcEntryPath) {
@@ -358,15 +936,39 @@ func copyDir(srcPath, destPath string, verbose bool, logWriter func(string), exc
 			continue
 		}
 
+		// 目录链接按 --reparse-points 策略跳过或跟随（避免环路）；AppExecLink 等无法读取的重解析点直接跳过
+		switch scanner.ClassifyDirEntry(srcEntryPath, entry) {
+		case scanner.LinkDir:
+			target, ok := "", false
+			if config.GetGlobalConfig().ReparsePoints == config.ReparseFollow {
+				target, ok = scanner.ResolveDirLink(srcEntryPath, followed)
+			}
+			if !ok {
+				if verbose {
+					logWriter(fmt.Sprintf("跳过目录链接: %s", srcEntryPath))
+				}
+				continue
+			}
+			if _, err := copyDir(srcEntryPath, destEntryPath, verbose, logWriter, excluder, append(followed, target)); err != nil {
+				return false, fmt.Errorf("复制子目录失败 %s: %w", srcEntryPath, err)
+			}
+			continue
+		case scanner.LinkSpecial:
+			if verbose {
+				logWriter(fmt.Sprintf("跳过重解析点: %s", srcEntryPath))
+			}
+			continue
+		}
+
 		if entry.IsDir() {
 			// 递归复制子目录
-			if _, err := copyDir(srcEntryPath, destEntryPath, verbose, logWriter, excluder); err != nil {
-				return false, fmt.Errorf("复制子目录失败 %s: %v", srcEntryPath, err)
+			if _, err := copyDir(srcEntryPath, destEntryPath, verbose, logWriter, excluder, followed); err != nil {
+				return false, fmt.Errorf("复制子目录失败 %s: %w", srcEntryPath, err)
 			}
 		} else {
 			// 复制文件
 			if _, err := copyFile(srcEntryPath, destEntryPath, verbose, logWriter, excluder); err != nil {
-				return false, fmt.Errorf("复制文件失败 %s: %v", srcEntryPath, err)
+				return false, fmt.Errorf("复制文件失败 %s: %w", srcEntryPath, err)
 			}
 		}
 	}
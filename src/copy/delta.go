@@ -0,0 +1,225 @@
+package copy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aogg/copy-ignore/src/fsguard"
+)
+
+// deltaBlockSize 增量传输的分块大小
+const deltaBlockSize = 64 << 10
+
+// deltaOp 增量操作：block >= 0 表示复用旧文件的第 block 块，
+// 否则表示从源文件 offset 处读取 length 字节的新数据
+type deltaOp struct {
+	block  int
+	offset int64
+	length int64
+}
+
+// blockSignature 旧文件的分块签名（弱校验和 -> 块序号，以及每块的强哈希）
+type blockSignature struct {
+	weak   map[uint32][]int
+	strong [][sha256.Size]byte
+	sizes  []int
+}
+
+// weakSum 计算 rsync 风格的弱校验和（a、b 两部分，各取低 16 位）
+func weakSum(data []byte) (a, b uint32) {
+	n := uint32(len(data))
+	for i, c := range data {
+		a += uint32(c)
+		b += (n - uint32(i)) * uint32(c)
+	}
+	return a & 0xffff, b & 0xffff
+}
+
+// buildSignature 读取旧文件，计算每个分块的弱校验和与强哈希
+func buildSignature(r io.Reader) (*blockSignature, error) {
+	sig := &blockSignature{weak: make(map[uint32][]int)}
+	buf := make([]byte, deltaBlockSize)
+	for i := 0; ; i++ {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			a, b := weakSum(buf[:n])
+			key := b<<16 | a
+			sig.weak[key] = append(sig.weak[key], i)
+			sig.strong = append(sig.strong, sha256.Sum256(buf[:n]))
+			sig.sizes = append(sig.sizes, n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return sig, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// find 查找与窗口内容一致的旧文件分块
+func (s *blockSignature) find(a, b uint32, window []byte) (int, bool) {
+	candidates := s.weak[b<<16|a]
+	if len(candidates) == 0 {
+		return 0, false
+	}
+	strong := sha256.Sum256(window)
+	for _, i := range candidates {
+		if s.sizes[i] == len(window) && bytes.Equal(s.strong[i][:], strong[:]) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// computeDelta 使用滚动校验和扫描源文件，生成相对旧文件的增量操作列表
+// 新数据以源文件中的偏移量记录，不在内存中保存内容
+func computeDelta(src io.Reader, sig *blockSignature) ([]deltaOp, error) {
+	var ops []deltaOp
+	buf := make([]byte, 0, 4*deltaBlockSize)
+	var base int64 // buf[0] 在源文件中的偏移
+	pos := 0       // 当前窗口在 buf 中的起点
+	eof := false
+	literalStart := int64(-1)
+
+	fill := func() error {
+		if eof || len(buf)-pos >= deltaBlockSize {
+			return nil
+		}
+		copied := copy(buf, buf[pos:])
+		buf = buf[:copied]
+		base += int64(pos)
+		pos = 0
+		for len(buf) < cap(buf) && !eof {
+			n, err := src.Read(buf[len(buf):cap(buf)])
+			buf = buf[:len(buf)+n]
+			if err == io.EOF {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	flushLiteral := func(end int64) {
+		if literalStart >= 0 && end > literalStart {
+			ops = append(ops, deltaOp{block: -1, offset: literalStart, length: end - literalStart})
+		}
+		literalStart = -1
+	}
+
+	var a, b uint32
+	sumLen := 0 // 当前校验和对应的窗口长度，0 表示需要重新计算
+	var out byte
+	rolled := false
+
+	for {
+		if err := fill(); err != nil {
+			return nil, err
+		}
+		avail := len(buf) - pos
+		if avail == 0 {
+			break
+		}
+		if avail < deltaBlockSize {
+			// 文件尾部不足一块：只尝试整体匹配旧文件的最后一块，避免逐字节重算校验和
+			tail := buf[pos:]
+			ta, tb := weakSum(tail)
+			if block, ok := sig.find(ta, tb, tail); ok {
+				flushLiteral(base + int64(pos))
+				ops = append(ops, deltaOp{block: block})
+				pos = len(buf)
+			} else if literalStart < 0 {
+				literalStart = base + int64(pos)
+			}
+			break
+		}
+		n := deltaBlockSize
+		window := buf[pos : pos+n]
+
+		if rolled && sumLen == n {
+			// 滚动更新：移出 out，移入窗口末尾的新字节
+			in := uint32(window[n-1])
+			a = (a - uint32(out) + in) & 0xffff
+			b = (b - uint32(n)*uint32(out) + a) & 0xffff
+		} else {
+			a, b = weakSum(window)
+			sumLen = n
+		}
+		rolled = false
+
+		if block, ok := sig.find(a, b, window); ok {
+			flushLiteral(base + int64(pos))
+			ops = append(ops, deltaOp{block: block})
+			pos += n
+			sumLen = 0
+			continue
+		}
+
+		if literalStart < 0 {
+			literalStart = base + int64(pos)
+		}
+		out = buf[pos]
+		pos++
+		rolled = true
+	}
+	flushLiteral(base + int64(len(buf)))
+
+	return ops, nil
+}
+
+// deltaBuildFile 以增量方式将 srcPath 的新内容写入 tempPath，返回从源文件读取的新数据字节数
+// 未变化的分块从 basisPath（已有的目标文件）复制，旧文件本身不被修改：中途失败时目标保持原样，下次运行照常重试
+func deltaBuildFile(srcPath, basisPath, tempPath string) (int64, error) {
+	basis, err := os.Open(basisPath)
+	if err != nil {
+		return 0, err
+	}
+	defer basis.Close()
+	sig, err := buildSignature(basis)
+	if err != nil {
+		return 0, fmt.Errorf("计算旧文件签名失败: %w", err)
+	}
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	ops, err := computeDelta(throttle(src), sig)
+	if err != nil {
+		return 0, fmt.Errorf("计算增量失败: %w", err)
+	}
+
+	out, err := fsguard.Create(tempPath, "增量更新：写入临时文件")
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	var written int64
+	for _, op := range ops {
+		var r io.Reader
+		if op.block >= 0 {
+			r = io.NewSectionReader(basis, int64(op.block)*deltaBlockSize, int64(sig.sizes[op.block]))
+		} else {
+			r = io.NewSectionReader(src, op.offset, op.length)
+		}
+		n, err := io.Copy(out, r)
+		if op.block < 0 {
+			written += n
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+	if err := out.Sync(); err != nil {
+		return written, err
+	}
+	return written, out.Close()
+}
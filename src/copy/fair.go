@@ -0,0 +1,76 @@
+package copy
+
+// repoScheduler 按仓库轮流派发复制任务
+// 被忽略文件极多的仓库（如几十万个缓存文件）不会占满任务队列，其他仓库的少量文件（如 .env）不必排在其后；
+// 匹配 --priority 的任务不分仓库，排在所有其他任务之前
+type repoScheduler struct {
+	priority []copyJob            // 优先派发的任务
+	queues   map[string][]copyJob // 仓库根目录 -> 待派发的任务
+	order    []string             // 有待派发任务的仓库，按轮转顺序
+	next     int                  // 下一个派发的仓库在 order 中的位置
+	size     int                  // 待派发的任务总数
+}
+
+func newRepoScheduler() *repoScheduler {
+	return &repoScheduler{queues: make(map[string][]copyJob)}
+}
+
+// len 返回待派发的任务总数
+func (s *repoScheduler) len() int {
+	return s.size
+}
+
+// push 将任务加入所属仓库的队列，priority 为 true 时加入优先队列
+func (s *repoScheduler) push(job copyJob, priority bool) {
+	if priority {
+		s.priority = append(s.priority, job)
+		s.size++
+		return
+	}
+	if len(s.queues[job.repoRoot]) == 0 {
+		s.order = append(s.order, job.repoRoot)
+	}
+	s.queues[job.repoRoot] = append(s.queues[job.repoRoot], job)
+	s.size++
+}
+
+// peek 返回下一个应派发的任务（调用前需确认 len() > 0）
+func (s *repoScheduler) peek() copyJob {
+	if len(s.priority) > 0 {
+		return s.priority[0]
+	}
+	return s.queues[s.order[s.next]][0]
+}
+
+// pop 移除 peek 返回的任务，并轮到下一个仓库
+func (s *repoScheduler) pop() {
+	if len(s.priority) > 0 {
+		s.priority = s.priority[1:]
+		s.size--
+		return
+	}
+	repo := s.order[s.next]
+	queue := s.queues[repo][1:]
+	s.size--
+	if len(queue) == 0 {
+		delete(s.queues, repo)
+		s.order = append(s.order[:s.next], s.order[s.next+1:]...)
+	} else {
+		s.queues[repo] = queue
+		s.next++
+	}
+	if s.next >= len(s.order) {
+		s.next = 0
+	}
+}
+
+// drop 丢弃所有待派发的任务，返回丢弃的数量
+func (s *repoScheduler) drop() int {
+	n := s.size
+	s.priority = nil
+	s.queues = make(map[string][]copyJob)
+	s.order = nil
+	s.next = 0
+	s.size = 0
+	return n
+}
@@ -0,0 +1,118 @@
+package copy
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/errs"
+	"github.com/aogg/copy-ignore/src/exclude"
+)
+
+// lastActivity 最近一次复制有进展（读写数据、获取信息、完成任务等）的时间（UnixNano），用于卡住检测
+var lastActivity atomic.Int64
+
+// touch 记录复制有进展
+func touch() {
+	lastActivity.Store(time.Now().UnixNano())
+}
+
+// LastActivity 返回最近一次复制有进展的时间（本次运行还没有进展时为零值）
+func LastActivity() time.Time {
+	if n := lastActivity.Load(); n != 0 {
+		return time.Unix(0, n)
+	}
+	return time.Time{}
+}
+
+// ActiveJob 工作协程正在执行的复制任务
+type ActiveJob struct {
+	Worker  int           // 工作协程编号（从 1 开始）
+	SrcPath string        // 任务的源路径（整体复制的目录为目录路径）
+	Running time.Duration // 任务已执行的时间
+}
+
+// activeJob 工作协程正在执行的任务，abandon 关闭后工作协程放弃该任务
+type activeJob struct {
+	src     string
+	started time.Time
+	abandon chan struct{}
+	once    sync.Once
+}
+
+// activeJobs 各工作协程正在执行的任务（按工作协程编号）
+var (
+	activeMu   sync.Mutex
+	activeJobs = make(map[int]*activeJob)
+)
+
+// beginJob 记录工作协程开始执行任务
+func beginJob(worker int, src string) *activeJob {
+	j := &activeJob{src: src, started: time.Now(), abandon: make(chan struct{})}
+	activeMu.Lock()
+	activeJobs[worker] = j
+	activeMu.Unlock()
+	return j
+}
+
+// endJob 记录工作协程结束当前任务
+func endJob(worker int) {
+	activeMu.Lock()
+	delete(activeJobs, worker)
+	activeMu.Unlock()
+	touch()
+}
+
+// ActiveJobs 返回各工作协程正在执行的任务（按工作协程编号排序）
+func ActiveJobs() []ActiveJob {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	jobs := make([]ActiveJob, 0, len(activeJobs))
+	for worker, j := range activeJobs {
+		jobs = append(jobs, ActiveJob{Worker: worker, SrcPath: j.src, Running: time.Since(j.started)})
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].Worker < jobs[k].Worker })
+	return jobs
+}
+
+// AbandonStalled 放弃已执行超过 olderThan 的任务（需开启 --stall-abort），返回被放弃的任务
+// 工作协程不再等待这些任务，记为出错后继续处理其余文件；卡住的系统调用无法中断，会在后台一直等到返回
+func AbandonStalled(olderThan time.Duration) []ActiveJob {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	var abandoned []ActiveJob
+	for worker, j := range activeJobs {
+		running := time.Since(j.started)
+		if running < olderThan {
+			continue
+		}
+		j.once.Do(func() { close(j.abandon) })
+		abandoned = append(abandoned, ActiveJob{Worker: worker, SrcPath: j.src, Running: running})
+	}
+	sort.Slice(abandoned, func(i, k int) bool { return abandoned[i].Worker < abandoned[k].Worker })
+	return abandoned
+}
+
+// runJobAbandonable 执行任务；开启 --stall-abort 时在单独的协程中执行，任务被放弃后立即返回 StalledError
+func runJobAbandonable(job copyJob, excluder exclude.Excluder, j *activeJob) (bool, error) {
+	if !config.GetGlobalConfig().StallAbort {
+		return runJob(job, excluder)
+	}
+	type outcome struct {
+		skipped bool
+		err     error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		skipped, err := runJob(job, excluder)
+		done <- outcome{skipped, err}
+	}()
+	select {
+	case o := <-done:
+		return o.skipped, o.err
+	case <-j.abandon:
+		return false, &errs.StalledError{Path: job.srcPath, Idle: time.Since(LastActivity())}
+	}
+}
@@ -0,0 +1,178 @@
+package copy
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// largestFilesCount 汇总中列出的最大文件数
+const largestFilesCount = 20
+
+// FileStat 单个文件的大小记录
+type FileStat struct {
+	Path    string
+	Size    int64
+	Skipped bool // 是否因目标已是最新而跳过
+}
+
+// ExtStat 按扩展名汇总的统计
+type ExtStat struct {
+	Ext          string // 扩展名（小写，含点号），无扩展名时为空
+	Copied       int    // 复制的文件数
+	CopiedBytes  int64  // 复制的字节数
+	Skipped      int    // 跳过的文件数
+	SkippedBytes int64  // 跳过的字节数
+}
+
+// TotalBytes 返回该扩展名的总字节数
+func (e *ExtStat) TotalBytes() int64 {
+	return e.CopiedBytes + e.SkippedBytes
+}
+
+// RunStats 本次运行的文件级统计，所有复制协程共享
+type RunStats struct {
+	mu             sync.Mutex
+	LargestCopied  []FileStat          // 复制的最大文件（从大到小）
+	LargestSkipped []FileStat          // 跳过的最大文件（从大到小）
+	byExt          map[string]*ExtStat // 扩展名 -> 统计
+	warnSize       int64               // 超大文件阈值（--warn-size），0 表示不检查
+	oversized      []FileStat          // 不小于阈值的文件
+	placeholders   int                 // 未下载的云端占位文件数
+	placeholderLen int64               // 云端占位文件的总大小（字节）
+	overwrites     OverwriteStats      // 覆盖已有目标文件的统计
+}
+
+// OverwriteStats 覆盖已有目标文件的统计（旧版本按 --overwrite 策略处理）
+type OverwriteStats struct {
+	Preserved int // 旧版本已保留（移入历史目录或在原位置重命名）
+	Discarded int // 旧版本未保留（--overwrite none 或没有可用的备份目录）
+	Failed    int // 保留旧版本失败，已直接覆盖
+}
+
+// Total 返回覆盖的目标文件总数
+func (o OverwriteStats) Total() int {
+	return o.Preserved + o.Discarded + o.Failed
+}
+
+// newRunStats 创建运行统计
+func newRunStats(warnSize int64) *RunStats {
+	return &RunStats{byExt: make(map[string]*ExtStat), warnSize: warnSize}
+}
+
+// Oversized 返回不小于 --warn-size 阈值的文件（从大到小）
+func (s *RunStats) Oversized() []FileStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := append([]FileStat(nil), s.oversized...)
+	sort.Slice(list, func(i, j int) bool { return list[i].Size > list[j].Size })
+	return list
+}
+
+// Placeholders 返回未下载、未复制内容的云端占位文件数及其总大小
+func (s *RunStats) Placeholders() (int, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.placeholders, s.placeholderLen
+}
+
+// Overwrites 返回覆盖已有目标文件的统计
+func (s *RunStats) Overwrites() OverwriteStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.overwrites
+}
+
+// ByExtension 返回按扩展名汇总的统计，按总字节数从大到小排序
+func (s *RunStats) ByExtension() []ExtStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]ExtStat, 0, len(s.byExt))
+	for _, e := range s.byExt {
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].TotalBytes() != list[j].TotalBytes() {
+			return list[i].TotalBytes() > list[j].TotalBytes()
+		}
+		return list[i].Ext < list[j].Ext
+	})
+	return list
+}
+
+// runStats 当前运行的统计，nil 表示不统计
+var runStats *RunStats
+
+// record 记录一个已处理的文件
+func (s *RunStats) record(path string, size int64, skipped bool) {
+	if s == nil {
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := FileStat{Path: path, Size: size, Skipped: skipped}
+	if s.warnSize > 0 && size >= s.warnSize {
+		s.oversized = append(s.oversized, f)
+	}
+
+	e := s.byExt[ext]
+	if e == nil {
+		e = &ExtStat{Ext: ext}
+		s.byExt[ext] = e
+	}
+	if skipped {
+		s.LargestSkipped = insertLargest(s.LargestSkipped, f)
+		e.Skipped++
+		e.SkippedBytes += size
+	} else {
+		s.LargestCopied = insertLargest(s.LargestCopied, f)
+		e.Copied++
+		e.CopiedBytes += size
+	}
+}
+
+// recordPlaceholder 记录一个未下载的云端占位文件
+func (s *RunStats) recordPlaceholder(size int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.placeholders++
+	s.placeholderLen += size
+}
+
+// recordOverwrite 记录一次覆盖：kept 为旧版本保存的位置，err 为保留旧版本时的错误
+func (s *RunStats) recordOverwrite(kept string, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case err != nil && kept == "":
+		s.overwrites.Failed++
+	case kept != "":
+		s.overwrites.Preserved++
+	default:
+		s.overwrites.Discarded++
+	}
+}
+
+// insertLargest 将文件插入按大小降序排列的列表，只保留最大的 largestFilesCount 个
+func insertLargest(list []FileStat, f FileStat) []FileStat {
+	if len(list) >= largestFilesCount && f.Size <= list[len(list)-1].Size {
+		return list
+	}
+	i := sort.Search(len(list), func(i int) bool { return list[i].Size < f.Size })
+	list = append(list, FileStat{})
+	copy(list[i+1:], list[i:])
+	list[i] = f
+	if len(list) > largestFilesCount {
+		list = list[:largestFilesCount]
+	}
+	return list
+}
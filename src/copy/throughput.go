@@ -0,0 +1,99 @@
+package copy
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// 复制一个文件的各个环节，分别统计耗时
+const (
+	phaseStat   = iota // 获取源文件和目标文件的信息
+	phaseRead          // 读取源文件（不含 --bwlimit 的限速等待）
+	phaseWrite         // 写入目标临时文件
+	phaseFsync         // 将目标文件同步到磁盘
+	phaseRename        // 将临时文件重命名为目标文件
+	phaseCount
+)
+
+// WorkerTime 单个复制协程的耗时
+type WorkerTime struct {
+	Busy  time.Duration // 执行复制任务的时间
+	Idle  time.Duration // 等待派发任务的时间（扫描跟不上复制时变长）
+	Files int           // 执行的任务数
+}
+
+// ThroughputStats 复制阶段各环节的累计耗时（所有复制协程之和），用于找出瓶颈
+type ThroughputStats struct {
+	Stat    time.Duration
+	Read    time.Duration
+	Write   time.Duration
+	Fsync   time.Duration
+	Rename  time.Duration
+	Bytes   int64         // 完整复制时写入的字节数（分块存储、增量更新不计）
+	Wall    time.Duration // 复制阶段的实际耗时
+	Workers []WorkerTime  // 各复制协程的耗时
+}
+
+// Total 返回各环节的耗时之和
+func (s ThroughputStats) Total() time.Duration {
+	return s.Stat + s.Read + s.Write + s.Fsync + s.Rename
+}
+
+// phaseTimes 当前运行各环节的累计耗时（纳秒），复制协程并发累加
+var phaseTimes [phaseCount]atomic.Int64
+
+// copiedBytes 当前运行完整复制时写入的字节数
+var copiedBytes atomic.Int64
+
+// resetThroughput 在每次运行开始时清零各环节的耗时（同时作为卡住检测的起点）
+func resetThroughput() {
+	for i := range phaseTimes {
+		phaseTimes[i].Store(0)
+	}
+	copiedBytes.Store(0)
+	touch()
+}
+
+// observe 将从 start 到现在的耗时计入环节 phase，用法: defer observe(phaseFsync, time.Now())
+func observe(phase int, start time.Time) {
+	phaseTimes[phase].Add(int64(time.Since(start)))
+	touch()
+}
+
+// throughputSnapshot 返回当前运行的各环节耗时
+func throughputSnapshot(wall time.Duration, workers []WorkerTime) ThroughputStats {
+	load := func(phase int) time.Duration { return time.Duration(phaseTimes[phase].Load()) }
+	return ThroughputStats{
+		Stat:    load(phaseStat),
+		Read:    load(phaseRead),
+		Write:   load(phaseWrite),
+		Fsync:   load(phaseFsync),
+		Rename:  load(phaseRename),
+		Bytes:   copiedBytes.Load(),
+		Wall:    wall,
+		Workers: workers,
+	}
+}
+
+// timedReader 统计读取耗时的 Reader
+type timedReader struct {
+	r io.Reader
+}
+
+func (t timedReader) Read(p []byte) (int, error) {
+	defer observe(phaseRead, time.Now())
+	return t.r.Read(p)
+}
+
+// timedWriter 统计写入耗时和字节数的 Writer
+type timedWriter struct {
+	w io.Writer
+}
+
+func (t timedWriter) Write(p []byte) (int, error) {
+	defer observe(phaseWrite, time.Now())
+	n, err := t.w.Write(p)
+	copiedBytes.Add(int64(n))
+	return n, err
+}
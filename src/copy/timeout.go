@@ -0,0 +1,70 @@
+package copy
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/errs"
+)
+
+// bounded 执行单个文件操作 fn，超过 --file-timeout 没有返回时不再等待，返回 TimeoutError
+// 超时的操作无法中断，会在后台一直等到返回，结果被丢弃；未设置 --file-timeout 时直接执行
+func bounded[T any](op, path string, fn func() (T, error)) (T, error) {
+	limit := config.GetGlobalConfig().FileTimeout
+	if limit <= 0 {
+		return fn()
+	}
+	type outcome struct {
+		v   T
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		v, err := fn()
+		done <- outcome{v, err}
+	}()
+	timer := time.NewTimer(limit)
+	defer timer.Stop()
+	select {
+	case o := <-done:
+		return o.v, o.err
+	case <-timer.C:
+		var zero T
+		return zero, &errs.TimeoutError{Op: op, Path: path, Limit: limit}
+	}
+}
+
+// boundedErr 同 bounded，用于只返回错误的操作
+func boundedErr(op, path string, fn func() error) error {
+	_, err := bounded(op, path, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// statBounded 获取文件信息，受 --file-timeout 限制
+func statBounded(path string) (os.FileInfo, error) {
+	return bounded("获取信息", path, func() (os.FileInfo, error) { return os.Stat(path) })
+}
+
+// timeoutReader 每次读取受 --file-timeout 限制的 Reader（限制单次读取，不限制整个文件的复制时间）
+type timeoutReader struct {
+	r    io.Reader
+	path string
+}
+
+func (t timeoutReader) Read(p []byte) (int, error) {
+	return bounded("读取", t.path, func() (int, error) { return t.r.Read(p) })
+}
+
+// timeoutWriter 每次写入受 --file-timeout 限制的 Writer
+type timeoutWriter struct {
+	w    io.Writer
+	path string
+}
+
+func (t timeoutWriter) Write(p []byte) (int, error) {
+	return bounded("写入", t.path, func() (int, error) { return t.w.Write(p) })
+}
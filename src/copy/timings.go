@@ -0,0 +1,52 @@
+package copy
+
+import (
+	"sync"
+	"time"
+)
+
+// RepoTime 单个仓库在本次运行中的复制耗时和已处理文件数
+type RepoTime struct {
+	Copy  time.Duration // 复制该仓库文件的累计耗时（各并发任务之和）
+	Files int           // 已处理（复制、跳过或出错）的文件数
+}
+
+// RepoTimer 按仓库统计复制耗时，用于持久化各仓库耗时和估计剩余时间
+type RepoTimer struct {
+	mu    sync.Mutex
+	repos map[string]*RepoTime
+}
+
+// Timer 当前运行的各仓库复制耗时（每次开始复制时重置）
+var Timer = &RepoTimer{repos: make(map[string]*RepoTime)}
+
+// reset 清空统计
+func (t *RepoTimer) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.repos = make(map[string]*RepoTime)
+}
+
+// add 记录仓库中一个文件的处理耗时
+func (t *RepoTimer) add(repo string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := t.repos[repo]
+	if r == nil {
+		r = &RepoTime{}
+		t.repos[repo] = r
+	}
+	r.Copy += d
+	r.Files++
+}
+
+// Snapshot 返回当前各仓库的统计
+func (t *RepoTimer) Snapshot() map[string]RepoTime {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	snapshot := make(map[string]RepoTime, len(t.repos))
+	for repo, r := range t.repos {
+		snapshot[repo] = *r
+	}
+	return snapshot
+}
@@ -0,0 +1,154 @@
+// Package errs 定义扫描和复制过程中按类别区分的错误类型
+// 各类型都实现了 Unwrap，调用方可以用 errors.As 取出具体信息，或用 errors.Is 判断底层的系统错误
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// GitCommandError 执行 git 命令失败
+type GitCommandError struct {
+	Repo    string // 执行命令的仓库
+	Command string // git 子命令（如 ls-files）
+	Stderr  string // 命令的错误输出
+	Err     error
+}
+
+func (e *GitCommandError) Error() string {
+	if e.Stderr != "" {
+		return fmt.Sprintf("执行 git %s 失败: %v\n错误输出: %s", e.Command, e.Err, e.Stderr)
+	}
+	return fmt.Sprintf("执行 git %s 失败: %v", e.Command, e.Err)
+}
+
+func (e *GitCommandError) Unwrap() error { return e.Err }
+
+// RepoAccessError 无法读取仓库（git 命令失败、仓库目录无法读取等）或仓库发现阶段的目录
+type RepoAccessError struct {
+	Path string
+	Err  error
+}
+
+func (e *RepoAccessError) Error() string {
+	return fmt.Sprintf("无法访问 %s: %v", e.Path, e.Err)
+}
+
+func (e *RepoAccessError) Unwrap() error { return e.Err }
+
+// DestinationFullError 备份目标所在的磁盘空间不足
+type DestinationFullError struct {
+	Path string
+	Err  error
+}
+
+func (e *DestinationFullError) Error() string {
+	return fmt.Sprintf("备份目标空间不足: %v", e.Err)
+}
+
+func (e *DestinationFullError) Unwrap() error { return e.Err }
+
+// PermissionError 没有权限读取源路径或写入备份目标
+type PermissionError struct {
+	Path string
+	Err  error
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("没有权限: %v", e.Err)
+}
+
+func (e *PermissionError) Unwrap() error { return e.Err }
+
+// PanicError 处理单个文件时发生的 panic（程序缺陷），已被恢复并转换为该文件的错误
+type PanicError struct {
+	Value any    // recover() 返回的值
+	Stack []byte // 发生 panic 时的调用栈
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("内部错误（panic）: %v", e.Value)
+}
+
+// Unwrap panic 的值本身是错误时（如空指针访问的 runtime.Error）返回该错误
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// StalledError 复制长时间没有进展（如 SMB 写入挂起），按 --stall-abort 放弃了该文件
+type StalledError struct {
+	Path string
+	Idle time.Duration // 放弃前没有进展的时间
+}
+
+func (e *StalledError) Error() string {
+	return fmt.Sprintf("%s 没有进展，已放弃", e.Idle.Round(time.Second))
+}
+
+// TimeoutError 单个文件操作（获取信息、打开、读写、同步、重命名）超过 --file-timeout 没有返回，
+// 多见于不稳定的网络共享；该文件没有被复制，下次运行会重新尝试
+type TimeoutError struct {
+	Op    string // 操作名称
+	Path  string
+	Limit time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s %s 超过 %s 没有返回", e.Op, e.Path, e.Limit)
+}
+
+// Timeout 与 net.Error 等超时错误一致，供调用方判断
+func (e *TimeoutError) Timeout() bool { return true }
+
+// Classify 按底层的系统错误将 err 归入 PermissionError 或 DestinationFullError；
+// 已经是本包的错误类型、或不属于这两类时原样返回
+func Classify(err error) error {
+	if err == nil || Kind(err) != "" {
+		return err
+	}
+	path := ""
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		path = pathErr.Path
+	}
+	switch {
+	case isNoSpace(err):
+		return &DestinationFullError{Path: path, Err: err}
+	case errors.Is(err, fs.ErrPermission):
+		return &PermissionError{Path: path, Err: err}
+	}
+	return err
+}
+
+// Kind 返回错误的类别名称（用于运行摘要等机器可读的输出），不属于已知类别时返回空字符串
+func Kind(err error) string {
+	var (
+		full *DestinationFullError
+		perm *PermissionError
+		repo *RepoAccessError
+		git  *GitCommandError
+		pan  *PanicError
+		stal *StalledError
+		tout *TimeoutError
+	)
+	switch {
+	case errors.As(err, &pan):
+		return "panic"
+	case errors.As(err, &full):
+		return "destination-full"
+	case errors.As(err, &perm):
+		return "permission"
+	case errors.As(err, &repo):
+		return "repo-access"
+	case errors.As(err, &git):
+		return "git-command"
+	case errors.As(err, &stal):
+		return "stalled"
+	case errors.As(err, &tout):
+		return "timeout"
+	}
+	return ""
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package errs
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isNoSpace 判断是否为磁盘空间不足（或超出配额）的错误
+func isNoSpace(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT)
+}
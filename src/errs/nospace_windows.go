@@ -0,0 +1,19 @@
+//go:build windows
+
+package errs
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Windows 上磁盘空间不足的错误码
+const (
+	errorHandleDiskFull syscall.Errno = 39  // ERROR_HANDLE_DISK_FULL
+	errorDiskFull       syscall.Errno = 112 // ERROR_DISK_FULL
+)
+
+// isNoSpace 判断是否为磁盘空间不足的错误
+func isNoSpace(err error) bool {
+	return errors.Is(err, errorDiskFull) || errors.Is(err, errorHandleDiskFull)
+}
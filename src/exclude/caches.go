@@ -0,0 +1,96 @@
+package exclude
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// cacheRule 可重建缓存目录的识别规则：目录名匹配，且满足目录内标记文件或同级项目文件的条件
+type cacheRule struct {
+	kind     string   // 缓存类型
+	names    []string // 目录名
+	markers  []string // 目录内存在其一即确认（为空表示不检查）
+	siblings []string // 同级存在其一即确认（为空表示不检查）
+}
+
+// cacheRules 精选的可重建缓存列表，与用户的 --exclude 规则分开维护
+var cacheRules = []cacheRule{
+	{kind: "git-lfs", names: []string{"lfs"}, markers: []string{"objects"}},
+	{kind: "maven", names: []string{".m2"}, markers: []string{"repository"}},
+	{kind: "gradle", names: []string{".gradle"}, siblings: []string{"build.gradle", "build.gradle.kts", "settings.gradle", "settings.gradle.kts"}},
+	{kind: "npm", names: []string{"node_modules"}, siblings: []string{"package.json"}},
+	{kind: "cargo", names: []string{"target"}, siblings: []string{"Cargo.toml"}},
+	{kind: "python-venv", names: []string{"venv", ".venv", "env", "virtualenv", ".virtualenv"}, markers: []string{"pyvenv.cfg"}},
+	{kind: "pip", names: []string{".pip-cache", "pip-cache"}},
+	{kind: "python", names: []string{"__pycache__", ".pytest_cache", ".mypy_cache", ".ruff_cache", ".tox", ".nox"}},
+}
+
+// cacheRulesByName 目录名 -> 识别规则
+var cacheRulesByName = func() map[string][]cacheRule {
+	m := make(map[string][]cacheRule)
+	for _, rule := range cacheRules {
+		for _, name := range rule.names {
+			m[name] = append(m[name], rule)
+		}
+	}
+	return m
+}()
+
+// DetectCache 判断路径是否为已知的可重建缓存目录，是则返回缓存类型，否则返回空字符串
+func DetectCache(path string) string {
+	rules := cacheRulesByName[filepath.Base(path)]
+	if len(rules) == 0 {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return ""
+	}
+	for _, rule := range rules {
+		if rule.matches(path) {
+			return rule.kind
+		}
+	}
+	return ""
+}
+
+// matches 检查目录内标记文件和同级项目文件
+func (r cacheRule) matches(dir string) bool {
+	if len(r.markers) > 0 && !anyExists(dir, r.markers) {
+		return false
+	}
+	if len(r.siblings) > 0 && !anyExists(filepath.Dir(dir), r.siblings) {
+		return false
+	}
+	return true
+}
+
+// anyExists 判断目录下是否存在任一名称
+func anyExists(dir string, names []string) bool {
+	for _, name := range names {
+		if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheDirTagSignature CACHEDIR.TAG 文件必须以该签名开头（https://bford.info/cachedir/）
+const cacheDirTagSignature = "Signature: 8a477f597d28d172789f06886806bc55"
+
+// HasBackupMarker 判断目录是否带有其他备份工具约定的“不备份”标记：
+// 内容以标准签名开头的 CACHEDIR.TAG，或 .nobackup 文件
+func HasBackupMarker(dir string) bool {
+	if _, err := os.Lstat(filepath.Join(dir, ".nobackup")); err == nil {
+		return true
+	}
+	f, err := os.Open(filepath.Join(dir, "CACHEDIR.TAG"))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	buf := make([]byte, len(cacheDirTagSignature))
+	n, _ := io.ReadFull(f, buf)
+	return string(buf[:n]) == cacheDirTagSignature
+}
@@ -0,0 +1,27 @@
+package exclude
+
+// Excluder 判断路径是否应被排除，扫描和复制接口通过它应用排除规则
+// 嵌入本工具的应用可以提供自己的实现（如从数据库或策略服务读取规则），Matcher 是基于排除模式的默认实现
+type Excluder interface {
+	ShouldExclude(path string) bool
+}
+
+// ReasonExcluder 能说明排除原因的 Excluder，用于在结果中报告文件为何被排除
+type ReasonExcluder interface {
+	Excluder
+	// ExcludeReason 返回路径是否被排除，以及排除的原因（如匹配的排除模式）
+	ExcludeReason(path string) (reason string, excluded bool)
+}
+
+// Reason 返回路径被 excluder 排除的原因；excluder 不能说明原因时返回空字符串
+func Reason(excluder Excluder, path string) (reason string, excluded bool) {
+	if r, ok := excluder.(ReasonExcluder); ok {
+		return r.ExcludeReason(path)
+	}
+	return "", excluder.ShouldExclude(path)
+}
+
+// DirPruner 可选接口：发现仓库时判断是否整个跳过某个目录（不再进入其中查找仓库），返回跳过的原因
+type DirPruner interface {
+	PruneDir(dir string) (reason string, pruned bool)
+}
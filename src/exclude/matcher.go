@@ -2,14 +2,127 @@ package exclude
 
 import (
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync/atomic"
 
 	"github.com/bmatcuk/doublestar/v4"
+	"golang.org/x/text/unicode/norm"
 )
 
 // Matcher 负责匹配排除模式
 type Matcher struct {
-	patterns []string
+	patterns   []string
+	skipCaches bool // 同时排除已知的可重建缓存目录（见 DetectCache）
+	skipMarked bool // 同时排除带有 CACHEDIR.TAG 或 .nobackup 标记的目录（见 HasBackupMarker）
+
+	sources []string // 与 patterns 一一对应的原始排除模式（用户输入的形式，用于报告排除原因）
+
+	skipDirs  []string // 总是排除的目录（位于搜索根目录之内的备份根目录、历史目录等工具自身的目录，见 SkipDirs）
+	skipFiles []string // 总是排除的文件（位于搜索根目录之内的审计日志、运行摘要、程序文件等，见 SkipFiles）
+
+	isOwnTemp func(name string) bool // 判断文件名是否为工具自身的临时文件（见 SkipOwnTemps），为 nil 表示不判断
+
+	// 各条规则排除的路径数（见 Stats）
+	counts      []atomic.Int64
+	shadowed    []atomic.Int64 // 也能匹配、但已被前面的规则排除的路径数（只统计尚未排除过路径的规则）
+	shadowedBy  []atomic.Int32 // 第一次出现上述情况时排除该路径的规则序号 + 1
+	cacheCount  atomic.Int64
+	markerCount atomic.Int64
+	ownCount    atomic.Int64
+}
+
+// PatternStat 一条排除规则在本次运行中排除的路径数
+type PatternStat struct {
+	Pattern    string // 用户输入的排除模式，或 "缓存目录"、"备份标记"、"工具自身的目录"
+	Count      int64
+	Shadowed   int64  // Count 为 0 时，也能匹配、但已被前面的规则排除的路径数
+	ShadowedBy string // Shadowed 大于 0 时，排除了这些路径的前面的规则
+}
+
+// Unused 判断规则是否没有匹配任何路径
+func (s PatternStat) Unused() bool {
+	return s.Count == 0 && s.Shadowed == 0
+}
+
+// Redundant 判断规则匹配的路径是否都已被前面更宽的规则排除
+func (s PatternStat) Redundant() bool {
+	return s.Count == 0 && s.Shadowed > 0
+}
+
+// SkipCaches 让匹配器同时排除已知的可重建缓存目录
+func (m *Matcher) SkipCaches() {
+	m.skipCaches = true
+}
+
+// SkipMarkedDirs 让匹配器同时排除带有 CACHEDIR.TAG 或 .nobackup 标记的目录
+func (m *Matcher) SkipMarkedDirs() {
+	m.skipMarked = true
+}
+
+// SkipDirs 让匹配器总是排除指定的目录及其中的所有路径（绝对路径），用于跳过工具自身的备份根目录和历史目录
+func (m *Matcher) SkipDirs(dirs ...string) {
+	for _, dir := range dirs {
+		m.skipDirs = append(m.skipDirs, filepath.Clean(dir))
+	}
+}
+
+// SkipFiles 让匹配器总是排除指定的文件（绝对路径），用于跳过工具自身写入的日志、报告和正在运行的程序文件
+func (m *Matcher) SkipFiles(files ...string) {
+	for _, file := range files {
+		m.skipFiles = append(m.skipFiles, filepath.Clean(file))
+	}
+}
+
+// SkipOwnTemps 让匹配器总是排除文件名满足 isTemp 的文件（工具自身写入中的临时文件，如 --sync 取回到源位置时）
+func (m *Matcher) SkipOwnTemps(isTemp func(name string) bool) {
+	m.isOwnTemp = isTemp
+}
+
+// PruneDir 判断发现仓库时是否整个跳过该目录（不再进入）；只对 SkipDirs 指定的目录生效，排除模式仍只作用于仓库和文件
+func (m *Matcher) PruneDir(dir string) (string, bool) {
+	return m.ownDirReason(dir)
+}
+
+// ownDirReason 判断路径是否位于 SkipDirs 指定的目录之内，或是 SkipFiles 指定的文件、SkipOwnTemps 识别的临时文件
+func (m *Matcher) ownDirReason(path string) (string, bool) {
+	if len(m.skipDirs) == 0 && len(m.skipFiles) == 0 && m.isOwnTemp == nil {
+		return "", false
+	}
+	cleanPath := filepath.Clean(path)
+	for _, file := range m.skipFiles {
+		if samePath(cleanPath, file) {
+			m.ownCount.Add(1)
+			return "工具自身的文件: " + file, true
+		}
+	}
+	if m.isOwnTemp != nil && m.isOwnTemp(filepath.Base(cleanPath)) {
+		m.ownCount.Add(1)
+		return "工具自身的临时文件", true
+	}
+	for _, dir := range m.skipDirs {
+		if withinDir(cleanPath, dir) {
+			m.ownCount.Add(1)
+			return "工具自身的目录: " + dir, true
+		}
+	}
+	return "", false
+}
+
+// samePath 判断两个清理后的路径是否相同（Windows 上不区分大小写）
+func samePath(a, b string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// withinDir 判断 path 是否为 dir 或位于 dir 之内（Windows 上不区分大小写）
+func withinDir(path, dir string) bool {
+	if runtime.GOOS == "windows" {
+		path, dir = strings.ToLower(path), strings.ToLower(dir)
+	}
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
 }
 
 // Patterns 返回匹配器的模式列表（用于调试）
@@ -30,7 +143,9 @@ func NewMatcher(patterns []string) (*Matcher, error) {
 		}
 
 	// 转换为正斜杠格式（doublestar 需要），但不使用 filepath.Clean 以避免破坏通配符
-	normalized := strings.ReplaceAll(pattern, "\\", "/")
+	// 统一为 Unicode NFC 形式，与 macOS 上分解形式（NFD）的文件名也能匹配
+	// 去掉 Windows 长路径前缀（\\?\C:\、\\?\UNC\），与扫描得到的路径形式一致
+	normalized := stripLongPathPrefix(strings.ReplaceAll(norm.NFC.String(pattern), "\\", "/"))
 
 	// 处理相对路径模式
 	if !m.isAbsolutePathPattern(normalized) {
@@ -59,29 +174,93 @@ func NewMatcher(patterns []string) (*Matcher, error) {
 	}
 
 		m.patterns = append(m.patterns, normalized)
+		m.sources = append(m.sources, pattern)
 	}
+	m.counts = make([]atomic.Int64, len(m.patterns))
+	m.shadowed = make([]atomic.Int64, len(m.patterns))
+	m.shadowedBy = make([]atomic.Int32, len(m.patterns))
 
 	return m, nil
 }
 
+// Stats 返回各条排除规则排除的路径数（按规则的顺序）；缓存目录、备份标记和工具自身的目录排除过路径时排在最后
+// 每次判断只计入第一条匹配的规则
+func (m *Matcher) Stats() []PatternStat {
+	stats := make([]PatternStat, 0, len(m.sources)+3)
+	for i, source := range m.sources {
+		stat := PatternStat{Pattern: source, Count: m.counts[i].Load()}
+		if stat.Count == 0 {
+			stat.Shadowed = m.shadowed[i].Load()
+			if by := m.shadowedBy[i].Load(); by > 0 {
+				stat.ShadowedBy = m.sources[by-1]
+			}
+		}
+		stats = append(stats, stat)
+	}
+	if n := m.cacheCount.Load(); n > 0 {
+		stats = append(stats, PatternStat{Pattern: "缓存目录", Count: n})
+	}
+	if n := m.markerCount.Load(); n > 0 {
+		stats = append(stats, PatternStat{Pattern: "备份标记", Count: n})
+	}
+	if n := m.ownCount.Load(); n > 0 {
+		stats = append(stats, PatternStat{Pattern: "工具自身的目录", Count: n})
+	}
+	return stats
+}
+
 // ShouldExclude 检查指定路径是否应该被排除
 func (m *Matcher) ShouldExclude(path string) bool {
+	_, excluded := m.ExcludeReason(path)
+	return excluded
+}
+
+// ExcludeReason 检查指定路径是否应该被排除，并返回原因：匹配的排除模式（用户输入的形式）、
+// "缓存目录: <类型>"（--skip-caches）、"备份标记"（CACHEDIR.TAG、.nobackup）或 "工具自身的目录: <目录>"
+func (m *Matcher) ExcludeReason(path string) (string, bool) {
+	if reason, ok := m.ownDirReason(path); ok {
+		return reason, true
+	}
+	if m.skipCaches {
+		if kind := DetectCache(path); kind != "" {
+			m.cacheCount.Add(1)
+			return "缓存目录: " + kind, true
+		}
+	}
+	if m.skipMarked && HasBackupMarker(path) {
+		m.markerCount.Add(1)
+		return "备份标记", true
+	}
 	if len(m.patterns) == 0 {
-		return false
+		return "", false
 	}
 
-	// 归一化待检查的路径，并转换为正斜杠（doublestar 需要）
-	cleanPath := filepath.Clean(path)
-	normalizedPath := strings.ReplaceAll(cleanPath, "\\", "/")
+	// 归一化待检查的路径（包括 Unicode NFC 形式），并转换为正斜杠（doublestar 需要）
+	cleanPath := norm.NFC.String(filepath.Clean(path))
+	normalizedPath := stripLongPathPrefix(strings.ReplaceAll(cleanPath, "\\", "/"))
 
 	// 检查每个模式
-	for _, pattern := range m.patterns {
+	for i, pattern := range m.patterns {
 		if m.matchesPattern(normalizedPath, pattern) {
-			return true
+			m.counts[i].Add(1)
+			m.recordShadowed(normalizedPath, i)
+			return m.sources[i], true
 		}
 	}
 
-	return false
+	return "", false
+}
+
+// recordShadowed 记录后面的规则中也能匹配该路径、但尚未排除过任何路径的规则，用于发现被更宽的规则覆盖的多余规则
+// 已排除过路径的规则不再检查，多余的匹配只发生在被排除的路径上
+func (m *Matcher) recordShadowed(path string, by int) {
+	for j := by + 1; j < len(m.patterns); j++ {
+		if m.counts[j].Load() > 0 || !m.matchesPattern(path, m.patterns[j]) {
+			continue
+		}
+		m.shadowed[j].Add(1)
+		m.shadowedBy[j].CompareAndSwap(0, int32(by+1))
+	}
 }
 
 // matchesPattern 检查单个模式是否匹配路径
@@ -102,6 +281,22 @@ func (m *Matcher) matchesPattern(path, pattern string) bool {
 	return matched
 }
 
+// stripLongPathPrefix 去掉正斜杠形式路径中的 Windows 长路径前缀：//?/C:/dir 变为 C:/dir，//?/UNC/server/share 变为 //server/share
+// 与 helpers.StripLongPathPrefix 相同（helpers 依赖本包，不能反向引用）
+func stripLongPathPrefix(path string) string {
+	if !strings.HasPrefix(path, "//?/") {
+		return path
+	}
+	rest := path[len("//?/"):]
+	switch {
+	case len(rest) >= 4 && strings.EqualFold(rest[:4], "UNC/"):
+		return "//" + rest[4:]
+	case len(rest) >= 2 && rest[1] == ':' && (len(rest) == 2 || rest[2] == '/'):
+		return rest
+	}
+	return path
+}
+
 // isAbsolutePathPattern 判断模式是否为绝对路径模式
 func (m *Matcher) isAbsolutePathPattern(pattern string) bool {
 	// Windows 绝对路径：以驱动器字母开头（如 C:/ 或 C:\）
@@ -0,0 +1,25 @@
+package exclude
+
+import (
+	"fmt"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// CheckPatterns 检查模式的语法（通配符按 doublestar 语法，如未闭合的 [ 或 {），返回第一个无效的模式
+// 无效的模式在匹配时会被跳过、不匹配任何路径，运行前检查可以避免规则悄悄失效
+func CheckPatterns(patterns []string) error {
+	m, err := NewMatcher(patterns)
+	if err != nil {
+		return err
+	}
+	for i, pattern := range m.patterns {
+		if m.isAbsolutePathPattern(pattern) {
+			continue
+		}
+		if !doublestar.ValidatePattern(pattern) {
+			return fmt.Errorf("无效的模式: %s", m.sources[i])
+		}
+	}
+	return nil
+}
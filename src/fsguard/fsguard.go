@@ -0,0 +1,196 @@
+// Package fsguard 是所有文件系统修改操作的统一入口：
+// 启用只读保护（--read-only-source）时拒绝修改搜索根目录下的源文件，启用审计（--audit）时逐条记录修改及原因
+package fsguard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSourceWrite 只读保护下试图修改源文件时返回的错误
+var ErrSourceWrite = errors.New("只读保护: 不允许修改源目录")
+
+var (
+	mu        sync.Mutex
+	protected []string  // 禁止修改的目录（搜索根目录）
+	allowed   []string  // 位于受保护目录之内、但允许修改的目录和文件（备份根目录、报告文件等）
+	audit     io.Writer // 审计日志，nil 表示不记录
+)
+
+// Protect 启用只读保护：拒绝修改 roots 之内的路径，allow 之内的路径除外
+func Protect(roots, allow []string) {
+	mu.Lock()
+	defer mu.Unlock()
+	protected = absPaths(roots)
+	allowed = absPaths(allow)
+}
+
+// SetAudit 设置审计日志，之后每次修改文件系统都写入一行：时间、操作、路径、原因（制表符分隔）
+func SetAudit(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	audit = w
+}
+
+// Do 在只读保护检查通过后执行修改操作 fn，并记入审计日志
+// 供无法用本包其他函数表达的修改（如设置扩展属性、安全描述符）使用
+func Do(op, path, reason string, fn func() error) error {
+	if err := check(path); err != nil {
+		return err
+	}
+	err := fn()
+	record(op, path, reason, err)
+	return err
+}
+
+// Remove 删除文件或空目录
+func Remove(path, reason string) error {
+	return Do("remove", path, reason, func() error { return os.Remove(path) })
+}
+
+// RemoveAll 删除路径及其包含的所有内容
+func RemoveAll(path, reason string) error {
+	return Do("remove-all", path, reason, func() error { return os.RemoveAll(path) })
+}
+
+// Rename 重命名或移动文件；源路径和目标路径都需通过只读保护检查
+func Rename(oldpath, newpath, reason string) error {
+	if err := check(oldpath); err != nil {
+		return err
+	}
+	return Do("rename", newpath, reason+"（来自 "+oldpath+"）", func() error { return os.Rename(oldpath, newpath) })
+}
+
+// Link 创建硬链接 newname 指向 oldname 的内容
+func Link(oldname, newname, reason string) error {
+	return Do("link", newname, reason+"（链接到 "+oldname+"）", func() error { return os.Link(oldname, newname) })
+}
+
+// WriteFile 写入文件
+func WriteFile(path string, data []byte, perm os.FileMode, reason string) error {
+	return Do("write", path, reason, func() error { return os.WriteFile(path, data, perm) })
+}
+
+// Create 创建或清空文件并打开用于写入
+func Create(path, reason string) (*os.File, error) {
+	return OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666, reason)
+}
+
+// OpenFile 以指定方式打开文件；只读打开不检查也不记录
+func OpenFile(path string, flag int, perm os.FileMode, reason string) (*os.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		return os.OpenFile(path, flag, perm)
+	}
+	var f *os.File
+	err := Do("open-write", path, reason, func() error {
+		var err error
+		f, err = os.OpenFile(path, flag, perm)
+		return err
+	})
+	return f, err
+}
+
+// CreateTemp 在 dir 下创建名称唯一的新文件并打开用于写入（见 os.CreateTemp）
+func CreateTemp(dir, pattern, reason string) (*os.File, error) {
+	if err := check(dir); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		record("create", filepath.Join(dir, pattern), reason, err)
+		return nil, err
+	}
+	record("create", f.Name(), reason, nil)
+	return f, nil
+}
+
+// MkdirAll 创建目录及其所有上级目录；目录已存在时不记录
+func MkdirAll(path string, perm os.FileMode, reason string) error {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return nil
+	}
+	return Do("mkdir", path, reason, func() error { return os.MkdirAll(path, perm) })
+}
+
+// Chtimes 修改文件的访问时间和修改时间
+func Chtimes(path string, atime, mtime time.Time, reason string) error {
+	return Do("chtimes", path, reason, func() error { return os.Chtimes(path, atime, mtime) })
+}
+
+// Chmod 修改文件权限
+func Chmod(path string, mode os.FileMode, reason string) error {
+	return Do("chmod", path, reason, func() error { return os.Chmod(path, mode) })
+}
+
+// Lchown 修改文件所有者（不跟随符号链接）
+func Lchown(path string, uid, gid int, reason string) error {
+	return Do("chown", path, reason, func() error { return os.Lchown(path, uid, gid) })
+}
+
+// check 只读保护下拒绝修改受保护目录之内、且不在允许范围内的路径
+func check(path string) error {
+	mu.Lock()
+	roots, allow := protected, allowed
+	mu.Unlock()
+	if len(roots) == 0 {
+		return nil
+	}
+
+	abs := absPath(path)
+	for _, a := range allow {
+		if within(abs, a) {
+			return nil
+		}
+	}
+	for _, root := range roots {
+		if within(abs, root) {
+			return fmt.Errorf("%w: %s", ErrSourceWrite, path)
+		}
+	}
+	return nil
+}
+
+// record 写入一行审计日志，操作失败时附上错误
+func record(op, path, reason string, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if audit == nil {
+		return
+	}
+	if err != nil {
+		reason += "（失败: " + err.Error() + "）"
+	}
+	fmt.Fprintf(audit, "%s\t%s\t%s\t%s\n", time.Now().Format("2006-01-02T15:04:05.000Z07:00"), op, path, reason)
+}
+
+func absPaths(paths []string) []string {
+	var result []string
+	for _, p := range paths {
+		if p != "" {
+			result = append(result, absPath(p))
+		}
+	}
+	return result
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// within 判断 path 是否为 root 自身或位于 root 之下（Windows 上不区分大小写）
+func within(path, root string) bool {
+	if runtime.GOOS == "windows" {
+		path, root = strings.ToLower(path), strings.ToLower(root)
+	}
+	return path == root || strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
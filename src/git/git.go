@@ -1,54 +1,73 @@
 package git
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"github.com/aogg/copy-ignore/src/errs"
 )
 
 // ListIgnoredFiles 使用 git ls-files 命令列出指定仓库中被忽略的文件
 // 返回相对于仓库根目录的相对路径列表
 func ListIgnoredFiles(repoRoot string) ([]string, error) {
+	files := []string{}
+	err := EachIgnoredFile(repoRoot, func(file string) bool {
+		files = append(files, file)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// EachIgnoredFile 逐个读取 git ls-files 输出的被忽略文件（相对于仓库根目录），边读边回调 fn
+// fn 返回 false 时停止枚举并结束 git 进程，不再读取剩余输出
+func EachIgnoredFile(repoRoot string, fn func(relPath string) bool) error {
 	// 使用 git ls-files -i --exclude-standard -o -z 列出被忽略的未追踪文件
 	// -i: 显示被忽略的文件
 	// --exclude-standard: 使用标准的忽略规则（包括 .gitignore）
 	// -o: 显示未被追踪的文件（与 -i 一起使用时显示被忽略的未追踪文件）
 	// -z: 以 null 字符分隔输出，避免路径中空格的问题
 	cmd := exec.Command("git", "-C", repoRoot, "ls-files", "-i", "--exclude-standard", "-o", "-z")
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
+	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("执行 git ls-files 失败: %v\n错误输出: %s", err, stderr.String())
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return commandError(repoRoot, "ls-files", "", err)
 	}
-
-	// 解析 null 分隔的输出
-	output := stdout.Bytes()
-	if len(output) == 0 {
-		return []string{}, nil
+	if err := cmd.Start(); err != nil {
+		return commandError(repoRoot, "ls-files", "", err)
 	}
 
-	// 使用 null 字符分割（最后一个元素是空字符串，需要去掉）
-	parts := bytes.Split(output, []byte{0})
-	files := make([]string, 0, len(parts)-1)
-
-	for _, part := range parts {
+	// 按 null 字符分割输出
+	reader := bufio.NewReader(stdout)
+	for {
+		part, readErr := reader.ReadBytes(0)
+		part = bytes.TrimSuffix(part, []byte{0})
 		if len(part) > 0 {
-			// 转换为字符串并清理路径
-			file := string(part)
-			file = filepath.Clean(file)
-
-			// 跳过空字符串和无效路径
-			if file != "" && file != "." && file != ".." {
-				files = append(files, file)
+			// 转换为字符串并清理路径，跳过空字符串和无效路径
+			file := filepath.Clean(string(part))
+			if file != "" && file != "." && file != ".." && !fn(file) {
+				// 提前停止：结束 git 进程，忽略其因此返回的错误
+				cmd.Process.Kill()
+				cmd.Wait()
+				return nil
 			}
 		}
+		if readErr != nil {
+			break
+		}
 	}
 
-	return files, nil
+	if err := cmd.Wait(); err != nil {
+		return commandError(repoRoot, "ls-files", stderr.String(), err)
+	}
+	return nil
 }
 
 // IsGitRepository 检查指定目录是否为 Git 仓库
@@ -91,5 +110,83 @@ func IsPathIgnored(repoRoot, path string) (bool, error) {
 	}
 
 	// 其他错误
-	return false, fmt.Errorf("检查忽略状态失败: %v", err)
+	return false, commandError(repoRoot, "check-ignore", "", err)
+}
+
+// RepoIdentity 返回仓库的身份标识，用于识别被移动或重命名的仓库
+// 优先使用 origin 远程地址，没有远程时使用根提交哈希；两者都没有时返回空字符串
+func RepoIdentity(repoRoot string) (string, error) {
+	if out, err := exec.Command("git", "-C", repoRoot, "config", "--get", "remote.origin.url").Output(); err == nil {
+		if url := strings.TrimSpace(string(out)); url != "" {
+			return "remote:" + url, nil
+		}
+	}
+
+	out, err := exec.Command("git", "-C", repoRoot, "rev-list", "--max-parents=0", "HEAD").Output()
+	if err != nil {
+		// 没有任何提交的新仓库
+		return "", nil
+	}
+	lines := strings.Fields(string(out))
+	if len(lines) == 0 {
+		return "", nil
+	}
+	// 可能存在多个根提交，取排序后的第一个保证稳定
+	root := lines[0]
+	for _, l := range lines[1:] {
+		if l < root {
+			root = l
+		}
+	}
+	return "root:" + root, nil
+}
+
+// IgnoreSource 返回使路径被忽略的规则，形如 ".gitignore:3:*.log"；路径未被忽略时返回空字符串
+func IgnoreSource(repoRoot, path string) (string, error) {
+	relPath, err := filepath.Rel(repoRoot, path)
+	if err != nil {
+		return "", fmt.Errorf("计算相对路径失败: %v", err)
+	}
+
+	// -v 输出 "<来源>:<行号>:<规则>\t<路径>"
+	out, err := exec.Command("git", "-C", repoRoot, "check-ignore", "-v", "--", relPath).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", commandError(repoRoot, "check-ignore", "", err)
+	}
+	source, _, _ := strings.Cut(strings.TrimRight(string(out), "\r\n"), "\t")
+	return source, nil
+}
+
+// DirtyGitignores 返回仓库中有未提交修改（已修改、新增、删除或未跟踪）的 .gitignore 文件（相对于仓库根目录）
+func DirtyGitignores(repoRoot string) ([]string, error) {
+	cmd := exec.Command("git", "-C", repoRoot, "status", "--porcelain", "-z", "--untracked-files=all", "--", ":(glob)**/.gitignore")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, commandError(repoRoot, "status", stderr.String(), err)
+	}
+
+	// 每条记录为 "XY 路径"，重命名和复制的记录后面还跟着原路径
+	var files []string
+	entries := strings.Split(string(out), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		files = append(files, filepath.FromSlash(entry[3:]))
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++
+		}
+	}
+	return files, nil
+}
+
+// commandError 包装 git 命令的失败，调用方可以用 errors.As 取出 *errs.GitCommandError
+func commandError(repoRoot, command, stderr string, err error) error {
+	return &errs.GitCommandError{Repo: repoRoot, Command: command, Stderr: strings.TrimSpace(stderr), Err: err}
 }
@@ -0,0 +1,69 @@
+//go:build linux
+
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/aogg/copy-ignore/src/fsguard"
+)
+
+// POSIX ACL 的扩展属性名（访问 ACL 和目录的默认 ACL）
+var posixACLAttrs = []string{"system.posix_acl_access", "system.posix_acl_default"}
+
+// CopyACL 将源文件或目录的权限位、所有者和 POSIX ACL 复制到目标
+// 修改所有者需要 root 权限，失败时仍复制权限位和 ACL，并返回说明所有者未能保留的错误
+func CopyACL(src, dest string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := fsguard.Chmod(dest, info.Mode().Perm(), "保留权限位（--preserve-acl）"); err != nil {
+		return err
+	}
+
+	for _, attr := range posixACLAttrs {
+		value, err := getxattr(src, attr)
+		if err == syscall.ENODATA {
+			continue
+		}
+		if err == syscall.ENOTSUP {
+			// 源文件系统不支持 ACL，只有权限位
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("读取 %s 失败: %v", attr, err)
+		}
+		err = fsguard.Do("setxattr", dest, "保留 POSIX ACL（--preserve-acl）", func() error {
+			return syscall.Setxattr(dest, attr, value, 0)
+		})
+		if err != nil {
+			return fmt.Errorf("设置 %s 失败: %v", attr, err)
+		}
+	}
+
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if err := fsguard.Lchown(dest, int(st.Uid), int(st.Gid), "保留所有者（--preserve-acl）"); err != nil {
+		return fmt.Errorf("已保留权限和 ACL，但所有者未能保留（需要 root 权限）: %v", err)
+	}
+	return nil
+}
+
+// getxattr 读取扩展属性的完整内容
+func getxattr(path, attr string) ([]byte, error) {
+	size, err := syscall.Getxattr(path, attr, nil)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	size, err = syscall.Getxattr(path, attr, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:size], nil
+}
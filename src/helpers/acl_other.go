@@ -0,0 +1,18 @@
+//go:build !windows && !linux
+
+package helpers
+
+import (
+	"os"
+
+	"github.com/aogg/copy-ignore/src/fsguard"
+)
+
+// CopyACL 其他系统上只复制权限位
+func CopyACL(src, dest string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	return fsguard.Chmod(dest, info.Mode().Perm(), "保留权限位（--preserve-acl）")
+}
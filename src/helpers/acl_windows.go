@@ -0,0 +1,101 @@
+//go:build windows
+
+package helpers
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/aogg/copy-ignore/src/fsguard"
+)
+
+// 安全描述符相关常量（见 Windows SDK accctrl.h、winnt.h）
+const (
+	seFileObject                     = 1
+	ownerSecurityInformation         = 0x00000001
+	groupSecurityInformation         = 0x00000002
+	daclSecurityInformation          = 0x00000004
+	protectedDaclSecurityInformation = 0x80000000
+	sePrivilegeEnabled               = 0x00000002
+)
+
+var (
+	modAdvapi32               = syscall.NewLazyDLL("advapi32.dll")
+	procGetNamedSecurityInfoW = modAdvapi32.NewProc("GetNamedSecurityInfoW")
+	procSetNamedSecurityInfoW = modAdvapi32.NewProc("SetNamedSecurityInfoW")
+	procLookupPrivilegeValueW = modAdvapi32.NewProc("LookupPrivilegeValueW")
+	procAdjustTokenPrivileges = modAdvapi32.NewProc("AdjustTokenPrivileges")
+
+	restorePrivilegeOnce sync.Once
+)
+
+// tokenPrivileges 只含一项特权的 TOKEN_PRIVILEGES
+type tokenPrivileges struct {
+	count      uint32
+	luid       [2]uint32
+	attributes uint32
+}
+
+// enableRestorePrivilege 启用 SeRestorePrivilege（以管理员身份运行时才有），
+// 否则只能把所有者设为自己，无法保留其他用户的所有权
+func enableRestorePrivilege() {
+	name, err := syscall.UTF16PtrFromString("SeRestorePrivilege")
+	if err != nil {
+		return
+	}
+	var token syscall.Token
+	process, _ := syscall.GetCurrentProcess()
+	if err := syscall.OpenProcessToken(process, syscall.TOKEN_ADJUST_PRIVILEGES|syscall.TOKEN_QUERY, &token); err != nil {
+		return
+	}
+	defer token.Close()
+	tp := tokenPrivileges{count: 1, attributes: sePrivilegeEnabled}
+	if ret, _, _ := procLookupPrivilegeValueW.Call(0, uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(&tp.luid))); ret == 0 {
+		return
+	}
+	procAdjustTokenPrivileges.Call(uintptr(token), 0, uintptr(unsafe.Pointer(&tp)), 0, 0, 0)
+}
+
+// CopyACL 将源文件或目录的所有者、主组和 DACL（NTFS 安全描述符）复制到目标
+// DACL 按源文件的实际内容设置并阻止从备份目录继承，还原后的权限与源文件一致；
+// 没有管理员权限时只能保留 DACL，返回说明所有者未能保留的错误
+func CopyACL(src, dest string) error {
+	restorePrivilegeOnce.Do(enableRestorePrivilege)
+
+	srcPtr, err := syscall.UTF16PtrFromString(src)
+	if err != nil {
+		return err
+	}
+	destPtr, err := syscall.UTF16PtrFromString(dest)
+	if err != nil {
+		return err
+	}
+
+	var owner, group, dacl, sd uintptr
+	info := uintptr(ownerSecurityInformation | groupSecurityInformation | daclSecurityInformation)
+	ret, _, _ := procGetNamedSecurityInfoW.Call(uintptr(unsafe.Pointer(srcPtr)), seFileObject, info,
+		uintptr(unsafe.Pointer(&owner)), uintptr(unsafe.Pointer(&group)), uintptr(unsafe.Pointer(&dacl)), 0, uintptr(unsafe.Pointer(&sd)))
+	if ret != 0 {
+		return fmt.Errorf("读取安全描述符失败: %v", syscall.Errno(ret))
+	}
+	defer syscall.LocalFree(syscall.Handle(sd))
+
+	setInfo := func(info, owner, group uintptr) error {
+		return fsguard.Do("set-acl", dest, "保留所有者和访问控制列表（--preserve-acl）", func() error {
+			if ret, _, _ := procSetNamedSecurityInfoW.Call(uintptr(unsafe.Pointer(destPtr)), seFileObject, info|protectedDaclSecurityInformation, owner, group, dacl, 0); ret != 0 {
+				return syscall.Errno(ret)
+			}
+			return nil
+		})
+	}
+	ownerErr := setInfo(info, owner, group)
+	if ownerErr == nil {
+		return nil
+	}
+	if err := setInfo(daclSecurityInformation, 0, 0); err != nil {
+		return fmt.Errorf("设置安全描述符失败: %v", err)
+	}
+	return fmt.Errorf("已保留 DACL，但所有者未能保留（需要以管理员身份运行）: %v", ownerErr)
+}
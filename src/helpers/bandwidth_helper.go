@@ -0,0 +1,188 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bandwidthRule 单个时间段的限速规则，start/end 为一天中的分钟数
+type bandwidthRule struct {
+	start, end int
+	rate       int64 // 字节/秒，0 表示不限速
+}
+
+// BandwidthSchedule 按时间段配置的带宽限制
+type BandwidthSchedule struct {
+	rules       []bandwidthRule
+	defaultRate int64
+}
+
+// ParseBandwidthSchedule 解析带宽限制配置
+// 格式为逗号分隔的规则，例如 "09:00-18:00=5M,2M"：
+// 工作时间限速 5 MB/s，其余时间 2 MB/s；不带时间段的规则为默认速率。
+// 时间段可跨越午夜（如 22:00-06:00），速率支持 K/M/G 后缀，0 或 unlimited 表示不限速
+func ParseBandwidthSchedule(spec string) (*BandwidthSchedule, error) {
+	s := &BandwidthSchedule{}
+	if strings.TrimSpace(spec) == "" {
+		return s, nil
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		window, rateText, hasWindow := strings.Cut(part, "=")
+		if !hasWindow {
+			rate, err := parseRate(part)
+			if err != nil {
+				return nil, err
+			}
+			s.defaultRate = rate
+			continue
+		}
+
+		startText, endText, ok := strings.Cut(window, "-")
+		if !ok {
+			return nil, fmt.Errorf("时间段格式错误: %s（应为 HH:MM-HH:MM）", window)
+		}
+		start, err := parseClock(startText)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(endText)
+		if err != nil {
+			return nil, err
+		}
+		rate, err := parseRate(rateText)
+		if err != nil {
+			return nil, err
+		}
+		s.rules = append(s.rules, bandwidthRule{start: start, end: end, rate: rate})
+	}
+
+	return s, nil
+}
+
+// RateAt 返回指定时刻的限速（字节/秒），0 表示不限速
+func (s *BandwidthSchedule) RateAt(t time.Time) int64 {
+	minute := t.Hour()*60 + t.Minute()
+	for _, r := range s.rules {
+		if r.start <= r.end {
+			if minute >= r.start && minute < r.end {
+				return r.rate
+			}
+		} else if minute >= r.start || minute < r.end {
+			// 跨越午夜的时间段
+			return r.rate
+		}
+	}
+	return s.defaultRate
+}
+
+// IsUnlimited 判断是否任何时间都不限速
+func (s *BandwidthSchedule) IsUnlimited() bool {
+	if s.defaultRate > 0 {
+		return false
+	}
+	for _, r := range s.rules {
+		if r.rate > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// parseClock 解析 HH:MM 为一天中的分钟数
+func parseClock(text string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(text))
+	if err != nil {
+		return 0, fmt.Errorf("时间格式错误: %s（应为 HH:MM）", text)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseRate 解析速率，如 500K、5M、1G（字节/秒）
+func parseRate(text string) (int64, error) {
+	text = strings.ToUpper(strings.TrimSpace(text))
+	if text == "" || text == "0" || text == "UNLIMITED" {
+		return 0, nil
+	}
+	rate, err := ParseSize(strings.TrimSuffix(text, "/S"))
+	if err != nil {
+		return 0, fmt.Errorf("速率格式错误: %s", text)
+	}
+	return rate, nil
+}
+
+// RateLimiter 多个复制协程共享的带宽限制器
+type RateLimiter struct {
+	mu       sync.Mutex
+	schedule *BandwidthSchedule
+	next     time.Time // 下一次允许传输的时间点
+}
+
+// NewRateLimiter 根据带宽计划创建限制器，计划为空或始终不限速时返回 nil
+func NewRateLimiter(schedule *BandwidthSchedule) *RateLimiter {
+	if schedule == nil || schedule.IsUnlimited() {
+		return nil
+	}
+	return &RateLimiter{schedule: schedule}
+}
+
+// Wait 为传输 n 个字节预留带宽，必要时阻塞等待
+func (l *RateLimiter) Wait(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	rate := l.schedule.RateAt(now)
+	if rate <= 0 {
+		l.next = now
+		l.mu.Unlock()
+		return
+	}
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / float64(rate) * float64(time.Second)))
+	wait := l.next.Sub(now)
+	l.mu.Unlock()
+
+	time.Sleep(wait)
+}
+
+// Limiting 判断当前时段是否限速，limiter 为 nil 时返回 false
+func (l *RateLimiter) Limiting() bool {
+	return l != nil && l.schedule.RateAt(time.Now()) > 0
+}
+
+// Reader 返回受限速控制的 Reader，limiter 为 nil 时原样返回
+func (l *RateLimiter) Reader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &limitedReader{r: r, limiter: l}
+}
+
+// limitedReader 每次读取后按读取字节数等待
+type limitedReader struct {
+	r       io.Reader
+	limiter *RateLimiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	// 单次读取不超过 64KB，使限速更平滑
+	if len(p) > 64<<10 {
+		p = p[:64<<10]
+	}
+	n, err := lr.r.Read(p)
+	lr.limiter.Wait(n)
+	return n, err
+}
@@ -0,0 +1,72 @@
+package helpers
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// binarySniffSize 判断是否为二进制文件时读取的文件头长度
+const binarySniffSize = 8000
+
+// binaryMagics 常见二进制格式的文件头（可执行文件、压缩包、图片、数据库等）
+var binaryMagics = [][]byte{
+	[]byte("\x7fELF"),             // ELF 可执行文件/共享库
+	[]byte("MZ"),                  // Windows PE（exe、dll）
+	{0xfe, 0xed, 0xfa, 0xce},      // Mach-O 32 位
+	{0xfe, 0xed, 0xfa, 0xcf},      // Mach-O 64 位
+	{0xce, 0xfa, 0xed, 0xfe},      // Mach-O 32 位（小端）
+	{0xcf, 0xfa, 0xed, 0xfe},      // Mach-O 64 位（小端）
+	{0xca, 0xfe, 0xba, 0xbe},      // Mach-O 通用二进制 / Java class
+	[]byte("!<arch>\n"),           // 静态库（.a、.lib）
+	[]byte("\x00asm"),             // WebAssembly
+	[]byte("PK\x03\x04"),          // zip、jar、docx 等
+	{0x1f, 0x8b},                  // gzip
+	[]byte("BZh"),                 // bzip2
+	{0xfd, '7', 'z', 'X', 'Z', 0}, // xz
+	{0x28, 0xb5, 0x2f, 0xfd},      // zstd
+	[]byte("7z\xbc\xaf\x27\x1c"),  // 7z
+	[]byte("Rar!\x1a\x07"),        // rar
+	[]byte("%PDF-"),               // PDF
+	[]byte("\x89PNG\r\n\x1a\n"),   // PNG
+	{0xff, 0xd8, 0xff},            // JPEG
+	[]byte("GIF8"),                // GIF
+	[]byte("SQLite format 3\x00"), // SQLite 数据库
+	{0xd0, 0xcf, 0x11, 0xe0},      // OLE（旧版 Office、pdb 等）
+	[]byte("Microsoft C/C++ MSF"), // PDB 调试符号
+}
+
+// utf16BOMs UTF-16 文本的字节序标记（UTF-16 文本含大量 0 字节，不能按 0 字节判断）
+var utf16BOMs = [][]byte{{0xff, 0xfe}, {0xfe, 0xff}}
+
+// IsBinaryFile 根据文件头判断文件是否为二进制文件：匹配常见二进制格式的魔数，
+// 或文件头中含有 0 字节（UTF-16 文本除外）
+func IsBinaryFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, binarySniffSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return IsBinaryContent(buf[:n]), nil
+}
+
+// IsBinaryContent 判断文件头内容是否属于二进制文件
+func IsBinaryContent(head []byte) bool {
+	for _, magic := range binaryMagics {
+		if bytes.HasPrefix(head, magic) {
+			return true
+		}
+	}
+	for _, bom := range utf16BOMs {
+		if bytes.HasPrefix(head, bom) {
+			return false
+		}
+	}
+	return bytes.IndexByte(head, 0) >= 0
+}
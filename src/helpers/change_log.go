@@ -0,0 +1,267 @@
+package helpers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/fsguard"
+)
+
+// 变更日志记录的类型
+const (
+	ChangeLogStart    = "start"    // watch 开始监视，Roots 为监视的目录
+	ChangeLogChange   = "change"   // Path 发生了变化（创建、修改、删除、重命名、属性变化）
+	ChangeLogOverflow = "overflow" // 事件丢失（事件队列溢出、无法监视全部目录），此前的变化范围无法确定
+	ChangeLogStop     = "stop"     // watch 正常退出
+)
+
+// ChangeLogHeartbeat watch 运行期间至少每隔该时间更新一次变更日志的修改时间
+const ChangeLogHeartbeat = 30 * time.Second
+
+// ChangeLogSlack 读取变更日志时向前多取的时间：事件写入日志前最多缓冲这么久，
+// 上次运行开始前刚发生、开始后才写入的变化也不会漏掉
+const ChangeLogSlack = 10 * time.Second
+
+// changeLogCompactSize 变更日志超过该大小（且是上次合并后的两倍）时合并重复的路径
+const changeLogCompactSize = 16 << 20
+
+// ChangeLogRecord 变更日志中的一条记录（每行一条 JSON）
+type ChangeLogRecord struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Path  string    `json:"path,omitempty"`
+	Roots []string  `json:"roots,omitempty"`
+}
+
+// ChangeLog watch 子命令写入的变更日志：开始监视时重写，之后逐条追加
+type ChangeLog struct {
+	mu    sync.Mutex
+	path  string
+	f     *os.File
+	w     *bufio.Writer
+	start ChangeLogRecord
+	size  int64
+	limit int64 // 超过该大小时合并
+}
+
+// CreateChangeLog 创建（覆盖）变更日志并写入开始记录；之前的记录属于已结束的监视，不再有用
+func CreateChangeLog(path string, roots []string) (*ChangeLog, error) {
+	f, err := fsguard.Create(path, "记录监视期间的变更")
+	if err != nil {
+		return nil, err
+	}
+	l := &ChangeLog{path: path, f: f, w: bufio.NewWriter(f), start: ChangeLogRecord{Event: ChangeLogStart, Time: time.Now(), Roots: roots},
+		limit: changeLogCompactSize}
+	if err := l.append(l.start); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, l.Flush()
+}
+
+// append 追加一条记录（调用方持有锁或尚未共享）
+func (l *ChangeLog) append(rec ChangeLogRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	n, err := l.w.Write(append(data, '\n'))
+	l.size += int64(n)
+	return err
+}
+
+// Record 记录一批发生变化的路径
+func (l *ChangeLog) Record(paths []string, t time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, p := range paths {
+		if err := l.append(ChangeLogRecord{Event: ChangeLogChange, Time: t, Path: p}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Overflow 记录事件丢失
+func (l *ChangeLog) Overflow(t time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.append(ChangeLogRecord{Event: ChangeLogOverflow, Time: t})
+}
+
+// Flush 将缓冲的记录写入文件并更新修改时间（供运行时判断 watch 是否仍在运行），日志过大时合并重复的路径
+func (l *ChangeLog) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.w.Flush(); err != nil {
+		return err
+	}
+	if l.size > l.limit {
+		if err := l.compact(); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	return fsguard.Chtimes(l.path, now, now, "更新变更日志的心跳")
+}
+
+// compact 重写变更日志：每个路径只保留最近一次变化，丢失记录只保留最近一条（调用方持有锁）
+func (l *ChangeLog) compact() error {
+	records, err := readChangeLog(l.path)
+	if err != nil {
+		return err
+	}
+	latest := make(map[string]time.Time)
+	var overflow *ChangeLogRecord
+	for i, rec := range records {
+		switch rec.Event {
+		case ChangeLogChange:
+			if rec.Time.After(latest[rec.Path]) {
+				latest[rec.Path] = rec.Time
+			}
+		case ChangeLogOverflow:
+			overflow = &records[i]
+		}
+	}
+	paths := make([]string, 0, len(latest))
+	for p := range latest {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	tmp := l.path + ".tmp"
+	f, err := fsguard.Create(tmp, "合并变更日志中重复的路径")
+	if err != nil {
+		return err
+	}
+	compacted := &ChangeLog{path: tmp, f: f, w: bufio.NewWriter(f), start: l.start}
+	err = compacted.append(l.start)
+	if overflow != nil && err == nil {
+		err = compacted.append(*overflow)
+	}
+	for _, p := range paths {
+		if err == nil {
+			err = compacted.append(ChangeLogRecord{Event: ChangeLogChange, Time: latest[p], Path: p})
+		}
+	}
+	if err == nil {
+		err = compacted.w.Flush()
+	}
+	if err != nil {
+		f.Close()
+		fsguard.Remove(tmp, "删除写入失败的变更日志")
+		return err
+	}
+	if err := fsguard.Rename(tmp, l.path, "替换为合并后的变更日志"); err != nil {
+		f.Close()
+		return err
+	}
+	l.f.Close()
+	l.f, l.w, l.size = f, compacted.w, compacted.size
+	l.limit = max(changeLogCompactSize, 2*l.size)
+	return nil
+}
+
+// Close 写入正常退出的记录并关闭变更日志
+func (l *ChangeLog) Close() error {
+	l.mu.Lock()
+	err := l.append(ChangeLogRecord{Event: ChangeLogStop, Time: time.Now()})
+	l.mu.Unlock()
+	if ferr := l.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// readChangeLog 读取变更日志的全部记录（末尾写了一半的行忽略）
+func readChangeLog(path string) ([]ChangeLogRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []ChangeLogRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var rec ChangeLogRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err == nil {
+			records = append(records, rec)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// ChangesSince 读取变更日志，返回 since 之后发生变化的路径
+// 无法确定变化范围时返回错误：没有变更日志、watch 已退出或不再更新（超过两个心跳间隔）、
+// 开始监视晚于 since、监视的目录没有覆盖全部 roots、since 之后有事件丢失
+func ChangesSince(path string, roots []string, since, now time.Time) ([]string, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("没有找到变更日志 %s（需要运行 watch 子命令）", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if now.Sub(info.ModTime()) > 2*ChangeLogHeartbeat {
+		return nil, fmt.Errorf("watch 没有在运行（变更日志最后更新于 %s）", info.ModTime().Format("2006-01-02 15:04:05"))
+	}
+	records, err := readChangeLog(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取变更日志失败: %w", err)
+	}
+	if len(records) == 0 || records[0].Event != ChangeLogStart {
+		return nil, fmt.Errorf("变更日志 %s 格式不正确", path)
+	}
+
+	start := records[0]
+	if start.Time.After(since) {
+		return nil, fmt.Errorf("watch 开始于 %s，晚于上次运行", start.Time.Format("2006-01-02 15:04:05"))
+	}
+	for _, root := range roots {
+		if !rootWatched(root, start.Roots) {
+			return nil, fmt.Errorf("watch 没有监视 %s", root)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var changed []string
+	for _, rec := range records[1:] {
+		if rec.Time.Before(since) {
+			continue
+		}
+		switch rec.Event {
+		case ChangeLogOverflow:
+			return nil, fmt.Errorf("watch 在 %s 丢失了部分事件", rec.Time.Format("2006-01-02 15:04:05"))
+		case ChangeLogStop:
+			return nil, fmt.Errorf("watch 已于 %s 退出", rec.Time.Format("2006-01-02 15:04:05"))
+		case ChangeLogChange:
+			if !seen[rec.Path] {
+				seen[rec.Path] = true
+				changed = append(changed, rec.Path)
+			}
+		}
+	}
+	return changed, nil
+}
+
+// rootWatched 判断 root 是否位于任一监视的目录之内
+func rootWatched(root string, watched []string) bool {
+	for _, w := range watched {
+		if root == w || strings.HasPrefix(root, strings.TrimSuffix(w, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+)
+
+// LoadCleanedSources 读取被 clean-source 从源仓库删除的文件（备份目标下的相对路径，正斜杠分隔）
+// 这些文件的源文件缺失是预期的，清理阶段不应把备份移入历史目录
+func LoadCleanedSources(backupRoot string) (map[string]bool, error) {
+	cleaned := make(map[string]bool)
+	data, err := os.ReadFile(filepath.Join(backupRoot, config.CleanedSourcesFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cleaned, nil
+		}
+		return nil, fmt.Errorf("读取已清理文件记录失败: %v", err)
+	}
+	var rels []string
+	if err := json.Unmarshal(data, &rels); err != nil {
+		return nil, fmt.Errorf("解析已清理文件记录失败: %v", err)
+	}
+	for _, rel := range rels {
+		cleaned[rel] = true
+	}
+	return cleaned, nil
+}
+
+// AddCleanedSources 追加记录被 clean-source 删除的文件
+func AddCleanedSources(backupRoot string, rels []string) error {
+	if len(rels) == 0 {
+		return nil
+	}
+	cleaned, err := LoadCleanedSources(backupRoot)
+	if err != nil {
+		return err
+	}
+	for _, rel := range rels {
+		cleaned[rel] = true
+	}
+
+	all := make([]string, 0, len(cleaned))
+	for rel := range cleaned {
+		all = append(all, rel)
+	}
+	sort.Strings(all)
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fsguard.WriteFile(filepath.Join(backupRoot, config.CleanedSourcesFileName), data, 0644, "记录 clean-source 删除的源文件"); err != nil {
+		return fmt.Errorf("写入已清理文件记录失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// CleanupEntry 清理阶段将要处理的一个目标文件
+type CleanupEntry struct {
+	DestPath    string // 备份目标中的文件
+	HistoryPath string // 将被移入的历史路径
+	Reason      string // 被清理的原因
+}
+
+// CleanupReport 清理预演（--delete-dry-run）收集到的结果
+type CleanupReport struct {
+	mu      sync.Mutex
+	Entries []CleanupEntry
+}
+
+// add 记录一个将被清理的文件并输出
+func (r *CleanupReport) add(entry CleanupEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Entries = append(r.Entries, entry)
+	ui.Printf("[清理预演] 将移入历史: %s -> %s（%s）\n", entry.DestPath, entry.HistoryPath, entry.Reason)
+}
+
+// WriteFile 将报告写入文件
+func (r *CleanupReport) WriteFile(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg := config.GetGlobalConfig()
+	var b strings.Builder
+	fmt.Fprintf(&b, "# copy-ignore 清理预演报告\n")
+	fmt.Fprintf(&b, "# 生成时间: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&b, "# 搜索根目录: %s\n", cfg.SearchRoot)
+	fmt.Fprintf(&b, "# 备份根目录: %s\n", cfg.BackupRoot)
+	fmt.Fprintf(&b, "# 将移入历史的文件: %d 个（未做任何修改）\n#\n", len(r.Entries))
+	cfg.WriteEffective(&b, "# ")
+	b.WriteString("\n")
+	for _, e := range r.Entries {
+		fmt.Fprintf(&b, "%s\n", e.DestPath)
+		fmt.Fprintf(&b, "    移入: %s\n", e.HistoryPath)
+		fmt.Fprintf(&b, "    原因: %s\n", e.Reason)
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := fsguard.MkdirAll(dir, 0755, "写入清理预演报告"); err != nil {
+			return err
+		}
+	}
+	return fsguard.WriteFile(path, []byte(b.String()), 0644, "写入清理预演报告")
+}
+
+// cleanupReason 说明目标文件为什么会被清理
+func cleanupReason(cause cleanupCause, srcPath string) string {
+	switch cause {
+	case causeSourceDeleted:
+		return "源文件已不存在: " + srcPath
+	case causeFiltered:
+		return "源文件仍存在但已被排除规则过滤（--filtered-policy history）: " + srcPath
+	case causeNotIgnored:
+		return "源文件仍存在但已不再被 .gitignore 忽略: " + srcPath
+	case causeDuplicateForm:
+		return "与 Unicode 规范化（NFC）后的备份路径重复: " + srcPath
+	}
+	return "不在本次扫描结果中，且无法确定对应的源文件"
+}
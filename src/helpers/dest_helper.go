@@ -0,0 +1,153 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// destMarker 备份目标根目录下的标记文件内容，首次使用时创建
+type destMarker struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// knownDestinationsFile 本机使用过的备份目标记录（位于用户配置目录），用于识别未挂载的挂载点
+const knownDestinationsFile = "known-destinations.json"
+
+// ProbeDestination 开始扫描前检查备份目标：可写，且带有首次使用时创建的标记文件
+// 本机使用过的目标缺少标记文件时（通常是网络盘或移动硬盘未挂载，只剩空的挂载点目录）返回错误，
+// 避免把备份写到本地磁盘、或把空目录当作“源文件都已删除”去清理；initDest 为 true 时重新初始化标记
+// appendOnly 时无法删除探测文件，跳过可写检查
+func ProbeDestination(root string, initDest, appendOnly bool) error {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		abs = root
+	}
+
+	if !appendOnly {
+		if err := probeWritable(root); err != nil {
+			return fmt.Errorf("备份目标不可写: %v", err)
+		}
+	}
+
+	known := loadKnownDestinations()
+	markerPath := filepath.Join(root, config.DestMarkerFileName)
+	data, err := os.ReadFile(markerPath)
+	if err == nil {
+		var marker destMarker
+		if err := json.Unmarshal(data, &marker); err != nil {
+			return fmt.Errorf("备份目标标记文件损坏: %s (%v)", markerPath, err)
+		}
+		if prev, ok := known[abs]; ok && prev != marker.ID {
+			ui.Printf("警告: 备份目标 %s 的标记与上次不同，可能挂载了另一块磁盘\n", root)
+		}
+		if known[abs] != marker.ID {
+			known[abs] = marker.ID
+			saveKnownDestinations(known)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("读取备份目标标记失败: %v", err)
+	}
+
+	if _, ok := known[abs]; ok && !initDest {
+		return fmt.Errorf("备份目标 %s 之前使用过，但缺少标记文件 %s，可能是未挂载的挂载点；确认目标无误后使用 --init-dest 重新初始化",
+			root, config.DestMarkerFileName)
+	}
+
+	// 首次使用：创建标记并记录到本机
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("生成备份目标标记失败: %v", err)
+	}
+	marker := destMarker{ID: hex.EncodeToString(id), CreatedAt: time.Now()}
+	data, err = json.MarshalIndent(marker, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fsguard.WriteFile(markerPath, data, 0644, "写入备份目标标记"); err != nil {
+		return fmt.Errorf("写入备份目标标记失败: %v", err)
+	}
+	ui.Printf("已初始化备份目标: %s\n", root)
+	known[abs] = marker.ID
+	saveKnownDestinations(known)
+	return nil
+}
+
+// probeWritable 写入、读回并删除一个探测文件
+func probeWritable(root string) error {
+	path := filepath.Join(root, fmt.Sprintf(".copy-ignore-probe-%d", os.Getpid()))
+	content := []byte(time.Now().String())
+	f, err := fsguard.Create(path, "探测备份目标是否可写")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	defer fsguard.Remove(path, "删除探测文件")
+	if err != nil {
+		return err
+	}
+	readBack, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if string(readBack) != string(content) {
+		return fmt.Errorf("探测文件读回的内容不一致")
+	}
+	return nil
+}
+
+// knownDestinationsPath 返回本机备份目标记录的路径，无法确定用户配置目录时返回空字符串
+func knownDestinationsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "copy-ignore", knownDestinationsFile)
+}
+
+// loadKnownDestinations 读取本机使用过的备份目标（绝对路径 -> 标记 ID），读取失败时返回空记录
+func loadKnownDestinations() map[string]string {
+	known := make(map[string]string)
+	path := knownDestinationsPath()
+	if path == "" {
+		return known
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &known)
+	}
+	return known
+}
+
+// saveKnownDestinations 保存本机使用过的备份目标，失败时只提示
+func saveKnownDestinations(known map[string]string) {
+	path := knownDestinationsPath()
+	if path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(known, "", "  ")
+	if err == nil {
+		if err = fsguard.MkdirAll(filepath.Dir(path), 0755, "保存本机使用过的备份目标"); err == nil {
+			err = fsguard.WriteFile(path, data, 0644, "保存本机使用过的备份目标")
+		}
+	}
+	if err != nil {
+		ui.Errorf("警告: 保存备份目标记录失败: %v\n", err)
+	}
+}
@@ -0,0 +1,55 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aogg/copy-ignore/src/fsguard"
+)
+
+// CopyFileAtomic 将 src 复制到 dest（先写临时文件再重命名），并保留源文件的修改时间
+func CopyFileAtomic(src, dest string) error {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("获取源文件信息失败: %v", err)
+	}
+
+	if err := ensureDir(filepath.Dir(dest)); err != nil {
+		return fmt.Errorf("创建目标目录失败: %v", err)
+	}
+
+	tempPath := TempPath(dest)
+	if err := writeFileFrom(src, tempPath); err != nil {
+		fsguard.Remove(tempPath, "删除复制失败的临时文件")
+		return fmt.Errorf("复制文件内容失败: %v", err)
+	}
+
+	if err := fsguard.Rename(tempPath, dest, "复制完成，替换为新版本"); err != nil {
+		fsguard.Remove(tempPath, "删除重命名失败的临时文件")
+		return fmt.Errorf("重命名文件失败: %v", err)
+	}
+
+	return fsguard.Chtimes(dest, srcInfo.ModTime(), srcInfo.ModTime(), "同步修改时间")
+}
+
+// writeFileFrom 将 src 的内容写入 dest 并同步到磁盘
+func writeFileFrom(src, dest string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	destFile, err := fsguard.Create(dest, "写入临时文件")
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+
+	if _, err := io.Copy(destFile, srcFile); err != nil {
+		return err
+	}
+	return destFile.Sync()
+}
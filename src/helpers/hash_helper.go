@@ -0,0 +1,126 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/zeebo/blake3"
+	"github.com/zeebo/xxh3"
+)
+
+// HashFile 计算文件内容的 SHA-256 哈希，返回十六进制字符串
+func HashFile(path string) (string, error) {
+	return HashFileWith(path, config.HashSHA256)
+}
+
+// HashFileWith 按指定算法计算文件内容的哈希，返回十六进制字符串
+func HashFileWith(path, algorithm string) (string, error) {
+	h, err := NewHasher(algorithm)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// NewHasher 创建指定算法的哈希计算器，算法为空时使用 SHA-256
+func NewHasher(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case config.HashSHA256, "":
+		return sha256.New(), nil
+	case config.HashBLAKE3:
+		return blake3.New(), nil
+	case config.HashXXH3:
+		return &xxh3Hasher{xxh3.New()}, nil
+	}
+	return nil, fmt.Errorf("未知的哈希算法: %s（可选 %v）", algorithm, config.HashAlgorithms)
+}
+
+// HashAlgorithm 返回实际使用的哈希算法名称（空表示默认的 SHA-256）
+func HashAlgorithm(algorithm string) string {
+	if algorithm == "" {
+		return config.HashSHA256
+	}
+	return algorithm
+}
+
+// ValidateHashAlgorithm 检查哈希算法名称是否受支持（空表示默认的 SHA-256）
+func ValidateHashAlgorithm(algorithm string) error {
+	if algorithm != "" && !slices.Contains(config.HashAlgorithms, algorithm) {
+		return fmt.Errorf("未知的哈希算法: %s（可选 %v）", algorithm, config.HashAlgorithms)
+	}
+	return nil
+}
+
+// xxh3Hasher 输出 128 位结果的 XXH3（xxh3.Hasher 的 Sum 只有 64 位，用于去重时碰撞概率偏高）
+type xxh3Hasher struct {
+	*xxh3.Hasher
+}
+
+// Size 返回哈希结果的字节数
+func (h *xxh3Hasher) Size() int { return 16 }
+
+// Sum 将 128 位哈希结果追加到 b 之后
+func (h *xxh3Hasher) Sum(b []byte) []byte {
+	sum := h.Sum128().Bytes()
+	return append(b, sum[:]...)
+}
+
+// copiedHash 复制时顺带计算的目标文件哈希，以及计算时目标文件的大小和修改时间
+type copiedHash struct {
+	size      int64
+	mtime     time.Time
+	algorithm string
+	hash      string
+}
+
+// copiedHashes 本次运行复制的文件的哈希（目标路径 -> 哈希），复制协程并发写入
+var copiedHashes struct {
+	mu     sync.Mutex
+	hashes map[string]copiedHash
+}
+
+// ResetCopiedHashes 在每次运行开始时清空复制时记录的哈希
+func ResetCopiedHashes() {
+	copiedHashes.mu.Lock()
+	defer copiedHashes.mu.Unlock()
+	copiedHashes.hashes = nil
+}
+
+// RecordCopiedHash 记录复制时边写入边计算出的目标文件哈希，info 为写入完成后目标文件的信息
+func RecordCopiedHash(destPath string, info os.FileInfo, algorithm, hash string) {
+	copiedHashes.mu.Lock()
+	defer copiedHashes.mu.Unlock()
+	if copiedHashes.hashes == nil {
+		copiedHashes.hashes = make(map[string]copiedHash)
+	}
+	copiedHashes.hashes[destPath] = copiedHash{size: info.Size(), mtime: info.ModTime(), algorithm: HashAlgorithm(algorithm), hash: hash}
+}
+
+// CopiedHash 返回本次运行复制 path 时按 algorithm 记录的哈希；
+// 文件在那之后被改动（大小或修改时间不同）或使用的算法不同时返回 false
+func CopiedHash(path string, info os.FileInfo, algorithm string) (string, bool) {
+	copiedHashes.mu.Lock()
+	defer copiedHashes.mu.Unlock()
+	h, ok := copiedHashes.hashes[path]
+	if !ok || h.algorithm != HashAlgorithm(algorithm) || h.size != info.Size() || !h.mtime.Equal(info.ModTime()) {
+		return "", false
+	}
+	return h.hash, true
+}
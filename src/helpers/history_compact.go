@@ -0,0 +1,218 @@
+package helpers
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// 历史目录合并方式（history compact --mode）
+const (
+	CompactHardlink = "hardlink" // 内容、权限和修改时间都相同的版本改为硬链接，所有时间戳目录中的路径都保留（默认）
+	CompactDrop     = "drop"     // 同一路径与前一个时间戳的版本内容相同时删除较新的重复版本，最早的版本保留
+)
+
+// CompactOptions 历史目录合并的选项
+type CompactOptions struct {
+	Mode     string         // hardlink 或 drop
+	Layout   string         // 时间戳目录名的 Go 时间格式
+	Location *time.Location // 时间戳目录名使用的时区
+	Hash     string         // 判断内容相同使用的哈希算法，空表示 SHA-256
+	DryRun   bool           // 只统计可以合并的版本，不做修改
+	Verbose  bool           // 输出每个被合并的文件
+}
+
+// CompactResult 历史目录合并的结果
+type CompactResult struct {
+	Snapshots int   // 参与合并的时间戳目录数
+	Files     int   // 检查的文件数
+	Merged    int   // 改为硬链接或删除的重复版本数（预演时为可合并的数量）
+	Freed     int64 // 释放（预演时为可释放）的空间
+	Failed    int   // 合并失败的文件数（原文件保持不变）
+}
+
+// historyVersion 历史目录中某个时间戳下的一个文件版本
+type historyVersion struct {
+	path string
+	rel  string // 相对于时间戳目录的路径
+	info os.FileInfo
+	hash string // 内容的哈希，只有存在大小相同的其他版本时才计算
+}
+
+// CompactHistory 合并历史目录 historyBase 下各时间戳目录中内容相同的旧版本
+// 合并后每个时间戳目录中原有的文件仍能按原路径还原（hardlink），或由更早的同内容版本代替（drop）
+func CompactHistory(historyBase string, opts CompactOptions) (CompactResult, error) {
+	var result CompactResult
+	timestamps, err := listTimestampedDirsIn(historyBase, opts.Layout, opts.Location)
+	if err != nil {
+		return result, fmt.Errorf("读取历史目录失败: %w", err)
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].time.Before(timestamps[j].time)
+	})
+	result.Snapshots = len(timestamps)
+
+	// 收集所有版本，按大小分组，只有大小相同的文件才需要计算哈希
+	bySize := make(map[int64][]*historyVersion)
+	var versions []*historyVersion
+	for _, ts := range timestamps {
+		root := filepath.Join(historyBase, ts.name)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return nil
+			}
+			// 路径过长文件旁的原始路径记录与文件一一对应，不单独合并
+			if IsLongPathRecord(d.Name()) || IsRunTempName(d.Name()) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			rel, _ := filepath.Rel(root, path)
+			v := &historyVersion{path: path, rel: rel, info: info}
+			versions = append(versions, v)
+			bySize[info.Size()] = append(bySize[info.Size()], v)
+			return nil
+		})
+		if err != nil {
+			return result, fmt.Errorf("遍历历史目录失败 %s: %w", root, err)
+		}
+	}
+	result.Files = len(versions)
+
+	for _, group := range bySize {
+		if len(group) < 2 {
+			continue
+		}
+		for _, v := range group {
+			if h, err := HashFileWith(v.path, opts.Hash); err == nil {
+				v.hash = h
+			}
+		}
+	}
+
+	if opts.Mode == CompactDrop {
+		compactDrop(versions, opts, &result)
+	} else {
+		compactHardlink(versions, opts, &result)
+	}
+
+	if !opts.DryRun {
+		for _, ts := range timestamps {
+			removeEmptyDirs(filepath.Join(historyBase, ts.name))
+		}
+	}
+	return result, nil
+}
+
+// compactHardlink 将内容、权限和修改时间都相同的版本改为指向最早一个版本的硬链接
+func compactHardlink(versions []*historyVersion, opts CompactOptions, result *CompactResult) {
+	type linkKey struct {
+		hash  string
+		mode  os.FileMode
+		mtime int64
+	}
+	first := make(map[linkKey]*historyVersion)
+	for _, v := range versions {
+		if v.hash == "" {
+			continue
+		}
+		key := linkKey{v.hash, v.info.Mode(), v.info.ModTime().UnixNano()}
+		target, ok := first[key]
+		if !ok {
+			first[key] = v
+			continue
+		}
+		if os.SameFile(target.info, v.info) {
+			continue
+		}
+		if opts.Verbose {
+			ui.Printf("硬链接: %s -> %s\n", v.path, target.path)
+		}
+		if !opts.DryRun {
+			if err := replaceWithLink(target.path, v.path); err != nil {
+				ui.Errorf("改为硬链接失败 %s: %v\n", v.path, err)
+				result.Failed++
+				continue
+			}
+		}
+		result.Merged++
+		result.Freed += v.info.Size()
+	}
+}
+
+// compactDrop 同一路径的相邻版本内容相同时删除较新的版本；删除后该路径在这个时间戳的内容与更早的版本相同
+func compactDrop(versions []*historyVersion, opts CompactOptions, result *CompactResult) {
+	byRel := make(map[string][]*historyVersion)
+	for _, v := range versions {
+		byRel[v.rel] = append(byRel[v.rel], v)
+	}
+	for _, list := range byRel {
+		// versions 按时间戳先后收集，同一路径的版本已是从旧到新
+		for i := 1; i < len(list); i++ {
+			prev, v := list[i-1], list[i]
+			if v.hash == "" || v.hash != prev.hash {
+				continue
+			}
+			if opts.Verbose {
+				ui.Printf("删除重复版本: %s（与 %s 相同）\n", v.path, prev.path)
+			}
+			if !opts.DryRun {
+				if err := fsguard.Remove(v.path, "合并历史目录：删除与更早版本内容相同的重复版本"); err != nil {
+					ui.Errorf("删除重复版本失败 %s: %v\n", v.path, err)
+					result.Failed++
+					continue
+				}
+				// 路径过长的文件旁的原始路径记录随文件一起删除
+				if record := v.path + config.LongPathRecordSuffix; pathExists(record) {
+					fsguard.Remove(record, "合并历史目录：随重复版本删除原始路径记录")
+				}
+			}
+			result.Merged++
+			result.Freed += v.info.Size()
+		}
+	}
+}
+
+// replaceWithLink 先在旁边创建指向 target 的硬链接，再原子地替换 path，失败时 path 保持不变
+func replaceWithLink(target, path string) error {
+	tmp := TempPath(path)
+	if err := fsguard.Link(target, tmp, "合并历史目录：创建指向相同版本的硬链接"); err != nil {
+		return err
+	}
+	if err := fsguard.Rename(tmp, path, "合并历史目录：以硬链接替换重复版本"); err != nil {
+		fsguard.Remove(tmp, "删除未能替换的硬链接")
+		return err
+	}
+	return nil
+}
+
+// removeEmptyDirs 自底向上删除 root 下（含 root 本身）的空目录
+func removeEmptyDirs(root string) {
+	var dirs []string
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if entries, err := os.ReadDir(dirs[i]); err == nil && len(entries) == 0 {
+			fsguard.Remove(dirs[i], "合并历史目录：删除已空的目录")
+		}
+	}
+}
+
+// pathExists 判断路径是否存在
+func pathExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -7,19 +7,29 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/ui"
 )
 
 // BackupFileBeforeOverwrite 在覆盖文件前备份到历史文件夹
 // destPath: 要被覆盖的目标文件路径
 func BackupFileBeforeOverwrite(destPath string) error {
+	_, err := backupBeforeOverwrite(destPath)
+	return err
+}
+
+// backupBeforeOverwrite 将要被覆盖的目标文件移入第一个备份目录的历史目录，返回旧版本的新位置（没有备份目录时为空）
+func backupBeforeOverwrite(destPath string) (string, error) {
 	cfg := config.GetGlobalConfig()
 
 	// 计算相对路径
 	relPath, err := filepath.Rel(cfg.BackupRoot, destPath)
 	if err != nil {
-		return fmt.Errorf("计算相对路径失败: %v", err)
+		return "", fmt.Errorf("计算相对路径失败: %v", err)
 	}
 
 	// 对每个备份目录执行备份
@@ -32,45 +42,144 @@ func BackupFileBeforeOverwrite(destPath string) error {
 		backupBase := cfg.HandleHistoryDir(backupDir)
 
 		if cfg.Verbose {
-			fmt.Printf("备份将被覆盖的文件: %s -> %s\n", destPath, backupBase)
+			ui.Printf("备份将被覆盖的文件: %s -> %s\n", destPath, backupBase)
 		}
 
 		// 移动到备份目录
 		if err := moveToBackup(destPath, backupBase, relPath); err != nil {
-			return fmt.Errorf("备份到目录 %s 失败: %v", backupDir, err)
+			return "", fmt.Errorf("备份到目录 %s 失败: %v", backupDir, err)
 		}
 
 		// 清理旧备份
 		if err := pruneBackups(backupBase, relPath, cfg.BackupKeep, cfg.Verbose); err != nil {
-			return fmt.Errorf("清理备份目录失败: %v", err)
+			return "", fmt.Errorf("清理备份目录失败: %v", err)
 		}
 
 		// 只需要在一个备份目录中处理即可
-		break
+		return filepath.Join(backupBase, relPath), nil
 	}
 
-	return nil
+	return "", nil
+}
+
+// CleanupStats 清理阶段的统计
+type CleanupStats struct {
+	Skipped    bool // 整个清理阶段被跳过（其他机器正在使用同一备份目录、规则初始化失败等）
+	Moved      int  // 移入历史目录的文件数（清理预演时为将要移入的文件数）
+	PulledBack int  // 双向同步取回到源位置的文件数
+	Kept       int  // 源文件被过滤或已由 clean-source 删除而保留的备份数
+	Errors     int  // 移入历史目录或取回失败的文件数
 }
 
-// CleanupDeletedSrcFiles 清理已删除的源文件对应的目标文件
+// CleanupDeletedSrcFiles 清理已删除的源文件对应的目标文件，返回清理阶段的统计
 // targetPaths: 当前扫描到的目标文件路径集合 (destPath -> srcPath)
-func CleanupDeletedSrcFiles(targetPaths map[string]string) {
+// scopes: 本次扫描到的仓库在备份目标下的目录，只清理这些目录内的文件；为 nil 时不限制
+// sourceOf: 根据备份目标下的相对路径反推源文件路径，用于区分源文件已删除和被过滤；为 nil 时不区分
+func CleanupDeletedSrcFiles(targetPaths map[string]string, scopes []string, sourceOf func(rel string) string) (stats CleanupStats) {
 
 	if config.GetGlobalConfig().Verbose {
-		fmt.Printf("开始CleanupDeletedSrcFiles: %d\n", len(targetPaths))
+		ui.Printf("开始CleanupDeletedSrcFiles: %d\n", len(targetPaths))
 	}
 
 	cfg := config.GetGlobalConfig()
+	// 其他机器正在写入重叠的目录，清理可能误把对方的文件当作已删除，本次跳过
+	if cfg.SharedInUse {
+		ui.Println("其他机器正在使用同一备份目录，跳过清理")
+		stats.Skipped = true
+		return stats
+	}
 	// 遍历目标根目录
 	pathHandleHistoryDir := cfg.HandleHistoryDir(cfg.BackupRoot)
 
-	err := filepath.Walk(cfg.BackupRoot, func(destPath string, info os.FileInfo, err error) error {
+	// 受保护路径的匹配器（--protect）
+	protector, err := exclude.NewMatcher(cfg.Protect)
+	if err != nil {
+		ui.Errorf("初始化保护规则失败，跳过清理: %v\n", err)
+		stats.Skipped = true
+		return stats
+	}
+
+	// 排除规则，用于识别仅因被过滤而不再复制的文件
+	excluder, err := exclude.NewMatcher(cfg.Excludes)
+	if err != nil {
+		ui.Errorf("初始化排除规则失败，跳过清理: %v\n", err)
+		stats.Skipped = true
+		return stats
+	}
+	if cfg.SkipCaches {
+		excluder.SkipCaches()
+	}
+	if !cfg.IgnoreBackupMarkers {
+		excluder.SkipMarkedDirs()
+	}
+
+	// 双向同步的文件模式，源文件不存在时从备份取回而不是移入历史目录
+	syncer, err := exclude.NewMatcher(cfg.Sync)
+	if err != nil {
+		ui.Errorf("初始化同步规则失败，跳过清理: %v\n", err)
+		stats.Skipped = true
+		return stats
+	}
+
+	// 被 clean-source 删除了源文件的备份需要保留
+	cleaned, err := LoadCleanedSources(cfg.BackupRoot)
+	if err != nil {
+		ui.Errorf("%v，跳过清理\n", err)
+		stats.Skipped = true
+		return stats
+	}
+
+	// 本机上次运行结束时源位置存在的双向同步文件：源位置缺失的同步文件只有不在其中时才从备份取回
+	synced, err := LoadSyncedSources(cfg.BackupRoot, cfg.HostName)
+	if err != nil {
+		ui.Errorf("%v，跳过清理\n", err)
+		stats.Skipped = true
+		return stats
+	}
+
+	// 清理预演模式（--delete-dry-run）只记录将被清理的文件，不做任何修改
+	var report *CleanupReport
+	if cfg.DeleteDryRun {
+		report = &CleanupReport{}
+	}
+
+	longDir := filepath.Join(cfg.BackupRoot, config.LongPathDirName)
+	var pulled []string // 本次从备份取回到源位置的同步文件（备份目标下的相对路径）
+	err = filepath.Walk(cfg.BackupRoot, func(destPath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		inLongDir := IsWithin(destPath, longDir)
+
+		// 受保护的路径（及其子孙）不参与清理
+		if destPath != cfg.BackupRoot && isProtectedPath(protector, cfg.BackupRoot, destPath) {
+			if cfg.Verbose {
+				ui.Printf("跳过受保护路径: %s\n", destPath)
+			}
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 
 		// 跳过目录，只处理文件
 		if info.IsDir() {
+			// 块池、移动日志、租约由工具自身管理，不参与清理
+			if info.Name() == config.ChunkDirName || info.Name() == config.JournalDirName || info.Name() == config.LockDirName {
+				return filepath.SkipDir
+			}
+			// 其他机器的备份子树（--per-host）不参与清理
+			if destPath != cfg.BackupRoot && IsHostSubtree(destPath) {
+				return filepath.SkipDir
+			}
+			// 路径过长的文件所在的哈希目录，其中的文件按记录的原始路径判断范围
+			if inLongDir {
+				return nil
+			}
+			// 不在任何已扫描仓库范围内、也不包含这类仓库的目录，整体跳过
+			if scopes != nil && !scopeRelated(destPath, scopes) {
+				return filepath.SkipDir
+			}
 			// 检查是否是备份子目录，如果是则跳过整个目录
 			// 排除历史记录目录及其子目录
 			if pathHandleHistoryDir != "" {
@@ -82,6 +191,58 @@ func CleanupDeletedSrcFiles(targetPaths map[string]string) {
 			return nil
 		}
 
+		// 跳过工具自身维护的文件（清单、仓库名映射）
+		if config.IsManagedFile(info.Name()) {
+			return nil
+		}
+		// 正在写入的临时文件（本次运行的工作协程或其他运行）不参与清理，遗留的由启动时的 CleanupOrphanedTempFiles 处理
+		if IsRunTempName(info.Name()) {
+			return nil
+		}
+		// --overwrite suffix-rename 保留的旧版本：对应的文件仍在时保留（由覆盖时的轮换处理），否则与对应文件一样按已删除处理
+		if base := OverwriteVersionBase(destPath); base != "" {
+			if _, err := os.Lstat(base); err == nil {
+				return nil
+			}
+		}
+
+		// 路径过长、改存到哈希目录的文件：按旁边记录的原始路径判断（记录文件随文件一起处理）
+		scopePath, srcRel := destPath, ""
+		if inLongDir {
+			if IsLongPathRecord(info.Name()) {
+				return nil
+			}
+			orig, err := ReadLongPathRecord(destPath)
+			if err != nil {
+				return nil
+			}
+			srcRel = filepath.FromSlash(orig)
+			scopePath = filepath.Join(cfg.BackupRoot, srcRel)
+		}
+
+		// 云端占位文件的元数据记录：对应的文件已有完整备份时记录已过期，直接删除；
+		// 对应的文件仍在本次扫描中时保留；否则按对应文件的源路径判断
+		if IsPlaceholderStub(info.Name()) {
+			realDest := strings.TrimSuffix(destPath, config.PlaceholderStubSuffix)
+			if _, err := os.Stat(realDest); err == nil {
+				if report == nil {
+					fsguard.Remove(destPath, "对应的文件已有完整备份，删除过期的占位文件元数据")
+				}
+				return nil
+			}
+			if _, ok := targetPaths[realDest]; ok {
+				return nil
+			}
+			if rel, err := filepath.Rel(cfg.BackupRoot, realDest); err == nil && srcRel == "" {
+				srcRel = rel
+			}
+		}
+
+		// 不属于本次扫描的任何仓库（如手动放入的文件、其他搜索根目录的备份），不做清理
+		if scopes != nil && !withinAny(scopePath, scopes) {
+			return nil
+		}
+
 		// 检查目标文件是否在当前扫描的文件中
 		_, exists := targetPaths[destPath]
 		if exists {
@@ -98,22 +259,85 @@ func CleanupDeletedSrcFiles(targetPaths map[string]string) {
 			return nil
 		}
 
-		// 目标文件不在当前扫描中，说明源文件已被删除
-		// 需要备份并删除目标文件
-		if cfg.Verbose {
-			fmt.Printf("检测到源文件已删除，准备备份目标文件: %s\n", destPath)
-		}
-
 		// 计算相对路径
 		relPath, err := filepath.Rel(cfg.BackupRoot, destPath)
 		if err != nil {
 			if cfg.Verbose {
-				fmt.Fprintf(os.Stderr, "计算相对路径失败 %s: %v\n", destPath, err)
+				ui.Errorf("计算相对路径失败 %s: %v\n", destPath, err)
+			}
+			return nil
+		}
+
+		// 目标文件不在当前扫描中：区分源文件已删除和源文件仅被排除规则过滤
+		if srcRel == "" {
+			srcRel = relPath
+		}
+		srcPath := ""
+		if sourceOf != nil {
+			srcPath = sourceOf(srcRel)
+		}
+		cause := classifyCleanup(cfg, excluder, srcPath)
+		// 旧版本按原始（NFD）文件名写入的备份，本次已按 NFC 形式的路径复制，是重复条目
+		if nfc := filepath.Join(cfg.BackupRoot, NormalizePath(relPath)); nfc != destPath {
+			if _, ok := targetPaths[nfc]; ok {
+				cause = causeDuplicateForm
+			}
+		}
+		if cause == causeFiltered && cfg.FilteredPolicy != config.FilteredHistory {
+			if cfg.Verbose {
+				ui.Printf("源文件已被排除规则过滤，保留备份: %s\n", destPath)
+			}
+			stats.Kept++
+			return nil
+		}
+
+		// 源文件已由 clean-source 在校验备份后删除，备份是唯一副本，保留
+		if cause == causeSourceDeleted && cleaned[filepath.ToSlash(relPath)] {
+			if cfg.Verbose {
+				ui.Printf("源文件已由 clean-source 删除，保留备份: %s\n", destPath)
 			}
+			stats.Kept++
+			return nil
+		}
+
+		// 双向同步的文件：源位置（所在目录仍在）缺少该文件、本机上次运行时也没有，是另一台机器新增的，取回到源位置；
+		// 本机上次运行时还有的是本机删除的，与其他文件一样移入历史目录，不会在每次运行时被取回
+		if cause == causeSourceDeleted && syncer.ShouldExclude(srcPath) && isDir(filepath.Dir(srcPath)) && !synced[filepath.ToSlash(relPath)] {
+			if report != nil {
+				ui.Printf("[清理预演] 将从备份取回到源位置: %s -> %s\n", destPath, srcPath)
+				stats.PulledBack++
+				return nil
+			}
+			if err := PullBackToSource(destPath, srcPath); err != nil {
+				ui.Errorf("从备份取回失败 %s: %v\n", destPath, err)
+				stats.Errors++
+			} else {
+				stats.PulledBack++
+				pulled = append(pulled, filepath.ToSlash(relPath))
+				if cfg.Verbose {
+					ui.Printf("已从备份取回: %s -> %s\n", destPath, srcPath)
+				}
+			}
+			return nil
+		}
+
+		// 需要备份并删除目标文件
+		if cfg.Verbose {
+			ui.Printf("检测到源文件已删除，准备备份目标文件: %s\n", destPath)
+		}
+
+		if report != nil {
+			report.add(CleanupEntry{
+				DestPath:    destPath,
+				HistoryPath: filepath.Join(cfg.HandleHistoryDir(cfg.BackupRoot), relPath),
+				Reason:      cleanupReason(cause, srcPath),
+			})
+			stats.Moved++
 			return nil
 		}
 
 		// 备份并删除目标文件
+		moved := false
 		for _, backupDir := range cfg.BackupDirs {
 			if backupDir == "" {
 				continue
@@ -123,36 +347,151 @@ func CleanupDeletedSrcFiles(targetPaths map[string]string) {
 			backupBase := cfg.HandleHistoryDir(backupDir)
 
 			if cfg.Verbose {
-				fmt.Printf("备份目标文件: %s -> %s\n", destPath, backupBase)
+				ui.Printf("备份目标文件: %s -> %s\n", destPath, backupBase)
 			}
 			if err := moveToBackup(destPath, backupBase, relPath); err != nil {
-				fmt.Fprintf(os.Stderr, "备份失败 %s: %v\n", destPath, err)
+				ui.Errorf("备份失败 %s: %v\n", destPath, err)
 				continue
 			}
+			if inLongDir {
+				if err := moveToBackup(destPath+config.LongPathRecordSuffix, backupBase, relPath+config.LongPathRecordSuffix); err != nil {
+					ui.Errorf("备份原始路径记录失败 %s: %v\n", destPath, err)
+				}
+			}
 
 			// 清理旧备份
 			if err := pruneBackups(backupBase, relPath, cfg.BackupKeep, cfg.Verbose); err != nil {
-				fmt.Fprintf(os.Stderr, "清理备份目录失败 %s: %v\n", backupBase, err)
+				ui.Errorf("清理备份目录失败 %s: %v\n", backupBase, err)
 				if cfg.Verbose {
 				}
 			}
 
 			// 备份成功后删除目标文件
 			if cfg.Verbose {
-				fmt.Printf("源文件已删除，备份并移除目标文件: %s\n", destPath)
+				ui.Printf("源文件已删除，备份并移除目标文件: %s\n", destPath)
 			}
 			// 只需要在一个备份目录中处理即可，因为目标文件只有一个
+			moved = true
 			break
 		}
+		if moved {
+			stats.Moved++
+		} else {
+			stats.Errors++
+		}
 
 		return nil
 	})
 
 	if err != nil {
 		if cfg.Verbose {
-			fmt.Fprintf(os.Stderr, "遍历目标目录失败: %v\n", err)
+			ui.Errorf("遍历目标目录失败: %v\n", err)
+		}
+	}
+
+	// 记录本机源位置现有的双向同步文件（包括刚取回的），供下次运行区分本机删除的文件
+	if report == nil && len(cfg.Sync) > 0 && err == nil {
+		present := make(map[string]bool)
+		for dest, src := range targetPaths {
+			if !syncer.ShouldExclude(src) {
+				continue
+			}
+			if _, statErr := os.Lstat(src); statErr != nil {
+				continue
+			}
+			if rel, relErr := filepath.Rel(cfg.BackupRoot, dest); relErr == nil {
+				present[filepath.ToSlash(rel)] = true
+			}
+		}
+		for _, rel := range pulled {
+			present[rel] = true
+		}
+		if saveErr := SaveSyncedSources(cfg.BackupRoot, cfg.HostName, present, scopes); saveErr != nil {
+			ui.Errorf("%v\n", saveErr)
+		}
+	}
+
+	if report != nil {
+		ui.Printf("[清理预演] 共 %d 个文件将被移入历史，未做任何修改\n", len(report.Entries))
+		if cfg.DeleteReport != "" {
+			if err := report.WriteFile(cfg.DeleteReport); err != nil {
+				ui.Errorf("写入清理预演报告失败: %v\n", err)
+			} else {
+				ui.Printf("[清理预演] 报告已写入: %s\n", cfg.DeleteReport)
+			}
+		}
+	}
+	return stats
+}
+
+// cleanupCause 目标文件不在本次扫描结果中的原因
+type cleanupCause int
+
+const (
+	causeUnknown       cleanupCause = iota // 无法确定对应的源文件
+	causeSourceDeleted                     // 源文件已删除
+	causeFiltered                          // 源文件仍在，但被排除规则过滤
+	causeNotIgnored                        // 源文件仍在，但已不再被 .gitignore 忽略
+	causeDuplicateForm                     // 与本次复制的 NFC 形式路径重复（Unicode 规范化前写入的备份）
+)
+
+// classifyCleanup 判断目标文件不在本次扫描结果中的原因
+func classifyCleanup(cfg *config.Config, excluder *exclude.Matcher, srcPath string) cleanupCause {
+	if srcPath == "" {
+		return causeUnknown
+	}
+	if _, err := os.Lstat(srcPath); os.IsNotExist(err) {
+		return causeSourceDeleted
+	}
+
+	// 扫描时被排除的目录下的文件同样不会出现，因此逐级检查到搜索根目录
+	for p := srcPath; ; p = filepath.Dir(p) {
+		if excluder.ShouldExclude(p) {
+			return causeFiltered
+		}
+		if p == cfg.SearchRoot || filepath.Dir(p) == p {
+			break
 		}
 	}
+	return causeNotIgnored
+}
+
+// isDir 判断路径是否为已存在的目录
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// isProtectedPath 判断备份目标中的路径是否匹配 --protect 规则（同时按绝对路径和相对备份根目录的路径匹配）
+func isProtectedPath(protector *exclude.Matcher, backupRoot, path string) bool {
+	if protector.ShouldExclude(path) {
+		return true
+	}
+	rel, err := filepath.Rel(backupRoot, path)
+	return err == nil && protector.ShouldExclude(rel)
+}
+
+// withinAny 判断 path 是否等于或位于任一目录之下
+func withinAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeRelated 判断目录是否位于某个清理范围内，或包含某个清理范围（需要继续向下遍历）
+func scopeRelated(dir string, scopes []string) bool {
+	if withinAny(dir, scopes) {
+		return true
+	}
+	for _, scope := range scopes {
+		if strings.HasPrefix(scope, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
 }
 
 // BackupPathIfModified 检查目标路径是否被修改，如果被修改则备份到指定的备份目录列表
@@ -221,9 +560,9 @@ func removeDestIfExists(destPath string, verbose bool) error {
 	}
 	// 目标存在，删除它
 	if verbose {
-		fmt.Printf("源文件已删除，移除目标文件: %s\n", destPath)
+		ui.Printf("源文件已删除，移除目标文件: %s\n", destPath)
 	}
-	if err := os.RemoveAll(destPath); err != nil {
+	if err := fsguard.RemoveAll(destPath, "源文件已删除，移除目标文件"); err != nil {
 		return fmt.Errorf("删除目标文件失败: %v", err)
 	}
 	return nil
@@ -341,33 +680,53 @@ func moveToBackup(src string, destBase string, relPath string) error {
 
 	// 尝试使用 os.Rename 进行快速移动（同设备）
 	if config.GetGlobalConfig().Verbose {
-		fmt.Printf("移动--moveToBackup: %s -> %s\n", src, backupTarget)
+		ui.Printf("移动--moveToBackup: %s -> %s\n", src, backupTarget)
+	}
+
+	// 移动前统计大小，用于汇总历史目录新占用的空间
+	files, size := pathUsage(src)
+
+	// 先写入意图日志，中途崩溃时下次运行可据此完成或回滚
+	journal, err := beginMove(config.GetGlobalConfig().BackupRoot, src, backupTarget)
+	if err != nil {
+		return err
 	}
 
-	if err := os.Rename(src, backupTarget); err == nil {
+	if err := fsguard.Rename(src, backupTarget, "移入历史目录"); err == nil {
+		journal.done()
+		recordHistoryMove(files, size)
 		return nil // 成功移动
 	}
 
 	// Rename失败（可能是跨设备），回退到复制+删除
 	if err := copyRecursive(src, backupTarget); err != nil {
+		fsguard.RemoveAll(backupTarget, "删除复制到历史目录失败的残留")
+		journal.done()
 		return fmt.Errorf("复制到备份目录失败: %v", err)
 	}
 
+	// 目标已完整，记录后才删除源路径
+	if err := journal.markCopied(); err != nil {
+		return err
+	}
+
 	if config.GetGlobalConfig().Verbose {
-		fmt.Printf("删除: %s\n", src)
+		ui.Printf("删除: %s\n", src)
 	}
 
 	// 删除原目录/文件
-	if err := os.RemoveAll(src); err != nil {
+	if err := fsguard.RemoveAll(src, "已复制到历史目录，删除原位置"); err != nil {
 		return fmt.Errorf("删除原路径失败: %v", err)
 	}
+	journal.done()
+	recordHistoryMove(files, size)
 
 	return nil
 }
 
 // ensureDir 确保目录存在，如果不存在则创建
 func ensureDir(dir string) error {
-	return os.MkdirAll(dir, 0755)
+	return fsguard.MkdirAll(dir, 0755, "创建目录")
 }
 
 // copyRecursive 递归复制文件或目录
@@ -415,7 +774,7 @@ func copyDirRecursive(src, dest string) error {
 // copyFileContent 复制文件内容
 func copyFileContent(src, dest string) error {
 	if config.GetGlobalConfig().Verbose {
-		fmt.Fprintf(os.Stdout, "history: 复制文件 %s -> %s\n", src, dest)
+		ui.Printf("history: 复制文件 %s -> %s\n", src, dest)
 	}
 
 	srcFile, err := os.Open(src)
@@ -424,7 +783,7 @@ func copyFileContent(src, dest string) error {
 	}
 	defer srcFile.Close()
 
-	destFile, err := os.Create(dest)
+	destFile, err := fsguard.Create(dest, "复制到历史目录")
 	if err != nil {
 		return err
 	}
@@ -440,6 +799,10 @@ func copyFileContent(src, dest string) error {
 
 // pruneBackups 清理备份，只保留最近的keep个备份
 func pruneBackups(destBase, relPath string, keep int, verbose bool) error {
+	// 其他机器正在写入重叠的目录时不轮换，避免删除对方刚写入的历史版本
+	if config.GetGlobalConfig().SharedInUse {
+		return nil
+	}
 	backupDir := filepath.Join(destBase, relPath)
 
 	// 获取所有时间戳目录
@@ -451,46 +814,124 @@ func pruneBackups(destBase, relPath string, keep int, verbose bool) error {
 	// 如果备份数不超过keep，直接返回
 	if len(timestamps) <= keep {
 		if verbose {
-			fmt.Printf("备份目录 %s 当前备份数 %d，无需清理（保留 %d）\n", backupDir, len(timestamps), keep)
+			ui.Printf("备份目录 %s 当前备份数 %d，无需清理（保留 %d）\n", backupDir, len(timestamps), keep)
 		}
 		return nil
 	}
 
-	// 按时间戳排序（最新的在前）
-	sort.Sort(sort.Reverse(sort.StringSlice(timestamps)))
+	// 按解析出的时间排序（最新的在前），不依赖目录名的字典序
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].time.After(timestamps[j].time)
+	})
 
 	// 删除超出keep的旧备份
 	for i := keep; i < len(timestamps); i++ {
-		oldBackup := filepath.Join(backupDir, timestamps[i])
+		oldBackup := filepath.Join(backupDir, timestamps[i].name)
+		if !PruneConfirmed(config.GetGlobalConfig()) {
+			recordPrunePlan(backupDir, oldBackup)
+			continue
+		}
 		if verbose {
-			fmt.Printf("删除旧备份: %s\n", oldBackup)
+			ui.Printf("删除旧备份: %s\n", oldBackup)
 		}
-		if err := os.RemoveAll(oldBackup); err != nil {
+		files, size := pathUsage(oldBackup)
+		if err := fsguard.RemoveAll(oldBackup, "轮换历史：删除超出保留数量的旧版本"); err != nil {
 			return fmt.Errorf("删除旧备份失败 %s: %v", oldBackup, err)
 		}
+		recordHistoryPrune(files, size)
 	}
 
 	return nil
 }
 
+// LatestHistoryVersion 返回历史目录中 relPath 最新一个版本的文件信息，没有历史版本时返回 nil
+// 历史版本位于 <历史目录>/<时间戳>/<relPath>
+func LatestHistoryVersion(relPath string) os.FileInfo {
+	cfg := config.GetGlobalConfig()
+	historyBase := filepath.Dir(cfg.HandleHistoryDir(cfg.BackupRoot))
+
+	timestamps, err := listTimestampedDirs(historyBase)
+	if err != nil {
+		return nil
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].time.After(timestamps[j].time)
+	})
+	for _, ts := range timestamps {
+		if info, err := os.Stat(filepath.Join(historyBase, ts.name, relPath)); err == nil {
+			return info
+		}
+	}
+	return nil
+}
+
+// timestampedDir 以时间戳命名的备份目录
+type timestampedDir struct {
+	name string
+	time time.Time
+}
+
 // listTimestampedDirs 列出指定目录下的所有时间戳目录
-func listTimestampedDirs(dir string) ([]string, error) {
+// 目录名按配置的时间戳格式和时区解析（兼容默认格式），无法解析的目录不参与轮换
+func listTimestampedDirs(dir string) ([]timestampedDir, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // 目录不存在，返回空列表
+		}
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, nil
+	}
+
+	cfg := config.GetGlobalConfig()
+	loc, err := LoadTimestampZone(cfg.TimestampZone)
+	if err != nil {
+		loc = time.Local
+	}
+	return listTimestampedDirsIn(dir, ResolveTimestampFormat(cfg.TimestampFormat), loc)
+}
+
+// HistoryVersion 历史目录中的一个时间戳目录，保存该次运行替换或删除的旧版本
+type HistoryVersion struct {
+	Name string    // 时间戳目录名
+	Time time.Time // 按时间戳格式解析出的时间
+}
+
+// ListHistoryVersions 按时间从早到晚列出历史目录 historyBase 下的时间戳目录，无法按时间戳格式解析的目录不列出
+func ListHistoryVersions(historyBase, layout string, loc *time.Location) ([]HistoryVersion, error) {
+	timestamps, err := listTimestampedDirsIn(historyBase, layout, loc)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].time.Before(timestamps[j].time)
+	})
+	versions := make([]HistoryVersion, len(timestamps))
+	for i, ts := range timestamps {
+		versions[i] = HistoryVersion{Name: ts.name, Time: ts.time}
+	}
+	return versions, nil
+}
+
+// listTimestampedDirsIn 按指定的时间戳格式和时区列出 dir 下的时间戳目录（不依赖全局配置，供子命令使用）
+func listTimestampedDirsIn(dir, layout string, loc *time.Location) ([]timestampedDir, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return []string{}, nil // 目录不存在，返回空列表
+			return nil, nil
 		}
 		return nil, err
 	}
 
-	var timestamps []string
+	var timestamps []timestampedDir
 	for _, entry := range entries {
-		if entry.IsDir() {
-			// 检查目录名是否符合时间戳格式 (YYYYMMDD-HHMMSS)
-			name := entry.Name()
-			if len(name) == 15 && strings.Contains(name, "-") {
-				timestamps = append(timestamps, name)
-			}
+		if !entry.IsDir() {
+			continue
+		}
+		if t, ok := ParseTimestampDir(entry.Name(), layout, loc); ok {
+			timestamps = append(timestamps, timestampedDir{name: entry.Name(), time: t})
 		}
 	}
 
@@ -0,0 +1,76 @@
+package helpers
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// PruneOptions 历史目录轮换的选项
+type PruneOptions struct {
+	Keep     int            // 保留最新的时间戳目录数
+	Before   time.Time      // 非零时只删除早于该时间的时间戳目录（最新的 Keep 个仍然保留）
+	Layout   string         // 时间戳目录名的 Go 时间格式
+	Location *time.Location // 时间戳目录名使用的时区
+	DryRun   bool           // 只列出将被删除的时间戳目录，不做修改
+	Verbose  bool           // 输出每个被删除的时间戳目录
+}
+
+// PruneResult 历史目录轮换的结果
+type PruneResult struct {
+	Snapshots int         // 历史目录中的时间戳目录数
+	Pruned    []PrunePlan // 删除（预演时为将被删除）的时间戳目录
+	Failed    int         // 删除失败的时间戳目录数
+}
+
+// PrunedBytes 返回删除（预演时为将被删除）的时间戳目录的总大小
+func (r PruneResult) PrunedBytes() int64 {
+	var total int64
+	for _, p := range r.Pruned {
+		total += p.Bytes
+	}
+	return total
+}
+
+// PruneHistory 删除历史目录 historyBase 下超出保留数量（或早于指定时间）的整个时间戳目录，不需要运行复制
+// 与复制时按 --backup-keep 轮换不同，这里按整次运行的时间戳目录计算，适合在备份目标上定期单独执行
+func PruneHistory(historyBase string, opts PruneOptions) (PruneResult, error) {
+	var result PruneResult
+	timestamps, err := listTimestampedDirsIn(historyBase, opts.Layout, opts.Location)
+	if err != nil {
+		return result, fmt.Errorf("读取历史目录失败: %w", err)
+	}
+	// 最新的在前
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].time.After(timestamps[j].time)
+	})
+	result.Snapshots = len(timestamps)
+
+	for i := opts.Keep; i < len(timestamps); i++ {
+		ts := timestamps[i]
+		if !opts.Before.IsZero() && !ts.time.Before(opts.Before) {
+			continue
+		}
+		path := filepath.Join(historyBase, ts.name)
+		files, size := pathUsage(path)
+		plan := PrunePlan{Path: historyBase, Version: ts.name, Files: files, Bytes: size}
+		if opts.DryRun {
+			result.Pruned = append(result.Pruned, plan)
+			continue
+		}
+		if opts.Verbose {
+			ui.Printf("删除旧备份: %s\n", path)
+		}
+		if err := fsguard.RemoveAll(path, "prune：删除超出保留数量或过期的历史时间戳目录"); err != nil {
+			ui.Errorf("删除旧备份失败 %s: %v\n", path, err)
+			result.Failed++
+			continue
+		}
+		result.Pruned = append(result.Pruned, plan)
+	}
+	return result, nil
+}
@@ -0,0 +1,116 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aogg/copy-ignore/src/config"
+)
+
+// HistoryStats 本次运行中历史机制新占用和释放的空间，用于按实际数据调整 --backup-keep
+type HistoryStats struct {
+	Moved       int   `json:"moved"`        // 保留为旧版本的文件数（移入历史目录或按 --overwrite suffix-rename 重命名）
+	MovedBytes  int64 `json:"moved_bytes"`  // 保留为旧版本的文件总大小
+	Pruned      int   `json:"pruned"`       // 超出 --backup-keep 被轮换删除的文件数
+	PrunedBytes int64 `json:"pruned_bytes"` // 轮换删除释放的空间
+
+	Planned []PrunePlan `json:"planned,omitempty"` // 超出 --backup-keep、但因轮换预演或未确认而没有删除的旧版本
+}
+
+// PrunePlan 超出 --backup-keep、将被轮换删除的一个旧版本
+type PrunePlan struct {
+	Path    string `json:"path"`    // 被轮换的路径：历史目录中的路径，或 --overwrite suffix-rename 时的目标文件
+	Version string `json:"version"` // 将被删除的时间戳目录或旧版本文件名
+	Files   int    `json:"files"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// Empty 判断本次运行是否没有保留、删除或计划删除任何旧版本
+func (s HistoryStats) Empty() bool {
+	return s.Moved == 0 && s.Pruned == 0 && len(s.Planned) == 0
+}
+
+// PlannedBytes 返回计划删除的旧版本的总大小
+func (s HistoryStats) PlannedBytes() int64 {
+	var total int64
+	for _, p := range s.Planned {
+		total += p.Bytes
+	}
+	return total
+}
+
+// PruneConfirmed 判断本次运行是否实际删除超出 --backup-keep 的旧版本：
+// 轮换预演（--keep-dry-run）时不删除；在交互式终端中运行时需要 --yes 确认
+func PruneConfirmed(cfg *config.Config) bool {
+	return !cfg.KeepDryRun && (cfg.Yes || !cfg.Interactive)
+}
+
+// historyStats 当前运行的历史统计，移入历史目录和轮换可能在多个复制协程中同时进行
+var historyStats struct {
+	mu    sync.Mutex
+	stats HistoryStats
+}
+
+// ResetHistoryStats 在每次运行开始时清零历史统计
+func ResetHistoryStats() {
+	historyStats.mu.Lock()
+	defer historyStats.mu.Unlock()
+	historyStats.stats = HistoryStats{}
+}
+
+// CurrentHistoryStats 返回本次运行到目前为止的历史统计
+func CurrentHistoryStats() HistoryStats {
+	historyStats.mu.Lock()
+	defer historyStats.mu.Unlock()
+	stats := historyStats.stats
+	stats.Planned = append([]PrunePlan(nil), stats.Planned...)
+	return stats
+}
+
+// recordHistoryMove 记录保留为旧版本的文件数和大小（在移动之前统计）
+func recordHistoryMove(files int, size int64) {
+	historyStats.mu.Lock()
+	defer historyStats.mu.Unlock()
+	historyStats.stats.Moved += files
+	historyStats.stats.MovedBytes += size
+}
+
+// recordHistoryPrune 记录轮换删除的文件数和释放的空间（在删除之前统计）
+func recordHistoryPrune(files int, size int64) {
+	historyStats.mu.Lock()
+	defer historyStats.mu.Unlock()
+	historyStats.stats.Pruned += files
+	historyStats.stats.PrunedBytes += size
+}
+
+// recordPrunePlan 记录超出 --backup-keep、但本次没有删除的旧版本 victim（path 为被轮换的路径）
+func recordPrunePlan(path, victim string) {
+	files, size := pathUsage(victim)
+	version := filepath.Base(victim)
+	historyStats.mu.Lock()
+	defer historyStats.mu.Unlock()
+	historyStats.stats.Planned = append(historyStats.stats.Planned, PrunePlan{Path: path, Version: version, Files: files, Bytes: size})
+}
+
+// pathUsage 统计文件或目录下的普通文件数和总大小（读取失败的子路径忽略）
+func pathUsage(root string) (files int, size int64) {
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+	return files, size
+}
+
+// HistoryUsage 统计备份根目录的历史目录当前的时间戳目录数和总大小
+func HistoryUsage(cfg *config.Config) (versions int, size int64) {
+	historyBase := filepath.Dir(cfg.HandleHistoryDir(cfg.BackupRoot))
+	timestamps, err := listTimestampedDirs(historyBase)
+	if err != nil || len(timestamps) == 0 {
+		return 0, 0
+	}
+	return len(timestamps), DirSize(historyBase)
+}
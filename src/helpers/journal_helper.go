@@ -0,0 +1,222 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// 移动日志的阶段
+const (
+	journalIntent = "intent" // 即将移动，源路径完整，目标可能只复制了一部分
+	journalCopied = "copied" // 已完整复制到目标，源路径可能只删除了一部分
+)
+
+// journalEntry 一次移入历史目录操作的意图记录
+type journalEntry struct {
+	Src   string `json:"src"`
+	Dest  string `json:"dest"`
+	Phase string `json:"phase"`
+}
+
+// moveJournal 单次移动对应的日志文件
+type moveJournal struct {
+	path  string
+	entry journalEntry
+}
+
+// journalDir 返回备份根目录下的移动日志目录
+func journalDir(backupRoot string) string {
+	return filepath.Join(backupRoot, config.JournalDirName)
+}
+
+// beginMove 在执行移动前写入意图记录，崩溃后可据此完成或回滚
+func beginMove(backupRoot, src, dest string) (*moveJournal, error) {
+	dir := journalDir(backupRoot)
+	if err := fsguard.MkdirAll(dir, 0755, "创建移动日志目录"); err != nil {
+		return nil, fmt.Errorf("创建移动日志目录失败: %v", err)
+	}
+	f, err := fsguard.CreateTemp(dir, "move-*.json", "记录移入历史目录的意图")
+	if err != nil {
+		return nil, fmt.Errorf("创建移动日志失败: %v", err)
+	}
+	f.Close()
+
+	j := &moveJournal{path: f.Name(), entry: journalEntry{Src: src, Dest: dest, Phase: journalIntent}}
+	if err := j.write(); err != nil {
+		fsguard.Remove(j.path, "删除写入失败的移动日志")
+		return nil, err
+	}
+	return j, nil
+}
+
+// write 原子写入日志内容并落盘
+func (j *moveJournal) write() error {
+	data, err := json.Marshal(j.entry)
+	if err != nil {
+		return err
+	}
+	tempPath := j.path + ".tmp"
+	f, err := fsguard.Create(tempPath, "记录移入历史目录的意图")
+	if err != nil {
+		return fmt.Errorf("写入移动日志失败: %v", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		fsguard.Remove(tempPath, "删除写入失败的临时日志")
+		return fmt.Errorf("写入移动日志失败: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		fsguard.Remove(tempPath, "删除写入失败的临时日志")
+		return fmt.Errorf("写入移动日志失败: %v", err)
+	}
+	f.Close()
+	if err := fsguard.Rename(tempPath, j.path, "记录移入历史目录的意图"); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("写入移动日志失败: %v", err)
+	}
+	return nil
+}
+
+// markCopied 记录目标已完整复制，之后才可以删除源路径
+func (j *moveJournal) markCopied() error {
+	j.entry.Phase = journalCopied
+	return j.write()
+}
+
+// done 移动完成，删除日志
+func (j *moveJournal) done() {
+	fsguard.Remove(j.path, "移动完成，删除移动日志")
+}
+
+// RepairResult 修复中断移动的结果
+type RepairResult struct {
+	Completed  int // 已完成的移动（删除残留的源路径）
+	RolledBack int // 已回滚的移动（删除不完整的目标）
+	Failed     int // 无法处理、保留日志待下次重试的移动
+}
+
+// RepairInterruptedMoves 根据移动日志处理上次运行中断的移入历史操作：
+// 目标已完整复制的，删除残留的源路径以完成移动；否则删除不完整的目标，保留源路径
+func RepairInterruptedMoves(backupRoot string, verbose bool) (*RepairResult, error) {
+	result := &RepairResult{}
+	dir := journalDir(backupRoot)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, fmt.Errorf("读取移动日志目录失败: %v", err)
+	}
+
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		// 写日志时中断留下的临时文件
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			fsguard.Remove(path, "删除已修复的移动日志")
+			continue
+		}
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+
+		j := &moveJournal{path: path}
+		data, err := os.ReadFile(path)
+		if err == nil && len(data) == 0 {
+			// 意图记录尚未写入就中断，没有执行任何移动
+			j.done()
+			continue
+		}
+		if err == nil {
+			err = json.Unmarshal(data, &j.entry)
+		}
+		if err != nil {
+			ui.Errorf("读取移动日志失败 %s: %v\n", path, err)
+			result.Failed++
+			continue
+		}
+
+		if err := repairMove(j, verbose); err != nil {
+			ui.Errorf("修复中断的移动失败 %s -> %s: %v\n", j.entry.Src, j.entry.Dest, err)
+			result.Failed++
+			continue
+		}
+		if j.entry.Phase == journalCopied {
+			result.Completed++
+		} else {
+			result.RolledBack++
+		}
+		j.done()
+	}
+
+	// 没有遗留日志时移除空的日志目录
+	fsguard.Remove(dir, "删除空的移动日志目录")
+	return result, nil
+}
+
+// repairMove 完成或回滚单次中断的移动
+func repairMove(j *moveJournal, verbose bool) error {
+	src, dest := j.entry.Src, j.entry.Dest
+	_, srcErr := os.Lstat(src)
+	srcExists := srcErr == nil
+
+	if j.entry.Phase == journalCopied {
+		// 目标已完整，补完源路径的删除
+		if !srcExists {
+			return nil
+		}
+		if _, err := os.Lstat(dest); err != nil {
+			return fmt.Errorf("日志记录已复制，但历史目标不存在: %v", err)
+		}
+		if verbose {
+			ui.Printf("完成中断的移动，删除残留的源路径: %s\n", src)
+		}
+		return fsguard.RemoveAll(src, "修复中断的移动：历史目录中已有完整副本，删除原位置的残留")
+	}
+
+	// 意图阶段：源路径完整；源已不存在说明重命名已成功
+	if !srcExists {
+		if _, err := os.Lstat(dest); err != nil {
+			return fmt.Errorf("源路径和历史目标都不存在")
+		}
+		return nil
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		if verbose {
+			ui.Printf("回滚中断的移动，删除不完整的历史目标: %s\n", dest)
+		}
+		return fsguard.RemoveAll(dest, "修复中断的移动：回滚未完成的复制")
+	}
+	return nil
+}
+
+// pendingMovePaths 返回仍有移动日志（修复失败、待下次重试）的源路径和历史目标
+func pendingMovePaths(backupRoot string) []string {
+	entries, err := os.ReadDir(journalDir(backupRoot))
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(journalDir(backupRoot), e.Name()))
+		if err != nil {
+			continue
+		}
+		var entry journalEntry
+		if json.Unmarshal(data, &entry) == nil {
+			paths = append(paths, entry.Src, entry.Dest)
+		}
+	}
+	return paths
+}
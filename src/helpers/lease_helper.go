@@ -0,0 +1,219 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+)
+
+// leaseTTL 租约有效期：运行中途崩溃的机器最多占用这么久，之后其他运行可接管
+const leaseTTL = 5 * time.Minute
+
+// leaseRenewInterval 运行期间续租的间隔
+const leaseRenewInterval = time.Minute
+
+// Lease 一台机器对共享备份根目录的租约（<共享根目录>/.copy-ignore-locks/<主机名>.json）
+type Lease struct {
+	Host      string    `json:"host"`
+	PID       int       `json:"pid"`
+	Subtree   string    `json:"subtree"` // 该机器写入的目录（相对共享根目录，"." 表示直接写入共享根目录）
+	StartedAt time.Time `json:"started_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RunID     string    `json:"run_id,omitempty"` // 运行标识，与该运行的临时文件名对应（见 TempPath）
+}
+
+// Active 判断租约在指定时间是否仍有效
+func (l *Lease) Active(now time.Time) bool {
+	return now.Before(l.ExpiresAt)
+}
+
+// Overlaps 判断两台机器写入的目录是否重叠（任一方直接写入共享根目录，或写入同一子目录）
+func (l *Lease) Overlaps(subtree string) bool {
+	return l.Subtree == "." || subtree == "." || l.Subtree == subtree
+}
+
+// LeaseHandle 已获取的租约，运行期间自动续租，结束时调用 Release 释放
+type LeaseHandle struct {
+	path  string
+	lease Lease
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// AcquireLease 在共享备份根目录下获取本机的租约
+// 本机已有未过期的租约（另一个运行正在进行）时返回错误；过期的租约（运行崩溃遗留）直接接管
+func AcquireLease(sharedRoot, host, subtree string) (*LeaseHandle, error) {
+	dir := filepath.Join(sharedRoot, config.LockDirName)
+	if err := fsguard.MkdirAll(dir, 0755, "创建租约目录"); err != nil {
+		return nil, fmt.Errorf("创建锁目录失败: %v", err)
+	}
+
+	now := time.Now()
+	h := &LeaseHandle{
+		path: filepath.Join(dir, host+".json"),
+		lease: Lease{
+			Host:      host,
+			PID:       os.Getpid(),
+			Subtree:   subtree,
+			StartedAt: now,
+			ExpiresAt: now.Add(leaseTTL),
+			RunID:     RunID(),
+		},
+		stop: make(chan struct{}),
+	}
+	data, err := json.MarshalIndent(h.lease, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	// 以独占方式创建租约文件，避免同一台机器的两个运行同时获得租约
+	for attempt := 0; ; attempt++ {
+		f, err := fsguard.OpenFile(h.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644, "获取租约")
+		if err == nil {
+			_, werr := f.Write(data)
+			f.Close()
+			if werr != nil {
+				fsguard.Remove(h.path, "删除写入失败的租约")
+				return nil, fmt.Errorf("写入租约失败: %v", werr)
+			}
+			break
+		}
+		if !os.IsExist(err) || attempt > 0 {
+			return nil, fmt.Errorf("获取租约失败: %v", err)
+		}
+		if existing, err := readLease(h.path); err == nil && existing.Active(now) {
+			return nil, fmt.Errorf("主机 %s 已有运行正在进行（进程 %d，开始于 %s，租约到期 %s）",
+				host, existing.PID, existing.StartedAt.Local().Format("2006-01-02 15:04:05"), existing.ExpiresAt.Local().Format("15:04:05"))
+		}
+		// 过期或损坏的租约：删除后重试一次
+		fsguard.Remove(h.path, "删除过期或损坏的租约")
+	}
+
+	h.wg.Add(1)
+	go h.renew()
+	return h, nil
+}
+
+// renew 定期延长租约到期时间
+func (h *LeaseHandle) renew() {
+	defer h.wg.Done()
+	ticker := time.NewTicker(leaseRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case now := <-ticker.C:
+			h.lease.ExpiresAt = now.Add(leaseTTL)
+			data, err := json.MarshalIndent(h.lease, "", "  ")
+			if err != nil {
+				continue
+			}
+			tmp := h.path + ".tmp"
+			if err := fsguard.WriteFile(tmp, data, 0644, "续租：写入临时文件"); err == nil {
+				if err := fsguard.Rename(tmp, h.path, "续租"); err != nil {
+					fsguard.Remove(tmp, "删除续租失败的临时文件")
+				}
+			}
+		}
+	}
+}
+
+// OtherActiveLeases 返回其他机器仍有效、且写入目录与本机重叠的租约
+func (h *LeaseHandle) OtherActiveLeases(sharedRoot string) []Lease {
+	leases, err := ReadLeases(sharedRoot)
+	if err != nil {
+		return nil
+	}
+	now := time.Now()
+	var others []Lease
+	for _, lease := range leases {
+		if lease.Host != h.lease.Host && lease.Active(now) && lease.Overlaps(h.lease.Subtree) {
+			others = append(others, lease)
+		}
+	}
+	return others
+}
+
+// Release 停止续租并删除租约文件
+func (h *LeaseHandle) Release() {
+	if h == nil {
+		return
+	}
+	close(h.stop)
+	h.wg.Wait()
+	fsguard.Remove(h.path, "释放租约")
+}
+
+// ReadLeases 读取共享备份根目录下所有机器的租约（包括已过期的），按主机名排序
+func ReadLeases(sharedRoot string) ([]Lease, error) {
+	dir := filepath.Join(sharedRoot, config.LockDirName)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取锁目录失败: %v", err)
+	}
+	var leases []Lease
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		lease, err := readLease(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		leases = append(leases, *lease)
+	}
+	sort.Slice(leases, func(i, j int) bool { return leases[i].Host < leases[j].Host })
+	return leases, nil
+}
+
+// ActiveRunIDs 返回共享备份根目录下仍有效的租约所属运行的标识
+func ActiveRunIDs(sharedRoot string) map[string]bool {
+	ids := make(map[string]bool)
+	leases, _ := ReadLeases(sharedRoot)
+	now := time.Now()
+	for _, lease := range leases {
+		if lease.RunID != "" && lease.Active(now) {
+			ids[lease.RunID] = true
+		}
+	}
+	return ids
+}
+
+// readLease 读取单个租约文件
+func readLease(path string) (*Lease, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lease Lease
+	if err := json.Unmarshal(data, &lease); err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
+
+// IsHostSubtree 判断目录是否为某台机器的备份子树（按主机分隔时，子树根目录带有主机标记文件）
+func IsHostSubtree(dir string) bool {
+	_, err := os.Lstat(filepath.Join(dir, config.HostMarkerFileName))
+	return err == nil
+}
+
+// WriteHostMarker 在本机的备份子树根目录写入主机标记
+func WriteHostMarker(dir, host string) error {
+	if err := fsguard.MkdirAll(dir, 0755, "创建主机子目录"); err != nil {
+		return err
+	}
+	return fsguard.WriteFile(filepath.Join(dir, config.HostMarkerFileName), []byte(host+"\n"), 0644, "写入主机标记")
+}
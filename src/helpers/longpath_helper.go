@@ -0,0 +1,84 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+)
+
+// maxNameBytes 单个文件名的长度上限（ext4、exFAT 等常见文件系统均为 255）
+const maxNameBytes = 255
+
+// DefaultMaxPathLen 当前操作系统下备份目标完整路径的长度上限（字节）
+// Windows 上 Go 会自动使用长路径前缀，按 NTFS/exFAT 的上限计算
+func DefaultMaxPathLen() int {
+	switch runtime.GOOS {
+	case "windows":
+		return 32000
+	case "darwin", "ios":
+		return 1023
+	default:
+		return 4095
+	}
+}
+
+// DestPathTooLong 判断备份目标路径（含复制时的临时文件后缀，见 TempPath）是否超过文件系统限制：
+// 任一级文件名超过 255 字节，或完整路径超过 maxPathLen（0 表示按操作系统默认）
+func DestPathTooLong(path string, maxPathLen int) bool {
+	if maxPathLen <= 0 {
+		maxPathLen = DefaultMaxPathLen()
+	}
+	if len(path)+TempSuffixMaxLen > maxPathLen {
+		return true
+	}
+	for _, name := range strings.Split(filepath.ToSlash(path), "/") {
+		if len(name)+TempSuffixMaxLen > maxNameBytes {
+			return true
+		}
+	}
+	return false
+}
+
+// LongPathTarget 返回路径过长的文件在备份目标中的替代位置：
+// <备份根目录>/.copy-ignore-long/<哈希前两位>/<相对路径的 SHA-256><扩展名>
+func LongPathTarget(backupRoot, rel string) string {
+	sum := sha256.Sum256([]byte(filepath.ToSlash(rel)))
+	name := hex.EncodeToString(sum[:])
+	if ext := filepath.Ext(rel); len(ext) <= 16 {
+		name += ext
+	}
+	return filepath.Join(backupRoot, config.LongPathDirName, name[:2], name)
+}
+
+// IsLongPathRecord 判断文件名是否为替代位置旁记录原始路径的文件
+func IsLongPathRecord(name string) bool {
+	return strings.HasSuffix(name, config.LongPathRecordSuffix)
+}
+
+// WriteLongPathRecord 在替代位置旁记录文件的原始相对路径，内容未变化时不重写
+func WriteLongPathRecord(dest, rel string) error {
+	record := dest + config.LongPathRecordSuffix
+	content := filepath.ToSlash(rel) + "\n"
+	if data, err := os.ReadFile(record); err == nil && string(data) == content {
+		return nil
+	}
+	if err := fsguard.MkdirAll(filepath.Dir(record), 0755, "记录路径过长文件的原始路径"); err != nil {
+		return err
+	}
+	return fsguard.WriteFile(record, []byte(content), 0644, "记录路径过长文件的原始路径")
+}
+
+// ReadLongPathRecord 读取替代位置上文件的原始相对路径（正斜杠形式）
+func ReadLongPathRecord(dest string) (string, error) {
+	data, err := os.ReadFile(dest + config.LongPathRecordSuffix)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
@@ -0,0 +1,118 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// PreserveBeforeOverwrite 按 --overwrite 策略处理即将被覆盖的目标文件（或整体复制的目录）
+// 返回旧版本保存的位置；策略为 none 或没有可用的备份目录时旧版本不保留，返回空字符串
+func PreserveBeforeOverwrite(destPath string) (string, error) {
+	cfg := config.GetGlobalConfig()
+	switch cfg.Overwrite {
+	case config.OverwriteNone:
+		return "", nil
+	case config.OverwriteSuffixRename:
+		return renameOldVersion(destPath)
+	default:
+		return backupBeforeOverwrite(destPath)
+	}
+}
+
+// IsOverwriteVersion 判断备份目标中的文件名是否为 --overwrite suffix-rename 保留的旧版本
+func IsOverwriteVersion(name string) bool {
+	return strings.HasSuffix(name, config.OverwriteVersionSuffix)
+}
+
+// OverwriteVersionBase 返回旧版本对应的目标文件路径（去掉 .<时间戳>.copy-ignore-old），不是旧版本时返回空字符串
+func OverwriteVersionBase(path string) string {
+	if !IsOverwriteVersion(path) {
+		return ""
+	}
+	trimmed := strings.TrimSuffix(path, config.OverwriteVersionSuffix)
+	dot := strings.LastIndex(trimmed, ".")
+	if dot <= 0 {
+		return ""
+	}
+	return trimmed[:dot]
+}
+
+// renameOldVersion 在原位置将目标文件重命名为 <文件名>.<时间戳>.copy-ignore-old，并轮换超出 --backup-keep 的旧版本
+func renameOldVersion(destPath string) (string, error) {
+	cfg := config.GetGlobalConfig()
+	target := destPath + "." + cfg.Timestamp + config.OverwriteVersionSuffix
+	if cfg.Verbose {
+		ui.Printf("重命名将被覆盖的文件: %s -> %s\n", destPath, target)
+	}
+	files, size := pathUsage(destPath)
+	if err := fsguard.Rename(destPath, target, "覆盖前在原位置保留旧版本"); err != nil {
+		return "", fmt.Errorf("重命名旧版本失败: %w", err)
+	}
+	recordHistoryMove(files, size)
+	if err := pruneOldVersions(destPath, cfg.BackupKeep); err != nil {
+		return target, fmt.Errorf("轮换旧版本失败: %w", err)
+	}
+	return target, nil
+}
+
+// pruneOldVersions 只保留 destPath 最新的 keep 个旧版本（按文件名中的时间戳排序）
+func pruneOldVersions(destPath string, keep int) error {
+	cfg := config.GetGlobalConfig()
+	// 其他机器正在写入重叠的目录时不轮换，与历史目录的轮换一致
+	if cfg.SharedInUse {
+		return nil
+	}
+	entries, err := os.ReadDir(filepath.Dir(destPath))
+	if err != nil {
+		return err
+	}
+
+	layout := ResolveTimestampFormat(cfg.TimestampFormat)
+	loc, err := LoadTimestampZone(cfg.TimestampZone)
+	if err != nil {
+		loc = time.Local
+	}
+	prefix := filepath.Base(destPath) + "."
+	var versions []timestampedDir
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || !IsOverwriteVersion(name) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), config.OverwriteVersionSuffix)
+		if t, ok := ParseTimestampDir(stamp, layout, loc); ok {
+			versions = append(versions, timestampedDir{name: name, time: t})
+		}
+	}
+	if len(versions) <= keep {
+		return nil
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].time.After(versions[j].time)
+	})
+	for _, v := range versions[keep:] {
+		old := filepath.Join(filepath.Dir(destPath), v.name)
+		if !PruneConfirmed(cfg) {
+			recordPrunePlan(destPath, old)
+			continue
+		}
+		if cfg.Verbose {
+			ui.Printf("删除旧版本: %s\n", old)
+		}
+		files, size := pathUsage(old)
+		if err := fsguard.RemoveAll(old, "轮换旧版本：删除超出保留数量的旧版本"); err != nil {
+			return fmt.Errorf("删除旧版本失败 %s: %v", old, err)
+		}
+		recordHistoryPrune(files, size)
+	}
+	return nil
+}
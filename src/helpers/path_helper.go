@@ -0,0 +1,38 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ResolvePath 返回路径的绝对、真实路径：解析符号链接（Windows 上包括目录联接），
+// 路径尚不存在时解析最深的已存在祖先目录，再拼接其余部分
+func ResolvePath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = filepath.Clean(path)
+	}
+	rest := ""
+	for p := abs; ; p = filepath.Dir(p) {
+		if resolved, err := filepath.EvalSymlinks(p); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		if _, err := os.Lstat(p); err == nil || filepath.Dir(p) == p {
+			return abs
+		}
+		rest = filepath.Join(filepath.Base(p), rest)
+	}
+}
+
+// IsWithin 判断 path 是否等于 root 或位于 root 之下（两者应已是绝对路径，Windows 上不区分大小写）
+func IsWithin(path, root string) bool {
+	if runtime.GOOS == "windows" {
+		path, root = strings.ToLower(path), strings.ToLower(root)
+	}
+	if path == root {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
@@ -0,0 +1,81 @@
+package helpers
+
+import (
+	"io"
+	"sync"
+)
+
+// PauseGate 运行时暂停/继续的开关，多个复制协程共享；暂停期间正在复制的文件在下一次读取时阻塞
+type PauseGate struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	paused bool
+}
+
+// NewPauseGate 创建处于运行状态的开关
+func NewPauseGate() *PauseGate {
+	g := &PauseGate{}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// Pause 暂停，返回状态是否发生变化
+func (g *PauseGate) Pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		return false
+	}
+	g.paused = true
+	return true
+}
+
+// Resume 继续，唤醒所有等待的协程，返回状态是否发生变化
+func (g *PauseGate) Resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		return false
+	}
+	g.paused = false
+	g.cond.Broadcast()
+	return true
+}
+
+// Paused 返回当前是否处于暂停状态
+func (g *PauseGate) Paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// Wait 暂停期间阻塞，直到继续；开关为 nil 时直接返回
+func (g *PauseGate) Wait() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	for g.paused {
+		g.cond.Wait()
+	}
+	g.mu.Unlock()
+}
+
+// Reader 返回每次读取前检查暂停状态的 Reader，开关为 nil 时原样返回
+func (g *PauseGate) Reader(r io.Reader) io.Reader {
+	if g == nil {
+		return r
+	}
+	return &pausableReader{r: r, gate: g}
+}
+
+// pausableReader 每次读取前等待暂停结束
+type pausableReader struct {
+	r    io.Reader
+	gate *PauseGate
+}
+
+func (pr *pausableReader) Read(p []byte) (int, error) {
+	pr.gate.Wait()
+	return pr.r.Read(p)
+}
@@ -0,0 +1,20 @@
+//go:build darwin
+
+package helpers
+
+import (
+	"os"
+	"syscall"
+)
+
+// sfDataless iCloud 已移除本地副本（“优化 Mac 储存空间”）的文件标志（见 sys/stat.h SF_DATALESS）
+const sfDataless = 0x40000000
+
+// fileAttributes 返回文件标志，以及文件内容是否只在云端（读取会触发下载）
+func fileAttributes(info os.FileInfo) (uint32, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return st.Flags, st.Flags&sfDataless != 0
+}
@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+)
+
+// PlaceholderInfo 云端占位文件（只在云端、本地未下载）的元数据，--placeholders metadata 时写入备份目标
+type PlaceholderInfo struct {
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mtime"`
+	Attributes uint32    `json:"attributes"` // Windows 文件属性或 macOS 文件标志
+}
+
+// IsCloudPlaceholder 判断文件是否为 OneDrive、iCloud 等的云端占位文件：
+// 读取其内容会触发下载（hydration），批量复制会把整个云盘下载到本地
+func IsCloudPlaceholder(info os.FileInfo) bool {
+	_, placeholder := fileAttributes(info)
+	return placeholder
+}
+
+// IsPlaceholderStub 判断备份目标中的文件名是否为占位文件的元数据记录
+func IsPlaceholderStub(name string) bool {
+	return strings.HasSuffix(name, config.PlaceholderStubSuffix)
+}
+
+// WritePlaceholderStub 在备份目标 dest 旁写入占位文件的元数据记录（修改时间与源文件一致），内容未变化时不重写
+func WritePlaceholderStub(dest string, info os.FileInfo) error {
+	attrs, _ := fileAttributes(info)
+	data, err := json.MarshalIndent(PlaceholderInfo{Size: info.Size(), ModTime: info.ModTime(), Attributes: attrs}, "", "  ")
+	if err != nil {
+		return err
+	}
+	stub := dest + config.PlaceholderStubSuffix
+	if old, err := os.ReadFile(stub); err == nil && bytes.Equal(old, data) {
+		return nil
+	}
+	if err := fsguard.MkdirAll(filepath.Dir(stub), 0755, "记录云端占位文件的元数据"); err != nil {
+		return err
+	}
+	if err := fsguard.WriteFile(stub, data, 0644, "记录云端占位文件的元数据"); err != nil {
+		return err
+	}
+	return fsguard.Chtimes(stub, info.ModTime(), info.ModTime(), "记录云端占位文件的元数据：同步修改时间")
+}
+
+// ReadPlaceholderStub 读取 dest 旁的占位文件元数据记录
+func ReadPlaceholderStub(dest string) (*PlaceholderInfo, error) {
+	data, err := os.ReadFile(dest + config.PlaceholderStubSuffix)
+	if err != nil {
+		return nil, err
+	}
+	var info PlaceholderInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
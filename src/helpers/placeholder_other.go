@@ -0,0 +1,10 @@
+//go:build !windows && !darwin
+
+package helpers
+
+import "os"
+
+// fileAttributes 其他系统上没有云端占位文件
+func fileAttributes(info os.FileInfo) (uint32, bool) {
+	return 0, false
+}
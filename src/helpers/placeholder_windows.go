@@ -0,0 +1,25 @@
+//go:build windows
+
+package helpers
+
+import (
+	"os"
+	"syscall"
+)
+
+// 云端占位文件的属性位（见 Windows SDK winnt.h）
+const (
+	fileAttributeOffline            = 0x00001000
+	fileAttributeRecallOnOpen       = 0x00040000
+	fileAttributeRecallOnDataAccess = 0x00400000
+)
+
+// fileAttributes 返回文件属性，以及文件内容是否只在云端（读取会触发下载）
+func fileAttributes(info os.FileInfo) (uint32, bool) {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return 0, false
+	}
+	attrs := data.FileAttributes
+	return attrs, attrs&(fileAttributeOffline|fileAttributeRecallOnOpen|fileAttributeRecallOnDataAccess) != 0
+}
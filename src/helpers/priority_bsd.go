@@ -0,0 +1,16 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+
+package helpers
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// EnterBackgroundMode 降低进程的 CPU 优先级（nice 19）；这些系统没有可直接调用的 IO 优先级接口，IO 优先级不变
+func EnterBackgroundMode() error {
+	if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, 19); err != nil {
+		return fmt.Errorf("降低 CPU 优先级失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+//go:build linux
+
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// Linux 的 IO 优先级（ioprio_set）常量
+const (
+	ioprioWhoProcess = 1
+	ioprioClassIdle  = 3
+	ioprioClassShift = 13
+)
+
+// EnterBackgroundMode 降低进程的 CPU 优先级（nice 19）并使用空闲 IO 调度类（相当于 ionice -c 3）
+// Linux 上优先级按线程生效，因此逐个设置当前所有线程，之后创建的线程继承创建者的优先级
+func EnterBackgroundMode() error {
+	tids := []int{0}
+	if entries, err := os.ReadDir("/proc/self/task"); err == nil {
+		tids = tids[:0]
+		for _, entry := range entries {
+			if tid, err := strconv.Atoi(entry.Name()); err == nil {
+				tids = append(tids, tid)
+			}
+		}
+	}
+
+	for _, tid := range tids {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, 19); err != nil {
+			return fmt.Errorf("降低 CPU 优先级失败: %v", err)
+		}
+		ioprio := ioprioClassIdle << ioprioClassShift
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(ioprio)); errno != 0 {
+			return fmt.Errorf("设置 IO 优先级失败: %v", errno)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !windows && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package helpers
+
+import "fmt"
+
+// EnterBackgroundMode 当前系统不支持调整进程优先级
+func EnterBackgroundMode() error {
+	return fmt.Errorf("当前系统不支持后台模式")
+}
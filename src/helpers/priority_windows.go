@@ -0,0 +1,25 @@
+//go:build windows
+
+package helpers
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// processModeBackgroundBegin SetPriorityClass 的后台模式：同时降低 CPU、IO 和内存优先级
+const processModeBackgroundBegin = 0x00100000
+
+var procSetPriorityClass = syscall.NewLazyDLL("kernel32.dll").NewProc("SetPriorityClass")
+
+// EnterBackgroundMode 将进程切换到后台模式（PROCESS_MODE_BACKGROUND_BEGIN）
+func EnterBackgroundMode() error {
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return fmt.Errorf("获取当前进程失败: %v", err)
+	}
+	if ret, _, err := procSetPriorityClass.Call(uintptr(process), processModeBackgroundBegin); ret == 0 {
+		return fmt.Errorf("切换到后台模式失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/aogg/copy-ignore/src/fsguard"
+)
+
+// sanitizeBase Windows/exFAT 不允许的字符映射到 Unicode 私用区 U+F000 + 原字符（与 Cygwin 的做法相同），
+// 映射一一对应，可以原样还原
+const sanitizeBase = 0xF000
+
+// windowsReservedNames Windows 保留的设备名（不区分大小写，带扩展名同样保留）
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizePath 转义相对路径中 Windows/exFAT 不兼容的部分，使 Linux、macOS 上的文件名能写入这类目标：
+// 字符 <>:"\|?* 和控制字符、文件名末尾的点和空格、保留设备名（CON、NUL、COM1 等）的最后一个字符
+// 转义后的字符位于 Unicode 私用区，UnsanitizePath 可还原
+func SanitizePath(rel string) string {
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, part := range parts {
+		parts[i] = sanitizeName(part)
+	}
+	return strings.Join(parts, string(filepath.Separator))
+}
+
+// sanitizeName 转义单个文件名
+func sanitizeName(name string) string {
+	if name == "" || name == "." || name == ".." {
+		return name
+	}
+	runes := []rune(name)
+	for i, r := range runes {
+		if r < 0x20 || strings.ContainsRune(`<>:"\|?*`, r) {
+			runes[i] = sanitizeBase + r
+		}
+	}
+	// Windows 会去掉文件名末尾的点和空格
+	for i := len(runes) - 1; i >= 0 && (runes[i] == '.' || runes[i] == ' '); i-- {
+		runes[i] = sanitizeBase + runes[i]
+	}
+	// 保留设备名：转义主文件名的最后一个字符
+	stem := len(runes)
+	for i, r := range runes {
+		if r == '.' {
+			stem = i
+			break
+		}
+	}
+	if stem > 0 && windowsReservedNames[strings.ToUpper(string(runes[:stem]))] {
+		runes[stem-1] = sanitizeBase + runes[stem-1]
+	}
+	return string(runes)
+}
+
+// UnsanitizePath 还原 SanitizePath 转义的路径
+func UnsanitizePath(rel string) string {
+	if !IsSanitizedPath(rel) {
+		return rel
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= sanitizeBase && r < sanitizeBase+0x80 {
+			return r - sanitizeBase
+		}
+		return r
+	}, rel)
+}
+
+// IsSanitizedPath 判断路径中是否含有 SanitizePath 转义过的字符
+func IsSanitizedPath(rel string) bool {
+	return strings.IndexFunc(rel, func(r rune) bool {
+		return r >= sanitizeBase && r < sanitizeBase+0x80
+	}) >= 0
+}
+
+// DestNeedsSanitizing 判断备份目标是否需要转义文件名：Windows 上总是需要；
+// 其他系统在目标中尝试创建含 ":" 的探测文件（exFAT、FAT、SMB 共享等会拒绝），probe 为 false 时不探测
+func DestNeedsSanitizing(root string, probe bool) bool {
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	if !probe {
+		return false
+	}
+	base := filepath.Join(root, fmt.Sprintf(".copy-ignore-probe-%d", os.Getpid()))
+	// 先确认普通文件名可以创建，避免把无写权限误判为不支持特殊字符
+	f, err := fsguard.OpenFile(base, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644, "探测目标是否接受普通文件名")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	fsguard.Remove(base, "删除探测文件")
+
+	f, err = fsguard.OpenFile(base+`:?`, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644, "探测目标是否接受含 : ? 的文件名")
+	if err != nil {
+		return true
+	}
+	f.Close()
+	fsguard.Remove(base+`:?`, "删除探测文件")
+	return false
+}
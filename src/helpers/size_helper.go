@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ParseSize 解析带单位的字节数，如 512、500K、64M、1.5G（1K = 1024 字节）
+func ParseSize(text string) (int64, error) {
+	text = strings.ToUpper(strings.TrimSpace(text))
+	if text == "" {
+		return 0, nil
+	}
+
+	text = strings.TrimSuffix(text, "B")
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(text, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(text, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(text, "G"):
+		multiplier = 1 << 30
+	case strings.HasSuffix(text, "T"):
+		multiplier = 1 << 40
+	}
+	if multiplier > 1 {
+		text = text[:len(text)-1]
+	}
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("大小格式错误: %s", text)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// FormatSize 将字节数格式化为便于阅读的字符串
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// DirSize 统计目录下所有普通文件的总大小（读取失败的子路径忽略）
+func DirSize(root string) int64 {
+	var total int64
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
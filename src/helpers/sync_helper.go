@@ -0,0 +1,79 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/chunkstore"
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+)
+
+// PullBackToSource 双向同步：将备份目标中较新的文件取回到源位置
+// 源文件已存在时，先把旧版本复制到历史目录；取回后源文件的修改时间与备份一致，下次运行不会再复制
+func PullBackToSource(backupPath, srcPath string) error {
+	cfg := config.GetGlobalConfig()
+
+	backupInfo, err := os.Stat(backupPath)
+	if err != nil {
+		return fmt.Errorf("获取备份文件信息失败: %v", err)
+	}
+	if !backupInfo.Mode().IsRegular() {
+		return fmt.Errorf("只支持同步普通文件: %s", backupPath)
+	}
+
+	// 保留被取回覆盖的源文件旧版本
+	if _, err := os.Stat(srcPath); err == nil {
+		relPath, err := filepath.Rel(cfg.BackupRoot, backupPath)
+		if err != nil {
+			return fmt.Errorf("计算相对路径失败: %v", err)
+		}
+		historyPath := filepath.Join(cfg.HandleHistoryDir(cfg.BackupRoot), relPath)
+		if err := CopyFileAtomic(srcPath, historyPath); err != nil {
+			return fmt.Errorf("备份源文件旧版本失败: %v", err)
+		}
+	}
+
+	return RestoreFile(cfg.BackupRoot, backupPath, srcPath)
+}
+
+// RestoreFile 将备份文件原子地写入 target（分块存储的文件按 backupRoot 块池中的配方还原内容），修改时间与备份一致
+func RestoreFile(backupRoot, backupPath, target string) error {
+	backupInfo, err := os.Stat(backupPath)
+	if err != nil {
+		return fmt.Errorf("获取备份文件信息失败: %v", err)
+	}
+
+	// 分块存储的文件需要按配方还原内容
+	recipe, err := chunkstore.ReadRecipe(backupPath)
+	if err != nil {
+		return err
+	}
+	if recipe == nil {
+		return CopyFileAtomic(backupPath, target)
+	}
+
+	if err := ensureDir(filepath.Dir(target)); err != nil {
+		return fmt.Errorf("创建目录失败: %v", err)
+	}
+	tempPath := TempPath(target)
+	f, err := fsguard.Create(tempPath, "从备份还原文件：写入临时文件")
+	if err != nil {
+		return err
+	}
+	err = chunkstore.Open(backupRoot).Restore(recipe, f)
+	if err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err == nil {
+		err = fsguard.Rename(tempPath, target, "从备份还原文件")
+	}
+	if err != nil {
+		fsguard.Remove(tempPath, "删除还原失败的临时文件")
+		return fmt.Errorf("还原分块文件失败: %v", err)
+	}
+	return fsguard.Chtimes(target, time.Now(), backupInfo.ModTime(), "从备份还原文件：同步修改时间")
+}
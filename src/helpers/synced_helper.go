@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+)
+
+// loadSyncedRecord 读取所有主机的双向同步文件记录：主机名 -> 备份目标下的相对路径（正斜杠分隔）
+func loadSyncedRecord(backupRoot string) (map[string][]string, error) {
+	record := make(map[string][]string)
+	data, err := os.ReadFile(filepath.Join(backupRoot, config.SyncedSourcesFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return record, nil
+		}
+		return nil, fmt.Errorf("读取同步文件记录失败: %v", err)
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("解析同步文件记录失败: %v", err)
+	}
+	return record, nil
+}
+
+// LoadSyncedSources 读取本机上次运行结束时源位置存在的双向同步文件（备份目标下的相对路径，正斜杠分隔）
+// 其中的文件源位置缺失时是本机删除的，其余的是本机从未有过（在其他机器上新增）的
+func LoadSyncedSources(backupRoot, host string) (map[string]bool, error) {
+	record, err := loadSyncedRecord(backupRoot)
+	if err != nil {
+		return nil, err
+	}
+	synced := make(map[string]bool)
+	for _, rel := range record[host] {
+		synced[rel] = true
+	}
+	return synced, nil
+}
+
+// SaveSyncedSources 记录本机本次运行结束时源位置存在的双向同步文件
+// scopes 为本次扫描到的仓库在备份目标下的目录，之外的记录（本次没有扫描的仓库）保持不变；为 nil 时整体替换
+func SaveSyncedSources(backupRoot, host string, present map[string]bool, scopes []string) error {
+	record, err := loadSyncedRecord(backupRoot)
+	if err != nil {
+		return err
+	}
+	var rels []string
+	if scopes != nil {
+		for _, rel := range record[host] {
+			if !withinAny(filepath.Join(backupRoot, filepath.FromSlash(rel)), scopes) {
+				rels = append(rels, rel)
+			}
+		}
+	}
+	for rel := range present {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	record[host] = rels
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fsguard.WriteFile(filepath.Join(backupRoot, config.SyncedSourcesFileName), data, 0644, "记录本机源位置存在的双向同步文件"); err != nil {
+		return fmt.Errorf("写入同步文件记录失败: %v", err)
+	}
+	return nil
+}
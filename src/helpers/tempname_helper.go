@@ -0,0 +1,29 @@
+package helpers
+
+import "github.com/aogg/copy-ignore/src/tempname"
+
+// 复制时的临时文件名：<目标文件名>.ci-<运行标识>-<序号>.tmp，格式定义在 tempname 包中（块池等同样使用）
+
+// TempSuffixMaxLen 临时文件名比目标文件名最多多出的字节数，用于判断路径是否过长
+const TempSuffixMaxLen = tempname.SuffixMaxLen
+
+// RunID 返回本次运行的标识（写入租约和临时文件名）
+func RunID() string {
+	return tempname.RunID()
+}
+
+// TempPath 返回写入 target 时使用的临时文件路径，每次调用都不相同
+func TempPath(target string) string {
+	return tempname.Path(target)
+}
+
+// ParseTempPath 解析临时文件路径，返回目标路径和所属运行的标识
+// 旧版本使用的 <目标文件名>.tmp 也视为临时文件，运行标识为空
+func ParseTempPath(path string) (target, owner string, ok bool) {
+	return tempname.Parse(path)
+}
+
+// IsRunTempName 判断文件名是否为带运行标识的临时文件名（不含旧版本的 .tmp）
+func IsRunTempName(name string) bool {
+	return tempname.IsRunName(name)
+}
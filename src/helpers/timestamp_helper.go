@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultTimestampFormat 历史目录名默认使用的时间戳格式（YYYYMMDD-HHMMSS）
+const DefaultTimestampFormat = "20060102-150405"
+
+// timestampPresets 预置的时间戳格式名称
+// RFC3339 中的冒号在 Windows 文件名中非法，因此用短横线代替
+var timestampPresets = map[string]string{
+	"default": DefaultTimestampFormat,
+	"rfc3339": "2006-01-02T15-04-05Z0700",
+	"iso":     "2006-01-02_15-04-05",
+}
+
+// ResolveTimestampFormat 将预置名称（default、rfc3339、iso）转换为 Go 时间格式，其他值视为 Go 时间格式原样返回
+func ResolveTimestampFormat(format string) string {
+	if format == "" {
+		return DefaultTimestampFormat
+	}
+	if layout, ok := timestampPresets[strings.ToLower(format)]; ok {
+		return layout
+	}
+	return format
+}
+
+// LoadTimestampZone 解析时区名称：local（默认）、UTC 或 IANA 时区名（如 Asia/Shanghai）
+func LoadTimestampZone(zone string) (*time.Location, error) {
+	if zone == "" || strings.EqualFold(zone, "local") {
+		return time.Local, nil
+	}
+	if strings.EqualFold(zone, "utc") {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("未知的时区: %s", zone)
+	}
+	return loc, nil
+}
+
+// ValidateTimestampFormat 检查时间戳格式可用作目录名，且生成的名称能被解析回来（用于识别和轮换历史目录）
+func ValidateTimestampFormat(layout string, loc *time.Location) error {
+	sample := time.Date(2001, 2, 3, 16, 7, 8, 0, loc)
+	name := sample.Format(layout)
+	if name == layout {
+		return fmt.Errorf("时间戳格式不包含任何时间字段: %s", layout)
+	}
+	if strings.ContainsAny(name, `/\:*?"<>|`) {
+		return fmt.Errorf("时间戳格式生成的目录名包含非法字符: %s", name)
+	}
+	parsed, err := time.ParseInLocation(layout, name, loc)
+	if err != nil || !parsed.Equal(sample) {
+		return fmt.Errorf("时间戳格式无法精确解析回时间（需包含年月日时分秒）: %s", layout)
+	}
+	return nil
+}
+
+// ParseTimestampDir 按指定格式解析历史目录名；同时兼容默认格式，便于切换格式后仍能识别旧目录
+func ParseTimestampDir(name, layout string, loc *time.Location) (time.Time, bool) {
+	if t, err := time.ParseInLocation(layout, name, loc); err == nil {
+		return t, true
+	}
+	if layout != DefaultTimestampFormat {
+		if t, err := time.ParseInLocation(DefaultTimestampFormat, name, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// FormatTimestamp 按配置的格式和时区生成历史目录名
+func FormatTimestamp(t time.Time, format, zone string) string {
+	loc, err := LoadTimestampZone(zone)
+	if err != nil {
+		loc = time.Local
+	}
+	return t.In(loc).Format(ResolveTimestampFormat(format))
+}
@@ -0,0 +1,207 @@
+package helpers
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// TempCleanupResult 启动时处理遗留临时文件的结果
+type TempCleanupResult struct {
+	Found     int // 发现的遗留临时文件数
+	Removed   int // 内容不完整或已无用、已删除的
+	Finalized int // 内容已完整、补完重命名的
+	Kept      int // 无法确定来源而保留的
+	Foreign   int // 属于其他正在进行的运行、未处理的
+}
+
+// foreignTempGrace 其他运行的临时文件在这段时间内有修改时视为仍在写入（对方可能没有租约，如只追加模式）
+const foreignTempGrace = leaseTTL
+
+// CleanupOrphanedTempFiles 处理上次运行崩溃后遗留在备份目标中的临时文件（见 TempPath，应在修复中断的移动之后调用）：
+//   - 其他运行的临时文件：所属运行的租约仍有效（activeRuns），或最近仍有修改时，跳过不处理
+//   - 块池中的临时块：同样跳过其他运行的临时块，内容哈希与块名一致时补完重命名，否则删除
+//   - 备份根目录下工具记录文件（清单、运行摘要等）的临时文件 <文件名>.tmp：删除
+//   - 备份文件的临时文件（只认带运行标识的名称，旧版本的 <文件名>.tmp 按普通备份文件处理）：
+//     内容与源文件一致且目标文件不存在时补完重命名，否则删除；源文件和目标文件都不存在时无法确定来源，保留
+//
+// 历史子目录、移动日志、租约目录和其他机器的子树不处理；仍有移动日志的路径留给下次修复
+// sourceOf 根据备份目标下的相对路径返回源文件路径（无法确定时返回空字符串）
+func CleanupOrphanedTempFiles(backupRoot string, managedDirs []string, activeRuns map[string]bool, sourceOf func(rel string) string, verbose bool) (*TempCleanupResult, error) {
+	result := &TempCleanupResult{}
+	backupRoot = filepath.Clean(backupRoot)
+	pending := pendingMovePaths(backupRoot)
+	skipDirs := make(map[string]bool)
+	for _, dir := range managedDirs {
+		skipDirs[filepath.Clean(dir)] = true
+	}
+	chunkDir := filepath.Join(backupRoot, config.ChunkDirName)
+
+	report := func(action, path string) {
+		if verbose {
+			ui.Printf("遗留的临时文件%s: %s\n", action, path)
+		}
+	}
+
+	err := filepath.WalkDir(backupRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			if path == chunkDir {
+				cleanupChunkTemps(chunkDir, activeRuns, result, report)
+				return filepath.SkipDir
+			}
+			if path != backupRoot && (skipDirs[path] || IsHostSubtree(path)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || underAny(path, pending) {
+			return nil
+		}
+		target, owner, ok := ParseTempPath(path)
+		if !ok || owner == RunID() {
+			return nil
+		}
+		if owner != "" && (activeRuns[owner] || recentlyModified(d, foreignTempGrace)) {
+			result.Foreign++
+			report("属于其他正在进行的运行，跳过", path)
+			return nil
+		}
+
+		// 清单、运行摘要等记录文件写入时使用 <文件名>.tmp，只认备份根目录下这些文件的临时文件
+		if filepath.Dir(path) == backupRoot && config.IsManagedFile(filepath.Base(target)) {
+			result.Found++
+			if fsguard.Remove(path, "删除未写完的记录文件（清单、运行摘要等）的临时文件") == nil {
+				result.Removed++
+				report("已删除", path)
+			}
+			return nil
+		}
+		// 旧版本的 <目标文件名>.tmp 与名为 *.tmp 的普通备份文件无法区分，不当作临时文件删除或重命名；
+		// 源文件已不存在时由清理阶段照常移入历史目录
+		if owner == "" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(backupRoot, path)
+		if err != nil {
+			return nil
+		}
+		if src := sourceOf(rel); src != "" {
+			if _, err := os.Lstat(src); err == nil {
+				// 源文件本身就叫 *.tmp，是正常的备份
+				return nil
+			}
+		}
+
+		result.Found++
+		var src string
+		if targetRel, err := filepath.Rel(backupRoot, target); err == nil {
+			src = sourceOf(targetRel)
+		}
+		srcInfo, srcErr := os.Stat(src)
+		_, targetErr := os.Lstat(target)
+		switch {
+		case srcErr == nil && srcInfo.Mode().IsRegular() && os.IsNotExist(targetErr) && sameContent(path, src):
+			// 内容已完整写入，只差重命名
+			if err := fsguard.Rename(path, target, "完成中断的复制（临时文件与源文件一致）"); err != nil {
+				return fmt.Errorf("补完临时文件失败 %s: %v", path, err)
+			}
+			fsguard.Chtimes(target, time.Now(), srcInfo.ModTime(), "完成中断的复制：同步修改时间")
+			result.Finalized++
+			report("已补完", target)
+		case srcErr == nil || targetErr == nil:
+			if err := fsguard.Remove(path, "删除残留的临时文件"); err != nil {
+				return fmt.Errorf("删除临时文件失败 %s: %v", path, err)
+			}
+			result.Removed++
+			report("已删除", path)
+		default:
+			result.Kept++
+			report("无法确定来源，保留", path)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// cleanupChunkTemps 处理块池中的临时块：内容哈希与块名一致的补完，否则删除
+// 块池由所有运行共享，与其他临时文件一样跳过其他正在进行的运行的临时块；
+// 旧版本的 <块名>.tmp 不带运行标识，最近仍有修改时同样视为正在写入
+func cleanupChunkTemps(chunkDir string, activeRuns map[string]bool, result *TempCleanupResult, report func(action, path string)) {
+	filepath.WalkDir(chunkDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		target, owner, ok := ParseTempPath(path)
+		if !ok || owner == RunID() {
+			return nil
+		}
+		if activeRuns[owner] || recentlyModified(d, foreignTempGrace) {
+			result.Foreign++
+			report("属于其他正在进行的运行，跳过", path)
+			return nil
+		}
+		result.Found++
+		if _, err := os.Lstat(target); os.IsNotExist(err) {
+			if hash, err := HashFile(path); err == nil && hash == filepath.Base(target) && fsguard.Rename(path, target, "完成块池中中断的写入（内容与块名一致）") == nil {
+				result.Finalized++
+				report("已补完", target)
+				return nil
+			}
+		}
+		if fsguard.Remove(path, "删除残留的临时文件") == nil {
+			result.Removed++
+			report("已删除", path)
+		}
+		return nil
+	})
+}
+
+// recentlyModified 判断文件在最近一段时间内是否有修改
+func recentlyModified(d fs.DirEntry, within time.Duration) bool {
+	info, err := d.Info()
+	return err == nil && time.Since(info.ModTime()) < within
+}
+
+// underAny 判断路径是否位于任一给定路径之下（或相同）
+func underAny(path string, roots []string) bool {
+	for _, root := range roots {
+		if root != "" && IsWithin(path, root) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameContent 判断两个文件的内容是否完全相同
+func sameContent(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	if err != nil || ai.Size() != bi.Size() {
+		return false
+	}
+	ha, err := HashFile(a)
+	if err != nil {
+		return false
+	}
+	hb, err := HashFile(b)
+	return err == nil && ha == hb
+}
@@ -0,0 +1,110 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// SlowNetworkLatency 访问网络路径超过该时间时提示响应较慢
+const SlowNetworkLatency = 200 * time.Millisecond
+
+// isPathSep 判断字符是否为路径分隔符（UNC 路径和长路径前缀的正反斜杠两种写法都接受）
+func isPathSep(c byte) bool {
+	return c == '\\' || c == '/'
+}
+
+// StripLongPathPrefix 去掉 Windows 长路径前缀：\\?\C:\dir 变为 C:\dir，\\?\UNC\server\share\dir 变为 \\server\share\dir
+// 前缀由 Go 在访问文件时按需自动加上，路径中保留前缀会让 filepath 的卷名判断、相对路径计算和排除规则匹配都出错
+func StripLongPathPrefix(path string) string {
+	if len(path) < 4 || !isPathSep(path[0]) || !isPathSep(path[1]) || path[2] != '?' || !isPathSep(path[3]) {
+		return path
+	}
+	rest := path[4:]
+	switch {
+	case len(rest) >= 4 && strings.EqualFold(rest[:3], "UNC") && isPathSep(rest[3]):
+		return path[:2] + rest[4:]
+	case len(rest) >= 2 && rest[1] == ':' && (len(rest) == 2 || isPathSep(rest[2])):
+		return rest
+	}
+	// 卷 GUID 路径（\\?\Volume{...}\）等没有对应的普通形式，保持原样
+	return path
+}
+
+// SplitUNC 拆分 UNC 路径 \\server\share\rest（分隔符可为正反斜杠），不是 UNC 路径时 ok 为 false
+// 只给出服务器名（\\server）时 share 为空
+func SplitUNC(path string) (server, share, rest string, ok bool) {
+	path = StripLongPathPrefix(path)
+	if len(path) < 3 || !isPathSep(path[0]) || !isPathSep(path[1]) || isPathSep(path[2]) {
+		return "", "", "", false
+	}
+	parts := strings.FieldsFunc(path[2:], func(r rune) bool { return r == '\\' || r == '/' })
+	server = parts[0]
+	if len(parts) > 1 {
+		share = parts[1]
+	}
+	if len(parts) > 2 {
+		rest = strings.Join(parts[2:], string(filepath.Separator))
+	}
+	return server, share, rest, true
+}
+
+// IsUNCPath 判断是否为 UNC 网络路径（只在 Windows 上，其他系统上以 // 开头的只是普通的绝对路径）
+func IsUNCPath(path string) bool {
+	if runtime.GOOS != "windows" {
+		return false
+	}
+	_, _, _, ok := SplitUNC(path)
+	return ok
+}
+
+// NormalizeRoot 归一化命令行给出的根目录：去掉长路径前缀；
+// Windows 上只有共享名的 UNC 路径（\\server\share）补上末尾的分隔符，否则它不是带根的路径，filepath.Rel 无法计算其下文件的相对路径
+func NormalizeRoot(path string) string {
+	if path == "" {
+		return path
+	}
+	path = StripLongPathPrefix(path)
+	if runtime.GOOS != "windows" {
+		return path
+	}
+	path = filepath.Clean(path)
+	if server, share, rest, ok := SplitUNC(path); ok && share != "" && rest == "" {
+		return `\\` + server + `\` + share + `\`
+	}
+	return path
+}
+
+// ValidateUNCRoot 检查 UNC 路径包含服务器名和共享名（不是 UNC 路径时不检查）
+func ValidateUNCRoot(path string) error {
+	if !IsUNCPath(path) {
+		return nil
+	}
+	if server, share, _, _ := SplitUNC(path); share == "" {
+		return fmt.Errorf("网络路径 %s 缺少共享名（应为 \\\\%s\\<共享名>\\...）", path, server)
+	}
+	return nil
+}
+
+// ProbeNetworkRoot 访问网络路径所在的共享（打开共享根目录并读取一个目录项），返回耗时；无法访问时返回错误
+// 只检查共享本身，路径中尚不存在的目录（如待创建的备份根目录）不影响结果
+func ProbeNetworkRoot(path string) (time.Duration, error) {
+	if server, share, _, ok := SplitUNC(path); ok {
+		path = `\\` + server + `\` + share + `\`
+	}
+	start := time.Now()
+	dir, err := os.Open(path)
+	if err != nil {
+		return time.Since(start), err
+	}
+	defer dir.Close()
+	if _, err := dir.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+		return time.Since(start), err
+	}
+	return time.Since(start), nil
+}
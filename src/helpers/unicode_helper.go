@@ -0,0 +1,31 @@
+package helpers
+
+import (
+	"os"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizePath 将路径统一为 Unicode NFC 形式
+// macOS 上创建的文件名常为分解形式（NFD），Windows 和 Linux 上通常为组合形式（NFC）；
+// 在两者之间同步的仓库，同一个文件名可能以两种形式出现，备份目标路径统一按 NFC 生成，避免重复条目
+func NormalizePath(path string) string {
+	if norm.NFC.IsNormalString(path) {
+		return path
+	}
+	return norm.NFC.String(path)
+}
+
+// ExistingPathForm 返回路径实际存在的 Unicode 形式：原样不存在时尝试 NFD 形式，都不存在时原样返回
+// 用于由 NFC 形式的备份路径反推源文件路径（源文件系统不做规范化时，源文件名可能是 NFD 形式）
+func ExistingPathForm(path string) string {
+	if _, err := os.Lstat(path); err == nil {
+		return path
+	}
+	if nfd := norm.NFD.String(path); nfd != path {
+		if _, err := os.Lstat(nfd); err == nil {
+			return nfd
+		}
+	}
+	return path
+}
@@ -0,0 +1,145 @@
+//go:build linux
+
+package helpers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// watchMask 监视的事件：目录中条目的创建、删除、重命名、内容和属性变化，以及目录自身被删除或移动
+const watchMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY | syscall.IN_ATTRIB |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF |
+	syscall.IN_ONLYDIR | syscall.IN_DONT_FOLLOW
+
+// TreeWatcher 通过 inotify 监视目录树中的变化
+type TreeWatcher struct {
+	f      *os.File
+	fd     int
+	dirs   map[int32]string // 监视描述符 -> 目录
+	skip   func(dir string) bool
+	onLost func(reason error)
+	full   bool // 运行中已达到监视数上限（只报告一次）
+}
+
+// WatchTree 监视 roots 之下的所有目录（skip 返回 true 的目录及其子孙除外，不跟随符号链接）
+// 返回时监视已全部就绪；监视数超过 fs.inotify.max_user_watches 时返回错误
+func WatchTree(roots []string, skip func(dir string) bool) (*TreeWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("初始化 inotify 失败: %w", err)
+	}
+	// 非阻塞的描述符交给运行时的网络轮询器，关闭后阻塞中的读取立即返回
+	w := &TreeWatcher{f: os.NewFile(uintptr(fd), "inotify"), fd: fd, dirs: make(map[int32]string), skip: skip}
+	for _, root := range roots {
+		if err := w.add(root); err != nil {
+			w.f.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Run 读取事件直到 done 关闭：每有变化调用 onChange（参数为发生变化的路径），
+// 事件丢失（事件队列溢出、新目录超过监视数上限）时调用 onLost；新建或移入的目录自动加入监视。回调都在调用 Run 的协程中执行
+func (w *TreeWatcher) Run(onChange func(path string), onLost func(reason error), done <-chan struct{}) error {
+	w.onLost = onLost
+	go func() {
+		<-done
+		w.f.Close()
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.f.Read(buf)
+		if errors.Is(err, os.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取 inotify 事件失败: %w", err)
+		}
+		// inotify_event：wd、mask、cookie、len 各 4 字节，之后是 len 字节以 NUL 填充的文件名
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			wd := int32(binary.NativeEndian.Uint32(buf[off:]))
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			name := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+nameLen]
+			off += syscall.SizeofInotifyEvent + nameLen
+
+			if mask&syscall.IN_Q_OVERFLOW != 0 {
+				onLost(errors.New("inotify 事件队列溢出"))
+				continue
+			}
+			dir, ok := w.dirs[wd]
+			if !ok {
+				continue
+			}
+			if mask&syscall.IN_IGNORED != 0 {
+				delete(w.dirs, wd)
+				continue
+			}
+			path := dir
+			if l := cstringLen(name); l > 0 {
+				path = filepath.Join(dir, string(name[:l]))
+			}
+			// 新目录先加入监视再报告（刚建立就被删除时不影响结果）
+			if mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				if err := w.add(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					onLost(err)
+				}
+			}
+			onChange(path)
+		}
+	}
+}
+
+// add 监视 root 及其下的所有子目录；同一目录再次加入时（如被移动后）更新其路径
+func (w *TreeWatcher) add(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 无法读取的目录中的被忽略文件也无法备份，不影响结果；监视起点本身无法访问时报错
+			if path == root && d == nil {
+				return fmt.Errorf("无法监视 %s: %w", root, err)
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if w.skip != nil && w.skip(path) {
+			return filepath.SkipDir
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, path, watchMask)
+		switch {
+		case err == syscall.ENOSPC:
+			limitErr := errors.New("监视的目录数超过 fs.inotify.max_user_watches，请调大该内核参数")
+			if w.onLost == nil {
+				return limitErr
+			}
+			if !w.full {
+				w.full = true
+				w.onLost(limitErr)
+			}
+			return filepath.SkipAll
+		case err != nil:
+			return nil
+		}
+		w.dirs[int32(wd)] = path
+		return nil
+	})
+}
+
+// cstringLen 返回以 NUL 结尾的字节串的长度
+func cstringLen(b []byte) int {
+	for i, c := range b {
+		if c == 0 {
+			return i
+		}
+	}
+	return len(b)
+}
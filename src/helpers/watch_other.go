@@ -0,0 +1,21 @@
+//go:build !linux
+
+package helpers
+
+import (
+	"errors"
+	"runtime"
+)
+
+// TreeWatcher 监视目录树中的变化（只支持 Linux）
+type TreeWatcher struct{}
+
+// WatchTree 只支持 Linux（inotify）；macOS 的 FSEvents 需要 cgo，Windows 上 --skip-unchanged 直接读取 USN 日志
+func WatchTree(roots []string, skip func(dir string) bool) (*TreeWatcher, error) {
+	return nil, errors.New("watch 子命令不支持 " + runtime.GOOS + "（只支持 Linux）")
+}
+
+// Run 其他系统上不会被调用
+func (w *TreeWatcher) Run(onChange func(path string), onLost func(reason error), done <-chan struct{}) error {
+	return nil
+}
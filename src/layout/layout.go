@@ -0,0 +1,216 @@
+package layout
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+// 备份目录布局
+const (
+	LayoutPath = "path" // 按相对于搜索根目录的完整路径存放（默认）
+	LayoutRepo = "repo" // 按仓库名存放，仓库移动位置后备份路径保持不变
+)
+
+// Mapper 将被忽略的文件映射为备份目标下的相对路径
+// repo 布局下，仓库名与仓库路径的对应关系持久化在备份根目录中，保证多次运行结果一致
+type Mapper struct {
+	mu       sync.Mutex
+	layout   string
+	root     string
+	names    map[string]string // 仓库名 -> 仓库根目录
+	byRepo   map[string]string // 仓库根目录 -> 仓库名
+	changed  bool
+	sanitize bool // 转义 Windows/exFAT 不兼容的文件名（见 helpers.SanitizePath）
+}
+
+// SanitizeNames 让映射结果转义 Windows/exFAT 不兼容的文件名，反推源文件路径时还原
+func (m *Mapper) SanitizeNames() {
+	m.sanitize = true
+}
+
+// destForm 将源文件的相对路径转换为备份目标下使用的形式
+func (m *Mapper) destForm(rel string) string {
+	rel = helpers.NormalizePath(rel)
+	if m.sanitize {
+		rel = helpers.SanitizePath(rel)
+	}
+	return rel
+}
+
+// Load 读取备份根目录下的仓库名映射，创建指定布局的映射器
+func Load(backupRoot, layout string) (*Mapper, error) {
+	m := &Mapper{
+		layout: layout,
+		root:   backupRoot,
+		names:  make(map[string]string),
+		byRepo: make(map[string]string),
+	}
+	if layout != LayoutRepo {
+		return m, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(backupRoot, config.RepoMapFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, fmt.Errorf("读取仓库名映射失败: %v", err)
+	}
+	if err := json.Unmarshal(data, &m.names); err != nil {
+		return nil, fmt.Errorf("解析仓库名映射失败: %v", err)
+	}
+	for name, repo := range m.names {
+		m.byRepo[repo] = name
+	}
+	return m, nil
+}
+
+// Validate 检查布局名称是否有效
+func Validate(layout string) error {
+	if layout != LayoutPath && layout != LayoutRepo {
+		return fmt.Errorf("未知的布局: %s（可选 %s、%s）", layout, LayoutPath, LayoutRepo)
+	}
+	return nil
+}
+
+// Resolve 返回文件在备份目标下的相对路径（统一为 Unicode NFC 形式，见 helpers.NormalizePath）
+func (m *Mapper) Resolve(file scanner.IgnoredFileInfo) string {
+	if m.layout != LayoutRepo || file.RepoRoot == "" {
+		return m.destForm(file.RelativePath)
+	}
+
+	relToRepo, err := filepath.Rel(file.RepoRoot, file.AbsPath)
+	if err != nil {
+		return m.destForm(file.RelativePath)
+	}
+	return filepath.Join(m.RepoName(file.RepoRoot), m.destForm(relToRepo))
+}
+
+// RepoName 返回仓库在备份目标下使用的目录名
+// 默认使用仓库目录名；同名仓库已被另一个仍存在的仓库占用时，追加路径哈希以区分；
+// 原仓库已不存在（通常是被移动了）时，新位置的仓库沿用该名称
+func (m *Mapper) RepoName(repoRoot string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if name, ok := m.byRepo[repoRoot]; ok {
+		return name
+	}
+
+	name := m.destForm(filepath.Base(repoRoot))
+	if owner, ok := m.names[name]; ok && owner != repoRoot {
+		if _, err := os.Stat(owner); err == nil {
+			sum := sha256.Sum256([]byte(repoRoot))
+			name = name + "-" + hex.EncodeToString(sum[:])[:8]
+		} else {
+			delete(m.byRepo, owner)
+		}
+	}
+
+	m.names[name] = repoRoot
+	m.byRepo[repoRoot] = name
+	m.changed = true
+	return name
+}
+
+// RepoSubtree 返回仓库根目录在备份目标下对应的相对路径
+// 从仓库内部开始扫描（仓库包含搜索根目录）时只对应搜索根目录这一部分：path 布局下即备份根目录本身（返回空字符串）
+func (m *Mapper) RepoSubtree(repoRoot, searchRoot string) string {
+	inner := innerPath(repoRoot, searchRoot)
+	if m.layout == LayoutRepo {
+		if inner != "" {
+			return filepath.Join(m.RepoName(repoRoot), m.destForm(inner))
+		}
+		return m.RepoName(repoRoot)
+	}
+	if inner != "" {
+		return ""
+	}
+	rel, err := filepath.Rel(searchRoot, repoRoot)
+	if err != nil {
+		return repoRoot
+	}
+	return m.destForm(rel)
+}
+
+// innerPath 返回搜索根目录相对于仓库根目录的路径；搜索根目录不在仓库之内（或就是仓库根目录）时返回空字符串
+func innerPath(repoRoot, searchRoot string) string {
+	rel, err := filepath.Rel(repoRoot, searchRoot)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return rel
+}
+
+// DropRepoScopes 从清理范围中去掉指定仓库在备份目标下的目录
+// 用于扫描结果不完整的仓库（如达到 --max-files-per-repo 上限），避免把未扫描到的文件的备份当作源文件已删除
+func (m *Mapper) DropRepoScopes(scopes, repos []string, backupRoot, searchRoot string) []string {
+	if len(repos) == 0 {
+		return scopes
+	}
+	drop := make(map[string]bool, len(repos))
+	for _, repo := range repos {
+		drop[filepath.Join(backupRoot, m.RepoSubtree(repo, searchRoot))] = true
+	}
+	kept := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !drop[scope] {
+			kept = append(kept, scope)
+		}
+	}
+	return kept
+}
+
+// Source 根据备份目标下的相对路径反推源文件路径，无法确定时返回空字符串
+// 转义过的文件名还原为原始文件名；备份路径是 NFC 形式，源文件名为 NFD 形式时返回实际存在的形式
+func (m *Mapper) Source(rel, searchRoot string) string {
+	if m.layout != LayoutRepo {
+		return helpers.ExistingPathForm(filepath.Join(searchRoot, helpers.UnsanitizePath(rel)))
+	}
+
+	parts := strings.SplitN(rel, string(filepath.Separator), 2)
+	m.mu.Lock()
+	repoRoot, ok := m.names[parts[0]]
+	m.mu.Unlock()
+	if !ok {
+		return ""
+	}
+	if len(parts) == 1 {
+		return repoRoot
+	}
+	return helpers.ExistingPathForm(filepath.Join(repoRoot, helpers.UnsanitizePath(parts[1])))
+}
+
+// Save 将仓库名映射写回备份根目录（无变化时不写入）
+func (m *Mapper) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.layout != LayoutRepo || !m.changed {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(m.names, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fsguard.MkdirAll(m.root, 0755, "保存仓库名映射"); err != nil {
+		return err
+	}
+	if err := fsguard.WriteFile(filepath.Join(m.root, config.RepoMapFileName), data, 0644, "保存仓库名映射"); err != nil {
+		return fmt.Errorf("写入仓库名映射失败: %v", err)
+	}
+	m.changed = false
+	return nil
+}
@@ -0,0 +1,193 @@
+package layout
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/git"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// repoRecord 仓库身份对应的上次运行位置
+type repoRecord struct {
+	RepoRoot string   `json:"repo_root"`        // 仓库根目录
+	Subtree  string   `json:"subtree"`          // 仓库在备份目标下的相对路径
+	Clones   []string `json:"clones,omitempty"` // 同一身份的其他仓库根目录（同一远程的多个克隆）
+}
+
+// Migrator 根据仓库身份（origin 地址或根提交）识别被移动的仓库，
+// 并将其备份子树重命名到新位置，避免重复复制和清理阶段把旧副本移入历史目录
+type Migrator struct {
+	mu         sync.Mutex
+	mapper     *Mapper
+	backupRoot string
+	searchRoot string
+	apply      bool                  // false 时只提示，不实际迁移
+	records    map[string]repoRecord // 仓库身份 -> 上次记录
+	seen       map[string][]string   // 仓库身份 -> 本次运行发现的仓库根目录
+	changed    bool
+}
+
+// LoadMigrator 读取备份根目录下的仓库身份记录
+func LoadMigrator(backupRoot, searchRoot string, mapper *Mapper, apply bool) (*Migrator, error) {
+	g := &Migrator{
+		mapper:     mapper,
+		backupRoot: backupRoot,
+		searchRoot: searchRoot,
+		apply:      apply,
+		records:    make(map[string]repoRecord),
+		seen:       make(map[string][]string),
+	}
+
+	data, err := os.ReadFile(filepath.Join(backupRoot, config.RepoIdentityFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return g, nil
+		}
+		return nil, fmt.Errorf("读取仓库身份记录失败: %v", err)
+	}
+	if err := json.Unmarshal(data, &g.records); err != nil {
+		return nil, fmt.Errorf("解析仓库身份记录失败: %v", err)
+	}
+	return g, nil
+}
+
+// Observe 处理本次运行发现的仓库：若同一身份的仓库上次位于其他路径且原路径已不存在，
+// 则迁移（或提示迁移）其备份子树；最后记录仓库的当前位置
+// 同一身份有多个仍存在的仓库（同一远程的多个克隆）时无法判断哪一个是被移动的，只记录不迁移
+// 应在该仓库的任何文件开始复制前调用
+func (g *Migrator) Observe(repoRoot string) {
+	// 从仓库内部开始扫描时备份只对应仓库的一部分，不记录也不迁移
+	if innerPath(repoRoot, g.searchRoot) != "" {
+		return
+	}
+	identity, err := git.RepoIdentity(repoRoot)
+	if err != nil || identity == "" {
+		return
+	}
+	subtree := g.mapper.RepoSubtree(repoRoot, g.searchRoot)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	seen := g.seen[identity]
+	g.seen[identity] = append(seen, repoRoot)
+
+	prev, ok := g.records[identity]
+	if !ok {
+		g.records[identity] = repoRecord{RepoRoot: repoRoot, Subtree: subtree}
+		g.changed = true
+		return
+	}
+	if prev.RepoRoot == repoRoot {
+		if prev.Subtree != subtree {
+			prev.Subtree = subtree
+			g.records[identity] = prev
+			g.changed = true
+		}
+		return
+	}
+	if containsPath(prev.Clones, repoRoot) {
+		return
+	}
+
+	// 同一身份的其他仓库：上次记录的克隆和本次已发现的仓库
+	var others []string
+	for _, root := range append(append([]string{}, prev.Clones...), seen...) {
+		if root != repoRoot && root != prev.RepoRoot && !containsPath(others, root) && exists(root) {
+			others = append(others, root)
+		}
+	}
+
+	if exists(prev.RepoRoot) {
+		// 原仓库仍在，是同一远程的另一个克隆
+		prev.Clones = append(others, repoRoot)
+		g.records[identity] = prev
+		g.changed = true
+		return
+	}
+	if len(others) > 0 {
+		ui.Printf("检测到仓库 %s 的原路径 %s 已不存在，但有多个仓库具有相同身份（%s），无法判断哪一个是被移动的，跳过迁移\n", repoRoot, prev.RepoRoot, identity)
+		prev.Clones = append(others, repoRoot)
+		g.records[identity] = prev
+		g.changed = true
+		return
+	}
+	if prev.Subtree != subtree && !g.migrate(prev, repoRoot, subtree) {
+		// 未迁移时保留旧记录，下次运行仍可迁移
+		return
+	}
+	g.records[identity] = repoRecord{RepoRoot: repoRoot, Subtree: subtree}
+	g.changed = true
+}
+
+// containsPath 判断路径列表中是否包含 path
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// exists 判断路径是否存在（无法确定时按存在处理，避免误迁移）
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return !os.IsNotExist(err)
+}
+
+// migrate 将旧位置的备份子树重命名到新位置，返回是否已迁移
+func (g *Migrator) migrate(prev repoRecord, repoRoot, subtree string) bool {
+	oldPath := filepath.Join(g.backupRoot, prev.Subtree)
+	newPath := filepath.Join(g.backupRoot, subtree)
+
+	if _, err := os.Stat(oldPath); err != nil {
+		return true // 旧备份已不存在，直接记录新位置
+	}
+	if _, err := os.Stat(newPath); err == nil {
+		ui.Printf("检测到仓库已移动: %s -> %s，但新备份路径已存在，跳过迁移: %s\n", prev.RepoRoot, repoRoot, newPath)
+		return true
+	}
+
+	if !g.apply {
+		ui.Printf("检测到仓库已移动: %s -> %s\n", prev.RepoRoot, repoRoot)
+		ui.Printf("  可使用 --migrate-moved 将备份 %s 重命名为 %s，避免重新复制\n", oldPath, newPath)
+		return false
+	}
+
+	if err := fsguard.MkdirAll(filepath.Dir(newPath), 0755, "迁移被移动仓库的备份"); err != nil {
+		ui.Errorf("迁移备份失败 %s: %v\n", oldPath, err)
+		return false
+	}
+	if err := fsguard.Rename(oldPath, newPath, "迁移被移动仓库的备份"); err != nil {
+		ui.Errorf("迁移备份失败 %s: %v\n", oldPath, err)
+		return false
+	}
+	ui.Printf("仓库已移动，备份已迁移: %s -> %s\n", oldPath, newPath)
+	return true
+}
+
+// Save 将仓库身份记录写回备份根目录（无变化时不写入）
+func (g *Migrator) Save() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.changed {
+		return nil
+	}
+	data, err := json.MarshalIndent(g.records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fsguard.WriteFile(filepath.Join(g.backupRoot, config.RepoIdentityFileName), data, 0644, "保存仓库身份记录"); err != nil {
+		return fmt.Errorf("写入仓库身份记录失败: %v", err)
+	}
+	g.changed = false
+	return nil
+}
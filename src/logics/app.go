@@ -2,75 +2,167 @@ package logics
 
 import (
 	"fmt"
-	"log"
-	"strings"
+	"os"
 	"time"
 
 	cfgpkg "github.com/aogg/copy-ignore/src/config"
 	"github.com/aogg/copy-ignore/src/copy"
 	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/layout"
+	"github.com/aogg/copy-ignore/src/manifest"
 	"github.com/aogg/copy-ignore/src/scanner"
+	"github.com/aogg/copy-ignore/src/snapshot"
+	"github.com/aogg/copy-ignore/src/ui"
 )
 
-// Run 运行主程序逻辑
-func Run(excluder *exclude.Matcher) {
+// Run 运行主程序逻辑，返回本次运行的结果
+// 运行中只输出进度，结束后的汇总由调用方通过 PrintReport 输出；失败时不退出进程，错误记录在 RunReport.Err 中
+func Run(excluder *exclude.Matcher) *RunReport {
 	cfg := cfgpkg.GetGlobalConfig()
-	// 扫描所有 Git 仓库并获取被忽略的文件
-	fmt.Printf("正在扫描目录: %s\n", cfg.SearchRoot)
-
-	// 创建进度显示回调
-	prevLen := 0
-	maxLen := 100 // 限制最大显示长度，避免换行
-	progress := func(path string) {
-		// 截断过长的路径
-		displayPath := path
-		if len(displayPath) > maxLen {
-			// 显示路径的开头部分和结尾部分
-			displayPath = displayPath[:maxLen-3] + "..."
-		}
+	if cfg.PrintConfig {
+		cfg.WriteEffective(os.Stdout, "")
+	}
+	if cfg.DebugAddr != "" {
+		defer startDebugServer(cfg.DebugAddr)()
+	}
 
-		// 回到行首，打印新路径，如果新路径比旧路径短，用空格覆盖剩余部分
-		fmt.Printf("\r当前扫描: %s", displayPath)
-		if len(displayPath) < prevLen {
-			fmt.Printf("%s", strings.Repeat(" ", prevLen-len(displayPath)))
+	// 扫描所有 Git 仓库并获取被忽略的文件
+	if len(cfg.ScanRoots) > 0 {
+		fmt.Printf("正在扫描 %d 个目录（备份路径相对于 %s）:\n", len(cfg.ScanRoots), cfg.SearchRoot)
+		for _, root := range cfg.ScanRoots {
+			fmt.Printf("  %s\n", root)
 		}
-		prevLen = len(displayPath)
+	} else {
+		fmt.Printf("正在扫描目录: %s\n", cfg.SearchRoot)
 	}
 
+	// 终端状态行：当前扫描的目录和复制进度，由界面协程按 --progress-interval 统一刷新
+	status := &statusLine{}
+
 	// 执行复制操作
+	report := newRunReport(cfg.DryRun)
 	if cfg.DryRun {
-		runDryRun(excluder, progress)
+		runDryRun(report, excluder, status)
 	} else {
-		runCopy(excluder, progress)
+		runCopy(report, excluder, status)
+	}
+	if report.Finished.IsZero() {
+		report.Finished = time.Now()
+	}
+	return report
+}
+
+// finishScan 记录扫描阶段的统计
+func finishScan(report *RunReport, excluder *exclude.Matcher, status *statusLine, duration time.Duration) {
+	report.Scan.Duration = duration
+	if p := status.discovery.Load(); p != nil {
+		report.Scan.DirsVisited = p.DirsVisited
+		report.Scan.Repos = p.ReposFound
+	}
+	report.Scan.TruncatedRepos = scanner.TruncatedRepos()
+	report.Scan.DirtyRepos = scanner.DirtyGitignoreRepos()
+	report.Scan.UnchangedRepos = scanner.UnchangedRepos()
+	report.Excludes = excluder.Stats()
+}
+
+// printLargestFiles 输出最大文件列表
+func printLargestFiles(title string, files []copy.FileStat) {
+	if len(files) == 0 {
+		return
+	}
+	fmt.Printf("%s（前 %d 个）:\n", title, len(files))
+	for _, f := range files {
+		fmt.Printf("  %10s  %s\n", helpers.FormatSize(f.Size), f.Path)
+	}
+}
+
+// printExtensionStats 按扩展名输出复制/跳过的文件数和字节数（按总字节数排序），用于发现值得排除的文件类型
+func printExtensionStats(stats []copy.ExtStat) {
+	if len(stats) == 0 {
+		return
+	}
+	var total int64
+	for _, e := range stats {
+		total += e.TotalBytes()
+	}
+	fmt.Printf("按扩展名统计:\n")
+	for _, e := range stats {
+		ext := e.Ext
+		if ext == "" {
+			ext = "(无扩展名)"
+		}
+		percent := 0.0
+		if total > 0 {
+			percent = float64(e.TotalBytes()) * 100 / float64(total)
+		}
+		fmt.Printf("  %-14s %5.1f%%  复制 %d 个 / %s，跳过 %d 个 / %s\n", ext, percent,
+			e.Copied, helpers.FormatSize(e.CopiedBytes), e.Skipped, helpers.FormatSize(e.SkippedBytes))
 	}
 }
 
 // runDryRun 执行干运行模式
-func runDryRun(excluder *exclude.Matcher, progress func(string)) {
+func runDryRun(report *RunReport, excluder *exclude.Matcher, status *statusLine) {
 	cfg := cfgpkg.GetGlobalConfig()
 	fmt.Println("干运行模式，不会实际复制文件")
 
+	// --output json：扫描结果逐个写入 JSON 文档（其余消息已重定向到标准错误）
+	var structured *scanJSONWriter
+	if cfg.Output == cfgpkg.OutputJSON {
+		structured = newScanJSONWriter(jsonStdout, cfg.SearchRoot)
+	}
+
 	// 记录扫描开始时间
 	scanStartTime := time.Now()
 	fmt.Printf("扫描开始时间: %s\n", scanStartTime.Format("2006-01-02 15:04:05"))
 
-	// 在dry-run模式下也需要扫描来显示文件，使用更大的缓冲区避免死锁
-	fileChan := make(chan scanner.IgnoredFileInfo, 10000)
-	var allFiles []scanner.IgnoredFileInfo
+	// 在dry-run模式下也需要扫描来显示文件：结果边扫描边输出、计入汇总，不在内存中累积
+	fileChan := make(chan scanner.IgnoredFileInfo, cfg.ScanQueueSize)
+	summary := newDryRunSummary()
+
+	// 同时指定 --delete-dry-run 时，预演清理阶段（只保留目标路径）
+	var preview *cleanupPreview
+	if cfg.DeleteDryRun {
+		var err error
+		if preview, err = newCleanupPreview(cfg); err != nil {
+			report.addError(fmt.Errorf("清理预演失败: %w", err))
+		}
+	}
 
 	// 启动收集协程
 	collectDone := make(chan struct{})
 	go func() {
 		defer close(collectDone)
 		for file := range fileChan {
-			allFiles = append(allFiles, file)
+			if cfg.Explain {
+				explainIncluded(file)
+			} else if cfg.Verbose {
+				ui.Printf("  %s\n", file.RelativePath)
+			}
+			info, _ := os.Lstat(file.AbsPath) // 已无法读取时为 nil
+			summary.add(file, info)
+			if structured != nil {
+				structured.add(newScanEntry(file, info))
+			}
+			if preview != nil {
+				preview.add(file)
+			}
 		}
 	}()
 
+	// 说明模式下同时列出被过滤的候选路径及原因
+	if cfg.Explain {
+		scanner.SetSkipHandler(func(ev scanner.SkipEvent) { explainSkipped(cfg.SearchRoot, ev) })
+		defer scanner.SetSkipHandler(nil)
+	}
+
 	// 扫描
-	err := scanner.ScanIgnoredFilesWithProgressStream(cfg.SearchRoot, excluder, progress, fileChan)
+	renderer := ui.Start(cfg.ProgressInterval, status.render)
+	err := scanner.ScanIgnoredFilesWithProgressStream(cfg.SearchRoot, excluder, status.setScanProgress, fileChan)
 	close(fileChan)
 	<-collectDone
+	status.scanDone.Store(true)
+	renderer.Stop()
 
 	// 记录扫描结束时间并计算耗时
 	scanEndTime := time.Now()
@@ -78,95 +170,222 @@ func runDryRun(excluder *exclude.Matcher, progress func(string)) {
 	fmt.Printf("扫描结束时间: %s\n", scanEndTime.Format("2006-01-02 15:04:05"))
 	fmt.Printf("扫描耗时: %.2f秒\n", scanDuration.Seconds())
 
+	if structured != nil {
+		totals := scanTotals{Entries: summary.files, Dirs: summary.dirs, Bytes: summary.size, Repos: len(summary.repos)}
+		if werr := structured.finish(totals, err); werr != nil {
+			report.addError(fmt.Errorf("输出 JSON 结果失败: %w", werr))
+		}
+	}
+
 	if err != nil {
-		log.Fatalf("扫描失败: %v", err)
+		report.fail(fmt.Errorf("%w: %w", ErrScan, err))
+		return
 	}
 
-	// 显示找到的文件
-	if cfg.Verbose && len(allFiles) > 0 {
-		// 记录输出开始时间
-		outputStartTime := time.Now()
-		fmt.Printf("输出结果开始时间: %s\n", outputStartTime.Format("2006-01-02 15:04:05"))
+	finishScan(report, excluder, status, scanDuration)
+	report.summary = summary
+	report.Scan.Entries = summary.files
+	report.Scan.Dirs = summary.dirs
+	report.Scan.Bytes = summary.size
 
-		fmt.Printf("找到 %d 个需要处理的被忽略文件\n", len(allFiles))
-		for _, file := range allFiles {
-			fmt.Printf("  %s\n", file.RelativePath)
-		}
+	if preview != nil {
+		stats := preview.run()
+		report.Cleanup = &stats
+	}
+}
 
-		// 记录输出结束时间
-		outputEndTime := time.Now()
-		fmt.Printf("输出结果结束时间: %s\n", outputEndTime.Format("2006-01-02 15:04:05"))
+// cleanupOrphanedTemps 处理上次运行崩溃后遗留在备份目标中的临时文件，并报告数量
+func cleanupOrphanedTemps(cfg *cfgpkg.Config) error {
+	mapper, err := layout.Load(cfg.BackupRoot, cfg.Layout)
+	if err != nil {
+		return fmt.Errorf("处理遗留的临时文件失败: %w", err)
+	}
+	if cfg.SanitizeActive {
+		mapper.SanitizeNames()
 	}
+	result, err := helpers.CleanupOrphanedTempFiles(cfg.BackupRoot, cfg.ManagedDirs(cfg.BackupRoot), helpers.ActiveRunIDs(cfg.SharedRoot), func(rel string) string {
+		return mapper.Source(rel, cfg.SearchRoot)
+	}, cfg.Verbose)
+	if err != nil {
+		err = fmt.Errorf("处理遗留的临时文件失败: %w", err)
+	}
+	if result != nil && result.Found > 0 {
+		fmt.Printf("发现 %d 个上次运行遗留的临时文件: %d 个已删除，%d 个已补完", result.Found, result.Removed, result.Finalized)
+		if result.Kept > 0 {
+			fmt.Printf("，%d 个无法确定来源而保留", result.Kept)
+		}
+		fmt.Println()
+	}
+	if result != nil && result.Foreign > 0 {
+		fmt.Printf("跳过 %d 个其他正在进行的运行的临时文件\n", result.Foreign)
+	}
+	return err
 }
 
 // runCopy 执行复制操作
-func runCopy(excluder *exclude.Matcher, progress func(string)) {
+func runCopy(report *RunReport, excluder *exclude.Matcher, status *statusLine) {
 	cfg := cfgpkg.GetGlobalConfig()
+	started := report.Started
 	fmt.Printf("正在复制到: %s\n", cfg.BackupRoot)
 
-	// 创建文件channel，使用更大的缓冲区避免死锁
-	fileChan := make(chan scanner.IgnoredFileInfo, 10000)
-
-	// 用于控制输出频率，避免输出过于频繁
-	lastOutputTime := time.Now()
-	lastSrc := ""
-	lastDest := ""
-
-	// 进度回调函数
-	onProgress := func(copied, skipped, errors, total int, src, dest string) {
-		now := time.Now()
-		// 每500ms最多输出一次，或者在路径发生变化时
-		if now.Sub(lastOutputTime) > 500*time.Millisecond || src != lastSrc || dest != lastDest {
-			fmt.Printf("\r进度: %d/%d 已复制, %d 跳过, %d 出错", copied, total, skipped, errors)
-			lastOutputTime = now
-			lastSrc = src
-			lastDest = dest
+	// 检查备份目标可写、带有标记文件，避免写入或清理未挂载的挂载点
+	if err := helpers.ProbeDestination(cfg.SharedRoot, cfg.InitDest, cfg.AppendOnly); err != nil {
+		report.fail(fmt.Errorf("%w: %w", ErrDestination, err))
+		return
+	}
+
+	// 获取本机对共享备份根目录的租约，检查其他机器是否正在写入重叠的目录
+	lease, err := acquireHostLease(cfg)
+	if err != nil {
+		report.fail(fmt.Errorf("%w: %w", ErrDestination, err))
+		return
+	}
+	defer lease.Release()
+
+	// 处理上次运行中断的移入历史操作（其他机器正在写入时，日志可能属于对方进行中的移动）
+	if !cfg.SharedInUse {
+		if repaired, err := helpers.RepairInterruptedMoves(cfg.BackupRoot, cfg.Verbose); err != nil {
+			report.addError(fmt.Errorf("修复中断的移动失败: %w", err))
+		} else if repaired.Completed+repaired.RolledBack > 0 {
+			fmt.Printf("已修复上次中断的移动: %d 个完成，%d 个回滚\n", repaired.Completed, repaired.RolledBack)
 		}
+		// 只追加模式下无法删除文件，遗留的临时文件保持原样
+		if !cfg.AppendOnly {
+			if err := cleanupOrphanedTemps(cfg); err != nil {
+				report.addError(err)
+			}
+		}
+	}
+
+	// 根据上次运行各仓库的耗时估计总耗时，复制过程中据此估计剩余时间
+	eta := newETAEstimator(loadRunTimings(cfg.BackupRoot), started)
+	if expected := eta.expected(); expected >= time.Second {
+		fmt.Printf("预计耗时: 约 %s（根据上次运行各仓库的耗时）\n", expected.Round(time.Second))
 	}
+	status.eta = eta
+
+	// 运行中暂停/继续复制（Unix 上为 SIGUSR1/SIGUSR2，Windows 上为键盘命令）
+	stopPauseControl := startPauseControl(copy.Pause, cfg.Verbose)
+	defer stopPauseControl()
+
+	// 超过 --stall-timeout 没有任何进展时警告（可选放弃卡住的文件），复制结束后停止检测
+	stopStallWatch := func() {}
+	if cfg.StallTimeout > 0 {
+		stopStallWatch = startStallWatch(cfg.StallTimeout, cfg.StallAbort, status)
+	}
+
+	// 开启 --skip-unchanged 时按 USN 日志跳过自上次运行以来没有变化的仓库，本次完整成功后记录日志位置
+	commitChanges := func() {}
+	if cfg.SkipUnchanged {
+		commitChanges = startChangeTracking(cfg)
+		defer scanner.SetUnchangedFilter(nil)
+	}
+
+	// 创建文件channel：复制跟不上时扫描阻塞等待，缓冲大小（--scan-queue）决定扫描最多领先多少个文件
+	fileChan := make(chan scanner.IgnoredFileInfo, cfg.ScanQueueSize)
+
+	// 终端输出交由界面协程统一渲染，状态行按 --progress-interval 刷新
+	renderer := ui.Start(cfg.ProgressInterval, status.render)
 
 	// 启动异步复制
 	var copyResult *copy.CopyResult
 	var copyErr error
 	copyDone := make(chan struct{})
+	scanFailed := make(chan struct{})
 	go func() {
 		defer close(copyDone)
-		copyResult, copyErr = copy.CopyFilesStreamWithProgress(
+		copyResult, copyErr = copy.CopyScanStream(
 			fileChan,
-			onProgress,
+			scanFailed,
+			status.setProgress,
 			excluder)
 	}()
 
 	// 流式扫描并发送文件到channel
-	scanErr := scanner.ScanIgnoredFilesWithProgressStream(cfg.SearchRoot, excluder, progress, fileChan)
-	close(fileChan) // 扫描完成，关闭channel
-
+	scanStarted := time.Now()
+	scanErr := scanner.ScanIgnoredFilesWithProgressStream(cfg.SearchRoot, excluder, status.setScanProgress, fileChan)
 	if scanErr != nil {
-		fmt.Println() // 换行以恢复正常输出
-		log.Fatalf("扫描失败: %v", scanErr)
+		close(scanFailed) // 不再派发剩余任务，不执行清理阶段
 	}
+	close(fileChan) // 扫描完成，关闭channel
+	status.scanDone.Store(true)
+	scanDuration := time.Since(scanStarted)
 
 	// 扫描完成，输出当前状态
-	fmt.Println() // 换行以恢复正常输出
-	fmt.Println("扫描完成，开始等待剩余复制任务...")
+	if scanErr == nil {
+		ui.Println("扫描完成，开始等待剩余复制任务...")
+	}
 
-	// 等待复制完成
+	// 等待复制完成（扫描失败时也要等正在复制的文件结束，返回后不能再写入备份目标），输出最终的进度后恢复直接输出
 	<-copyDone
+	stopStallWatch()
+	renderer.Stop()
+
+	if scanErr != nil {
+		recordRun(newLastRun(started, copyResult, scanErr))
+		report.fail(fmt.Errorf("%w: %w", ErrScan, scanErr))
+		return
+	}
+	finishScan(report, excluder, status, scanDuration)
+	report.Scan.Entries = int(status.total.Load())
 
 	if copyErr != nil {
-		log.Fatalf("复制失败: %v", copyErr)
+		recordRun(newLastRun(started, nil, copyErr))
+		report.fail(fmt.Errorf("%w: %w", ErrCopy, copyErr))
+		return
+	}
+	report.Copy = copyResult
+	report.Cleanup = copyResult.Cleanup
+
+	// 出错数超过 --max-errors 或备份目标空间不足：以失败结束（正在复制的文件已删除临时文件，清理阶段和清单更新不执行）
+	if copyResult.Aborted {
+		abortErr := fmt.Errorf("%w: 出错数超过 --max-errors %d，运行已中止", ErrCopy, cfg.MaxErrors)
+		if copyResult.AbortCause != nil {
+			abortErr = fmt.Errorf("%w: %w，运行已中止", ErrCopy, copyResult.AbortCause)
+		}
+		recordRun(newLastRun(started, copyResult, abortErr))
+		report.fail(abortErr)
+		return
 	}
 
-	// 输出最终结果
-	fmt.Printf("复制全部完成: %d 个文件处理，%d 个跳过", copyResult.Copied, copyResult.Skipped)
-	if copyResult.Errors > 0 {
-		fmt.Printf("，%d 个出错", copyResult.Errors)
+	// 按清单校验并从副本目标修复损坏的文件（需在更新清单前进行）
+	if cfg.HealFrom != "" {
+		if err := healFromSecondary(cfg.BackupRoot, cfg.HealFrom); err != nil {
+			report.addError(fmt.Errorf("校验修复失败: %w", err))
+		}
 	}
-	fmt.Println()
 
-	// 输出复制日志
-	if len(copyResult.Logs) > 0 {
-		for _, log := range copyResult.Logs {
-			fmt.Println(log)
+	// 更新备份根目录的清单，供 check 等命令使用（只追加模式下不改写已有文件）
+	if !cfg.AppendOnly {
+		if _, err := manifest.Update(cfg.BackupRoot, cfg.ManagedDirs(cfg.BackupRoot), cfg.Hash); err != nil {
+			report.addError(fmt.Errorf("更新清单失败: %w", err))
 		}
 	}
+
+	// 没有出错的文件时为备份目标创建快照（--snapshot），每次成功的运行对应一个不可修改的快照
+	run := newLastRun(started, copyResult, nil)
+	if cfg.Snapshot != "" {
+		if copyResult.Errors == 0 {
+			target := snapshot.Target{Root: cfg.BackupRoot, Shared: cfg.SharedRoot, Timestamp: cfg.Timestamp, Host: cfg.HostName}
+			if id, err := snapshot.Take(cfg.Snapshot, cfg.SnapshotOptions, target); err != nil {
+				run.SnapshotError = err.Error()
+				report.addError(fmt.Errorf("创建快照失败: %w", err))
+			} else {
+				run.Snapshot = id
+				fmt.Printf("已创建快照: %s\n", id)
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "警告: 有 %d 个文件出错，不创建快照\n", copyResult.Errors)
+		}
+	}
+
+	// 写入机器可读的运行摘要（供外部监控检查备份是否新鲜）并追加到运行历史
+	recordRun(run)
+	if !cfg.AppendOnly {
+		saveRunTimings(cfg.BackupRoot, time.Since(started))
+	}
+	// 有文件出错时不记录，下次运行仍从上次的位置比较，出错文件所在的仓库会被重新扫描
+	if copyResult.Errors == 0 {
+		commitChanges()
+	}
 }
@@ -0,0 +1,36 @@
+package logics
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// setupFSGuard 按 --read-only-source 启用源目录只读保护，按 --audit 打开审计日志
+// 所有文件系统修改都经过 fsguard：只读保护下修改搜索根目录之内（备份根目录、报告文件等除外）的路径会直接失败
+func setupFSGuard(cfg *cfgpkg.Config) error {
+	if cfg.ReadOnlySource {
+		if len(cfg.Sync) > 0 {
+			return fmt.Errorf("--read-only-source 不能与 --sync 同时使用（双向同步会把备份取回到源目录）")
+		}
+		fsguard.Protect(
+			[]string{cfg.SearchRoot, helpers.ResolvePath(cfg.SearchRoot)},
+			[]string{cfg.SharedRoot, cfg.BackupRoot, cfg.HistoryDir, cfg.LastRunFile, cfg.DeleteReport, cfg.AuditLog},
+		)
+	}
+
+	if cfg.AuditLog == "" {
+		return nil
+	}
+	f, err := os.OpenFile(cfg.AuditLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("打开审计日志失败: %v", err)
+	}
+	fmt.Fprintf(f, "# 开始运行: %s\n", strings.Join(os.Args, " "))
+	fsguard.SetAudit(f)
+	return nil
+}
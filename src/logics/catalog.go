@@ -0,0 +1,278 @@
+package logics
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/catalog"
+	"github.com/aogg/copy-ignore/src/chunkstore"
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/layout"
+	"github.com/aogg/copy-ignore/src/manifest"
+)
+
+// RunCatalog 执行 catalog 子命令：导出备份目录（当前文件、历史版本、运行历史）供外部查询
+func RunCatalog(args []string) int {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s catalog export --sqlite <文件.db> [--search-root 目录] [--layout path|repo] [--history-dir 目录] [--compute-hashes] <备份根目录>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "      将备份的所有文件、历史版本、大小、哈希和运行历史导出为 SQLite 数据库，可用任意 SQL 查询\n")
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+
+	switch args[0] {
+	case "export":
+		return runCatalogExport(args[1:])
+	}
+	usage()
+	return 2
+}
+
+// catalogSource 导出时读取的备份目标
+type catalogSource struct {
+	backupRoot  string
+	historyBase string
+	searchRoot  string // 为空时不确定原始位置
+	mapper      *layout.Mapper
+	manifest    *manifest.Manifest // 没有清单时为 nil
+	algorithm   string
+	compute     bool // 清单中没有（或已过期）的文件及历史版本现场计算哈希
+}
+
+// runCatalogExport 导出备份目录到 SQLite 数据库
+func runCatalogExport(args []string) int {
+	fs := flag.NewFlagSet("catalog export", flag.ExitOnError)
+	output := fs.String("sqlite", "", "导出的 SQLite 数据库文件（已存在时替换）")
+	searchRoot := fs.String("search-root", "", "复制时的搜索根目录，指定时记录每个文件的原始位置（source 列）")
+	layoutName := fs.String("layout", layout.LayoutPath, "复制时使用的备份目录布局：path 或 repo")
+	historyDir := fs.String("history-dir", "", "单独配置的备份历史文件夹（与复制时的 --history-dir 相同）")
+	historySubDir := fs.String("history-subdir", "copy-ignore备份", "备份目录下的历史子目录名称（与复制时的 --history-subdir 相同）")
+	timestampFormat := fs.String("timestamp-format", "default", "历史目录名的时间戳格式（与复制时的 --timestamp-format 相同）")
+	timestampZone := fs.String("timestamp-tz", "local", "历史目录时间戳使用的时区（与复制时的 --timestamp-tz 相同）")
+	compute := fs.Bool("compute-hashes", false, "清单中没有记录的文件和历史版本现场计算哈希（默认只使用清单中的哈希，其余留空）")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: %s catalog export --sqlite <文件.db> [选项] <备份根目录>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "将备份目标中的当前文件（files 表）、历史目录中的旧版本（versions 表）和运行历史（runs 表）导出为 SQLite 数据库。\n\n")
+		fmt.Fprintf(os.Stderr, "参数:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 || *output == "" {
+		fs.Usage()
+		return 2
+	}
+	if err := layout.Validate(*layoutName); err != nil {
+		fmt.Fprintf(os.Stderr, "参数错误: %v\n", err)
+		return 2
+	}
+	loc, err := helpers.LoadTimestampZone(*timestampZone)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+
+	backupRoot := filepath.Clean(fs.Arg(0))
+	if info, err := os.Stat(backupRoot); err != nil || !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "备份根目录不存在: %s\n", backupRoot)
+		return 1
+	}
+	out := absRoot(*output)
+	if helpers.IsWithin(out, absRoot(backupRoot)) {
+		fmt.Fprintf(os.Stderr, "参数错误: 导出的数据库不能位于备份根目录中: %s\n", out)
+		return 2
+	}
+
+	src := &catalogSource{backupRoot: backupRoot, historyBase: filepath.Join(backupRoot, *historySubDir), compute: *compute}
+	if *historyDir != "" {
+		src.historyBase = filepath.Clean(*historyDir)
+	}
+	if *searchRoot != "" {
+		src.searchRoot = absRoot(*searchRoot)
+	}
+	if src.mapper, err = layout.Load(backupRoot, *layoutName); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if src.manifest, err = manifest.Load(backupRoot); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	src.algorithm = helpers.HashAlgorithm("")
+	if src.manifest != nil {
+		src.algorithm = src.manifest.Algorithm
+	}
+	versions, err := helpers.ListHistoryVersions(src.historyBase, helpers.ResolveTimestampFormat(*timestampFormat), loc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取历史目录失败: %v\n", err)
+		return 1
+	}
+	runs, err := loadRunHistory(backupRoot)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	// 先写入临时文件，完整导出后再替换，查询中的旧数据库不会读到写了一半的内容
+	tmp := out + ".tmp"
+	fsguard.Remove(tmp, "删除上次导出遗留的临时数据库")
+	var stats catalogStats
+	err = fsguard.Do("write", tmp, "导出备份目录：写入临时数据库", func() error {
+		w, err := catalog.Create(tmp)
+		if err != nil {
+			return err
+		}
+		if err := src.export(w, versions, runs, &stats); err != nil {
+			w.Abort()
+			return err
+		}
+		return w.Close()
+	})
+	if err == nil {
+		err = fsguard.Rename(tmp, out, "导出备份目录")
+	}
+	if err != nil {
+		fsguard.Remove(tmp, "删除导出失败的临时数据库")
+		fmt.Fprintf(os.Stderr, "导出失败: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("已导出到 %s: %d 个文件（%s），%d 个时间戳目录中的 %d 个历史版本（%s），%d 次运行\n", out,
+		stats.files, helpers.FormatSize(stats.bytes), len(versions), stats.versions, helpers.FormatSize(stats.versionBytes), len(runs))
+	if stats.unhashed > 0 {
+		fmt.Printf("%d 个文件或版本没有哈希（清单中没有记录，加 --compute-hashes 现场计算）\n", stats.unhashed)
+	}
+	return 0
+}
+
+// catalogStats 导出的统计
+type catalogStats struct {
+	files        int
+	bytes        int64
+	versions     int
+	versionBytes int64
+	unhashed     int
+}
+
+// export 写入元信息、当前文件、历史版本和运行历史
+func (s *catalogSource) export(w *catalog.Writer, versions []helpers.HistoryVersion, runs []RunRecord, stats *catalogStats) error {
+	meta := map[string]string{
+		"backup_root":    s.backupRoot,
+		"history_dir":    s.historyBase,
+		"search_root":    s.searchRoot,
+		"hash_algorithm": s.algorithm,
+		"exported_at":    time.Now().UTC().Format(catalog.TimeFormat),
+	}
+	if s.manifest != nil {
+		meta["manifest_updated"] = s.manifest.Updated.UTC().Format(catalog.TimeFormat)
+	}
+	for key, value := range meta {
+		if err := w.SetMeta(key, value); err != nil {
+			return err
+		}
+	}
+
+	// 当前文件：与还原相同，历史目录、块池等工具自身管理的目录和文件不导出
+	cfg := &cfgpkg.Config{BackupSubdir: filepath.Base(s.historyBase)}
+	var addErr error
+	err := walkBackupFiles(s.backupRoot, cfg.ManagedDirs(s.backupRoot), func(rel, path string, info fs.FileInfo) {
+		if addErr != nil {
+			return
+		}
+		f := s.file(s.backupRoot, rel, path, info, true)
+		stats.files++
+		stats.bytes += f.Size
+		if f.Hash == "" {
+			stats.unhashed++
+		}
+		addErr = w.AddFile(f)
+	})
+	if err == nil {
+		err = addErr
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, v := range versions {
+		root := filepath.Join(s.historyBase, v.Name)
+		err := walkBackupFiles(root, nil, func(rel, path string, info fs.FileInfo) {
+			if addErr != nil {
+				return
+			}
+			f := s.file(root, rel, path, info, false)
+			f.Version, f.VersionTime = v.Name, v.Time
+			stats.versions++
+			stats.versionBytes += f.Size
+			if f.Hash == "" {
+				stats.unhashed++
+			}
+			addErr = w.AddFile(f)
+		})
+		if err == nil {
+			err = addErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, r := range runs {
+		err := w.AddRun(catalog.Run{
+			StartedAt:    r.StartedAt,
+			FinishedAt:   r.FinishedAt,
+			Duration:     r.Duration,
+			Success:      r.Success,
+			FatalError:   r.FatalError,
+			Copied:       r.Totals.Copied,
+			Skipped:      r.Totals.Skipped,
+			Errors:       r.Totals.Errors,
+			Conflicts:    r.Totals.Conflicts,
+			CopiedBytes:  r.Totals.CopiedBytes,
+			SkippedBytes: r.Totals.SkippedBytes,
+			BackupBytes:  r.Totals.BackupBytes,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// file 生成一个文件的记录；current 表示备份目标中的当前版本，清单中大小和修改时间一致的记录直接使用其哈希
+func (s *catalogSource) file(root, rel, path string, info fs.FileInfo, current bool) catalog.File {
+	stored, _ := filepath.Rel(root, path)
+	f := catalog.File{
+		Path:       filepath.ToSlash(rel),
+		StoredPath: filepath.ToSlash(stored),
+		Size:       info.Size(),
+		ModTime:    info.ModTime(),
+	}
+	if s.searchRoot != "" {
+		f.Source = s.mapper.Source(rel, s.searchRoot)
+	}
+	if recipe, err := chunkstore.ReadRecipe(path); err == nil && recipe != nil {
+		f.Chunked = true
+		f.Size = recipe.Size
+	}
+	if current && s.manifest != nil {
+		if entry, ok := s.manifest.Entries[f.StoredPath]; ok && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+			f.Hash = entry.Hash
+		}
+	}
+	if f.Hash == "" && s.compute {
+		if hash, err := helpers.HashFileWith(path, s.algorithm); err == nil {
+			f.Hash = hash
+		}
+	}
+	return f
+}
@@ -0,0 +1,96 @@
+package logics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+// changeState 上次完整成功的运行开始时的变更位置（--skip-unchanged）
+type changeState struct {
+	Config    string                `json:"config"`              // 影响被选中的文件和备份路径的配置，变化后之前的位置不再沿用
+	Since     time.Time             `json:"since"`               // 运行开始的时间（按 watch 子命令的变更日志比较）
+	Positions []scanner.USNPosition `json:"positions,omitempty"` // 各卷的 USN 日志位置（Windows）
+}
+
+// changeFingerprint 返回影响被选中的文件和备份路径的配置，这些配置变化后没有变化的仓库也需要重新扫描
+func changeFingerprint(cfg *cfgpkg.Config) string {
+	data, _ := json.Marshal([]any{cfg.SearchRoot, cfg.ScanRoots, cfg.Excludes, cfg.SkipBinary, cfg.SkipCaches,
+		cfg.IgnoreBackupMarkers, cfg.RecheckDirs, cfg.DirtyGitignore, cfg.Layout, cfg.Placeholders, cfg.ReparsePoints,
+		cfg.SanitizeNames, cfg.MaxFilesPerRepo})
+	return string(data)
+}
+
+// loadChangeState 读取上次记录的变更位置，不存在、损坏或配置已变化时返回 nil
+func loadChangeState(cfg *cfgpkg.Config) *changeState {
+	data, err := os.ReadFile(filepath.Join(cfg.BackupRoot, cfgpkg.ChangeStateFileName))
+	if err != nil {
+		return nil
+	}
+	var s changeState
+	if err := json.Unmarshal(data, &s); err != nil || s.Config != changeFingerprint(cfg) {
+		return nil
+	}
+	return &s
+}
+
+// saveChangeState 记录本次运行开始时的变更位置
+func saveChangeState(backupRoot string, s *changeState) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		err = fsguard.WriteFile(filepath.Join(backupRoot, cfgpkg.ChangeStateFileName), data, 0644, "记录变更位置（下次只扫描有变化的仓库）")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 记录变更位置失败: %v\n", err)
+	}
+}
+
+// changeKey 返回比较路径时使用的形式（Windows 上不区分大小写）
+func changeKey(path string) string {
+	if runtime.GOOS == "windows" {
+		return strings.ToLower(path)
+	}
+	return path
+}
+
+// startChangeTracking 确定自上次完整成功的运行以来有变化的路径（Windows 上读取 NTFS 的 USN 日志，
+// 其他系统上读取 watch 子命令记录的变更日志），设置扫描时跳过没有变化的仓库；
+// 返回在本次运行完整成功后记录当前位置的函数。无法确定变化范围的仓库照常扫描
+func startChangeTracking(cfg *cfgpkg.Config) (commit func()) {
+	state := &changeState{Config: changeFingerprint(cfg), Since: time.Now()}
+	prev := loadChangeState(cfg)
+
+	var tracked func(repoRoot string) bool
+	var changed []string
+	if runtime.GOOS == "windows" {
+		state.Positions, tracked, changed = usnChanges(cfg, prev)
+	} else {
+		tracked, changed = watchLogChanges(cfg, prev)
+	}
+
+	if tracked != nil {
+		// 记录有变化的路径及其所有上级目录，仓库根目录在其中即表示仓库有变化
+		changedDirs := make(map[string]bool)
+		for _, p := range changed {
+			for p = changeKey(p); !changedDirs[p]; p = filepath.Dir(p) {
+				changedDirs[p] = true
+				if filepath.Dir(p) == p {
+					break
+				}
+			}
+		}
+		scanner.SetUnchangedFilter(func(repoRoot string) bool {
+			return tracked(repoRoot) && !changedDirs[changeKey(repoRoot)]
+		})
+	}
+
+	return func() { saveChangeState(cfg.BackupRoot, state) }
+}
@@ -0,0 +1,179 @@
+package logics
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/manifest"
+)
+
+// RunCheck 执行 check 子命令：比较多个备份目标的内容是否一致
+// 以第一个目标为基准，逐一报告其余目标的缺失、多余和内容不同的文件
+func RunCheck(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	heal := fs.Bool("heal", false, "以第一个目标为基准修复其余目标（补齐缺失、覆盖内容不同的文件）")
+	rehash := fs.Bool("rehash", false, "忽略已有清单中的哈希，重新计算所有文件")
+	hashAlgorithm := fs.String("hash", "", "比较使用的内容哈希算法：sha256、blake3 或 xxh3，默认使用基准目标清单中记录的算法")
+	historySubDir := fs.String("history-subdir", "copy-ignore备份", "备份目录下的历史子目录名称（比较时跳过）")
+	verbose := fs.Bool("verbose", false, "显示详细输出")
+	fs.BoolVar(verbose, "v", false, "显示详细输出（简写）")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: %s check [选项] <基准目标> <目标> [目标...]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "比较多个备份目标的内容（基于清单和 SHA-256 哈希），报告不一致之处。\n\n")
+		fmt.Fprintf(os.Stderr, "参数:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	dests := fs.Args()
+	if len(dests) < 2 {
+		fs.Usage()
+		return 2
+	}
+
+	if err := helpers.ValidateHashAlgorithm(*hashAlgorithm); err != nil {
+		fmt.Fprintf(os.Stderr, "参数错误: %v\n", err)
+		return 2
+	}
+	for i, dest := range dests {
+		dests[i] = filepath.Clean(dest)
+	}
+	// 所有目标按同一算法计算，默认沿用基准目标清单中的算法
+	if *hashAlgorithm == "" {
+		if m, err := manifest.Load(dests[0]); err == nil && m != nil {
+			*hashAlgorithm = m.Algorithm
+		}
+	}
+
+	cfg := &cfgpkg.Config{
+		Verbose:      *verbose,
+		BackupSubdir: *historySubDir,
+		Hash:         *hashAlgorithm,
+	}
+	cfgpkg.InitGlobalConfig(cfg)
+
+	// 为每个目标生成最新清单
+	manifests := make([]*manifest.Manifest, len(dests))
+	for i := range dests {
+		m, err := buildDestManifest(dests[i], cfg.ManagedDirs(dests[i]), *rehash, cfg.Hash)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "读取目标 %s 失败: %v\n", dests[i], err)
+			return 1
+		}
+		manifests[i] = m
+		fmt.Printf("目标: %s（%d 个文件）\n", dests[i], len(m.Entries))
+	}
+	fmt.Println()
+
+	base := dests[0]
+	divergent := 0
+	// 基准目标的块池损坏时无法用于修复，只报告
+	if !checkDestChunks("", base, cfg.ManagedDirs(base), *verbose) {
+		divergent++
+	}
+	for i := 1; i < len(dests); i++ {
+		diffs := manifest.Diff(manifests[0], manifests[i])
+		consistent := len(diffs) == 0
+		if !consistent {
+			fmt.Printf("✗ %s 与基准存在 %d 处差异:\n", dests[i], len(diffs))
+			for _, d := range diffs {
+				fmt.Printf("  [%s] %s\n", d.Kind, d.Path)
+			}
+		}
+
+		if !consistent && *heal {
+			healed, failed := healDestination(base, dests[i], diffs, *verbose)
+			fmt.Printf("  修复完成: %d 个文件已修复，%d 个失败\n", healed, failed)
+			// 多余文件不会被删除，仍视为不一致
+			consistent = failed == 0 && healed == len(diffs)
+			if _, err := manifest.Update(dests[i], cfg.ManagedDirs(dests[i]), cfg.Hash); err != nil {
+				fmt.Fprintf(os.Stderr, "  更新清单失败: %v\n", err)
+			}
+		}
+
+		// 修复后再校验块池：修复复制的配方引用的块同样需要从基准获取
+		healFrom := ""
+		if *heal {
+			healFrom = base
+		}
+		if !checkDestChunks(healFrom, dests[i], cfg.ManagedDirs(dests[i]), *verbose) {
+			consistent = false
+		}
+
+		if consistent {
+			fmt.Printf("✓ %s 与基准一致\n", dests[i])
+		} else {
+			divergent++
+		}
+	}
+
+	if divergent > 0 {
+		return 1
+	}
+	return 0
+}
+
+// buildDestManifest 为备份目标生成反映当前磁盘内容的清单
+func buildDestManifest(dest string, skipDirs []string, rehash bool, algorithm string) (*manifest.Manifest, error) {
+	if info, err := os.Stat(dest); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("不是目录")
+	}
+
+	var prev *manifest.Manifest
+	if !rehash {
+		loaded, err := manifest.Load(dest)
+		if err != nil {
+			return nil, err
+		}
+		prev = loaded
+	}
+	return manifest.Build(dest, prev, skipDirs, algorithm)
+}
+
+// checkDestChunks 校验目标中的配方引用的块，healFrom 不为空时从该目标获取缺失或损坏的块；块全部完好（或已修复）时返回 true
+func checkDestChunks(healFrom, dest string, skipDirs []string, verbose bool) bool {
+	issues, err := checkChunks(dest, skipDirs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✗ 校验 %s 的块池失败: %v\n", dest, err)
+		return false
+	}
+	if len(issues) == 0 {
+		return true
+	}
+	printChunkIssues(dest, issues)
+	if healFrom == "" {
+		return false
+	}
+	healed, failed := healChunks(healFrom, dest, issues, verbose)
+	fmt.Printf("  块修复完成: %d 个已修复，%d 个失败\n", healed, failed)
+	return failed == 0
+}
+
+// healDestination 从基准目标复制缺失或内容不同的文件到目标
+// 仅存在于目标中的多余文件不会被删除
+func healDestination(base, dest string, diffs []manifest.Difference, verbose bool) (healed, failed int) {
+	for _, d := range diffs {
+		if d.Kind == manifest.OnlyInOther {
+			continue
+		}
+		src := filepath.Join(base, filepath.FromSlash(d.Path))
+		target := filepath.Join(dest, filepath.FromSlash(d.Path))
+		if err := helpers.CopyFileAtomic(src, target); err != nil {
+			fmt.Fprintf(os.Stderr, "  修复失败 %s: %v\n", d.Path, err)
+			failed++
+			continue
+		}
+		if verbose {
+			fmt.Printf("  已修复: %s -> %s\n", src, target)
+		}
+		healed++
+	}
+	return healed, failed
+}
@@ -0,0 +1,194 @@
+package logics
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/chunkstore"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// RunChunks 执行 chunks 子命令：维护分块存储的块池
+func RunChunks(args []string) int {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s chunks gc [--dry-run] [--history-dir 目录] <备份根目录>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "      删除未被任何配方（包括历史版本）引用的块\n")
+		fmt.Fprintf(os.Stderr, "  %s chunks cat <备份根目录> <配方文件> [输出文件]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "      按配方还原文件内容，未指定输出文件时写到标准输出\n")
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+
+	switch args[0] {
+	case "gc":
+		return runChunksGC(args[1:])
+	case "cat":
+		return runChunksCat(args[1:], usage)
+	}
+	usage()
+	return 2
+}
+
+// runChunksGC 回收未被引用的块
+func runChunksGC(args []string) int {
+	fs := flag.NewFlagSet("chunks gc", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "只统计可回收的块，不删除")
+	historyDir := fs.String("history-dir", "", "单独配置的备份历史文件夹（其中的配方同样需要保留引用）")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	backupRoot := fs.Arg(0)
+	roots := []string{backupRoot}
+	if *historyDir != "" {
+		roots = append(roots, *historyDir)
+	}
+
+	// 正在进行的复制已写入块、但还没有写入配方，回收会删除这些块，使其配方引用缺失的块
+	if !*dryRun {
+		if runs := activeRunsOn(backupRoot); runs > 0 {
+			fmt.Fprintf(os.Stderr, "有 %d 个复制正在使用该备份目标，拒绝回收（可加 --dry-run 只统计）\n", runs)
+			return 1
+		}
+	}
+
+	result, err := chunkstore.Open(backupRoot).GC(roots, *dryRun, chunkGCGrace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "回收失败: %v\n", err)
+		return 1
+	}
+
+	action := "已删除"
+	if *dryRun {
+		action = "可删除"
+	}
+	fmt.Printf("被引用的块: %d 个\n", result.Referenced)
+	fmt.Printf("%s未引用的块: %d 个，共 %s\n", action, result.Removed, helpers.FormatSize(result.Freed))
+	if result.Recent > 0 {
+		fmt.Printf("保留最近 %.0f 小时内写入、暂未被引用的块: %d 个（可能属于正在进行的复制）\n", chunkGCGrace.Hours(), result.Recent)
+	}
+	return 0
+}
+
+// chunkGCGrace 回收时保留最近写入的未引用块的时长：
+// 没有租约的运行（如只追加模式）写入的块同样可能还在等待配方，暂停中的复制也可能长时间不写入配方
+const chunkGCGrace = 24 * time.Hour
+
+// activeRunsOn 返回正在写入备份目标的运行数（租约仍有效）；按主机分隔的子树，租约位于共享根目录
+func activeRunsOn(backupRoot string) int {
+	shared := backupRoot
+	if helpers.IsHostSubtree(backupRoot) {
+		shared = filepath.Dir(filepath.Clean(backupRoot))
+	}
+	leases, _ := helpers.ReadLeases(shared)
+	now := time.Now()
+	active := 0
+	for _, lease := range leases {
+		if lease.Active(now) {
+			active++
+		}
+	}
+	return active
+}
+
+// runChunksCat 按配方还原文件
+func runChunksCat(args []string, usage func()) int {
+	if len(args) < 2 || len(args) > 3 {
+		usage()
+		return 2
+	}
+
+	recipe, err := chunkstore.ReadRecipe(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取配方失败: %v\n", err)
+		return 1
+	}
+	if recipe == nil {
+		fmt.Fprintf(os.Stderr, "不是分块存储的配方文件: %s\n", args[1])
+		return 1
+	}
+
+	var out io.Writer = os.Stdout
+	if len(args) == 3 {
+		f, err := fsguard.Create(args[2], "导出文件")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "创建输出文件失败: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := chunkstore.Open(args[0]).Restore(recipe, out); err != nil {
+		fmt.Fprintf(os.Stderr, "还原失败: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// chunkIssue 配方引用的缺失或损坏的块
+type chunkIssue struct {
+	hash   string
+	recipe string // 引用该块的配方（相对于备份目标，/ 分隔）
+	err    error
+}
+
+// checkChunks 校验备份目标中（跳过 skipDirs）的配方引用的所有块，返回缺失或损坏的块
+// 清单和目标间的比较只覆盖配方本身（块的列表），块池中的内容需要单独按哈希校验
+func checkChunks(root string, skipDirs []string) ([]chunkIssue, error) {
+	store := chunkstore.Open(root)
+	refs, err := store.Referenced([]string{root}, skipDirs)
+	if err != nil {
+		return nil, err
+	}
+	var issues []chunkIssue
+	for hash, recipe := range refs {
+		if err := store.Check(hash); err != nil {
+			rel, _ := filepath.Rel(root, recipe)
+			issues = append(issues, chunkIssue{hash: hash, recipe: filepath.ToSlash(rel), err: err})
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].recipe != issues[j].recipe {
+			return issues[i].recipe < issues[j].recipe
+		}
+		return issues[i].hash < issues[j].hash
+	})
+	return issues, nil
+}
+
+// printChunkIssues 列出缺失或损坏的块
+func printChunkIssues(dest string, issues []chunkIssue) {
+	fmt.Printf("✗ %s 的块池中有 %d 个被引用的块缺失或损坏:\n", dest, len(issues))
+	for _, issue := range issues {
+		fmt.Printf("  [块] %s（%s 引用）: %v\n", issue.hash, issue.recipe, issue.err)
+	}
+}
+
+// healChunks 从 source 的块池获取缺失或损坏的块（源块的内容与哈希一致时才写入）
+func healChunks(source, dest string, issues []chunkIssue, verbose bool) (healed, failed int) {
+	from, to := chunkstore.Open(source), chunkstore.Open(dest)
+	for _, issue := range issues {
+		if err := to.CopyFrom(from, issue.hash); err != nil {
+			fmt.Fprintf(os.Stderr, "  修复块失败 %s: %v\n", issue.hash, err)
+			failed++
+			continue
+		}
+		if verbose {
+			fmt.Printf("  已修复块: %s\n", issue.hash)
+		}
+		healed++
+	}
+	return healed, failed
+}
@@ -0,0 +1,298 @@
+package logics
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aogg/copy-ignore/src/chunkstore"
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/layout"
+	"github.com/aogg/copy-ignore/src/manifest"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+// cleanSourceStats clean-source 的统计结果
+type cleanSourceStats struct {
+	verified   int      // 已校验（且已删除或可删除）的文件数
+	freed      int64    // 释放（或可释放）的字节数
+	unverified int      // 备份缺失或内容不一致、保留的文件数
+	failed     int      // 删除失败的文件数
+	cleaned    []string // 已删除文件在备份目标下的相对路径
+}
+
+// RunCleanSource 执行 clean-source 子命令：校验被忽略的文件在备份中存在且哈希一致后，从源仓库删除
+// 相当于对所有仓库执行更安全的 git clean -fdX；默认只列出，指定 --yes 才会删除
+func RunCleanSource(args []string) int {
+	fs := flag.NewFlagSet("clean-source", flag.ExitOnError)
+	var excludes sliceFlags
+	fs.Var(&excludes, "exclude", "排除模式（支持多次），与复制时使用的排除规则保持一致")
+	yes := fs.Bool("yes", false, "确认删除；未指定时只列出校验通过、可删除的文件")
+	layoutName := fs.String("layout", layout.LayoutPath, "复制时使用的备份目录布局：path 或 repo")
+	sanitizeNames := fs.String("sanitize-names", cfgpkg.SanitizeAuto, "复制时使用的文件名转义模式：auto、always 或 never")
+	auditLog := fs.String("audit", "", "审计日志路径：逐行记录每一次删除（时间、操作、路径、原因）")
+	reparsePoints := fs.String("reparse-points", cfgpkg.ReparseSkip, "复制时使用的目录链接处理策略：skip 或 follow")
+	verbose := fs.Bool("verbose", false, "显示详细输出")
+	fs.BoolVar(verbose, "v", false, "显示详细输出（简写）")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: %s clean-source [选项] <搜索根目录> <备份根目录>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "删除源仓库中被忽略、且已在备份中校验（SHA-256 一致）的文件，以释放磁盘空间。\n")
+		fmt.Fprintf(os.Stderr, "备份缺失或内容不一致的文件会保留。默认只列出，指定 --yes 才会删除。\n\n")
+		fmt.Fprintf(os.Stderr, "参数:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	if err := layout.Validate(*layoutName); err != nil {
+		fmt.Fprintf(os.Stderr, "参数错误: %v\n", err)
+		return 2
+	}
+
+	searchRoot := absRoot(fs.Arg(0))
+	backupRoot := filepath.Clean(fs.Arg(1))
+	cfg := &cfgpkg.Config{
+		SearchRoot:    searchRoot,
+		BackupRoot:    backupRoot,
+		Excludes:      excludes,
+		Layout:        *layoutName,
+		AuditLog:      *auditLog,
+		ReparsePoints: *reparsePoints,
+		SanitizeNames: *sanitizeNames,
+		Verbose:       *verbose,
+	}
+	if err := resolveSanitizeNames(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "参数错误: %v\n", err)
+		return 2
+	}
+	if err := setupFSGuard(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	cfgpkg.InitGlobalConfig(cfg)
+
+	excluder, err := exclude.NewMatcher(excludes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "初始化排除匹配器失败: %v\n", err)
+		return 1
+	}
+	mapper, err := layout.Load(backupRoot, *layoutName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if cfg.SanitizeActive {
+		mapper.SanitizeNames()
+	}
+	m, err := manifest.Load(backupRoot)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	// 扫描被忽略的文件
+	files, err := scanIgnoredFiles(searchRoot, excluder)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "扫描失败: %v\n", err)
+		return 1
+	}
+
+	v := &sourceVerifier{backupRoot: backupRoot, manifest: m, store: chunkstore.Open(backupRoot)}
+	stats := &cleanSourceStats{}
+	for _, file := range files {
+		destPath := filepath.Join(backupRoot, mapper.Resolve(file))
+		cleanSourcePath(v, file.AbsPath, destPath, excluder, *yes, *verbose, stats)
+	}
+
+	// 记录已删除的文件，之后的复制运行不会把它们的备份当作“源文件已删除”移入历史目录
+	if err := helpers.AddCleanedSources(backupRoot, stats.cleaned); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		stats.failed++
+	}
+
+	action := "可删除"
+	if *yes {
+		action = "已删除"
+	}
+	fmt.Printf("\n%s %d 个已校验的文件，共 %s；%d 个文件备份缺失或不一致，已保留", action, stats.verified, helpers.FormatSize(stats.freed), stats.unverified)
+	if stats.failed > 0 {
+		fmt.Printf("；%d 个删除失败", stats.failed)
+	}
+	fmt.Println()
+	if !*yes && stats.verified > 0 {
+		fmt.Println("确认无误后加上 --yes 执行删除")
+	}
+	if stats.failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// cleanSourcePath 校验并删除单个被忽略的路径；目录逐个文件校验，删除后移除变空的子目录
+func cleanSourcePath(v *sourceVerifier, srcPath, destPath string, excluder *exclude.Matcher, apply, verbose bool, stats *cleanSourceStats) {
+	dirs := walkIgnoredPath(srcPath, destPath, excluder, func(path, dest string, fi os.FileInfo) {
+		cleanSourceFile(v, path, dest, fi, apply, verbose, stats)
+	})
+
+	// 由深到浅移除已清空的目录（非空目录 os.Remove 会失败，直接忽略）
+	if apply {
+		for i := len(dirs) - 1; i >= 0; i-- {
+			fsguard.Remove(dirs[i], "clean-source: 删除已清空的源目录")
+		}
+	}
+}
+
+// scanIgnoredFiles 扫描搜索根目录下所有仓库中被忽略的文件和目录，按路径排序
+func scanIgnoredFiles(searchRoot string, excluder *exclude.Matcher) ([]scanner.IgnoredFileInfo, error) {
+	fileChan := make(chan scanner.IgnoredFileInfo, cfgpkg.DefaultScanQueueSize)
+	var files []scanner.IgnoredFileInfo
+	collectDone := make(chan struct{})
+	go func() {
+		defer close(collectDone)
+		for file := range fileChan {
+			files = append(files, file)
+		}
+	}()
+	err := scanner.ScanIgnoredFilesWithProgressStream(searchRoot, excluder, nil, fileChan)
+	close(fileChan)
+	<-collectDone
+	sort.Slice(files, func(i, j int) bool { return files[i].AbsPath < files[j].AbsPath })
+	return files, err
+}
+
+// walkIgnoredPath 对被忽略的路径中的每个文件调用 fn（传入源路径、对应的备份路径和文件信息）
+// 被忽略的目录逐个文件展开，跳过排除的子路径；返回遍历到的目录（由浅到深）
+func walkIgnoredPath(srcPath, destPath string, excluder *exclude.Matcher, fn func(src, dest string, info os.FileInfo)) []string {
+	info, err := os.Lstat(srcPath)
+	if err != nil {
+		return nil
+	}
+	if !info.IsDir() {
+		fn(srcPath, destPath, info)
+		return nil
+	}
+
+	var dirs []string
+	filepath.Walk(srcPath, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if excluder.ShouldExclude(path) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		}
+		rel, err := filepath.Rel(srcPath, path)
+		if err != nil {
+			return nil
+		}
+		if cfgpkg.GetGlobalConfig().SanitizeActive {
+			rel = helpers.SanitizePath(rel)
+		}
+		fn(path, filepath.Join(destPath, rel), fi)
+		return nil
+	})
+	return dirs
+}
+
+// cleanSourceFile 校验单个文件在备份中的内容一致后删除
+func cleanSourceFile(v *sourceVerifier, srcPath, destPath string, info os.FileInfo, apply, verbose bool, stats *cleanSourceStats) {
+	if !info.Mode().IsRegular() {
+		return
+	}
+	if reason := v.verify(srcPath, destPath); reason != "" {
+		stats.unverified++
+		if verbose {
+			fmt.Printf("保留: %s（%s）\n", srcPath, reason)
+		}
+		return
+	}
+
+	if !apply {
+		fmt.Printf("可删除: %s\n", srcPath)
+		stats.verified++
+		stats.freed += info.Size()
+		return
+	}
+	if err := fsguard.Remove(srcPath, "clean-source: 备份校验一致，删除源文件"); err != nil {
+		fmt.Fprintf(os.Stderr, "删除失败 %s: %v\n", srcPath, err)
+		stats.failed++
+		return
+	}
+	if verbose {
+		fmt.Printf("已删除: %s\n", srcPath)
+	}
+	if rel, err := filepath.Rel(v.backupRoot, destPath); err == nil {
+		stats.cleaned = append(stats.cleaned, filepath.ToSlash(rel))
+	}
+	stats.verified++
+	stats.freed += info.Size()
+}
+
+// sourceVerifier 校验源文件与备份内容一致
+type sourceVerifier struct {
+	backupRoot string
+	manifest   *manifest.Manifest
+	store      *chunkstore.Store
+}
+
+// verify 返回空字符串表示备份存在且哈希一致，否则返回原因
+func (v *sourceVerifier) verify(srcPath, destPath string) string {
+	if _, err := os.Stat(destPath); err != nil {
+		return "备份中不存在"
+	}
+	srcHash, err := helpers.HashFileWith(srcPath, v.algorithm())
+	if err != nil {
+		return fmt.Sprintf("读取源文件失败: %v", err)
+	}
+	destHash, err := v.destHash(destPath)
+	if err != nil {
+		return fmt.Sprintf("读取备份失败: %v", err)
+	}
+	if srcHash != destHash {
+		return "备份内容与源文件不一致"
+	}
+	return ""
+}
+
+// destHash 读取备份的实际内容计算哈希（分块存储的配方按块还原后计算）
+// 不使用清单中记录的哈希：大小和修改时间不变的损坏或截断无法由清单发现，删除源文件前必须确认备份真正可用
+func (v *sourceVerifier) destHash(destPath string) (string, error) {
+	recipe, err := chunkstore.ReadRecipe(destPath)
+	if err != nil {
+		return "", err
+	}
+	if recipe == nil {
+		return helpers.HashFileWith(destPath, v.algorithm())
+	}
+	h, err := helpers.NewHasher(v.algorithm())
+	if err != nil {
+		return "", err
+	}
+	if err := v.store.Restore(recipe, h); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// algorithm 返回比较使用的哈希算法：与清单一致
+func (v *sourceVerifier) algorithm() string {
+	if v.manifest != nil {
+		return v.manifest.Algorithm
+	}
+	return cfgpkg.HashSHA256
+}
@@ -0,0 +1,41 @@
+package logics
+
+// Command 子命令定义
+type Command struct {
+	Name    string                  // 子命令名称
+	Summary string                  // 一句话说明
+	Run     func(args []string) int // 执行子命令，返回进程退出码
+}
+
+// commands 已注册的子命令（在 init 中注册：copy 的用法说明会列出全部子命令）
+var commands []Command
+
+func init() {
+	commands = []Command{
+		{Name: "copy", Summary: "将被忽略的文件复制到备份根目录（不指定子命令时的默认操作）", Run: RunCopy},
+		{Name: "scan", Summary: "只扫描，列出将被备份的被忽略文件/目录或所在的仓库，不需要备份根目录", Run: RunScan},
+		{Name: "prune", Summary: "按保留数量和时间删除备份历史目录中旧的时间戳目录，不复制", Run: RunPrune},
+		{Name: "check", Summary: "比较多个备份目标的一致性，可选修复", Run: RunCheck},
+		{Name: "du", Summary: "统计各仓库被忽略数据的占用空间，列出最大的文件/目录", Run: RunDu},
+		{Name: "verify", Summary: "校验备份：按清单发现损坏（manifest），或与源文件比较（source、quick）", Run: RunVerify},
+		{Name: "restore", Summary: "将备份中的文件复制回原来的仓库位置，可按模式和仓库选择", Run: RunRestore},
+		{Name: "clean-source", Summary: "删除源仓库中已在备份中校验一致的被忽略文件，释放空间", Run: RunCleanSource},
+		{Name: "repair", Summary: "完成或回滚上次运行中断的移入历史操作", Run: RunRepair},
+		{Name: "history", Summary: "历史目录维护：合并各时间戳目录中内容相同的旧版本（compact）", Run: RunHistory},
+		{Name: "catalog", Summary: "将备份的所有文件、历史版本、大小和哈希导出为 SQLite 数据库（export），供自定义查询", Run: RunCatalog},
+		{Name: "stats", Summary: "根据运行历史输出数据增长、耗时和出错率的趋势", Run: RunStats},
+		{Name: "chunks", Summary: "分块存储维护：回收未引用的块（gc）、还原文件（cat）", Run: RunChunks},
+		{Name: "watch", Summary: "持续监视搜索根目录中的变化并记录到变更日志，定时运行时配合 --skip-unchanged 只扫描有变化的仓库", Run: RunWatch},
+		{Name: "config", Summary: "检查配置（lint）：模式语法、目录可达性与包含关系、取值是否合理，输出生效的配置", Run: RunConfig},
+	}
+}
+
+// LookupCommand 根据名称查找子命令
+func LookupCommand(name string) (Command, bool) {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd, true
+		}
+	}
+	return Command{}, false
+}
@@ -0,0 +1,96 @@
+package logics
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+)
+
+// RunConfig 执行 config 子命令，目前支持 lint：检查配置而不运行
+func RunConfig(args []string) int {
+	if len(args) == 0 || args[0] != "lint" {
+		fmt.Fprintf(os.Stderr, "用法: %s config lint [选项] <搜索根目录>... <备份根目录>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  lint  按复制模式的选项检查配置（模式语法、目录是否可达、目录是否互相包含、取值是否合理），输出生效的配置，不扫描、不复制、不修改任何文件\n")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("config lint", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: %s config lint [选项] <搜索根目录>... <备份根目录>\n\n选项与复制模式相同:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	cfg, err := parseFlags(fs, args[1:])
+	if err != nil {
+		return flagsExitCode(fs, err)
+	}
+	problems := lintConfig(cfg)
+
+	cfg.WriteEffective(os.Stdout, "")
+	fmt.Println()
+	if len(problems) == 0 {
+		fmt.Println("配置检查通过")
+		return 0
+	}
+	fmt.Printf("发现 %d 个问题:\n", len(problems))
+	for _, err := range problems {
+		fmt.Printf("  %v\n", err)
+	}
+	return 1
+}
+
+// lintConfig 检查配置的所有问题（不在第一个问题处停止），不创建备份根目录、不探测、不修改任何文件
+// 检查通过后路径等按实际运行时的方式解析，便于输出生效的配置
+func lintConfig(cfg *cfgpkg.Config) []error {
+	var problems []error
+
+	if err := resolveSearchRoots(cfg); err != nil {
+		problems = append(problems, err)
+	}
+
+	if err := resolveHost(cfg); err != nil {
+		problems = append(problems, err)
+	}
+	if err := checkRootOverlap(cfg); err != nil {
+		problems = append(problems, err)
+	}
+	if err := checkDestReachable(cfg.SharedRoot); err != nil {
+		problems = append(problems, err)
+	}
+	problems = append(problems, validateOptions(cfg)...)
+
+	cfg.SearchRoot = filepath.Clean(cfg.SearchRoot)
+	cfg.BackupRoot = filepath.Clean(cfg.BackupRoot)
+	if cfg.HealFrom != "" {
+		cfg.HealFrom = filepath.Clean(cfg.HealFrom)
+	}
+	return problems
+}
+
+// checkDestReachable 检查备份根目录是否可达：已存在时必须是目录；不存在时运行会创建它，最近的已存在的上级必须是目录
+// （未挂载的网络盘、拔出的移动硬盘通常表现为上级目录不存在）
+func checkDestReachable(dir string) error {
+	info, err := os.Stat(dir)
+	if err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("备份根目录不是目录: %s", dir)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("访问备份根目录失败: %s (%v)", dir, err)
+	}
+	for parent := filepath.Dir(dir); ; parent = filepath.Dir(parent) {
+		if info, err := os.Stat(parent); err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("无法创建备份根目录 %s：%s 不是目录", dir, parent)
+			}
+			return nil
+		}
+		if parent == filepath.Dir(parent) {
+			return fmt.Errorf("备份根目录 %s 不可达：所在的卷或挂载点不存在", dir)
+		}
+	}
+}
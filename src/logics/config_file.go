@@ -0,0 +1,145 @@
+package logics
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// 配置文件中表示位置参数的键：搜索根目录（列表）和备份根目录
+const (
+	configKeyRoots  = "roots"
+	configKeyBackup = "backup"
+)
+
+// loadConfigFile 读取 YAML（.yaml、.yml）或 TOML（.toml）配置文件，键为选项名（不带 --）
+func loadConfigFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("无法识别配置文件格式 %s（扩展名应为 .yaml、.yml 或 .toml）", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+	return values, nil
+}
+
+// applyConfigFile 将配置文件中的取值设置到命令行没有指定的选项上（命令行优先），
+// 命令行没有给出位置参数时返回配置文件中的搜索根目录和备份根目录
+// 列表选项（exclude 等）在命令行指定时整体取代配置文件中的列表，不合并
+func applyConfigFile(fs *flag.FlagSet, path string) (positional []string, err error) {
+	values, err := loadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 命令行指定过的选项（-v 与 --verbose 等简写共用同一取值，指定其一即视为都已指定）
+	var setOnCLI []flag.Value
+	fs.Visit(func(f *flag.Flag) { setOnCLI = append(setOnCLI, f.Value) })
+	onCLI := func(f *flag.Flag) bool {
+		for _, v := range setOnCLI {
+			if v == f.Value {
+				return true
+			}
+		}
+		return false
+	}
+
+	// 按键名排序处理，出错时报告的总是同一个键
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var roots []string
+	var backup string
+	for _, key := range keys {
+		items, err := configValues(values[key])
+		if err != nil {
+			return nil, fmt.Errorf("配置文件 %s 中 %s 的取值无效: %w", path, key, err)
+		}
+		switch key {
+		case configKeyRoots:
+			roots = items
+			continue
+		case configKeyBackup:
+			if len(items) != 1 {
+				return nil, fmt.Errorf("配置文件 %s 中 %s 应为一个目录", path, key)
+			}
+			backup = items[0]
+			continue
+		case "config":
+			return nil, fmt.Errorf("配置文件 %s 中不能再指定 config", path)
+		}
+
+		f := fs.Lookup(key)
+		if f == nil {
+			return nil, fmt.Errorf("配置文件 %s 中有未知的选项 %s", path, key)
+		}
+		if onCLI(f) {
+			continue
+		}
+		// 只有可多次指定的选项接受列表
+		if _, multi := f.Value.(*sliceFlags); !multi && len(items) != 1 {
+			return nil, fmt.Errorf("配置文件 %s 中 %s 只能有一个取值", path, key)
+		}
+		for _, item := range items {
+			if err := fs.Set(key, item); err != nil {
+				return nil, fmt.Errorf("配置文件 %s 中 %s 的取值无效: %w", path, key, err)
+			}
+		}
+	}
+
+	if len(roots) == 0 && backup == "" {
+		return nil, nil
+	}
+	if len(roots) == 0 || backup == "" {
+		return nil, fmt.Errorf("配置文件 %s 需要同时指定 %s 和 %s", path, configKeyRoots, configKeyBackup)
+	}
+	return append(roots, backup), nil
+}
+
+// configValues 将配置文件中的一个取值转换为命令行形式的字符串（列表逐项转换）
+func configValues(value any) ([]string, error) {
+	list, ok := value.([]any)
+	if !ok {
+		list = []any{value}
+	}
+	items := make([]string, 0, len(list))
+	for _, v := range list {
+		switch v := v.(type) {
+		case string:
+			items = append(items, v)
+		case bool:
+			items = append(items, strconv.FormatBool(v))
+		case int:
+			items = append(items, strconv.Itoa(v))
+		case int64:
+			items = append(items, strconv.FormatInt(v, 10))
+		case uint64:
+			items = append(items, strconv.FormatUint(v, 10))
+		case float64:
+			items = append(items, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			return nil, fmt.Errorf("不支持的类型 %T（应为字符串、数字、布尔值或它们的列表）", v)
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,103 @@
+package logics
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// RunCopy 执行 copy 子命令：将被忽略的文件复制到备份根目录（不带子命令运行时同样按此处理）
+func RunCopy(args []string) int {
+	// 记录时间戳对应的时间（入口处统一生成）
+	now := time.Now()
+
+	fs := flag.NewFlagSet("copy", flag.ContinueOnError)
+	fs.Usage = func() { copyUsage(fs) }
+	cfg, err := parseFlags(fs, args)
+	if err != nil {
+		return flagsExitCode(fs, err)
+	}
+
+	// 指定了多个备份目标时，每个目标由独立的子进程复制，本进程只负责协调和汇总
+	if len(cfg.Dests) > 0 && !cfg.FanOutChild {
+		return RunFanOut(cfg, args)
+	}
+
+	// 初始化全局配置
+	cfgpkg.InitGlobalConfig(cfg)
+
+	// 验证参数
+	if err := ValidateConfig(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		// 多目标复制的子进程只报告错误，用法说明不必为每个目标重复输出
+		if !cfg.FanOutChild {
+			fs.Usage()
+		}
+		return 1
+	}
+
+	// --output json：标准输出只留给 JSON 文档，进度、汇总等消息改为输出到标准错误
+	if cfg.Output == cfgpkg.OutputJSON {
+		defer redirectStdout()()
+	}
+
+	// 后台模式：降低 CPU 和 IO 优先级（失败时只提示，照常运行）
+	if cfg.Background {
+		if err := helpers.EnterBackgroundMode(); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+		}
+	}
+
+	// 按配置的格式和时区设置时间戳
+	cfg.Timestamp = helpers.FormatTimestamp(now, cfg.TimestampFormat, cfg.TimestampZone)
+
+	// 初始化排除匹配器
+	excluder, err := exclude.NewMatcher(cfg.Excludes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "初始化排除匹配器失败: %v\n", err)
+		return 1
+	}
+	if cfg.SkipCaches {
+		excluder.SkipCaches()
+	}
+	if !cfg.IgnoreBackupMarkers {
+		excluder.SkipMarkedDirs()
+	}
+	// 位于搜索根目录之内的备份根目录、历史目录、日志等工具自身的产物总是跳过
+	SkipOwnArtifacts(cfg, excluder)
+
+	// 运行主程序逻辑，输出汇总后按结果决定退出码
+	report := Run(excluder)
+	PrintReport(report)
+	if report.Err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", report.Err)
+		return 1
+	}
+	return 0
+}
+
+// copyUsage 输出复制的用法说明，同时列出所有子命令
+func copyUsage(fs *flag.FlagSet) {
+	fmt.Fprintf(os.Stderr, "用法: %s [copy] [选项] <搜索根目录>... <备份根目录>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "      %s <子命令> [选项] ...\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "将 Git 仓库中被忽略的文件复制到指定备份目录，保持目录结构。不指定子命令时执行复制（与 copy 相同）。\n\n")
+	fmt.Fprintf(os.Stderr, "参数:\n")
+	fs.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\n子命令（%s <子命令> -h 查看各自的选项）:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.Name, cmd.Summary)
+	}
+	fmt.Fprintf(os.Stderr, "\n示例:\n")
+	fmt.Fprintf(os.Stderr, "  %s --exclude \"C:\\aaa\\qwe\\\" --exclude \"*\\vendor\" C:\\search D:\\backup\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s --backup-keep 5 --backup-subdir \"old\" C:\\search D:\\backup\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s --bwlimit \"09:00-18:00=5M,0\" C:\\search D:\\backup\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s \"D:\\work\\*\\projects\" D:\\work\\tools D:\\backup\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s --config D:\\backup\\copy-ignore.yaml\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s scan C:\\search\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s prune --keep 10 D:\\backup\n", os.Args[0])
+}
@@ -0,0 +1,72 @@
+package logics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"time"
+)
+
+// debugStats /debug/stats 返回的运行时统计
+type debugStats struct {
+	Uptime     string `json:"uptime"`
+	Goroutines int    `json:"goroutines"`
+	HeapAlloc  uint64 `json:"heap_alloc"`  // 堆上正在使用的字节数
+	HeapInuse  uint64 `json:"heap_inuse"`  // 堆占用的内存（含碎片）
+	Sys        uint64 `json:"sys"`         // 从操作系统获得的内存总量
+	NumGC      uint32 `json:"num_gc"`      // 已完成的 GC 次数
+	PauseTotal string `json:"pause_total"` // GC 暂停的累计时间
+}
+
+// startDebugServer 在 addr 上提供 net/http/pprof 和运行时统计（/debug/stats），用于诊断长时间运行中的卡住和内存增长
+// 监听失败只输出警告，不影响备份；返回关闭服务的函数
+func startDebugServer(addr string) (stop func()) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 调试服务监听 %s 失败，本次运行不提供: %v\n", addr, err)
+		return func() {}
+	}
+
+	// pprof 可以读取命令行和内存内容，只应监听本机地址
+	if host, _, err := net.SplitHostPort(addr); err == nil && host != "localhost" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			fmt.Fprintf(os.Stderr, "警告: 调试服务监听 %s，其他机器也可以访问（包括命令行参数和内存内容），建议使用 127.0.0.1\n", addr)
+		}
+	}
+
+	started := time.Now()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/stats", func(w http.ResponseWriter, r *http.Request) {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(debugStats{
+			Uptime:     time.Since(started).Round(time.Second).String(),
+			Goroutines: runtime.NumGoroutine(),
+			HeapAlloc:  m.HeapAlloc,
+			HeapInuse:  m.HeapInuse,
+			Sys:        m.Sys,
+			NumGC:      m.NumGC,
+			PauseTotal: time.Duration(m.PauseTotalNs).String(),
+		})
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(ln)
+	fmt.Printf("调试服务: http://%s/debug/pprof/（运行时统计: /debug/stats）\n", ln.Addr())
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}
+}
@@ -0,0 +1,194 @@
+package logics
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/layout"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+// dryRunTopN 干运行汇总中列出的仓库数和最大文件数
+const dryRunTopN = 10
+
+// dryRunEntry 干运行汇总中的一项（仓库或文件）
+type dryRunEntry struct {
+	path  string
+	size  int64
+	files int
+}
+
+// dryRunSummary 干运行的流式汇总：扫描结果逐个计入后即丢弃，
+// 内存中只保留计数、各仓库的汇总和最大的若干个文件，与被忽略文件的总数无关
+type dryRunSummary struct {
+	files   int
+	dirs    int
+	size    int64
+	repos   map[string]*dryRunEntry
+	largest []dryRunEntry // 按大小从大到小，最多 dryRunTopN 项
+}
+
+func newDryRunSummary() *dryRunSummary {
+	return &dryRunSummary{repos: make(map[string]*dryRunEntry)}
+}
+
+// add 计入一个扫描结果及其 Lstat 信息（目录整体复制时按一项计，不统计目录内的大小）
+func (s *dryRunSummary) add(file scanner.IgnoredFileInfo, info fs.FileInfo) {
+	var size int64
+	if info != nil {
+		if info.IsDir() {
+			s.dirs++
+		} else {
+			size = info.Size()
+		}
+	}
+	s.files++
+	s.size += size
+
+	repo := s.repos[file.RepoRoot]
+	if repo == nil {
+		repo = &dryRunEntry{path: file.RepoRoot}
+		s.repos[file.RepoRoot] = repo
+	}
+	repo.files++
+	repo.size += size
+
+	if size > 0 && (len(s.largest) < dryRunTopN || size > s.largest[len(s.largest)-1].size) {
+		i := sort.Search(len(s.largest), func(i int) bool { return s.largest[i].size < size })
+		s.largest = append(s.largest, dryRunEntry{})
+		copy(s.largest[i+1:], s.largest[i:])
+		s.largest[i] = dryRunEntry{path: file.RelativePath, size: size, files: 1}
+		if len(s.largest) > dryRunTopN {
+			s.largest = s.largest[:dryRunTopN]
+		}
+	}
+}
+
+// print 输出汇总：总数、文件最多的仓库和最大的文件
+func (s *dryRunSummary) print(searchRoot string) {
+	fmt.Printf("找到 %d 个需要处理的被忽略文件", s.files)
+	if s.dirs > 0 {
+		fmt.Printf("（其中 %d 个目录整体复制）", s.dirs)
+	}
+	fmt.Printf("，共 %s，分布在 %d 个仓库\n", helpers.FormatSize(s.size), len(s.repos))
+	if s.files == 0 {
+		return
+	}
+
+	repos := make([]dryRunEntry, 0, len(s.repos))
+	for _, repo := range s.repos {
+		repos = append(repos, *repo)
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		if repos[i].files != repos[j].files {
+			return repos[i].files > repos[j].files
+		}
+		return repos[i].path < repos[j].path
+	})
+	if len(repos) > dryRunTopN {
+		repos = repos[:dryRunTopN]
+	}
+	fmt.Println("文件最多的仓库:")
+	for _, repo := range repos {
+		display := repo.path
+		if rel, err := filepath.Rel(searchRoot, repo.path); err == nil {
+			display = rel
+		}
+		fmt.Printf("  %8d 个  %10s  %s\n", repo.files, helpers.FormatSize(repo.size), display)
+	}
+
+	if len(s.largest) > 0 {
+		fmt.Println("最大的文件:")
+		for _, file := range s.largest {
+			fmt.Printf("  %10s  %s\n", helpers.FormatSize(file.size), file.path)
+		}
+	}
+}
+
+// printTruncatedRepos 列出被忽略的条目超过 --max-files-per-repo、只处理了一部分的仓库
+func printTruncatedRepos(repos []string, limit int) {
+	if len(repos) == 0 {
+		return
+	}
+	fmt.Printf("警告: %d 个仓库被忽略的条目超过 %d 个，只处理了前 %d 个，这些仓库的备份不完整且未清理（检查是否有失控的缓存目录，或用 --exclude 排除）:\n", len(repos), limit, limit)
+	for _, repo := range repos {
+		fmt.Printf("  %s\n", repo)
+	}
+}
+
+// printDirtyGitignoreRepos 列出因 .gitignore 有未提交修改而跳过的仓库（--dirty-gitignore skip）
+func printDirtyGitignoreRepos(repos []string) {
+	if len(repos) == 0 {
+		return
+	}
+	fmt.Printf("已跳过 %d 个 .gitignore 有未提交修改的仓库，这些仓库的备份未更新也未清理（提交或撤销修改后再运行）:\n", len(repos))
+	for _, repo := range repos {
+		fmt.Printf("  %s\n", repo)
+	}
+}
+
+// printUnchangedRepos 输出因自上次运行以来没有变化而跳过的仓库数，详细模式下逐个列出
+func printUnchangedRepos(repos []string, verbose bool) {
+	if len(repos) == 0 {
+		return
+	}
+	fmt.Printf("已跳过 %d 个自上次运行以来没有变化的仓库（--skip-unchanged），这些仓库的备份保持不变\n", len(repos))
+	if verbose {
+		for _, repo := range repos {
+			fmt.Printf("  %s\n", repo)
+		}
+	}
+}
+
+// cleanupPreview 按扫描结果逐个计算目标路径，扫描结束后预演清理阶段（不复制、不移动任何文件）
+// 只保留清理判断需要的目标路径，不保留完整的扫描结果
+type cleanupPreview struct {
+	cfg         *cfgpkg.Config
+	mapper      *layout.Mapper
+	targetPaths map[string]string
+	scopes      []string
+	seenRepos   map[string]bool
+}
+
+func newCleanupPreview(cfg *cfgpkg.Config) (*cleanupPreview, error) {
+	mapper, err := layout.Load(cfg.BackupRoot, cfg.Layout)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SanitizeActive {
+		mapper.SanitizeNames()
+	}
+	return &cleanupPreview{
+		cfg:         cfg,
+		mapper:      mapper,
+		targetPaths: make(map[string]string),
+		seenRepos:   make(map[string]bool),
+	}, nil
+}
+
+// add 记录一个扫描结果对应的目标路径
+func (p *cleanupPreview) add(file scanner.IgnoredFileInfo) {
+	if file.RepoRoot != "" && !p.seenRepos[file.RepoRoot] {
+		p.seenRepos[file.RepoRoot] = true
+		p.scopes = append(p.scopes, filepath.Join(p.cfg.BackupRoot, p.mapper.RepoSubtree(file.RepoRoot, p.cfg.SearchRoot)))
+	}
+	rel := p.mapper.Resolve(file)
+	destPath := filepath.Join(p.cfg.BackupRoot, rel)
+	if helpers.DestPathTooLong(destPath, p.cfg.MaxPathLen) {
+		destPath = helpers.LongPathTarget(p.cfg.BackupRoot, rel)
+	}
+	p.targetPaths[destPath] = file.AbsPath
+}
+
+// run 预演清理阶段，返回清理预演的统计
+func (p *cleanupPreview) run() helpers.CleanupStats {
+	// 达到 --max-files-per-repo 上限的仓库扫描结果不完整，不在其中清理
+	p.scopes = p.mapper.DropRepoScopes(p.scopes, scanner.TruncatedRepos(), p.cfg.BackupRoot, p.cfg.SearchRoot)
+	return helpers.CleanupDeletedSrcFiles(p.targetPaths, p.scopes, func(rel string) string {
+		return p.mapper.Source(rel, p.cfg.SearchRoot)
+	})
+}
@@ -0,0 +1,148 @@
+package logics
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+// duItem 占用统计的一项（仓库或被忽略的文件/目录）
+type duItem struct {
+	path  string
+	size  int64
+	files int
+}
+
+// RunDu 执行 du 子命令：不复制，统计各仓库及各被忽略文件/目录的占用空间
+func RunDu(args []string) int {
+	fs := flag.NewFlagSet("du", flag.ExitOnError)
+	var excludes sliceFlags
+	fs.Var(&excludes, "exclude", "排除模式（支持多次），用于预估添加排除规则后的效果")
+	top := fs.Int("top", 20, "列出占用最大的被忽略文件/目录的数量")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: %s du [选项] <搜索根目录>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "统计被忽略文件的占用空间（按仓库汇总，并列出最大的文件/目录），不复制任何文件。\n\n")
+		fmt.Fprintf(os.Stderr, "参数:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	searchRoot := absRoot(fs.Arg(0))
+	cfgpkg.InitGlobalConfig(&cfgpkg.Config{SearchRoot: searchRoot, Excludes: excludes})
+	excluder, err := exclude.NewMatcher(excludes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "初始化排除匹配器失败: %v\n", err)
+		return 1
+	}
+
+	fileChan := make(chan scanner.IgnoredFileInfo, cfgpkg.DefaultScanQueueSize)
+	repos := make(map[string]*duItem)
+	var entries []duItem
+	var total duItem
+	collectDone := make(chan struct{})
+	go func() {
+		defer close(collectDone)
+		for file := range fileChan {
+			size, files := pathUsage(file.AbsPath, excluder)
+			entries = append(entries, duItem{path: file.AbsPath, size: size, files: files})
+
+			repo := repos[file.RepoRoot]
+			if repo == nil {
+				repo = &duItem{path: file.RepoRoot}
+				repos[file.RepoRoot] = repo
+			}
+			repo.size += size
+			repo.files += files
+			total.size += size
+			total.files += files
+		}
+	}()
+	scanErr := scanner.ScanIgnoredFilesWithProgressStream(searchRoot, excluder, nil, fileChan)
+	close(fileChan)
+	<-collectDone
+	if scanErr != nil {
+		fmt.Fprintf(os.Stderr, "扫描失败: %v\n", scanErr)
+		return 1
+	}
+
+	repoList := make([]duItem, 0, len(repos))
+	for _, repo := range repos {
+		repoList = append(repoList, *repo)
+	}
+	sortUsage(repoList)
+	sortUsage(entries)
+
+	fmt.Printf("\n被忽略的数据共 %s（%d 个文件，%d 个仓库）\n", helpers.FormatSize(total.size), total.files, len(repoList))
+
+	fmt.Printf("\n按仓库:\n")
+	for _, repo := range repoList {
+		printUsage(repo, searchRoot, total.size)
+	}
+
+	if *top > 0 && len(entries) > *top {
+		entries = entries[:*top]
+	}
+	fmt.Printf("\n最大的被忽略文件/目录:\n")
+	for _, entry := range entries {
+		printUsage(entry, searchRoot, total.size)
+	}
+	return 0
+}
+
+// pathUsage 统计路径（文件或目录）的总大小和文件数，跳过排除规则匹配的子路径
+func pathUsage(path string, excluder *exclude.Matcher) (int64, int) {
+	var size int64
+	var files int
+	filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if p != path && excluder.ShouldExclude(p) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+			files++
+		}
+		return nil
+	})
+	return size, files
+}
+
+// sortUsage 按占用从大到小排序
+func sortUsage(items []duItem) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].size != items[j].size {
+			return items[i].size > items[j].size
+		}
+		return items[i].path < items[j].path
+	})
+}
+
+// printUsage 输出一行占用统计（路径显示为相对搜索根目录）
+func printUsage(item duItem, searchRoot string, total int64) {
+	display := item.path
+	if rel, err := filepath.Rel(searchRoot, item.path); err == nil {
+		display = rel
+	}
+	percent := 0.0
+	if total > 0 {
+		percent = float64(item.size) * 100 / float64(total)
+	}
+	fmt.Printf("  %10s %5.1f%% %8d 个文件  %s\n", helpers.FormatSize(item.size), percent, item.files, display)
+}
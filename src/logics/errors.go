@@ -0,0 +1,11 @@
+package logics
+
+import "errors"
+
+// 运行失败的类别：ValidateConfig 返回的错误和 RunReport.Err 包装了其中之一，可以用 errors.Is 区分
+var (
+	ErrValidation  = errors.New("参数错误")
+	ErrDestination = errors.New("备份目标检查失败")
+	ErrScan        = errors.New("扫描失败")
+	ErrCopy        = errors.New("复制失败")
+)
@@ -0,0 +1,134 @@
+package logics
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/copy"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+// etaMinFraction 完成比例低于该值时按上次运行的总耗时估计剩余时间（此时按比例外推误差太大）
+const etaMinFraction = 0.05
+
+// runTimings 上次运行的总耗时和各仓库的扫描、复制耗时，
+// 用于下次运行开始时估计总耗时，并在复制速率稳定之前给出较准确的剩余时间
+type runTimings struct {
+	Wall  float64               `json:"wall"`  // 总耗时（秒）
+	Repos map[string]repoTiming `json:"repos"` // 仓库根目录 -> 耗时
+}
+
+// repoTiming 单个仓库的耗时记录
+type repoTiming struct {
+	Scan  float64 `json:"scan"`  // 扫描（查询被忽略的文件）耗时（秒）
+	Copy  float64 `json:"copy"`  // 复制耗时（秒，各并发任务之和）
+	Files int     `json:"files"` // 处理的文件数
+}
+
+// loadRunTimings 读取上次运行的耗时记录，不存在或损坏时返回 nil
+func loadRunTimings(backupRoot string) *runTimings {
+	data, err := os.ReadFile(filepath.Join(backupRoot, cfgpkg.RunTimingsFileName))
+	if err != nil {
+		return nil
+	}
+	var t runTimings
+	if err := json.Unmarshal(data, &t); err != nil || t.Wall <= 0 {
+		return nil
+	}
+	return &t
+}
+
+// saveRunTimings 记录本次运行的总耗时和各仓库的耗时
+func saveRunTimings(backupRoot string, wall time.Duration) {
+	t := runTimings{Wall: wall.Seconds(), Repos: make(map[string]repoTiming)}
+	for repo, d := range scanner.RepoScanTimes() {
+		t.Repos[repo] = repoTiming{Scan: d.Seconds()}
+	}
+	for repo, c := range copy.Timer.Snapshot() {
+		r := t.Repos[repo]
+		r.Copy, r.Files = c.Copy.Seconds(), c.Files
+		t.Repos[repo] = r
+	}
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err == nil {
+		err = fsguard.WriteFile(filepath.Join(backupRoot, cfgpkg.RunTimingsFileName), data, 0644, "记录各仓库的耗时（用于估计剩余时间）")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 记录运行耗时失败: %v\n", err)
+	}
+}
+
+// etaEstimator 结合上次运行各仓库的耗时和本次的实际进度估计剩余时间
+type etaEstimator struct {
+	started time.Time
+	prev    *runTimings // 上次运行的耗时记录，nil 表示没有
+	weight  float64     // 上次运行各仓库耗时之和
+}
+
+func newETAEstimator(prev *runTimings, started time.Time) *etaEstimator {
+	e := &etaEstimator{started: started, prev: prev}
+	if prev != nil {
+		for _, r := range prev.Repos {
+			e.weight += r.Scan + r.Copy
+		}
+	}
+	return e
+}
+
+// expected 返回按上次运行估计的总耗时，没有记录时返回 0
+func (e *etaEstimator) expected() time.Duration {
+	if e.prev == nil {
+		return 0
+	}
+	return time.Duration(e.prev.Wall * float64(time.Second))
+}
+
+// remaining 估计剩余时间，无法估计时返回 false
+// 完成比例按上次运行各仓库的耗时加权：已扫描的仓库计入其扫描耗时，复制耗时按已处理文件数占上次文件数的比例计入；
+// 扫描结束、本次的文件总数确定后，随实际完成比例的增长逐渐改用实际比例
+func (e *etaEstimator) remaining(done, total int, scanDone bool) (time.Duration, bool) {
+	elapsed := time.Since(e.started)
+	fraction, known := 0.0, false
+
+	if e.weight > 0 {
+		scanned := scanner.RepoScanTimes()
+		progress := copy.Timer.Snapshot()
+		var doneWeight float64
+		for repo, r := range e.prev.Repos {
+			if _, ok := scanned[repo]; ok {
+				doneWeight += r.Scan
+			}
+			if r.Files > 0 {
+				doneWeight += r.Copy * math.Min(1, float64(progress[repo].Files)/float64(r.Files))
+			}
+		}
+		fraction, known = doneWeight/e.weight, true
+	}
+	if scanDone && total > 0 {
+		actual := float64(done) / float64(total)
+		if known {
+			fraction = fraction*(1-actual) + actual*actual
+		} else {
+			fraction, known = actual, true
+		}
+	}
+
+	switch {
+	case !known:
+		return 0, false
+	case fraction >= 1:
+		return 0, true
+	case fraction < etaMinFraction:
+		if e.prev == nil {
+			return 0, false
+		}
+		return max(e.expected()-elapsed, 0), true
+	}
+	return time.Duration(float64(elapsed) * (1 - fraction) / fraction), true
+}
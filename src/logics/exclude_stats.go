@@ -0,0 +1,38 @@
+package logics
+
+import (
+	"fmt"
+
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// printExcludeStats 输出各条排除规则在本次扫描中排除的路径数，用于发现作用最大的规则和写错的规则
+func printExcludeStats(stats []exclude.PatternStat) {
+	if len(stats) == 0 {
+		return
+	}
+	width := 0
+	for _, s := range stats {
+		if w := ui.Width(s.Pattern); w > width {
+			width = w
+		}
+	}
+	fmt.Println("排除规则统计:")
+	for _, s := range stats {
+		fmt.Printf("  %s%*s  %d 个\n", s.Pattern, width-ui.Width(s.Pattern), "", s.Count)
+	}
+	printExcludeWarnings(stats)
+}
+
+// printExcludeWarnings 提示没有匹配任何路径的排除规则（很可能写错了）和被前面更宽的规则覆盖的多余规则
+func printExcludeWarnings(stats []exclude.PatternStat) {
+	for _, s := range stats {
+		switch {
+		case s.Unused():
+			fmt.Printf("警告: 排除规则 %q 没有匹配任何路径，检查是否写错\n", s.Pattern)
+		case s.Redundant():
+			fmt.Printf("警告: 排除规则 %q 匹配的 %d 个路径都已被前面更宽的规则 %q 排除，可以删除\n", s.Pattern, s.Shadowed, s.ShadowedBy)
+		}
+	}
+}
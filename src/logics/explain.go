@@ -0,0 +1,36 @@
+package logics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aogg/copy-ignore/src/git"
+	"github.com/aogg/copy-ignore/src/scanner"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// explainIncluded 输出干运行列出的文件及原因：所在仓库、使其被忽略的 git 规则；目录说明为整体复制（--explain）
+func explainIncluded(file scanner.IgnoredFileInfo) {
+	rule, err := git.IgnoreSource(file.RepoRoot, file.AbsPath)
+	switch {
+	case err != nil:
+		rule = fmt.Sprintf("（无法确定规则: %v）", err)
+	case rule == "":
+		rule = "（无法确定规则）"
+	}
+	note := ""
+	if info, err := os.Lstat(file.AbsPath); err == nil && info.IsDir() {
+		note = "；被忽略的目录，整体复制，其中的文件不再单独列出"
+	}
+	ui.Printf("  + %s\n      仓库: %s；被 git 忽略: %s%s\n", file.RelativePath, file.RepoRoot, rule, note)
+}
+
+// explainSkipped 输出扫描时被过滤掉的候选路径及原因（--explain）
+func explainSkipped(searchRoot string, ev scanner.SkipEvent) {
+	rel, err := filepath.Rel(searchRoot, ev.Path)
+	if err != nil {
+		rel = ev.Path
+	}
+	ui.Printf("  - %s\n      %s\n", rel, ev.Reason)
+}
@@ -0,0 +1,364 @@
+package logics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// fanOutEnv 多目标复制时传给子进程的环境变量（JSON），子进程只复制到其中指定的备份目标
+const fanOutEnv = "COPY_IGNORE_FANOUT"
+
+// fanOutChild 协调进程传给子进程的设置
+type fanOutChild struct {
+	Dest        string `json:"dest"`        // 子进程复制到的备份目标
+	Interactive bool   `json:"interactive"` // 协调进程是否在交互式终端中运行（子进程的输出经由管道，无法自行判断）
+}
+
+// fanOutChildEnv 读取协调进程传来的设置，不是多目标复制的子进程时返回 nil
+func fanOutChildEnv() *fanOutChild {
+	value := os.Getenv(fanOutEnv)
+	if value == "" {
+		return nil
+	}
+	var child fanOutChild
+	if err := json.Unmarshal([]byte(value), &child); err != nil || child.Dest == "" {
+		return nil
+	}
+	return &child
+}
+
+// fanOutResult 一个备份目标的复制结果
+type fanOutResult struct {
+	Dest     string
+	ExitCode int        // 最后一次尝试的退出码，无法启动子进程时为 -1
+	Attempts int        // 尝试次数（含重试）
+	Err      error      // 无法启动子进程等协调进程自身的错误
+	Run      *RunRecord // 最后一次尝试写入的运行摘要，没有写入时为 nil
+	Duration time.Duration
+}
+
+// Success 该目标是否复制成功
+func (r *fanOutResult) Success() bool {
+	return r.Err == nil && r.ExitCode == 0
+}
+
+// fanOutProcs 正在运行的子进程，用于转发信号和标准输入
+type fanOutProcs struct {
+	mu       sync.Mutex
+	procs    map[*exec.Cmd]io.Writer
+	stopping bool // 收到退出信号后不再重试
+}
+
+// RunFanOut 复制到多个备份目标（--dest）：每个目标由独立的子进程复制，各自有复制队列、出错统计、
+// 清理和运行摘要，一个目标出错或缓慢（如网络共享）不会阻塞或影响其他目标；失败的目标按 --dest-retries 单独重试
+// args 为原样传给子进程的命令行参数；全部目标都成功时返回 0
+func RunFanOut(cfg *cfgpkg.Config, args []string) int {
+	if err := checkFanOut(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", ErrValidation, err)
+		return 1
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "无法确定程序路径: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("同时复制到 %d 个备份目标（每个目标独立运行，失败时单独重试 %d 次）:\n", len(cfg.Dests), cfg.DestRetries)
+	for _, dest := range cfg.Dests {
+		fmt.Printf("  %s\n", dest)
+	}
+
+	procs := &fanOutProcs{procs: make(map[*exec.Cmd]io.Writer)}
+	stopForwarding := procs.forward()
+	defer stopForwarding()
+
+	var outMu sync.Mutex
+	results := make([]fanOutResult, len(cfg.Dests))
+	var wg sync.WaitGroup
+	for i, dest := range cfg.Dests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			prefix := fmt.Sprintf("[%s] ", dest)
+			stdout := &prefixWriter{mu: &outMu, w: os.Stdout, prefix: prefix}
+			stderr := &prefixWriter{mu: &outMu, w: os.Stderr, prefix: prefix}
+			results[i] = runFanOutDest(cfg, exe, args, dest, procs, stdout, stderr)
+		}()
+	}
+	wg.Wait()
+
+	printFanOutResults(results)
+	for _, r := range results {
+		if !r.Success() {
+			return 1
+		}
+	}
+	return 0
+}
+
+// checkFanOut 在启动子进程前检查各目标共同的配置（选项取值、目录包含关系），避免每个子进程重复报告同样的错误
+// 目标是否可达由各子进程检查，不可达的目标单独失败和重试
+func checkFanOut(cfg *cfgpkg.Config) error {
+	if err := normalizeRoots(cfg); err != nil {
+		return err
+	}
+	if err := resolveSearchRoots(cfg); err != nil {
+		return err
+	}
+	if err := resolveHost(cfg); err != nil {
+		return err
+	}
+	if err := checkRootOverlap(cfg); err != nil {
+		return err
+	}
+	if errs := validateOptions(cfg); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// runFanOutDest 在子进程中复制到一个备份目标，失败时等待 --dest-retry-delay 后重试
+func runFanOutDest(cfg *cfgpkg.Config, exe string, args []string, dest string, procs *fanOutProcs, stdout, stderr *prefixWriter) (result fanOutResult) {
+	env, _ := json.Marshal(fanOutChild{Dest: dest, Interactive: ui.Interactive()})
+	result.Dest = dest
+	started := time.Now()
+	defer func() { result.Duration = time.Since(started) }()
+
+	// 子进程写入的运行摘要（与子进程按同样的方式确定位置）
+	destCfg := *cfg
+	destCfg.BackupRoot = dest
+	summaryPath := ""
+	if resolveHost(&destCfg) == nil {
+		summaryPath = lastRunPath(&destCfg)
+	}
+
+	for {
+		result.Attempts++
+		attemptStarted := time.Now()
+		cmd := exec.Command(exe, args...)
+		cmd.Env = append(os.Environ(), fanOutEnv+"="+string(env))
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+		result.ExitCode, result.Err = procs.run(cmd)
+		stdout.Flush()
+		stderr.Flush()
+		result.Run = readFanOutRun(summaryPath, attemptStarted)
+
+		if result.Success() || result.Attempts > cfg.DestRetries || procs.isStopping() {
+			return result
+		}
+		reason := fmt.Sprintf("退出码 %d", result.ExitCode)
+		if result.Err != nil {
+			reason = result.Err.Error()
+		}
+		stderr.Printf("复制失败（%s），%s 后重试（第 %d/%d 次）\n", reason, cfg.DestRetryDelay, result.Attempts, cfg.DestRetries)
+		time.Sleep(cfg.DestRetryDelay)
+		if procs.isStopping() {
+			return result
+		}
+	}
+}
+
+// readFanOutRun 读取子进程本次写入的运行摘要；没有写入（如参数错误、只追加模式）或是更早的运行留下的时返回 nil
+func readFanOutRun(path string, since time.Time) *RunRecord {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var run RunRecord
+	if err := json.Unmarshal(data, &run); err != nil || run.StartedAt.Before(since) {
+		return nil
+	}
+	return &run
+}
+
+// run 启动子进程并等待其结束，返回退出码
+func (p *fanOutProcs) run(cmd *exec.Cmd) (int, error) {
+	// 只有 Windows 上的子进程读取标准输入（暂停/继续的键盘命令），其他系统上不连接，转发的输入不会因无人读取而阻塞
+	var stdin io.Writer = io.Discard
+	if runtime.GOOS == "windows" {
+		pipe, err := cmd.StdinPipe()
+		if err != nil {
+			return -1, err
+		}
+		stdin = pipe
+	}
+	p.mu.Lock()
+	if p.stopping {
+		p.mu.Unlock()
+		return -1, errors.New("已收到退出信号")
+	}
+	if err := cmd.Start(); err != nil {
+		p.mu.Unlock()
+		return -1, fmt.Errorf("启动子进程失败: %w", err)
+	}
+	p.procs[cmd] = stdin
+	p.mu.Unlock()
+
+	err := cmd.Wait()
+	p.mu.Lock()
+	delete(p.procs, cmd)
+	p.mu.Unlock()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// isStopping 是否已收到退出信号
+func (p *fanOutProcs) isStopping() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stopping
+}
+
+// forward 将收到的信号（见 fanOutSignals）转发给所有子进程，标准输入的每一行（Windows 上暂停/继续的键盘命令）发给所有子进程
+// 收到中断或退出信号后不再重试失败的目标；返回停止转发的函数
+func (p *fanOutProcs) forward() func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, fanOutSignals...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				p.mu.Lock()
+				if sig == os.Interrupt || sig == syscall.SIGTERM {
+					p.stopping = true
+				}
+				for cmd := range p.procs {
+					cmd.Process.Signal(sig)
+				}
+				p.mu.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// 读取标准输入无法中断，停止后读取协程只是不再转发，随进程退出
+	if runtime.GOOS == "windows" {
+		go func() {
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				line := append(scanner.Bytes(), '\n')
+				p.mu.Lock()
+				for _, stdin := range p.procs {
+					stdin.Write(line)
+				}
+				p.mu.Unlock()
+			}
+		}()
+	}
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// printFanOutResults 输出各备份目标的复制结果
+func printFanOutResults(results []fanOutResult) {
+	width := 0
+	for _, r := range results {
+		width = max(width, len(r.Dest))
+	}
+	fmt.Println()
+	fmt.Println("各备份目标的复制结果:")
+	for _, r := range results {
+		status := "成功"
+		if !r.Success() {
+			status = "失败"
+		}
+		line := fmt.Sprintf("  %s  %-*s  用时 %.1f 秒", status, width, r.Dest, r.Duration.Seconds())
+		if r.Attempts > 1 {
+			line += fmt.Sprintf("，共尝试 %d 次", r.Attempts)
+		}
+		if r.Run != nil {
+			t := r.Run.Totals
+			line += fmt.Sprintf("，复制 %d 个（%s），跳过 %d 个，出错 %d 个", t.Copied, helpers.FormatSize(t.CopiedBytes), t.Skipped, t.Errors)
+		}
+		switch {
+		case r.Err != nil:
+			line += fmt.Sprintf("：%v", r.Err)
+		case r.ExitCode != 0 && r.Run != nil && r.Run.FatalError != "":
+			line += fmt.Sprintf("：%s", r.Run.FatalError)
+		case r.ExitCode != 0:
+			line += fmt.Sprintf("：退出码 %d", r.ExitCode)
+		}
+		fmt.Println(line)
+	}
+}
+
+// prefixWriter 按行转发子进程的输出并在行首加上备份目标，多个子进程的输出不会交错在同一行
+// 子进程的状态行以 \r 原地刷新，只保留每行最终的内容
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		p.writeLine(p.buf[:i])
+		p.buf = p.buf[i+1:]
+	}
+	// 尚未换行的部分只保留最后一次刷新的状态行，长时间没有消息时不会不断累积
+	if i := bytes.LastIndexByte(p.buf, '\r'); i > 0 {
+		p.buf = p.buf[i:]
+	}
+	return len(b), nil
+}
+
+// Printf 输出协调进程自身关于该目标的消息
+func (p *prefixWriter) Printf(format string, args ...any) {
+	p.writeLine([]byte(fmt.Sprintf(format, args...)))
+}
+
+// Flush 输出末尾没有换行的内容
+func (p *prefixWriter) Flush() {
+	p.writeLine(p.buf)
+	p.buf = nil
+}
+
+// writeLine 输出一行（去掉被 \r 覆盖的部分和末尾的空白，空行不输出）
+func (p *prefixWriter) writeLine(line []byte) {
+	if i := bytes.LastIndexByte(line, '\r'); i >= 0 {
+		line = line[i+1:]
+	}
+	line = bytes.TrimRight(line, " \t\r\n")
+	if len(line) == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.w, "%s%s\n", p.prefix, line)
+}
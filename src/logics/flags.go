@@ -1,12 +1,22 @@
 package logics
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/layout"
+	"github.com/aogg/copy-ignore/src/snapshot"
+	"github.com/aogg/copy-ignore/src/ui"
 )
 
 // sliceFlags 用于支持多个相同名称的标志
@@ -21,65 +31,277 @@ func (s *sliceFlags) Set(value string) error {
 	return nil
 }
 
-// ParseFlags 解析命令行标志
-func ParseFlags() *cfgpkg.Config {
-	var excludes sliceFlags
+// sizeFlag 支持 K/M/G 单位的字节数标志
+type sizeFlag int64
 
-	flag.Var(&excludes, "exclude", "排除模式（支持多次，可为绝对路径或通配符）")
-	dryRun := flag.Bool("dry-run", false, "仅显示要复制的文件，不实际复制")
-	concurrency := flag.Int("concurrency", 8, "并行复制的并发数")
-	verbose := flag.Bool("verbose", false, "显示详细输出")
-	flag.BoolVar(verbose, "v", false, "显示详细输出（简写）")
-	backupKeep := flag.Int("backup-keep", 3, "每个备份目录保留的最近备份数")
-	historySubDir := flag.String("history-subdir", "copy-ignore备份", "在备份目录下创建的子目录名称")
-	historyDir := flag.String("history-dir", "", "备份历史文件夹")
+func (s *sizeFlag) String() string {
+	return fmt.Sprintf("%d", int64(*s))
+}
 
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "用法: %s [选项] <搜索根目录> <备份根目录>\n\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "将 Git 仓库中被忽略的文件复制到指定备份目录，保持目录结构。\n\n")
-		fmt.Fprintf(os.Stderr, "参数:\n")
-		flag.PrintDefaults()
-		fmt.Fprintf(os.Stderr, "\n示例:\n")
-		fmt.Fprintf(os.Stderr, "  %s --exclude \"C:\\aaa\\qwe\\\" --exclude \"*\\vendor\" C:\\search D:\\backup\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "  %s --backup-keep 5 --backup-subdir \"old\" C:\\search D:\\backup\n", os.Args[0])
+func (s *sizeFlag) Set(value string) error {
+	n, err := helpers.ParseSize(value)
+	if err != nil {
+		return err
 	}
+	*s = sizeFlag(n)
+	return nil
+}
+
+// errMissingRoots 命令行和配置文件都没有给出搜索根目录和备份根目录
+var errMissingRoots = errors.New("缺少搜索根目录或备份根目录")
 
-	flag.Parse()
+// parseFlags 用指定的标志集解析参数（供复制模式和 config lint 等子命令共用），用法说明由调用方设置（fs.Usage）
+// 不退出进程：参数有误时返回错误，由调用方通过 flagsExitCode 输出错误并决定退出码
+func parseFlags(fs *flag.FlagSet, args []string) (*cfgpkg.Config, error) {
+	var excludes sliceFlags
+	var protects sliceFlags
+	var syncs sliceFlags
+	var priorities sliceFlags
+	var extraDests sliceFlags
+
+	fs.Var(&excludes, "exclude", "排除模式（支持多次，可为绝对路径或通配符）")
+	fs.Var(&syncs, "sync", "双向同步的文件模式（支持多次，如 .env），备份比源文件新时取回到源位置")
+	fs.Var(&priorities, "priority", "优先复制的文件模式（支持多次，如 \"**/.env*\"），匹配的文件先于其他文件复制，运行中途被打断时最重要的数据已经备份")
+	fs.Var(&protects, "protect", "清理阶段永不处理的备份目标路径（支持多次，可为绝对路径或相对备份根目录的通配符）")
+	noOverwrite := fs.Bool("no-overwrite", false, "不覆盖、不删除已有的目标文件，源文件的新版本直接写入历史目录")
+	appendOnly := fs.Bool("append-only", false, "只追加模式（适用于 WORM 共享等不可变目标）：在 --no-overwrite 基础上也不改写清单等记录文件")
+	dryRun := fs.Bool("dry-run", false, "仅显示要复制的文件，不实际复制")
+	explain := fs.Bool("explain", false, "与 --dry-run 一起使用：为每个列出的文件说明原因（所在仓库、使其被忽略的 git 规则），并列出被过滤的候选路径及原因（排除规则、已包含在被忽略的目录中）")
+	output := fs.String("output", cfgpkg.OutputText, "与 --dry-run 一起使用：结果的输出格式，text 或 json（标准输出只有一个包含所有条目的 JSON 文档，供其他工具处理；其余消息输出到标准错误）")
+	printConfig := fs.Bool("print-config", false, "开始运行前输出解析后生效的完整配置（默认值、归一化后的路径、--per-host 展开后的目录等），排查扫描了错误的目录等问题")
+	deleteDryRun := fs.Bool("delete-dry-run", false, "清理预演：列出清理阶段将移入历史的文件及原因，不移动任何文件")
+	filteredPolicy := fs.String("filtered-policy", cfgpkg.FilteredKeep, "源文件仍在、仅因排除规则不再复制的文件在清理阶段的处理：keep 保留备份，history 移入历史目录")
+	deleteReport := fs.String("delete-report", "copy-ignore-cleanup-report.txt", "清理预演报告的写入路径（空字符串表示只输出到屏幕）")
+	concurrency := fs.Int("concurrency", 8, "并行复制的并发数")
+	adaptive := fs.Bool("adaptive-concurrency", false, "根据目标端延迟和错误率自动调整并发数（以 --concurrency 为初始值）")
+	maxConcurrency := fs.Int("max-concurrency", 32, "自适应并发的上限")
+	debugAddr := fs.String("debug-addr", "", "在该地址提供 pprof 和运行时统计（如 127.0.0.1:6060），用于诊断长时间运行中的卡住和内存增长")
+	stallTimeout := fs.Duration("stall-timeout", 10*time.Minute, "超过该时间没有任何进展（扫描、复制都停住，如 SMB 写入挂起、git 进程无响应）时警告，列出各复制协程正在处理的文件（0 表示不检测）")
+	stallAbort := fs.Bool("stall-abort", false, "卡住超过 --stall-timeout 时放弃正在复制的文件（记为出错），继续处理其余文件")
+	fileTimeout := fs.Duration("file-timeout", 0, "单个文件操作（获取信息、打开、单次读写、同步、重命名）的超时，如 30s；不稳定的网络共享上挂起的操作记为该文件出错（下次运行重试），继续处理其余文件（0 表示不限制）")
+	progressInterval := fs.Duration("progress-interval", ui.DefaultInterval, "终端状态行（当前扫描的目录、复制进度）的刷新间隔，如 200ms、2s")
+	verbose := fs.Bool("verbose", false, "显示详细输出")
+	fs.BoolVar(verbose, "v", false, "显示详细输出（简写）")
+	backupKeep := fs.Int("backup-keep", 3, "每个备份目录保留的最近备份数")
+	keepDryRun := fs.Bool("keep-dry-run", false, "轮换预演：只列出超出 --backup-keep、将被删除的旧版本（按路径）及其大小，不删除")
+	yes := fs.Bool("yes", false, "确认轮换删除超出 --backup-keep 的旧版本（在交互式终端中运行时需要，否则只列出）")
+	overwrite := fs.String("overwrite", string(cfgpkg.OverwriteHistory), "更新已有的目标文件时旧版本的去处：history 移入历史目录，suffix-rename 在原位置重命名为 <文件名>.<时间戳>.copy-ignore-old，none 直接覆盖不保留")
+	hashAlgorithm := fs.String("hash", cfgpkg.HashSHA256, "清单、校验使用的内容哈希算法：sha256、blake3 或 xxh3（最快，但只能发现意外损坏），记录在清单中")
+	historySubDir := fs.String("history-subdir", "copy-ignore备份", "在备份目录下创建的子目录名称")
+	historyDir := fs.String("history-dir", "", "备份历史文件夹")
+	timestampFormat := fs.String("timestamp-format", "default", "历史目录名的时间戳格式：default（20060102-150405）、rfc3339、iso 或 Go 时间格式")
+	timestampZone := fs.String("timestamp-tz", "local", "历史目录时间戳使用的时区：local、UTC 或 IANA 时区名（如 Asia/Shanghai）")
+	bwLimit := fs.String("bwlimit", "", "按时间段限制复制带宽，如 \"09:00-18:00=5M,0\"（无时间段的规则为默认速率，0 不限速）")
+	var deltaThreshold sizeFlag
+	fs.Var(&deltaThreshold, "delta-threshold", "不小于该大小的已存在文件改为增量更新，只写入变化的分块（如 256M，默认关闭）")
+	var chunkThreshold sizeFlag
+	fs.Var(&chunkThreshold, "chunk-threshold", "不小于该大小的文件按内容分块存入块池，跨版本、跨仓库去重（如 64M，默认关闭）")
+	var warnSize sizeFlag
+	fs.Var(&warnSize, "warn-size", "不小于该大小的文件照常复制，但在结果汇总中醒目列出（如 1G，默认关闭）")
+	maxPathLen := fs.Int("max-path-len", 0, "备份目标路径的长度上限（字节），超过时改存到 .copy-ignore-long 下的哈希目录，0 表示按操作系统默认")
+	maxErrors := fs.Int("max-errors", 0, "出错的文件数超过该值时中止运行（如备份目标在运行中途消失），0 表示不限制")
+	maxFilesPerRepo := fs.Int("max-files-per-repo", 0, "每个仓库最多处理的被忽略条目数，超过时停止枚举该仓库并警告，继续处理其他仓库（0 表示不限制）")
+	scanQueue := fs.Int("scan-queue", cfgpkg.DefaultScanQueueSize, "扫描结果队列的缓冲大小：扫描最多领先复制的文件数，复制跟不上时扫描暂停等待")
+	jobQueue := fs.Int("job-queue", cfgpkg.DefaultJobQueueSize, "复制任务队列和结果队列的缓冲大小")
+	skipBinary := fs.Bool("skip-binary", false, "按文件头（魔数、0 字节）识别二进制文件并跳过，只备份文本文件")
+	skipCaches := fs.Bool("skip-caches", false, "跳过已知的可重建缓存目录（git-lfs、maven、gradle、npm、cargo、venv、pip 等）")
+	recheckDirs := fs.Bool("recheck-dirs", false, "不整体复制被忽略的目录，其中的内容逐个按 git 的判断复制，与 git 认为被忽略的文件完全一致")
+	dirtyGitignore := fs.String("dirty-gitignore", cfgpkg.DirtyGitignoreAllow, ".gitignore 有未提交修改（被忽略的文件范围可能还在变化）的仓库：allow 照常备份，warn 照常备份并警告，skip 跳过该仓库")
+	ignoreBackupMarkers := fs.Bool("ignore-backup-markers", false, "不理会 CACHEDIR.TAG 和 .nobackup 标记，照常复制带标记的目录（默认跳过）")
+	readOnlySource := fs.Bool("read-only-source", false, "只读保护：保证不以写方式打开、不删除、不修改搜索根目录下的源文件，违反时操作直接失败（不能与 --sync 同时使用）")
+	auditLog := fs.String("audit", "", "审计日志路径：逐行记录本次运行对文件系统的每一次修改（时间、操作、路径、原因）")
+	preserveACL := fs.Bool("preserve-acl", false, "同时复制所有者和访问控制列表（Windows 为 NTFS 安全描述符，Linux 为权限位和 POSIX ACL），还原到多用户服务器时权限不丢失")
+	placeholders := fs.String("placeholders", cfgpkg.PlaceholderSkip, "OneDrive、iCloud 等只在云端的占位文件：skip 跳过，hydrate 下载后复制，metadata 只记录大小和修改时间（不下载）")
+	reparsePoints := fs.String("reparse-points", cfgpkg.ReparseSkip, "目录链接（符号链接、Windows 目录联接和挂载点）的处理：skip 跳过，follow 跟随（检测环路，不重复扫描）")
+	fastDiscovery := fs.Bool("fast-discovery", false, "搜索根目录为整个驱动器（如 C:\\）时，通过 NTFS 的 MFT 查找仓库，代替逐个目录读取（仅 Windows，需要管理员权限）")
+	skipUnchanged := fs.Bool("skip-unchanged", false, "跳过自上次成功运行以来没有任何变化的仓库，不再执行 git 列出被忽略的文件（Windows 上读取 NTFS 的 USN 日志，需要管理员权限；Linux 上读取 watch 子命令记录的变更日志）")
+	layoutName := fs.String("layout", "path", "备份目录布局：path 按搜索根目录下的完整路径，repo 按仓库名（仓库移动后路径不变）")
+	sanitizeNames := fs.String("sanitize-names", cfgpkg.SanitizeAuto, "转义 Windows/exFAT 不兼容的文件名（: * ? 等字符、CON 等保留名、末尾的点和空格）：auto 按目标探测，always，never")
+	migrateMoved := fs.Bool("migrate-moved", false, "检测到仓库被移动（origin 地址或根提交相同）时，将旧备份重命名到新位置")
+	lastRun := fs.String("last-run", "", "运行摘要（JSON）的写入路径，默认备份根目录下的 last-run.json")
+	perHost := fs.Bool("per-host", false, "按主机分隔：写入 <备份根目录>/<主机名>，多台机器共享同一备份根目录时使用")
+	hostName := fs.String("host-name", "", "本机名称（用于 --per-host 子目录和租约文件），默认取系统主机名")
+	background := fs.Bool("background", false, "后台模式：降低进程的 CPU 和 IO 优先级，避免工作时间机器变卡")
+	initDest := fs.Bool("init-dest", false, "备份目标之前使用过但缺少 .copy-ignore-dest 标记时，确认已正确挂载后重新初始化")
+	fs.Var(&extraDests, "dest", "同时复制到的其他备份目标（支持多次）：每个目标由独立的进程复制，一个目标出错或缓慢（如网络共享）不影响其他目标")
+	destRetries := fs.Int("dest-retries", 2, "复制到多个备份目标时，失败的目标单独重试的次数")
+	destRetryDelay := fs.Duration("dest-retry-delay", time.Minute, "复制到多个备份目标时，失败的目标重试前等待的时间")
+	snapshotPreset := fs.String("snapshot", "", "运行成功（没有出错的文件）后为备份目标创建快照：vss（Windows 卷影复制）、btrfs、zfs、synology（群晖共享文件夹快照）、http（请求指定地址，如 QNAP 等 NAS 的接口）、command（自定义命令）")
+	var snapshotOpts sliceFlags
+	fs.Var(&snapshotOpts, "snapshot-opt", "快照预设的选项 key=value（支持多次），如 --snapshot-opt share=backup")
+	healFrom := fs.String("heal-from", "", "复制完成后按清单校验备份目标，损坏的文件从该副本目标重新获取")
+	configFile := fs.String("config", "", "从 YAML（.yaml、.yml）或 TOML（.toml）配置文件读取选项，键为选项名，roots、backup 为搜索根目录和备份根目录；命令行指定的选项和目录优先")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
 
-	args := flag.Args()
-	if len(args) != 2 {
-		flag.Usage()
-		os.Exit(1)
+	args = fs.Args()
+	// 配置文件中的取值只用于命令行没有指定的选项；命令行没有给出目录时使用配置文件中的目录
+	if *configFile != "" {
+		positional, err := applyConfigFile(fs, *configFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrValidation, err)
+		}
+		if len(args) == 0 {
+			args = positional
+		}
+	}
+	if len(args) < 2 {
+		return nil, errMissingRoots
 	}
 
-	backupRoot := args[1]
+	// 最后一个参数是备份根目录，之前的都是搜索根目录（可以含通配符）
+	backupRoot := args[len(args)-1]
+	searchRoot, scanRoots := searchRootArgs(args[:len(args)-1])
+
+	// 指定了其他备份目标时记录全部目标；作为多目标复制的子进程运行时只复制到分配给它的目标
+	var dests []string
+	if len(extraDests) > 0 {
+		dests = append([]string{backupRoot}, extraDests...)
+	}
+	child := fanOutChildEnv()
+	if child != nil {
+		backupRoot = child.Dest
+	}
 
 	return &cfgpkg.Config{
-		SearchRoot:   args[0],
-		BackupRoot:   backupRoot,
-		Excludes:     excludes,
-		DryRun:       *dryRun,
-		Concurrency:  *concurrency,
-		Verbose:      *verbose,
-		BackupDirs:   nil,
-		BackupKeep:   *backupKeep,
-		BackupSubdir: *historySubDir,
-		HistoryDir:   *historyDir,
+		SearchRoot:          searchRoot,
+		ScanRoots:           scanRoots,
+		BackupRoot:          backupRoot,
+		Excludes:            excludes,
+		Protect:             protects,
+		Sync:                syncs,
+		Priority:            priorities,
+		NoOverwrite:         *noOverwrite || *appendOnly,
+		AppendOnly:          *appendOnly,
+		DryRun:              *dryRun,
+		Explain:             *explain,
+		Output:              *output,
+		PrintConfig:         *printConfig,
+		DeleteDryRun:        *deleteDryRun,
+		DeleteReport:        *deleteReport,
+		FilteredPolicy:      *filteredPolicy,
+		LastRunFile:         *lastRun,
+		PerHost:             *perHost,
+		HostName:            *hostName,
+		InitDest:            *initDest,
+		Background:          *background,
+		Concurrency:         *concurrency,
+		Verbose:             *verbose,
+		ProgressInterval:    *progressInterval,
+		DebugAddr:           *debugAddr,
+		StallTimeout:        *stallTimeout,
+		StallAbort:          *stallAbort,
+		FileTimeout:         *fileTimeout,
+		BackupDirs:          nil,
+		BackupKeep:          *backupKeep,
+		KeepDryRun:          *keepDryRun,
+		Yes:                 *yes,
+		Overwrite:           cfgpkg.OverwritePolicy(*overwrite),
+		Hash:                *hashAlgorithm,
+		BackupSubdir:        *historySubDir,
+		HistoryDir:          *historyDir,
+		TimestampFormat:     *timestampFormat,
+		TimestampZone:       *timestampZone,
+		HealFrom:            *healFrom,
+		Dests:               dests,
+		DestRetries:         *destRetries,
+		DestRetryDelay:      *destRetryDelay,
+		Snapshot:            *snapshotPreset,
+		SnapshotOptions:     snapshotOpts,
+		FanOutChild:         child != nil,
+		BandwidthLimit:      *bwLimit,
+		AdaptiveConcurrency: *adaptive,
+		MaxConcurrency:      *maxConcurrency,
+		DeltaThreshold:      int64(deltaThreshold),
+		ChunkThreshold:      int64(chunkThreshold),
+		WarnSize:            int64(warnSize),
+		MaxErrors:           *maxErrors,
+		MaxPathLen:          *maxPathLen,
+		MaxFilesPerRepo:     *maxFilesPerRepo,
+		ScanQueueSize:       *scanQueue,
+		JobQueueSize:        *jobQueue,
+		SkipBinary:          *skipBinary,
+		SkipCaches:          *skipCaches,
+		IgnoreBackupMarkers: *ignoreBackupMarkers,
+		RecheckDirs:         *recheckDirs,
+		DirtyGitignore:      *dirtyGitignore,
+		Layout:              *layoutName,
+		ReadOnlySource:      *readOnlySource,
+		AuditLog:            *auditLog,
+		PreserveACL:         *preserveACL,
+		Placeholders:        *placeholders,
+		ReparsePoints:       *reparsePoints,
+		FastDiscovery:       *fastDiscovery,
+		SkipUnchanged:       *skipUnchanged,
+		SanitizeNames:       *sanitizeNames,
+		MigrateMoved:        *migrateMoved,
+	}, nil
+}
+
+// flagsExitCode 输出 parseFlags 返回的错误并给出子命令的退出码：
+// -h 为 0，缺少目录时输出用法说明并返回 1，其余参数错误返回 2（标志解析错误已由 flag 包输出）
+func flagsExitCode(fs *flag.FlagSet, err error) int {
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errMissingRoots):
+		fs.Usage()
+		return 1
+	case errors.Is(err, ErrValidation):
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 	}
+	return 2
 }
 
-// ValidateConfig 验证配置参数
+// ValidateConfig 验证配置参数，返回的错误包装了 ErrValidation
 func ValidateConfig(cfg *cfgpkg.Config) error {
-	// 检查搜索根目录是否存在且为目录
-	if info, err := os.Stat(cfg.SearchRoot); err != nil {
-		return fmt.Errorf("搜索根目录不存在: %s", cfg.SearchRoot)
-	} else if !info.IsDir() {
-		return fmt.Errorf("搜索根目录不是目录: %s", cfg.SearchRoot)
+	if err := validateConfig(cfg); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	return nil
+}
+
+// validateConfig 验证配置参数并补全解析后的取值（本机名称、备份目录列表等）
+func validateConfig(cfg *cfgpkg.Config) error {
+	// 归一化各根目录，检查网络路径是否完整、能否访问（需在检查目录是否存在之前，无法访问时给出明确的原因）
+	if err := normalizeRoots(cfg); err != nil {
+		return err
+	}
+	if err := probeNetworkRoots(cfg); err != nil {
+		return err
+	}
+
+	// 展开搜索根目录中的通配符，检查搜索根目录是否存在且为目录
+	if err := resolveSearchRoots(cfg); err != nil {
+		return err
+	}
+
+	// 确定本机名称；按主机分隔时实际写入 <备份根目录>/<主机名>，历史目录同样按主机分隔
+	if err := resolveHost(cfg); err != nil {
+		return err
+	}
+
+	// 在交互式终端中运行时，轮换删除旧版本需要 --yes 确认（多目标复制的子进程的输出经由管道，按协调进程判断）
+	cfg.Interactive = ui.Interactive()
+	if child := fanOutChildEnv(); child != nil {
+		cfg.Interactive = child.Interactive
+	}
+
+	// 搜索根目录与备份根目录、历史目录不能互相包含（按解析符号链接后的真实路径判断，需在创建备份根目录前检查）
+	if err := checkRootOverlap(cfg); err != nil {
+		return err
+	}
+
+	// 启用源目录只读保护和审计日志（需在创建备份根目录等任何修改之前）
+	if err := setupFSGuard(cfg); err != nil {
+		return err
 	}
 
 	// 检查备份根目录是否存在，不存在则创建
 	if _, err := os.Stat(cfg.BackupRoot); os.IsNotExist(err) {
-		if err := os.MkdirAll(cfg.BackupRoot, 0755); err != nil {
+		if err := fsguard.MkdirAll(cfg.BackupRoot, 0755, "创建备份根目录"); err != nil {
 			return fmt.Errorf("创建备份根目录失败: %s (%v)", cfg.BackupRoot, err)
 		}
 	} else if info, err := os.Stat(cfg.BackupRoot); err != nil {
@@ -91,19 +313,302 @@ func ValidateConfig(cfg *cfgpkg.Config) error {
 	// 将 BackupRoot 添加到备份目录列表，用于备份功能
 	cfg.BackupDirs = append(cfg.BackupDirs, cfg.BackupRoot)
 
+	// 验证各项选项
+	if errs := validateOptions(cfg); len(errs) > 0 {
+		return errs[0]
+	}
+
+	// 确定是否转义目标文件名（需在备份根目录创建后探测）
+	if err := resolveSanitizeNames(cfg); err != nil {
+		return err
+	}
+
+	if cfg.HealFrom != "" {
+		cfg.HealFrom = filepath.Clean(cfg.HealFrom)
+	}
+
+	// 归一化路径
+	cfg.SearchRoot = filepath.Clean(cfg.SearchRoot)
+	cfg.BackupRoot = filepath.Clean(cfg.BackupRoot)
+
+	return nil
+}
+
+// validateOptions 检查各项选项的取值和组合（不修改文件系统），返回发现的所有问题
+func validateOptions(cfg *cfgpkg.Config) []error {
+	var errs []error
+
 	// 验证并发数
 	if cfg.Concurrency <= 0 {
-		return fmt.Errorf("并发数必须大于 0")
+		errs = append(errs, fmt.Errorf("并发数必须大于 0"))
+	}
+
+	// 验证自适应并发上限
+	if cfg.AdaptiveConcurrency && cfg.MaxConcurrency < cfg.Concurrency {
+		errs = append(errs, fmt.Errorf("自适应并发上限不能小于初始并发数"))
+	}
+
+	// 验证出错上限
+	if cfg.MaxErrors < 0 {
+		errs = append(errs, fmt.Errorf("--max-errors 不能为负数"))
+	}
+
+	// 验证状态行刷新间隔
+	if cfg.ProgressInterval <= 0 {
+		errs = append(errs, fmt.Errorf("--progress-interval 必须大于 0"))
+	}
+
+	// 验证卡住检测
+	if cfg.StallTimeout < 0 {
+		errs = append(errs, fmt.Errorf("--stall-timeout 不能为负数"))
+	}
+	if cfg.StallAbort && cfg.StallTimeout == 0 {
+		errs = append(errs, fmt.Errorf("--stall-abort 需要 --stall-timeout 大于 0"))
+	}
+
+	// 验证文件操作超时
+	if cfg.FileTimeout < 0 {
+		errs = append(errs, fmt.Errorf("--file-timeout 不能为负数"))
+	}
+
+	// 验证调试服务地址
+	if cfg.DebugAddr != "" {
+		if _, _, err := net.SplitHostPort(cfg.DebugAddr); err != nil {
+			errs = append(errs, fmt.Errorf("--debug-addr 格式应为 主机:端口（如 127.0.0.1:6060）: %v", err))
+		}
+	}
+
+	// 说明模式只用于干运行
+	if cfg.Explain && !cfg.DryRun {
+		errs = append(errs, fmt.Errorf("--explain 需要与 --dry-run 一起使用"))
+	}
+
+	// 验证输出格式：JSON 只用于干运行的结果，且每次运行只输出一个文档
+	switch cfg.Output {
+	case cfgpkg.OutputText, "":
+	case cfgpkg.OutputJSON:
+		if !cfg.DryRun {
+			errs = append(errs, fmt.Errorf("--output json 需要与 --dry-run 一起使用"))
+		}
+		if cfg.Explain {
+			errs = append(errs, fmt.Errorf("--output json 不能与 --explain 同时使用"))
+		}
+		if len(cfg.Dests) > 0 {
+			errs = append(errs, fmt.Errorf("--output json 不能与 --dest 同时使用（每个备份目标的结果相同，只需对一个目标运行）"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("未知的输出格式: %s（可选 %s、%s）", cfg.Output, cfgpkg.OutputText, cfgpkg.OutputJSON))
+	}
+
+	// 验证每个仓库的条目上限
+	if cfg.MaxFilesPerRepo < 0 {
+		errs = append(errs, fmt.Errorf("--max-files-per-repo 不能为负数"))
+	}
+
+	// 验证队列缓冲大小（0 表示不缓冲，同样不会死锁）
+	if cfg.ScanQueueSize < 0 || cfg.JobQueueSize < 0 {
+		errs = append(errs, fmt.Errorf("--scan-queue 和 --job-queue 不能为负数"))
+	}
+
+	// 验证路径长度上限（过小时哈希目录下的路径本身也会超限）
+	if cfg.MaxPathLen < 0 || cfg.MaxPathLen > 0 && cfg.MaxPathLen < len(helpers.LongPathTarget(cfg.BackupRoot, "x"))+helpers.TempSuffixMaxLen {
+		errs = append(errs, fmt.Errorf("--max-path-len 过小，至少需要容纳哈希目录下的路径"))
 	}
 
 	// 验证备份保留数
 	if cfg.BackupKeep <= 0 {
-		return fmt.Errorf("备份保留数必须大于 0")
+		errs = append(errs, fmt.Errorf("备份保留数必须大于 0"))
 	}
 
-	// 归一化路径
-	cfg.SearchRoot = filepath.Clean(cfg.SearchRoot)
-	cfg.BackupRoot = filepath.Clean(cfg.BackupRoot)
+	// 验证覆盖已有目标文件时旧版本的处理策略
+	switch cfg.Overwrite {
+	case "", cfgpkg.OverwriteHistory, cfgpkg.OverwriteSuffixRename, cfgpkg.OverwriteNone:
+	default:
+		errs = append(errs, fmt.Errorf("未知的覆盖策略: %s（可选 %s、%s、%s）", cfg.Overwrite, cfgpkg.OverwriteHistory, cfgpkg.OverwriteSuffixRename, cfgpkg.OverwriteNone))
+	}
 
+	if err := helpers.ValidateHashAlgorithm(cfg.Hash); err != nil {
+		errs = append(errs, err)
+	}
+
+	// 验证历史目录的时间戳格式和时区
+	if loc, err := helpers.LoadTimestampZone(cfg.TimestampZone); err != nil {
+		errs = append(errs, err)
+	} else if err := helpers.ValidateTimestampFormat(helpers.ResolveTimestampFormat(cfg.TimestampFormat), loc); err != nil {
+		errs = append(errs, err)
+	}
+
+	// 验证备份目录布局
+	if err := layout.Validate(cfg.Layout); err != nil {
+		errs = append(errs, err)
+	}
+
+	// 验证云端占位文件的处理策略
+	switch cfg.Placeholders {
+	case cfgpkg.PlaceholderSkip, cfgpkg.PlaceholderHydrate, cfgpkg.PlaceholderMetadata:
+	default:
+		errs = append(errs, fmt.Errorf("未知的云端占位文件处理策略: %s（可选 %s、%s、%s）", cfg.Placeholders, cfgpkg.PlaceholderSkip, cfgpkg.PlaceholderHydrate, cfgpkg.PlaceholderMetadata))
+	}
+
+	// 验证目录链接的处理策略
+	if cfg.ReparsePoints != cfgpkg.ReparseSkip && cfg.ReparsePoints != cfgpkg.ReparseFollow {
+		errs = append(errs, fmt.Errorf("未知的目录链接处理策略: %s（可选 %s、%s）", cfg.ReparsePoints, cfgpkg.ReparseSkip, cfgpkg.ReparseFollow))
+	}
+
+	// 验证快速查找仓库：MFT 中只有真实的父子关系，无法跟随目录链接
+	if cfg.FastDiscovery && cfg.ReparsePoints == cfgpkg.ReparseFollow {
+		errs = append(errs, fmt.Errorf("--fast-discovery 不能与 --reparse-points %s 同时使用", cfgpkg.ReparseFollow))
+	}
+
+	// 验证 .gitignore 有未提交修改的仓库的处理策略
+	switch cfg.DirtyGitignore {
+	case cfgpkg.DirtyGitignoreAllow, cfgpkg.DirtyGitignoreWarn, cfgpkg.DirtyGitignoreSkip:
+	default:
+		errs = append(errs, fmt.Errorf("未知的 .gitignore 未提交修改处理策略: %s（可选 %s、%s、%s）", cfg.DirtyGitignore, cfgpkg.DirtyGitignoreAllow, cfgpkg.DirtyGitignoreWarn, cfgpkg.DirtyGitignoreSkip))
+	}
+
+	// 验证被过滤文件的清理策略
+	if cfg.FilteredPolicy != cfgpkg.FilteredKeep && cfg.FilteredPolicy != cfgpkg.FilteredHistory {
+		errs = append(errs, fmt.Errorf("未知的被过滤文件处理策略: %s（可选 %s、%s）", cfg.FilteredPolicy, cfgpkg.FilteredKeep, cfgpkg.FilteredHistory))
+	}
+
+	// 只追加模式下不允许任何会改写或移动已有文件的功能
+	if cfg.AppendOnly {
+		if cfg.MigrateMoved {
+			errs = append(errs, fmt.Errorf("--append-only 不能与 --migrate-moved 同时使用"))
+		}
+		if cfg.HealFrom != "" {
+			errs = append(errs, fmt.Errorf("--append-only 不能与 --heal-from 同时使用"))
+		}
+		if cfg.Layout == layout.LayoutRepo {
+			errs = append(errs, fmt.Errorf("--append-only 不能与 --layout repo 同时使用（仓库名映射需要改写）"))
+		}
+		if cfg.SkipUnchanged {
+			errs = append(errs, fmt.Errorf("--append-only 不能与 --skip-unchanged 同时使用（变更位置需要改写）"))
+		}
+	}
+
+	// 验证各类文件模式的语法
+	for _, list := range []struct {
+		flag     string
+		patterns []string
+	}{{"--exclude", cfg.Excludes}, {"--protect", cfg.Protect}, {"--sync", cfg.Sync}, {"--priority", cfg.Priority}} {
+		if err := exclude.CheckPatterns(list.patterns); err != nil {
+			errs = append(errs, fmt.Errorf("%s 模式错误: %v", list.flag, err))
+		}
+	}
+
+	// 验证文件名转义模式
+	switch cfg.SanitizeNames {
+	case cfgpkg.SanitizeAuto, cfgpkg.SanitizeAlways, cfgpkg.SanitizeNever, "":
+	default:
+		errs = append(errs, fmt.Errorf("未知的文件名转义模式: %s（可选 %s、%s、%s）", cfg.SanitizeNames, cfgpkg.SanitizeAuto, cfgpkg.SanitizeAlways, cfgpkg.SanitizeNever))
+	}
+
+	// 验证带宽限制配置
+	if _, err := helpers.ParseBandwidthSchedule(cfg.BandwidthLimit); err != nil {
+		errs = append(errs, fmt.Errorf("带宽限制配置错误: %v", err))
+	}
+
+	// 验证多目标复制：各目标不能相同或互相包含；各目标会写入同一位置的文件不能共用
+	if cfg.DestRetries < 0 || cfg.DestRetryDelay < 0 {
+		errs = append(errs, fmt.Errorf("--dest-retries 和 --dest-retry-delay 不能为负数"))
+	}
+	for i, a := range cfg.Dests {
+		for _, b := range cfg.Dests[i+1:] {
+			if helpers.IsWithin(filepath.Clean(a), filepath.Clean(b)) || helpers.IsWithin(filepath.Clean(b), filepath.Clean(a)) {
+				errs = append(errs, fmt.Errorf("备份目标 %s 与 %s 相同或互相包含", a, b))
+			}
+		}
+	}
+	if len(cfg.Dests) > 0 {
+		for _, shared := range []struct {
+			flag string
+			set  bool
+		}{{"--history-dir", cfg.HistoryDir != ""}, {"--last-run", cfg.LastRunFile != ""}, {"--audit", cfg.AuditLog != ""},
+			{"--delete-report", cfg.DeleteDryRun && cfg.DeleteReport != ""}} {
+			if shared.set {
+				errs = append(errs, fmt.Errorf("--dest 不能与 %s 同时使用（各备份目标会写入同一个位置）", shared.flag))
+			}
+		}
+	}
+
+	// 验证快照预设及其选项
+	if cfg.Snapshot != "" {
+		if err := snapshot.Validate(cfg.Snapshot, cfg.SnapshotOptions); err != nil {
+			errs = append(errs, err)
+		}
+	} else if len(cfg.SnapshotOptions) > 0 {
+		errs = append(errs, fmt.Errorf("--snapshot-opt 需要与 --snapshot 同时使用"))
+	}
+
+	// 检查修复用的副本目标
+	if cfg.HealFrom != "" {
+		if info, err := os.Stat(cfg.HealFrom); err != nil {
+			errs = append(errs, fmt.Errorf("副本备份目标不存在: %s", cfg.HealFrom))
+		} else if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("副本备份目标不是目录: %s", cfg.HealFrom))
+		}
+	}
+
+	return errs
+}
+
+// resolveSanitizeNames 根据 --sanitize-names 和备份目标探测结果设置 cfg.SanitizeActive
+// 只追加模式下无法删除探测文件，auto 时只按操作系统判断
+func resolveSanitizeNames(cfg *cfgpkg.Config) error {
+	switch cfg.SanitizeNames {
+	case cfgpkg.SanitizeAlways:
+		cfg.SanitizeActive = true
+	case cfgpkg.SanitizeNever:
+		cfg.SanitizeActive = false
+	case cfgpkg.SanitizeAuto, "":
+		cfg.SanitizeActive = helpers.DestNeedsSanitizing(cfg.BackupRoot, !cfg.AppendOnly)
+	default:
+		return fmt.Errorf("未知的文件名转义模式: %s（可选 %s、%s、%s）", cfg.SanitizeNames, cfgpkg.SanitizeAuto, cfgpkg.SanitizeAlways, cfgpkg.SanitizeNever)
+	}
+	return nil
+}
+
+// checkRootOverlap 检查搜索根目录、备份根目录和历史目录之间的包含关系
+// 备份根目录或历史目录位于搜索根目录下时扫描会自动跳过它们（见 SkipOwnArtifacts），不在这里报错
+func checkRootOverlap(cfg *cfgpkg.Config) error {
+	// 有多个搜索根目录时逐个检查实际扫描的目录（它们共同的上级目录不会被扫描）
+	for _, root := range cfg.Roots() {
+		search := helpers.ResolvePath(root)
+		for _, target := range ownDirTargets(cfg) {
+			if helpers.IsWithin(search, helpers.ResolvePath(target.path)) {
+				return fmt.Errorf("搜索根目录 %s 位于%s %s 之内", root, target.name, target.path)
+			}
+		}
+	}
+
+	// 历史目录不能包含备份根目录，否则轮换历史时会删除备份
+	if cfg.HistoryDir != "" && helpers.IsWithin(helpers.ResolvePath(cfg.SharedRoot), helpers.ResolvePath(cfg.HistoryDir)) {
+		return fmt.Errorf("备份根目录 %s 位于历史目录 %s 之内", cfg.SharedRoot, cfg.HistoryDir)
+	}
+	return nil
+}
+
+// resolveHost 确定本机名称并处理 --per-host：共享根目录记录在 SharedRoot，BackupRoot 改为本机子目录
+func resolveHost(cfg *cfgpkg.Config) error {
+	if cfg.HostName == "" {
+		name, err := os.Hostname()
+		if err != nil || name == "" {
+			name = "localhost"
+		}
+		cfg.HostName = name
+	}
+	if strings.ContainsAny(cfg.HostName, `/\:*?"<>|`) || cfg.HostName == "." || cfg.HostName == ".." {
+		return fmt.Errorf("主机名不能用作目录名: %s", cfg.HostName)
+	}
+
+	cfg.SharedRoot = filepath.Clean(cfg.BackupRoot)
+	if cfg.PerHost {
+		cfg.BackupRoot = filepath.Join(cfg.SharedRoot, cfg.HostName)
+		if cfg.HistoryDir != "" {
+			cfg.HistoryDir = filepath.Join(cfg.HistoryDir, cfg.HostName)
+		}
+	}
 	return nil
 }
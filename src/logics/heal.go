@@ -0,0 +1,80 @@
+package logics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/manifest"
+)
+
+// healFromSecondary 按清单校验主备份目标，校验失败的文件和配方引用的损坏的块从副本目标重新获取
+// 只有副本中的文件哈希与清单记录一致（块的内容与其哈希一致）时才会用于修复
+func healFromSecondary(primary, secondary string) error {
+	cfg := cfgpkg.GetGlobalConfig()
+
+	m, err := manifest.Load(primary)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		fmt.Println("主备份目标尚无清单，跳过校验修复")
+		return nil
+	}
+
+	fmt.Printf("正在按清单校验备份目标: %s\n", primary)
+	failed, err := manifest.Verify(primary, m)
+	if err != nil {
+		return fmt.Errorf("校验失败: %v", err)
+	}
+	if len(failed) > 0 {
+		fmt.Printf("发现 %d 个校验失败的文件，尝试从 %s 修复\n", len(failed), secondary)
+		healed := healFiles(primary, secondary, m, failed, cfg.Verbose)
+		fmt.Printf("修复完成: %d 个已修复，%d 个失败\n", healed, len(failed)-healed)
+	}
+
+	// 清单只覆盖配方本身：修复后再逐个校验配方引用的块，缺失或损坏的块从副本的块池按内容哈希校验后获取
+	issues, err := checkChunks(primary, cfg.ManagedDirs(primary))
+	if err != nil {
+		return fmt.Errorf("校验块池失败: %v", err)
+	}
+	if len(issues) > 0 {
+		printChunkIssues(primary, issues)
+		healed, chunkFailed := healChunks(secondary, primary, issues, cfg.Verbose)
+		fmt.Printf("块修复完成: %d 个已修复，%d 个失败\n", healed, chunkFailed)
+	} else if len(failed) == 0 {
+		fmt.Println("校验通过，无需修复")
+	}
+	return nil
+}
+
+// healFiles 从副本目标获取校验失败的文件，副本中的文件哈希与清单记录一致时才会使用，返回修复的文件数
+func healFiles(primary, secondary string, m *manifest.Manifest, failed []string, verbose bool) int {
+	healed := 0
+	for _, key := range failed {
+		expected := m.Entries[key].Hash
+		src := filepath.Join(secondary, filepath.FromSlash(key))
+		dest := filepath.Join(primary, filepath.FromSlash(key))
+
+		hash, err := helpers.HashFileWith(src, m.Algorithm)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  无法修复 %s: 副本读取失败: %v\n", key, err)
+			continue
+		}
+		if hash != expected {
+			fmt.Fprintf(os.Stderr, "  无法修复 %s: 副本哈希与清单不一致\n", key)
+			continue
+		}
+		if err := helpers.CopyFileAtomic(src, dest); err != nil {
+			fmt.Fprintf(os.Stderr, "  无法修复 %s: %v\n", key, err)
+			continue
+		}
+		if verbose {
+			fmt.Printf("  已修复: %s -> %s\n", src, dest)
+		}
+		healed++
+	}
+	return healed
+}
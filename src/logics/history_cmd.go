@@ -0,0 +1,100 @@
+package logics
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// RunHistory 执行 history 子命令：维护备份历史目录
+func RunHistory(args []string) int {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s history compact [--mode hardlink|drop] [--dry-run] [--history-dir 目录] <备份根目录>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "      合并各时间戳目录中内容相同的旧版本，释放重复占用的空间\n")
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+
+	switch args[0] {
+	case "compact":
+		return runHistoryCompact(args[1:])
+	}
+	usage()
+	return 2
+}
+
+// runHistoryCompact 合并历史目录中内容相同的旧版本
+func runHistoryCompact(args []string) int {
+	fs := flag.NewFlagSet("history compact", flag.ExitOnError)
+	mode := fs.String("mode", helpers.CompactHardlink, "合并方式：hardlink 将相同的版本改为硬链接（各时间戳目录中的路径都保留），drop 删除与前一个版本相同的较新版本")
+	dryRun := fs.Bool("dry-run", false, "只统计可以合并的版本和可释放的空间，不做修改")
+	historyDir := fs.String("history-dir", "", "单独配置的备份历史文件夹（与复制时的 --history-dir 相同）")
+	historySubDir := fs.String("history-subdir", "copy-ignore备份", "备份目录下的历史子目录名称（与复制时的 --history-subdir 相同）")
+	timestampFormat := fs.String("timestamp-format", "default", "历史目录名的时间戳格式（与复制时的 --timestamp-format 相同）")
+	timestampZone := fs.String("timestamp-tz", "local", "历史目录时间戳使用的时区（与复制时的 --timestamp-tz 相同）")
+	hashAlgorithm := fs.String("hash", cfgpkg.HashSHA256, "判断内容相同使用的哈希算法：sha256、blake3 或 xxh3")
+	verbose := fs.Bool("v", false, "显示每个被合并的文件")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if *mode != helpers.CompactHardlink && *mode != helpers.CompactDrop {
+		fmt.Fprintf(os.Stderr, "未知的合并方式: %s（可选 %s、%s）\n", *mode, helpers.CompactHardlink, helpers.CompactDrop)
+		return 2
+	}
+	if err := helpers.ValidateHashAlgorithm(*hashAlgorithm); err != nil {
+		fmt.Fprintf(os.Stderr, "参数错误: %v\n", err)
+		return 2
+	}
+	loc, err := helpers.LoadTimestampZone(*timestampZone)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+
+	historyBase := filepath.Join(filepath.Clean(fs.Arg(0)), *historySubDir)
+	if *historyDir != "" {
+		historyBase = filepath.Clean(*historyDir)
+	}
+	if info, err := os.Stat(historyBase); err != nil || !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "历史目录不存在: %s\n", historyBase)
+		return 1
+	}
+
+	result, err := helpers.CompactHistory(historyBase, helpers.CompactOptions{
+		Mode:     *mode,
+		Layout:   helpers.ResolveTimestampFormat(*timestampFormat),
+		Location: loc,
+		Hash:     *hashAlgorithm,
+		DryRun:   *dryRun,
+		Verbose:  *verbose,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "合并失败: %v\n", err)
+		return 1
+	}
+
+	action := "已合并"
+	if *dryRun {
+		action = "可合并"
+	}
+	fmt.Printf("检查了 %d 个时间戳目录中的 %d 个文件\n", result.Snapshots, result.Files)
+	fmt.Printf("%s重复的版本: %d 个，释放 %s\n", action, result.Merged, helpers.FormatSize(result.Freed))
+	if result.Failed > 0 {
+		fmt.Printf("%d 个版本合并失败，已保持原样", result.Failed)
+		if *mode == helpers.CompactHardlink {
+			fmt.Print("（文件系统不支持硬链接时可改用 --mode drop）")
+		}
+		fmt.Println()
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,112 @@
+package logics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// acquireHostLease 获取本机对共享备份根目录的租约；其他机器正在写入重叠的目录时设置 cfg.SharedInUse
+// 只追加模式下无法删除租约文件，不使用租约（该模式本身也不清理、不轮换）
+func acquireHostLease(cfg *cfgpkg.Config) (*helpers.LeaseHandle, error) {
+	if cfg.AppendOnly {
+		return nil, nil
+	}
+
+	subtree := "."
+	if cfg.PerHost {
+		subtree = cfg.HostName
+		if !helpers.IsHostSubtree(cfg.BackupRoot) {
+			if err := helpers.WriteHostMarker(cfg.BackupRoot, cfg.HostName); err != nil {
+				return nil, fmt.Errorf("写入主机标记失败: %v", err)
+			}
+		}
+	}
+
+	lease, err := helpers.AcquireLease(cfg.SharedRoot, cfg.HostName, subtree)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range lease.OtherActiveLeases(cfg.SharedRoot) {
+		cfg.SharedInUse = true
+		fmt.Printf("警告: 主机 %s 正在写入重叠的备份目录（开始于 %s），本次不清理、不轮换历史、不修复中断的移动\n",
+			other.Host, other.StartedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	return lease, nil
+}
+
+// hostStatus 共享备份根目录下一台机器的状态
+type hostStatus struct {
+	host    string
+	subtree string
+	lease   *helpers.Lease // 最近的租约（可能已过期），nil 表示没有
+	lastRun *LastRun       // 该机器最近一次运行的摘要，nil 表示没有
+}
+
+// loadHostStatuses 收集共享备份根目录下各机器的租约和最近运行摘要
+// root 可以是共享根目录，也可以是某台机器的子目录（带主机标记）
+func loadHostStatuses(root string) []hostStatus {
+	shared := root
+	if helpers.IsHostSubtree(root) {
+		shared = filepath.Dir(root)
+	}
+
+	hosts := make(map[string]*hostStatus)
+	leases, _ := helpers.ReadLeases(shared)
+	for i := range leases {
+		hosts[leases[i].Host] = &hostStatus{host: leases[i].Host, subtree: leases[i].Subtree, lease: &leases[i]}
+	}
+	if entries, err := os.ReadDir(shared); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() && helpers.IsHostSubtree(filepath.Join(shared, entry.Name())) && hosts[entry.Name()] == nil {
+				hosts[entry.Name()] = &hostStatus{host: entry.Name(), subtree: entry.Name()}
+			}
+		}
+	}
+
+	statuses := make([]hostStatus, 0, len(hosts))
+	for _, h := range hosts {
+		data, err := os.ReadFile(filepath.Join(shared, h.subtree, cfgpkg.LastRunFileName))
+		if err == nil {
+			var run LastRun
+			if json.Unmarshal(data, &run) == nil {
+				h.lastRun = &run
+			}
+		}
+		statuses = append(statuses, *h)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].host < statuses[j].host })
+	return statuses
+}
+
+// printHostStatuses 输出共享备份根目录下各机器的运行状态和最近一次运行结果
+func printHostStatuses(root string) {
+	statuses := loadHostStatuses(root)
+	if len(statuses) == 0 {
+		return
+	}
+	now := time.Now()
+	fmt.Printf("\n各机器状态:\n")
+	for _, h := range statuses {
+		state := "空闲"
+		if h.lease != nil && h.lease.Active(now) {
+			state = fmt.Sprintf("运行中（开始于 %s）", h.lease.StartedAt.Local().Format("2006-01-02 15:04"))
+		}
+		last := "没有运行摘要"
+		if h.lastRun != nil {
+			result := "成功"
+			if !h.lastRun.Success {
+				result = "失败"
+			}
+			last = fmt.Sprintf("最近完成 %s（%s前），%s", h.lastRun.FinishedAt.Local().Format("2006-01-02 15:04"),
+				now.Sub(h.lastRun.FinishedAt).Round(time.Minute), result)
+		}
+		fmt.Printf("  %-20s %s；%s\n", h.host, state, last)
+	}
+}
@@ -0,0 +1,122 @@
+package logics
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/scanner"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// scanEntry --output json 时输出的一个扫描结果
+type scanEntry struct {
+	AbsPath      string    `json:"abs_path"`
+	RelativePath string    `json:"relative_path"` // 相对于搜索根目录
+	RepoRoot     string    `json:"repo_root"`
+	Dir          bool      `json:"dir,omitempty"` // 整体复制的目录
+	Size         int64     `json:"size"`
+	ModTime      time.Time `json:"mtime"`
+}
+
+// newScanEntry 由扫描结果及其 Lstat 信息生成输出项（info 为 nil 表示已无法读取，大小和时间留空）
+func newScanEntry(file scanner.IgnoredFileInfo, info fs.FileInfo) scanEntry {
+	e := scanEntry{AbsPath: file.AbsPath, RelativePath: file.RelativePath, RepoRoot: file.RepoRoot}
+	if info != nil {
+		e.Dir = info.IsDir()
+		if !e.Dir {
+			e.Size = info.Size()
+		}
+		e.ModTime = info.ModTime()
+	}
+	return e
+}
+
+// scanTotals JSON 文档末尾的汇总
+type scanTotals struct {
+	Entries int   `json:"entries"`
+	Dirs    int   `json:"dirs"`
+	Bytes   int64 `json:"bytes"`
+	Repos   int   `json:"repos"`
+}
+
+// scanJSONWriter 边扫描边输出 JSON 文档，不在内存中累积扫描结果：
+//
+//	{"search_root": "...", "entries": [{...}, ...], "totals": {...}, "error": "..."}
+//
+// 扫描失败时同样输出完整的文档，error 为失败原因
+type scanJSONWriter struct {
+	w   *bufio.Writer
+	n   int
+	err error // 第一个写入错误（如管道已关闭），之后的写入全部忽略
+}
+
+func newScanJSONWriter(w io.Writer, searchRoot string) *scanJSONWriter {
+	j := &scanJSONWriter{w: bufio.NewWriter(w)}
+	j.write(`{"search_root":`)
+	j.value(searchRoot)
+	j.write(`,"entries":[`)
+	return j
+}
+
+// add 输出一个条目（每个条目一行）
+func (j *scanJSONWriter) add(e scanEntry) {
+	if j.n > 0 {
+		j.write(",")
+	}
+	j.write("\n")
+	j.value(e)
+	j.n++
+}
+
+// finish 输出汇总和扫描错误，结束文档，返回写入过程中的第一个错误
+func (j *scanJSONWriter) finish(totals scanTotals, scanErr error) error {
+	j.write("\n],\"totals\":")
+	j.value(totals)
+	if scanErr != nil {
+		j.write(`,"error":`)
+		j.value(scanErr.Error())
+	}
+	j.write("}\n")
+	if j.err == nil {
+		j.err = j.w.Flush()
+	}
+	return j.err
+}
+
+func (j *scanJSONWriter) write(s string) {
+	if j.err == nil {
+		_, j.err = j.w.WriteString(s)
+	}
+}
+
+func (j *scanJSONWriter) value(v any) {
+	if j.err != nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		j.err = err
+		return
+	}
+	_, j.err = j.w.Write(data)
+}
+
+// jsonStdout --output json 时 JSON 文档的输出位置（重定向之前的标准输出）
+var jsonStdout io.Writer = os.Stdout
+
+// redirectStdout 让标准输出只留给 JSON 文档：进度、汇总、警告等消息改为输出到标准错误，返回恢复函数
+func redirectStdout() func() {
+	stdout := os.Stdout
+	jsonStdout = stdout
+	os.Stdout = os.Stderr
+	restoreUI := ui.SetOutput(os.Stderr)
+	return func() {
+		ui.SetOutput(restoreUI)
+		os.Stdout = stdout
+		jsonStdout = stdout
+	}
+}
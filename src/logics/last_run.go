@@ -0,0 +1,130 @@
+package logics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/copy"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// RunRecord 单次运行的摘要，同时追加到运行历史（见 run_history.go）
+type RunRecord struct {
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Duration   float64       `json:"duration_seconds"`
+	Success    bool          `json:"success"`               // 运行完成且没有出错的文件
+	FatalError string        `json:"fatal_error,omitempty"` // 导致运行中止的错误
+	Totals     LastRunTotals `json:"totals"`
+}
+
+// LastRun 单次运行的机器可读摘要，每次复制结束后写入 last-run.json，供外部监控检查备份是否新鲜
+type LastRun struct {
+	RunRecord
+	Failures      []copy.Failure        `json:"failures,omitempty"`       // 出错的文件（最多记录 100 个）
+	History       *helpers.HistoryStats `json:"history,omitempty"`        // 本次保留和轮换删除的旧版本占用的空间
+	Snapshot      string                `json:"snapshot,omitempty"`       // 运行成功后创建的备份目标快照（--snapshot）
+	SnapshotError string                `json:"snapshot_error,omitempty"` // 创建快照失败的原因
+	Config        *cfgpkg.Config        `json:"config"`
+}
+
+// LastRunTotals 本次运行的汇总数据
+type LastRunTotals struct {
+	Copied       int   `json:"copied"`
+	Skipped      int   `json:"skipped"`
+	Errors       int   `json:"errors"`
+	Unprocessed  int   `json:"unprocessed,omitempty"` // 超过 --max-errors 中止后未处理的文件数
+	Conflicts    int   `json:"conflicts"`
+	Overwritten  int   `json:"overwritten,omitempty"` // 覆盖的已有目标文件数（旧版本按 --overwrite 策略处理）
+	CopiedBytes  int64 `json:"copied_bytes"`
+	SkippedBytes int64 `json:"skipped_bytes"`
+	BackupBytes  int64 `json:"backup_bytes,omitempty"` // 运行结束时备份根目录的总大小（含历史目录），运行中止时不统计
+}
+
+// newLastRun 根据复制结果生成运行摘要，result 为 nil 表示运行中止
+func newLastRun(started time.Time, result *copy.CopyResult, fatal error) *LastRun {
+	finished := time.Now()
+	run := &LastRun{
+		RunRecord: RunRecord{
+			StartedAt:  started,
+			FinishedAt: finished,
+			Duration:   finished.Sub(started).Seconds(),
+		},
+		Config: cfgpkg.GetGlobalConfig(),
+	}
+	if fatal != nil {
+		run.FatalError = fatal.Error()
+	}
+	if result != nil {
+		run.Totals = LastRunTotals{
+			Copied:      result.Copied,
+			Skipped:     result.Skipped,
+			Errors:      result.Errors,
+			Unprocessed: result.Unprocessed,
+			Conflicts:   len(result.Conflicts),
+		}
+		if result.Stats != nil {
+			for _, e := range result.Stats.ByExtension() {
+				run.Totals.CopiedBytes += e.CopiedBytes
+				run.Totals.SkippedBytes += e.SkippedBytes
+			}
+			run.Totals.Overwritten = result.Stats.Overwrites().Total()
+		}
+		run.Failures = result.Failures
+		if !result.History.Empty() {
+			run.History = &result.History
+		}
+		run.Totals.BackupBytes = helpers.DirSize(run.Config.BackupRoot)
+	}
+	run.Success = fatal == nil && run.Totals.Errors == 0
+	return run
+}
+
+// lastRunPath 返回运行摘要的写入路径，空字符串表示不写入
+// 只追加模式下不改写备份根目录中的记录文件，只有显式指定 --last-run 时才写入
+func lastRunPath(cfg *cfgpkg.Config) string {
+	if cfg.LastRunFile != "" {
+		return cfg.LastRunFile
+	}
+	if cfg.AppendOnly {
+		return ""
+	}
+	return filepath.Join(cfg.BackupRoot, cfgpkg.LastRunFileName)
+}
+
+// recordRun 写入 last-run.json 并追加到运行历史
+func recordRun(run *LastRun) {
+	writeLastRun(run)
+	appendRunHistory(&run.RunRecord)
+}
+
+// writeLastRun 写入运行摘要（先写临时文件再重命名，监控程序不会读到写了一半的文件）
+func writeLastRun(run *LastRun) {
+	path := lastRunPath(cfgpkg.GetGlobalConfig())
+	if path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "生成运行摘要失败: %v\n", err)
+		return
+	}
+	if err := fsguard.MkdirAll(filepath.Dir(path), 0755, "写入运行摘要"); err != nil {
+		fmt.Fprintf(os.Stderr, "写入运行摘要失败: %v\n", err)
+		return
+	}
+	tmp := path + ".tmp"
+	if err := fsguard.WriteFile(tmp, data, 0644, "写入运行摘要：写入临时文件"); err != nil {
+		fmt.Fprintf(os.Stderr, "写入运行摘要失败: %v\n", err)
+		return
+	}
+	if err := fsguard.Rename(tmp, path, "写入运行摘要"); err != nil {
+		fsguard.Remove(tmp, "删除写入失败的临时文件")
+		fmt.Fprintf(os.Stderr, "写入运行摘要失败: %v\n", err)
+	}
+}
@@ -0,0 +1,65 @@
+package logics
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// normalizeRoots 归一化搜索根目录、备份根目录、历史目录和副本备份目标（去掉长路径前缀等，见 helpers.NormalizeRoot），
+// 检查其中的 UNC 网络路径（\\server\share\...）是否完整
+func normalizeRoots(cfg *cfgpkg.Config) error {
+	cfg.SearchRoot = helpers.NormalizeRoot(cfg.SearchRoot)
+	for i := range cfg.ScanRoots {
+		cfg.ScanRoots[i] = helpers.NormalizeRoot(cfg.ScanRoots[i])
+	}
+	cfg.BackupRoot = helpers.NormalizeRoot(cfg.BackupRoot)
+	cfg.HistoryDir = helpers.NormalizeRoot(cfg.HistoryDir)
+	cfg.HealFrom = helpers.NormalizeRoot(cfg.HealFrom)
+	for i := range cfg.Dests {
+		cfg.Dests[i] = helpers.NormalizeRoot(cfg.Dests[i])
+	}
+
+	for _, root := range networkRoots(cfg) {
+		if err := helpers.ValidateUNCRoot(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// networkRoots 返回配置中需要访问的根目录（空的不列出）
+func networkRoots(cfg *cfgpkg.Config) []string {
+	roots := append([]string{}, cfg.ScanRoots...)
+	for _, root := range []string{cfg.SearchRoot, cfg.BackupRoot, cfg.HistoryDir, cfg.HealFrom} {
+		if root != "" {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// probeNetworkRoots 逐个访问位于网络共享上的根目录：无法访问时报错（比“目录不存在”更明确），
+// 响应慢于 helpers.SlowNetworkLatency 时警告扫描和复制可能很慢；同一共享只访问一次
+func probeNetworkRoots(cfg *cfgpkg.Config) error {
+	probed := make(map[string]bool)
+	for _, root := range networkRoots(cfg) {
+		server, share, _, ok := helpers.SplitUNC(root)
+		if !helpers.IsUNCPath(root) || !ok || probed[server+`\`+share] {
+			continue
+		}
+		probed[server+`\`+share] = true
+		latency, err := helpers.ProbeNetworkRoot(root)
+		if err != nil {
+			return fmt.Errorf("无法访问网络路径 %s: %v", root, err)
+		}
+		if latency >= helpers.SlowNetworkLatency {
+			fmt.Fprintf(os.Stderr, "警告: 网络路径 \\\\%s\\%s 响应较慢（访问耗时 %s），扫描和复制可能很慢；可使用 --file-timeout、--stall-timeout 避免在不稳定的连接上卡住\n",
+				server, share, latency.Round(time.Millisecond))
+		}
+	}
+	return nil
+}
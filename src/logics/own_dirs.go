@@ -0,0 +1,92 @@
+package logics
+
+import (
+	"os"
+	"path/filepath"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// ownDir 工具自身写入的目录（备份根目录、历史目录等）或文件（审计日志、运行摘要等）
+type ownDir struct {
+	name string
+	path string
+}
+
+// ownDirTargets 返回工具自身写入的目录；按主机分隔时取共享的备份根目录
+func ownDirTargets(cfg *cfgpkg.Config) []ownDir {
+	targets := []ownDir{{"备份根目录", cfg.SharedRoot}}
+	if cfg.HistoryDir != "" {
+		targets = append(targets, ownDir{"历史目录", cfg.HistoryDir})
+	}
+	if cfg.HealFrom != "" {
+		targets = append(targets, ownDir{"副本备份目标", cfg.HealFrom})
+	}
+	// 多目标复制时其他进程正在写入的备份目标
+	for _, dest := range cfg.Dests {
+		if !helpers.IsWithin(filepath.Clean(dest), cfg.SharedRoot) {
+			targets = append(targets, ownDir{"其他备份目标", dest})
+		}
+	}
+	return targets
+}
+
+// ownFileTargets 返回工具自身写入的文件和正在运行的程序文件（如在本项目的源码目录中构建后直接运行）
+// 默认位于备份根目录中的文件随备份根目录一起跳过，这里只列出另外指定了位置的
+func ownFileTargets(cfg *cfgpkg.Config) []ownDir {
+	var targets []ownDir
+	if cfg.AuditLog != "" {
+		targets = append(targets, ownDir{"审计日志", cfg.AuditLog})
+	}
+	if cfg.LastRunFile != "" {
+		targets = append(targets, ownDir{"运行摘要", cfg.LastRunFile})
+	}
+	if cfg.DeleteDryRun && cfg.DeleteReport != "" {
+		targets = append(targets, ownDir{"清理预演报告", cfg.DeleteReport})
+	}
+	if exe, err := os.Executable(); err == nil {
+		targets = append(targets, ownDir{"程序文件", exe})
+	}
+	return targets
+}
+
+// SkipOwnArtifacts 让扫描自动跳过位于搜索根目录之内的工具自身的产物，避免备份被再次扫描复制、不断自我增长：
+// 备份根目录、历史目录等目录，审计日志、运行摘要、正在运行的程序文件，以及写入中的临时文件（见 helpers.TempPath）
+// 已被 --exclude 排除的不再重复处理；每个被跳过的目录和文件都会提示一次
+func SkipOwnArtifacts(cfg *cfgpkg.Config, excluder *exclude.Matcher) {
+	userExcluder, err := exclude.NewMatcher(cfg.Excludes)
+	if err != nil {
+		return
+	}
+	excluder.SkipOwnTemps(helpers.IsRunTempName)
+
+	skip := func(target ownDir, add func(...string)) {
+		for _, root := range cfg.Roots() {
+			search := helpers.ResolvePath(root)
+			resolved := helpers.ResolvePath(target.path)
+			if !helpers.IsWithin(resolved, search) || userExcluder.ShouldExclude(target.path) || userExcluder.ShouldExclude(resolved) {
+				continue
+			}
+			// 扫描得到的路径以搜索根目录开头（未解析符号链接），两种形式都要排除
+			rel, err := filepath.Rel(search, resolved)
+			if err != nil {
+				continue
+			}
+			path := filepath.Join(root, rel)
+			add(path)
+			if path != resolved {
+				add(resolved)
+			}
+			ui.Printf("%s %s 位于搜索根目录 %s 之内，扫描时自动跳过\n", target.name, target.path, root)
+		}
+	}
+	for _, target := range ownDirTargets(cfg) {
+		skip(target, excluder.SkipDirs)
+	}
+	for _, target := range ownFileTargets(cfg) {
+		skip(target, excluder.SkipFiles)
+	}
+}
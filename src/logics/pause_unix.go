@@ -0,0 +1,46 @@
+//go:build !windows
+
+package logics
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// startPauseControl 通过信号控制暂停/继续：SIGUSR1 暂停复制，SIGUSR2 继续；返回停止监听的函数
+func startPauseControl(gate *helpers.PauseGate, verbose bool) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig == syscall.SIGUSR1 {
+					if gate.Pause() {
+						ui.Printf("已暂停复制（发送 SIGUSR2 继续: kill -USR2 %d）\n", os.Getpid())
+					}
+				} else if gate.Resume() {
+					ui.Printf("继续复制\n")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	if verbose {
+		fmt.Printf("运行中可发送 SIGUSR1 暂停复制、SIGUSR2 继续（kill -USR1 %d）\n", os.Getpid())
+	}
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// fanOutSignals 多目标复制时协调进程转发给各子进程的信号（暂停/继续和退出）
+var fanOutSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGUSR2}
@@ -0,0 +1,46 @@
+//go:build windows
+
+package logics
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// startPauseControl 通过键盘命令控制暂停/继续：输入 p 回车暂停复制，输入 r 回车继续；返回停止监听的函数
+// 读取标准输入无法中断，停止后读取协程只是不再处理命令，随进程退出
+func startPauseControl(gate *helpers.PauseGate, verbose bool) func() {
+	var stopped atomic.Bool
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if stopped.Load() {
+				return
+			}
+			switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+			case "p", "pause":
+				if gate.Pause() {
+					ui.Printf("已暂停复制（输入 r 回车继续）\n")
+				}
+			case "r", "resume":
+				if gate.Resume() {
+					ui.Printf("继续复制\n")
+				}
+			}
+		}
+	}()
+	if verbose {
+		fmt.Println("运行中输入 p 回车暂停复制，输入 r 回车继续")
+	}
+	return func() { stopped.Store(true) }
+}
+
+// fanOutSignals 多目标复制时协调进程处理的信号：Windows 上无法向其他进程转发信号，
+// Ctrl+C 由控制台直接发给各子进程，暂停/继续的键盘命令由协调进程转发到各子进程的标准输入
+var fanOutSignals = []os.Signal{os.Interrupt}
@@ -0,0 +1,99 @@
+package logics
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// RunPrune 执行 prune 子命令：不复制，按保留数量和时间删除备份历史目录中旧的时间戳目录
+func RunPrune(args []string) int {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	keep := fs.Int("keep", 3, "保留最新的时间戳目录数（至少 1）")
+	olderThan := fs.Duration("older-than", 0, "只删除早于该时长的时间戳目录（如 720h），最新的 --keep 个仍然保留；默认不按时间限制")
+	dryRun := fs.Bool("dry-run", false, "只列出将被删除的时间戳目录及其大小，不做修改")
+	yes := fs.Bool("yes", false, "确认删除（在交互式终端中运行时需要，计划任务等无人值守的运行不需要）")
+	historyDir := fs.String("history-dir", "", "单独配置的备份历史文件夹（与复制时的 --history-dir 相同）")
+	historySubDir := fs.String("history-subdir", "copy-ignore备份", "备份目录下的历史子目录名称（与复制时的 --history-subdir 相同）")
+	timestampFormat := fs.String("timestamp-format", "default", "历史目录名的时间戳格式（与复制时的 --timestamp-format 相同）")
+	timestampZone := fs.String("timestamp-tz", "local", "历史目录时间戳使用的时区（与复制时的 --timestamp-tz 相同）")
+	verbose := fs.Bool("v", false, "显示每个被删除的时间戳目录")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: %s prune [选项] <备份根目录>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "删除备份历史目录中超出保留数量（或早于指定时长）的整个时间戳目录，不扫描、不复制。\n\n")
+		fmt.Fprintf(os.Stderr, "参数:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if *keep < 1 {
+		fmt.Fprintf(os.Stderr, "参数错误: --keep 至少为 1\n")
+		return 2
+	}
+	if *olderThan < 0 {
+		fmt.Fprintf(os.Stderr, "参数错误: --older-than 不能为负数\n")
+		return 2
+	}
+	loc, err := helpers.LoadTimestampZone(*timestampZone)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+
+	historyBase := filepath.Join(filepath.Clean(fs.Arg(0)), *historySubDir)
+	if *historyDir != "" {
+		historyBase = filepath.Clean(*historyDir)
+	}
+	if info, err := os.Stat(historyBase); err != nil || !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "历史目录不存在: %s\n", historyBase)
+		return 1
+	}
+
+	// 与复制时的轮换相同：在交互式终端中运行且没有 --yes 时只列出，不删除
+	unconfirmed := !*dryRun && !*yes && ui.Interactive()
+	opts := helpers.PruneOptions{
+		Keep:     *keep,
+		Layout:   helpers.ResolveTimestampFormat(*timestampFormat),
+		Location: loc,
+		DryRun:   *dryRun || unconfirmed,
+		Verbose:  *verbose,
+	}
+	if *olderThan > 0 {
+		opts.Before = time.Now().Add(-*olderThan)
+	}
+	result, err := helpers.PruneHistory(historyBase, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "轮换失败: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("历史目录 %s 中有 %d 个时间戳目录（保留最新的 %d 个）\n", historyBase, result.Snapshots, *keep)
+	switch {
+	case len(result.Pruned) == 0:
+		fmt.Println("没有需要删除的时间戳目录")
+		return 0
+	case *dryRun:
+		fmt.Printf("预演: 以下 %d 个时间戳目录将被删除，共 %s:\n", len(result.Pruned), helpers.FormatSize(result.PrunedBytes()))
+	case unconfirmed:
+		fmt.Printf("在交互式终端中运行且未指定 --yes，没有删除以下 %d 个时间戳目录（共 %s，加 --yes 确认删除）:\n", len(result.Pruned), helpers.FormatSize(result.PrunedBytes()))
+	default:
+		fmt.Printf("已删除 %d 个时间戳目录，释放 %s:\n", len(result.Pruned), helpers.FormatSize(result.PrunedBytes()))
+	}
+	for _, p := range result.Pruned {
+		fmt.Printf("  %-32s %10s（%d 个文件）\n", p.Version, helpers.FormatSize(p.Bytes), p.Files)
+	}
+	if result.Failed > 0 {
+		fmt.Printf("%d 个时间戳目录删除失败\n", result.Failed)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,39 @@
+package logics
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// RunRepair 执行 repair 子命令：根据移动日志完成或回滚中断的移入历史操作
+// 正常复制开始前也会自动执行同样的修复
+func RunRepair(args []string) int {
+	fs := flag.NewFlagSet("repair", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "显示每个被处理的路径")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: %s repair [-v] <备份根目录>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	result, err := helpers.RepairInterruptedMoves(filepath.Clean(fs.Arg(0)), *verbose)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "修复失败: %v\n", err)
+		return 1
+	}
+	fmt.Printf("完成 %d 个中断的移动，回滚 %d 个", result.Completed, result.RolledBack)
+	if result.Failed > 0 {
+		fmt.Printf("，%d 个无法处理（日志已保留）\n", result.Failed)
+		return 1
+	}
+	fmt.Println()
+	return 0
+}
@@ -0,0 +1,318 @@
+package logics
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/copy"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// RunReport 一次运行的结果：扫描、复制、清理的统计和运行中遇到的错误
+// Run 只负责执行并填写结果，由调用方决定如何输出（PrintReport）以及是否以失败退出
+type RunReport struct {
+	DryRun   bool
+	Started  time.Time
+	Finished time.Time
+
+	Scan     ScanReport
+	Copy     *copy.CopyResult      // 复制阶段的结果，干运行或复制未完成时为 nil
+	Cleanup  *helpers.CleanupStats // 清理阶段（或清理预演）的统计，未执行时为 nil
+	Excludes []exclude.PatternStat // 各条排除规则排除的路径数
+
+	// Err 导致运行失败的错误（扫描失败、备份目标检查失败、出错数超过 --max-errors 等），成功时为 nil
+	// 包装了 ErrDestination、ErrScan 或 ErrCopy，可以用 errors.Is 区分
+	Err error
+
+	mu      sync.Mutex
+	errors  []error        // 不影响运行继续的错误（修复中断的移动、更新清单失败等）
+	summary *dryRunSummary // 干运行的汇总，用于输出文件最多的仓库和最大的文件
+}
+
+// ScanReport 扫描阶段的统计
+type ScanReport struct {
+	Duration       time.Duration
+	DirsVisited    int      // 仓库发现阶段访问的目录数
+	Repos          int      // 发现的 Git 仓库数（不含被排除的仓库）
+	Entries        int      // 需要处理的条目数（文件，或整体复制的目录）
+	Dirs           int      // 其中整体复制的目录数（只在干运行时统计）
+	Bytes          int64    // 需要处理的文件总大小（只在干运行时统计）
+	TruncatedRepos []string // 被忽略的条目超过 --max-files-per-repo、只处理了一部分的仓库
+	DirtyRepos     []string // 因 .gitignore 有未提交修改而跳过的仓库（--dirty-gitignore skip）
+	UnchangedRepos []string // 自上次运行以来没有变化而跳过的仓库（--skip-unchanged）
+}
+
+func newRunReport(dryRun bool) *RunReport {
+	return &RunReport{DryRun: dryRun, Started: time.Now()}
+}
+
+// addError 记录不影响运行继续的错误，可在多个协程中调用
+func (r *RunReport) addError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, err)
+}
+
+// Errors 返回运行中记录的不影响运行继续的错误
+func (r *RunReport) Errors() []error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]error(nil), r.errors...)
+}
+
+// fail 记录导致运行失败的错误，返回报告本身，便于直接 return
+func (r *RunReport) fail(err error) *RunReport {
+	r.Err = err
+	r.Finished = time.Now()
+	return r
+}
+
+// PrintReport 输出运行结束后的汇总（不含致命错误本身，由调用方输出）
+func PrintReport(r *RunReport) {
+	cfg := cfgpkg.GetGlobalConfig()
+	if r.DryRun {
+		if r.summary != nil && r.Err == nil {
+			r.summary.print(cfg.SearchRoot)
+		}
+	} else if r.Copy != nil {
+		printCopyResult(cfg, r.Copy)
+	}
+
+	if r.Err == nil {
+		printTruncatedRepos(r.Scan.TruncatedRepos, cfg.MaxFilesPerRepo)
+		printDirtyGitignoreRepos(r.Scan.DirtyRepos)
+		printUnchangedRepos(r.Scan.UnchangedRepos, cfg.Verbose)
+		printExcludeStats(r.Excludes)
+		if r.Copy != nil && !r.Copy.Aborted {
+			printCopyDetails(cfg, r.Copy)
+		}
+	}
+
+	for _, err := range r.Errors() {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+}
+
+// printCopyResult 输出复制的总数；运行因出错过多而中止时列出前几个出错的文件
+func printCopyResult(cfg *cfgpkg.Config, result *copy.CopyResult) {
+	if result.Aborted {
+		fmt.Printf("已中止: %d 个文件处理，%d 个跳过，%d 个出错，%d 个未处理\n",
+			result.Copied, result.Skipped, result.Errors, result.Unprocessed)
+		for i, f := range result.Failures {
+			if i == 10 {
+				fmt.Printf("  ...（共 %d 个出错，详见运行摘要）\n", result.Errors)
+				break
+			}
+			fmt.Printf("  %s: %s\n", f.SrcPath, f.Error)
+		}
+		return
+	}
+
+	fmt.Printf("复制全部完成: %d 个文件处理，%d 个跳过", result.Copied, result.Skipped)
+	if result.Errors > 0 {
+		fmt.Printf("，%d 个出错", result.Errors)
+	}
+	fmt.Println()
+
+	// 输出复制日志
+	for _, log := range result.Logs {
+		fmt.Println(log)
+	}
+
+	// 云端占位文件没有下载，提示备份中缺少其内容
+	if result.Stats != nil {
+		if n, size := result.Stats.Placeholders(); n > 0 {
+			action := "已跳过"
+			if cfg.Placeholders == cfgpkg.PlaceholderMetadata {
+				action = "只记录了元数据"
+			}
+			fmt.Printf("云端占位文件: %d 个，共 %s，未下载，%s（--placeholders hydrate 可下载后复制）\n", n, helpers.FormatSize(size), action)
+		}
+		printOverwrites(cfg, result.Stats.Overwrites())
+	}
+	printHistoryStats(cfg, result.History)
+}
+
+// printHistoryStats 输出本次保留的旧版本和轮换删除的旧版本占用的空间，以及历史目录当前的总大小，
+// 用于按实际数据调整 --backup-keep
+func printHistoryStats(cfg *cfgpkg.Config, h helpers.HistoryStats) {
+	if h.Empty() {
+		return
+	}
+	fmt.Printf("历史版本: 本次保留 %d 个文件（%s），轮换删除 %d 个文件（释放 %s）",
+		h.Moved, helpers.FormatSize(h.MovedBytes), h.Pruned, helpers.FormatSize(h.PrunedBytes))
+	if versions, size := helpers.HistoryUsage(cfg); versions > 0 {
+		fmt.Printf("；历史目录现有 %d 个时间戳目录，共 %s（--backup-keep %d）", versions, helpers.FormatSize(size), cfg.BackupKeep)
+	}
+	fmt.Println()
+	printPrunePlan(cfg, h.Planned)
+}
+
+// printPrunePlan 按路径列出超出 --backup-keep、本次没有删除的旧版本及其大小
+func printPrunePlan(cfg *cfgpkg.Config, planned []helpers.PrunePlan) {
+	if len(planned) == 0 {
+		return
+	}
+	var total int64
+	byPath := make(map[string][]helpers.PrunePlan)
+	var paths []string
+	for _, p := range planned {
+		if _, ok := byPath[p.Path]; !ok {
+			paths = append(paths, p.Path)
+		}
+		byPath[p.Path] = append(byPath[p.Path], p)
+		total += p.Bytes
+	}
+	sort.Strings(paths)
+
+	if cfg.KeepDryRun {
+		fmt.Printf("\n轮换预演: 以下 %d 个旧版本超出 --backup-keep %d，将被删除，共 %s:\n", len(planned), cfg.BackupKeep, helpers.FormatSize(total))
+	} else {
+		fmt.Printf("\n在交互式终端中运行且未指定 --yes，没有轮换删除以下 %d 个超出 --backup-keep %d 的旧版本（共 %s，加 --yes 确认删除）:\n", len(planned), cfg.BackupKeep, helpers.FormatSize(total))
+	}
+	for _, path := range paths {
+		fmt.Printf("  %s\n", path)
+		for _, p := range byPath[path] {
+			fmt.Printf("    %-32s %10s（%d 个文件）\n", p.Version, helpers.FormatSize(p.Bytes), p.Files)
+		}
+	}
+}
+
+// printOverwrites 输出覆盖的已有目标文件数，以及旧版本按 --overwrite 策略的去处
+func printOverwrites(cfg *cfgpkg.Config, o copy.OverwriteStats) {
+	if o.Total() == 0 {
+		return
+	}
+	fmt.Printf("覆盖已有文件: %d 个", o.Total())
+	if o.Preserved > 0 {
+		switch cfg.Overwrite {
+		case cfgpkg.OverwriteSuffixRename:
+			fmt.Printf("，%d 个旧版本在原位置重命名为 <文件名>.%s%s（各保留 %d 个）", o.Preserved, cfg.Timestamp, cfgpkg.OverwriteVersionSuffix, cfg.BackupKeep)
+		default:
+			fmt.Printf("，%d 个旧版本移入历史目录 %s", o.Preserved, cfg.HandleHistoryDir(cfg.BackupRoot))
+		}
+	}
+	if o.Discarded > 0 {
+		fmt.Printf("，%d 个旧版本未保留", o.Discarded)
+		if cfg.Overwrite == cfgpkg.OverwriteNone {
+			fmt.Print("（--overwrite none）")
+		}
+	}
+	fmt.Println()
+	if o.Failed > 0 {
+		fmt.Printf("警告: %d 个文件的旧版本保留失败，已直接覆盖（-v 查看原因）\n", o.Failed)
+	}
+}
+
+// printCopyDetails 输出超过 --warn-size 的文件、最大的文件和冲突报告
+func printCopyDetails(cfg *cfgpkg.Config, result *copy.CopyResult) {
+	// 超过 --warn-size 的文件总是列出，避免磁盘被意外占满
+	if result.Stats != nil && cfg.WarnSize > 0 {
+		if oversized := result.Stats.Oversized(); len(oversized) > 0 {
+			fmt.Printf("\n警告: %d 个文件不小于 %s:\n", len(oversized), helpers.FormatSize(cfg.WarnSize))
+			for _, f := range oversized {
+				status := "已复制"
+				if f.Skipped {
+					status = "已是最新"
+				}
+				fmt.Printf("  %10s  %s（%s）\n", helpers.FormatSize(f.Size), f.Path, status)
+			}
+			fmt.Println()
+		}
+	}
+
+	// 详细模式下列出最大的文件，通常少数大文件决定了耗时和备份大小
+	if cfg.Verbose && result.Stats != nil {
+		printLargestFiles("最大的已复制文件", result.Stats.LargestCopied)
+		printLargestFiles("最大的跳过文件", result.Stats.LargestSkipped)
+		printExtensionStats(result.Stats.ByExtension())
+	}
+
+	// 输出冲突报告：源文件和备份自上次运行后都被修改
+	if len(result.Conflicts) > 0 {
+		fmt.Printf("检测到 %d 个冲突（源文件和备份自上次运行后都被修改）:\n", len(result.Conflicts))
+		for _, c := range result.Conflicts {
+			fmt.Printf("  %s <-> %s\n    %s\n", c.SrcPath, c.DestPath, c.Resolution)
+		}
+	}
+
+	printThroughput(cfg, result.Throughput)
+}
+
+// throughputReportMin 复制各环节的耗时之和达到该值时（或详细模式下）输出耗时分解
+const throughputReportMin = 5 * time.Second
+
+// printThroughput 输出复制各环节的耗时分解，并在某个环节占主要时间时给出调整建议
+func printThroughput(cfg *cfgpkg.Config, t copy.ThroughputStats) {
+	total := t.Total()
+	if total <= 0 || (total < throughputReportMin && !cfg.Verbose) {
+		return
+	}
+	percent := func(d time.Duration) float64 { return float64(d) * 100 / float64(total) }
+
+	fmt.Printf("\n复制耗时分解（%d 个复制协程累计 %s，复制阶段用时 %s）:\n", len(t.Workers), total.Round(time.Millisecond), t.Wall.Round(time.Millisecond))
+	phases := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"获取文件信息", t.Stat},
+		{"读取源文件", t.Read},
+		{"写入目标", t.Write},
+		{"同步到磁盘", t.Fsync},
+		{"重命名", t.Rename},
+	}
+	for _, p := range phases {
+		fmt.Printf("  %s%*s %10s  %5.1f%%\n", p.name, 12-ui.Width(p.name), "", p.d.Round(time.Millisecond), percent(p.d))
+	}
+	if t.Wall > 0 && t.Bytes > 0 {
+		fmt.Printf("  写入 %s，平均 %s/s\n", helpers.FormatSize(t.Bytes), helpers.FormatSize(int64(float64(t.Bytes)/t.Wall.Seconds())))
+	}
+
+	var busy, idle time.Duration
+	for i, w := range t.Workers {
+		busy += w.Busy
+		idle += w.Idle
+		if cfg.Verbose {
+			fmt.Printf("  协程 %-3d 执行 %s，等待任务 %s，%d 个任务\n", i+1, w.Busy.Round(time.Millisecond), w.Idle.Round(time.Millisecond), w.Files)
+		}
+	}
+
+	// 耗时很短时各环节的占比没有参考价值，不给建议
+	if total < throughputReportMin {
+		return
+	}
+	for _, hint := range throughputHints(t, busy, idle) {
+		fmt.Printf("提示: %s\n", hint)
+	}
+}
+
+// throughputHints 根据占主要时间的环节给出调整建议
+func throughputHints(t copy.ThroughputStats, busy, idle time.Duration) []string {
+	var hints []string
+	// 复制协程大部分时间在等待任务：瓶颈在扫描而不是复制
+	if busy+idle > 0 && idle*2 > busy+idle {
+		hints = append(hints, fmt.Sprintf("复制协程有 %.0f%% 的时间在等待任务，瓶颈在扫描仓库而不是复制，提高 --concurrency 帮助不大",
+			float64(idle)*100/float64(busy+idle)))
+	}
+
+	total := t.Total()
+	dominant := func(d time.Duration) bool { return d*10 >= total*4 } // 占 40% 以上
+	share := func(d time.Duration) float64 { return float64(d) * 100 / float64(total) }
+	switch {
+	case dominant(t.Fsync):
+		hints = append(hints, fmt.Sprintf("同步到磁盘占 %.0f%%，目标端（常见于网络盘、USB 盘）的同步写入是瓶颈，可提高 --concurrency 让多个文件的同步重叠进行，或使用 --adaptive-concurrency", share(t.Fsync)))
+	case dominant(t.Write):
+		hints = append(hints, fmt.Sprintf("写入目标占 %.0f%%，目标端写入速度是瓶颈；已存在的大文件可用 --delta-threshold 只写入变化的部分，重复内容多时可用 --chunk-threshold 去重", share(t.Write)))
+	case dominant(t.Read):
+		hints = append(hints, fmt.Sprintf("读取源文件占 %.0f%%，源磁盘是瓶颈；机械硬盘上提高 --concurrency 反而可能因寻道变慢", share(t.Read)))
+	case dominant(t.Stat + t.Rename):
+		hints = append(hints, fmt.Sprintf("获取文件信息和重命名占 %.0f%%，小文件多或目标文件系统的元数据操作慢（常见于网络盘），可提高 --concurrency 让元数据操作并行", share(t.Stat+t.Rename)))
+	}
+	return hints
+}
@@ -0,0 +1,570 @@
+package logics
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/chunkstore"
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/layout"
+	"github.com/aogg/copy-ignore/src/scanner"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// restoreEntry 备份目标中一个待还原的文件
+type restoreEntry struct {
+	backupPath string // 备份文件的路径
+	rel        string // 备份目标下的相对路径（路径过长的文件为记录的原始路径）
+	target     string // 还原到的位置
+}
+
+// restoreStats restore 的统计结果
+type restoreStats struct {
+	restored int   // 已还原（预演时为将还原）的文件数
+	bytes    int64 // 已还原的字节数
+	upToDate int   // 目标位置已是相同版本、跳过的文件数
+	filtered int   // 不在所选范围内（--include、--exclude、--repo）的文件数
+	unmapped int   // 无法确定原始位置的文件数（repo 布局下仓库名映射中没有记录）
+	failed   int   // 还原失败的文件数
+	declined int   // 冲突时选择保留目标位置的文件、没有覆盖的文件数
+
+	conflicts []restoreConflict // 目标位置的文件不比备份旧、没有覆盖的文件
+}
+
+// restoreConflict 目标位置已有不比备份旧的文件，按 --conflict 询问、跳过或覆盖
+type restoreConflict struct {
+	target     string
+	targetTime time.Time
+	backupTime time.Time
+}
+
+// 冲突的处理方式（--conflict）
+const (
+	conflictAsk       = "ask"       // 逐个询问
+	conflictSkip      = "skip"      // 不覆盖，运行结束时列为冲突
+	conflictOverwrite = "overwrite" // 覆盖
+)
+
+// conflictResolver 决定是否覆盖目标位置不比备份旧的文件
+type conflictResolver struct {
+	policy string
+	all    string        // ask 时回答了全部覆盖（overwrite）或全部跳过（skip）后不再询问
+	in     *bufio.Reader // ask 时读取回答
+}
+
+// overwrite 返回是否覆盖冲突的文件；declined 表示询问后选择了保留，不再列为未处理的冲突
+// 读不到回答（标准输入已关闭）时其余冲突都按 skip 处理
+func (r *conflictResolver) overwrite(c restoreConflict) (overwrite, declined bool) {
+	switch {
+	case r.policy == conflictOverwrite:
+		return true, false
+	case r.policy == conflictSkip:
+		return false, false
+	case r.all != "":
+		return r.all == conflictOverwrite, r.all == conflictSkip
+	}
+	for {
+		fmt.Printf("冲突: %s\n  目标修改于 %s，备份修改于 %s，覆盖？[y]是 [n]否 [a]全部覆盖 [s]全部跳过: ", c.target,
+			c.targetTime.Format("2006-01-02 15:04:05"), c.backupTime.Format("2006-01-02 15:04:05"))
+		line, err := r.in.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return true, false
+		case "n", "no":
+			return false, true
+		case "a":
+			r.all = conflictOverwrite
+			return true, false
+		case "s":
+			r.all = conflictSkip
+			return false, true
+		}
+		if err != nil {
+			fmt.Println()
+			r.policy = conflictSkip
+			return false, false
+		}
+	}
+}
+
+// restorePoint --at 指定的时间点：备份目标中的文件按历史目录中保存的旧版本回退到该时间点的状态
+type restorePoint struct {
+	at          time.Time
+	historyBase string
+	versions    []helpers.HistoryVersion
+}
+
+// restoreFilter 选择要还原的文件：--include、--exclude 按还原后的路径匹配，--repo 按所在仓库匹配
+type restoreFilter struct {
+	includes   *exclude.Matcher // 为 nil 时不限制
+	excludes   *exclude.Matcher
+	repos      []string
+	searchRoot string
+	mapper     *layout.Mapper
+	layout     string
+}
+
+// RunRestore 执行 restore 子命令：将备份目标中的文件复制回原来的仓库位置，可按模式和仓库只还原一部分
+func RunRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	var includes, excludes, repos sliceFlags
+	fs.Var(&includes, "include", "只还原匹配的文件（支持多次），模式写法与 --exclude 相同，按还原后的路径匹配")
+	fs.Var(&excludes, "exclude", "不还原匹配的文件（支持多次）")
+	fs.Var(&repos, "repo", "只还原指定仓库中的文件（支持多次）：仓库目录名、相对于搜索根目录的路径或绝对路径")
+	layoutName := fs.String("layout", layout.LayoutPath, "复制时使用的备份目录布局：path 或 repo")
+	historySubDir := fs.String("history-subdir", "copy-ignore备份", "备份目录下的历史子目录名称（还原时跳过）")
+	restoreTo := fs.String("restore-to", "", "还原到这个目录（保持相对于搜索根目录的结构），而不是写回原来的仓库，便于覆盖工作区前先检查")
+	force := fs.Bool("force", false, "覆盖目标位置比备份新的文件（等同于 --conflict overwrite）")
+	conflict := fs.String("conflict", "", "目标位置的文件不比备份旧时的处理方式：ask 逐个询问，skip 不覆盖并列为冲突，overwrite 覆盖；默认在交互式终端中为 ask，否则为 skip")
+	at := fs.String("at", "", "还原到过去某个时间点的状态：历史目录中的时间戳（还原到该次运行之前的状态）或时间（如 \"2026-10-01 18:00\"），使用历史目录中保存的旧版本")
+	listVersions := fs.Bool("list-versions", false, "列出历史目录中可用于 --at 的时间戳，不还原")
+	historyDir := fs.String("history-dir", "", "单独配置的备份历史文件夹（与复制时的 --history-dir 相同）")
+	timestampFormat := fs.String("timestamp-format", "default", "历史目录名的时间戳格式（与复制时的 --timestamp-format 相同）")
+	timestampZone := fs.String("timestamp-tz", "local", "历史目录时间戳使用的时区（与复制时的 --timestamp-tz 相同）")
+	dryRun := fs.Bool("dry-run", false, "只列出将要还原的文件，不做修改")
+	auditLog := fs.String("audit", "", "审计日志路径：逐行记录每一次写入（时间、操作、路径、原因）")
+	verbose := fs.Bool("verbose", false, "显示详细输出")
+	fs.BoolVar(verbose, "v", false, "显示详细输出（简写）")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: %s restore [选项] <搜索根目录> <备份根目录>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "将备份目标中的文件复制回搜索根目录下原来的位置（与复制时的参数顺序相同）。\n")
+		fmt.Fprintf(os.Stderr, "目标位置已是相同版本（大小和修改时间一致）的文件跳过。\n")
+		fmt.Fprintf(os.Stderr, "目标位置的文件不比备份旧时按 --conflict 处理：在交互式终端中逐个询问，否则不覆盖、列为冲突。\n")
+		fmt.Fprintf(os.Stderr, "指定 --at 时还原到过去某个时间点的状态（使用历史目录中的旧版本），--list-versions 列出可用的时间戳。\n")
+		fmt.Fprintf(os.Stderr, "指定 --restore-to 时写入该目录，原来的仓库不做修改。\n\n")
+		fmt.Fprintf(os.Stderr, "参数:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	if err := layout.Validate(*layoutName); err != nil {
+		fmt.Fprintf(os.Stderr, "参数错误: %v\n", err)
+		return 2
+	}
+	policy := *conflict
+	switch {
+	case *force && policy != "" && policy != conflictOverwrite:
+		fmt.Fprintf(os.Stderr, "参数错误: --force 不能与 --conflict %s 同时使用\n", policy)
+		return 2
+	case *force:
+		policy = conflictOverwrite
+	case policy == "" && ui.Interactive():
+		policy = conflictAsk
+	case policy == "":
+		policy = conflictSkip
+	}
+	switch policy {
+	case conflictAsk, conflictSkip, conflictOverwrite:
+	default:
+		fmt.Fprintf(os.Stderr, "参数错误: 未知的冲突处理方式 %s（可选 %s、%s、%s）\n", policy, conflictAsk, conflictSkip, conflictOverwrite)
+		return 2
+	}
+	// 预演不询问，冲突照常列出
+	if *dryRun && policy == conflictAsk {
+		policy = conflictSkip
+	}
+	loc, err := helpers.LoadTimestampZone(*timestampZone)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+	for _, patterns := range [][]string{includes, excludes} {
+		if err := exclude.CheckPatterns(patterns); err != nil {
+			fmt.Fprintf(os.Stderr, "参数错误: %v\n", err)
+			return 2
+		}
+	}
+
+	searchRoot := absRoot(fs.Arg(0))
+	backupRoot := filepath.Clean(fs.Arg(1))
+	if info, err := os.Stat(backupRoot); err != nil || !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "备份根目录不存在: %s\n", backupRoot)
+		return 1
+	}
+	historyBase := filepath.Join(backupRoot, *historySubDir)
+	if *historyDir != "" {
+		historyBase = filepath.Clean(*historyDir)
+	}
+	tsLayout := helpers.ResolveTimestampFormat(*timestampFormat)
+	if *listVersions {
+		return printHistoryVersions(historyBase, tsLayout, loc)
+	}
+	var point *restorePoint
+	if *at != "" {
+		if point, err = parseRestorePoint(*at, historyBase, tsLayout, loc); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 2
+		}
+	}
+	if *restoreTo != "" {
+		*restoreTo = absRoot(*restoreTo)
+		if helpers.IsWithin(*restoreTo, absRoot(backupRoot)) {
+			fmt.Fprintf(os.Stderr, "参数错误: --restore-to 不能位于备份根目录中: %s\n", *restoreTo)
+			return 2
+		}
+	}
+	cfg := &cfgpkg.Config{
+		SearchRoot:   searchRoot,
+		BackupRoot:   backupRoot,
+		BackupSubdir: *historySubDir,
+		Excludes:     excludes,
+		Layout:       *layoutName,
+		AuditLog:     *auditLog,
+		Verbose:      *verbose,
+	}
+	if err := setupFSGuard(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	cfgpkg.InitGlobalConfig(cfg)
+
+	filter, err := newRestoreFilter(cfg, includes, repos)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	entries, stats, err := collectRestoreEntries(cfg, filter, point)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取备份目标失败: %v\n", err)
+		return 1
+	}
+	if point != nil {
+		fmt.Printf("还原到 %s 的状态\n", point.at.Format("2006-01-02 15:04:05"))
+	}
+
+	resolver := &conflictResolver{policy: policy, in: bufio.NewReader(os.Stdin)}
+	for _, e := range entries {
+		if *restoreTo != "" {
+			e.target = relocateTarget(e, searchRoot, *restoreTo)
+		}
+		restoreEntryTo(backupRoot, e, resolver, *dryRun, *verbose, stats)
+	}
+
+	printRestoreConflicts(stats.conflicts)
+
+	action := "已还原"
+	if *dryRun {
+		action = "将还原"
+	}
+	if *restoreTo != "" {
+		action += "到 " + *restoreTo + " "
+	}
+	fmt.Printf("\n%s %d 个文件，共 %s；%d 个已是最新", action, stats.restored, helpers.FormatSize(stats.bytes), stats.upToDate)
+	if stats.filtered > 0 {
+		fmt.Printf("，%d 个不在所选范围内", stats.filtered)
+	}
+	if stats.unmapped > 0 {
+		fmt.Printf("，%d 个无法确定原始位置（仓库名映射中没有记录）", stats.unmapped)
+	}
+	if stats.declined > 0 {
+		fmt.Printf("，%d 个冲突按选择保留", stats.declined)
+	}
+	if len(stats.conflicts) > 0 {
+		fmt.Printf("，%d 个冲突未覆盖", len(stats.conflicts))
+	}
+	if stats.failed > 0 {
+		fmt.Printf("，%d 个还原失败", stats.failed)
+	}
+	fmt.Println()
+	if stats.failed > 0 || len(stats.conflicts) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printRestoreConflicts 列出目标位置比备份新、没有覆盖的文件
+func printRestoreConflicts(conflicts []restoreConflict) {
+	if len(conflicts) == 0 {
+		return
+	}
+	fmt.Printf("\n冲突: %d 个文件在目标位置的版本不比备份旧，未覆盖（确认后可加 --force 强制覆盖，或在终端中运行逐个选择）:\n", len(conflicts))
+	for _, c := range conflicts {
+		fmt.Printf("  %s（目标 %s，备份 %s）\n", c.target,
+			c.targetTime.Format("2006-01-02 15:04:05"), c.backupTime.Format("2006-01-02 15:04:05"))
+	}
+}
+
+// newRestoreFilter 根据 --include、--exclude、--repo 创建还原范围的过滤器
+func newRestoreFilter(cfg *cfgpkg.Config, includes, repos []string) (*restoreFilter, error) {
+	mapper, err := layout.Load(cfg.BackupRoot, cfg.Layout)
+	if err != nil {
+		return nil, err
+	}
+	f := &restoreFilter{repos: repos, searchRoot: cfg.SearchRoot, mapper: mapper, layout: cfg.Layout}
+	if len(includes) > 0 {
+		if f.includes, err = exclude.NewMatcher(includes); err != nil {
+			return nil, fmt.Errorf("初始化包含规则失败: %v", err)
+		}
+	}
+	if f.excludes, err = exclude.NewMatcher(cfg.Excludes); err != nil {
+		return nil, fmt.Errorf("初始化排除规则失败: %v", err)
+	}
+	return f, nil
+}
+
+// match 判断还原到 target 的文件是否在所选范围内
+func (f *restoreFilter) match(e restoreEntry) bool {
+	if f.includes != nil && !f.includes.ShouldExclude(e.target) {
+		return false
+	}
+	if f.excludes.ShouldExclude(e.target) {
+		return false
+	}
+	if len(f.repos) == 0 {
+		return true
+	}
+	repoRoot := f.repoRoot(e)
+	if repoRoot == "" {
+		return false
+	}
+	for _, repo := range f.repos {
+		switch {
+		case filepath.IsAbs(repo):
+			if filepath.Clean(repo) == repoRoot {
+				return true
+			}
+		case strings.ContainsAny(repo, `/\`):
+			if filepath.Join(f.searchRoot, repo) == repoRoot {
+				return true
+			}
+		default:
+			if filepath.Base(repoRoot) == repo {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// repoRoot 返回文件所属仓库的根目录：repo 布局下按仓库名映射，path 布局下向上查找还原位置所在的仓库；
+// 找不到时（如仓库尚未克隆）返回空字符串
+func (f *restoreFilter) repoRoot(e restoreEntry) string {
+	if f.layout == layout.LayoutRepo {
+		name, _, _ := strings.Cut(e.rel, string(filepath.Separator))
+		return f.mapper.Source(name, f.searchRoot)
+	}
+	return scanner.EnclosingRepo(e.target)
+}
+
+// collectRestoreEntries 遍历备份目标，列出所选范围内的文件及其原始位置；point 不为 nil 时回退到该时间点的版本
+// 历史目录、块池、移动日志、租约、其他主机的子树和工具自身维护的文件不参与还原
+func collectRestoreEntries(cfg *cfgpkg.Config, filter *restoreFilter, point *restorePoint) ([]restoreEntry, *restoreStats, error) {
+	stats := &restoreStats{}
+	// 备份目标下的相对路径 -> 要还原的备份文件
+	files := make(map[string]string)
+	err := walkBackupFiles(cfg.BackupRoot, cfg.ManagedDirs(cfg.BackupRoot), func(rel, path string, info fs.FileInfo) {
+		// 修改时间晚于时间点的版本当时还不存在
+		if point == nil || !info.ModTime().After(point.at) {
+			files[rel] = path
+		}
+	})
+	if err != nil {
+		return nil, stats, err
+	}
+	if point != nil {
+		if err := point.apply(files); err != nil {
+			return nil, stats, err
+		}
+	}
+
+	var entries []restoreEntry
+	for rel, path := range files {
+		target := filter.mapper.Source(rel, cfg.SearchRoot)
+		if target == "" {
+			stats.unmapped++
+			continue
+		}
+		e := restoreEntry{backupPath: path, rel: rel, target: target}
+		if !filter.match(e) {
+			stats.filtered++
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].target < entries[j].target })
+	return entries, stats, nil
+}
+
+// walkBackupFiles 遍历备份目标（或历史目录中的一个时间戳目录）中可还原的文件，rel 为备份目标下的相对路径
+// skipDirs 中的目录、其他主机的子树和工具自身维护的文件跳过；路径过长、改存到哈希目录的文件按旁边记录的原始路径给出 rel
+func walkBackupFiles(root string, skipDirs []string, fn func(rel, path string, info fs.FileInfo)) error {
+	longDir := filepath.Join(root, cfgpkg.LongPathDirName)
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			for _, dir := range skipDirs {
+				if path == dir {
+					return filepath.SkipDir
+				}
+			}
+			if path != root && helpers.IsHostSubtree(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := d.Name()
+		if !d.Type().IsRegular() || cfgpkg.IsManagedFile(name) || helpers.IsRunTempName(name) ||
+			helpers.IsPlaceholderStub(name) || helpers.IsOverwriteVersion(name) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		if helpers.IsWithin(path, longDir) {
+			if helpers.IsLongPathRecord(name) {
+				return nil
+			}
+			orig, err := helpers.ReadLongPathRecord(path)
+			if err != nil {
+				return nil
+			}
+			rel = filepath.FromSlash(orig)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		fn(rel, path, info)
+		return nil
+	})
+}
+
+// parseRestorePoint 解析 --at：历史目录中的时间戳（还原到该次运行之前的状态），或按时间戳时区解析的时间
+func parseRestorePoint(value, historyBase, layout string, loc *time.Location) (*restorePoint, error) {
+	at, ok := helpers.ParseTimestampDir(value, layout, loc)
+	if !ok {
+		for _, format := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+			if t, err := time.ParseInLocation(format, value, loc); err == nil {
+				at, ok = t, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("参数错误: 无法解析 --at %s（应为历史目录中的时间戳，或如 \"2026-10-01 18:00\" 的时间）", value)
+	}
+	versions, err := helpers.ListHistoryVersions(historyBase, layout, loc)
+	if err != nil {
+		return nil, fmt.Errorf("读取历史目录失败: %w", err)
+	}
+	return &restorePoint{at: at, historyBase: historyBase, versions: versions}, nil
+}
+
+// apply 将 files 回退到时间点的状态：每个文件在时间点之后第一次被替换或删除时移入历史目录的旧版本就是当时的版本，
+// 该版本的修改时间晚于时间点时说明当时还没有这个文件；之后没有被替换过的文件使用备份目标中的当前版本
+func (p *restorePoint) apply(files map[string]string) error {
+	seen := make(map[string]bool)
+	for _, v := range p.versions {
+		if v.Time.Before(p.at) {
+			continue
+		}
+		err := walkBackupFiles(filepath.Join(p.historyBase, v.Name), nil, func(rel, path string, info fs.FileInfo) {
+			if seen[rel] {
+				return
+			}
+			seen[rel] = true
+			if info.ModTime().After(p.at) {
+				delete(files, rel)
+			} else {
+				files[rel] = path
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printHistoryVersions 列出历史目录中的时间戳（--list-versions）
+func printHistoryVersions(historyBase, layout string, loc *time.Location) int {
+	versions, err := helpers.ListHistoryVersions(historyBase, layout, loc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取历史目录失败: %v\n", err)
+		return 1
+	}
+	if len(versions) == 0 {
+		fmt.Printf("历史目录 %s 中没有时间戳目录\n", historyBase)
+		return 0
+	}
+	fmt.Printf("历史目录 %s 中的 %d 个时间戳（--at <时间戳> 还原到该次运行之前的状态）:\n", historyBase, len(versions))
+	for _, v := range versions {
+		fmt.Printf("  %-32s %s %10s\n", v.Name, v.Time.Format("2006-01-02 15:04:05"), helpers.FormatSize(helpers.DirSize(filepath.Join(historyBase, v.Name))))
+	}
+	return 0
+}
+
+// relocateTarget 返回 --restore-to 时文件在替代目录中的位置：保持相对于搜索根目录的结构，
+// 原始位置不在搜索根目录下时改用备份目标中的相对路径
+func relocateTarget(e restoreEntry, searchRoot, restoreTo string) string {
+	rel, err := filepath.Rel(searchRoot, e.target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		rel = e.rel
+	}
+	return filepath.Join(restoreTo, rel)
+}
+
+// restoreEntryTo 将一个备份文件还原到原始位置；目标位置已是相同版本时跳过
+// 与复制方向只在源文件较新时覆盖一致，目标位置的文件不比备份旧时按 resolver 询问、跳过（记为冲突）或覆盖
+func restoreEntryTo(backupRoot string, e restoreEntry, resolver *conflictResolver, dryRun, verbose bool, stats *restoreStats) {
+	info, err := os.Stat(e.backupPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取备份文件失败 %s: %v\n", e.backupPath, err)
+		stats.failed++
+		return
+	}
+	size := info.Size()
+	if recipe, err := chunkstore.ReadRecipe(e.backupPath); err == nil && recipe != nil {
+		size = recipe.Size
+	}
+	if cur, err := os.Stat(e.target); err == nil && cur.Mode().IsRegular() {
+		if cur.Size() == size && cur.ModTime().Equal(info.ModTime()) {
+			stats.upToDate++
+			return
+		}
+		if !cur.ModTime().Before(info.ModTime()) {
+			c := restoreConflict{target: e.target, targetTime: cur.ModTime(), backupTime: info.ModTime()}
+			overwrite, declined := resolver.overwrite(c)
+			if !overwrite {
+				if declined {
+					stats.declined++
+				} else {
+					stats.conflicts = append(stats.conflicts, c)
+				}
+				return
+			}
+		}
+	}
+
+	if dryRun {
+		fmt.Printf("[预演] 将还原: %s -> %s\n", e.backupPath, e.target)
+	} else {
+		if err := helpers.RestoreFile(backupRoot, e.backupPath, e.target); err != nil {
+			fmt.Fprintf(os.Stderr, "还原失败 %s: %v\n", e.target, err)
+			stats.failed++
+			return
+		}
+		if verbose {
+			fmt.Printf("已还原: %s -> %s\n", e.backupPath, e.target)
+		}
+	}
+	stats.restored++
+	stats.bytes += size
+}
@@ -0,0 +1,200 @@
+package logics
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// appendRunHistory 将运行摘要追加到备份根目录的运行历史（每行一个 JSON）
+// 只追加模式下不修改备份根目录中的记录文件
+func appendRunHistory(run *RunRecord) {
+	cfg := cfgpkg.GetGlobalConfig()
+	if cfg.AppendOnly {
+		return
+	}
+	data, err := json.Marshal(run)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "生成运行历史失败: %v\n", err)
+		return
+	}
+	f, err := fsguard.OpenFile(filepath.Join(cfg.BackupRoot, cfgpkg.RunHistoryFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644, "追加运行历史")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "写入运行历史失败: %v\n", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		fmt.Fprintf(os.Stderr, "写入运行历史失败: %v\n", err)
+	}
+}
+
+// loadRunHistory 读取运行历史，无法解析的行（如写入中断留下的半行）跳过
+func loadRunHistory(backupRoot string) ([]RunRecord, error) {
+	f, err := os.Open(filepath.Join(backupRoot, cfgpkg.RunHistoryFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取运行历史失败: %v", err)
+	}
+	defer f.Close()
+
+	var runs []RunRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var run RunRecord
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取运行历史失败: %v", err)
+	}
+	return runs, nil
+}
+
+// RunStats 执行 stats 子命令：根据运行历史输出数据增长、耗时和出错率的趋势，用于备份盘的容量规划
+func RunStats(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	last := fs.Int("last", 20, "列出最近多少次运行的明细（0 表示全部），汇总始终基于全部历史")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: %s stats [选项] <备份根目录>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "根据备份根目录下的运行历史（%s）输出每次运行的明细和趋势汇总。\n", cfgpkg.RunHistoryFileName)
+		fmt.Fprintf(os.Stderr, "多台机器共享备份根目录时，同时列出各机器的运行状态和最近一次运行结果。\n\n")
+		fmt.Fprintf(os.Stderr, "参数:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	root := filepath.Clean(fs.Arg(0))
+	runs, err := loadRunHistory(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if len(runs) == 0 {
+		fmt.Println("没有运行历史")
+		printHostStatuses(root)
+		return 0
+	}
+
+	shown := runs
+	if *last > 0 && len(shown) > *last {
+		shown = shown[len(shown)-*last:]
+	}
+	fmt.Printf("%-16s %9s %8s %8s %6s %10s %10s %11s\n", "开始时间", "耗时", "复制", "跳过", "出错", "复制数据", "备份大小", "增长")
+	var prevSize int64
+	for i, run := range runs {
+		growth := ""
+		if run.Totals.BackupBytes > 0 && prevSize > 0 {
+			growth = formatSizeDelta(run.Totals.BackupBytes - prevSize)
+		}
+		if run.Totals.BackupBytes > 0 {
+			prevSize = run.Totals.BackupBytes
+		}
+		if i < len(runs)-len(shown) {
+			continue
+		}
+		size := "-"
+		if run.Totals.BackupBytes > 0 {
+			size = helpers.FormatSize(run.Totals.BackupBytes)
+		}
+		status := ""
+		if run.FatalError != "" {
+			status = "  中止: " + run.FatalError
+		}
+		fmt.Printf("%-16s %9s %8d %8d %6d %10s %10s %11s%s\n", run.StartedAt.Local().Format("2006-01-02 15:04"),
+			formatSeconds(run.Duration), run.Totals.Copied, run.Totals.Skipped, run.Totals.Errors,
+			helpers.FormatSize(run.Totals.CopiedBytes), size, growth, status)
+	}
+
+	printRunTrends(runs)
+	printHostStatuses(root)
+	return 0
+}
+
+// printRunTrends 输出全部历史的趋势汇总
+func printRunTrends(runs []RunRecord) {
+	var failed, files, errorFiles int
+	var totalDuration float64
+	var first, latest *RunRecord
+	for i := range runs {
+		run := &runs[i]
+		if !run.Success {
+			failed++
+		}
+		files += run.Totals.Copied + run.Totals.Skipped + run.Totals.Errors
+		errorFiles += run.Totals.Errors
+		totalDuration += run.Duration
+		if run.Totals.BackupBytes > 0 {
+			if first == nil {
+				first = run
+			}
+			latest = run
+		}
+	}
+
+	fmt.Printf("\n共 %d 次运行（%s ~ %s）\n", len(runs),
+		runs[0].StartedAt.Local().Format("2006-01-02"), runs[len(runs)-1].StartedAt.Local().Format("2006-01-02"))
+	fmt.Printf("失败的运行: %d 次（%.1f%%）\n", failed, float64(failed)*100/float64(len(runs)))
+	if files > 0 {
+		fmt.Printf("出错的文件: %d 个（占处理文件的 %.2f%%）\n", errorFiles, float64(errorFiles)*100/float64(files))
+	}
+	fmt.Printf("平均耗时: %s", formatSeconds(totalDuration/float64(len(runs))))
+	if len(runs) > 5 {
+		fmt.Printf("（最近 5 次平均 %s）", formatSeconds(recentAverageDuration(runs, 5)))
+	}
+	fmt.Println()
+
+	if first != nil && latest != first {
+		growth := latest.Totals.BackupBytes - first.Totals.BackupBytes
+		fmt.Printf("备份大小: %s -> %s（%s）", helpers.FormatSize(first.Totals.BackupBytes),
+			helpers.FormatSize(latest.Totals.BackupBytes), formatSizeDelta(growth))
+		if days := latest.StartedAt.Sub(first.StartedAt).Hours() / 24; days >= 1 {
+			fmt.Printf("，平均每天 %s", formatSizeDelta(int64(float64(growth)/days)))
+		}
+		fmt.Println()
+	} else if latest != nil {
+		fmt.Printf("备份大小: %s\n", helpers.FormatSize(latest.Totals.BackupBytes))
+	}
+}
+
+// recentAverageDuration 最近 n 次运行的平均耗时（秒）
+func recentAverageDuration(runs []RunRecord, n int) float64 {
+	if len(runs) < n {
+		n = len(runs)
+	}
+	var total float64
+	for _, run := range runs[len(runs)-n:] {
+		total += run.Duration
+	}
+	return total / float64(n)
+}
+
+// formatSeconds 将秒数格式化为便于阅读的时长
+func formatSeconds(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(100 * time.Millisecond).String()
+}
+
+// formatSizeDelta 格式化带符号的大小变化
+func formatSizeDelta(n int64) string {
+	if n < 0 {
+		return "-" + helpers.FormatSize(-n)
+	}
+	return "+" + helpers.FormatSize(n)
+}
@@ -0,0 +1,143 @@
+package logics
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/scanner"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// RunScan 执行 scan 子命令：只扫描，逐行输出将被备份的被忽略文件/目录（或所在的仓库），不需要备份根目录
+func RunScan(args []string) int {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	var excludes sliceFlags
+	fs.Var(&excludes, "exclude", "排除模式（支持多次），与复制时的 --exclude 相同")
+	skipCaches := fs.Bool("skip-caches", false, "跳过已知的可重建缓存目录（与复制时的 --skip-caches 相同）")
+	ignoreBackupMarkers := fs.Bool("ignore-backup-markers", false, "不理会 CACHEDIR.TAG 和 .nobackup 标记（与复制时的 --ignore-backup-markers 相同）")
+	repos := fs.Bool("repos", false, "只列出含有被忽略文件的仓库")
+	sizes := fs.Bool("size", false, "在每一行前输出占用空间")
+	output := fs.String("output", cfgpkg.OutputText, "输出格式：text 逐行输出路径，json 输出一个包含所有条目（路径、所属仓库、大小、修改时间）的 JSON 文档")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: %s scan [选项] <搜索根目录>...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "扫描搜索根目录中的 Git 仓库，逐行输出复制时会备份的被忽略文件/目录，不复制任何文件；汇总输出到标准错误。\n\n")
+		fmt.Fprintf(os.Stderr, "参数:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if *output != cfgpkg.OutputText && *output != cfgpkg.OutputJSON {
+		fmt.Fprintf(os.Stderr, "参数错误: 未知的输出格式: %s（可选 %s、%s）\n", *output, cfgpkg.OutputText, cfgpkg.OutputJSON)
+		return 2
+	}
+	if *output == cfgpkg.OutputJSON && *repos {
+		fmt.Fprintf(os.Stderr, "参数错误: --output json 不能与 --repos 同时使用（每个条目都带有所属的仓库）\n")
+		return 2
+	}
+
+	cfg := &cfgpkg.Config{Excludes: excludes, SkipCaches: *skipCaches, IgnoreBackupMarkers: *ignoreBackupMarkers}
+	cfg.SearchRoot, cfg.ScanRoots = searchRootArgs(fs.Args())
+	if err := resolveSearchRoots(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	cfgpkg.InitGlobalConfig(cfg)
+	excluder, err := exclude.NewMatcher(excludes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "初始化排除匹配器失败: %v\n", err)
+		return 1
+	}
+	if cfg.SkipCaches {
+		excluder.SkipCaches()
+	}
+	if !cfg.IgnoreBackupMarkers {
+		excluder.SkipMarkedDirs()
+	}
+
+	// 扫描过程的消息输出到标准错误，标准输出只有列表，便于交给其他命令处理
+	defer ui.SetOutput(ui.SetOutput(os.Stderr))
+
+	var structured *scanJSONWriter
+	var entries, dirs int
+	if *output == cfgpkg.OutputJSON {
+		structured = newScanJSONWriter(os.Stdout, cfg.SearchRoot)
+	}
+
+	fileChan := make(chan scanner.IgnoredFileInfo, cfgpkg.DefaultScanQueueSize)
+	repoSizes := make(map[string]int64)
+	var repoOrder []string
+	var total duItem
+	collectDone := make(chan struct{})
+	go func() {
+		defer close(collectDone)
+		for file := range fileChan {
+			var size int64
+			var files int
+			if *sizes || !*repos {
+				size, files = pathUsage(file.AbsPath, excluder)
+			}
+			if _, ok := repoSizes[file.RepoRoot]; !ok {
+				repoOrder = append(repoOrder, file.RepoRoot)
+			}
+			repoSizes[file.RepoRoot] += size
+			total.size += size
+			total.files += files
+			if *repos {
+				continue
+			}
+			if structured != nil {
+				// 目录的大小为其中所有文件的总大小，与 --size 相同
+				info, _ := os.Lstat(file.AbsPath)
+				entry := newScanEntry(file, info)
+				if entry.Dir {
+					entry.Size = size
+					dirs++
+				}
+				structured.add(entry)
+				entries++
+				continue
+			}
+			if *sizes {
+				fmt.Printf("%10s  %s\n", helpers.FormatSize(size), file.AbsPath)
+			} else {
+				fmt.Println(file.AbsPath)
+			}
+		}
+	}()
+	scanErr := scanner.ScanIgnoredFilesWithProgressStream(cfg.SearchRoot, excluder, nil, fileChan)
+	close(fileChan)
+	<-collectDone
+	if structured != nil {
+		totals := scanTotals{Entries: entries, Dirs: dirs, Bytes: total.size, Repos: len(repoOrder)}
+		if err := structured.finish(totals, scanErr); err != nil {
+			fmt.Fprintf(os.Stderr, "输出 JSON 结果失败: %v\n", err)
+			return 1
+		}
+	}
+	if scanErr != nil {
+		fmt.Fprintf(os.Stderr, "扫描失败: %v\n", scanErr)
+		return 1
+	}
+
+	if *repos {
+		for _, repo := range repoOrder {
+			if *sizes {
+				fmt.Printf("%10s  %s\n", helpers.FormatSize(repoSizes[repo]), repo)
+			} else {
+				fmt.Println(repo)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%d 个仓库含有被忽略的文件\n", len(repoOrder))
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "%d 个仓库中共 %d 个被忽略的文件，%s\n", len(repoOrder), total.files, helpers.FormatSize(total.size))
+	return 0
+}
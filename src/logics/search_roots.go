@@ -0,0 +1,145 @@
+package logics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// searchRootArgs 根据位置参数中的搜索根目录确定 SearchRoot 和待展开的 ScanRoots
+// 只有一个不含通配符的搜索根目录时与以前相同，不需要展开
+func searchRootArgs(roots []string) (string, []string) {
+	if len(roots) == 1 && !isGlobPattern(roots[0]) {
+		return roots[0], nil
+	}
+	return "", roots
+}
+
+// isGlobPattern 判断搜索根目录参数是否含通配符（Windows 的命令行不会替我们展开）
+// 本身就是已存在的路径时按字面处理，目录名中恰好带 [ 或 { 的不会被误当成模式
+func isGlobPattern(arg string) bool {
+	if !strings.ContainsAny(arg, "*?[{") {
+		return false
+	}
+	_, err := os.Stat(arg)
+	return err != nil
+}
+
+// resolveSearchRoots 展开搜索根目录参数中的通配符，检查每个搜索根目录都是存在的目录
+// 有多个搜索根目录时 SearchRoot 取各参数固定部分（第一个通配符之前）的共同上级目录，
+// 备份路径相对于它计算，同一组参数每次运行得到的备份路径不随匹配到的目录变化
+func resolveSearchRoots(cfg *cfgpkg.Config) error {
+	if len(cfg.ScanRoots) == 0 {
+		if info, err := os.Stat(cfg.SearchRoot); err != nil {
+			return fmt.Errorf("搜索根目录不存在: %s", cfg.SearchRoot)
+		} else if !info.IsDir() {
+			return fmt.Errorf("搜索根目录不是目录: %s", cfg.SearchRoot)
+		}
+		// 转为绝对路径，从仓库内部（如 "."）开始扫描时才能向上找到仓库根目录
+		cfg.SearchRoot = absRoot(cfg.SearchRoot)
+		return nil
+	}
+
+	var roots, bases []string
+	seen := make(map[string]bool)
+	for _, arg := range cfg.ScanRoots {
+		matches := []string{arg}
+		base := arg
+		if isGlobPattern(arg) {
+			var err error
+			if matches, err = doublestar.FilepathGlob(arg); err != nil {
+				return fmt.Errorf("搜索根目录模式无效: %s (%v)", arg, err)
+			}
+			base = globBase(arg)
+		}
+
+		found := 0
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return fmt.Errorf("搜索根目录不存在: %s", match)
+			}
+			if !info.IsDir() {
+				// 通配符匹配到的文件直接忽略；明确给出的路径必须是目录
+				if match == arg {
+					return fmt.Errorf("搜索根目录不是目录: %s", match)
+				}
+				continue
+			}
+			abs, err := filepath.Abs(match)
+			if err != nil {
+				return fmt.Errorf("解析搜索根目录失败: %s (%v)", match, err)
+			}
+			found++
+			if !seen[abs] {
+				seen[abs] = true
+				roots = append(roots, abs)
+			}
+		}
+		if found == 0 {
+			return fmt.Errorf("搜索根目录模式 %s 没有匹配到任何目录", arg)
+		}
+
+		abs, err := filepath.Abs(base)
+		if err != nil {
+			return fmt.Errorf("解析搜索根目录失败: %s (%v)", base, err)
+		}
+		bases = append(bases, abs)
+	}
+
+	ancestor, err := commonAncestor(bases)
+	if err != nil {
+		return err
+	}
+	sort.Strings(roots)
+	cfg.SearchRoot = ancestor
+	cfg.ScanRoots = roots
+	if len(roots) == 1 && roots[0] == ancestor {
+		cfg.ScanRoots = nil
+	}
+	return nil
+}
+
+// absRoot 返回搜索根目录的绝对路径（先按 helpers.NormalizeRoot 归一化），无法确定时返回清理后的原路径
+func absRoot(root string) string {
+	root = helpers.NormalizeRoot(root)
+	if abs, err := filepath.Abs(root); err == nil {
+		return abs
+	}
+	return filepath.Clean(root)
+}
+
+// globBase 返回模式中第一个含通配符的路径段之前的固定部分
+func globBase(pattern string) string {
+	dir := filepath.Clean(pattern)
+	for strings.ContainsAny(dir, "*?[{") {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return dir
+}
+
+// commonAncestor 返回多个绝对路径共同的最近上级目录（可能是其中之一）
+func commonAncestor(paths []string) (string, error) {
+	ancestor := paths[0]
+	for _, p := range paths[1:] {
+		for !helpers.IsWithin(p, ancestor) {
+			parent := filepath.Dir(ancestor)
+			if parent == ancestor {
+				return "", fmt.Errorf("搜索根目录 %s 与 %s 没有共同的上级目录（不在同一个卷上）", paths[0], p)
+			}
+			ancestor = parent
+		}
+	}
+	return ancestor, nil
+}
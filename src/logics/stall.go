@@ -0,0 +1,78 @@
+package logics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/copy"
+	"github.com/aogg/copy-ignore/src/scanner"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// stallCheckInterval 卡住检测的最长检查间隔
+const stallCheckInterval = 30 * time.Second
+
+// startStallWatch 在复制期间检测卡住：超过 timeout 没有任何进展（扫描进度、复制读写、完成文件）时警告，
+// 列出各复制协程正在处理的文件和正在扫描的仓库；abort 时放弃正在复制的文件。返回停止检测的函数
+// 每次卡住只警告一次，恢复进展后再次卡住时重新警告；暂停复制期间不检测
+func startStallWatch(timeout time.Duration, abort bool, status *statusLine) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(min(timeout/4, stallCheckInterval))
+		defer ticker.Stop()
+		var warnedAt time.Time // 已警告的卡住开始于的最近一次进展
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			last := status.lastEventTime()
+			if t := copy.LastActivity(); t.After(last) {
+				last = t
+			}
+			idle := time.Since(last)
+			if copy.Pause.Paused() || idle < timeout || last.Equal(warnedAt) {
+				continue
+			}
+			warnedAt = last
+			reportStall(idle, timeout, abort, status)
+		}
+	}()
+	return func() { close(done) }
+}
+
+// reportStall 输出卡住警告：各复制协程正在处理的文件、正在扫描的仓库，abort 时放弃正在复制的文件
+func reportStall(idle, timeout time.Duration, abort bool, status *statusLine) {
+	ui.Errorf("警告: 已有 %s 没有任何进展，可能卡住了（如网络共享写入挂起、git 进程无响应）\n", idle.Round(time.Second))
+	jobs := copy.ActiveJobs()
+	for _, j := range jobs {
+		ui.Errorf("  复制协程 %d: %s（已处理 %s）\n", j.Worker, j.SrcPath, j.Running.Round(time.Second))
+	}
+
+	scans := scanner.ActiveRepoScans()
+	repos := make([]string, 0, len(scans))
+	for repo := range scans {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	for _, repo := range repos {
+		ui.Errorf("  正在扫描仓库: %s（已处理 %s）\n", repo, scans[repo].Round(time.Second))
+	}
+	if p := status.discovery.Load(); len(repos) == 0 && p != nil && !p.Done && !status.scanDone.Load() {
+		ui.Errorf("  正在查找仓库: %s\n", p.Dir)
+	}
+	if len(jobs) == 0 && len(repos) == 0 {
+		ui.Errorf("  没有正在复制的文件或正在扫描的仓库\n")
+	}
+
+	if !abort {
+		if len(jobs) > 0 {
+			ui.Errorf("  使用 --stall-abort 可放弃卡住的文件、继续处理其余文件\n")
+		}
+		return
+	}
+	for _, j := range copy.AbandonStalled(timeout) {
+		ui.Errorf("  已放弃: %s（记为出错）\n", j.SrcPath)
+	}
+}
@@ -0,0 +1,72 @@
+package logics
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/scanner"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// statusScanPathWidth 状态行中当前扫描路径的最大显示宽度（终端列数）
+const statusScanPathWidth = 60
+
+// statusLine 终端状态行的数据：扫描协程和复制结果收集协程只更新计数，
+// 由界面协程（见 ui.Start）按 --progress-interval 生成状态行并刷新
+type statusLine struct {
+	discovery atomic.Pointer[scanner.ScanProgress] // 仓库发现阶段的最新进度
+	scanDone  atomic.Bool
+	copying   atomic.Bool
+	copied    atomic.Int64
+	skipped   atomic.Int64
+	errors    atomic.Int64
+	total     atomic.Int64
+	lastEvent atomic.Int64  // 最近一次收到扫描或复制进度的时间（UnixNano），用于卡住检测
+	eta       *etaEstimator // 为 nil 时不显示剩余时间
+}
+
+// setScanProgress 记录仓库发现阶段的进度（作为扫描的进度回调）
+func (s *statusLine) setScanProgress(p scanner.ScanProgress) {
+	s.discovery.Store(&p)
+	s.lastEvent.Store(time.Now().UnixNano())
+}
+
+// setProgress 记录复制进度（作为复制的进度回调）
+func (s *statusLine) setProgress(copied, skipped, errors, total int, _, _ string) {
+	s.copied.Store(int64(copied))
+	s.skipped.Store(int64(skipped))
+	s.errors.Store(int64(errors))
+	s.total.Store(int64(total))
+	s.copying.Store(true)
+	s.lastEvent.Store(time.Now().UnixNano())
+}
+
+// lastEventTime 返回最近一次收到扫描或复制进度的时间（还没有收到时为零值）
+func (s *statusLine) lastEventTime() time.Time {
+	if n := s.lastEvent.Load(); n != 0 {
+		return time.Unix(0, n)
+	}
+	return time.Time{}
+}
+
+// render 生成当前状态行
+func (s *statusLine) render() string {
+	var parts []string
+	if s.copying.Load() {
+		copied, skipped, errors, total := int(s.copied.Load()), int(s.skipped.Load()), int(s.errors.Load()), int(s.total.Load())
+		line := fmt.Sprintf("进度: %d/%d 已复制, %d 跳过, %d 出错", copied, total, skipped, errors)
+		if s.eta != nil {
+			if remaining, ok := s.eta.remaining(copied+skipped+errors, total, s.scanDone.Load()); ok {
+				line += fmt.Sprintf(", 剩余约 %s", remaining.Round(time.Second))
+			}
+		}
+		parts = append(parts, line)
+	}
+	if p := s.discovery.Load(); p != nil && !p.Done && !s.scanDone.Load() {
+		parts = append(parts, fmt.Sprintf("发现仓库: %d 个（已访问 %d 个目录，%s）", p.ReposFound, p.DirsVisited, p.Elapsed.Round(time.Second)))
+		parts = append(parts, "当前扫描: "+ui.Truncate(p.Dir, statusScanPathWidth))
+	}
+	return strings.Join(parts, " | ")
+}
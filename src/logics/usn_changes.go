@@ -0,0 +1,58 @@
+package logics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+// usnChanges 读取各扫描目录所在卷的 USN 日志当前位置，并读取自上次记录的位置以来的变更记录
+// 返回当前位置、判断仓库所在卷能否确定变化范围的函数（都不能时为 nil）和有变化的路径；
+// 无法读取日志的卷（非 NTFS、没有管理员权限、日志已重建或被覆盖）上的仓库照常扫描
+func usnChanges(cfg *cfgpkg.Config, prev *changeState) (positions []scanner.USNPosition, tracked func(repoRoot string) bool, changed []string) {
+	current := make(map[string]scanner.USNPosition)
+	for _, root := range cfg.Roots() {
+		volume := strings.ToUpper(filepath.VolumeName(root))
+		if _, ok := current[volume]; ok {
+			continue
+		}
+		pos, err := scanner.QueryUSNPosition(volume)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 无法读取 %s 的 USN 日志，照常扫描其中的所有仓库: %v\n", root, err)
+			continue
+		}
+		current[volume] = pos
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].Volume < positions[j].Volume })
+	if prev == nil {
+		return positions, nil, nil
+	}
+
+	volumes := make(map[string]bool) // 能确定变化范围的卷
+	for _, since := range prev.Positions {
+		now, ok := current[since.Volume]
+		if !ok {
+			continue
+		}
+		paths, err := scanner.USNChanges(since, now.NextUsn)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "警告: %v，照常扫描 %s 上的所有仓库\n", err, since.Volume)
+			continue
+		}
+		volumes[since.Volume] = true
+		changed = append(changed, paths...)
+		fmt.Printf("%s 自上次运行以来有 %d 处变化（USN 日志）\n", since.Volume, len(paths))
+	}
+	if len(volumes) == 0 {
+		return positions, nil, nil
+	}
+	return positions, func(repoRoot string) bool {
+		return volumes[strings.ToUpper(filepath.VolumeName(repoRoot))]
+	}, changed
+}
@@ -0,0 +1,285 @@
+package logics
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aogg/copy-ignore/src/chunkstore"
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/layout"
+	"github.com/aogg/copy-ignore/src/manifest"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// verify 的校验模式
+const (
+	verifyModeManifest = "manifest" // 按清单校验备份目标，发现损坏
+	verifyModeSource   = "source"   // 按内容哈希比较源文件和备份，发现不一致
+	verifyModeQuick    = "quick"    // 只比较源文件和备份的大小、修改时间
+)
+
+// verify 的退出码：0 全部一致，1 发现问题，2 参数错误，3 无法完成校验
+const (
+	verifyExitProblems = 1
+	verifyExitUsage    = 2
+	verifyExitError    = 3
+)
+
+// 校验发现的问题类型（JSON 输出中的 status）
+const (
+	verifyMissing  = "missing"  // 备份中不存在
+	verifyCorrupt  = "corrupt"  // 修改时间与清单一致，但大小或哈希不同
+	verifyModified = "modified" // 清单生成之后被修改过（修改时间不同）
+	verifyDrift    = "drift"    // 备份与源文件不一致
+	verifyError    = "error"    // 读取失败，无法校验
+)
+
+// verifyStatusLabels 问题类型的中文说明，用于文本输出
+var verifyStatusLabels = map[string]string{
+	verifyMissing:  "缺失",
+	verifyCorrupt:  "损坏",
+	verifyModified: "已修改",
+	verifyDrift:    "不一致",
+	verifyError:    "无法读取",
+}
+
+// verifyProblem 校验发现的一个问题
+type verifyProblem struct {
+	Path   string `json:"path"` // manifest 模式为备份目标下的相对路径，其余模式为源文件路径
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// verifyReport verify 的结果，--json 时原样输出
+type verifyReport struct {
+	Mode       string          `json:"mode"`
+	SearchRoot string          `json:"search_root,omitempty"`
+	BackupRoot string          `json:"backup_root"`
+	Checked    int             `json:"checked"`
+	Problems   []verifyProblem `json:"problems"`
+}
+
+// add 记录一个问题
+func (r *verifyReport) add(path, status, detail string) {
+	r.Problems = append(r.Problems, verifyProblem{Path: path, Status: status, Detail: detail})
+}
+
+// RunVerify 执行 verify 子命令：按清单校验备份目标（manifest），或比较源文件与备份（source、quick）
+func RunVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	mode := fs.String("mode", verifyModeManifest, "校验模式：manifest 按清单发现备份损坏，source 按内容哈希比较源文件和备份，quick 只比较大小和修改时间")
+	var excludes sliceFlags
+	fs.Var(&excludes, "exclude", "排除模式（支持多次），与复制时使用的排除规则保持一致（source、quick 模式）")
+	layoutName := fs.String("layout", layout.LayoutPath, "复制时使用的备份目录布局：path 或 repo（source、quick 模式）")
+	sanitizeNames := fs.String("sanitize-names", cfgpkg.SanitizeAuto, "复制时使用的文件名转义模式：auto、always 或 never（source、quick 模式）")
+	jsonOut := fs.Bool("json", false, "以 JSON 输出校验结果")
+	verbose := fs.Bool("verbose", false, "显示详细输出")
+	fs.BoolVar(verbose, "v", false, "显示详细输出（简写）")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s verify [--mode manifest] [选项] <备份根目录>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s verify --mode source|quick [选项] <搜索根目录> <备份根目录>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "退出码: 0 全部一致，1 发现问题，2 参数错误，3 无法完成校验。\n\n")
+		fmt.Fprintf(os.Stderr, "参数:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	wantArgs := 2
+	switch *mode {
+	case verifyModeManifest:
+		wantArgs = 1
+	case verifyModeSource, verifyModeQuick:
+	default:
+		fmt.Fprintf(os.Stderr, "未知的校验模式: %s（可选 %s、%s、%s）\n", *mode, verifyModeManifest, verifyModeSource, verifyModeQuick)
+		return verifyExitUsage
+	}
+	if fs.NArg() != wantArgs {
+		fs.Usage()
+		return verifyExitUsage
+	}
+	if err := layout.Validate(*layoutName); err != nil {
+		fmt.Fprintf(os.Stderr, "参数错误: %v\n", err)
+		return verifyExitUsage
+	}
+
+	// --json 时标准输出只包含结果，扫描进度等消息改到标准错误
+	if *jsonOut {
+		defer ui.SetOutput(ui.SetOutput(os.Stderr))
+	}
+
+	report := &verifyReport{Mode: *mode, BackupRoot: filepath.Clean(fs.Arg(fs.NArg() - 1))}
+	var err error
+	if *mode == verifyModeManifest {
+		err = verifyManifest(report, *verbose)
+	} else {
+		report.SearchRoot = absRoot(fs.Arg(0))
+		cfg := &cfgpkg.Config{
+			SearchRoot:    report.SearchRoot,
+			BackupRoot:    report.BackupRoot,
+			Excludes:      excludes,
+			Layout:        *layoutName,
+			SanitizeNames: *sanitizeNames,
+			Verbose:       *verbose,
+		}
+		if err := resolveSanitizeNames(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "参数错误: %v\n", err)
+			return verifyExitUsage
+		}
+		cfgpkg.InitGlobalConfig(cfg)
+		err = verifySource(report, cfg, *mode == verifyModeQuick)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "校验失败: %v\n", err)
+		return verifyExitError
+	}
+
+	if *jsonOut {
+		if report.Problems == nil {
+			report.Problems = []verifyProblem{}
+		}
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		for _, p := range report.Problems {
+			if p.Detail != "" {
+				fmt.Printf("[%s] %s（%s）\n", verifyStatusLabels[p.Status], p.Path, p.Detail)
+			} else {
+				fmt.Printf("[%s] %s\n", verifyStatusLabels[p.Status], p.Path)
+			}
+		}
+		fmt.Printf("校验了 %d 个文件，发现 %d 个问题\n", report.Checked, len(report.Problems))
+	}
+	if len(report.Problems) > 0 {
+		return verifyExitProblems
+	}
+	return 0
+}
+
+// verifyManifest 按清单逐个校验备份目标中的文件
+// 修改时间与清单一致、但大小或哈希不同的文件视为损坏；修改时间不同的说明清单生成后被改写过
+func verifyManifest(report *verifyReport, verbose bool) error {
+	root := report.BackupRoot
+	m, err := manifest.Load(root)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return fmt.Errorf("备份目标中没有清单（%s），请先完成一次复制运行", manifest.FileName)
+	}
+
+	for _, key := range m.Keys() {
+		entry := m.Entries[key]
+		path := filepath.Join(root, filepath.FromSlash(key))
+		report.Checked++
+		if verbose {
+			fmt.Fprintf(os.Stderr, "校验: %s\n", key)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				report.add(key, verifyMissing, "")
+			} else {
+				report.add(key, verifyError, err.Error())
+			}
+			continue
+		}
+		if !info.ModTime().Equal(entry.ModTime) {
+			report.add(key, verifyModified, fmt.Sprintf("清单记录 %s，当前 %s",
+				entry.ModTime.Format("2006-01-02 15:04:05"), info.ModTime().Format("2006-01-02 15:04:05")))
+			continue
+		}
+		if info.Size() != entry.Size {
+			report.add(key, verifyCorrupt, fmt.Sprintf("大小 %d，清单记录 %d", info.Size(), entry.Size))
+			continue
+		}
+		hash, err := helpers.HashFileWith(path, m.Algorithm)
+		if err != nil {
+			report.add(key, verifyError, err.Error())
+			continue
+		}
+		if hash != entry.Hash {
+			report.add(key, verifyCorrupt, "哈希（"+m.Algorithm+"）与清单不一致")
+		}
+	}
+	return nil
+}
+
+// verifySource 扫描被忽略的文件，逐个与备份比较；quick 时只比较大小和修改时间
+func verifySource(report *verifyReport, cfg *cfgpkg.Config, quick bool) error {
+	excluder, err := exclude.NewMatcher(cfg.Excludes)
+	if err != nil {
+		return fmt.Errorf("初始化排除匹配器失败: %v", err)
+	}
+	mapper, err := layout.Load(cfg.BackupRoot, cfg.Layout)
+	if err != nil {
+		return err
+	}
+	if cfg.SanitizeActive {
+		mapper.SanitizeNames()
+	}
+	m, err := manifest.Load(cfg.BackupRoot)
+	if err != nil {
+		return err
+	}
+	files, err := scanIgnoredFiles(cfg.SearchRoot, excluder)
+	if err != nil {
+		return fmt.Errorf("扫描失败: %v", err)
+	}
+
+	v := &sourceVerifier{backupRoot: cfg.BackupRoot, manifest: m, store: chunkstore.Open(cfg.BackupRoot)}
+	for _, file := range files {
+		destPath := filepath.Join(cfg.BackupRoot, mapper.Resolve(file))
+		walkIgnoredPath(file.AbsPath, destPath, excluder, func(src, dest string, info os.FileInfo) {
+			if !info.Mode().IsRegular() {
+				return
+			}
+			report.Checked++
+			if cfg.Verbose {
+				fmt.Fprintf(os.Stderr, "校验: %s\n", src)
+			}
+			if quick {
+				verifyQuick(report, src, dest, info)
+			} else if reason := v.verify(src, dest); reason != "" {
+				status := verifyDrift
+				if _, err := os.Stat(dest); os.IsNotExist(err) {
+					status = verifyMissing
+					reason = ""
+				}
+				report.add(src, status, reason)
+			}
+		})
+	}
+	return nil
+}
+
+// verifyQuick 只按大小和修改时间比较源文件和备份（分块存储的文件按配方中记录的大小比较）
+func verifyQuick(report *verifyReport, src, dest string, info os.FileInfo) {
+	destInfo, err := os.Stat(dest)
+	if err != nil {
+		if os.IsNotExist(err) {
+			report.add(src, verifyMissing, "")
+		} else {
+			report.add(src, verifyError, err.Error())
+		}
+		return
+	}
+	size := destInfo.Size()
+	if recipe, err := chunkstore.ReadRecipe(dest); err == nil && recipe != nil {
+		size = recipe.Size
+	}
+	switch {
+	case size != info.Size():
+		report.add(src, verifyDrift, fmt.Sprintf("大小 %d，备份 %d", info.Size(), size))
+	case !destInfo.ModTime().Equal(info.ModTime()):
+		report.add(src, verifyDrift, fmt.Sprintf("修改时间 %s，备份 %s",
+			info.ModTime().Format("2006-01-02 15:04:05"), destInfo.ModTime().Format("2006-01-02 15:04:05")))
+	}
+}
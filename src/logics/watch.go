@@ -0,0 +1,189 @@
+package logics
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	cfgpkg "github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// watchFlushInterval 变更写入日志的间隔
+const watchFlushInterval = time.Second
+
+// RunWatch 执行 watch 子命令：持续监视搜索根目录中的变化并写入备份根目录下的变更日志，
+// 计划任务等定时运行时指定 --skip-unchanged，据此只扫描有变化的仓库
+func RunWatch(args []string) int {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	perHost := fs.Bool("per-host", false, "与复制时的 --per-host 一致：变更日志写入 <备份根目录>/<主机名>")
+	hostName := fs.String("host", "", "与复制时的 --host 一致：本机名称，默认取系统主机名")
+	verbose := fs.Bool("verbose", false, "逐条输出发生变化的路径")
+	fs.BoolVar(verbose, "v", false, "逐条输出发生变化的路径（简写）")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: %s watch [选项] <搜索根目录>... <备份根目录>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "持续监视搜索根目录中的变化（Linux 上使用 inotify），写入备份根目录下的变更日志 %s。\n", cfgpkg.ChangeLogFileName)
+		fmt.Fprintf(os.Stderr, "保持运行期间，复制时指定 --skip-unchanged 只扫描自上次运行以来有变化的仓库。\n\n")
+		fmt.Fprintf(os.Stderr, "参数:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return 2
+	}
+
+	var roots []string
+	for _, arg := range fs.Args()[:fs.NArg()-1] {
+		root := absRoot(arg)
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "参数错误: 搜索根目录不存在或不是目录: %s\n", arg)
+			return 2
+		}
+		roots = append(roots, root)
+	}
+	cfg := &cfgpkg.Config{BackupRoot: filepath.Clean(fs.Arg(fs.NArg() - 1)), PerHost: *perHost, HostName: *hostName}
+	if err := resolveHost(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "参数错误: %v\n", err)
+		return 2
+	}
+	if err := fsguard.MkdirAll(cfg.BackupRoot, 0755, "创建备份根目录（写入变更日志）"); err != nil {
+		fmt.Fprintf(os.Stderr, "创建备份根目录失败: %v\n", err)
+		return 1
+	}
+
+	// 备份根目录位于搜索根目录之内时不监视，复制写入的备份和变更日志本身不算作变化
+	ownDirs := []string{absRoot(cfg.SharedRoot), absRoot(cfg.BackupRoot)}
+	watcher, err := helpers.WatchTree(roots, func(dir string) bool {
+		for _, own := range ownDirs {
+			if dir == own || strings.HasPrefix(dir, own+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	// 监视就绪后才写入开始记录，此后的变化都会被记录
+	logPath := filepath.Join(cfg.BackupRoot, cfgpkg.ChangeLogFileName)
+	changeLog, err := helpers.CreateChangeLog(logPath, roots)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "创建变更日志失败: %v\n", err)
+		return 1
+	}
+	fmt.Printf("正在监视 %s\n", strings.Join(roots, "、"))
+	fmt.Printf("变更日志: %s（按 Ctrl+C 退出）\n", logPath)
+
+	var mu sync.Mutex
+	pending := make(map[string]bool)
+	// record 将尚未写入的变化写入日志；事件最多在写入前一个间隔发生
+	record := func() error {
+		mu.Lock()
+		paths := make([]string, 0, len(pending))
+		for p := range pending {
+			paths = append(paths, p)
+		}
+		clear(pending)
+		mu.Unlock()
+		return changeLog.Record(paths, time.Now().Add(-watchFlushInterval))
+	}
+
+	done := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func() { stopOnce.Do(func() { close(done) }) }
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		ticker := time.NewTicker(watchFlushInterval)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			// 有变化时立即写入；没有变化时按心跳间隔更新日志的修改时间，表示仍在运行
+			mu.Lock()
+			idle := len(pending) == 0
+			mu.Unlock()
+			if idle && time.Since(last) < helpers.ChangeLogHeartbeat {
+				continue
+			}
+			err := record()
+			if err == nil {
+				err = changeLog.Flush()
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "警告: 写入变更日志失败: %v\n", err)
+			}
+			last = time.Now()
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		stop()
+	}()
+
+	runErr := watcher.Run(func(path string) {
+		if *verbose {
+			fmt.Printf("  %s %s\n", time.Now().Format("15:04:05"), path)
+		}
+		mu.Lock()
+		pending[path] = true
+		mu.Unlock()
+	}, func(reason error) {
+		fmt.Fprintf(os.Stderr, "警告: %v，下次运行将扫描所有仓库\n", reason)
+		if err := changeLog.Overflow(time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 写入变更日志失败: %v\n", err)
+		}
+	}, done)
+
+	// 退出前写入尚未写入的变化
+	stop()
+	<-flushed
+	if err := record(); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 写入变更日志失败: %v\n", err)
+	}
+	if err := changeLog.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 关闭变更日志失败: %v\n", err)
+	}
+	if runErr != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", runErr)
+		return 1
+	}
+	fmt.Println("已停止监视")
+	return 0
+}
+
+// watchLogChanges 读取 watch 子命令记录的变更日志，返回自上次完整成功的运行开始以来有变化的路径
+// watch 没有在上次运行之前开始并持续运行到现在（或监视的目录不包含全部扫描目录、有事件丢失）时
+// 无法确定变化范围，tracked 为 nil，照常扫描所有仓库
+func watchLogChanges(cfg *cfgpkg.Config, prev *changeState) (tracked func(repoRoot string) bool, changed []string) {
+	if prev == nil || prev.Since.IsZero() {
+		return nil, nil
+	}
+	logPath := filepath.Join(cfg.BackupRoot, cfgpkg.ChangeLogFileName)
+	changed, err := helpers.ChangesSince(logPath, cfg.Roots(), prev.Since.Add(-helpers.ChangeLogSlack), time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v，照常扫描所有仓库\n", err)
+		return nil, nil
+	}
+	fmt.Printf("自上次运行以来有 %d 处变化（watch 变更日志）\n", len(changed))
+	return func(string) bool { return true }, changed
+}
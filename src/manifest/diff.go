@@ -0,0 +1,54 @@
+package manifest
+
+import "sort"
+
+// DiffKind 差异类型
+type DiffKind int
+
+const (
+	OnlyInBase  DiffKind = iota // 仅存在于基准清单
+	OnlyInOther                 // 仅存在于对比清单
+	Changed                     // 两侧都存在但内容不同
+)
+
+// String 返回差异类型的中文描述
+func (k DiffKind) String() string {
+	switch k {
+	case OnlyInBase:
+		return "缺失"
+	case OnlyInOther:
+		return "多余"
+	case Changed:
+		return "内容不同"
+	}
+	return "未知"
+}
+
+// Difference 两份清单之间的单个差异
+type Difference struct {
+	Path string // 相对路径（正斜杠）
+	Kind DiffKind
+}
+
+// Diff 以 base 为基准比较 other，返回按路径排序的差异列表
+func Diff(base, other *Manifest) []Difference {
+	var diffs []Difference
+
+	for key, baseEntry := range base.Entries {
+		otherEntry, ok := other.Entries[key]
+		if !ok {
+			diffs = append(diffs, Difference{Path: key, Kind: OnlyInBase})
+		} else if otherEntry.Hash != baseEntry.Hash {
+			diffs = append(diffs, Difference{Path: key, Kind: Changed})
+		}
+	}
+
+	for key := range other.Entries {
+		if _, ok := base.Entries[key]; !ok {
+			diffs = append(diffs, Difference{Path: key, Kind: OnlyInOther})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs
+}
@@ -0,0 +1,210 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// FileName 清单文件名，位于备份根目录下
+const FileName = config.ManifestFileName
+
+// Version 当前的清单格式版本
+// 版本 1 只使用 SHA-256，哈希记录在 sha256 字段；版本 2 起算法记录在清单头部的 algorithm 中，哈希记录在 hash 字段
+const Version = 2
+
+// Entry 清单中单个文件的记录
+type Entry struct {
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mtime"`
+	Hash       string    `json:"hash"`               // 按清单的 Algorithm 计算的内容哈希（十六进制）
+	LegacyHash string    `json:"sha256,omitempty"`   // 版本 1 清单中的 SHA-256，读取时转为 Hash
+	Original   string    `json:"original,omitempty"` // 文件名被转义（--sanitize-names）或路径过长改存到哈希目录时记录原始相对路径，还原时使用
+}
+
+// Manifest 备份目录的文件清单（相对路径 -> 文件记录）
+type Manifest struct {
+	Version   int              `json:"version"`
+	Updated   time.Time        `json:"updated"`
+	Algorithm string           `json:"algorithm"` // 内容哈希算法：sha256、blake3 或 xxh3
+	Entries   map[string]Entry `json:"entries"`
+}
+
+// New 创建一个使用指定哈希算法（空表示 SHA-256）的空清单
+func New(algorithm string) *Manifest {
+	return &Manifest{Version: Version, Algorithm: helpers.HashAlgorithm(algorithm), Entries: make(map[string]Entry)}
+}
+
+// Path 返回指定备份根目录下的清单文件路径
+func Path(root string) string {
+	return filepath.Join(root, FileName)
+}
+
+// Load 读取备份根目录下的清单，清单不存在时返回 nil
+func Load(root string) (*Manifest, error) {
+	data, err := os.ReadFile(Path(root))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取清单失败: %v", err)
+	}
+
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("解析清单失败: %v", err)
+	}
+	if m.Version > Version {
+		return nil, fmt.Errorf("清单版本 %d 高于本程序支持的版本 %d，请升级 copy-ignore", m.Version, Version)
+	}
+	if err := helpers.ValidateHashAlgorithm(m.Algorithm); err != nil {
+		return nil, fmt.Errorf("清单使用了%v", err)
+	}
+	// 版本 1 的清单没有 algorithm，哈希都是 SHA-256
+	m.Algorithm = helpers.HashAlgorithm(m.Algorithm)
+	if m.Entries == nil {
+		m.Entries = make(map[string]Entry)
+	}
+	for key, entry := range m.Entries {
+		if entry.Hash == "" && entry.LegacyHash != "" {
+			entry.Hash, entry.LegacyHash = entry.LegacyHash, ""
+			m.Entries[key] = entry
+		}
+	}
+	return m, nil
+}
+
+// Save 将清单写入备份根目录（先写临时文件再重命名），总是写为当前格式版本
+func (m *Manifest) Save(root string) error {
+	m.Version = Version
+	m.Updated = time.Now()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化清单失败: %v", err)
+	}
+
+	target := Path(root)
+	tempPath := target + ".tmp"
+	if err := fsguard.WriteFile(tempPath, data, 0644, "更新清单：写入临时文件"); err != nil {
+		return fmt.Errorf("写入清单失败: %v", err)
+	}
+	if err := fsguard.Rename(tempPath, target, "更新清单"); err != nil {
+		fsguard.Remove(tempPath, "删除写入失败的临时清单")
+		return fmt.Errorf("重命名清单失败: %v", err)
+	}
+	return nil
+}
+
+// OriginalPath 返回清单条目对应的原始相对路径（文件名未转义时即为 key 本身）
+func (m *Manifest) OriginalPath(key string) string {
+	if entry, ok := m.Entries[key]; ok && entry.Original != "" {
+		return entry.Original
+	}
+	return key
+}
+
+// Keys 返回按字典序排序的相对路径列表
+func (m *Manifest) Keys() []string {
+	keys := make([]string, 0, len(m.Entries))
+	for k := range m.Entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Build 遍历备份根目录，按 algorithm（空表示 SHA-256）生成清单
+// prev 不为 nil 且使用相同的算法时，大小和修改时间未变化的文件直接复用旧哈希，避免重复读取；
+// 算法不同时所有文件重新计算
+// skipDirs 中的目录（如历史记录目录）及其子孙不纳入清单
+func Build(root string, prev *Manifest, skipDirs []string, algorithm string) (*Manifest, error) {
+	m := New(algorithm)
+	if prev != nil && prev.Algorithm != m.Algorithm {
+		prev = nil
+	}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			for _, skip := range skipDirs {
+				if skip != "" && (path == skip || strings.HasPrefix(path, skip+string(filepath.Separator))) {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+
+		// 跳过工具自身维护的文件和未完成的临时文件
+		if config.IsManagedFile(info.Name()) || strings.HasSuffix(info.Name(), ".tmp") {
+			return nil
+		}
+		longFile := strings.HasPrefix(path, filepath.Join(root, config.LongPathDirName)+string(filepath.Separator))
+		if longFile && helpers.IsLongPathRecord(info.Name()) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+
+		if prev != nil {
+			if old, ok := prev.Entries[key]; ok && old.Size == info.Size() && old.ModTime.Equal(info.ModTime()) && old.Hash != "" {
+				m.Entries[key] = old
+				return nil
+			}
+		}
+
+		// 本次运行刚复制的文件在写入时已计算过哈希
+		hash, ok := helpers.CopiedHash(path, info, m.Algorithm)
+		if !ok {
+			if hash, err = helpers.HashFileWith(path, m.Algorithm); err != nil {
+				return fmt.Errorf("计算哈希失败 %s: %v", path, err)
+			}
+		}
+		entry := Entry{Size: info.Size(), ModTime: info.ModTime(), Hash: hash}
+		if longFile {
+			// 路径过长、改存到哈希目录的文件，原始路径记录在旁边
+			if orig, err := helpers.ReadLongPathRecord(path); err == nil {
+				entry.Original = orig
+			}
+		} else if helpers.IsSanitizedPath(rel) {
+			entry.Original = filepath.ToSlash(helpers.UnsanitizePath(rel))
+		}
+		m.Entries[key] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Update 基于已有清单增量重建并保存备份根目录的清单，algorithm 与已有清单不同时按新算法重新计算所有文件
+func Update(root string, skipDirs []string, algorithm string) (*Manifest, error) {
+	prev, err := Load(root)
+	if err != nil {
+		return nil, err
+	}
+	m, err := Build(root, prev, skipDirs, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Save(root); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,45 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// Verify 按清单校验备份根目录中的文件，返回校验失败的相对路径
+// 只有修改时间与清单一致、但大小或哈希不一致的文件才视为损坏；
+// 修改时间变化的文件属于正常更新，不存在的文件交由清理逻辑处理
+func Verify(root string, m *Manifest) ([]string, error) {
+	var failed []string
+
+	for _, key := range m.Keys() {
+		entry := m.Entries[key]
+		path := filepath.Join(root, filepath.FromSlash(key))
+
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if !info.ModTime().Equal(entry.ModTime) {
+			continue
+		}
+		if info.Size() != entry.Size {
+			failed = append(failed, key)
+			continue
+		}
+
+		hash, err := helpers.HashFileWith(path, m.Algorithm)
+		if err != nil {
+			return nil, err
+		}
+		if hash != entry.Hash {
+			failed = append(failed, key)
+		}
+	}
+
+	return failed, nil
+}
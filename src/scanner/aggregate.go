@@ -0,0 +1,43 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/aogg/copy-ignore/src/config"
+)
+
+// recheckDirs 返回是否不整体复制被忽略的目录（--recheck-dirs）
+// 整体复制时目录中的所有内容都会被带上，不再经过 git 的判断；
+// 不整体复制时目录中的内容由 git ls-files 逐个列出，与 git 的判断完全一致
+func recheckDirs() bool {
+	if cfg := config.GetGlobalConfig(); cfg != nil {
+		return cfg.RecheckDirs
+	}
+	return false
+}
+
+// topDirs 返回需要判断是否整体被忽略的目录：仓库根目录下的直接子目录，有子树限制时为各子树下的直接子目录
+// 指定 --recheck-dirs 时不整体复制任何目录，返回空
+func topDirs(repoRoot string, scopes []string) ([]string, error) {
+	if recheckDirs() {
+		return nil, nil
+	}
+	parents := scopes
+	if len(parents) == 0 {
+		parents = []string{repoRoot}
+	}
+	var dirs []string
+	for _, parent := range parents {
+		entries, err := os.ReadDir(parent)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				dirs = append(dirs, filepath.Join(parent, entry.Name()))
+			}
+		}
+	}
+	return dirs, nil
+}
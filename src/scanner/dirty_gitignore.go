@@ -0,0 +1,64 @@
+package scanner
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/git"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+var (
+	dirtyMu    sync.Mutex
+	dirtyRepos []string // 本次扫描中因 .gitignore 有未提交修改而跳过的仓库
+)
+
+// dirtyGitignorePolicy 返回 .gitignore 有未提交修改的仓库的处理策略（--dirty-gitignore）
+func dirtyGitignorePolicy() string {
+	if cfg := config.GetGlobalConfig(); cfg != nil && cfg.DirtyGitignore != "" {
+		return cfg.DirtyGitignore
+	}
+	return config.DirtyGitignoreAllow
+}
+
+// skipDirtyGitignore 检查仓库的 .gitignore 是否有未提交的修改（被忽略的文件范围可能还在变化）
+// warn 策略只输出警告；skip 策略记录该仓库并返回 true，调用方跳过整个仓库（其已有的备份不复制也不清理）
+func skipDirtyGitignore(repoRoot string) bool {
+	policy := dirtyGitignorePolicy()
+	if policy == config.DirtyGitignoreAllow {
+		return false
+	}
+	files, err := git.DirtyGitignores(repoRoot)
+	if err != nil {
+		ui.Errorf("警告: 检查仓库 %s 的 .gitignore 状态失败: %v\n", repoRoot, err)
+		return false
+	}
+	if len(files) == 0 {
+		return false
+	}
+	if policy == config.DirtyGitignoreWarn {
+		ui.Errorf("警告: 仓库 %s 的 %s 有未提交的修改，被忽略的文件可能与项目约定的不一致\n", repoRoot, strings.Join(files, "、"))
+		return false
+	}
+
+	dirtyMu.Lock()
+	dirtyRepos = append(dirtyRepos, repoRoot)
+	dirtyMu.Unlock()
+	ui.Errorf("跳过仓库 %s: %s 有未提交的修改（--dirty-gitignore skip）\n", repoRoot, strings.Join(files, "、"))
+	return true
+}
+
+// DirtyGitignoreRepos 返回最近一次扫描中因 .gitignore 有未提交修改而跳过的仓库（--dirty-gitignore skip）
+func DirtyGitignoreRepos() []string {
+	dirtyMu.Lock()
+	defer dirtyMu.Unlock()
+	return append([]string(nil), dirtyRepos...)
+}
+
+// resetDirtyRepos 开始新的扫描前清空记录
+func resetDirtyRepos() {
+	dirtyMu.Lock()
+	defer dirtyMu.Unlock()
+	dirtyRepos = nil
+}
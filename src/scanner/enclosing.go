@@ -0,0 +1,82 @@
+package scanner
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// startDirs 返回广度优先搜索的起点，以及包含起点的仓库
+// 起点本身位于某个仓库之内（从项目的子目录开始扫描）时，向下遍历找不到该仓库，改为向上查找仓库根目录并直接处理该仓库，
+// 处理时只取起点之内的路径（见 repoScopes）
+func startDirs(searchRoot string) (queue, enclosing []string) {
+	seen := make(map[string]bool)
+	for _, root := range scanRoots(searchRoot) {
+		if isGitRepo(root) {
+			queue = append(queue, root)
+			continue
+		}
+		if repo := enclosingRepo(root); repo != "" {
+			if !seen[repo] {
+				seen[repo] = true
+				enclosing = append(enclosing, repo)
+			}
+			continue
+		}
+		queue = append(queue, root)
+	}
+	return queue, enclosing
+}
+
+// enclosingRepo 向上查找包含 dir 的 Git 仓库根目录，没有找到时返回空字符串
+// 只处理绝对路径：相对路径的上级目录无法与搜索根目录换算相对路径
+func enclosingRepo(dir string) string {
+	if !filepath.IsAbs(dir) {
+		return ""
+	}
+	for p := filepath.Dir(filepath.Clean(dir)); ; p = filepath.Dir(p) {
+		if isGitRepo(p) {
+			return p
+		}
+		if filepath.Dir(p) == p {
+			return ""
+		}
+	}
+}
+
+// EnclosingRepo 向上查找包含 path（文件或目录，不含 path 本身）的 Git 仓库根目录，没有找到时返回空字符串
+func EnclosingRepo(path string) string {
+	return enclosingRepo(path)
+}
+
+// repoScopes 返回仓库中需要处理的子树：仓库位于搜索根目录之内时为 nil（整个仓库）；
+// 仓库包含搜索根目录（从仓库内部开始扫描）时只处理位于仓库之内的扫描起点
+func repoScopes(searchRoot, repoRoot string) []string {
+	var scopes []string
+	for _, root := range scanRoots(searchRoot) {
+		if withinDir(repoRoot, root) {
+			return nil
+		}
+		if withinDir(root, repoRoot) {
+			scopes = append(scopes, root)
+		}
+	}
+	return scopes
+}
+
+// inScopes 判断路径是否位于任一子树之内；scopes 为空表示不限制
+func inScopes(path string, scopes []string) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	for _, scope := range scopes {
+		if withinDir(path, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// withinDir 判断 path 是否为 dir 或位于 dir 之内
+func withinDir(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
@@ -0,0 +1,95 @@
+package scanner
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// fastDiscoveryEnabled 是否开启 --fast-discovery
+func fastDiscoveryEnabled() bool {
+	cfg := config.GetGlobalConfig()
+	return cfg != nil && cfg.FastDiscovery
+}
+
+// isDriveRoot 判断目录是否为整个驱动器的根目录（如 C:\）
+func isDriveRoot(dir string) bool {
+	vol := filepath.VolumeName(dir)
+	return len(vol) == 2 && dir == vol+string(filepath.Separator)
+}
+
+// discoverFast 对起点中整个驱动器的根目录，通过读取卷的 MFT 查找仓库，代替逐个目录读取；返回仍需逐个目录查找的起点
+// 结果与逐个目录查找一致：仓库之内的仓库、被跳过的目录（如备份根目录）之内的仓库不计入，目录链接不跟随
+// 读取失败（非 NTFS、没有管理员权限、非 Windows）时警告并全部改为逐个目录查找
+func discoverFast(queue []string, excluder exclude.Excluder, discovery *discoveryProgress, found func(repoRoot string)) (rest []string, repoCount int) {
+	var drives []string
+	for _, dir := range queue {
+		if isDriveRoot(dir) {
+			drives = append(drives, dir)
+		} else {
+			rest = append(rest, dir)
+		}
+	}
+	if len(drives) == 0 {
+		return queue, 0
+	}
+
+	var candidates []string
+	for _, drive := range drives {
+		list, err := enumerateRepoCandidates(filepath.VolumeName(drive))
+		if err != nil {
+			ui.Errorf("警告: 无法快速查找仓库，改为逐个目录查找: %v\n", err)
+			return queue, 0
+		}
+		candidates = append(candidates, list...)
+	}
+	// 排序后上级目录总在其子孙之前，先确定外层仓库
+	sort.Strings(candidates)
+
+	repos := make(map[string]bool)
+	prunedDirs := make(map[string]bool)
+	// skippedUnder 判断 dir 是否位于已发现的仓库或被跳过的目录之内（不含 dir 本身）
+	skippedUnder := func(dir string) bool {
+		var ancestors []string
+		for p := filepath.Dir(dir); p != dir; dir, p = p, filepath.Dir(p) {
+			ancestors = append(ancestors, p)
+		}
+		// 从驱动器根目录向下检查，与逐个目录查找时遇到的顺序一致
+		for i := len(ancestors) - 1; i >= 0; i-- {
+			a := ancestors[i]
+			if repos[a] {
+				return true
+			}
+			skip, ok := prunedDirs[a]
+			if !ok {
+				skip = !isDriveRoot(a) && pruned(excluder, a)
+				prunedDirs[a] = skip
+			}
+			if skip {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, dir := range candidates {
+		if skippedUnder(dir) || pruned(excluder, dir) {
+			continue
+		}
+		discovery.visit(dir)
+		// MFT 中可能残留已删除的记录，按实际目录内容确认
+		if !isGitRepo(dir) {
+			continue
+		}
+		repos[dir] = true
+		if !excluded(excluder, dir, dir) {
+			repoCount++
+			discovery.foundRepo()
+			found(dir)
+		}
+	}
+	return rest, repoCount
+}
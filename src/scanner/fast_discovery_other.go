@@ -0,0 +1,8 @@
+//go:build !windows
+
+package scanner
+
+// enumerateRepoCandidates 只在 Windows 的 NTFS 卷上可用
+func enumerateRepoCandidates(volume string) ([]string, error) {
+	return nil, errNoJournal
+}
@@ -0,0 +1,127 @@
+//go:build windows
+
+package scanner
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// mftEnumData MFT_ENUM_DATA_V0
+type mftEnumData struct {
+	StartFileReferenceNumber uint64
+	LowUsn                   int64
+	HighUsn                  int64
+}
+
+// mftDir MFT 中的一个目录：父目录的文件引用号和目录名
+type mftDir struct {
+	parent uint64
+	name   string
+}
+
+// enumerateRepoCandidates 通过 FSCTL_ENUM_USN_DATA 读取卷的 MFT，返回卷上所有包含 .git（目录或文件）的目录
+// 只读取文件名和父目录引用，不访问目录内容；需要管理员权限打开卷，只支持 NTFS
+func enumerateRepoCandidates(volume string) ([]string, error) {
+	h, err := openVolume(volume)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.CloseHandle(h)
+	rootRef, err := fileReference(volume + `\`)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 根目录信息失败: %w", volume, err)
+	}
+
+	// 日志未启用时枚举全部记录
+	enum := mftEnumData{HighUsn: math.MaxInt64}
+	if journal, err := queryJournal(h); err == nil {
+		enum.HighUsn = journal.NextUsn
+	}
+
+	dirs := make(map[uint64]mftDir)
+	var gitParents []uint64
+	buf := make([]byte, usnBufferSize)
+	var n uint32
+	for {
+		err := syscall.DeviceIoControl(h, fsctlEnumUsnData, (*byte)(unsafe.Pointer(&enum)), uint32(unsafe.Sizeof(enum)), &buf[0], uint32(len(buf)), &n, nil)
+		if err == errorHandleEOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("枚举卷 %s 的 MFT 失败: %w", volume, err)
+		}
+		if n <= 8 {
+			break
+		}
+		enum.StartFileReferenceNumber = binary.LittleEndian.Uint64(buf)
+
+		err = parseUsnRecords(buf[:n], func(rec usnRecord) {
+			if strings.EqualFold(rec.name, ".git") {
+				gitParents = append(gitParents, rec.parent)
+			}
+			if rec.attrs&syscall.FILE_ATTRIBUTE_DIRECTORY != 0 {
+				dirs[rec.ref] = mftDir{parent: rec.parent, name: rec.name}
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("卷 %s: %w", volume, err)
+		}
+	}
+
+	// 沿父目录引用拼出完整路径；链断开（已删除的目录等）的跳过
+	paths := map[uint64]string{rootRef: volume + `\`}
+	var resolve func(ref uint64, depth int) (string, bool)
+	resolve = func(ref uint64, depth int) (string, bool) {
+		if p, ok := paths[ref]; ok {
+			return p, true
+		}
+		dir, ok := dirs[ref]
+		if !ok || depth > 1024 {
+			return "", false
+		}
+		parent, ok := resolve(dir.parent, depth+1)
+		if !ok {
+			return "", false
+		}
+		p := filepath.Join(parent, dir.name)
+		paths[ref] = p
+		return p, true
+	}
+
+	seen := make(map[uint64]bool)
+	var candidates []string
+	for _, ref := range gitParents {
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		if p, ok := resolve(ref, 0); ok {
+			candidates = append(candidates, p)
+		}
+	}
+	return candidates, nil
+}
+
+// fileReference 返回文件或目录的文件引用号（与 MFT 记录中的父目录引用号一致）
+func fileReference(path string) (uint64, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	h, err := syscall.CreateFile(p, 0, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE, nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer syscall.CloseHandle(h)
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(h, &info); err != nil {
+		return 0, err
+	}
+	return uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow), nil
+}
@@ -0,0 +1,56 @@
+package scanner
+
+import "time"
+
+// ScanProgress 仓库发现阶段（广度优先遍历目录）的进度事件
+type ScanProgress struct {
+	Dir         string        // 当前访问的目录（绝对路径）
+	DirsVisited int           // 已访问的目录数
+	ReposFound  int           // 已发现的 Git 仓库数（不含被排除的仓库）
+	Elapsed     time.Duration // 遍历开始以来的耗时
+	Done        bool          // 遍历已结束（最后一个事件，Dir 为空）
+}
+
+// ProgressFunc 接收扫描进度事件的回调，在扫描协程中同步调用，不应阻塞
+type ProgressFunc func(ScanProgress)
+
+// discoveryProgress 统计遍历进度并发送事件
+type discoveryProgress struct {
+	fn    ProgressFunc
+	start time.Time
+	dirs  int
+	repos int
+}
+
+func newDiscoveryProgress(fn ProgressFunc) *discoveryProgress {
+	return &discoveryProgress{fn: fn, start: time.Now()}
+}
+
+// visit 记录访问一个目录
+func (p *discoveryProgress) visit(dir string) {
+	p.dirs++
+	p.emit(dir, false)
+}
+
+// foundRepo 记录发现一个仓库
+func (p *discoveryProgress) foundRepo() {
+	p.repos++
+}
+
+// done 发送遍历结束事件
+func (p *discoveryProgress) done() {
+	p.emit("", true)
+}
+
+func (p *discoveryProgress) emit(dir string, done bool) {
+	if p.fn == nil {
+		return
+	}
+	p.fn(ScanProgress{
+		Dir:         dir,
+		DirsVisited: p.dirs,
+		ReposFound:  p.repos,
+		Elapsed:     time.Since(p.start),
+		Done:        done,
+	})
+}
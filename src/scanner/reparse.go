@@ -0,0 +1,115 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// LinkKind 目录项的链接类型（见 ClassifyDirEntry）
+type LinkKind int
+
+const (
+	LinkNone    LinkKind = iota // 普通文件或目录
+	LinkDir                     // 指向目录的符号链接、目录联接（junction）或挂载点，可能指向扫描范围外或形成环路
+	LinkFile                    // 指向文件的符号链接，复制时按目标文件内容处理
+	LinkSpecial                 // 其他重解析点（AppExecLink 等），既不是目录也无法按普通文件读取
+)
+
+// ClassifyDirEntry 判断目录项是否为链接或重解析点
+// OneDrive 等云同步目录本身也是重解析点，但内容就在原位置，按普通目录处理
+func ClassifyDirEntry(path string, entry os.DirEntry) LinkKind {
+	if entry.Type()&(os.ModeSymlink|os.ModeIrregular) == 0 {
+		return LinkNone
+	}
+	return classifyLink(path, entry.Type())
+}
+
+// ResolveDirLink 返回目录链接目标的真实路径，以及跟随它是否安全：
+// 目标是链接所在目录自身或其上级目录（会形成环路），或者与已跟随过的目标重叠（重复扫描）时返回 false
+func ResolveDirLink(linkPath string, followed []string) (string, bool) {
+	target, err := RealPath(linkPath)
+	if err != nil {
+		return "", false
+	}
+	parent, err := RealPath(filepath.Dir(linkPath))
+	if err != nil || pathWithin(target, parent) {
+		return target, false
+	}
+	for _, f := range followed {
+		if pathWithin(f, target) || pathWithin(target, f) {
+			return target, false
+		}
+	}
+	return target, true
+}
+
+// RealPath 返回解析所有链接后的绝对路径
+func RealPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// pathWithin 判断 path 是否为 dir 自身或位于 dir 之下
+func pathWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// dirLinks 记录扫描仓库时遇到的目录链接：默认跳过；--reparse-points follow 时跟随，
+// 但目标位于搜索根目录之内（会被正常遍历到）、是其上级目录或已跟随过的不再跟随，避免环路和重复扫描
+type dirLinks struct {
+	follow   bool
+	followed []string // 搜索根目录和已跟随的链接目标（真实路径）
+	skipped  int      // 按策略跳过的链接数
+	loops    int      // 因环路或重复而未跟随的链接数
+}
+
+func newDirLinks(searchRoot string) *dirLinks {
+	d := &dirLinks{}
+	if cfg := config.GetGlobalConfig(); cfg != nil && cfg.ReparsePoints == config.ReparseFollow {
+		d.follow = true
+	}
+	if root, err := RealPath(searchRoot); err == nil {
+		d.followed = append(d.followed, root)
+	}
+	return d
+}
+
+// descend 判断是否进入子目录 entry
+func (d *dirLinks) descend(childDir string, entry os.DirEntry) bool {
+	switch ClassifyDirEntry(childDir, entry) {
+	case LinkNone:
+		return entry.IsDir()
+	case LinkDir:
+		if !d.follow {
+			d.skipped++
+			return false
+		}
+		target, ok := ResolveDirLink(childDir, d.followed)
+		if !ok {
+			d.loops++
+			return false
+		}
+		d.followed = append(d.followed, target)
+		return true
+	default:
+		return false
+	}
+}
+
+// report 输出本次扫描跳过的目录链接
+func (d *dirLinks) report() {
+	if d.skipped > 0 {
+		ui.Printf("跳过目录链接: %d 个（符号链接、目录联接等，指定 --reparse-points follow 可跟随）\n", d.skipped)
+	}
+	if d.loops > 0 {
+		ui.Printf("未跟随的目录链接: %d 个（指向搜索根目录内、其上级目录或已扫描过的目录，避免环路）\n", d.loops)
+	}
+}
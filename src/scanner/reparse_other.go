@@ -0,0 +1,16 @@
+//go:build !windows
+
+package scanner
+
+import "os"
+
+// classifyLink 区分符号链接指向的是目录还是文件；其他特殊文件按普通目录项处理
+func classifyLink(path string, mode os.FileMode) LinkKind {
+	if mode&os.ModeSymlink == 0 {
+		return LinkNone
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return LinkDir
+	}
+	return LinkFile
+}
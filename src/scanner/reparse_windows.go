@@ -0,0 +1,54 @@
+//go:build windows
+
+package scanner
+
+import (
+	"os"
+	"syscall"
+)
+
+// 重解析点标签（见 Windows SDK winnt.h）
+const (
+	ioReparseTagMountPoint = 0xA0000003 // 目录联接（junction）和卷挂载点
+	ioReparseTagSymlink    = 0xA000000C // 符号链接
+	ioReparseTagCloud      = 0x9000001A // 云文件（OneDrive 等），第 12~15 位为子类型
+	ioReparseTagCloudMask  = 0x0000F000
+)
+
+// reparseTag 读取路径的重解析点标签，不是重解析点时返回 0
+func reparseTag(path string) (uint32, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var data syscall.Win32finddata
+	h, err := syscall.FindFirstFile(p, &data)
+	if err != nil {
+		return 0, err
+	}
+	syscall.FindClose(h)
+	if data.FileAttributes&syscall.FILE_ATTRIBUTE_REPARSE_POINT == 0 {
+		return 0, nil
+	}
+	return data.Reserved0, nil
+}
+
+// classifyLink 按重解析点标签区分目录联接、符号链接、云文件占位符和 AppExecLink 等
+func classifyLink(path string, mode os.FileMode) LinkKind {
+	tag, err := reparseTag(path)
+	if err != nil {
+		return LinkSpecial
+	}
+	switch {
+	case tag == 0, tag&^ioReparseTagCloudMask == ioReparseTagCloud:
+		return LinkNone
+	case tag == ioReparseTagMountPoint, tag == ioReparseTagSymlink:
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return LinkDir
+		}
+		return LinkFile
+	default:
+		// AppExecLink、WSL 符号链接等无法当作目录遍历，也不能按普通文件读取
+		return LinkSpecial
+	}
+}
@@ -0,0 +1,44 @@
+package scanner
+
+import (
+	"sync"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+var (
+	truncatedMu    sync.Mutex
+	truncatedRepos []string // 本次扫描中被忽略的条目超过上限、只处理了一部分的仓库
+)
+
+// maxFilesPerRepo 返回每个仓库最多处理的被忽略条目数（--max-files-per-repo），0 表示不限制
+func maxFilesPerRepo() int {
+	if cfg := config.GetGlobalConfig(); cfg != nil {
+		return cfg.MaxFilesPerRepo
+	}
+	return 0
+}
+
+// TruncatedRepos 返回最近一次扫描中被忽略的条目超过 --max-files-per-repo、只处理了前一部分的仓库
+// 这些仓库的备份不完整，清理阶段不能据此判断哪些备份文件的源文件已不存在
+func TruncatedRepos() []string {
+	truncatedMu.Lock()
+	defer truncatedMu.Unlock()
+	return append([]string(nil), truncatedRepos...)
+}
+
+// resetTruncatedRepos 开始新的扫描前清空记录
+func resetTruncatedRepos() {
+	truncatedMu.Lock()
+	defer truncatedMu.Unlock()
+	truncatedRepos = nil
+}
+
+// recordTruncated 记录一个达到条目上限的仓库并输出警告
+func recordTruncated(repoRoot string, limit int) {
+	truncatedMu.Lock()
+	defer truncatedMu.Unlock()
+	truncatedRepos = append(truncatedRepos, repoRoot)
+	ui.Errorf("警告: 仓库 %s 被忽略的条目超过 %d 个（--max-files-per-repo），只处理前 %d 个，继续处理其他仓库\n", repoRoot, limit, limit)
+}
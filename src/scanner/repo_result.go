@@ -0,0 +1,78 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aogg/copy-ignore/src/exclude"
+)
+
+// repoFilesBuffer 每个仓库的文件通道的缓冲大小
+const repoFilesBuffer = 64
+
+// RepoResult 一个仓库的扫描结果：被忽略且未被排除的文件逐个发送到 Files，处理完后关闭
+// Err 在 Files 关闭后才有效：处理该仓库出错时为对应的错误，扫描被取消时为 ctx.Err()
+type RepoResult struct {
+	RepoRoot string
+	Files    <-chan IgnoredFileInfo
+	Err      error
+}
+
+// ScanRepos 并发扫描搜索根目录下的所有 Git 仓库，每开始处理一个仓库就发送一个 RepoResult，
+// 供需要按仓库分别处理（如每个仓库打包一个归档）的调用方使用
+// 调用方必须读完每个 RepoResult 的 Files（或取消 ctx），否则对应的工作协程会一直阻塞；
+// 所有仓库处理完后 results 关闭，随后 errc 收到查找仓库时的错误（成功为 nil）
+func ScanRepos(ctx context.Context, searchRoot string, excluder exclude.Excluder, progress ProgressFunc, numWorkers int) (<-chan *RepoResult, <-chan error) {
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	results := make(chan *RepoResult)
+	errc := make(chan error, 1)
+
+	go func() {
+		resetScanTimes()
+		resetTruncatedRepos()
+		resetDirtyRepos()
+
+		jobs := make(chan string, numWorkers*2)
+		var wg sync.WaitGroup
+		for i := 0; i < numWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for repoRoot := range jobs {
+					scanRepo(ctx, repoRoot, searchRoot, excluder, results)
+				}
+			}()
+		}
+
+		_, err := discoverRepositories(searchRoot, excluder, progress, func(repoRoot string) {
+			select {
+			case jobs <- repoRoot:
+			case <-ctx.Done():
+			}
+		})
+		close(jobs)
+		wg.Wait()
+		close(results)
+		if err == nil {
+			err = ctx.Err()
+		}
+		errc <- err
+	}()
+
+	return results, errc
+}
+
+// scanRepo 扫描单个仓库，先把它的 RepoResult 交给调用方，再逐个发送文件
+func scanRepo(ctx context.Context, repoRoot, searchRoot string, excluder exclude.Excluder, results chan<- *RepoResult) {
+	files := make(chan IgnoredFileInfo, repoFilesBuffer)
+	result := &RepoResult{RepoRoot: repoRoot, Files: files}
+	select {
+	case results <- result:
+	case <-ctx.Done():
+		return
+	}
+	result.Err = processRepository(ctx, repoRoot, searchRoot, excluder, files)
+	close(files)
+}
@@ -0,0 +1,14 @@
+package scanner
+
+import (
+	"github.com/aogg/copy-ignore/src/config"
+)
+
+// scanRoots 返回从搜索根目录开始扫描时实际遍历的起点
+// 搜索根目录参数含通配符或有多个时 searchRoot 是它们共同的上级目录，只扫描展开得到的目录
+func scanRoots(searchRoot string) []string {
+	if cfg := config.GetGlobalConfig(); cfg != nil && cfg.SearchRoot == searchRoot && len(cfg.ScanRoots) > 0 {
+		return append([]string(nil), cfg.ScanRoots...)
+	}
+	return []string{searchRoot}
+}
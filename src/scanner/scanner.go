@@ -10,7 +10,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/aogg/copy-ignore/src/errs"
+	"github.com/aogg/copy-ignore/src/exclude"
 	"github.com/aogg/copy-ignore/src/git"
+	"github.com/aogg/copy-ignore/src/ui"
 )
 
 // IgnoredFileInfo 表示一个被忽略的文件信息
@@ -21,19 +24,22 @@ type IgnoredFileInfo struct {
 }
 
 // ScanIgnoredFiles 扫描指定根目录下的所有 Git 仓库，并返回所有被忽略且未被排除的文件
-func ScanIgnoredFiles(searchRoot string, excluder interface{ ShouldExclude(path string) bool }) ([]IgnoredFileInfo, error) {
+func ScanIgnoredFiles(searchRoot string, excluder exclude.Excluder) ([]IgnoredFileInfo, error) {
 	return ScanIgnoredFilesWithProgress(searchRoot, excluder, nil)
 }
 
 // ScanIgnoredFilesWithProgress 扫描指定根目录下的所有 Git 仓库，并返回所有被忽略且未被排除的文件
-// progress 回调函数会在扫描过程中被调用，传入当前正在扫描的绝对路径
-func ScanIgnoredFilesWithProgress(searchRoot string, excluder interface{ ShouldExclude(path string) bool }, progress func(absPath string)) ([]IgnoredFileInfo, error) {
+// progress 回调函数会在遍历目录的过程中被调用，传入当前目录和已访问的目录数、已发现的仓库数等（见 ScanProgress）
+func ScanIgnoredFilesWithProgress(searchRoot string, excluder exclude.Excluder, progress ProgressFunc) ([]IgnoredFileInfo, error) {
 	var allFiles []IgnoredFileInfo
+	resetTruncatedRepos()
+	resetDirtyRepos()
+	limit := maxFilesPerRepo()
 
 	// 递归查找所有 Git 仓库
 	repos, err := findGitRepositoriesWithProgress(searchRoot, progress)
 	if err != nil {
-		return nil, fmt.Errorf("查找 Git 仓库失败: %v", err)
+		return nil, fmt.Errorf("查找 Git 仓库失败: %w", err)
 	}
 
 	if len(repos) == 0 {
@@ -42,28 +48,29 @@ func ScanIgnoredFilesWithProgress(searchRoot string, excluder interface{ ShouldE
 
 	// 对每个仓库，获取被忽略的文件列表
 	for _, repoRoot := range repos {
+		// .gitignore 有未提交修改时按 --dirty-gitignore 跳过
+		if skipDirtyGitignore(repoRoot) {
+			continue
+		}
+
 		// 第一步：检查仓库根目录下的直接子目录是否被忽略
 		// 这样可以一次性识别出整个被忽略的目录（如 demo/）
 		directIgnoredDirs := make(map[string]bool)
+		var repoEntries []IgnoredFileInfo // 该仓库中整体加入结果的被忽略目录
+		truncated := false
 
-		// 读取仓库根目录
-		rootEntries, err := os.ReadDir(repoRoot)
+		// 读取仓库根目录（从仓库内部开始扫描时读取扫描起点）
+		scopes := repoScopes(searchRoot, repoRoot)
+		rootDirs, err := topDirs(repoRoot, scopes)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "警告: 读取仓库目录 %s 失败: %v\n", repoRoot, err)
+			ui.Errorf("警告: 读取仓库目录 %s 失败: %v\n", repoRoot, err)
 			continue
 		}
 
 		// 检查每个直接子目录是否被忽略（只检查直接子目录，一次性批量处理）
-		for _, entry := range rootEntries {
-			if !entry.IsDir() {
-				continue // 只处理目录
-			}
-
-			dirName := entry.Name()
-			dirPath := filepath.Join(repoRoot, dirName)
-
+		for _, dirPath := range rootDirs {
 			// 应用排除规则
-			if excluder.ShouldExclude(dirPath) {
+			if excluded(excluder, dirPath, repoRoot) {
 				continue
 			}
 
@@ -83,33 +90,35 @@ func ScanIgnoredFilesWithProgress(searchRoot string, excluder interface{ ShouldE
 					relToSearchRoot = dirPath
 				}
 
+				// 达到每个仓库的条目上限时，剩余的目录不再加入结果（仍记入 directIgnoredDirs，其中的文件不会单独加入）
+				if limit > 0 && len(repoEntries) >= limit {
+					truncated = true
+					continue
+				}
+
 				// 添加目录到结果
 				dirInfo := IgnoredFileInfo{
 					AbsPath:      dirPath,
 					RelativePath: relToSearchRoot,
 					RepoRoot:     repoRoot,
 				}
-				allFiles = append(allFiles, dirInfo)
+				repoEntries = append(repoEntries, dirInfo)
 			}
 		}
 
-		// 第二步：获取被忽略的文件列表
-		files, err := git.ListIgnoredFiles(repoRoot)
-		if err != nil {
-			// 如果某个仓库失败，继续处理其他仓库，但记录警告
-			fmt.Fprintf(os.Stderr, "警告: 处理仓库 %s 时出错: %v\n", repoRoot, err)
-			continue
-		}
-
-		// 收集所有被忽略且未被排除的文件
+		// 第二步：逐个读取被忽略的文件，收集被忽略且未被排除的文件
 		var repoFiles []IgnoredFileInfo
-
-		for _, relPath := range files {
+		err = git.EachIgnoredFile(repoRoot, func(relPath string) bool {
 			absPath := filepath.Join(repoRoot, relPath)
 
+			// 从仓库内部开始扫描时只处理扫描起点之内的文件
+			if !inScopes(absPath, scopes) {
+				return true
+			}
+
 			// 应用排除规则
-			if excluder.ShouldExclude(absPath) {
-				continue
+			if excluded(excluder, absPath, repoRoot) {
+				return true
 			}
 
 			// 检查文件是否在任何被忽略的直接子目录下
@@ -119,11 +128,18 @@ func ScanIgnoredFilesWithProgress(searchRoot string, excluder interface{ ShouldE
 				prefix := ignoredDir + string(filepath.Separator)
 				if strings.HasPrefix(absPath, prefix) || absPath == ignoredDir {
 					skipFile = true
+					reportAggregated(absPath, repoRoot, ignoredDir)
 					break
 				}
 			}
 			if skipFile {
-				continue
+				return true
+			}
+
+			// 达到每个仓库的条目上限，停止枚举该仓库
+			if limit > 0 && len(repoEntries)+len(repoFiles) >= limit {
+				truncated = true
+				return false
 			}
 
 			// 计算相对于搜索根目录的相对路径
@@ -140,6 +156,16 @@ func ScanIgnoredFilesWithProgress(searchRoot string, excluder interface{ ShouldE
 			}
 
 			repoFiles = append(repoFiles, fileInfo)
+			return true
+		})
+		if truncated {
+			recordTruncated(repoRoot, limit)
+		}
+		allFiles = append(allFiles, repoEntries...)
+		if err != nil {
+			// 如果某个仓库失败，继续处理其他仓库，但记录警告
+			ui.Errorf("警告: 处理仓库 %s 时出错: %v\n", repoRoot, err)
+			continue
 		}
 
 		// 过滤掉被父目录包含的文件（聚合优化）
@@ -156,14 +182,17 @@ func ScanIgnoredFilesWithProgress(searchRoot string, excluder interface{ ShouldE
 
 // ScanIgnoredFilesWithProgressStream 扫描指定根目录下的所有 Git 仓库，
 // 将发现的文件实时发送到fileChan，支持进度回调
-func ScanIgnoredFilesWithProgressStream(searchRoot string, excluder interface{ ShouldExclude(path string) bool }, progress func(absPath string), fileChan chan<- IgnoredFileInfo) error {
+func ScanIgnoredFilesWithProgressStream(searchRoot string, excluder exclude.Excluder, progress ProgressFunc, fileChan chan<- IgnoredFileInfo) error {
 	return ScanIgnoredFilesWithProgressStreamConcurrent(searchRoot, excluder, progress, fileChan, runtime.NumCPU())
 }
 
 // ScanIgnoredFilesWithProgressStreamConcurrent 并发扫描指定根目录下的所有 Git 仓库，
 // 将发现的文件实时发送到fileChan，支持进度回调和并发处理
-func ScanIgnoredFilesWithProgressStreamConcurrent(searchRoot string, excluder interface{ ShouldExclude(path string) bool }, progress func(absPath string), fileChan chan<- IgnoredFileInfo, numWorkers int) error {
+func ScanIgnoredFilesWithProgressStreamConcurrent(searchRoot string, excluder exclude.Excluder, progress ProgressFunc, fileChan chan<- IgnoredFileInfo, numWorkers int) error {
 	ctx := context.Background()
+	resetScanTimes()
+	resetTruncatedRepos()
+	resetDirtyRepos()
 
 	// 创建任务通道，缓冲大小为 numWorkers*2 以减少阻塞
 	jobs := make(chan string, numWorkers*2)
@@ -187,20 +216,74 @@ func ScanIgnoredFilesWithProgressStreamConcurrent(searchRoot string, excluder in
 		}()
 	}
 
-	fmt.Println()
-	fmt.Println("开始扫描 Git 仓库")
+	ui.Println()
+	ui.Println("开始扫描 Git 仓库")
 	// 开始时间
 	startTime := time.Now()
-	fmt.Printf("开始时间: %s\n", startTime.Format("2006-01-02 15:04:05.000"))
-	fmt.Printf("搜索根目录: %s\n", searchRoot)
-	fmt.Printf("排除规则: %v\n", excluder)
-	fmt.Println()
+	ui.Printf("开始时间: %s\n", startTime.Format("2006-01-02 15:04:05.000"))
+	ui.Printf("搜索根目录: %s\n", searchRoot)
+	ui.Printf("排除规则: %v\n", excluder)
+	ui.Println()
+
+	repoCount, err := discoverRepositories(searchRoot, excluder, progress, func(repoRoot string) {
+		wg.Add(1)
+		jobs <- repoRoot
+	})
+	if err != nil {
+		close(jobs)
+		wg.Wait()
+		return err
+	}
+
+	// 输出详细
+	ui.Println()
+	ui.Printf("Git 仓库数量: %d\n", repoCount)
+
+	if repoCount > 0 {
+		ui.Println()
+		ui.Println()
+		ui.Println("开始并发扫描 Git 仓库")
+	}
+
+	// 关闭任务通道，表示不再发送新任务
+	close(jobs)
 
+	// 等待所有仓库处理完成
+	wg.Wait()
+
+	ui.Println()
+	ui.Println("所有仓库处理完成")
+	ui.Printf("扫描结束时间: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+
+	return nil
+}
+
+// discoverRepositories 广度优先查找搜索根目录下未被排除的 Git 仓库，每发现一个就调用 found，返回仓库数量
+// 仓库的子孙目录不再查找；目录链接按 --reparse-points 策略处理
+func discoverRepositories(searchRoot string, excluder exclude.Excluder, progress ProgressFunc, found func(repoRoot string)) (int, error) {
 	// 使用队列实现广度优先搜索，同时在发现仓库时应用排除规则
-	queue := []string{searchRoot}
+	queue, enclosing := startDirs(searchRoot)
 	visited := make(map[string]bool)
+	links := newDirLinks(searchRoot)
+	discovery := newDiscoveryProgress(progress)
 	repoCount := 0
 
+	// 从仓库内部开始扫描时，包含扫描起点的仓库直接处理
+	for _, repo := range enclosing {
+		if !excluded(excluder, repo, repo) {
+			repoCount++
+			discovery.foundRepo()
+			found(repo)
+		}
+	}
+
+	// 开启 --fast-discovery 时，整个驱动器通过 MFT 查找仓库
+	if fastDiscoveryEnabled() {
+		var n int
+		queue, n = discoverFast(queue, excluder, discovery, found)
+		repoCount += n
+	}
+
 	for len(queue) > 0 {
 		currentDir := queue[0]
 		queue = queue[1:]
@@ -212,17 +295,15 @@ func ScanIgnoredFilesWithProgressStreamConcurrent(searchRoot string, excluder in
 		visited[currentDir] = true
 
 		// 调用进度回调
-		if progress != nil {
-			progress(currentDir)
-		}
+		discovery.visit(currentDir)
 
 		// 先判断当前目录是否为 Git 仓库
 		if isGitRepo(currentDir) {
 			// 应用排除规则到仓库根目录
-			if !excluder.ShouldExclude(currentDir) {
+			if !excluded(excluder, currentDir, currentDir) {
 				repoCount++
-				wg.Add(1)
-				jobs <- currentDir
+				discovery.foundRepo()
+				found(currentDir)
 			}
 			// 如果是 Git 仓库，后续就不需要扫描这个文件夹的子孙了
 			continue
@@ -235,13 +316,14 @@ func ScanIgnoredFilesWithProgressStreamConcurrent(searchRoot string, excluder in
 			if os.IsPermission(err) {
 				continue
 			}
-			return err
+			return repoCount, &errs.RepoAccessError{Path: currentDir, Err: err}
 		}
 
 		// 将子目录添加到队列中（广度优先）
 		for _, entry := range entries {
-			if entry.IsDir() {
-				childDir := filepath.Join(currentDir, entry.Name())
+			childDir := filepath.Join(currentDir, entry.Name())
+			// 目录链接（junction、符号链接）按 --reparse-points 策略处理；备份根目录等工具自身的目录不进入
+			if links.descend(childDir, entry) && !pruned(excluder, childDir) {
 				// 确保不超出搜索根目录
 				if rel, err := filepath.Rel(searchRoot, childDir); err == nil && !strings.HasPrefix(rel, "..") {
 					queue = append(queue, childDir)
@@ -250,78 +332,65 @@ func ScanIgnoredFilesWithProgressStreamConcurrent(searchRoot string, excluder in
 		}
 	}
 
-	// 输出详细
-	fmt.Println()
-	fmt.Printf("Git 仓库数量: %d\n", repoCount)
+	discovery.done()
+	links.report()
+	return repoCount, nil
+}
 
-	if repoCount > 0 {
-		fmt.Println()
-		fmt.Println()
-		fmt.Println("开始并发扫描 Git 仓库")
+// processRepository 处理单个 Git 仓库，获取被忽略的文件并发送到 fileChan，返回处理该仓库时的错误（ctx 取消时返回 ctx.Err()）
+func processRepository(ctx context.Context, repoRoot, searchRoot string, excluder exclude.Excluder, fileChan chan<- IgnoredFileInfo) (processError error) {
+	// 开启 --skip-unchanged 时，自上次运行以来没有变化的仓库不再列出被忽略的文件
+	if skipUnchanged(repoRoot) {
+		return nil
 	}
 
-	// 关闭任务通道，表示不再发送新任务
-	close(jobs)
-
-	// 等待所有仓库处理完成
-	wg.Wait()
-
-	fmt.Println()
-	fmt.Println("所有仓库处理完成")
-	fmt.Printf("扫描结束时间: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-
-	return nil
-}
+	// .gitignore 有未提交修改时按 --dirty-gitignore 跳过
+	if skipDirtyGitignore(repoRoot) {
+		return nil
+	}
 
-// processRepository 处理单个 Git 仓库，获取被忽略的文件并发送到 fileChan
-func processRepository(ctx context.Context, repoRoot, searchRoot string, excluder interface{ ShouldExclude(path string) bool }, fileChan chan<- IgnoredFileInfo) {
 	startTime := time.Now()
 	fileCount := 0
-	var processError error
+	limit := maxFilesPerRepo()
+	truncated := false
+	beginRepoScan(repoRoot, startTime)
 
 	defer func() {
 		endTime := time.Now()
 		duration := endTime.Sub(startTime)
-
-		// 处理完成后立即输出结果
-		if processError == nil {
-			fmt.Printf("✓ 仓库: %s\n", repoRoot)
-			fmt.Printf("  开始时间: %s\n", startTime.Format("2006-01-02 15:04:05.000"))
-			fmt.Printf("  结束时间: %s\n", endTime.Format("2006-01-02 15:04:05.000"))
-			fmt.Printf("  处理耗时: %v\n", duration)
-			fmt.Printf("  发现文件: %d 个\n", fileCount)
-		} else {
-			fmt.Printf("✗ 仓库: %s\n", repoRoot)
-			fmt.Printf("  开始时间: %s\n", startTime.Format("2006-01-02 15:04:05.000"))
-			fmt.Printf("  结束时间: %s\n", endTime.Format("2006-01-02 15:04:05.000"))
-			fmt.Printf("  处理耗时: %v\n", duration)
-			fmt.Printf("  错误: %v\n", processError)
-		}
-		fmt.Println()
+		endRepoScan(repoRoot)
+		recordScanTime(repoRoot, duration)
+		if truncated {
+			recordTruncated(repoRoot, limit)
+		}
+
+		// 处理完成后立即输出结果（作为一条消息输出，避免与其他仓库的结果交错）
+		mark, result := "✓", fmt.Sprintf("  发现文件: %d 个", fileCount)
+		if processError != nil {
+			mark, result = "✗", fmt.Sprintf("  错误: %v", processError)
+		}
+		ui.Printf("%s 仓库: %s\n  开始时间: %s\n  结束时间: %s\n  处理耗时: %v\n%s\n\n",
+			mark, repoRoot,
+			startTime.Format("2006-01-02 15:04:05.000"),
+			endTime.Format("2006-01-02 15:04:05.000"),
+			duration, result)
 	}()
 
 	// 第一步：检查仓库根目录下的直接子目录是否被忽略
 	directIgnoredDirs := make(map[string]bool)
 
-	// 读取仓库根目录
-	rootEntries, err := os.ReadDir(repoRoot)
+	// 读取仓库根目录（从仓库内部开始扫描时读取扫描起点）
+	scopes := repoScopes(searchRoot, repoRoot)
+	rootDirs, err := topDirs(repoRoot, scopes)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "警告: 读取仓库目录 %s 失败: %v\n", repoRoot, err)
-		processError = err
-		return
+		ui.Errorf("警告: 读取仓库目录 %s 失败: %v\n", repoRoot, err)
+		return &errs.RepoAccessError{Path: repoRoot, Err: err}
 	}
 
 	// 检查每个直接子目录是否被忽略
-	for _, entry := range rootEntries {
-		if !entry.IsDir() {
-			continue // 只处理目录
-		}
-
-		dirName := entry.Name()
-		dirPath := filepath.Join(repoRoot, dirName)
-
+	for _, dirPath := range rootDirs {
 		// 应用排除规则
-		if excluder.ShouldExclude(dirPath) {
+		if excluded(excluder, dirPath, repoRoot) {
 			continue
 		}
 
@@ -340,6 +409,12 @@ func processRepository(ctx context.Context, repoRoot, searchRoot string, exclude
 				relToSearchRoot = dirPath
 			}
 
+			// 达到每个仓库的条目上限时，剩余的目录不再发送（仍记入 directIgnoredDirs，其中的文件不会单独发送）
+			if limit > 0 && fileCount >= limit {
+				truncated = true
+				continue
+			}
+
 			// 立即发送到复制channel
 			dirInfo := IgnoredFileInfo{
 				AbsPath:      dirPath,
@@ -350,26 +425,24 @@ func processRepository(ctx context.Context, repoRoot, searchRoot string, exclude
 			case fileChan <- dirInfo:
 				fileCount++
 			case <-ctx.Done():
-				return
+				return ctx.Err()
 			}
 		}
 	}
 
-	// 第二步：获取被忽略的文件列表
-	files, err := git.ListIgnoredFiles(repoRoot)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "警告: 处理仓库 %s 时出错: %v\n", repoRoot, err)
-		processError = err
-		return
-	}
-
-	// 处理每个被忽略的文件
-	for _, relPath := range files {
+	// 第二步：逐个读取被忽略的文件，立即发送到复制channel
+	cancelled := false
+	err = git.EachIgnoredFile(repoRoot, func(relPath string) bool {
 		absPath := filepath.Join(repoRoot, relPath)
 
+		// 从仓库内部开始扫描时只处理扫描起点之内的文件
+		if !inScopes(absPath, scopes) {
+			return true
+		}
+
 		// 应用排除规则
-		if excluder.ShouldExclude(absPath) {
-			continue
+		if excluded(excluder, absPath, repoRoot) {
+			return true
 		}
 
 		// 检查文件是否在任何被忽略的直接子目录下
@@ -378,11 +451,18 @@ func processRepository(ctx context.Context, repoRoot, searchRoot string, exclude
 			prefix := ignoredDir + string(filepath.Separator)
 			if strings.HasPrefix(absPath, prefix) || absPath == ignoredDir {
 				skipFile = true
+				reportAggregated(absPath, repoRoot, ignoredDir)
 				break
 			}
 		}
 		if skipFile {
-			continue
+			return true
+		}
+
+		// 达到每个仓库的条目上限，停止枚举该仓库
+		if limit > 0 && fileCount >= limit {
+			truncated = true
+			return false
 		}
 
 		// 计算相对于搜索根目录的相对路径
@@ -401,10 +481,20 @@ func processRepository(ctx context.Context, repoRoot, searchRoot string, exclude
 		select {
 		case fileChan <- fileInfo:
 			fileCount++
+			return true
 		case <-ctx.Done():
-			return
+			cancelled = true
+			return false
 		}
+	})
+	if cancelled {
+		return ctx.Err()
 	}
+	if err != nil {
+		ui.Errorf("警告: 处理仓库 %s 时出错: %v\n", repoRoot, err)
+		return &errs.RepoAccessError{Path: repoRoot, Err: err}
+	}
+	return nil
 }
 
 // findGitRepositories 递归查找指定目录下的所有 Git 仓库
@@ -414,14 +504,21 @@ func findGitRepositories(root string) ([]string, error) {
 }
 
 // findGitRepositoriesWithProgress 广度优先查找指定目录下的所有 Git 仓库
-// progress 回调函数会在遍历过程中被调用，传入当前正在扫描的绝对路径
+// progress 回调函数会在遍历过程中被调用，传入当前目录和已访问的目录数、已发现的仓库数等（见 ScanProgress）
 // 返回所有找到的仓库根目录列表
-func findGitRepositoriesWithProgress(root string, progress func(absPath string)) ([]string, error) {
+func findGitRepositoriesWithProgress(root string, progress ProgressFunc) ([]string, error) {
 	var repos []string
 
 	// 使用队列实现广度优先搜索
-	queue := []string{root}
+	// 从仓库内部开始扫描时，包含扫描起点的仓库直接处理
+	queue, enclosing := startDirs(root)
+	repos = append(repos, enclosing...)
 	visited := make(map[string]bool)
+	links := newDirLinks(root)
+	discovery := newDiscoveryProgress(progress)
+	for range enclosing {
+		discovery.foundRepo()
+	}
 
 	for len(queue) > 0 {
 		currentDir := queue[0]
@@ -434,13 +531,12 @@ func findGitRepositoriesWithProgress(root string, progress func(absPath string))
 		visited[currentDir] = true
 
 		// 调用进度回调
-		if progress != nil {
-			progress(currentDir)
-		}
+		discovery.visit(currentDir)
 
 		// 先判断当前目录是否为 Git 仓库
 		if isGitRepo(currentDir) {
 			repos = append(repos, currentDir)
+			discovery.foundRepo()
 			// 如果是 Git 仓库，后续就不需要扫描这个文件夹的子孙了
 			continue
 		}
@@ -452,13 +548,13 @@ func findGitRepositoriesWithProgress(root string, progress func(absPath string))
 			if os.IsPermission(err) {
 				continue
 			}
-			return nil, err
+			return nil, &errs.RepoAccessError{Path: currentDir, Err: err}
 		}
 
 		// 将子目录添加到队列中（广度优先）
 		for _, entry := range entries {
-			if entry.IsDir() {
-				childDir := filepath.Join(currentDir, entry.Name())
+			childDir := filepath.Join(currentDir, entry.Name())
+			if links.descend(childDir, entry) {
 				// 确保不超出搜索根目录
 				if rel, err := filepath.Rel(root, childDir); err == nil && !strings.HasPrefix(rel, "..") {
 					queue = append(queue, childDir)
@@ -466,6 +562,7 @@ func findGitRepositoriesWithProgress(root string, progress func(absPath string))
 			}
 		}
 	}
+	discovery.done()
 
 	return repos, nil
 }
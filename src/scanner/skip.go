@@ -0,0 +1,75 @@
+package scanner
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/aogg/copy-ignore/src/exclude"
+)
+
+// SkipEvent 扫描时被过滤掉的候选路径及原因（用于 --explain）
+type SkipEvent struct {
+	Path     string // 被过滤的路径（绝对路径）
+	RepoRoot string // 所在仓库的根目录；被排除的是仓库本身时与 Path 相同，发现仓库时跳过的目录为空
+	Reason   string // 过滤原因
+}
+
+var (
+	skipMu      sync.RWMutex
+	skipHandler func(SkipEvent)
+)
+
+// SetSkipHandler 设置接收被过滤路径的回调，nil 表示不接收（默认）；回调可能在多个扫描协程中并发调用
+func SetSkipHandler(fn func(SkipEvent)) {
+	skipMu.Lock()
+	skipHandler = fn
+	skipMu.Unlock()
+}
+
+// reportSkip 将被过滤的路径交给回调
+func reportSkip(path, repoRoot, reason string) {
+	skipMu.RLock()
+	fn := skipHandler
+	skipMu.RUnlock()
+	if fn != nil {
+		fn(SkipEvent{Path: path, RepoRoot: repoRoot, Reason: reason})
+	}
+}
+
+// excluded 判断路径是否被排除规则排除，排除时报告原因
+func excluded(excluder exclude.Excluder, path, repoRoot string) bool {
+	reason, ok := exclude.Reason(excluder, path)
+	if !ok {
+		return false
+	}
+	if reason == "" {
+		reason = "被排除规则排除"
+	} else {
+		reason = "排除规则: " + reason
+	}
+	reportSkip(path, repoRoot, reason)
+	return true
+}
+
+// pruned 判断发现仓库时是否整个跳过该目录（如位于搜索根目录之内的备份根目录），跳过时报告原因
+func pruned(excluder exclude.Excluder, dir string) bool {
+	pruner, ok := excluder.(exclude.DirPruner)
+	if !ok {
+		return false
+	}
+	reason, ok := pruner.PruneDir(dir)
+	if !ok {
+		return false
+	}
+	reportSkip(dir, "", reason)
+	return true
+}
+
+// reportAggregated 报告因所在目录已整体复制而不单独列出的文件
+func reportAggregated(path, repoRoot, dir string) {
+	rel, err := filepath.Rel(repoRoot, dir)
+	if err != nil {
+		rel = dir
+	}
+	reportSkip(path, repoRoot, "已包含在被忽略的目录 "+rel+string(filepath.Separator)+" 中（随目录整体复制）")
+}
@@ -0,0 +1,62 @@
+package scanner
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	scanTimesMu sync.Mutex
+	scanTimes   = make(map[string]time.Duration) // 仓库根目录 -> 本次扫描中处理该仓库的耗时
+	activeScans = make(map[string]time.Time)     // 仓库根目录 -> 开始处理的时间（正在处理的仓库）
+)
+
+// RepoScanTimes 返回最近一次流式扫描中各仓库的处理耗时（查询被忽略的文件等）
+func RepoScanTimes() map[string]time.Duration {
+	scanTimesMu.Lock()
+	defer scanTimesMu.Unlock()
+	times := make(map[string]time.Duration, len(scanTimes))
+	for repo, d := range scanTimes {
+		times[repo] = d
+	}
+	return times
+}
+
+// resetScanTimes 开始新的扫描前清空耗时记录
+func resetScanTimes() {
+	scanTimesMu.Lock()
+	defer scanTimesMu.Unlock()
+	scanTimes = make(map[string]time.Duration)
+}
+
+// recordScanTime 记录一个仓库的处理耗时
+func recordScanTime(repoRoot string, d time.Duration) {
+	scanTimesMu.Lock()
+	defer scanTimesMu.Unlock()
+	scanTimes[repoRoot] = d
+}
+
+// beginRepoScan 记录开始处理一个仓库
+func beginRepoScan(repoRoot string, started time.Time) {
+	scanTimesMu.Lock()
+	defer scanTimesMu.Unlock()
+	activeScans[repoRoot] = started
+}
+
+// endRepoScan 记录一个仓库处理结束
+func endRepoScan(repoRoot string) {
+	scanTimesMu.Lock()
+	defer scanTimesMu.Unlock()
+	delete(activeScans, repoRoot)
+}
+
+// ActiveRepoScans 返回正在处理的仓库及已处理的时间（用于卡住检测时说明扫描停在哪里，如 git 命令无响应）
+func ActiveRepoScans() map[string]time.Duration {
+	scanTimesMu.Lock()
+	defer scanTimesMu.Unlock()
+	active := make(map[string]time.Duration, len(activeScans))
+	for repo, started := range activeScans {
+		active[repo] = time.Since(started)
+	}
+	return active
+}
@@ -0,0 +1,40 @@
+package scanner
+
+import "sync"
+
+var (
+	unchangedMu     sync.Mutex
+	unchangedFilter func(repoRoot string) bool
+	unchangedRepos  []string // 本次扫描中自上次运行以来没有变化而跳过的仓库
+)
+
+// SetUnchangedFilter 设置判断仓库自上次运行以来是否没有变化的回调，nil 表示不判断（默认）
+// 没有变化的仓库不列出被忽略的文件（不执行 git），其已有的备份不复制也不清理
+func SetUnchangedFilter(fn func(repoRoot string) bool) {
+	unchangedMu.Lock()
+	defer unchangedMu.Unlock()
+	unchangedFilter = fn
+	unchangedRepos = nil
+}
+
+// skipUnchanged 仓库自上次运行以来没有变化时记录该仓库并返回 true
+func skipUnchanged(repoRoot string) bool {
+	unchangedMu.Lock()
+	fn := unchangedFilter
+	unchangedMu.Unlock()
+	if fn == nil || !fn(repoRoot) {
+		return false
+	}
+	unchangedMu.Lock()
+	unchangedRepos = append(unchangedRepos, repoRoot)
+	unchangedMu.Unlock()
+	reportSkip(repoRoot, repoRoot, "自上次运行以来没有变化（--skip-unchanged）")
+	return true
+}
+
+// UnchangedRepos 返回最近一次扫描中因自上次运行以来没有变化而跳过的仓库
+func UnchangedRepos() []string {
+	unchangedMu.Lock()
+	defer unchangedMu.Unlock()
+	return append([]string(nil), unchangedRepos...)
+}
@@ -0,0 +1,19 @@
+package scanner
+
+// USNPosition 卷的 USN 日志位置（日志重建后 JournalID 变化，之前的位置失效）
+type USNPosition struct {
+	Volume    string `json:"volume"`     // 驱动器，如 C:
+	JournalID uint64 `json:"journal_id"` // 日志编号
+	NextUsn   int64  `json:"next_usn"`   // 下一条记录的 USN
+}
+
+// QueryUSNPosition 返回卷的 USN 日志当前位置（只支持 Windows 上启用了 USN 日志的 NTFS 卷，需要管理员权限）
+func QueryUSNPosition(volume string) (USNPosition, error) {
+	return queryUSNPosition(volume)
+}
+
+// USNChanges 返回卷上从 since 到 until（不含）之间有变化（创建、修改、删除、重命名等）的路径
+// 日志已重建或上次的位置已被覆盖时返回错误，调用方应视为所有内容都可能有变化
+func USNChanges(since USNPosition, until int64) ([]string, error) {
+	return readUSNChanges(since, until)
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package scanner
+
+import "errors"
+
+// errNoJournal 非 Windows 系统上没有 USN 日志
+var errNoJournal = errors.New("只支持 Windows 上的 NTFS 卷")
+
+func queryUSNPosition(volume string) (USNPosition, error) {
+	return USNPosition{}, errNoJournal
+}
+
+func readUSNChanges(since USNPosition, until int64) ([]string, error) {
+	return nil, errNoJournal
+}
@@ -0,0 +1,220 @@
+//go:build windows
+
+package scanner
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// 读取 MFT 和 USN 日志使用的控制码和错误码（见 Windows SDK winioctl.h、winerror.h）
+const (
+	fsctlEnumUsnData     = 0x000900B3
+	fsctlReadUsnJournal  = 0x000900BB
+	fsctlQueryUsnJournal = 0x000900F4
+	errorHandleEOF       = syscall.Errno(38)
+)
+
+// usnBufferSize 每次读取 MFT 或 USN 日志的缓冲区大小
+const usnBufferSize = 1 << 20
+
+// usnJournalData USN_JOURNAL_DATA_V0
+type usnJournalData struct {
+	UsnJournalID    uint64
+	FirstUsn        int64
+	NextUsn         int64
+	LowestValidUsn  int64
+	MaxUsn          int64
+	MaximumSize     uint64
+	AllocationDelta uint64
+}
+
+// readUsnJournalData READ_USN_JOURNAL_DATA_V0
+type readUsnJournalData struct {
+	StartUsn          int64
+	ReasonMask        uint32
+	ReturnOnlyOnClose uint32
+	Timeout           uint64
+	BytesToWaitFor    uint64
+	UsnJournalID      uint64
+}
+
+// fileIDDescriptor FILE_ID_DESCRIPTOR（Type 为 FileIdType，联合体按最大的 128 位成员占 16 字节）
+type fileIDDescriptor struct {
+	Size   uint32
+	Type   uint32
+	FileID uint64
+	_      uint64
+}
+
+// usnRecord USN_RECORD_V2 中用到的字段
+type usnRecord struct {
+	ref    uint64 // 文件引用号
+	parent uint64 // 父目录的文件引用号
+	usn    int64
+	attrs  uint32
+	name   string
+}
+
+var (
+	modkernel32                   = syscall.NewLazyDLL("kernel32.dll")
+	procOpenFileByID              = modkernel32.NewProc("OpenFileById")
+	procGetFinalPathNameByHandleW = modkernel32.NewProc("GetFinalPathNameByHandleW")
+)
+
+// openVolume 打开驱动器（如 C:）对应的卷，读取 MFT 和 USN 日志需要管理员权限
+func openVolume(volume string) (syscall.Handle, error) {
+	if len(volume) != 2 || volume[1] != ':' {
+		return syscall.InvalidHandle, fmt.Errorf("%s 不是本地驱动器", volume)
+	}
+	path, err := syscall.UTF16PtrFromString(`\\.\` + volume)
+	if err != nil {
+		return syscall.InvalidHandle, err
+	}
+	h, err := syscall.CreateFile(path, syscall.GENERIC_READ, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE, nil, syscall.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return syscall.InvalidHandle, fmt.Errorf("打开卷 %s 失败（需要以管理员身份运行）: %w", volume, err)
+	}
+	return h, nil
+}
+
+// queryJournal 查询卷的 USN 日志（未启用日志时返回错误）
+func queryJournal(h syscall.Handle) (usnJournalData, error) {
+	var journal usnJournalData
+	var n uint32
+	err := syscall.DeviceIoControl(h, fsctlQueryUsnJournal, nil, 0, (*byte)(unsafe.Pointer(&journal)), uint32(unsafe.Sizeof(journal)), &n, nil)
+	return journal, err
+}
+
+// parseUsnRecords 解析 FSCTL_ENUM_USN_DATA、FSCTL_READ_USN_JOURNAL 返回的记录（跳过开头 8 字节的续读位置）
+// 只支持 USN_RECORD_V2；ReFS 等使用 128 位文件引用号（USN_RECORD_V3），返回错误
+func parseUsnRecords(buf []byte, fn func(rec usnRecord)) error {
+	// 文件引用号在 8，父目录引用号在 16，USN 在 24，属性在 52，文件名长度和偏移在 56、58
+	for off := 8; off+60 <= len(buf); {
+		rec := buf[off:]
+		length := int(binary.LittleEndian.Uint32(rec))
+		if length < 60 || length > len(rec) {
+			break
+		}
+		if major := binary.LittleEndian.Uint16(rec[4:]); major != 2 {
+			return fmt.Errorf("不是 NTFS 卷（记录版本 %d）", major)
+		}
+		nameLen := int(binary.LittleEndian.Uint16(rec[56:]))
+		nameOff := int(binary.LittleEndian.Uint16(rec[58:]))
+		if nameOff+nameLen <= length {
+			fn(usnRecord{
+				ref:    binary.LittleEndian.Uint64(rec[8:]),
+				parent: binary.LittleEndian.Uint64(rec[16:]),
+				usn:    int64(binary.LittleEndian.Uint64(rec[24:])),
+				attrs:  binary.LittleEndian.Uint32(rec[52:]),
+				name:   utf16Name(rec[nameOff : nameOff+nameLen]),
+			})
+		}
+		off += length
+	}
+	return nil
+}
+
+// utf16Name 将记录中的 UTF-16LE 文件名转换为字符串
+func utf16Name(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// queryUSNPosition 返回卷的 USN 日志当前位置
+func queryUSNPosition(volume string) (USNPosition, error) {
+	h, err := openVolume(volume)
+	if err != nil {
+		return USNPosition{}, err
+	}
+	defer syscall.CloseHandle(h)
+	journal, err := queryJournal(h)
+	if err != nil {
+		return USNPosition{}, fmt.Errorf("卷 %s 没有启用 USN 日志: %w", volume, err)
+	}
+	return USNPosition{Volume: volume, JournalID: journal.UsnJournalID, NextUsn: journal.NextUsn}, nil
+}
+
+// readUSNChanges 读取 USN 日志中从 since 到 until 之间的记录，返回变化的路径（父目录已不存在的记录跳过）
+func readUSNChanges(since USNPosition, until int64) ([]string, error) {
+	h, err := openVolume(since.Volume)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.CloseHandle(h)
+	journal, err := queryJournal(h)
+	if err != nil {
+		return nil, fmt.Errorf("卷 %s 没有启用 USN 日志: %w", since.Volume, err)
+	}
+	if journal.UsnJournalID != since.JournalID {
+		return nil, fmt.Errorf("卷 %s 的 USN 日志已重建", since.Volume)
+	}
+	if since.NextUsn < journal.LowestValidUsn {
+		return nil, fmt.Errorf("卷 %s 的 USN 日志已覆盖上次运行之后的记录", since.Volume)
+	}
+
+	parents := make(map[uint64]string)
+	var changed []string
+	var failed error
+	read := readUsnJournalData{StartUsn: since.NextUsn, ReasonMask: 0xFFFFFFFF, UsnJournalID: since.JournalID}
+	buf := make([]byte, usnBufferSize)
+	for read.StartUsn < until {
+		var n uint32
+		err := syscall.DeviceIoControl(h, fsctlReadUsnJournal, (*byte)(unsafe.Pointer(&read)), uint32(unsafe.Sizeof(read)), &buf[0], uint32(len(buf)), &n, nil)
+		if err != nil {
+			return nil, fmt.Errorf("读取卷 %s 的 USN 日志失败: %w", since.Volume, err)
+		}
+		if n <= 8 {
+			break
+		}
+		read.StartUsn = int64(binary.LittleEndian.Uint64(buf))
+		err = parseUsnRecords(buf[:n], func(rec usnRecord) {
+			if rec.usn >= until {
+				return
+			}
+			dir, ok := parents[rec.parent]
+			if !ok {
+				dir = pathByFileID(h, rec.parent)
+				parents[rec.parent] = dir
+			}
+			if dir != "" {
+				changed = append(changed, dir+`\`+rec.name)
+			}
+		})
+		if err != nil {
+			failed = err
+			break
+		}
+	}
+	if failed != nil {
+		return nil, fmt.Errorf("读取卷 %s 的 USN 日志失败: %w", since.Volume, failed)
+	}
+	return changed, nil
+}
+
+// pathByFileID 按文件引用号打开目录并返回其当前路径，已删除时返回空字符串
+func pathByFileID(volume syscall.Handle, ref uint64) string {
+	id := fileIDDescriptor{Size: uint32(unsafe.Sizeof(fileIDDescriptor{})), FileID: ref}
+	r, _, _ := procOpenFileByID.Call(uintptr(volume), uintptr(unsafe.Pointer(&id)), 0,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE, 0, syscall.FILE_FLAG_BACKUP_SEMANTICS)
+	h := syscall.Handle(r)
+	if h == syscall.InvalidHandle {
+		return ""
+	}
+	defer syscall.CloseHandle(h)
+	buf := make([]uint16, syscall.MAX_LONG_PATH)
+	n, _, _ := procGetFinalPathNameByHandleW.Call(uintptr(h), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), 0)
+	if n == 0 || int(n) > len(buf) {
+		return ""
+	}
+	return strings.TrimSuffix(helpers.StripLongPathPrefix(syscall.UTF16ToString(buf[:n])), `\`)
+}
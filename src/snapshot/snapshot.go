@@ -0,0 +1,187 @@
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/aogg/copy-ignore/src/fsguard"
+)
+
+// 快照预设（--snapshot）
+const (
+	PresetVSS      = "vss"      // Windows 卷影复制：为备份目标所在的卷创建可在“以前的版本”中访问的卷影副本
+	PresetBtrfs    = "btrfs"    // Btrfs 只读子卷快照
+	PresetZFS      = "zfs"      // ZFS 数据集快照
+	PresetSynology = "synology" // 群晖 DSM 共享文件夹快照（Web API，需要套件 Snapshot Replication）
+	PresetHTTP     = "http"     // 请求指定的地址（QNAP 等其他 NAS 的快照接口、自建的服务）
+	PresetCommand  = "command"  // 执行自定义命令
+)
+
+// Presets 支持的快照预设
+var Presets = []string{PresetVSS, PresetBtrfs, PresetZFS, PresetSynology, PresetHTTP, PresetCommand}
+
+// presetOptions 各预设接受的选项（--snapshot-opt key=value），值为 true 的是必填项
+var presetOptions = map[string]map[string]bool{
+	PresetVSS:      {"volume": false},
+	PresetBtrfs:    {"subvol": false, "dir": false},
+	PresetZFS:      {"dataset": false, "recursive": false},
+	PresetSynology: {"url": true, "user": true, "share": true, "password-env": false, "insecure": false, "lock": false},
+	PresetHTTP:     {"url": true, "method": false, "insecure": false},
+	PresetCommand:  {"cmd": true},
+}
+
+// DefaultPasswordEnv 默认读取 NAS 密码的环境变量（密码不放在命令行上，避免出现在进程列表和计划任务配置中）
+const DefaultPasswordEnv = "COPY_IGNORE_SNAPSHOT_PASSWORD"
+
+// Target 要创建快照的备份目标
+type Target struct {
+	Root      string // 实际写入的备份根目录（按主机分隔时为本机子目录）
+	Shared    string // 共享的备份根目录（未按主机分隔时与 Root 相同），默认对它所在的子卷、数据集创建快照
+	Timestamp string // 本次运行的时间戳
+	Host      string // 本机名称
+}
+
+// Name 快照名称 copy-ignore-<时间戳>，时间戳中字母、数字、点、下划线和连字符以外的字符替换为 -
+// （Btrfs 目录名、ZFS 快照名、群晖快照说明中都可以使用）
+func (t Target) Name() string {
+	name := []byte("copy-ignore-" + t.Timestamp)
+	for i, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-') {
+			name[i] = '-'
+		}
+	}
+	return string(name)
+}
+
+// ParseOptions 解析 key=value 形式的选项列表
+func ParseOptions(list []string) (map[string]string, error) {
+	opts := make(map[string]string, len(list))
+	for _, item := range list {
+		key, value, ok := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("快照选项格式应为 key=value: %s", item)
+		}
+		opts[key] = value
+	}
+	return opts, nil
+}
+
+// Validate 检查快照预设和选项（不访问备份目标）
+func Validate(preset string, list []string) error {
+	allowed, ok := presetOptions[preset]
+	if !ok {
+		return fmt.Errorf("未知的快照预设: %s（可选 %s）", preset, strings.Join(Presets, "、"))
+	}
+	if preset == PresetVSS && runtime.GOOS != "windows" {
+		return fmt.Errorf("快照预设 %s 只能在 Windows 上使用", preset)
+	}
+	opts, err := ParseOptions(list)
+	if err != nil {
+		return err
+	}
+	for key := range opts {
+		if _, ok := allowed[key]; !ok {
+			return fmt.Errorf("快照预设 %s 不支持选项 %s（可选 %s）", preset, key, strings.Join(optionNames(allowed), "、"))
+		}
+	}
+	for key, required := range allowed {
+		if required && opts[key] == "" {
+			return fmt.Errorf("快照预设 %s 需要选项 %s（--snapshot-opt %s=...）", preset, key, key)
+		}
+	}
+	return nil
+}
+
+// optionNames 返回排序后的选项名
+func optionNames(allowed map[string]bool) []string {
+	names := make([]string, 0, len(allowed))
+	for name := range allowed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Take 按预设为备份目标创建快照，返回快照的标识（快照路径、名称或 NAS 返回的 ID）
+func Take(preset string, list []string, t Target) (string, error) {
+	if err := Validate(preset, list); err != nil {
+		return "", err
+	}
+	opts, _ := ParseOptions(list)
+	var id string
+	err := fsguard.Do("snapshot", t.Shared, "运行成功后创建备份目标的快照（"+preset+"）", func() error {
+		var err error
+		switch preset {
+		case PresetVSS:
+			id, err = takeVSS(opts, t)
+		case PresetBtrfs:
+			id, err = takeBtrfs(opts, t)
+		case PresetZFS:
+			id, err = takeZFS(opts, t)
+		case PresetSynology:
+			id, err = takeSynology(opts, t)
+		case PresetHTTP:
+			id, err = takeHTTP(opts, t)
+		default:
+			id, err = takeCommand(opts, t)
+		}
+		return err
+	})
+	return id, err
+}
+
+// takeCommand 通过系统 shell 执行自定义命令，快照信息通过环境变量传入，输出的最后一行作为快照标识
+func takeCommand(opts map[string]string, t Target) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", opts["cmd"])
+	} else {
+		cmd = exec.Command("sh", "-c", opts["cmd"])
+	}
+	cmd.Env = append(os.Environ(),
+		"COPY_IGNORE_SNAPSHOT_NAME="+t.Name(),
+		"COPY_IGNORE_BACKUP_ROOT="+t.Root,
+		"COPY_IGNORE_SHARED_ROOT="+t.Shared,
+		"COPY_IGNORE_TIMESTAMP="+t.Timestamp,
+		"COPY_IGNORE_HOST="+t.Host,
+	)
+	out, err := run(cmd)
+	if err != nil {
+		return "", err
+	}
+	if out == "" {
+		return t.Name(), nil
+	}
+	lines := strings.Split(out, "\n")
+	return strings.TrimSpace(lines[len(lines)-1]), nil
+}
+
+// run 执行命令并返回去掉首尾空白的输出；失败时错误中带上命令的输出
+func run(cmd *exec.Cmd) (string, error) {
+	out, err := cmd.CombinedOutput()
+	text := strings.TrimSpace(string(out))
+	if err != nil {
+		if text != "" {
+			return "", fmt.Errorf("%s 执行失败: %w: %s", cmd.Args[0], err, text)
+		}
+		return "", fmt.Errorf("%s 执行失败: %w", cmd.Args[0], err)
+	}
+	return text, nil
+}
+
+// boolOption 读取布尔选项，未指定时返回 def
+func boolOption(opts map[string]string, key string, def bool) bool {
+	switch strings.ToLower(opts[key]) {
+	case "":
+		return def
+	case "1", "true", "yes", "on":
+		return true
+	default:
+		return false
+	}
+}
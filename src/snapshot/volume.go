@@ -0,0 +1,71 @@
+package snapshot
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/aogg/copy-ignore/src/fsguard"
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// takeVSS 为备份目标所在的卷创建卷影副本（ClientAccessible：持久保存，可在资源管理器“以前的版本”中浏览），需要管理员权限
+// 返回卷影副本的 ID
+func takeVSS(opts map[string]string, t Target) (string, error) {
+	volume := opts["volume"]
+	if volume == "" {
+		if helpers.IsUNCPath(t.Root) {
+			return "", fmt.Errorf("卷影复制只能用于本机的卷，%s 是网络路径（请在 NAS 上创建快照）", t.Root)
+		}
+		volume = filepath.VolumeName(t.Root)
+	}
+	volume = strings.TrimRight(volume, `\`) + `\`
+	script := fmt.Sprintf("$r = Invoke-CimMethod -ClassName Win32_ShadowCopy -MethodName Create -Arguments @{Volume='%s'; Context='ClientAccessible'}; "+
+		"if ($r.ReturnValue -ne 0) { [Console]::Error.WriteLine('Win32_ShadowCopy.Create 返回 ' + $r.ReturnValue); exit 1 }; $r.ShadowID",
+		strings.ReplaceAll(volume, "'", "''"))
+	return run(exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script))
+}
+
+// takeBtrfs 为备份目标所在的子卷创建只读快照 <dir>/<子卷名>-<快照名>
+// 子卷默认为共享的备份根目录，快照目录默认为其上级目录下的 .snapshots（放在子卷之外，不会被备份和清理阶段看到）
+func takeBtrfs(opts map[string]string, t Target) (string, error) {
+	subvol := opts["subvol"]
+	if subvol == "" {
+		subvol = t.Shared
+	}
+	subvol = filepath.Clean(subvol)
+	dir := opts["dir"]
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(subvol), ".snapshots")
+	}
+	if err := fsguard.MkdirAll(dir, 0755, "创建存放快照的目录"); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, filepath.Base(subvol)+"-"+t.Name())
+	if _, err := run(exec.Command("btrfs", "subvolume", "snapshot", "-r", subvol, path)); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// takeZFS 为备份目标所在的数据集创建快照 <数据集>@<快照名>，数据集默认按备份根目录查找
+func takeZFS(opts map[string]string, t Target) (string, error) {
+	dataset := opts["dataset"]
+	if dataset == "" {
+		out, err := run(exec.Command("zfs", "list", "-H", "-o", "name", t.Root))
+		if err != nil {
+			return "", fmt.Errorf("无法确定 %s 所在的 ZFS 数据集（可用 --snapshot-opt dataset=... 指定）: %w", t.Root, err)
+		}
+		dataset = strings.TrimSpace(strings.SplitN(out, "\n", 2)[0])
+	}
+	name := dataset + "@" + t.Name()
+	args := []string{"snapshot"}
+	if boolOption(opts, "recursive", false) {
+		args = append(args, "-r")
+	}
+	if _, err := run(exec.Command("zfs", append(args, name)...)); err != nil {
+		return "", err
+	}
+	return name, nil
+}
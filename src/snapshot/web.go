@@ -0,0 +1,162 @@
+package snapshot
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpTimeout 访问 NAS 接口的超时时间
+const httpTimeout = 2 * time.Minute
+
+// newHTTPClient 返回访问 NAS 接口的客户端；insecure 时不校验证书（NAS 常用自签名证书）
+func newHTTPClient(opts map[string]string) *http.Client {
+	client := &http.Client{Timeout: httpTimeout}
+	if boolOption(opts, "insecure", false) {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		client.Transport = transport
+	}
+	return client
+}
+
+// synologyResponse 群晖 Web API 的响应
+type synologyResponse struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Error   struct {
+		Code int `json:"code"`
+	} `json:"error"`
+}
+
+// synologyCall 以 POST 表单调用群晖 Web API，返回响应中的 data
+func synologyCall(client *http.Client, endpoint string, form url.Values) (json.RawMessage, error) {
+	resp, err := client.PostForm(endpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s 返回 %s", form.Get("api"), form.Get("method"), resp.Status)
+	}
+	var result synologyResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("%s %s 的响应无法解析: %w", form.Get("api"), form.Get("method"), err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("%s %s 失败，错误码 %d", form.Get("api"), form.Get("method"), result.Error.Code)
+	}
+	return result.Data, nil
+}
+
+// takeSynology 通过群晖 DSM Web API 为共享文件夹创建快照（默认锁定，不会被保留策略自动删除），返回快照名称
+// 密码从 password-env 指定的环境变量读取（默认 COPY_IGNORE_SNAPSHOT_PASSWORD）
+func takeSynology(opts map[string]string, t Target) (string, error) {
+	passwordEnv := opts["password-env"]
+	if passwordEnv == "" {
+		passwordEnv = DefaultPasswordEnv
+	}
+	password, ok := os.LookupEnv(passwordEnv)
+	if !ok {
+		return "", fmt.Errorf("没有设置环境变量 %s（群晖账号 %s 的密码）", passwordEnv, opts["user"])
+	}
+	base := strings.TrimRight(opts["url"], "/") + "/webapi/"
+	client := newHTTPClient(opts)
+
+	data, err := synologyCall(client, base+"auth.cgi", url.Values{
+		"api":     {"SYNO.API.Auth"},
+		"version": {"3"},
+		"method":  {"login"},
+		"account": {opts["user"]},
+		"passwd":  {password},
+		"session": {"copy-ignore"},
+		"format":  {"sid"},
+	})
+	if err != nil {
+		return "", fmt.Errorf("登录群晖失败: %w", err)
+	}
+	var login struct {
+		SID string `json:"sid"`
+	}
+	if err := json.Unmarshal(data, &login); err != nil || login.SID == "" {
+		return "", fmt.Errorf("登录群晖失败: 响应中没有 sid")
+	}
+	defer synologyCall(client, base+"auth.cgi", url.Values{
+		"api":     {"SYNO.API.Auth"},
+		"version": {"3"},
+		"method":  {"logout"},
+		"session": {"copy-ignore"},
+		"_sid":    {login.SID},
+	})
+
+	snapinfo, _ := json.Marshal(map[string]any{
+		"desc": "copy-ignore " + t.Timestamp,
+		"lock": boolOption(opts, "lock", true),
+	})
+	data, err = synologyCall(client, base+"entry.cgi", url.Values{
+		"api":      {"SYNO.Core.Share.Snapshot"},
+		"version":  {"1"},
+		"method":   {"create"},
+		"name":     {strconv.Quote(opts["share"])},
+		"snapinfo": {string(snapinfo)},
+		"_sid":     {login.SID},
+	})
+	if err != nil {
+		return "", fmt.Errorf("创建共享文件夹 %s 的快照失败: %w", opts["share"], err)
+	}
+	var id string
+	if err := json.Unmarshal(data, &id); err != nil || id == "" {
+		return t.Name(), nil
+	}
+	return id, nil
+}
+
+// takeHTTP 请求 url 选项指定的地址（默认 POST），返回 2xx 即视为成功，响应内容（为空时为快照名称）作为快照标识
+// 地址中的 {name}、{root}、{timestamp}、{host} 替换为快照名称、备份根目录、时间戳和本机名称（已转义）
+func takeHTTP(opts map[string]string, t Target) (string, error) {
+	target := strings.NewReplacer(
+		"{name}", url.QueryEscape(t.Name()),
+		"{root}", url.QueryEscape(t.Root),
+		"{timestamp}", url.QueryEscape(t.Timestamp),
+		"{host}", url.QueryEscape(t.Host),
+	).Replace(opts["url"])
+	method := strings.ToUpper(opts["method"])
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := newHTTPClient(opts).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(string(body))
+	if resp.StatusCode/100 != 2 {
+		if text != "" {
+			return "", fmt.Errorf("%s %s 返回 %s: %s", method, req.URL.Redacted(), resp.Status, text)
+		}
+		return "", fmt.Errorf("%s %s 返回 %s", method, req.URL.Redacted(), resp.Status)
+	}
+	if text == "" {
+		return t.Name(), nil
+	}
+	return text, nil
+}
@@ -0,0 +1,87 @@
+// Package tempname 定义写入备份目标时使用的临时文件名，块池等不能依赖 helpers 的包也按同样的格式命名临时文件
+package tempname
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 复制时的临时文件名：<目标文件名>.ci-<运行标识>-<序号>.tmp
+// 运行标识每个进程随机生成，序号在进程内递增，两个运行（或同一运行的两个工作协程）写入同一目标文件时不会互相覆盖临时文件
+const (
+	tempMarker = ".ci-"
+	tempSuffix = ".tmp"
+	runIDLen   = 8
+)
+
+// SuffixMaxLen 临时文件名比目标文件名最多多出的字节数，用于判断路径是否过长
+const SuffixMaxLen = len(tempMarker) + runIDLen + len("-") + 16 + len(tempSuffix)
+
+var (
+	runID   = newRunID()
+	tempSeq atomic.Uint64
+)
+
+// newRunID 生成本次运行的标识
+func newRunID() string {
+	buf := make([]byte, runIDLen/2)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%08x", uint32(os.Getpid())^uint32(time.Now().UnixNano()))
+	}
+	return hex.EncodeToString(buf)
+}
+
+// RunID 返回本次运行的标识（写入租约和临时文件名）
+func RunID() string {
+	return runID
+}
+
+// Path 返回写入 target 时使用的临时文件路径，每次调用都不相同
+func Path(target string) string {
+	return fmt.Sprintf("%s%s%s-%x%s", target, tempMarker, runID, tempSeq.Add(1), tempSuffix)
+}
+
+// Parse 解析临时文件路径，返回目标路径和所属运行的标识
+// 旧版本使用的 <目标文件名>.tmp 也视为临时文件，运行标识为空
+func Parse(path string) (target, owner string, ok bool) {
+	if !strings.HasSuffix(path, tempSuffix) {
+		return "", "", false
+	}
+	base := strings.TrimSuffix(path, tempSuffix)
+	if i := strings.LastIndex(base, tempMarker); i > 0 {
+		if id, seq, found := strings.Cut(base[i+len(tempMarker):], "-"); found && isRunID(id) && isHexSeq(seq) {
+			return base[:i], id, true
+		}
+	}
+	return base, "", true
+}
+
+// IsRunName 判断文件名是否为带运行标识的临时文件名（不含旧版本的 .tmp）
+func IsRunName(name string) bool {
+	_, owner, ok := Parse(name)
+	return ok && owner != ""
+}
+
+// isRunID 判断字符串是否为运行标识（8 位小写十六进制）
+func isRunID(s string) bool {
+	if len(s) != runIDLen {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
+
+// isHexSeq 判断字符串是否为十六进制序号
+func isHexSeq(s string) bool {
+	if s == "" || len(s) > 16 {
+		return false
+	}
+	_, err := strconv.ParseUint(s, 16, 64)
+	return err == nil
+}
@@ -0,0 +1,228 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/text/width"
+)
+
+// DefaultInterval 状态行的默认刷新间隔
+const DefaultInterval = 500 * time.Millisecond
+
+// message 交给界面协程输出的一条消息
+type message struct {
+	w    io.Writer
+	text string
+}
+
+// Renderer 界面协程：唯一向终端写入的协程
+// 状态行（如复制进度）按固定间隔重新生成并原地刷新；其他消息经 Printf 等函数送来，
+// 输出前先清除状态行，输出后再重画，避免多个协程的输出交错成乱码
+type Renderer struct {
+	interval time.Duration
+	status   func() string // 生成当前状态行，由界面协程按间隔调用
+	out      io.Writer
+	messages chan message
+	done     chan struct{}
+	stopped  chan struct{}
+
+	shown   int  // 当前显示的状态行宽度（终端列数），0 表示没有显示
+	midLine bool // 最后一条消息没有以换行结尾
+}
+
+var (
+	mu     sync.RWMutex
+	active *Renderer // 当前运行的界面协程，nil 表示直接输出
+
+	stdout io.Writer = os.Stdout // Printf、Println 的输出位置
+)
+
+// SetOutput 设置 Printf、Println 的输出位置，返回原来的位置
+// 需要标准输出只包含机器可读结果（如 JSON）时，可将进度等消息改到标准错误
+func SetOutput(w io.Writer) io.Writer {
+	mu.Lock()
+	defer mu.Unlock()
+	prev := stdout
+	stdout = w
+	return prev
+}
+
+// Start 启动界面协程，之后 Printf 等函数的输出都经由它进行
+// status 返回当前状态行（不含换行），返回空字符串时不显示状态行
+func Start(interval time.Duration, status func() string) *Renderer {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	r := &Renderer{
+		interval: interval,
+		status:   status,
+		out:      os.Stdout,
+		messages: make(chan message, 256),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	mu.Lock()
+	active = r
+	mu.Unlock()
+	go r.run()
+	return r
+}
+
+// Stop 输出剩余的消息和最终的状态行并换行，之后恢复直接输出
+func (r *Renderer) Stop() {
+	mu.Lock()
+	if active == r {
+		active = nil
+	}
+	mu.Unlock()
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+	}
+	<-r.stopped
+}
+
+// run 界面协程主循环
+func (r *Renderer) run() {
+	defer close(r.stopped)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case m := <-r.messages:
+			r.write(m)
+		case <-ticker.C:
+			r.draw()
+		case <-r.done:
+			// Stop 之后不会再有新消息，输出已排队的消息后画最终状态
+			for len(r.messages) > 0 {
+				r.write(<-r.messages)
+			}
+			r.draw()
+			if r.shown > 0 || r.midLine {
+				fmt.Fprintln(r.out)
+			}
+			return
+		}
+	}
+}
+
+// write 清除状态行后输出一条消息
+func (r *Renderer) write(m message) {
+	r.clear()
+	io.WriteString(m.w, m.text)
+	r.midLine = !strings.HasSuffix(m.text, "\n")
+}
+
+// draw 重画状态行
+func (r *Renderer) draw() {
+	if r.status == nil {
+		return
+	}
+	line := r.status()
+	if line == "" {
+		r.clear()
+		return
+	}
+	if r.midLine {
+		fmt.Fprintln(r.out)
+		r.midLine = false
+	}
+	cols := Width(line)
+	pad := ""
+	if cols < r.shown {
+		pad = strings.Repeat(" ", r.shown-cols)
+	}
+	fmt.Fprintf(r.out, "\r%s%s", line, pad)
+	r.shown = cols
+}
+
+// clear 清除当前显示的状态行，光标回到行首
+func (r *Renderer) clear() {
+	if r.shown == 0 {
+		return
+	}
+	fmt.Fprintf(r.out, "\r%s\r", strings.Repeat(" ", r.shown))
+	r.shown = 0
+}
+
+// send 有界面协程时交给它输出，否则直接输出（同样加锁，保证消息完整不交错）
+// w 为 nil 时输出到 SetOutput 设置的位置（默认标准输出）
+func send(w io.Writer, text string) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if w == nil {
+		w = stdout
+	}
+	if active != nil {
+		active.messages <- message{w: w, text: text}
+		return
+	}
+	io.WriteString(w, text)
+}
+
+// Printf 向标准输出输出消息
+func Printf(format string, a ...any) {
+	send(nil, fmt.Sprintf(format, a...))
+}
+
+// Println 向标准输出输出一行消息
+func Println(a ...any) {
+	send(nil, fmt.Sprintln(a...))
+}
+
+// Errorf 向标准错误输出消息（警告、错误）
+func Errorf(format string, a ...any) {
+	send(os.Stderr, fmt.Sprintf(format, a...))
+}
+
+// Width 返回字符串在终端中占用的列数（中日韩等宽字符按 2 列计）
+func Width(s string) int {
+	cols := 0
+	for _, r := range s {
+		cols += runeWidth(r)
+	}
+	return cols
+}
+
+// Truncate 将字符串截断到不超过 limit 列，截断时以 "..." 结尾（不会截断在多字节字符中间）
+func Truncate(s string, limit int) string {
+	if Width(s) <= limit {
+		return s
+	}
+	cols := 0
+	for i, r := range s {
+		if cols+runeWidth(r) > limit-3 {
+			return s[:i] + "..."
+		}
+		cols += runeWidth(r)
+	}
+	return s
+}
+
+// runeWidth 返回字符占用的列数
+func runeWidth(r rune) int {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// Interactive 判断是否在交互式终端中运行（标准输入和标准输出都是终端），计划任务等无人值守的运行返回 false
+func Interactive() bool {
+	return isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+// isTerminal 判断文件是否为终端
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
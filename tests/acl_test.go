@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+func TestCopyACL_PermissionBits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 上权限由安全描述符决定，跳过测试")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.txt")
+	dest := filepath.Join(dir, "dest.txt")
+	if err := os.WriteFile(src, []byte("源文件"), 0600); err != nil {
+		t.Fatalf("写入文件失败: %v", err)
+	}
+	if err := os.Chmod(src, 0640); err != nil {
+		t.Fatalf("设置权限失败: %v", err)
+	}
+	if err := os.WriteFile(dest, []byte("源文件"), 0644); err != nil {
+		t.Fatalf("写入文件失败: %v", err)
+	}
+
+	// 非 root 用户修改所有者可能失败，但权限位仍应复制
+	if err := helpers.CopyACL(src, dest); err != nil {
+		t.Logf("复制访问控制列表: %v", err)
+	}
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatalf("获取文件信息失败: %v", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("目标权限应为 0640，实际 %o", info.Mode().Perm())
+	}
+}
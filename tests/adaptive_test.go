@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/copy"
+)
+
+func TestConcurrencyController_SkipsDoNotCollapse(t *testing.T) {
+	c := copy.NewConcurrencyController(8, 16, false)
+	window := func(jobs int, latency time.Duration, bytes int64) {
+		for i := 0; i < jobs; i++ {
+			c.Acquire()
+			c.Release(latency, bytes, false)
+		}
+		c.Adjust()
+	}
+
+	// 一个窗口全是跳过的文件（耗时极短，没有复制数据），不应作为延迟基线
+	window(1000, 5*time.Microsecond, 0)
+	if got := c.Limit(); got != 8 {
+		t.Errorf("只有跳过的文件时并发数应保持不变，实际 %d", got)
+	}
+
+	// 之后是正常的复制：每字节耗时稳定，并发数不应被减半，而应逐步增加
+	for i := 0; i < 5; i++ {
+		window(20, 40*time.Millisecond, 4<<20)
+	}
+	if got := c.Limit(); got <= 8 {
+		t.Errorf("延迟稳定的复制不应降低并发数，实际 %d", got)
+	}
+
+	// 同样大小的文件耗时明显升高时（目标端过载）仍应减半
+	before := c.Limit()
+	window(20, 200*time.Millisecond, 4<<20)
+	if got := c.Limit(); got != before/2 {
+		t.Errorf("延迟升高时并发数应从 %d 减半，实际 %d", before, got)
+	}
+}
@@ -0,0 +1,36 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+func TestBandwidthSchedule_RateAt(t *testing.T) {
+	s, err := helpers.ParseBandwidthSchedule("09:00-18:00=5M,22:00-06:00=500K,1M")
+	if err != nil {
+		t.Fatalf("解析带宽计划失败: %v", err)
+	}
+
+	cases := map[string]int64{
+		"10:30": 5 << 20,
+		"18:00": 1 << 20,
+		"23:15": 500 << 10,
+		"03:00": 500 << 10,
+	}
+	for clock, want := range cases {
+		at, _ := time.Parse("15:04", clock)
+		if got := s.RateAt(at); got != want {
+			t.Errorf("%s: 期望 %d，实际 %d", clock, want, got)
+		}
+	}
+}
+
+func TestBandwidthSchedule_Invalid(t *testing.T) {
+	for _, spec := range []string{"9-18=5M", "09:00-18:00=fast", "abc"} {
+		if _, err := helpers.ParseBandwidthSchedule(spec); err == nil {
+			t.Errorf("期望 %q 解析失败", spec)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+func TestIsBinaryContent(t *testing.T) {
+	cases := []struct {
+		name   string
+		head   []byte
+		binary bool
+	}{
+		{"ELF", []byte("\x7fELF\x02\x01\x01"), true},
+		{"PE", []byte("MZ\x90\x00\x03"), true},
+		{"zip", []byte("PK\x03\x04\x14\x00"), true},
+		{"PNG", []byte("\x89PNG\r\n\x1a\n"), true},
+		{"含 0 字节", []byte("abc\x00def"), true},
+		{".env", []byte("DB_PASSWORD=secret\nAPI_KEY=abc\n"), false},
+		{"UTF-8 中文", []byte("# 配置\nname=测试\n"), false},
+		{"UTF-16 BOM", []byte{0xff, 0xfe, 'a', 0, 'b', 0}, false},
+		{"空文件", nil, false},
+	}
+	for _, c := range cases {
+		if got := helpers.IsBinaryContent(c.head); got != c.binary {
+			t.Errorf("%s: 期望 %v，实际 %v", c.name, c.binary, got)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/exclude"
+)
+
+func TestDetectCache(t *testing.T) {
+	root := t.TempDir()
+	mkdir := func(rel string) string {
+		p := filepath.Join(root, rel)
+		if err := os.MkdirAll(p, 0755); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	touch := func(rel string) {
+		if err := os.WriteFile(filepath.Join(root, rel), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rustTarget := mkdir("rust/target")
+	touch("rust/Cargo.toml")
+	javaTarget := mkdir("java/target") // 没有 Cargo.toml，不是 cargo 缓存
+	venv := mkdir("py/.venv")
+	touch("py/.venv/pyvenv.cfg")
+	nodeModules := mkdir("web/node_modules")
+	touch("web/package.json")
+
+	cases := map[string]string{
+		rustTarget:  "cargo",
+		javaTarget:  "",
+		venv:        "python-venv",
+		nodeModules: "npm",
+		filepath.Join(root, "py/.venv/pyvenv.cfg"): "",
+	}
+	for path, want := range cases {
+		if got := exclude.DetectCache(path); got != want {
+			t.Errorf("%s: 期望 %q，实际 %q", path, want, got)
+		}
+	}
+
+	m, err := exclude.NewMatcher(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ShouldExclude(rustTarget) {
+		t.Errorf("未启用 SkipCaches 时不应排除缓存目录")
+	}
+	m.SkipCaches()
+	if !m.ShouldExclude(rustTarget) || m.ShouldExclude(javaTarget) {
+		t.Errorf("启用 SkipCaches 后应只排除识别出的缓存目录")
+	}
+}
+
+func TestBackupMarkers(t *testing.T) {
+	root := t.TempDir()
+	tagged := filepath.Join(root, "tagged")
+	fakeTag := filepath.Join(root, "fake")
+	nobackup := filepath.Join(root, "nobackup")
+	for _, dir := range []string{tagged, fakeTag, nobackup} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(tagged, "CACHEDIR.TAG"), []byte("Signature: 8a477f597d28d172789f06886806bc55\n# cache\n"), 0644)
+	os.WriteFile(filepath.Join(fakeTag, "CACHEDIR.TAG"), []byte("not a cache\n"), 0644)
+	os.WriteFile(filepath.Join(nobackup, ".nobackup"), nil, 0644)
+
+	m, err := exclude.NewMatcher(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SkipMarkedDirs()
+	if !m.ShouldExclude(tagged) || !m.ShouldExclude(nobackup) {
+		t.Errorf("带有 CACHEDIR.TAG 或 .nobackup 的目录应被排除")
+	}
+	if m.ShouldExclude(fakeTag) {
+		t.Errorf("签名不正确的 CACHEDIR.TAG 不应生效")
+	}
+}
@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/aogg/copy-ignore/src/logics"
+)
+
+func TestCatalogExport(t *testing.T) {
+	tempDir := t.TempDir()
+	backupRoot := filepath.Join(tempDir, "backup")
+	writeTestFile(t, backupRoot, "web/.env", "current")
+	writeTestFile(t, backupRoot, "api/debug.log", "log")
+	writeTestFile(t, backupRoot, "copy-ignore备份/20260101-000000/web/.env", "old")
+	writeTestFile(t, backupRoot, "copy-ignore备份/20260102-000000/web/.env", "older")
+
+	out := filepath.Join(tempDir, "catalog.db")
+	if code := logics.RunCatalog([]string{"export", "--sqlite", out, "--search-root", filepath.Join(tempDir, "src"), "--compute-hashes", backupRoot}); code != 0 {
+		t.Fatalf("导出应成功，退出码 %d", code)
+	}
+	if _, err := os.Stat(out + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("导出完成后不应留下临时文件: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", out)
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	defer db.Close()
+	count := func(query string, args ...any) int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+			t.Fatalf("查询 %q 失败: %v", query, err)
+		}
+		return n
+	}
+	// 历史目录不算作当前文件
+	if n := count("SELECT COUNT(*) FROM files"); n != 2 {
+		t.Errorf("files 应有 2 行，实际 %d", n)
+	}
+	if n := count("SELECT COUNT(*) FROM versions WHERE path = ?", "web/.env"); n != 2 {
+		t.Errorf("web/.env 应有 2 个历史版本，实际 %d", n)
+	}
+	if n := count("SELECT COUNT(*) FROM all_versions WHERE hash IS NULL"); n != 0 {
+		t.Errorf("--compute-hashes 时所有文件都应有哈希，%d 个没有", n)
+	}
+
+	var size int64
+	var source string
+	if err := db.QueryRow("SELECT size, source FROM files WHERE path = ?", "web/.env").Scan(&size, &source); err != nil {
+		t.Fatalf("查询 web/.env 失败: %v", err)
+	}
+	if size != int64(len("current")) {
+		t.Errorf("web/.env 大小应为 %d，实际 %d", len("current"), size)
+	}
+	if want := filepath.Join(tempDir, "src", "web", ".env"); source != want {
+		t.Errorf("原始位置应为 %s，实际 %s", want, source)
+	}
+
+	// 数据库不能放在备份根目录中
+	if code := logics.RunCatalog([]string{"export", "--sqlite", filepath.Join(backupRoot, "c.db"), backupRoot}); code != 2 {
+		t.Errorf("数据库位于备份根目录中时应返回 2，实际 %d", code)
+	}
+}
@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+// TestScanIgnoredFiles_SkipUnchanged 判定为自上次运行以来没有变化的仓库不列出被忽略的文件，并记录为已跳过
+func TestScanIgnoredFiles_SkipUnchanged(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	root := t.TempDir()
+	changed := filepath.Join(root, "changed")
+	unchanged := filepath.Join(root, "unchanged")
+	for _, repo := range []string{changed, unchanged} {
+		if err := os.MkdirAll(repo, 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+		initGitRepo(t, repo)
+		createGitignore(t, repo, "*.log\n")
+		createIgnoredFile(t, repo, "debug.log", "日志内容")
+	}
+
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	config.InitGlobalConfig(&config.Config{})
+	scanner.SetUnchangedFilter(func(repoRoot string) bool { return repoRoot == unchanged })
+	defer scanner.SetUnchangedFilter(nil)
+
+	excluder, err := exclude.NewMatcher([]string{})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	fileChan := make(chan scanner.IgnoredFileInfo, 10)
+	if err := scanner.ScanIgnoredFilesWithProgressStreamConcurrent(root, excluder, nil, fileChan, 2); err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	close(fileChan)
+
+	count := 0
+	for file := range fileChan {
+		count++
+		if file.RepoRoot != changed {
+			t.Errorf("不应处理没有变化的仓库: %s", file.AbsPath)
+		}
+	}
+	if count == 0 {
+		t.Error("有变化的仓库应照常扫描")
+	}
+	if skipped := scanner.UnchangedRepos(); len(skipped) != 1 || skipped[0] != unchanged {
+		t.Errorf("应记录跳过的仓库 %s，实际 %v", unchanged, skipped)
+	}
+}
+
+// TestUSNPosition_Unsupported 非 Windows 系统上读取 USN 日志返回错误（调用方照常扫描）
+func TestUSNPosition_Unsupported(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 上的结果取决于卷的文件系统和权限")
+	}
+	if _, err := scanner.QueryUSNPosition("C:"); err == nil {
+		t.Error("非 Windows 系统上不应能读取 USN 日志")
+	}
+}
+
+// TestChangesSince 按变更日志确定上次运行以来有变化的路径；无法确定变化范围时返回错误
+func TestChangesSince(t *testing.T) {
+	root := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), config.ChangeLogFileName)
+	changeLog, err := helpers.CreateChangeLog(logPath, []string{root})
+	if err != nil {
+		t.Fatalf("创建变更日志失败: %v", err)
+	}
+	since := time.Now()
+	old := filepath.Join(root, "a", "old.txt")
+	changed := filepath.Join(root, "b", "new.txt")
+	if err := changeLog.Record([]string{old}, since.Add(-time.Minute)); err != nil {
+		t.Fatalf("写入变更日志失败: %v", err)
+	}
+	if err := changeLog.Record([]string{changed, changed}, since.Add(time.Second)); err != nil {
+		t.Fatalf("写入变更日志失败: %v", err)
+	}
+	if err := changeLog.Flush(); err != nil {
+		t.Fatalf("写入变更日志失败: %v", err)
+	}
+
+	now := since.Add(2 * time.Second)
+	paths, err := helpers.ChangesSince(logPath, []string{filepath.Join(root, "b")}, since, now)
+	if err != nil {
+		t.Fatalf("应能确定变化范围: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != changed {
+		t.Errorf("只应返回上次运行之后的变化（去重），实际 %v", paths)
+	}
+
+	// 开始监视晚于上次运行、没有监视扫描目录、watch 已停止更新
+	if _, err := helpers.ChangesSince(logPath, []string{root}, since.Add(-time.Hour), now); err == nil {
+		t.Error("watch 开始晚于上次运行时应无法确定变化范围")
+	}
+	if _, err := helpers.ChangesSince(logPath, []string{t.TempDir()}, since, now); err == nil {
+		t.Error("扫描目录不在监视范围内时应无法确定变化范围")
+	}
+	if _, err := helpers.ChangesSince(logPath, []string{root}, since, now.Add(time.Hour)); err == nil {
+		t.Error("变更日志长时间没有更新时应视为 watch 没有在运行")
+	}
+
+	// 上次运行之后有事件丢失、watch 已退出
+	if err := changeLog.Overflow(now); err != nil {
+		t.Fatalf("写入变更日志失败: %v", err)
+	}
+	if err := changeLog.Close(); err != nil {
+		t.Fatalf("关闭变更日志失败: %v", err)
+	}
+	if _, err := helpers.ChangesSince(logPath, []string{root}, since, now); err == nil {
+		t.Error("有事件丢失时应无法确定变化范围")
+	}
+}
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/chunkstore"
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/logics"
+)
+
+func TestChunkStore_PutRestoreDedup(t *testing.T) {
+	store := chunkstore.Open(t.TempDir())
+	data := make([]byte, 6<<20)
+	rand.New(rand.NewSource(2)).Read(data)
+
+	recipe, written, err := store.Put(bytes.NewReader(data))
+	if err != nil || written != int64(len(data)) {
+		t.Fatalf("首次写入失败: %v（写入 %d）", err, written)
+	}
+
+	// 头部插入数据后，除首块外的内容应能复用已有的块
+	shifted := append([]byte("prefix"), data...)
+	_, written, err = store.Put(bytes.NewReader(shifted))
+	if err != nil || written >= int64(len(data))/2 {
+		t.Errorf("插入数据后应复用大部分块，实际新写入 %d 字节（%v）", written, err)
+	}
+
+	var out bytes.Buffer
+	if err := store.Restore(recipe, &out); err != nil || !bytes.Equal(out.Bytes(), data) {
+		t.Errorf("还原内容不一致: %v", err)
+	}
+}
+
+// TestChunkStore_ConcurrentPut 多个工作协程同时写入相同内容（共享同一批新块）时都应成功
+func TestChunkStore_ConcurrentPut(t *testing.T) {
+	store := chunkstore.Open(t.TempDir())
+	data := make([]byte, 4<<20)
+	rand.New(rand.NewSource(4)).Read(data)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			recipe, _, err := store.Put(bytes.NewReader(data))
+			if err == nil {
+				var out bytes.Buffer
+				if err = store.Restore(recipe, &out); err == nil && !bytes.Equal(out.Bytes(), data) {
+					err = fmt.Errorf("还原内容不一致")
+				}
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("第 %d 个写入失败: %v", i, err)
+		}
+	}
+	filepath.Walk(store.Dir(), func(path string, info os.FileInfo, err error) error {
+		if err == nil && strings.HasSuffix(path, ".tmp") {
+			t.Errorf("不应留下临时块: %s", path)
+		}
+		return nil
+	})
+}
+
+// TestChunksGC_KeepsInFlightChunks 正在进行的复制写入的块（还没有配方引用）不被回收：
+// 有运行持有租约时拒绝回收，最近写入的未引用块保留
+func TestChunksGC_KeepsInFlightChunks(t *testing.T) {
+	root := t.TempDir()
+	store := chunkstore.Open(root)
+	kept := make([]byte, 2<<20)
+	rand.New(rand.NewSource(5)).Read(kept)
+	recipe, _, err := store.Put(bytes.NewReader(kept))
+	if err != nil {
+		t.Fatalf("写入块池失败: %v", err)
+	}
+	if err := chunkstore.WriteRecipe(filepath.Join(root, "kept.bin"), recipe); err != nil {
+		t.Fatalf("写入配方失败: %v", err)
+	}
+	// 只写入了块、还没有写入配方的文件
+	pending := make([]byte, 2<<20)
+	rand.New(rand.NewSource(6)).Read(pending)
+	unreferenced, _, err := store.Put(bytes.NewReader(pending))
+	if err != nil {
+		t.Fatalf("写入块池失败: %v", err)
+	}
+	present := func(r *chunkstore.Recipe) bool {
+		for _, hash := range r.Chunks {
+			if store.Check(hash) != nil {
+				return false
+			}
+		}
+		return true
+	}
+
+	if code := logics.RunChunks([]string{"gc", root}); code != 0 || !present(unreferenced) {
+		t.Fatalf("最近写入的未引用块应保留，退出码 %d", code)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, hash := range unreferenced.Chunks {
+		if err := os.Chtimes(filepath.Join(store.Dir(), hash[:2], hash), old, old); err != nil {
+			t.Fatalf("设置修改时间失败: %v", err)
+		}
+	}
+	lease, err := helpers.AcquireLease(root, "laptop", ".")
+	if err != nil {
+		t.Fatalf("获取租约失败: %v", err)
+	}
+	if code := logics.RunChunks([]string{"gc", root}); code == 0 || !present(unreferenced) {
+		t.Errorf("有运行正在写入时应拒绝回收，退出码 %d", code)
+	}
+	lease.Release()
+
+	if code := logics.RunChunks([]string{"gc", root}); code != 0 {
+		t.Fatalf("回收失败，退出码 %d", code)
+	}
+	if !present(recipe) {
+		t.Error("被配方引用的块不应被回收")
+	}
+	for _, hash := range unreferenced.Chunks {
+		if store.Check(hash) == nil {
+			t.Errorf("超过保护期的未引用块应被回收: %s", hash)
+		}
+	}
+}
+
+func TestCheckHeal_Chunks(t *testing.T) {
+	base, dest := filepath.Join(t.TempDir(), "base"), filepath.Join(t.TempDir(), "dest")
+	data := make([]byte, 3<<20)
+	rand.New(rand.NewSource(3)).Read(data)
+	recipe, _, err := chunkstore.Open(base).Put(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("写入块池失败: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(base, "web"), 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	if err := chunkstore.WriteRecipe(filepath.Join(base, "web", "big.bin"), recipe); err != nil {
+		t.Fatalf("写入配方失败: %v", err)
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	// 修复复制配方时，配方引用的块一并从基准获取
+	if code := logics.RunCheck([]string{"--heal", base, dest}); code != 0 {
+		t.Fatalf("修复后应一致，退出码 %d", code)
+	}
+	restore := func() ([]byte, error) {
+		healed, err := chunkstore.ReadRecipe(filepath.Join(dest, "web", "big.bin"))
+		if err != nil || healed == nil {
+			return nil, fmt.Errorf("读取配方失败: %v", err)
+		}
+		var out bytes.Buffer
+		err = chunkstore.Open(dest).Restore(healed, &out)
+		return out.Bytes(), err
+	}
+	if got, err := restore(); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("修复后的目标应能还原分块存储的文件: %v", err)
+	}
+
+	// 配方一致但块损坏时应报告不一致，修复后可再次还原
+	chunk := filepath.Join(dest, config.ChunkDirName, recipe.Chunks[0][:2], recipe.Chunks[0])
+	if err := os.WriteFile(chunk, []byte("corrupted"), 0644); err != nil {
+		t.Fatalf("写入损坏的块失败: %v", err)
+	}
+	if code := logics.RunCheck([]string{base, dest}); code != 1 {
+		t.Errorf("块损坏时应报告不一致，退出码 %d", code)
+	}
+	if code := logics.RunCheck([]string{"--heal", base, dest}); code != 0 {
+		t.Errorf("修复损坏的块后应一致，退出码 %d", code)
+	}
+	if got, err := restore(); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("修复损坏的块后应能还原: %v", err)
+	}
+}
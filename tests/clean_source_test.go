@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/logics"
+)
+
+// setupCleanSource 创建一个包含被忽略文件的仓库并完成一次复制，返回搜索根目录、仓库和备份根目录
+func setupCleanSource(t *testing.T) (string, string, string) {
+	t.Helper()
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	searchRoot := t.TempDir()
+	repo := filepath.Join(searchRoot, "repo")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	initGitRepo(t, repo)
+	createGitignore(t, repo, "*.log\n")
+	createIgnoredFile(t, repo, "a.log", "已备份的内容")
+	createIgnoredFile(t, repo, "b.log", "将被损坏的内容")
+	createIgnoredFile(t, repo, "c.log", "将被手动删除")
+
+	dest := filepath.Join(t.TempDir(), "backup")
+	old := config.GetGlobalConfig()
+	t.Cleanup(func() { config.InitGlobalConfig(old) })
+	if code := logics.RunCopy([]string{searchRoot, dest}); code != 0 {
+		t.Fatalf("复制失败，退出码 %d", code)
+	}
+	return searchRoot, repo, dest
+}
+
+// corruptKeepingStat 改写备份文件的内容，保持大小和修改时间不变（清单无法发现的损坏）
+func corruptKeepingStat(t *testing.T, path string) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("读取文件信息失败: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", int(info.Size()))), 0644); err != nil {
+		t.Fatalf("写入文件失败: %v", err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("设置修改时间失败: %v", err)
+	}
+}
+
+func TestCleanSource_DryRunLists(t *testing.T) {
+	searchRoot, repo, dest := setupCleanSource(t)
+	corruptKeepingStat(t, filepath.Join(dest, "repo", "b.log"))
+
+	out, code := captureStdout(t, func() int { return logics.RunCleanSource([]string{searchRoot, dest}) })
+	if code != 0 {
+		t.Fatalf("干运行应成功，退出码 %d", code)
+	}
+	if !strings.Contains(out, "可删除: "+filepath.Join(repo, "a.log")) {
+		t.Errorf("应列出校验一致的文件:\n%s", out)
+	}
+	if strings.Contains(out, filepath.Join(repo, "b.log")) {
+		t.Errorf("备份已损坏的文件不应列为可删除:\n%s", out)
+	}
+	for _, name := range []string{"a.log", "b.log", "c.log"} {
+		if _, err := os.Stat(filepath.Join(repo, name)); err != nil {
+			t.Errorf("未指定 --yes 时不应删除任何文件: %s", name)
+		}
+	}
+}
+
+func TestCleanSource_DeletesVerifiedOnly(t *testing.T) {
+	searchRoot, repo, dest := setupCleanSource(t)
+	// 备份损坏但大小和修改时间与清单一致：必须按实际内容比较，保留源文件
+	corruptKeepingStat(t, filepath.Join(dest, "repo", "b.log"))
+	// 源文件修改后与备份不一致
+	createIgnoredFile(t, repo, "c.log", "源文件已修改")
+
+	if _, code := captureStdout(t, func() int { return logics.RunCleanSource([]string{"--yes", searchRoot, dest}) }); code != 0 {
+		t.Fatalf("清理应成功，退出码 %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "a.log")); !os.IsNotExist(err) {
+		t.Error("校验一致的源文件应被删除")
+	}
+	for _, name := range []string{"b.log", "c.log"} {
+		if _, err := os.Stat(filepath.Join(repo, name)); err != nil {
+			t.Errorf("哈希不一致的源文件应保留: %s", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dest, "repo", "a.log")); err != nil {
+		t.Error("删除源文件后备份应保留")
+	}
+}
+
+func TestCleanSource_CleanedKeepsBackup(t *testing.T) {
+	searchRoot, repo, dest := setupCleanSource(t)
+	// c.log 由用户手动删除，a.log、b.log 由 clean-source 删除
+	if err := os.Remove(filepath.Join(repo, "c.log")); err != nil {
+		t.Fatalf("删除文件失败: %v", err)
+	}
+	if _, code := captureStdout(t, func() int { return logics.RunCleanSource([]string{"--yes", searchRoot, dest}) }); code != 0 {
+		t.Fatalf("清理应成功，退出码 %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(dest, ".copy-ignore-cleaned.json")); err != nil {
+		t.Fatalf("应记录已删除的源文件: %v", err)
+	}
+
+	// 仓库中仍有被忽略的文件时才会在其备份目录中清理
+	createIgnoredFile(t, repo, "new.log", "新文件")
+	if code := logics.RunCopy([]string{searchRoot, dest}); code != 0 {
+		t.Fatalf("复制失败，退出码 %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "repo", "a.log")); err != nil {
+		t.Error("clean-source 删除的源文件，其备份不应被清理阶段移走")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "repo", "c.log")); !os.IsNotExist(err) {
+		t.Error("源文件已删除、没有清理记录的备份应被清理阶段移入历史目录")
+	}
+}
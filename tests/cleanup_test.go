@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/logics"
+)
+
+// setupDeletedSources 复制一个包含 a.log、b.log、keep.log 的仓库后删除 a.log 和 b.log，
+// 返回搜索根目录和备份根目录（keep.log 保证仓库仍在清理范围内）
+func setupDeletedSources(t *testing.T) (string, string) {
+	t.Helper()
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	searchRoot := t.TempDir()
+	repo := filepath.Join(searchRoot, "repo")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	initGitRepo(t, repo)
+	createGitignore(t, repo, "*.log\n")
+	for _, name := range []string{"a.log", "b.log", "keep.log"} {
+		createIgnoredFile(t, repo, name, name)
+	}
+
+	dest := filepath.Join(t.TempDir(), "backup")
+	old := config.GetGlobalConfig()
+	t.Cleanup(func() { config.InitGlobalConfig(old) })
+	if code := logics.RunCopy([]string{searchRoot, dest}); code != 0 {
+		t.Fatalf("复制失败，退出码 %d", code)
+	}
+	for _, name := range []string{"a.log", "b.log"} {
+		if err := os.Remove(filepath.Join(repo, name)); err != nil {
+			t.Fatalf("删除文件失败: %v", err)
+		}
+	}
+	return searchRoot, dest
+}
+
+// historyCopies 返回历史目录中 repo/<name> 的所有旧版本
+func historyCopies(t *testing.T, dest, name string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dest, "copy-ignore备份", "*", "repo", name))
+	if err != nil {
+		t.Fatalf("查找历史版本失败: %v", err)
+	}
+	return matches
+}
+
+func TestCleanup_ProtectKeepsBackup(t *testing.T) {
+	searchRoot, dest := setupDeletedSources(t)
+
+	if code := logics.RunCopy([]string{"--protect", "repo/a.log", searchRoot, dest}); code != 0 {
+		t.Fatalf("复制失败，退出码 %d", code)
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "repo", "a.log")); err != nil || string(data) != "a.log" {
+		t.Errorf("受保护的备份在源文件删除后应保留，实际 %q, %v", data, err)
+	}
+	if copies := historyCopies(t, dest, "a.log"); len(copies) != 0 {
+		t.Errorf("受保护的备份不应移入历史目录: %v", copies)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "repo", "b.log")); !os.IsNotExist(err) {
+		t.Error("未受保护的备份在源文件删除后应移走")
+	}
+	if copies := historyCopies(t, dest, "b.log"); len(copies) != 1 {
+		t.Errorf("未受保护的备份应移入历史目录，实际 %v", copies)
+	}
+}
+
+func TestCleanup_DeleteDryRunMatchesCleanup(t *testing.T) {
+	searchRoot, dest := setupDeletedSources(t)
+	backupRepo := filepath.Join(dest, "repo")
+	before := snapshotTree(t, backupRepo)
+
+	report := filepath.Join(t.TempDir(), "report.txt")
+	if code := logics.RunCopy([]string{"--delete-dry-run", "--delete-report", report, searchRoot, dest}); code != 0 {
+		t.Fatalf("清理预演失败，退出码 %d", code)
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatalf("读取清理预演报告失败: %v", err)
+	}
+	// 报告中不以 # 或空白开头的行是将被移入历史的备份文件
+	var previewed []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, " ") {
+			previewed = append(previewed, line)
+		}
+	}
+	sort.Strings(previewed)
+
+	if after := snapshotTree(t, backupRepo); !reflect.DeepEqual(after, before) {
+		t.Errorf("清理预演不应修改备份: 之前 %v，之后 %v", before, after)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "copy-ignore备份")); !os.IsNotExist(err) {
+		t.Error("清理预演不应创建历史目录")
+	}
+
+	// 实际清理移走的文件与预演列出的一致
+	if code := logics.RunCopy([]string{searchRoot, dest}); code != 0 {
+		t.Fatalf("复制失败，退出码 %d", code)
+	}
+	after := snapshotTree(t, backupRepo)
+	var moved []string
+	for rel := range before {
+		if _, ok := after[rel]; !ok {
+			moved = append(moved, filepath.Join(backupRepo, rel))
+		}
+	}
+	sort.Strings(moved)
+	if len(moved) != 2 || !reflect.DeepEqual(previewed, moved) {
+		t.Errorf("预演列出的文件应与实际清理的一致: 预演 %v，实际 %v", previewed, moved)
+	}
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/logics"
+)
+
+func TestCheckPatterns(t *testing.T) {
+	if err := exclude.CheckPatterns([]string{"*.log", "**/vendor/**", "node_modules", "/abs/[path"}); err != nil {
+		t.Errorf("有效的模式不应报错: %v", err)
+	}
+	for _, pattern := range []string{"[abc", "**/{a,b"} {
+		if err := exclude.CheckPatterns([]string{"*.log", pattern}); err == nil {
+			t.Errorf("%s 应被识别为无效的模式", pattern)
+		}
+	}
+}
+
+func TestRunConfigLint(t *testing.T) {
+	base := t.TempDir()
+	search := filepath.Join(base, "src")
+	if err := os.MkdirAll(search, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	dest := filepath.Join(base, "dest")
+
+	if code := logics.RunConfig([]string{"lint", search, dest}); code != 0 {
+		t.Errorf("有效的配置应检查通过，退出码 %d", code)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Error("检查配置不应创建备份根目录")
+	}
+
+	// 搜索根目录位于备份根目录之内、保留数为 0、模式无效
+	if code := logics.RunConfig([]string{"lint", "--backup-keep", "0", "--exclude", "[abc", search, base}); code != 1 {
+		t.Errorf("有问题的配置应返回 1，实际 %d", code)
+	}
+}
+
+func TestConfigWriteEffective(t *testing.T) {
+	cfg := &config.Config{SearchRoot: "/src", Excludes: []string{"*.log", "vendor"}, BackupKeep: 3}
+	var b strings.Builder
+	cfg.WriteEffective(&b, "# ")
+	out := b.String()
+	for _, want := range []string{`SearchRoot `, `"/src"`, `["*.log", "vendor"]`, `BackupKeep `, `HistoryDir `, `""`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("输出中缺少 %s:\n%s", want, out)
+		}
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if !strings.HasPrefix(line, "# ") {
+			t.Errorf("每行都应以前缀开头: %q", line)
+		}
+	}
+}
+
+// TestRunConfigLint_ConfigFile 从 YAML、TOML 配置文件读取选项和目录，命令行指定的选项优先
+func TestRunConfigLint_ConfigFile(t *testing.T) {
+	base := t.TempDir()
+	search := filepath.Join(base, "src")
+	if err := os.MkdirAll(search, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	dest := filepath.Join(base, "dest")
+
+	// 配置文件中保留数为 0、模式无效，命令行覆盖后检查通过
+	yamlFile := filepath.Join(base, "copy-ignore.yaml")
+	writeTestFile(t, base, "copy-ignore.yaml", "roots:\n  - "+search+"\nbackup: "+dest+"\nbackup-keep: 0\nexclude: [\"[abc\"]\nverbose: true\n")
+	if code := logics.RunConfig([]string{"lint", "--config", yamlFile}); code != 1 {
+		t.Errorf("配置文件中有问题时应返回 1，实际 %d", code)
+	}
+	if code := logics.RunConfig([]string{"lint", "--config", yamlFile, "--backup-keep", "3", "--exclude", "*.log"}); code != 0 {
+		t.Errorf("命令行指定的选项应取代配置文件中的取值，退出码 %d", code)
+	}
+
+	tomlFile := filepath.Join(base, "copy-ignore.toml")
+	writeTestFile(t, base, "copy-ignore.toml", "roots = [\""+filepath.ToSlash(search)+"\"]\nbackup = \""+filepath.ToSlash(dest)+"\"\nexclude = [\"*.log\", \"**/vendor\"]\nconcurrency = 4\nstall-timeout = \"5m\"\n")
+	if code := logics.RunConfig([]string{"lint", "--config", tomlFile}); code != 0 {
+		t.Errorf("有效的 TOML 配置应检查通过，退出码 %d", code)
+	}
+	// 命令行给出的目录取代配置文件中的目录
+	if code := logics.RunConfig([]string{"lint", "--config", tomlFile, search, base}); code != 1 {
+		t.Errorf("命令行给出的目录应优先（搜索根目录位于备份根目录之内），实际 %d", code)
+	}
+}
+
+// TestRunConfigLint_BadArgs 参数错误时返回退出码而不是直接退出进程
+func TestRunConfigLint_BadArgs(t *testing.T) {
+	base := t.TempDir()
+	cases := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"缺少目录", []string{"lint"}, 1},
+		{"配置文件不存在", []string{"lint", "--config", filepath.Join(base, "missing.yaml")}, 2},
+		{"未知选项", []string{"lint", "--no-such-flag", base, filepath.Join(base, "dest")}, 2},
+		{"帮助", []string{"lint", "-h"}, 0},
+	}
+	for _, c := range cases {
+		if code := logics.RunConfig(c.args); code != c.want {
+			t.Errorf("%s: 退出码应为 %d，实际 %d", c.name, c.want, code)
+		}
+	}
+	if code := logics.RunCopy([]string{"--config", filepath.Join(base, "missing.yaml")}); code != 2 {
+		t.Errorf("复制模式配置文件不存在时应返回 2，实际 %d", code)
+	}
+}
+
+// TestRunConfigLint_Dests 多目标复制：各目标不能互相包含，不能共用会写入同一位置的文件
+func TestRunConfigLint_Dests(t *testing.T) {
+	base := t.TempDir()
+	search := filepath.Join(base, "src")
+	if err := os.MkdirAll(search, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	dest := filepath.Join(base, "dest")
+	nas := filepath.Join(base, "nas")
+
+	if code := logics.RunConfig([]string{"lint", "--dest", nas, search, dest}); code != 0 {
+		t.Errorf("互不包含的多个目标应检查通过，退出码 %d", code)
+	}
+	for _, args := range [][]string{
+		{"--dest", filepath.Join(dest, "mirror")},
+		{"--dest", nas, "--history-dir", filepath.Join(base, "history")},
+		{"--dest", nas, "--dest-retries", "-1"},
+		{"--dest", filepath.Join(search, "inner", "..")},
+	} {
+		if code := logics.RunConfig(append(append([]string{"lint"}, args...), search, dest)); code != 1 {
+			t.Errorf("%v 应检查不通过，实际 %d", args, code)
+		}
+	}
+}
+
+func TestRunConfigLint_Snapshot(t *testing.T) {
+	base := t.TempDir()
+	search := filepath.Join(base, "src")
+	if err := os.MkdirAll(search, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	dest := filepath.Join(base, "dest")
+
+	if code := logics.RunConfig([]string{"lint", "--snapshot", "zfs", "--snapshot-opt", "dataset=tank/backup", search, dest}); code != 0 {
+		t.Errorf("有效的快照配置应检查通过，退出码 %d", code)
+	}
+	for _, args := range [][]string{
+		{"--snapshot", "lvm"},
+		{"--snapshot", "synology", "--snapshot-opt", "url=https://nas:5001"},
+		{"--snapshot-opt", "dataset=tank"},
+	} {
+		if code := logics.RunConfig(append(append([]string{"lint"}, args...), search, dest)); code != 1 {
+			t.Errorf("%v 应检查不通过，实际 %d", args, code)
+		}
+	}
+}
+
+func TestRunConfigLint_Output(t *testing.T) {
+	base := t.TempDir()
+	search := filepath.Join(base, "src")
+	if err := os.MkdirAll(search, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	dest := filepath.Join(base, "dest")
+
+	if code := logics.RunConfig([]string{"lint", "--dry-run", "--output", "json", search, dest}); code != 0 {
+		t.Errorf("干运行的 JSON 输出应检查通过，退出码 %d", code)
+	}
+	for _, args := range [][]string{
+		{"--output", "json"},
+		{"--dry-run", "--output", "xml"},
+		{"--dry-run", "--explain", "--output", "json"},
+	} {
+		if code := logics.RunConfig(append(append([]string{"lint"}, args...), search, dest)); code != 1 {
+			t.Errorf("%v 应检查不通过，实际 %d", args, code)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/aogg/copy-ignore/src/config"
 	"github.com/aogg/copy-ignore/src/copy"
+	"github.com/aogg/copy-ignore/src/manifest"
 	"github.com/aogg/copy-ignore/src/scanner"
 )
 
@@ -69,6 +71,11 @@ func TestCopyFiles_SingleFile(t *testing.T) {
 		t.Errorf("目标文件内容不匹配")
 	}
 
+	// 各环节耗时：每个复制协程都有记录，写入的字节数与文件大小一致
+	if tp := result.Throughput; len(tp.Workers) != 2 || tp.Bytes != int64(len(content)) || tp.Fsync <= 0 || tp.Wall <= 0 {
+		t.Errorf("复制耗时统计不正确: %+v", tp)
+	}
+
 	// 验证时间戳
 	srcStat, err := os.Stat(srcFile)
 	if err != nil {
@@ -448,3 +455,563 @@ func TestCopyFilesStreamWithProgress_ErrorHandling(t *testing.T) {
 		t.Errorf("最后回调的错误数不正确: 期望 1, 实际 %d", lastCall.errors)
 	}
 }
+
+func TestCopyFiles_NoOverwrite(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	backupRoot := filepath.Join(tempDir, "backup")
+
+	config.InitGlobalConfig(&config.Config{
+		BackupRoot:   backupRoot,
+		BackupDirs:   []string{backupRoot},
+		BackupKeep:   3,
+		BackupSubdir: "history",
+		Timestamp:    "20240101-000000",
+		NoOverwrite:  true,
+	})
+
+	writeTestFile(t, srcDir, "test.txt", "原内容")
+	srcFile := filepath.Join(srcDir, "test.txt")
+	fileInfo := scanner.IgnoredFileInfo{AbsPath: srcFile, RelativePath: "test.txt", RepoRoot: srcDir}
+
+	if _, err := copy.CopyFiles([]scanner.IgnoredFileInfo{fileInfo}, backupRoot, 2, false, nil); err != nil {
+		t.Fatalf("第一次复制失败: %v", err)
+	}
+
+	// 源文件更新后，新版本写入历史目录，已有目标保持不变
+	writeTestFile(t, srcDir, "test.txt", "新内容")
+	newer := time.Now().Add(time.Hour)
+	os.Chtimes(srcFile, newer, newer)
+	if _, err := copy.CopyFiles([]scanner.IgnoredFileInfo{fileInfo}, backupRoot, 2, false, nil); err != nil {
+		t.Fatalf("第二次复制失败: %v", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(backupRoot, "test.txt")); string(data) != "原内容" {
+		t.Errorf("不覆盖模式下已有目标不应被修改，实际内容 %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(backupRoot, "history", "20240101-000000", "test.txt")); string(data) != "新内容" {
+		t.Errorf("新版本应写入历史目录，实际内容 %q", data)
+	}
+
+	// 历史目录中已是最新版本时不再重复写入
+	result, err := copy.CopyFiles([]scanner.IgnoredFileInfo{fileInfo}, backupRoot, 2, false, nil)
+	if err != nil {
+		t.Fatalf("第三次复制失败: %v", err)
+	}
+	if result.Copied != 0 || result.Skipped != 1 {
+		t.Errorf("历史版本已是最新时应跳过: %+v", result)
+	}
+}
+
+func TestCopyFilesStreamWithProgress_Conflict(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	backupRoot := filepath.Join(tempDir, "backup")
+	cfg := &config.Config{
+		BackupRoot:   backupRoot,
+		BackupDirs:   []string{backupRoot},
+		BackupKeep:   3,
+		BackupSubdir: "history",
+		Timestamp:    "20240101-000000",
+		Concurrency:  2,
+		Layout:       "path",
+	}
+	config.InitGlobalConfig(cfg)
+
+	writeTestFile(t, srcDir, "a.env", "A=1")
+	fileInfo := scanner.IgnoredFileInfo{AbsPath: filepath.Join(srcDir, "a.env"), RelativePath: "a.env", RepoRoot: srcDir}
+	run := func() *copy.CopyResult {
+		fileChan := make(chan scanner.IgnoredFileInfo, 1)
+		fileChan <- fileInfo
+		close(fileChan)
+		result, err := copy.CopyFilesStreamWithProgress(fileChan, nil, nil)
+		if err != nil {
+			t.Fatalf("复制失败: %v", err)
+		}
+		return result
+	}
+
+	run()
+	if _, err := manifest.Update(backupRoot, cfg.ManagedDirs(backupRoot), cfg.Hash); err != nil {
+		t.Fatalf("更新清单失败: %v", err)
+	}
+
+	// 源文件和备份都被修改
+	later := time.Now().Add(time.Hour)
+	writeTestFile(t, backupRoot, "a.env", "A=backup")
+	os.Chtimes(filepath.Join(backupRoot, "a.env"), later, later)
+	writeTestFile(t, srcDir, "a.env", "A=source")
+	os.Chtimes(fileInfo.AbsPath, later.Add(time.Hour), later.Add(time.Hour))
+
+	result := run()
+	if len(result.Conflicts) != 1 || result.Conflicts[0].SrcPath != fileInfo.AbsPath {
+		t.Errorf("期望检测到 1 个冲突，实际 %+v", result.Conflicts)
+	}
+}
+
+func TestCopyFilesStreamWithProgress_MaxErrors(t *testing.T) {
+	tempDir := t.TempDir()
+	backupRoot := filepath.Join(tempDir, "backup")
+
+	// 出错超过 2 个后中止
+	config.InitGlobalConfig(&config.Config{
+		BackupRoot:  backupRoot,
+		BackupKeep:  3,
+		Concurrency: 1,
+		MaxErrors:   2,
+	})
+
+	// 所有源文件都不存在，每个文件都会出错
+	fileChan := make(chan scanner.IgnoredFileInfo, 20)
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("missing%d.txt", i)
+		fileChan <- scanner.IgnoredFileInfo{
+			AbsPath:      filepath.Join(tempDir, name),
+			RelativePath: name,
+			RepoRoot:     tempDir,
+		}
+	}
+	close(fileChan)
+
+	result, err := copy.CopyFilesStreamWithProgress(fileChan, nil, nil)
+	if err != nil {
+		t.Fatalf("流式复制失败: %v", err)
+	}
+	if !result.Aborted {
+		t.Fatalf("出错数超过上限后应中止运行")
+	}
+	if result.Errors != 3 {
+		t.Errorf("期望中止前 3 个错误，实际 %d 个", result.Errors)
+	}
+	if result.Errors+result.Unprocessed != 20 {
+		t.Errorf("出错和未处理的文件数之和应为 20，实际 %d + %d", result.Errors, result.Unprocessed)
+	}
+}
+
+func TestCopyFilesStreamWithProgress_UnbufferedQueues(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	backupRoot := filepath.Join(tempDir, "backup")
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		t.Fatalf("创建源目录失败: %v", err)
+	}
+
+	// 队列不缓冲、单个工作协程时，文件数远多于缓冲大小也应全部完成（依靠背压而不是缓冲避免死锁）
+	config.InitGlobalConfig(&config.Config{
+		BackupRoot:    backupRoot,
+		BackupDirs:    []string{backupRoot},
+		BackupKeep:    3,
+		Concurrency:   1,
+		ScanQueueSize: 0,
+		JobQueueSize:  0,
+	})
+	defer config.InitGlobalConfig(nil)
+
+	const count = 200
+	fileChan := make(chan scanner.IgnoredFileInfo)
+	go func() {
+		defer close(fileChan)
+		for i := 0; i < count; i++ {
+			name := fmt.Sprintf("f%03d.txt", i)
+			path := filepath.Join(srcDir, name)
+			if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+				t.Errorf("创建源文件失败: %v", err)
+				return
+			}
+			fileChan <- scanner.IgnoredFileInfo{AbsPath: path, RelativePath: name}
+		}
+	}()
+
+	done := make(chan *copy.CopyResult)
+	go func() {
+		result, err := copy.CopyFilesStreamWithProgress(fileChan, nil, nil)
+		if err != nil {
+			t.Errorf("流式复制失败: %v", err)
+		}
+		done <- result
+	}()
+
+	select {
+	case result := <-done:
+		if result != nil && result.Copied != count {
+			t.Errorf("期望复制 %d 个文件，实际 %+v", count, result)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("不缓冲的队列下复制未完成，可能发生死锁")
+	}
+}
+
+func TestCopyFilesStreamWithProgress_RoundRobinAcrossRepos(t *testing.T) {
+	tempDir := t.TempDir()
+	backupRoot := filepath.Join(tempDir, "backup")
+	bigRepo := filepath.Join(tempDir, "src", "big")
+	smallRepo := filepath.Join(tempDir, "src", "small")
+	for _, dir := range []string{bigRepo, smallRepo} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("创建源目录失败: %v", err)
+		}
+	}
+
+	config.InitGlobalConfig(&config.Config{
+		BackupRoot:   backupRoot,
+		BackupKeep:   3,
+		Concurrency:  1,
+		JobQueueSize: 100,
+	})
+	defer config.InitGlobalConfig(nil)
+
+	// 大仓库的文件全部排在小仓库之前进入队列
+	const bigCount = 50
+	fileChan := make(chan scanner.IgnoredFileInfo, bigCount+1)
+	for i := 0; i < bigCount; i++ {
+		name := fmt.Sprintf("cache%03d.bin", i)
+		path := filepath.Join(bigRepo, name)
+		if err := os.WriteFile(path, make([]byte, 64*1024), 0644); err != nil {
+			t.Fatalf("创建源文件失败: %v", err)
+		}
+		fileChan <- scanner.IgnoredFileInfo{AbsPath: path, RelativePath: filepath.Join("big", name), RepoRoot: bigRepo}
+	}
+	envPath := filepath.Join(smallRepo, ".env")
+	if err := os.WriteFile(envPath, []byte("SECRET=1"), 0644); err != nil {
+		t.Fatalf("创建源文件失败: %v", err)
+	}
+	fileChan <- scanner.IgnoredFileInfo{AbsPath: envPath, RelativePath: filepath.Join("small", ".env"), RepoRoot: smallRepo}
+	close(fileChan)
+
+	var order []string
+	onProgress := func(copied, skipped, errors, total int, lastSrc, lastDest string) {
+		order = append(order, lastSrc)
+	}
+	if _, err := copy.CopyFilesStreamWithProgress(fileChan, onProgress, nil); err != nil {
+		t.Fatalf("流式复制失败: %v", err)
+	}
+
+	position := -1
+	for i, src := range order {
+		if src == envPath {
+			position = i
+		}
+	}
+	if position < 0 || position > 5 {
+		t.Errorf("小仓库的文件应与大仓库轮流派发，而不是排在最后，实际位于第 %d 个（共 %d 个）", position+1, len(order))
+	}
+}
+
+func TestCopyFilesStreamWithProgress_PriorityFirst(t *testing.T) {
+	tempDir := t.TempDir()
+	backupRoot := filepath.Join(tempDir, "backup")
+	repo := filepath.Join(tempDir, "src", "repo")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("创建源目录失败: %v", err)
+	}
+
+	config.InitGlobalConfig(&config.Config{
+		BackupRoot:   backupRoot,
+		BackupKeep:   3,
+		Concurrency:  1,
+		JobQueueSize: 100,
+		Priority:     []string{"**/*.key"},
+	})
+	defer config.InitGlobalConfig(nil)
+
+	// 同一仓库中，匹配 --priority 的文件最后进入队列
+	const count = 50
+	fileChan := make(chan scanner.IgnoredFileInfo, count+1)
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("cache%03d.bin", i)
+		path := filepath.Join(repo, name)
+		if err := os.WriteFile(path, make([]byte, 64*1024), 0644); err != nil {
+			t.Fatalf("创建源文件失败: %v", err)
+		}
+		fileChan <- scanner.IgnoredFileInfo{AbsPath: path, RelativePath: filepath.Join("repo", name), RepoRoot: repo}
+	}
+	keyPath := filepath.Join(repo, "id.key")
+	if err := os.WriteFile(keyPath, []byte("key"), 0644); err != nil {
+		t.Fatalf("创建源文件失败: %v", err)
+	}
+	fileChan <- scanner.IgnoredFileInfo{AbsPath: keyPath, RelativePath: filepath.Join("repo", "id.key"), RepoRoot: repo}
+	close(fileChan)
+
+	var order []string
+	onProgress := func(copied, skipped, errors, total int, lastSrc, lastDest string) {
+		order = append(order, lastSrc)
+	}
+	if _, err := copy.CopyFilesStreamWithProgress(fileChan, onProgress, nil); err != nil {
+		t.Fatalf("流式复制失败: %v", err)
+	}
+
+	position := -1
+	for i, src := range order {
+		if src == keyPath {
+			position = i
+		}
+	}
+	if position < 0 || position > 5 {
+		t.Errorf("匹配 --priority 的文件应优先复制，实际位于第 %d 个（共 %d 个）", position+1, len(order))
+	}
+}
+
+// panicExcluder 遇到指定路径时 panic，模拟复制过程中的程序缺陷
+type panicExcluder struct{ path string }
+
+func (e panicExcluder) ShouldExclude(path string) bool {
+	if path == e.path {
+		panic("测试用的 panic")
+	}
+	return false
+}
+
+func TestCopyFiles_PanicRecovered(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	backupRoot := filepath.Join(tempDir, "backup")
+	if err := os.MkdirAll(filepath.Join(srcDir, "build"), 0755); err != nil {
+		t.Fatalf("创建源目录失败: %v", err)
+	}
+	bad := filepath.Join(srcDir, "build", "bad.bin")
+	good := filepath.Join(srcDir, "good.env")
+	for _, path := range []string{bad, good} {
+		if err := os.WriteFile(path, []byte("内容"), 0644); err != nil {
+			t.Fatalf("创建源文件失败: %v", err)
+		}
+	}
+
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	config.InitGlobalConfig(&config.Config{BackupRoot: backupRoot})
+
+	files := []scanner.IgnoredFileInfo{
+		{AbsPath: filepath.Join(srcDir, "build"), RelativePath: "build", RepoRoot: srcDir},
+		{AbsPath: good, RelativePath: "good.env", RepoRoot: srcDir},
+	}
+	// verbose 模式下复制日志同样写入结果，不再因缺少日志回调而 panic
+	result, err := copy.CopyFiles(files, backupRoot, 1, true, panicExcluder{path: bad})
+	if err != nil {
+		t.Fatalf("复制失败: %v", err)
+	}
+	if result.Copied != 1 || result.Errors != 1 || result.Panics != 1 {
+		t.Fatalf("panic 的文件应计为出错，其余文件照常复制，实际 %+v", result)
+	}
+	if len(result.Failures) != 1 || result.Failures[0].Kind != "panic" {
+		t.Errorf("应记录 panic 的文件，实际 %+v", result.Failures)
+	}
+	if _, err := os.Stat(filepath.Join(backupRoot, "good.env")); err != nil {
+		t.Errorf("其余文件应已复制: %v", err)
+	}
+	if len(result.Logs) == 0 {
+		t.Errorf("verbose 模式下应记录复制日志")
+	}
+}
+
+func TestCopyFiles_CleanupLikeStream(t *testing.T) {
+	tempDir := t.TempDir()
+	searchRoot := filepath.Join(tempDir, "src")
+	repo := filepath.Join(searchRoot, "repo")
+	backupRoot := filepath.Join(tempDir, "backup")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("创建源目录失败: %v", err)
+	}
+	var files []scanner.IgnoredFileInfo
+	for _, name := range []string{"a.env", "b.env"} {
+		path := filepath.Join(repo, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("创建源文件失败: %v", err)
+		}
+		files = append(files, scanner.IgnoredFileInfo{AbsPath: path, RelativePath: filepath.Join("repo", name), RepoRoot: repo})
+	}
+
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	config.InitGlobalConfig(&config.Config{
+		SearchRoot:   searchRoot,
+		BackupRoot:   backupRoot,
+		BackupDirs:   []string{backupRoot},
+		BackupSubdir: "history",
+		BackupKeep:   5,
+		Timestamp:    "t1",
+	})
+
+	if _, err := copy.CopyFiles(files, backupRoot, 2, false, nil); err != nil {
+		t.Fatalf("复制失败: %v", err)
+	}
+
+	// 源文件被删除后，列表复制与流式复制一样把对应的备份移入历史目录
+	if err := os.Remove(files[1].AbsPath); err != nil {
+		t.Fatalf("删除源文件失败: %v", err)
+	}
+	result, err := copy.CopyFiles(files[:1], backupRoot, 2, false, nil)
+	if err != nil {
+		t.Fatalf("复制失败: %v", err)
+	}
+	if result.Cleanup == nil || result.Cleanup.Moved != 1 {
+		t.Fatalf("应将 1 个已删除源文件的备份移入历史目录，实际 %+v", result.Cleanup)
+	}
+	if _, err := os.Stat(filepath.Join(backupRoot, "repo", "b.env")); !os.IsNotExist(err) {
+		t.Errorf("已删除源文件的备份应已移走: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(backupRoot, "history", "t1", "repo", "b.env")); err != nil {
+		t.Errorf("备份应移入历史目录: %v", err)
+	}
+
+	// 复制到其他目录时不执行清理
+	other := filepath.Join(tempDir, "other")
+	result, err = copy.CopyFiles(files[:1], other, 2, false, nil)
+	if err != nil || result.Copied != 1 || result.Cleanup != nil {
+		t.Errorf("复制到其他目录时应只复制、不清理，实际 %+v %v", result, err)
+	}
+}
+
+func TestCopyFiles_OverwritePolicy(t *testing.T) {
+	tempDir := t.TempDir()
+	searchRoot := filepath.Join(tempDir, "src")
+	repo := filepath.Join(searchRoot, "repo")
+	backupRoot := filepath.Join(tempDir, "backup")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("创建源目录失败: %v", err)
+	}
+	src := filepath.Join(repo, "a.env")
+	dest := filepath.Join(backupRoot, "repo", "a.env")
+	files := []scanner.IgnoredFileInfo{{AbsPath: src, RelativePath: filepath.Join("repo", "a.env"), RepoRoot: repo}}
+
+	// 修改源文件并让它比备份新，再复制一次
+	modified := time.Now().Add(-time.Hour)
+	update := func(content string) *copy.CopyResult {
+		t.Helper()
+		modified = modified.Add(time.Minute)
+		if err := os.WriteFile(src, []byte(content), 0644); err != nil {
+			t.Fatalf("写入源文件失败: %v", err)
+		}
+		if err := os.Chtimes(src, modified, modified); err != nil {
+			t.Fatalf("修改时间失败: %v", err)
+		}
+		result, err := copy.CopyFiles(files, backupRoot, 1, false, nil)
+		if err != nil {
+			t.Fatalf("复制失败: %v", err)
+		}
+		return result
+	}
+
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	cfg := &config.Config{
+		SearchRoot:   searchRoot,
+		BackupRoot:   backupRoot,
+		BackupDirs:   []string{backupRoot},
+		BackupSubdir: "history",
+		BackupKeep:   1,
+		Timestamp:    "20260101-000000",
+		Overwrite:    config.OverwriteSuffixRename,
+	}
+	config.InitGlobalConfig(cfg)
+
+	update("v1")
+	result := update("v2")
+	if o := result.Stats.Overwrites(); o.Preserved != 1 || o.Total() != 1 {
+		t.Errorf("应保留 1 个旧版本，实际 %+v", o)
+	}
+	first := dest + ".20260101-000000" + config.OverwriteVersionSuffix
+	if data, err := os.ReadFile(first); err != nil || string(data) != "v1" {
+		t.Fatalf("旧版本应在原位置重命名保留: %q %v", data, err)
+	}
+
+	// 下一次运行再次覆盖：按 --backup-keep 只保留最新的旧版本，旧版本不被清理阶段当作已删除的文件
+	cfg.Timestamp = "20260102-000000"
+	result = update("v3")
+	if h := result.History; h.Moved != 1 || h.MovedBytes != 2 || h.Pruned != 1 || h.PrunedBytes != 2 {
+		t.Errorf("应统计保留和轮换删除的旧版本占用的空间，实际 %+v", h)
+	}
+	second := dest + ".20260102-000000" + config.OverwriteVersionSuffix
+	if data, err := os.ReadFile(second); err != nil || string(data) != "v2" {
+		t.Errorf("应保留最新的旧版本: %q %v", data, err)
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("超出保留数量的旧版本应被删除: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(backupRoot, "history")); !os.IsNotExist(err) {
+		t.Errorf("suffix-rename 不应写入历史目录: %v", err)
+	}
+
+	// none：直接覆盖，不保留旧版本
+	cfg.Overwrite = config.OverwriteNone
+	cfg.Timestamp = "20260103-000000"
+	result = update("v4")
+	if o := result.Stats.Overwrites(); o.Discarded != 1 || o.Preserved != 0 {
+		t.Errorf("none 应不保留旧版本，实际 %+v", o)
+	}
+	if _, err := os.Stat(dest + ".20260103-000000" + config.OverwriteVersionSuffix); !os.IsNotExist(err) {
+		t.Errorf("none 不应重命名旧版本: %v", err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "v4" {
+		t.Errorf("目标文件应为最新内容，实际 %q", data)
+	}
+}
+
+func TestCopyFiles_KeepDryRun(t *testing.T) {
+	cases := []struct {
+		name        string
+		keepDryRun  bool
+		interactive bool
+		yes         bool
+		pruned      bool
+	}{
+		{name: "预演", keepDryRun: true, yes: true},
+		{name: "交互式未确认", interactive: true},
+		{name: "交互式已确认", interactive: true, yes: true, pruned: true},
+		{name: "无人值守", pruned: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			repo := filepath.Join(tempDir, "src", "repo")
+			backupRoot := filepath.Join(tempDir, "backup")
+			if err := os.MkdirAll(repo, 0755); err != nil {
+				t.Fatalf("创建源目录失败: %v", err)
+			}
+			src := filepath.Join(repo, "a.env")
+			dest := filepath.Join(backupRoot, "repo", "a.env")
+			files := []scanner.IgnoredFileInfo{{AbsPath: src, RelativePath: filepath.Join("repo", "a.env"), RepoRoot: repo}}
+
+			defer config.InitGlobalConfig(config.GetGlobalConfig())
+			cfg := &config.Config{
+				SearchRoot:  filepath.Join(tempDir, "src"),
+				BackupRoot:  backupRoot,
+				BackupDirs:  []string{backupRoot},
+				BackupKeep:  1,
+				Overwrite:   config.OverwriteSuffixRename,
+				KeepDryRun:  c.keepDryRun,
+				Interactive: c.interactive,
+				Yes:         c.yes,
+			}
+			config.InitGlobalConfig(cfg)
+
+			// 三次运行各写入一个新版本，前两个版本成为旧版本
+			var result *copy.CopyResult
+			for i, stamp := range []string{"20260101-000000", "20260102-000000", "20260103-000000"} {
+				cfg.Timestamp = stamp
+				modified := time.Now().Add(time.Duration(i-10) * time.Minute)
+				if err := os.WriteFile(src, []byte(stamp), 0644); err != nil {
+					t.Fatalf("写入源文件失败: %v", err)
+				}
+				if err := os.Chtimes(src, modified, modified); err != nil {
+					t.Fatalf("修改时间失败: %v", err)
+				}
+				var err error
+				if result, err = copy.CopyFiles(files, backupRoot, 1, false, nil); err != nil {
+					t.Fatalf("复制失败: %v", err)
+				}
+			}
+
+			oldest := dest + ".20260102-000000" + config.OverwriteVersionSuffix
+			_, err := os.Stat(oldest)
+			if c.pruned {
+				if !os.IsNotExist(err) || len(result.History.Planned) != 0 || result.History.Pruned != 1 {
+					t.Errorf("应删除超出保留数的旧版本，实际 %+v %v", result.History, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("未确认时不应删除旧版本: %v", err)
+			}
+			if planned := result.History.Planned; len(planned) != 1 || planned[0].Path != dest || planned[0].Version != filepath.Base(oldest) || planned[0].Bytes != 15 {
+				t.Errorf("应列出将被删除的旧版本，实际 %+v", planned)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/copy"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+// runDeltaCopy 以增量模式将 oldData 更新为 newData，返回目标文件最终内容
+func runDeltaCopy(t *testing.T, oldData, newData []byte) []byte {
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src", "big.db")
+	backupRoot := filepath.Join(tempDir, "backup")
+	dest := filepath.Join(backupRoot, "big.db")
+	writeTestFile(t, filepath.Dir(src), "big.db", string(newData))
+	writeTestFile(t, backupRoot, "big.db", string(oldData))
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(dest, old, old)
+
+	config.InitGlobalConfig(&config.Config{BackupRoot: backupRoot, Concurrency: 1, DeltaThreshold: 1})
+	fileChan := make(chan scanner.IgnoredFileInfo, 1)
+	fileChan <- scanner.IgnoredFileInfo{AbsPath: src, RelativePath: "big.db", RepoRoot: filepath.Dir(src)}
+	close(fileChan)
+	result, err := copy.CopyFilesStreamWithProgress(fileChan, nil, nil)
+	if err != nil || result.Errors != 0 {
+		t.Fatalf("增量复制失败: %v %+v", err, result)
+	}
+
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("读取目标文件失败: %v", err)
+	}
+	return got
+}
+
+func TestDeltaCopy(t *testing.T) {
+	base := make([]byte, 300<<10)
+	rand.New(rand.NewSource(1)).Read(base)
+
+	modified := append([]byte{}, base...)
+	modified = append(append(modified[:100<<10:100<<10], []byte("changed")...), base[100<<10+7:]...)
+	inserted := append(append(append([]byte{}, base[:1000]...), []byte("inserted")...), base[1000:]...)
+	truncated := base[:200<<10+17]
+
+	for name, want := range map[string][]byte{"原位修改": modified, "插入数据": inserted, "截断": truncated} {
+		if got := runDeltaCopy(t, base, want); !bytes.Equal(got, want) {
+			t.Errorf("%s: 增量更新后内容不一致（长度 %d，期望 %d）", name, len(got), len(want))
+		}
+	}
+}
+
+func TestDeltaCopy_History(t *testing.T) {
+	base := make([]byte, 300<<10)
+	rand.New(rand.NewSource(2)).Read(base)
+	modified := append(append(append([]byte{}, base[:5000]...), []byte("changed")...), base[5007:]...)
+
+	tempDir := t.TempDir()
+	src := filepath.Join(tempDir, "src", "big.db")
+	backupRoot := filepath.Join(tempDir, "backup")
+	dest := filepath.Join(backupRoot, "big.db")
+	writeTestFile(t, filepath.Dir(src), "big.db", string(modified))
+	writeTestFile(t, backupRoot, "big.db", string(base))
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(dest, old, old)
+
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	config.InitGlobalConfig(&config.Config{BackupRoot: backupRoot, BackupDirs: []string{backupRoot}, BackupSubdir: "history",
+		Timestamp: "20260101-000000", BackupKeep: 3, Concurrency: 1, DeltaThreshold: 1})
+	fileChan := make(chan scanner.IgnoredFileInfo, 1)
+	fileChan <- scanner.IgnoredFileInfo{AbsPath: src, RelativePath: "big.db", RepoRoot: filepath.Dir(src)}
+	close(fileChan)
+	result, err := copy.CopyFilesStreamWithProgress(fileChan, nil, nil)
+	if err != nil || result.Errors != 0 {
+		t.Fatalf("增量复制失败: %v %+v", err, result)
+	}
+
+	// 增量更新与完整复制相同：旧版本移入历史目录，目标替换为新版本并同步修改时间
+	if got, err := os.ReadFile(dest); err != nil || !bytes.Equal(got, modified) {
+		t.Errorf("目标应更新为新版本: %v", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(backupRoot, "history", "20260101-000000", "big.db")); err != nil || !bytes.Equal(got, base) {
+		t.Errorf("旧版本应移入历史目录: %v", err)
+	}
+	srcInfo, _ := os.Stat(src)
+	if info, err := os.Stat(dest); err != nil || !info.ModTime().Equal(srcInfo.ModTime()) {
+		t.Errorf("目标的修改时间应与源文件一致: %v", err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(backupRoot, "big.db*")); len(matches) != 1 {
+		t.Errorf("不应留下临时文件: %v", matches)
+	}
+}
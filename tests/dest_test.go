@@ -0,0 +1,38 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+func TestProbeDestination_MissingMarker(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("通过 XDG_CONFIG_HOME 隔离本机记录，仅在 Linux 上运行")
+	}
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dest := t.TempDir()
+
+	// 首次使用：创建标记
+	if err := helpers.ProbeDestination(dest, false, false); err != nil {
+		t.Fatalf("首次使用不应报错: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, ".copy-ignore-dest")); err != nil {
+		t.Fatalf("首次使用应创建标记文件: %v", err)
+	}
+	if err := helpers.ProbeDestination(dest, false, false); err != nil {
+		t.Fatalf("标记存在时不应报错: %v", err)
+	}
+
+	// 模拟未挂载：标记消失，目录为空
+	os.Remove(filepath.Join(dest, ".copy-ignore-dest"))
+	if err := helpers.ProbeDestination(dest, false, false); err == nil {
+		t.Errorf("使用过的目标缺少标记时应报错")
+	}
+	if err := helpers.ProbeDestination(dest, true, false); err != nil {
+		t.Errorf("指定重新初始化时不应报错: %v", err)
+	}
+}
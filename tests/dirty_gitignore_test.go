@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/git"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+func TestScanIgnoredFiles_DirtyGitignore(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	root := t.TempDir()
+	clean := filepath.Join(root, "clean")
+	dirty := filepath.Join(root, "dirty")
+	for _, repo := range []string{clean, dirty} {
+		if err := os.MkdirAll(repo, 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+		initGitRepo(t, repo)
+		createGitignore(t, repo, "*.log\n")
+		createIgnoredFile(t, repo, "debug.log", "日志内容")
+	}
+	// 已提交的 .gitignore 被修改但未提交
+	if err := os.WriteFile(filepath.Join(dirty, ".gitignore"), []byte("*.log\n*.tmp\n"), 0644); err != nil {
+		t.Fatalf("修改 .gitignore 失败: %v", err)
+	}
+
+	if files, err := git.DirtyGitignores(clean); err != nil || len(files) != 0 {
+		t.Errorf("已提交的 .gitignore 不应被视为有修改: %v %v", files, err)
+	}
+	if files, err := git.DirtyGitignores(dirty); err != nil || len(files) != 1 || files[0] != ".gitignore" {
+		t.Errorf("应发现未提交修改的 .gitignore，实际 %v %v", files, err)
+	}
+
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	config.InitGlobalConfig(&config.Config{DirtyGitignore: config.DirtyGitignoreSkip})
+
+	excluder, err := exclude.NewMatcher([]string{})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	fileChan := make(chan scanner.IgnoredFileInfo, 10)
+	if err := scanner.ScanIgnoredFilesWithProgressStreamConcurrent(root, excluder, nil, fileChan, 2); err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	close(fileChan)
+
+	for file := range fileChan {
+		if file.RepoRoot != clean {
+			t.Errorf("不应处理 .gitignore 有未提交修改的仓库: %s", file.AbsPath)
+		}
+	}
+	if skipped := scanner.DirtyGitignoreRepos(); len(skipped) != 1 || skipped[0] != dirty {
+		t.Errorf("应记录跳过的仓库 %s，实际 %v", dirty, skipped)
+	}
+}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/logics"
+)
+
+// duLine 返回 du 输出中以 suffix 结尾的统计行的字段（大小、占比、文件数）
+func duLine(t *testing.T, out, suffix string) []string {
+	t.Helper()
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) == 6 && fields[5] == suffix {
+			return []string{fields[0] + " " + fields[1], fields[2], fields[3]}
+		}
+	}
+	t.Fatalf("输出中没有 %s 的统计:\n%s", suffix, out)
+	return nil
+}
+
+func TestDu_Totals(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	searchRoot := t.TempDir()
+	web := filepath.Join(searchRoot, "web")
+	api := filepath.Join(searchRoot, "api")
+	for _, repo := range []string{web, api} {
+		if err := os.MkdirAll(repo, 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+		initGitRepo(t, repo)
+		createGitignore(t, repo, "*.log\nnode_modules/\n")
+	}
+	writeTestFile(t, web, "node_modules/x.js", strings.Repeat("x", 300))
+	writeTestFile(t, web, "node_modules/y.js", strings.Repeat("y", 200))
+	createIgnoredFile(t, web, "a.log", strings.Repeat("a", 100))
+	createIgnoredFile(t, api, "b.log", strings.Repeat("b", 250))
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+
+	out, code := captureStdout(t, func() int { return logics.RunDu([]string{searchRoot}) })
+	if code != 0 {
+		t.Fatalf("du 应成功，退出码 %d", code)
+	}
+	if !strings.Contains(out, "被忽略的数据共 850 B（4 个文件，2 个仓库）") {
+		t.Errorf("总计错误:\n%s", out)
+	}
+	for suffix, want := range map[string][]string{
+		"web":                                {"600 B", "70.6%", "3"},
+		"api":                                {"250 B", "29.4%", "1"},
+		filepath.Join("web", "node_modules"): {"500 B", "58.8%", "2"},
+		filepath.Join("web", "a.log"):        {"100 B", "11.8%", "1"},
+	} {
+		if got := duLine(t, out, suffix); strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("%s 的统计应为 %v，实际 %v", suffix, want, got)
+		}
+	}
+
+	// 预估添加排除规则后的效果
+	out, code = captureStdout(t, func() int {
+		return logics.RunDu([]string{"--exclude", "**/node_modules/**", "--top", "1", searchRoot})
+	})
+	if code != 0 {
+		t.Fatalf("du 应成功，退出码 %d", code)
+	}
+	if !strings.Contains(out, "被忽略的数据共 350 B（2 个文件，2 个仓库）") {
+		t.Errorf("排除后的总计错误:\n%s", out)
+	}
+	top := out[strings.Index(out, "最大的被忽略文件/目录:"):]
+	if strings.Count(strings.TrimSpace(top), "\n") != 1 || !strings.Contains(top, filepath.Join("api", "b.log")) {
+		t.Errorf("--top 1 应只列出最大的 api/b.log:\n%s", top)
+	}
+}
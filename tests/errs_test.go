@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/errs"
+	"github.com/aogg/copy-ignore/src/git"
+)
+
+func TestClassify(t *testing.T) {
+	denied := fmt.Errorf("复制文件内容失败: %w", &fs.PathError{Op: "open", Path: "/backup/a.env", Err: syscall.EACCES})
+	err := errs.Classify(denied)
+	var perm *errs.PermissionError
+	if !errors.As(err, &perm) || perm.Path != "/backup/a.env" {
+		t.Fatalf("权限错误应归为 PermissionError，实际 %#v", err)
+	}
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("PermissionError 应能判断出底层的 fs.ErrPermission")
+	}
+	if kind := errs.Kind(fmt.Errorf("外层: %w", err)); kind != "permission" {
+		t.Errorf("包装后仍应识别错误类别，实际 %q", kind)
+	}
+
+	if runtime.GOOS != "windows" {
+		full := errs.Classify(fmt.Errorf("复制文件内容失败: %w", &fs.PathError{Op: "write", Path: "/backup/b.bin", Err: syscall.ENOSPC}))
+		var dest *errs.DestinationFullError
+		if !errors.As(full, &dest) || dest.Path != "/backup/b.bin" {
+			t.Errorf("空间不足应归为 DestinationFullError，实际 %#v", full)
+		}
+	}
+
+	other := errors.New("其他错误")
+	if errs.Classify(other) != other || errs.Kind(other) != "" {
+		t.Errorf("未知类别的错误应原样返回")
+	}
+}
+
+func TestGitCommandError(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	// 不是 Git 仓库的目录，git 命令以非 0/1 的退出码失败
+	dir := t.TempDir()
+	_, err := git.IsPathIgnored(dir, filepath.Join(dir, "a.log"))
+	var gitErr *errs.GitCommandError
+	if !errors.As(err, &gitErr) {
+		t.Fatalf("git 命令失败应返回 GitCommandError，实际 %#v", err)
+	}
+	if gitErr.Repo != dir || gitErr.Command != "check-ignore" {
+		t.Errorf("应记录仓库和子命令，实际 %q %q", gitErr.Repo, gitErr.Command)
+	}
+	if errs.Kind(err) != "git-command" {
+		t.Errorf("错误类别应为 git-command，实际 %q", errs.Kind(err))
+	}
+}
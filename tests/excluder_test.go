@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/logics"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+// suffixExcluder 自定义的排除器：排除指定后缀的文件
+type suffixExcluder struct{ suffix string }
+
+func (e suffixExcluder) ShouldExclude(path string) bool {
+	return strings.HasSuffix(path, e.suffix)
+}
+
+func TestScanIgnoredFiles_CustomExcluder(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	root := t.TempDir()
+	repo := filepath.Join(root, "repo")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	initGitRepo(t, repo)
+	createGitignore(t, repo, "*.log\n*.env\n")
+	createIgnoredFile(t, repo, "debug.log", "日志内容")
+	createIgnoredFile(t, repo, "app.env", "A=1")
+
+	files, err := scanner.ScanIgnoredFiles(root, suffixExcluder{".log"})
+	if err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	if len(files) != 1 || filepath.Base(files[0].AbsPath) != "app.env" {
+		t.Errorf("自定义排除器应排除 .log 文件，实际: %v", files)
+	}
+}
+
+func TestMatcher_ExcludeReason(t *testing.T) {
+	m, err := exclude.NewMatcher([]string{"*.log", "vendor"})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	tests := []struct {
+		path   string
+		reason string
+		want   bool
+	}{
+		{"/a/b/debug.log", "*.log", true},
+		{"/a/vendor/x.go", "vendor", true},
+		{"/a/b/main.go", "", false},
+	}
+	for _, tt := range tests {
+		reason, excluded := exclude.Reason(m, tt.path)
+		if excluded != tt.want || reason != tt.reason {
+			t.Errorf("%s: 期望 (%q, %v)，实际 (%q, %v)", tt.path, tt.reason, tt.want, reason, excluded)
+		}
+	}
+
+	// 不能说明原因的排除器只返回是否排除
+	if reason, excluded := exclude.Reason(suffixExcluder{".log"}, "/a/debug.log"); !excluded || reason != "" {
+		t.Errorf("自定义排除器: 期望 (\"\", true)，实际 (%q, %v)", reason, excluded)
+	}
+}
+
+func TestMatcher_Stats(t *testing.T) {
+	m, err := exclude.NewMatcher([]string{"*.log", "**/vendor/**", "*.lgo"})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	for _, path := range []string{"/a/x.log", "/a/y.log", "/a/vendor/z.log", "/a/vendor/z.go", "/a/main.go"} {
+		m.ShouldExclude(path)
+	}
+	// 同时匹配多条规则的路径只计入第一条
+	want := []exclude.PatternStat{{Pattern: "*.log", Count: 3}, {Pattern: "**/vendor/**", Count: 1}, {Pattern: "*.lgo", Count: 0}}
+	got := m.Stats()
+	if len(got) != len(want) {
+		t.Fatalf("期望 %v，实际 %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("第 %d 条规则: 期望 %v，实际 %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestMatcher_StatsUnusedAndRedundant(t *testing.T) {
+	m, err := exclude.NewMatcher([]string{"*.log", "debug.log", "*.lgo"})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	for _, path := range []string{"/a/debug.log", "/a/b/debug.log", "/a/x.log"} {
+		m.ShouldExclude(path)
+	}
+	stats := m.Stats()
+	if stats[0].Unused() || stats[0].Redundant() {
+		t.Errorf("*.log 排除了路径，不应提示: %+v", stats[0])
+	}
+	if !stats[1].Redundant() || stats[1].Shadowed != 2 || stats[1].ShadowedBy != "*.log" {
+		t.Errorf("debug.log 应被 *.log 覆盖: %+v", stats[1])
+	}
+	if !stats[2].Unused() {
+		t.Errorf("*.lgo 应提示没有匹配任何路径: %+v", stats[2])
+	}
+}
+
+func TestMatcher_SkipDirs(t *testing.T) {
+	m, err := exclude.NewMatcher(nil)
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	backup := filepath.Join(t.TempDir(), "src", "backup")
+	m.SkipDirs(backup)
+
+	if _, ok := m.PruneDir(filepath.Join(filepath.Dir(backup), "backup2")); ok {
+		t.Error("名称以备份根目录开头的其他目录不应被跳过")
+	}
+	if reason, ok := m.PruneDir(backup); !ok || !strings.Contains(reason, backup) {
+		t.Errorf("备份根目录应被跳过并说明原因，实际 %q %v", reason, ok)
+	}
+	if !m.ShouldExclude(filepath.Join(backup, "repo", ".env")) {
+		t.Error("备份根目录之内的文件应被排除")
+	}
+	stats := m.Stats()
+	if len(stats) != 1 || stats[0].Pattern != "工具自身的目录" || stats[0].Count != 2 {
+		t.Errorf("统计应只有工具自身的目录 2 个，实际 %+v", stats)
+	}
+}
+
+func TestSkipOwnArtifacts(t *testing.T) {
+	root := t.TempDir()
+	audit := filepath.Join(root, "repo", "audit.log")
+	cfg := &config.Config{
+		SearchRoot: root,
+		SharedRoot: filepath.Join(t.TempDir(), "backup"),
+		AuditLog:   audit,
+	}
+	m, err := exclude.NewMatcher(nil)
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	logics.SkipOwnArtifacts(cfg, m)
+
+	if reason, ok := m.ExcludeReason(audit); !ok || !strings.Contains(reason, "audit.log") {
+		t.Errorf("搜索根目录之内的审计日志应被排除，实际 %q %v", reason, ok)
+	}
+	if m.ShouldExclude(filepath.Join(root, "repo", "audit.log.1")) {
+		t.Error("与审计日志同名前缀的其他文件不应被排除")
+	}
+	if !m.ShouldExclude(filepath.Join(root, "repo", ".env"+".ci-0123abcd-1f.tmp")) {
+		t.Error("写入中的临时文件应被排除")
+	}
+	if m.ShouldExclude(filepath.Join(root, "repo", "data.tmp")) {
+		t.Error("普通的 .tmp 文件不应被排除")
+	}
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/git"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+func TestScan_SkipEvents(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	root := t.TempDir()
+	repo := filepath.Join(root, "repo")
+	if err := os.MkdirAll(filepath.Join(repo, "build"), 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	initGitRepo(t, repo)
+	createGitignore(t, repo, "*.log\nbuild/\n.env\n")
+	createIgnoredFile(t, repo, "debug.log", "日志内容")
+	createIgnoredFile(t, repo, filepath.Join("build", "a.out"), "bin")
+	createIgnoredFile(t, repo, ".env", "A=1")
+
+	var mu sync.Mutex
+	reasons := make(map[string]string)
+	scanner.SetSkipHandler(func(ev scanner.SkipEvent) {
+		mu.Lock()
+		reasons[filepath.Base(ev.Path)] = ev.Reason
+		mu.Unlock()
+	})
+	defer scanner.SetSkipHandler(nil)
+
+	excluder, err := exclude.NewMatcher([]string{"*.log"})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	fileChan := make(chan scanner.IgnoredFileInfo, 10)
+	if err := scanner.ScanIgnoredFilesWithProgressStream(root, excluder, nil, fileChan); err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	close(fileChan)
+
+	if r := reasons["debug.log"]; r != "排除规则: *.log" {
+		t.Errorf("debug.log 的过滤原因不正确: %q", r)
+	}
+	if r := reasons["a.out"]; !strings.Contains(r, "build") {
+		t.Errorf("build/a.out 应说明已包含在被忽略的目录中: %q", r)
+	}
+	if _, ok := reasons[".env"]; ok {
+		t.Error(".env 被列出，不应报告为被过滤")
+	}
+
+	rule, err := git.IgnoreSource(repo, filepath.Join(repo, ".env"))
+	if err != nil || rule != ".gitignore:3:.env" {
+		t.Errorf("忽略规则不正确: %q, %v", rule, err)
+	}
+}
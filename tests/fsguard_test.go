@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/fsguard"
+)
+
+func TestFSGuard_ReadOnlySource(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	backup := filepath.Join(src, "backup") // 位于搜索根目录之内、但允许写入的备份根目录
+	if err := os.MkdirAll(backup, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	file := filepath.Join(src, "a.txt")
+	if err := os.WriteFile(file, []byte("源文件"), 0644); err != nil {
+		t.Fatalf("写入文件失败: %v", err)
+	}
+
+	fsguard.Protect([]string{src}, []string{backup})
+	defer fsguard.Protect(nil, nil)
+
+	if err := fsguard.Remove(file, "测试"); !errors.Is(err, fsguard.ErrSourceWrite) {
+		t.Errorf("删除源文件应被拒绝，实际: %v", err)
+	}
+	if err := fsguard.WriteFile(file, []byte("x"), 0644, "测试"); !errors.Is(err, fsguard.ErrSourceWrite) {
+		t.Errorf("写入源文件应被拒绝，实际: %v", err)
+	}
+	if err := fsguard.Rename(file, filepath.Join(backup, "a.txt"), "测试"); !errors.Is(err, fsguard.ErrSourceWrite) {
+		t.Errorf("把源文件移走应被拒绝，实际: %v", err)
+	}
+	if _, err := fsguard.OpenFile(file, os.O_RDWR, 0, "测试"); !errors.Is(err, fsguard.ErrSourceWrite) {
+		t.Errorf("以写方式打开源文件应被拒绝，实际: %v", err)
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "源文件" {
+		t.Errorf("源文件应保持不变，实际 %q, %v", data, err)
+	}
+
+	// 只读打开和备份根目录内的写入不受限制
+	f, err := fsguard.OpenFile(file, os.O_RDONLY, 0, "测试")
+	if err != nil {
+		t.Errorf("只读打开源文件不应被拒绝: %v", err)
+	} else {
+		f.Close()
+	}
+	if err := fsguard.WriteFile(filepath.Join(backup, "b.txt"), []byte("备份"), 0644, "测试"); err != nil {
+		t.Errorf("写入备份根目录不应被拒绝: %v", err)
+	}
+}
+
+func TestFSGuard_AuditLog(t *testing.T) {
+	dir := t.TempDir()
+	var log bytes.Buffer
+	fsguard.SetAudit(&log)
+	defer fsguard.SetAudit(nil)
+
+	path := filepath.Join(dir, "a.txt")
+	if err := fsguard.WriteFile(path, []byte("x"), 0644, "写入测试文件"); err != nil {
+		t.Fatalf("写入文件失败: %v", err)
+	}
+	if err := fsguard.Remove(path, "删除测试文件"); err != nil {
+		t.Fatalf("删除文件失败: %v", err)
+	}
+	fsguard.Remove(path, "再次删除")
+
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("期望 3 行审计记录，实际: %q", log.String())
+	}
+	for i, want := range []string{"write\t" + path + "\t写入测试文件", "remove\t" + path + "\t删除测试文件", "remove\t" + path + "\t再次删除（失败: "} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("第 %d 行审计记录应包含 %q，实际: %q", i+1, want, lines[i])
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/chunkstore"
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/logics"
+)
+
+// setupHealFrom 将同一仓库复制到主目标和副本目标（big.bin 分块存储），返回搜索根目录、主目标、副本目标和 big.bin 的内容
+func setupHealFrom(t *testing.T) (string, string, string, []byte) {
+	t.Helper()
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	searchRoot := t.TempDir()
+	repo := filepath.Join(searchRoot, "repo")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	initGitRepo(t, repo)
+	createGitignore(t, repo, "*.log\n*.bin\n")
+	createIgnoredFile(t, repo, "small.log", "原始内容")
+	big := make([]byte, 3<<20)
+	rand.New(rand.NewSource(7)).Read(big)
+	createIgnoredFile(t, repo, "big.bin", string(big))
+
+	primary := filepath.Join(t.TempDir(), "primary")
+	secondary := filepath.Join(t.TempDir(), "secondary")
+	old := config.GetGlobalConfig()
+	t.Cleanup(func() { config.InitGlobalConfig(old) })
+	for _, dest := range []string{primary, secondary} {
+		if code := logics.RunCopy([]string{"--chunk-threshold", "1M", searchRoot, dest}); code != 0 {
+			t.Fatalf("复制失败，退出码 %d", code)
+		}
+	}
+	return searchRoot, primary, secondary, big
+}
+
+// runHealFrom 复制到主目标并从副本目标修复
+func runHealFrom(t *testing.T, searchRoot, primary, secondary string) {
+	t.Helper()
+	if code := logics.RunCopy([]string{"--chunk-threshold", "1M", "--heal-from", secondary, searchRoot, primary}); code != 0 {
+		t.Fatalf("复制失败，退出码 %d", code)
+	}
+}
+
+func TestHealFrom_CorruptedFile(t *testing.T) {
+	searchRoot, primary, secondary, _ := setupHealFrom(t)
+	corruptKeepingStat(t, filepath.Join(primary, "repo", "small.log"))
+
+	runHealFrom(t, searchRoot, primary, secondary)
+	if data, _ := os.ReadFile(filepath.Join(primary, "repo", "small.log")); string(data) != "原始内容" {
+		t.Errorf("损坏的文件应从副本目标修复，实际 %q", data)
+	}
+}
+
+func TestHealFrom_MissingChunk(t *testing.T) {
+	searchRoot, primary, secondary, big := setupHealFrom(t)
+	recipe, err := chunkstore.ReadRecipe(filepath.Join(primary, "repo", "big.bin"))
+	if err != nil || recipe == nil {
+		t.Fatalf("big.bin 应分块存储: %v", err)
+	}
+	hash := recipe.Chunks[0]
+	if err := os.Remove(filepath.Join(primary, config.ChunkDirName, hash[:2], hash)); err != nil {
+		t.Fatalf("删除块失败: %v", err)
+	}
+
+	runHealFrom(t, searchRoot, primary, secondary)
+	var out bytes.Buffer
+	if err := chunkstore.Open(primary).Restore(recipe, &out); err != nil || !bytes.Equal(out.Bytes(), big) {
+		t.Errorf("缺失的块应从副本目标的块池修复: %v", err)
+	}
+}
+
+func TestHealFrom_RefusesMismatchedSource(t *testing.T) {
+	searchRoot, primary, secondary, _ := setupHealFrom(t)
+	target := filepath.Join(primary, "repo", "small.log")
+	corruptKeepingStat(t, target)
+	damaged, _ := os.ReadFile(target)
+	// 副本中的文件同样损坏（内容与清单不一致），不能用来修复
+	if err := os.WriteFile(filepath.Join(secondary, "repo", "small.log"), []byte("副本内容不一致"), 0644); err != nil {
+		t.Fatalf("写入文件失败: %v", err)
+	}
+
+	runHealFrom(t, searchRoot, primary, secondary)
+	if data, _ := os.ReadFile(target); !bytes.Equal(data, damaged) {
+		t.Errorf("副本哈希与清单不一致时不应使用副本覆盖，实际 %q", data)
+	}
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// writeHistoryVersion 在历史目录的时间戳目录下写入一个旧版本，修改时间固定
+func writeHistoryVersion(t *testing.T, base, stamp, rel, content string) string {
+	t.Helper()
+	path := filepath.Join(base, stamp, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("写入文件失败: %v", err)
+	}
+	mtime := time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("修改时间失败: %v", err)
+	}
+	return path
+}
+
+func TestCompactHistory(t *testing.T) {
+	for _, mode := range []string{helpers.CompactHardlink, helpers.CompactDrop} {
+		t.Run(mode, func(t *testing.T) {
+			base := t.TempDir()
+			first := writeHistoryVersion(t, base, "20260101-000000", "repo/a.env", "same")
+			second := writeHistoryVersion(t, base, "20260102-000000", "repo/a.env", "same")
+			changed := writeHistoryVersion(t, base, "20260103-000000", "repo/a.env", "diff")
+			// 不是时间戳的目录不参与合并
+			other := writeHistoryVersion(t, base, "notes", "repo/a.env", "same")
+
+			opts := helpers.CompactOptions{Mode: mode, Layout: helpers.DefaultTimestampFormat, Location: time.Local}
+			dry := opts
+			dry.DryRun = true
+			preview, err := helpers.CompactHistory(base, dry)
+			if err != nil || preview.Merged != 1 || preview.Freed != 4 {
+				t.Fatalf("预演应统计 1 个可合并的版本，实际 %+v %v", preview, err)
+			}
+			if os.SameFile(mustStat(t, first), mustStat(t, second)) {
+				t.Fatalf("预演不应修改文件")
+			}
+
+			result, err := helpers.CompactHistory(base, opts)
+			if err != nil || result.Snapshots != 3 || result.Merged != 1 || result.Failed != 0 {
+				t.Fatalf("应合并 1 个重复版本，实际 %+v %v", result, err)
+			}
+			switch mode {
+			case helpers.CompactHardlink:
+				if !os.SameFile(mustStat(t, first), mustStat(t, second)) {
+					t.Errorf("相同的版本应改为硬链接")
+				}
+			case helpers.CompactDrop:
+				if _, err := os.Stat(filepath.Join(base, "20260102-000000")); !os.IsNotExist(err) {
+					t.Errorf("较新的重复版本及变空的目录应被删除: %v", err)
+				}
+			}
+			for _, path := range []string{first, changed, other} {
+				if _, err := os.Stat(path); err != nil {
+					t.Errorf("不应删除 %s: %v", path, err)
+				}
+			}
+		})
+	}
+}
+
+func mustStat(t *testing.T, path string) os.FileInfo {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("读取文件信息失败: %v", err)
+	}
+	return info
+}
+
+func TestPruneHistory(t *testing.T) {
+	base := t.TempDir()
+	for _, stamp := range []string{"20260101-000000", "20260102-000000", "20260103-000000", "20260104-000000"} {
+		writeHistoryVersion(t, base, stamp, "repo/a.env", "v")
+	}
+	// 不是时间戳的目录不会被删除
+	other := writeHistoryVersion(t, base, "notes", "repo/a.env", "v")
+
+	opts := helpers.PruneOptions{Keep: 2, Layout: helpers.DefaultTimestampFormat, Location: time.Local}
+	dry := opts
+	dry.DryRun = true
+	result, err := helpers.PruneHistory(base, dry)
+	if err != nil {
+		t.Fatalf("轮换预演失败: %v", err)
+	}
+	if result.Snapshots != 4 || len(result.Pruned) != 2 || result.PrunedBytes() != 2 {
+		t.Fatalf("预演结果不符合预期: %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(base, "20260101-000000")); err != nil {
+		t.Errorf("预演不应删除时间戳目录")
+	}
+
+	// 只删除早于指定时间的时间戳目录
+	before := opts
+	before.Before = time.Date(2026, 1, 2, 0, 0, 0, 0, time.Local)
+	if result, err := helpers.PruneHistory(base, before); err != nil || len(result.Pruned) != 1 || result.Pruned[0].Version != "20260101-000000" {
+		t.Fatalf("按时间轮换的结果不符合预期: %+v, %v", result, err)
+	}
+
+	if _, err := helpers.PruneHistory(base, opts); err != nil {
+		t.Fatalf("轮换失败: %v", err)
+	}
+	for stamp, want := range map[string]bool{"20260102-000000": false, "20260103-000000": true, "20260104-000000": true} {
+		if _, err := os.Stat(filepath.Join(base, stamp)); (err == nil) != want {
+			t.Errorf("时间戳目录 %s 存在: %v，期望 %v", stamp, err == nil, want)
+		}
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("不是时间戳的目录不应被删除")
+	}
+}
@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/layout"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+func TestScanIgnoredFiles_InsideRepository(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	repo := t.TempDir()
+	initGitRepo(t, repo)
+	createGitignore(t, repo, "*.log\nbuild/\n")
+	createIgnoredFile(t, repo, "root.log", "仓库根目录的日志")
+	sub := filepath.Join(repo, "sub")
+	if err := os.MkdirAll(filepath.Join(sub, "build"), 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	createIgnoredFile(t, sub, "debug.log", "子目录的日志")
+	createIgnoredFile(t, sub, filepath.Join("build", "out.bin"), "构建产物")
+
+	excluder, err := exclude.NewMatcher([]string{})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	fileChan := make(chan scanner.IgnoredFileInfo, 10)
+	if err := scanner.ScanIgnoredFilesWithProgressStreamConcurrent(sub, excluder, nil, fileChan, 2); err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	close(fileChan)
+
+	found := make(map[string]string)
+	for file := range fileChan {
+		found[file.RelativePath] = file.RepoRoot
+	}
+	// 只处理扫描起点之内的路径，相对路径相对于扫描起点，仓库根目录向上找到
+	want := []string{"debug.log", "build"}
+	if len(found) != len(want) {
+		t.Fatalf("期望 %v，实际 %v", want, found)
+	}
+	for _, rel := range want {
+		if found[rel] != repo {
+			t.Errorf("%s 应属于仓库 %s，实际 %v", rel, repo, found)
+		}
+	}
+}
+
+func TestRepoSubtree_InsideRepository(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := filepath.Join(tempDir, "proj")
+	search := filepath.Join(repo, "sub")
+
+	pathMapper, _ := layout.Load(filepath.Join(tempDir, "backup"), layout.LayoutPath)
+	if got := pathMapper.RepoSubtree(repo, search); got != "" {
+		t.Errorf("path 布局下应对应备份根目录本身，实际 %q", got)
+	}
+	repoMapper, _ := layout.Load(filepath.Join(tempDir, "backup"), layout.LayoutRepo)
+	if got := repoMapper.RepoSubtree(repo, search); got != filepath.Join("proj", "sub") {
+		t.Errorf("repo 布局下应对应仓库名下的子目录，实际 %q", got)
+	}
+}
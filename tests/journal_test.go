@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// writeJournal 模拟中断时遗留的移动日志
+func writeJournal(t *testing.T, backupRoot, name, src, dest, phase string) {
+	content := fmt.Sprintf(`{"src":%q,"dest":%q,"phase":%q}`, src, dest, phase)
+	writeTestFile(t, filepath.Join(backupRoot, config.JournalDirName), name, content)
+}
+
+func TestRepairInterruptedMoves(t *testing.T) {
+	backupRoot := t.TempDir()
+	history := filepath.Join(backupRoot, "history")
+
+	// 复制到一半中断：源完整，目标不完整 -> 回滚
+	writeTestFile(t, backupRoot, "repo/a.env", "A=1")
+	writeTestFile(t, history, "repo/a.env", "A")
+	writeJournal(t, backupRoot, "move-1.json", filepath.Join(backupRoot, "repo/a.env"), filepath.Join(history, "repo/a.env"), "intent")
+
+	// 复制完成、删除源时中断 -> 完成移动
+	writeTestFile(t, backupRoot, "repo/b.env", "B=1")
+	writeTestFile(t, history, "repo/b.env", "B=1")
+	writeJournal(t, backupRoot, "move-2.json", filepath.Join(backupRoot, "repo/b.env"), filepath.Join(history, "repo/b.env"), "copied")
+
+	result, err := helpers.RepairInterruptedMoves(backupRoot, false)
+	if err != nil {
+		t.Fatalf("修复失败: %v", err)
+	}
+	if result.Completed != 1 || result.RolledBack != 1 || result.Failed != 0 {
+		t.Errorf("修复结果不正确: %+v", result)
+	}
+
+	if _, err := os.Stat(filepath.Join(backupRoot, "repo/a.env")); err != nil {
+		t.Errorf("回滚后源文件应保留: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(history, "repo/a.env")); !os.IsNotExist(err) {
+		t.Errorf("回滚后不完整的历史目标应删除")
+	}
+	if _, err := os.Stat(filepath.Join(backupRoot, "repo/b.env")); !os.IsNotExist(err) {
+		t.Errorf("完成移动后源文件应删除")
+	}
+	if _, err := os.Stat(filepath.Join(backupRoot, config.JournalDirName)); !os.IsNotExist(err) {
+		t.Errorf("处理完成后日志目录应移除")
+	}
+}
+
+func TestCleanupOrphanedTempFiles(t *testing.T) {
+	srcRoot := t.TempDir()
+	backupRoot := t.TempDir()
+	// 已结束的运行留下的临时文件（很久没有修改）
+	temp := func(rel string) string { return rel + ".ci-12345678-1.tmp" }
+
+	// 内容完整、只差重命名 -> 补完
+	writeTestFile(t, srcRoot, "repo/a.env", "A=1")
+	writeTestFile(t, backupRoot, temp("repo/a.env"), "A=1")
+	// 只写入了一部分 -> 删除
+	writeTestFile(t, srcRoot, "repo/b.env", "B=12345")
+	writeTestFile(t, backupRoot, temp("repo/b.env"), "B=1")
+	// 源文件本身就叫 *.tmp -> 正常的备份，不处理
+	writeTestFile(t, srcRoot, "repo/c.tmp", "C")
+	writeTestFile(t, backupRoot, "repo/c.tmp", "C")
+	// 源文件和目标文件都不存在 -> 保留
+	writeTestFile(t, backupRoot, temp("repo/d.env"), "D")
+	// 清单的临时文件 -> 删除
+	writeTestFile(t, backupRoot, config.ManifestFileName+".tmp", "{")
+	// 仍有移动日志的路径 -> 留给下次修复
+	writeTestFile(t, backupRoot, temp("repo/e.env"), "E")
+	writeJournal(t, backupRoot, "move-1.json", filepath.Join(backupRoot, temp("repo/e.env")), filepath.Join(backupRoot, "history/e.env.tmp"), "intent")
+	// 旧版本的 <文件名>.tmp 无法与普通备份区分（源文件 f.env.tmp 已删除、f.env 存在）-> 不删除也不重命名
+	writeTestFile(t, srcRoot, "repo/f.env", "F=new")
+	writeTestFile(t, backupRoot, "repo/f.env", "F=new")
+	writeTestFile(t, backupRoot, "repo/f.env.tmp", "F=draft")
+
+	old := time.Now().Add(-time.Hour)
+	for _, rel := range []string{"repo/a.env", "repo/b.env", "repo/d.env", "repo/e.env"} {
+		if err := os.Chtimes(filepath.Join(backupRoot, temp(rel)), old, old); err != nil {
+			t.Fatalf("设置修改时间失败: %v", err)
+		}
+	}
+
+	sourceOf := func(rel string) string { return filepath.Join(srcRoot, rel) }
+	result, err := helpers.CleanupOrphanedTempFiles(backupRoot, nil, nil, sourceOf, false)
+	if err != nil {
+		t.Fatalf("处理遗留的临时文件失败: %v", err)
+	}
+	if result.Found != 4 || result.Finalized != 1 || result.Removed != 2 || result.Kept != 1 {
+		t.Errorf("处理结果不正确: %+v", result)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(backupRoot, "repo/a.env")); err != nil || string(data) != "A=1" {
+		t.Errorf("内容完整的临时文件应补完为目标文件")
+	}
+	for _, rel := range []string{temp("repo/b.env"), config.ManifestFileName + ".tmp"} {
+		if _, err := os.Stat(filepath.Join(backupRoot, rel)); !os.IsNotExist(err) {
+			t.Errorf("临时文件应被删除: %s", rel)
+		}
+	}
+	for _, rel := range []string{"repo/c.tmp", temp("repo/d.env"), temp("repo/e.env")} {
+		if _, err := os.Stat(filepath.Join(backupRoot, rel)); err != nil {
+			t.Errorf("文件应保留: %s", rel)
+		}
+	}
+	for rel, want := range map[string]string{"repo/f.env.tmp": "F=draft", "repo/f.env": "F=new"} {
+		if data, err := os.ReadFile(filepath.Join(backupRoot, rel)); err != nil || string(data) != want {
+			t.Errorf("旧版本命名的 .tmp 文件及其目标不应被改动: %s = %q %v", rel, data, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/logics"
+)
+
+// scanDocument --output json 输出的文档
+type scanDocument struct {
+	SearchRoot string `json:"search_root"`
+	Entries    []struct {
+		AbsPath      string    `json:"abs_path"`
+		RelativePath string    `json:"relative_path"`
+		RepoRoot     string    `json:"repo_root"`
+		Dir          bool      `json:"dir"`
+		Size         int64     `json:"size"`
+		ModTime      time.Time `json:"mtime"`
+	} `json:"entries"`
+	Totals struct {
+		Entries int   `json:"entries"`
+		Dirs    int   `json:"dirs"`
+		Bytes   int64 `json:"bytes"`
+		Repos   int   `json:"repos"`
+	} `json:"totals"`
+	Error string `json:"error"`
+}
+
+// captureStdout 运行 fn，返回其间写入标准输出的内容
+func captureStdout(t *testing.T, fn func() int) (string, int) {
+	t.Helper()
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatalf("创建输出文件失败: %v", err)
+	}
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	code := fn()
+	os.Stdout = stdout
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatalf("读取输出失败: %v", err)
+	}
+	return string(data), code
+}
+
+func TestOutputJSON(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	tempDir := t.TempDir()
+	searchRoot := filepath.Join(tempDir, "src")
+	repo := filepath.Join(searchRoot, "web")
+	if err := os.MkdirAll(filepath.Join(repo, "build"), 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	initGitRepo(t, repo)
+	createGitignore(t, repo, ".env\nbuild/\n")
+	createIgnoredFile(t, repo, ".env", "A=1")
+	createIgnoredFile(t, repo, filepath.Join("build", "a.out"), "binary")
+
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	for _, c := range []struct {
+		name    string
+		args    []string
+		dirSize int64 // 干运行不统计整体复制的目录的大小，scan 为其中文件的总大小
+	}{
+		{"干运行", []string{"--dry-run", "--output", "json", searchRoot, filepath.Join(tempDir, "backup")}, 0},
+		{"scan", []string{"scan", "--output", "json", searchRoot}, int64(len("binary"))},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			out, code := captureStdout(t, func() int {
+				if c.args[0] == "scan" {
+					return logics.RunScan(c.args[1:])
+				}
+				return logics.RunCopy(c.args)
+			})
+			if code != 0 {
+				t.Fatalf("应成功，退出码 %d", code)
+			}
+			var doc scanDocument
+			if err := json.Unmarshal([]byte(out), &doc); err != nil {
+				t.Fatalf("标准输出应只有一个 JSON 文档: %v\n%s", err, out)
+			}
+			if doc.Totals.Entries != 2 || doc.Totals.Dirs != 1 || doc.Totals.Repos != 1 || len(doc.Entries) != 2 {
+				t.Fatalf("条目或汇总不正确: %+v", doc)
+			}
+			for _, e := range doc.Entries {
+				if e.RepoRoot != repo || e.AbsPath != filepath.Join(searchRoot, e.RelativePath) || e.ModTime.IsZero() {
+					t.Errorf("条目的路径或修改时间不正确: %+v", e)
+				}
+				switch {
+				case e.RelativePath == filepath.Join("web", ".env") && (e.Dir || e.Size != 3):
+					t.Errorf(".env 应为 3 字节的文件: %+v", e)
+				case e.RelativePath == filepath.Join("web", "build") && (!e.Dir || e.Size != c.dirSize):
+					t.Errorf("build 应为大小 %d 的目录: %+v", c.dirSize, e)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/logics"
+)
+
+// readLastRun 读取运行摘要
+func readLastRun(t *testing.T, path string) logics.LastRun {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取运行摘要失败: %v", err)
+	}
+	var run logics.LastRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		t.Fatalf("解析运行摘要失败: %v\n%s", err, data)
+	}
+	return run
+}
+
+func TestLastRun_WrittenOnSuccessAndFailure(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	searchRoot := t.TempDir()
+	repo := filepath.Join(searchRoot, "repo")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	initGitRepo(t, repo)
+	createGitignore(t, repo, "*.log\n")
+	createIgnoredFile(t, repo, "a.log", "aaa")
+	createIgnoredFile(t, repo, "b.log", "bbbb")
+	dest := filepath.Join(t.TempDir(), "backup")
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+
+	if code := logics.RunCopy([]string{searchRoot, dest}); code != 0 {
+		t.Fatalf("复制失败，退出码 %d", code)
+	}
+	run := readLastRun(t, filepath.Join(dest, "last-run.json"))
+	if !run.Success || run.FatalError != "" || run.Totals.Copied != 2 || run.Totals.Errors != 0 || run.Totals.CopiedBytes != 7 {
+		t.Errorf("成功运行的摘要错误: %+v", run.RunRecord)
+	}
+	if run.FinishedAt.Before(run.StartedAt) || run.Config == nil || run.Config.BackupRoot != dest {
+		t.Errorf("运行摘要应包含时间和配置: %+v", run)
+	}
+
+	// 仓库的备份目录被同名文件占用，两个文件都复制失败；摘要写入 --last-run 指定的位置
+	if err := os.RemoveAll(filepath.Join(dest, "repo")); err != nil {
+		t.Fatalf("删除目录失败: %v", err)
+	}
+	writeTestFile(t, dest, "repo", "occupied")
+	summary := filepath.Join(t.TempDir(), "monitor", "summary.json")
+	logics.RunCopy([]string{"--last-run", summary, searchRoot, dest})
+	run = readLastRun(t, summary)
+	if run.Success || run.Totals.Errors != 2 || len(run.Failures) != 2 {
+		t.Errorf("失败运行的摘要应记录出错的文件: %+v, %+v", run.RunRecord, run.Failures)
+	}
+	if prev := readLastRun(t, filepath.Join(dest, "last-run.json")); !prev.Success {
+		t.Error("指定 --last-run 时不应改写备份根目录下的 last-run.json")
+	}
+
+	// 扫描失败导致运行中止时同样写入摘要
+	if runtime.GOOS == "windows" {
+		return
+	}
+	makeUnreadableDir(t, searchRoot)
+	if code := logics.RunCopy([]string{"--last-run", summary, searchRoot, dest}); code == 0 {
+		t.Fatal("扫描失败时应以非零退出码结束")
+	}
+	if run = readLastRun(t, summary); run.Success || run.FatalError == "" {
+		t.Errorf("运行中止的摘要应记录错误: %+v", run.RunRecord)
+	}
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/layout"
+)
+
+func TestLayoutMapper_RepoNames(t *testing.T) {
+	tempDir := t.TempDir()
+	backupRoot := filepath.Join(tempDir, "backup")
+	repoA := filepath.Join(tempDir, "a", "app")
+	repoB := filepath.Join(tempDir, "b", "app")
+	os.MkdirAll(repoA, 0755)
+	os.MkdirAll(repoB, 0755)
+
+	m, _ := layout.Load(backupRoot, layout.LayoutRepo)
+	if name := m.RepoName(repoA); name != "app" {
+		t.Errorf("期望使用目录名 app，实际 %s", name)
+	}
+	if name := m.RepoName(repoB); !strings.HasPrefix(name, "app-") {
+		t.Errorf("同名仓库应追加哈希后缀，实际 %s", name)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("保存映射失败: %v", err)
+	}
+
+	// 仓库 A 移动到新位置后，新位置沿用原名称
+	moved := filepath.Join(tempDir, "moved", "app")
+	os.MkdirAll(filepath.Dir(moved), 0755)
+	os.Rename(repoA, moved)
+	reloaded, _ := layout.Load(backupRoot, layout.LayoutRepo)
+	if name := reloaded.RepoName(moved); name != "app" {
+		t.Errorf("移动后的仓库应沿用名称 app，实际 %s", name)
+	}
+}
+
+func TestLayoutMapper_Source(t *testing.T) {
+	tempDir := t.TempDir()
+	searchRoot := filepath.Join(tempDir, "src")
+	repo := filepath.Join(searchRoot, "group", "app")
+
+	pathMapper, _ := layout.Load(filepath.Join(tempDir, "backup"), layout.LayoutPath)
+	rel := filepath.Join("group", "app", ".env")
+	if src := pathMapper.Source(rel, searchRoot); src != filepath.Join(searchRoot, rel) {
+		t.Errorf("path 布局反推源文件错误: %s", src)
+	}
+
+	repoMapper, _ := layout.Load(filepath.Join(tempDir, "backup"), layout.LayoutRepo)
+	name := repoMapper.RepoName(repo)
+	if src := repoMapper.Source(filepath.Join(name, ".env"), searchRoot); src != filepath.Join(repo, ".env") {
+		t.Errorf("repo 布局反推源文件错误: %s", src)
+	}
+	if src := repoMapper.Source(filepath.Join("unknown", ".env"), searchRoot); src != "" {
+		t.Errorf("未知仓库名应返回空字符串，实际 %s", src)
+	}
+}
@@ -0,0 +1,38 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+func TestAcquireLease(t *testing.T) {
+	root := t.TempDir()
+
+	lease, err := helpers.AcquireLease(root, "laptop", "laptop")
+	if err != nil {
+		t.Fatalf("获取租约失败: %v", err)
+	}
+	if _, err := helpers.AcquireLease(root, "laptop", "laptop"); err == nil {
+		t.Errorf("同一台机器的租约未释放时不应再次获取")
+	}
+
+	other, err := helpers.AcquireLease(root, "desk", ".")
+	if err != nil {
+		t.Fatalf("其他机器获取租约失败: %v", err)
+	}
+	if others := lease.OtherActiveLeases(root); len(others) != 1 || others[0].Host != "desk" {
+		t.Errorf("直接写入共享根目录的租约应与本机重叠，实际: %+v", others)
+	}
+	other.Release()
+	if others := lease.OtherActiveLeases(root); len(others) != 0 {
+		t.Errorf("释放后不应再有其他机器的租约，实际: %+v", others)
+	}
+
+	lease.Release()
+	again, err := helpers.AcquireLease(root, "laptop", "laptop")
+	if err != nil {
+		t.Fatalf("释放后应能重新获取租约: %v", err)
+	}
+	again.Release()
+}
@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/manifest"
+)
+
+func TestDestPathTooLong(t *testing.T) {
+	if helpers.DestPathTooLong(filepath.Join("backup", "repo", "a.env"), 0) {
+		t.Errorf("普通路径不应判定为过长")
+	}
+	if !helpers.DestPathTooLong(filepath.Join("backup", strings.Repeat("n", 253)), 0) {
+		t.Errorf("加上 .tmp 后缀超过 255 字节的文件名应判定为过长")
+	}
+	if !helpers.DestPathTooLong(filepath.Join("backup", "repo", "a.env"), 16) {
+		t.Errorf("超过 --max-path-len 的路径应判定为过长")
+	}
+}
+
+func TestLongPathTarget(t *testing.T) {
+	backupRoot := t.TempDir()
+	rel := filepath.Join("repo", strings.Repeat("n", 300)+".log")
+
+	target := helpers.LongPathTarget(backupRoot, rel)
+	if helpers.DestPathTooLong(target, 0) {
+		t.Fatalf("替代位置不应过长: %s", target)
+	}
+	if !strings.HasPrefix(target, filepath.Join(backupRoot, config.LongPathDirName)) || filepath.Ext(target) != ".log" {
+		t.Errorf("替代位置应位于哈希目录下并保留扩展名: %s", target)
+	}
+	if helpers.LongPathTarget(backupRoot, rel) != target {
+		t.Errorf("同一路径的替代位置应保持不变")
+	}
+
+	// 清单记录原始路径，原始路径记录文件本身不纳入清单
+	writeTestFile(t, filepath.Dir(target), filepath.Base(target), "log")
+	if err := helpers.WriteLongPathRecord(target, rel); err != nil {
+		t.Fatalf("记录原始路径失败: %v", err)
+	}
+	built, err := manifest.Build(backupRoot, nil, nil, "")
+	if err != nil {
+		t.Fatalf("生成清单失败: %v", err)
+	}
+	if len(built.Entries) != 1 {
+		t.Errorf("清单应只有 1 个条目，实际 %d 个", len(built.Entries))
+	}
+	key, _ := filepath.Rel(backupRoot, target)
+	if got := built.OriginalPath(filepath.ToSlash(key)); got != filepath.ToSlash(rel) {
+		t.Errorf("清单应记录原始路径，实际 %q", got)
+	}
+}
@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/manifest"
+)
+
+// writeTestFile 在 root 下创建文件（自动创建父目录）
+func writeTestFile(t *testing.T, root, rel, content string) {
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("创建文件失败: %v", err)
+	}
+}
+
+func TestManifestBuild_SkipsHistoryAndManifest(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "repo/.env", "A=1")
+	writeTestFile(t, root, "history/20240101-000000/repo/.env", "A=0")
+
+	m, err := manifest.Build(root, nil, []string{filepath.Join(root, "history")}, "")
+	if err != nil {
+		t.Fatalf("生成清单失败: %v", err)
+	}
+	if err := m.Save(root); err != nil {
+		t.Fatalf("保存清单失败: %v", err)
+	}
+
+	rebuilt, err := manifest.Build(root, m, []string{filepath.Join(root, "history")}, "")
+	if err != nil {
+		t.Fatalf("重新生成清单失败: %v", err)
+	}
+	if len(rebuilt.Entries) != 1 || rebuilt.Entries["repo/.env"].Hash == "" {
+		t.Errorf("清单内容不正确: %+v", rebuilt.Entries)
+	}
+}
+
+func TestManifestDiff(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	writeTestFile(t, a, "same.txt", "x")
+	writeTestFile(t, b, "same.txt", "x")
+	writeTestFile(t, a, "changed.txt", "1")
+	writeTestFile(t, b, "changed.txt", "2")
+	writeTestFile(t, a, "missing.txt", "m")
+	writeTestFile(t, b, "extra.txt", "e")
+
+	ma, _ := manifest.Build(a, nil, nil, "")
+	mb, _ := manifest.Build(b, nil, nil, "")
+	diffs := manifest.Diff(ma, mb)
+
+	want := []manifest.Difference{
+		{Path: "changed.txt", Kind: manifest.Changed},
+		{Path: "extra.txt", Kind: manifest.OnlyInOther},
+		{Path: "missing.txt", Kind: manifest.OnlyInBase},
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("期望 %d 处差异，实际 %d: %+v", len(want), len(diffs), diffs)
+	}
+	for i := range want {
+		if diffs[i] != want[i] {
+			t.Errorf("差异 %d: 期望 %+v，实际 %+v", i, want[i], diffs[i])
+		}
+	}
+}
+
+func TestManifestBuild_UsesCopiedHash(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "a.txt", "hello")
+	path := filepath.Join(root, "a.txt")
+	info, _ := os.Stat(path)
+	defer helpers.ResetCopiedHashes()
+
+	// 复制时记录的哈希直接写入清单，不再读取文件
+	helpers.RecordCopiedHash(path, info, "", "copied")
+	m, err := manifest.Build(root, nil, nil, "")
+	if err != nil {
+		t.Fatalf("生成清单失败: %v", err)
+	}
+	if got := m.Entries["a.txt"].Hash; got != "copied" {
+		t.Errorf("应使用复制时记录的哈希，实际 %s", got)
+	}
+
+	// 复制之后文件被改动，重新计算
+	later := info.ModTime().Add(time.Second)
+	os.Chtimes(path, later, later)
+	m, _ = manifest.Build(root, nil, nil, "")
+	want, _ := helpers.HashFile(path)
+	if got := m.Entries["a.txt"].Hash; got != want {
+		t.Errorf("文件改动后应重新计算哈希，实际 %s", got)
+	}
+}
+
+func TestManifest_HashAlgorithm(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "a.txt", "hello")
+
+	sha, err := manifest.Update(root, nil, "")
+	if err != nil {
+		t.Fatalf("生成清单失败: %v", err)
+	}
+	if sha.Algorithm != "sha256" {
+		t.Errorf("默认应使用 sha256，实际 %s", sha.Algorithm)
+	}
+
+	// 更换算法后所有文件按新算法重新计算，算法记录在清单中
+	for _, algorithm := range []string{"blake3", "xxh3"} {
+		if _, err := manifest.Update(root, nil, algorithm); err != nil {
+			t.Fatalf("%s: 生成清单失败: %v", algorithm, err)
+		}
+		loaded, err := manifest.Load(root)
+		if err != nil {
+			t.Fatalf("%s: 读取清单失败: %v", algorithm, err)
+		}
+		want, _ := helpers.HashFileWith(filepath.Join(root, "a.txt"), algorithm)
+		if loaded.Algorithm != algorithm || loaded.Entries["a.txt"].Hash != want {
+			t.Errorf("%s: 清单应记录算法和对应的哈希，实际 %s %s", algorithm, loaded.Algorithm, loaded.Entries["a.txt"].Hash)
+		}
+		if want == sha.Entries["a.txt"].Hash {
+			t.Errorf("%s: 哈希不应与 SHA-256 相同", algorithm)
+		}
+	}
+	if _, err := manifest.Update(root, nil, "md5"); err == nil {
+		t.Error("不支持的算法应返回错误")
+	}
+}
+
+func TestManifestLoad_Version1(t *testing.T) {
+	root := t.TempDir()
+	legacy := `{"version":1,"entries":{"a.txt":{"size":5,"mtime":"2026-01-01T00:00:00Z","sha256":"abc"}}}`
+	writeTestFile(t, root, manifest.FileName, legacy)
+
+	m, err := manifest.Load(root)
+	if err != nil {
+		t.Fatalf("读取版本 1 的清单失败: %v", err)
+	}
+	if m.Algorithm != "sha256" || m.Entries["a.txt"].Hash != "abc" {
+		t.Errorf("版本 1 的清单应按 SHA-256 读取，实际 %s %q", m.Algorithm, m.Entries["a.txt"].Hash)
+	}
+
+	writeTestFile(t, root, manifest.FileName, `{"version":99,"entries":{}}`)
+	if _, err := manifest.Load(root); err == nil {
+		t.Error("高于支持版本的清单应返回错误")
+	}
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/layout"
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+// initRepoWithOrigin 在 dir 创建 Git 仓库并设置 origin 远程地址（仓库身份）
+func initRepoWithOrigin(t *testing.T, dir, url string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	initGitRepo(t, dir)
+	cmd := exec.Command("git", "remote", "add", "origin", url)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("设置 origin 失败 %s: %v\n输出: %s", dir, err, output)
+	}
+}
+
+// observeRun 模拟一次复制运行：依次处理发现的仓库并保存身份记录，返回输出
+func observeRun(t *testing.T, backupRoot, searchRoot string, apply bool, repos ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	defer ui.SetOutput(ui.SetOutput(&out))
+
+	mapper, err := layout.Load(backupRoot, layout.LayoutPath)
+	if err != nil {
+		t.Fatalf("读取布局失败: %v", err)
+	}
+	m, err := layout.LoadMigrator(backupRoot, searchRoot, mapper, apply)
+	if err != nil {
+		t.Fatalf("读取仓库身份记录失败: %v", err)
+	}
+	for _, repo := range repos {
+		m.Observe(repo)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("保存仓库身份记录失败: %v", err)
+	}
+	return out.String()
+}
+
+// setupMovedRepo 创建已记录过一次的仓库 old 及其备份，并将仓库移动到 new
+func setupMovedRepo(t *testing.T) (backupRoot, searchRoot string) {
+	t.Helper()
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	tempDir := t.TempDir()
+	backupRoot = filepath.Join(tempDir, "backup")
+	searchRoot = filepath.Join(tempDir, "src")
+	initRepoWithOrigin(t, filepath.Join(searchRoot, "old"), "https://example.com/app.git")
+	writeTestFile(t, backupRoot, "old/.env", "SECRET=1")
+	observeRun(t, backupRoot, searchRoot, true, filepath.Join(searchRoot, "old"))
+
+	if err := os.Rename(filepath.Join(searchRoot, "old"), filepath.Join(searchRoot, "new")); err != nil {
+		t.Fatalf("移动仓库失败: %v", err)
+	}
+	return backupRoot, searchRoot
+}
+
+func TestMigrator_MovedRepoRenamed(t *testing.T) {
+	backupRoot, searchRoot := setupMovedRepo(t)
+
+	out := observeRun(t, backupRoot, searchRoot, true, filepath.Join(searchRoot, "new"))
+	if data, err := os.ReadFile(filepath.Join(backupRoot, "new", ".env")); err != nil || string(data) != "SECRET=1" {
+		t.Errorf("备份应迁移到新位置，实际 %q, %v\n%s", data, err, out)
+	}
+	if _, err := os.Stat(filepath.Join(backupRoot, "old")); !os.IsNotExist(err) {
+		t.Error("迁移后旧备份路径不应存在")
+	}
+	var records map[string]struct {
+		RepoRoot string `json:"repo_root"`
+	}
+	data, _ := os.ReadFile(filepath.Join(backupRoot, ".copy-ignore-identities.json"))
+	if err := json.Unmarshal(data, &records); err != nil || records["remote:https://example.com/app.git"].RepoRoot != filepath.Join(searchRoot, "new") {
+		t.Errorf("身份记录应更新为新位置: %s, %v", data, err)
+	}
+}
+
+func TestMigrator_OldPathStillExists(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	tempDir := t.TempDir()
+	backupRoot := filepath.Join(tempDir, "backup")
+	searchRoot := filepath.Join(tempDir, "src")
+	initRepoWithOrigin(t, filepath.Join(searchRoot, "old"), "https://example.com/app.git")
+	writeTestFile(t, backupRoot, "old/.env", "SECRET=1")
+	observeRun(t, backupRoot, searchRoot, true, filepath.Join(searchRoot, "old"))
+
+	// 同一远程的另一个克隆，原仓库仍在
+	initRepoWithOrigin(t, filepath.Join(searchRoot, "new"), "https://example.com/app.git")
+	observeRun(t, backupRoot, searchRoot, true, filepath.Join(searchRoot, "old"), filepath.Join(searchRoot, "new"))
+	if _, err := os.Stat(filepath.Join(backupRoot, "old", ".env")); err != nil {
+		t.Error("原路径仍存在时不应迁移备份")
+	}
+	if _, err := os.Stat(filepath.Join(backupRoot, "new")); !os.IsNotExist(err) {
+		t.Error("原路径仍存在时不应创建新备份路径")
+	}
+}
+
+func TestMigrator_NewPathExistsSkipped(t *testing.T) {
+	backupRoot, searchRoot := setupMovedRepo(t)
+	writeTestFile(t, backupRoot, "new/.env", "OTHER=1")
+
+	out := observeRun(t, backupRoot, searchRoot, true, filepath.Join(searchRoot, "new"))
+	if !strings.Contains(out, "新备份路径已存在") {
+		t.Errorf("应提示跳过迁移:\n%s", out)
+	}
+	if data, _ := os.ReadFile(filepath.Join(backupRoot, "new", ".env")); string(data) != "OTHER=1" {
+		t.Errorf("已存在的新备份不应被覆盖，实际 %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(backupRoot, "old", ".env")); err != nil {
+		t.Error("跳过迁移时旧备份应保留")
+	}
+}
+
+func TestMigrator_PromptOnly(t *testing.T) {
+	backupRoot, searchRoot := setupMovedRepo(t)
+
+	out := observeRun(t, backupRoot, searchRoot, false, filepath.Join(searchRoot, "new"))
+	if !strings.Contains(out, "--migrate-moved") {
+		t.Errorf("未指定 --migrate-moved 时应提示:\n%s", out)
+	}
+	if _, err := os.Stat(filepath.Join(backupRoot, "old", ".env")); err != nil {
+		t.Error("未指定 --migrate-moved 时不应迁移备份")
+	}
+	if _, err := os.Stat(filepath.Join(backupRoot, "new")); !os.IsNotExist(err) {
+		t.Error("未指定 --migrate-moved 时不应创建新备份路径")
+	}
+
+	// 保留旧记录，之后指定 --migrate-moved 仍可迁移
+	observeRun(t, backupRoot, searchRoot, true, filepath.Join(searchRoot, "new"))
+	if _, err := os.Stat(filepath.Join(backupRoot, "new", ".env")); err != nil {
+		t.Error("之后指定 --migrate-moved 的运行应迁移备份")
+	}
+}
+
+func TestMigrator_ClonesNotMigrated(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	// 两种发现顺序：身份记录指向被移动的克隆 a，或指向仍在原处的克隆 b
+	for _, order := range [][]string{{"a", "b"}, {"b", "a"}} {
+		t.Run(strings.Join(order, ""), func(t *testing.T) {
+			tempDir := t.TempDir()
+			backupRoot := filepath.Join(tempDir, "backup")
+			searchRoot := filepath.Join(tempDir, "src")
+			var repos []string
+			for _, name := range order {
+				initRepoWithOrigin(t, filepath.Join(searchRoot, name), "https://example.com/app.git")
+				writeTestFile(t, backupRoot, name+"/.env", name)
+				repos = append(repos, filepath.Join(searchRoot, name))
+			}
+			observeRun(t, backupRoot, searchRoot, true, repos...)
+
+			// 克隆 a 被移动到 c，另一个克隆 b 仍在：无法判断哪一个是被移动的
+			if err := os.Rename(filepath.Join(searchRoot, "a"), filepath.Join(searchRoot, "c")); err != nil {
+				t.Fatalf("移动仓库失败: %v", err)
+			}
+			out := observeRun(t, backupRoot, searchRoot, true, filepath.Join(searchRoot, "c"), filepath.Join(searchRoot, "b"))
+			if _, err := os.Stat(filepath.Join(backupRoot, "c")); !os.IsNotExist(err) {
+				t.Errorf("多个仓库具有相同身份时不应迁移备份:\n%s", out)
+			}
+			for _, name := range []string{"a", "b"} {
+				if _, err := os.Stat(filepath.Join(backupRoot, name, ".env")); err != nil {
+					t.Errorf("备份 %s 应保留", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+func TestResolvePath_Symlink(t *testing.T) {
+	root := t.TempDir()
+	real := filepath.Join(root, "real")
+	if err := os.MkdirAll(real, 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(root, "link")
+	if err := os.Symlink(real, link); err != nil {
+		t.Skipf("无法创建符号链接: %v", err)
+	}
+
+	// 不存在的子路径经由符号链接解析后仍位于真实目录之下
+	resolved := helpers.ResolvePath(filepath.Join(link, "backup", "new"))
+	realResolved := helpers.ResolvePath(real)
+	if !helpers.IsWithin(resolved, realResolved) {
+		t.Errorf("%s 应位于 %s 之下", resolved, realResolved)
+	}
+	if helpers.IsWithin(helpers.ResolvePath(root+"-other"), helpers.ResolvePath(root)) {
+		t.Errorf("前缀相同的兄弟目录不应被视为子目录")
+	}
+}
@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+func TestPauseGate(t *testing.T) {
+	gate := helpers.NewPauseGate()
+	if !gate.Pause() || gate.Pause() {
+		t.Fatalf("第一次暂停应改变状态，重复暂停不应改变")
+	}
+
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(gate.Reader(bytes.NewReader([]byte("hello"))))
+		done <- data
+	}()
+
+	select {
+	case <-done:
+		t.Fatalf("暂停期间读取不应完成")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	gate.Resume()
+	select {
+	case data := <-done:
+		if string(data) != "hello" {
+			t.Errorf("继续后读取的内容不正确: %q", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("继续后读取应完成")
+	}
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+func TestIsCloudPlaceholder_RegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("本地文件"), 0644); err != nil {
+		t.Fatalf("写入文件失败: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("获取文件信息失败: %v", err)
+	}
+	if helpers.IsCloudPlaceholder(info) {
+		t.Error("本地普通文件不应被识别为云端占位文件")
+	}
+}
+
+func TestPlaceholderStub_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src", "big.bin")
+	dest := filepath.Join(dir, "dest", "sub", "big.bin")
+	if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	if err := os.WriteFile(src, make([]byte, 1234), 0644); err != nil {
+		t.Fatalf("写入文件失败: %v", err)
+	}
+	mtime := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatalf("设置修改时间失败: %v", err)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		t.Fatalf("获取文件信息失败: %v", err)
+	}
+
+	if err := helpers.WritePlaceholderStub(dest, info); err != nil {
+		t.Fatalf("写入占位文件元数据失败: %v", err)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Error("只记录元数据时不应创建目标文件本身")
+	}
+	stub := dest + config.PlaceholderStubSuffix
+	if !helpers.IsPlaceholderStub(filepath.Base(stub)) {
+		t.Errorf("%s 应被识别为占位文件元数据记录", stub)
+	}
+	stubInfo, err := os.Stat(stub)
+	if err != nil {
+		t.Fatalf("元数据记录不存在: %v", err)
+	}
+	if !stubInfo.ModTime().Equal(mtime) {
+		t.Errorf("元数据记录的修改时间应与源文件一致，实际 %v", stubInfo.ModTime())
+	}
+
+	got, err := helpers.ReadPlaceholderStub(dest)
+	if err != nil {
+		t.Fatalf("读取占位文件元数据失败: %v", err)
+	}
+	if got.Size != 1234 || !got.ModTime.Equal(mtime) {
+		t.Errorf("元数据不正确: %+v", got)
+	}
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+func TestScanProgress_Events(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	root := t.TempDir()
+	for _, repo := range []string{filepath.Join(root, "a"), filepath.Join(root, "group", "b")} {
+		if err := os.MkdirAll(repo, 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+		initGitRepo(t, repo)
+	}
+
+	excluder, err := exclude.NewMatcher([]string{})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	var events []scanner.ScanProgress
+	if _, err := scanner.ScanIgnoredFilesWithProgress(root, excluder, func(p scanner.ScanProgress) {
+		events = append(events, p)
+	}); err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+
+	if len(events) < 2 {
+		t.Fatalf("应至少收到访问目录和遍历结束两个事件，实际 %d 个", len(events))
+	}
+	if events[0].Dir != root || events[0].DirsVisited != 1 {
+		t.Errorf("第一个事件应为访问搜索根目录: %+v", events[0])
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].DirsVisited < events[i-1].DirsVisited || events[i].ReposFound < events[i-1].ReposFound {
+			t.Errorf("计数不应减少: %+v -> %+v", events[i-1], events[i])
+		}
+	}
+	last := events[len(events)-1]
+	// 访问的目录: root、a、group、group/b
+	if !last.Done || last.ReposFound != 2 || last.DirsVisited != 4 {
+		t.Errorf("遍历结束事件不正确: %+v", last)
+	}
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+func TestScanIgnoredFiles_RecheckDirs(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	repo := t.TempDir()
+	initGitRepo(t, repo)
+	createGitignore(t, repo, "build/\n")
+	if err := os.MkdirAll(filepath.Join(repo, "build", "sub"), 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	createIgnoredFile(t, repo, filepath.Join("build", "a.out"), "构建产物")
+	createIgnoredFile(t, repo, filepath.Join("build", "sub", "b.out"), "构建产物")
+
+	scan := func() map[string]bool {
+		excluder, err := exclude.NewMatcher([]string{})
+		if err != nil {
+			t.Fatalf("创建排除匹配器失败: %v", err)
+		}
+		fileChan := make(chan scanner.IgnoredFileInfo, 10)
+		if err := scanner.ScanIgnoredFilesWithProgressStreamConcurrent(repo, excluder, nil, fileChan, 1); err != nil {
+			t.Fatalf("扫描失败: %v", err)
+		}
+		close(fileChan)
+		found := make(map[string]bool)
+		for file := range fileChan {
+			found[file.RelativePath] = true
+		}
+		return found
+	}
+
+	// 默认整体复制被忽略的目录
+	if found := scan(); len(found) != 1 || !found["build"] {
+		t.Errorf("默认应只列出整体复制的目录 build，实际 %v", found)
+	}
+
+	// --recheck-dirs：目录中的内容按 git 的判断逐个列出
+	config.InitGlobalConfig(&config.Config{RecheckDirs: true})
+	defer config.InitGlobalConfig(nil)
+	found := scan()
+	for _, rel := range []string{filepath.Join("build", "a.out"), filepath.Join("build", "sub", "b.out")} {
+		if !found[rel] {
+			t.Errorf("应逐个列出 %s，实际 %v", rel, found)
+		}
+	}
+	if found["build"] {
+		t.Errorf("不应整体复制目录 build，实际 %v", found)
+	}
+}
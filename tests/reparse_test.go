@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+// setupLinkedTree 创建包含环路链接和指向外部仓库链接的搜索根目录：
+// root/loop -> root（环路），root/outside -> <外部目录>（其中有一个 Git 仓库）
+func setupLinkedTree(t *testing.T) (root, outsideRepo string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("创建符号链接需要管理员权限，跳过测试")
+	}
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+
+	base := t.TempDir()
+	root = filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+	outsideRepo = filepath.Join(outside, "repo")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	if err := os.MkdirAll(outsideRepo, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	initGitRepo(t, outsideRepo)
+	createGitignore(t, outsideRepo, "*.log\n")
+	createIgnoredFile(t, outsideRepo, "debug.log", "日志内容")
+
+	if err := os.Symlink(root, filepath.Join(root, "loop")); err != nil {
+		t.Fatalf("创建符号链接失败: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "outside")); err != nil {
+		t.Fatalf("创建符号链接失败: %v", err)
+	}
+	return root, outsideRepo
+}
+
+func TestScanIgnoredFiles_SkipsDirLinksByDefault(t *testing.T) {
+	root, _ := setupLinkedTree(t)
+	config.InitGlobalConfig(&config.Config{ReparsePoints: config.ReparseSkip})
+	defer config.InitGlobalConfig(nil)
+
+	excluder, err := exclude.NewMatcher([]string{})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	files, err := scanner.ScanIgnoredFiles(root, excluder)
+	if err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("默认不应跟随目录链接，实际找到 %d 个文件: %v", len(files), files)
+	}
+}
+
+func TestScanIgnoredFiles_FollowDirLinksWithoutLoops(t *testing.T) {
+	root, _ := setupLinkedTree(t)
+	config.InitGlobalConfig(&config.Config{ReparsePoints: config.ReparseFollow})
+	defer config.InitGlobalConfig(nil)
+
+	excluder, err := exclude.NewMatcher([]string{})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	files, err := scanner.ScanIgnoredFiles(root, excluder)
+	if err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+
+	// 外部仓库只经由 outside 链接发现一次，loop 链接指向搜索根目录自身，不会重复扫描
+	want := filepath.Join(root, "outside", "repo")
+	if len(files) != 1 || files[0].RepoRoot != want {
+		t.Fatalf("期望只在 %s 中找到 1 个文件，实际: %v", want, files)
+	}
+	if files[0].RelativePath != filepath.Join("outside", "repo", "debug.log") {
+		t.Errorf("相对路径不正确: %s", files[0].RelativePath)
+	}
+}
+
+func TestResolveDirLink_DetectsCycles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("创建符号链接需要管理员权限，跳过测试")
+	}
+	base := t.TempDir()
+	a := filepath.Join(base, "a")
+	b := filepath.Join(base, "b")
+	for _, dir := range []string{a, b} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+	}
+	// a/up -> base（上级目录，形成环路），a/toB -> b，b/toA -> a（互相指向）
+	if err := os.Symlink(base, filepath.Join(a, "up")); err != nil {
+		t.Fatalf("创建符号链接失败: %v", err)
+	}
+	if err := os.Symlink(b, filepath.Join(a, "toB")); err != nil {
+		t.Fatalf("创建符号链接失败: %v", err)
+	}
+	if err := os.Symlink(a, filepath.Join(b, "toA")); err != nil {
+		t.Fatalf("创建符号链接失败: %v", err)
+	}
+
+	if _, ok := scanner.ResolveDirLink(filepath.Join(a, "up"), nil); ok {
+		t.Error("指向上级目录的链接应被判定为环路")
+	}
+	target, ok := scanner.ResolveDirLink(filepath.Join(a, "toB"), nil)
+	if !ok {
+		t.Fatal("指向同级目录的链接应允许跟随")
+	}
+	realA, err := scanner.RealPath(a)
+	if err != nil {
+		t.Fatalf("解析真实路径失败: %v", err)
+	}
+	if _, ok := scanner.ResolveDirLink(filepath.Join(a, "toB", "toA"), []string{realA, target}); ok {
+		t.Error("从 a 经由 b 再回到 a 的链接应被判定为环路")
+	}
+}
+
+// TestScanIgnoredFiles_FastDiscoveryFallsBack 搜索根目录不是整个驱动器时，--fast-discovery 仍逐个目录查找仓库，结果不变
+func TestScanIgnoredFiles_FastDiscoveryFallsBack(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	root := t.TempDir()
+	repo := filepath.Join(root, "projects", "repo")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	initGitRepo(t, repo)
+	createGitignore(t, repo, "*.log\n")
+	createIgnoredFile(t, repo, "debug.log", "日志内容")
+
+	config.InitGlobalConfig(&config.Config{ReparsePoints: config.ReparseSkip, FastDiscovery: true})
+	defer config.InitGlobalConfig(nil)
+
+	excluder, err := exclude.NewMatcher([]string{})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	files, err := scanner.ScanIgnoredFiles(root, excluder)
+	if err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	if len(files) != 1 || files[0].RepoRoot != repo {
+		t.Fatalf("期望在 %s 中找到 1 个文件，实际: %v", repo, files)
+	}
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+func TestScanIgnoredFiles_MaxFilesPerRepo(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	root := t.TempDir()
+	big := filepath.Join(root, "big")
+	small := filepath.Join(root, "small")
+	for _, repo := range []string{big, small} {
+		if err := os.MkdirAll(repo, 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+		initGitRepo(t, repo)
+		createGitignore(t, repo, "*.log\n")
+	}
+	for i := 0; i < 5; i++ {
+		createIgnoredFile(t, big, fmt.Sprintf("%d.log", i), "日志内容")
+	}
+	createIgnoredFile(t, small, "debug.log", "日志内容")
+
+	config.InitGlobalConfig(&config.Config{MaxFilesPerRepo: 2})
+	defer config.InitGlobalConfig(nil)
+
+	excluder, err := exclude.NewMatcher([]string{})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	fileChan := make(chan scanner.IgnoredFileInfo, 10)
+	if err := scanner.ScanIgnoredFilesWithProgressStreamConcurrent(root, excluder, nil, fileChan, 2); err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	close(fileChan)
+
+	perRepo := make(map[string]int)
+	for file := range fileChan {
+		perRepo[file.RepoRoot]++
+	}
+	if perRepo[big] != 2 {
+		t.Errorf("超过上限的仓库应只处理 2 个条目，实际 %d 个", perRepo[big])
+	}
+	if perRepo[small] != 1 {
+		t.Errorf("其他仓库应照常处理，实际 %d 个", perRepo[small])
+	}
+
+	truncated := scanner.TruncatedRepos()
+	if len(truncated) != 1 || truncated[0] != big {
+		t.Errorf("应只记录 %s 达到上限，实际: %v", big, truncated)
+	}
+}
@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+func TestScanRepos_PerRepoResults(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	root := t.TempDir()
+	want := map[string][]string{
+		filepath.Join(root, "a"): {"a.log", "b.log"},
+		filepath.Join(root, "b"): {"c.log"},
+	}
+	for repo, names := range want {
+		if err := os.MkdirAll(repo, 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+		initGitRepo(t, repo)
+		createGitignore(t, repo, "*.log\n")
+		for _, name := range names {
+			createIgnoredFile(t, repo, name, "日志内容")
+		}
+	}
+
+	excluder, err := exclude.NewMatcher([]string{})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	results, errc := scanner.ScanRepos(context.Background(), root, excluder, nil, 2)
+
+	// 按仓库依次读完，每个仓库的文件只出现在自己的结果中
+	got := make(map[string][]string)
+	for result := range results {
+		for file := range result.Files {
+			if file.RepoRoot != result.RepoRoot {
+				t.Errorf("文件 %s 不属于仓库 %s", file.AbsPath, result.RepoRoot)
+			}
+			got[result.RepoRoot] = append(got[result.RepoRoot], filepath.Base(file.AbsPath))
+		}
+		if result.Err != nil {
+			t.Errorf("仓库 %s 处理出错: %v", result.RepoRoot, result.Err)
+		}
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("期望 %d 个仓库，实际 %v", len(want), got)
+	}
+	for repo, names := range want {
+		files := got[repo]
+		sort.Strings(files)
+		if len(files) != len(names) || files[0] != names[0] {
+			t.Errorf("仓库 %s 的文件不正确: %v", repo, files)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/logics"
+)
+
+func TestRun_DryRunReport(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	root := t.TempDir()
+	repo := filepath.Join(root, "repo")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	initGitRepo(t, repo)
+	createGitignore(t, repo, "*.log\n")
+	createIgnoredFile(t, repo, "debug.log", "日志内容")
+
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	config.InitGlobalConfig(&config.Config{
+		SearchRoot: root,
+		BackupRoot: filepath.Join(t.TempDir(), "backup"),
+		DryRun:     true,
+	})
+	excluder, err := exclude.NewMatcher([]string{})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+
+	report := logics.Run(excluder)
+	if report.Err != nil {
+		t.Fatalf("干运行不应失败: %v", report.Err)
+	}
+	if !report.DryRun || report.Copy != nil {
+		t.Errorf("干运行不应有复制结果: %+v", report.Copy)
+	}
+	if report.Scan.Entries != 1 || report.Scan.Repos != 1 {
+		t.Errorf("应扫描到 1 个仓库中的 1 个文件，实际 %d 个仓库、%d 个文件", report.Scan.Repos, report.Scan.Entries)
+	}
+	if report.Finished.Before(report.Started) {
+		t.Errorf("结束时间不应早于开始时间: %v %v", report.Started, report.Finished)
+	}
+}
+
+func TestRun_FatalErrorReturned(t *testing.T) {
+	dir := t.TempDir()
+	// 备份目标是普通文件，目标检查失败
+	dest := filepath.Join(dir, "dest")
+	if err := os.WriteFile(dest, []byte("x"), 0644); err != nil {
+		t.Fatalf("创建文件失败: %v", err)
+	}
+
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	config.InitGlobalConfig(&config.Config{
+		SearchRoot: dir,
+		BackupRoot: dest,
+		SharedRoot: dest,
+	})
+	excluder, err := exclude.NewMatcher([]string{})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+
+	// 失败时 Run 返回错误而不是退出进程
+	report := logics.Run(excluder)
+	if !errors.Is(report.Err, logics.ErrDestination) {
+		t.Fatalf("应返回备份目标检查失败的错误，实际 %v", report.Err)
+	}
+	if errors.Is(report.Err, logics.ErrScan) || errors.Is(report.Err, logics.ErrCopy) {
+		t.Errorf("目标检查失败不应被归为扫描或复制失败: %v", report.Err)
+	}
+	if report.Copy != nil {
+		t.Errorf("目标检查失败时不应有复制结果")
+	}
+}
+
+func TestValidateConfig_ErrValidation(t *testing.T) {
+	cfg := &config.Config{
+		SearchRoot: filepath.Join(t.TempDir(), "missing"),
+		BackupRoot: filepath.Join(t.TempDir(), "backup"),
+	}
+	if err := logics.ValidateConfig(cfg); !errors.Is(err, logics.ErrValidation) {
+		t.Errorf("搜索根目录不存在时应返回参数错误，实际 %v", err)
+	}
+}
+
+// makeUnreadableDir 在 base 下创建一串嵌套目录，最深处的完整路径超过系统的路径长度限制，扫描到此处时读取目录失败
+func makeUnreadableDir(t *testing.T, base string) {
+	t.Helper()
+	root, err := os.OpenRoot(base)
+	if err != nil {
+		t.Fatalf("打开目录失败: %v", err)
+	}
+	name := strings.Repeat("d", 200)
+	for depth := len(base); depth <= 4200; depth += len(name) + 1 {
+		if err := root.Mkdir(name, 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+		sub, err := root.OpenRoot(name)
+		root.Close()
+		if err != nil {
+			t.Fatalf("打开目录失败: %v", err)
+		}
+		root = sub
+	}
+	root.Close()
+}
+
+// snapshotTree 返回目录下所有文件的相对路径和大小
+func snapshotTree(t *testing.T, root string) map[string]int64 {
+	t.Helper()
+	files := make(map[string]int64)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		files[rel] = info.Size()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("遍历目录失败: %v", err)
+	}
+	return files
+}
+
+// TestRunCopy_ScanErrorWaitsForCopy 扫描失败时等正在复制的文件结束后才返回，不执行清理阶段
+func TestRunCopy_ScanErrorWaitsForCopy(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("依赖 Unix 的路径长度限制制造扫描错误")
+	}
+	searchRoot := t.TempDir()
+	repo := filepath.Join(searchRoot, "a")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	initGitRepo(t, repo)
+	createGitignore(t, repo, "*.bin\n*.log\n")
+	large := strings.Repeat("x", 512<<10)
+	for _, name := range []string{"1.bin", "2.bin", "3.bin"} {
+		createIgnoredFile(t, repo, name, large)
+	}
+	// 读取失败的目录排在大量普通目录之后，扫描失败时仓库 a 的文件已经开始复制
+	for i := 0; i < 5000; i++ {
+		if err := os.Mkdir(filepath.Join(searchRoot, fmt.Sprintf("e%04d", i)), 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+	}
+	makeUnreadableDir(t, searchRoot)
+
+	// 源文件已删除的备份：扫描不完整，不能被移入历史目录
+	dest := filepath.Join(t.TempDir(), "backup")
+	writeTestFile(t, dest, "a/stale.log", "旧备份")
+
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	// 限速使复制在扫描失败时仍在进行
+	code := logics.RunCopy([]string{"--init-dest", "--bwlimit", "1M", "--concurrency", "3", searchRoot, dest})
+	if code == 0 {
+		t.Fatal("扫描失败时应返回非 0 退出码")
+	}
+
+	before := snapshotTree(t, dest)
+	time.Sleep(500 * time.Millisecond)
+	after := snapshotTree(t, dest)
+	for rel, size := range after {
+		if strings.HasSuffix(rel, ".tmp") {
+			t.Errorf("返回后不应留下临时文件: %s", rel)
+		}
+		if before[rel] != size {
+			t.Errorf("返回后备份目标仍在写入: %s %d -> %d", rel, before[rel], size)
+		}
+		if strings.HasSuffix(rel, ".bin") && size != int64(len(large)) {
+			t.Errorf("已开始复制的文件应完整复制: %s %d", rel, size)
+		}
+	}
+	if len(after) != len(before) {
+		t.Errorf("返回后备份目标仍有变化: %v -> %v", before, after)
+	}
+	if after[filepath.Join("a", "stale.log")] == 0 {
+		t.Errorf("扫描失败时不应清理源文件已删除的备份: %v", after)
+	}
+}
@@ -0,0 +1,200 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/logics"
+)
+
+func TestRestore_Selective(t *testing.T) {
+	tempDir := t.TempDir()
+	searchRoot := filepath.Join(tempDir, "src")
+	backupRoot := filepath.Join(tempDir, "backup")
+	for _, repo := range []string{"web", "api"} {
+		if err := os.MkdirAll(filepath.Join(searchRoot, repo, ".git"), 0755); err != nil {
+			t.Fatalf("创建仓库失败: %v", err)
+		}
+		writeTestFile(t, backupRoot, repo+"/.env", repo+" env")
+		writeTestFile(t, backupRoot, repo+"/config/local.env", repo+" local")
+		writeTestFile(t, backupRoot, repo+"/debug.log", repo+" log")
+	}
+	// 历史目录中的旧版本不参与还原
+	writeTestFile(t, backupRoot, "copy-ignore备份/20260101-000000/web/old.env", "old")
+
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	code := logics.RunRestore([]string{"--repo", "web", "--include", "*.env", "--exclude", "config", searchRoot, backupRoot})
+	if code != 0 {
+		t.Fatalf("还原应成功，退出码 %d", code)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(searchRoot, "web", ".env")); err != nil || string(data) != "web env" {
+		t.Errorf("应还原所选仓库中匹配的文件: %q %v", data, err)
+	}
+	for _, path := range []string{
+		filepath.Join(searchRoot, "web", "debug.log"),           // 不匹配 --include
+		filepath.Join(searchRoot, "web", "config", "local.env"), // 匹配 --exclude
+		filepath.Join(searchRoot, "api", ".env"),                // 不是所选的仓库
+		filepath.Join(searchRoot, "web", "old.env"),             // 历史版本
+	} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("不应还原 %s: %v", path, err)
+		}
+	}
+}
+
+func TestRestore_RestoreTo(t *testing.T) {
+	tempDir := t.TempDir()
+	searchRoot := filepath.Join(tempDir, "src")
+	backupRoot := filepath.Join(tempDir, "backup")
+	restoreTo := filepath.Join(tempDir, "inspect")
+	if err := os.MkdirAll(filepath.Join(searchRoot, "web", ".git"), 0755); err != nil {
+		t.Fatalf("创建仓库失败: %v", err)
+	}
+	writeTestFile(t, searchRoot, "web/.env", "live")
+	writeTestFile(t, backupRoot, "web/.env", "backup")
+	writeTestFile(t, backupRoot, "web/config/local.env", "local")
+
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	if code := logics.RunRestore([]string{"--restore-to", restoreTo, searchRoot, backupRoot}); code != 0 {
+		t.Fatalf("还原应成功，退出码 %d", code)
+	}
+
+	// 替代目录中保持相对于搜索根目录的结构
+	for rel, want := range map[string]string{"web/.env": "backup", "web/config/local.env": "local"} {
+		if data, err := os.ReadFile(filepath.Join(restoreTo, filepath.FromSlash(rel))); err != nil || string(data) != want {
+			t.Errorf("%s 应还原到替代目录: %q %v", rel, data, err)
+		}
+	}
+	// 原来的仓库不做修改
+	if data, _ := os.ReadFile(filepath.Join(searchRoot, "web", ".env")); string(data) != "live" {
+		t.Errorf("--restore-to 不应修改工作区中的文件: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(searchRoot, "web", "config")); !os.IsNotExist(err) {
+		t.Errorf("--restore-to 不应在工作区中创建文件: %v", err)
+	}
+
+	if code := logics.RunRestore([]string{"--restore-to", filepath.Join(backupRoot, "x"), searchRoot, backupRoot}); code != 2 {
+		t.Errorf("--restore-to 位于备份根目录中应报参数错误，退出码 %d", code)
+	}
+}
+
+func TestRestore_ConflictProtection(t *testing.T) {
+	tempDir := t.TempDir()
+	searchRoot := filepath.Join(tempDir, "src")
+	backupRoot := filepath.Join(tempDir, "backup")
+	if err := os.MkdirAll(filepath.Join(searchRoot, "web", ".git"), 0755); err != nil {
+		t.Fatalf("创建仓库失败: %v", err)
+	}
+	writeTestFile(t, backupRoot, "web/.env", "backup")
+	writeTestFile(t, backupRoot, "web/old.env", "backup")
+	writeTestFile(t, searchRoot, "web/.env", "edited")
+	writeTestFile(t, searchRoot, "web/old.env", "stale")
+	// 工作区中的 .env 比备份新，old.env 比备份旧
+	now := time.Now()
+	os.Chtimes(filepath.Join(backupRoot, "web", ".env"), now.Add(-time.Hour), now.Add(-time.Hour))
+	os.Chtimes(filepath.Join(searchRoot, "web", "old.env"), now.Add(-2*time.Hour), now.Add(-2*time.Hour))
+
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	if code := logics.RunRestore([]string{searchRoot, backupRoot}); code != 1 {
+		t.Errorf("有冲突时退出码应为 1，实际 %d", code)
+	}
+	if data, _ := os.ReadFile(filepath.Join(searchRoot, "web", ".env")); string(data) != "edited" {
+		t.Errorf("不应覆盖比备份新的文件: %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(searchRoot, "web", "old.env")); string(data) != "backup" {
+		t.Errorf("应覆盖比备份旧的文件: %q", data)
+	}
+
+	if code := logics.RunRestore([]string{"--force", searchRoot, backupRoot}); code != 0 {
+		t.Errorf("--force 时应成功，退出码 %d", code)
+	}
+	if data, _ := os.ReadFile(filepath.Join(searchRoot, "web", ".env")); string(data) != "backup" {
+		t.Errorf("--force 时应覆盖比备份新的文件: %q", data)
+	}
+}
+
+func TestRestore_At(t *testing.T) {
+	tempDir := t.TempDir()
+	searchRoot := filepath.Join(tempDir, "src")
+	backupRoot := filepath.Join(tempDir, "backup")
+	restoreTo := filepath.Join(tempDir, "inspect")
+	if err := os.MkdirAll(filepath.Join(searchRoot, "web", ".git"), 0755); err != nil {
+		t.Fatalf("创建仓库失败: %v", err)
+	}
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 12, 0, 0, 0, time.Local) }
+	write := func(rel, content string, mtime time.Time) {
+		writeTestFile(t, backupRoot, rel, content)
+		os.Chtimes(filepath.Join(backupRoot, filepath.FromSlash(rel)), mtime, mtime)
+	}
+	// .env 在 3 日的运行中被替换，5 日被再次替换；old.env 在 4 日被删除；new.env 在 5 日中午才创建
+	write("copy-ignore备份/20260103-000000/web/.env", "v1", day(1))
+	write("copy-ignore备份/20260105-000000/web/.env", "v2", day(3))
+	write("web/.env", "v3", day(4))
+	write("copy-ignore备份/20260104-000000/web/old.env", "old", day(1))
+	write("web/new.env", "new", day(5))
+
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	for _, tc := range []struct {
+		at   string
+		want map[string]string
+	}{
+		{"2026-01-02", map[string]string{".env": "v1", "old.env": "old"}},
+		{"20260105-000000", map[string]string{".env": "v2"}},
+		{"2026-01-06 00:00", map[string]string{".env": "v3", "new.env": "new"}},
+	} {
+		os.RemoveAll(restoreTo)
+		if code := logics.RunRestore([]string{"--at", tc.at, "--restore-to", restoreTo, searchRoot, backupRoot}); code != 0 {
+			t.Fatalf("--at %s 还原应成功，退出码 %d", tc.at, code)
+		}
+		for _, name := range []string{".env", "old.env", "new.env"} {
+			data, err := os.ReadFile(filepath.Join(restoreTo, "web", name))
+			if want, ok := tc.want[name]; ok != (err == nil) || string(data) != want {
+				t.Errorf("--at %s: %s 为 %q（%v），期望 %q", tc.at, name, data, err, want)
+			}
+		}
+	}
+
+	if code := logics.RunRestore([]string{"--at", "yesterday", searchRoot, backupRoot}); code != 2 {
+		t.Errorf("无法解析的 --at 应报参数错误，退出码 %d", code)
+	}
+}
+
+func TestRestore_ConflictAsk(t *testing.T) {
+	tempDir := t.TempDir()
+	searchRoot := filepath.Join(tempDir, "src")
+	backupRoot := filepath.Join(tempDir, "backup")
+	if err := os.MkdirAll(filepath.Join(searchRoot, "web", ".git"), 0755); err != nil {
+		t.Fatalf("创建仓库失败: %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	for _, name := range []string{"a.env", "b.env", "c.env"} {
+		writeTestFile(t, backupRoot, "web/"+name, "backup")
+		os.Chtimes(filepath.Join(backupRoot, "web", name), old, old)
+		writeTestFile(t, searchRoot, "web/"+name, "edited")
+	}
+
+	// 按文件顺序回答：a.env 覆盖，b.env 保留，c.env 读不到回答按 skip 处理
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("创建管道失败: %v", err)
+	}
+	w.WriteString("y\nn\n")
+	w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	if code := logics.RunRestore([]string{"--conflict", "ask", searchRoot, backupRoot}); code != 1 {
+		t.Errorf("有未处理的冲突时退出码应为 1，实际 %d", code)
+	}
+	for name, want := range map[string]string{"a.env": "backup", "b.env": "edited", "c.env": "edited"} {
+		if data, _ := os.ReadFile(filepath.Join(searchRoot, "web", name)); string(data) != want {
+			t.Errorf("%s 为 %q，期望 %q", name, data, want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/layout"
+	"github.com/aogg/copy-ignore/src/manifest"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+func TestSanitizePath(t *testing.T) {
+	cases := []struct {
+		name    string
+		changed bool
+	}{
+		{"a.env", false},
+		{"中文.txt", false},
+		{"..", false},
+		{"time 12:30.log", true},
+		{"what?.txt", true},
+		{"star*", true},
+		{"trailing.", true},
+		{"trailing ", true},
+		{"CON", true},
+		{"con.txt", true},
+		{"Console", false},
+		{"LPT1.log", true},
+	}
+	for _, c := range cases {
+		got := helpers.SanitizePath(c.name)
+		if (got != c.name) != c.changed {
+			t.Errorf("SanitizePath(%q) = %q，是否转义不符合预期", c.name, got)
+		}
+		if strings.ContainsAny(got, `<>:"|?*`) || strings.HasSuffix(got, ".") && got != ".." || strings.HasSuffix(got, " ") {
+			t.Errorf("SanitizePath(%q) = %q 仍含有不兼容的字符", c.name, got)
+		}
+		if back := helpers.UnsanitizePath(got); back != c.name {
+			t.Errorf("UnsanitizePath(%q) = %q，期望还原为 %q", got, back, c.name)
+		}
+	}
+}
+
+func TestLayoutSanitizeNames(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 上无法创建含 \":\" 的源文件")
+	}
+	tempDir := t.TempDir()
+	searchRoot := filepath.Join(tempDir, "src")
+	backupRoot := filepath.Join(tempDir, "backup")
+	writeTestFile(t, searchRoot, "repo/logs/12:30.log", "log")
+
+	m, _ := layout.Load(backupRoot, layout.LayoutPath)
+	m.SanitizeNames()
+	file := scanner.IgnoredFileInfo{
+		AbsPath:      filepath.Join(searchRoot, "repo/logs/12:30.log"),
+		RelativePath: filepath.Join("repo", "logs", "12:30.log"),
+		RepoRoot:     filepath.Join(searchRoot, "repo"),
+	}
+	rel := m.Resolve(file)
+	if strings.Contains(rel, ":") {
+		t.Fatalf("备份路径应转义 \":\"，实际 %q", rel)
+	}
+	if src := m.Source(rel, searchRoot); src != file.AbsPath {
+		t.Errorf("反推的源文件路径应还原为 %q，实际 %q", file.AbsPath, src)
+	}
+
+	// 清单记录原始路径
+	writeTestFile(t, backupRoot, rel, "log")
+	built, err := manifest.Build(backupRoot, nil, nil, "")
+	if err != nil {
+		t.Fatalf("生成清单失败: %v", err)
+	}
+	key := filepath.ToSlash(rel)
+	if got := built.OriginalPath(key); got != "repo/logs/12:30.log" {
+		t.Errorf("清单应记录原始路径，实际 %q", got)
+	}
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/logics"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+func TestRunConfigLint_SearchRootGlob(t *testing.T) {
+	base := t.TempDir()
+	for _, dir := range []string{"a/projects", "b/projects"} {
+		if err := os.MkdirAll(filepath.Join(base, "work", dir), 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+	}
+	dest := filepath.Join(base, "dest")
+
+	if code := logics.RunConfig([]string{"lint", filepath.Join(base, "work", "*", "projects"), dest}); code != 0 {
+		t.Errorf("匹配到目录的搜索根目录模式应检查通过，退出码 %d", code)
+	}
+	if code := logics.RunConfig([]string{"lint", filepath.Join(base, "work", "*", "missing"), dest}); code != 1 {
+		t.Errorf("没有匹配到任何目录的模式应报告问题，退出码 %d", code)
+	}
+	// 备份根目录位于共同的上级目录之内、但不在任何实际扫描的目录之内
+	if code := logics.RunConfig([]string{"lint", filepath.Join(base, "work", "*", "projects"), filepath.Join(base, "work", "backup")}); code != 0 {
+		t.Errorf("备份根目录不在实际扫描的目录之内时不应报告包含关系，退出码 %d", code)
+	}
+}
+
+func TestScanIgnoredFiles_MultipleRoots(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	root := t.TempDir()
+	var repos []string
+	for _, dir := range []string{"a/projects/app", "b/projects/lib", "c/other/tool"} {
+		repo := filepath.Join(root, dir)
+		if err := os.MkdirAll(repo, 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+		initGitRepo(t, repo)
+		createGitignore(t, repo, "*.log\n")
+		createIgnoredFile(t, repo, "debug.log", "日志内容")
+		repos = append(repos, repo)
+	}
+
+	config.InitGlobalConfig(&config.Config{
+		SearchRoot: root,
+		ScanRoots:  []string{filepath.Join(root, "a", "projects"), filepath.Join(root, "b", "projects")},
+	})
+	defer config.InitGlobalConfig(nil)
+
+	excluder, err := exclude.NewMatcher([]string{})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	fileChan := make(chan scanner.IgnoredFileInfo, 10)
+	if err := scanner.ScanIgnoredFilesWithProgressStreamConcurrent(root, excluder, nil, fileChan, 2); err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	close(fileChan)
+
+	found := make(map[string]string)
+	for file := range fileChan {
+		found[file.RepoRoot] = file.RelativePath
+	}
+	if len(found) != 2 || found[repos[0]] == "" || found[repos[1]] == "" {
+		t.Fatalf("应只扫描展开得到的两个目录，实际: %v", found)
+	}
+	// 相对路径按共同的上级目录计算，不同搜索根目录下的仓库不会互相覆盖
+	if want := filepath.Join("a", "projects", "app", "debug.log"); found[repos[0]] != want {
+		t.Errorf("相对路径应为 %s，实际 %s", want, found[repos[0]])
+	}
+}
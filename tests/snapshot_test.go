@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/snapshot"
+)
+
+func TestSnapshotValidate(t *testing.T) {
+	if err := snapshot.Validate("btrfs", []string{"dir=/mnt/.snapshots"}); err != nil {
+		t.Errorf("有效的选项不应报错: %v", err)
+	}
+	for _, tc := range []struct {
+		preset string
+		opts   []string
+	}{
+		{"lvm", nil},
+		{"zfs", []string{"volume=tank"}},
+		{"http", nil},
+		{"command", []string{"cmd"}},
+	} {
+		if err := snapshot.Validate(tc.preset, tc.opts); err == nil {
+			t.Errorf("%s %v 应报错", tc.preset, tc.opts)
+		}
+	}
+}
+
+func TestSnapshotTargetName(t *testing.T) {
+	target := snapshot.Target{Timestamp: "2026-10-16T08:30:00+08:00"}
+	if got, want := target.Name(), "copy-ignore-2026-10-16T08-30-00-08-00"; got != want {
+		t.Errorf("快照名称为 %s，期望 %s", got, want)
+	}
+}
+
+func TestSnapshotTake_Command(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("命令使用 sh 语法")
+	}
+	dir := t.TempDir()
+	target := snapshot.Target{Root: dir, Shared: dir, Timestamp: "20261016-083000", Host: "pc1"}
+	id, err := snapshot.Take("command", []string{`cmd=echo creating; echo "$COPY_IGNORE_HOST/$COPY_IGNORE_SNAPSHOT_NAME"`}, target)
+	if err != nil {
+		t.Fatalf("执行快照命令失败: %v", err)
+	}
+	if want := "pc1/copy-ignore-20261016-083000"; id != want {
+		t.Errorf("快照标识为 %q，期望输出的最后一行 %q", id, want)
+	}
+
+	if _, err := snapshot.Take("command", []string{"cmd=echo no space >&2; exit 3"}, target); err == nil || !strings.Contains(err.Error(), "no space") {
+		t.Errorf("命令失败时应返回带输出的错误，实际 %v", err)
+	}
+}
+
+func TestSnapshotTake_HTTP(t *testing.T) {
+	var gotMethod, gotName string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotName = r.Method, r.URL.Query().Get("name")
+		if r.URL.Path == "/fail" {
+			http.Error(w, "volume busy", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "snap-42")
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	target := snapshot.Target{Root: dir, Shared: dir, Timestamp: "20261016-083000"}
+	id, err := snapshot.Take("http", []string{"url=" + server.URL + "/snap?name={name}"}, target)
+	if err != nil {
+		t.Fatalf("请求快照接口失败: %v", err)
+	}
+	if id != "snap-42" || gotMethod != http.MethodPost || gotName != target.Name() {
+		t.Errorf("快照标识 %q、方法 %s、名称 %q 不符合预期", id, gotMethod, gotName)
+	}
+
+	if _, err := snapshot.Take("http", []string{"url=" + server.URL + "/fail", "method=put"}, target); err == nil || gotMethod != http.MethodPut {
+		t.Errorf("接口返回 503 时应报错，实际 %v（方法 %s）", err, gotMethod)
+	}
+}
+
+func TestSnapshotTake_Synology(t *testing.T) {
+	var calls []string
+	var snapName, snapInfo string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		calls = append(calls, r.URL.Path+" "+r.Form.Get("method"))
+		switch {
+		case r.Form.Get("method") == "login":
+			if r.Form.Get("account") != "backup" || r.Form.Get("passwd") != "secret" {
+				fmt.Fprint(w, `{"error":{"code":400},"success":false}`)
+				return
+			}
+			fmt.Fprint(w, `{"data":{"sid":"abc"},"success":true}`)
+		case r.Form.Get("_sid") != "abc":
+			fmt.Fprint(w, `{"error":{"code":119},"success":false}`)
+		case r.Form.Get("method") == "create":
+			snapName, snapInfo = r.Form.Get("name"), r.Form.Get("snapinfo")
+			fmt.Fprint(w, `{"data":"GMT+08-2026.10.16-08.30.00","success":true}`)
+		default:
+			fmt.Fprint(w, `{"success":true}`)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	target := snapshot.Target{Root: dir, Shared: dir, Timestamp: "20261016-083000"}
+	opts := []string{"url=" + server.URL, "user=backup", "share=projects", "password-env=TEST_NAS_PASSWORD"}
+	t.Setenv("TEST_NAS_PASSWORD", "secret")
+	id, err := snapshot.Take("synology", opts, target)
+	if err != nil {
+		t.Fatalf("创建群晖快照失败: %v", err)
+	}
+	if id != "GMT+08-2026.10.16-08.30.00" {
+		t.Errorf("快照标识为 %q", id)
+	}
+	if snapName != `"projects"` || !strings.Contains(snapInfo, `"lock":true`) {
+		t.Errorf("创建快照的参数不符合预期: name=%s snapinfo=%s", snapName, snapInfo)
+	}
+	if want := "/webapi/auth.cgi login|/webapi/entry.cgi create|/webapi/auth.cgi logout"; strings.Join(calls, "|") != want {
+		t.Errorf("调用顺序为 %v，期望 %s", calls, want)
+	}
+
+	t.Setenv("TEST_NAS_PASSWORD", "wrong")
+	if _, err := snapshot.Take("synology", opts, target); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("登录失败时应报错，实际 %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+//go:build unix
+
+package tests
+
+import (
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/copy"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+// TestCopyFiles_AbandonStalled 打开命名管道会一直阻塞（模拟挂起的网络共享），--stall-abort 时放弃该文件并继续复制其余文件
+// 被放弃的读取一直阻塞到测试进程结束，不会再写入备份目录
+func TestCopyFiles_AbandonStalled(t *testing.T) {
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	backupRoot := filepath.Join(tempDir, "backup")
+	writeTestFile(t, srcDir, "ok.txt", "内容")
+	fifo := filepath.Join(srcDir, "stuck.pipe")
+	if err := syscall.Mkfifo(fifo, 0644); err != nil {
+		t.Skipf("无法创建命名管道: %v", err)
+	}
+
+	config.InitGlobalConfig(&config.Config{
+		BackupRoot:   backupRoot,
+		BackupKeep:   3,
+		Concurrency:  2,
+		StallTimeout: time.Second,
+		StallAbort:   true,
+	})
+
+	files := []scanner.IgnoredFileInfo{
+		{AbsPath: fifo, RelativePath: "stuck.pipe", RepoRoot: srcDir},
+		{AbsPath: filepath.Join(srcDir, "ok.txt"), RelativePath: "ok.txt", RepoRoot: srcDir},
+	}
+	done := make(chan *copy.CopyResult)
+	go func() {
+		result, err := copy.CopyFiles(files, backupRoot, 2, false, nil)
+		if err != nil {
+			t.Errorf("复制失败: %v", err)
+		}
+		done <- result
+	}()
+
+	// 等待卡住的任务出现，放弃后复制应结束
+	var abandoned []copy.ActiveJob
+	for deadline := time.Now().Add(5 * time.Second); len(abandoned) == 0 && time.Now().Before(deadline); {
+		time.Sleep(50 * time.Millisecond)
+		abandoned = copy.AbandonStalled(100 * time.Millisecond)
+	}
+	if len(abandoned) != 1 || abandoned[0].SrcPath != fifo {
+		t.Fatalf("应放弃卡住的命名管道，实际 %+v", abandoned)
+	}
+
+	var result *copy.CopyResult
+	select {
+	case result = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("放弃卡住的文件后复制没有结束")
+	}
+	if result.Copied != 1 || result.Errors != 1 {
+		t.Errorf("应复制 1 个文件、1 个出错，实际 %+v", result)
+	}
+	if len(result.Failures) != 1 || result.Failures[0].Kind != "stalled" {
+		t.Errorf("出错的文件应记为 stalled，实际 %+v", result.Failures)
+	}
+	if len(copy.ActiveJobs()) != 0 {
+		t.Errorf("复制结束后不应有正在执行的任务: %+v", copy.ActiveJobs())
+	}
+}
+
+// TestCopyFiles_FileTimeout 打开命名管道超过 --file-timeout 没有返回时，该文件记为超时出错，其余文件照常复制
+func TestCopyFiles_FileTimeout(t *testing.T) {
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	backupRoot := filepath.Join(tempDir, "backup")
+	writeTestFile(t, srcDir, "ok.txt", "内容")
+	fifo := filepath.Join(srcDir, "stuck.pipe")
+	if err := syscall.Mkfifo(fifo, 0644); err != nil {
+		t.Skipf("无法创建命名管道: %v", err)
+	}
+
+	config.InitGlobalConfig(&config.Config{
+		BackupRoot:  backupRoot,
+		BackupKeep:  3,
+		Concurrency: 2,
+		FileTimeout: 200 * time.Millisecond,
+	})
+
+	files := []scanner.IgnoredFileInfo{
+		{AbsPath: fifo, RelativePath: "stuck.pipe", RepoRoot: srcDir},
+		{AbsPath: filepath.Join(srcDir, "ok.txt"), RelativePath: "ok.txt", RepoRoot: srcDir},
+	}
+	result, err := copy.CopyFiles(files, backupRoot, 2, false, nil)
+	if err != nil {
+		t.Fatalf("复制失败: %v", err)
+	}
+	if result.Copied != 1 || result.Errors != 1 {
+		t.Errorf("应复制 1 个文件、1 个出错，实际 %+v", result)
+	}
+	if len(result.Failures) != 1 || result.Failures[0].Kind != "timeout" {
+		t.Errorf("出错的文件应记为 timeout，实际 %+v", result.Failures)
+	}
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/logics"
+)
+
+func TestStats_Aggregation(t *testing.T) {
+	backupRoot := t.TempDir()
+	day := time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local) // 明细和汇总按本地时间显示日期
+	runs := []logics.RunRecord{
+		{StartedAt: day, Duration: 10, Success: true, Totals: logics.LastRunTotals{Copied: 10, BackupBytes: 1024}},
+		{StartedAt: day.Add(24 * time.Hour), Duration: 20, Totals: logics.LastRunTotals{Copied: 8, Errors: 2, BackupBytes: 2048}},
+		{StartedAt: day.Add(47 * time.Hour), Duration: 30, FatalError: "备份目标空间不足"},
+		{StartedAt: day.Add(48 * time.Hour), Duration: 40, Success: true, Totals: logics.LastRunTotals{Skipped: 10, BackupBytes: 3072}},
+	}
+	var lines []string
+	for i, run := range runs {
+		data, err := json.Marshal(run)
+		if err != nil {
+			t.Fatalf("生成运行历史失败: %v", err)
+		}
+		lines = append(lines, string(data))
+		if i == 1 {
+			lines = append(lines, `{"started_at": "写入中断`) // 无法解析的行跳过
+		}
+	}
+	writeTestFile(t, backupRoot, config.RunHistoryFileName, strings.Join(lines, "\n")+"\n")
+
+	out, code := captureStdout(t, func() int { return logics.RunStats([]string{"--last", "2", backupRoot}) })
+	if code != 0 {
+		t.Fatalf("stats 应成功，退出码 %d", code)
+	}
+	for _, want := range []string{
+		"共 4 次运行（2026-01-01 ~ 2026-01-03）",
+		"失败的运行: 2 次（50.0%）",
+		"出错的文件: 2 个（占处理文件的 6.67%）",
+		"平均耗时: 25s",
+		"备份大小: 1.0 KB -> 3.0 KB（+2.0 KB），平均每天 +1.0 KB",
+		"中止: 备份目标空间不足",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("输出中应包含 %q:\n%s", want, out)
+		}
+	}
+
+	// 明细只列出最近 2 次，增长按之前最近一次有备份大小的运行计算
+	var rows []string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "2026-") {
+			rows = append(rows, line)
+		}
+	}
+	if len(rows) != 2 || !strings.HasSuffix(strings.TrimSpace(rows[1]), "3.0 KB     +1.0 KB") {
+		t.Errorf("--last 2 应列出最近 2 次运行的明细:\n%s", strings.Join(rows, "\n"))
+	}
+}
+
+func TestStats_NoHistory(t *testing.T) {
+	backupRoot := t.TempDir()
+	out, code := captureStdout(t, func() int { return logics.RunStats([]string{backupRoot}) })
+	if code != 0 || !strings.Contains(out, "没有运行历史") {
+		t.Errorf("没有运行历史时应提示，退出码 %d:\n%s", code, out)
+	}
+	if _, err := os.Stat(filepath.Join(backupRoot, config.RunHistoryFileName)); !os.IsNotExist(err) {
+		t.Error("stats 不应创建运行历史")
+	}
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/logics"
+)
+
+func TestPullBackToSource(t *testing.T) {
+	tempDir := t.TempDir()
+	srcDir := filepath.Join(tempDir, "src")
+	backupRoot := filepath.Join(tempDir, "backup")
+	config.InitGlobalConfig(&config.Config{
+		BackupRoot:   backupRoot,
+		BackupSubdir: "history",
+		Timestamp:    "20240101-000000",
+	})
+
+	writeTestFile(t, srcDir, "repo/.env", "LOCAL=1")
+	writeTestFile(t, backupRoot, "repo/.env", "REMOTE=1")
+	backupTime := time.Now().Add(time.Hour).Truncate(time.Second)
+	os.Chtimes(filepath.Join(backupRoot, "repo/.env"), backupTime, backupTime)
+
+	srcFile := filepath.Join(srcDir, "repo/.env")
+	if err := helpers.PullBackToSource(filepath.Join(backupRoot, "repo/.env"), srcFile); err != nil {
+		t.Fatalf("取回失败: %v", err)
+	}
+
+	if data, _ := os.ReadFile(srcFile); string(data) != "REMOTE=1" {
+		t.Errorf("源文件应更新为备份内容，实际 %q", data)
+	}
+	if info, _ := os.Stat(srcFile); !info.ModTime().Equal(backupTime) {
+		t.Errorf("源文件修改时间应与备份一致，实际 %v", info.ModTime())
+	}
+	if data, _ := os.ReadFile(filepath.Join(backupRoot, "history", "20240101-000000", "repo/.env")); string(data) != "LOCAL=1" {
+		t.Errorf("源文件旧版本应保存到历史目录，实际 %q", data)
+	}
+}
+
+// setupSyncRepo 创建一个忽略 .env 和 *.log 的仓库，返回搜索根目录、仓库和备份根目录
+func setupSyncRepo(t *testing.T) (string, string, string) {
+	t.Helper()
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	searchRoot := t.TempDir()
+	repo := filepath.Join(searchRoot, "repo")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	initGitRepo(t, repo)
+	createGitignore(t, repo, ".env\n*.log\n")
+	// 仓库中始终有被忽略的文件，保证每次运行都会在其备份目录中清理
+	createIgnoredFile(t, repo, "keep.log", "保留")
+	old := config.GetGlobalConfig()
+	t.Cleanup(func() { config.InitGlobalConfig(old) })
+	return searchRoot, repo, filepath.Join(t.TempDir(), "backup")
+}
+
+func TestSync_DeletedHereMovesToHistory(t *testing.T) {
+	searchRoot, repo, dest := setupSyncRepo(t)
+	createIgnoredFile(t, repo, ".env", "LOCAL=1")
+	if code := logics.RunCopy([]string{"--sync", ".env", searchRoot, dest}); code != 0 {
+		t.Fatalf("复制失败，退出码 %d", code)
+	}
+
+	// 上次运行时源位置还有 .env，之后在本机删除
+	if err := os.Remove(filepath.Join(repo, ".env")); err != nil {
+		t.Fatalf("删除文件失败: %v", err)
+	}
+	if code := logics.RunCopy([]string{"--sync", ".env", searchRoot, dest}); code != 0 {
+		t.Fatalf("复制失败，退出码 %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(repo, ".env")); !os.IsNotExist(err) {
+		t.Error("本机删除的同步文件不应被取回")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "repo", ".env")); !os.IsNotExist(err) {
+		t.Error("本机删除的同步文件，其备份应移入历史目录")
+	}
+	moved, _ := filepath.Glob(filepath.Join(dest, "copy-ignore备份", "*", "repo", ".env"))
+	if len(moved) != 1 {
+		t.Errorf("历史目录中应有 .env 的备份，实际 %v", moved)
+	}
+
+	// 之后的运行也不会再取回
+	if code := logics.RunCopy([]string{"--sync", ".env", searchRoot, dest}); code != 0 {
+		t.Fatalf("复制失败，退出码 %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(repo, ".env")); !os.IsNotExist(err) {
+		t.Error("本机删除的同步文件不应在之后的运行中被取回")
+	}
+}
+
+func TestSync_AddedElsewherePulledBack(t *testing.T) {
+	searchRoot, repo, dest := setupSyncRepo(t)
+	if code := logics.RunCopy([]string{"--sync", ".env", searchRoot, dest}); code != 0 {
+		t.Fatalf("复制失败，退出码 %d", code)
+	}
+
+	// 另一台机器新增并备份了 .env，本机从未有过
+	writeTestFile(t, dest, "repo/.env", "REMOTE=1")
+	if code := logics.RunCopy([]string{"--sync", ".env", searchRoot, dest}); code != 0 {
+		t.Fatalf("复制失败，退出码 %d", code)
+	}
+	if data, err := os.ReadFile(filepath.Join(repo, ".env")); err != nil || string(data) != "REMOTE=1" {
+		t.Errorf("其他机器新增的同步文件应取回到源位置，实际 %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "repo", ".env")); err != nil {
+		t.Error("取回后备份应保留")
+	}
+
+	// 取回后在本机删除，下次运行移入历史目录
+	if err := os.Remove(filepath.Join(repo, ".env")); err != nil {
+		t.Fatalf("删除文件失败: %v", err)
+	}
+	if code := logics.RunCopy([]string{"--sync", ".env", searchRoot, dest}); code != 0 {
+		t.Fatalf("复制失败，退出码 %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(repo, ".env")); !os.IsNotExist(err) {
+		t.Error("取回后在本机删除的同步文件不应再被取回")
+	}
+}
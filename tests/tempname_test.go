@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+func TestTempPath_UniqueAndParsable(t *testing.T) {
+	target := filepath.Join("repo", "a.env")
+	first := helpers.TempPath(target)
+	second := helpers.TempPath(target)
+	if first == second {
+		t.Fatalf("同一目标文件的临时文件名应每次不同: %s", first)
+	}
+	if len(first)-len(target) > helpers.TempSuffixMaxLen {
+		t.Errorf("临时文件后缀超过 TempSuffixMaxLen: %s", first)
+	}
+
+	got, owner, ok := helpers.ParseTempPath(first)
+	if !ok || got != target || owner != helpers.RunID() {
+		t.Errorf("解析结果不正确: %s, %s, %v", got, owner, ok)
+	}
+	if !helpers.IsRunTempName(filepath.Base(first)) {
+		t.Errorf("%s 应被识别为带运行标识的临时文件", first)
+	}
+
+	// 旧版本的 .tmp 仍视为临时文件，但没有运行标识
+	got, owner, ok = helpers.ParseTempPath("a.env.tmp")
+	if !ok || got != "a.env" || owner != "" {
+		t.Errorf("旧格式解析结果不正确: %s, %s, %v", got, owner, ok)
+	}
+	if helpers.IsRunTempName("notes.ci-draft.tmp") || helpers.IsRunTempName("a.env") {
+		t.Error("普通文件名不应被识别为带运行标识的临时文件")
+	}
+}
+
+func TestCleanupOrphanedTempFiles_SkipsForeignRuns(t *testing.T) {
+	srcRoot := t.TempDir()
+	backupRoot := t.TempDir()
+	writeTestFile(t, srcRoot, "repo/a.env", "A=12345")
+	writeTestFile(t, srcRoot, "repo/b.env", "B=12345")
+
+	// 租约仍有效的运行 -> 跳过
+	active := "repo/a.env.ci-0badf00d-1.tmp"
+	writeTestFile(t, backupRoot, active, "A=1")
+	// 所属运行已结束、也很久没有修改 -> 按遗留的临时文件删除
+	stale := "repo/b.env.ci-12345678-2.tmp"
+	writeTestFile(t, backupRoot, stale, "B=1")
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(backupRoot, stale), old, old); err != nil {
+		t.Fatalf("设置修改时间失败: %v", err)
+	}
+	if err := os.Chtimes(filepath.Join(backupRoot, active), old, old); err != nil {
+		t.Fatalf("设置修改时间失败: %v", err)
+	}
+
+	sourceOf := func(rel string) string { return filepath.Join(srcRoot, rel) }
+	result, err := helpers.CleanupOrphanedTempFiles(backupRoot, nil, map[string]bool{"0badf00d": true}, sourceOf, false)
+	if err != nil {
+		t.Fatalf("处理遗留的临时文件失败: %v", err)
+	}
+	if result.Foreign != 1 || result.Removed != 1 {
+		t.Errorf("期望跳过 1 个、删除 1 个，实际 %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(backupRoot, active)); err != nil {
+		t.Errorf("其他运行正在写入的临时文件不应被处理: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(backupRoot, stale)); !os.IsNotExist(err) {
+		t.Error("遗留的临时文件应被删除")
+	}
+}
+
+// TestCleanupOrphanedTempFiles_ChunkTemps 块池中的临时块同样跳过其他正在进行的运行和最近仍有修改的临时块
+func TestCleanupOrphanedTempFiles_ChunkTemps(t *testing.T) {
+	backupRoot := t.TempDir()
+	data := "chunk data"
+	sum := sha256.Sum256([]byte(data))
+	hash := hex.EncodeToString(sum[:])
+	chunk := func(run string, seq int) string {
+		return filepath.Join(config.ChunkDirName, hash[:2], fmt.Sprintf("%s.ci-%s-%d.tmp", hash, run, seq))
+	}
+
+	active := chunk("0badf00d", 1) // 租约仍有效的运行
+	recent := chunk("12345678", 2) // 刚刚写入（对方可能没有租约）
+	stale := chunk("12345678", 3)  // 已结束的运行，内容完整 -> 补完
+	for _, rel := range []string{active, recent, stale} {
+		writeTestFile(t, backupRoot, rel, data)
+	}
+	old := time.Now().Add(-time.Hour)
+	for _, rel := range []string{active, stale} {
+		if err := os.Chtimes(filepath.Join(backupRoot, rel), old, old); err != nil {
+			t.Fatalf("设置修改时间失败: %v", err)
+		}
+	}
+
+	result, err := helpers.CleanupOrphanedTempFiles(backupRoot, nil, map[string]bool{"0badf00d": true}, func(string) string { return "" }, false)
+	if err != nil {
+		t.Fatalf("处理遗留的临时文件失败: %v", err)
+	}
+	if result.Foreign != 2 || result.Finalized != 1 {
+		t.Errorf("期望跳过 2 个、补完 1 个，实际 %+v", result)
+	}
+	for _, rel := range []string{active, recent} {
+		if _, err := os.Stat(filepath.Join(backupRoot, rel)); err != nil {
+			t.Errorf("其他运行正在写入的临时块不应被处理: %s", rel)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(backupRoot, config.ChunkDirName, hash[:2], hash)); err != nil {
+		t.Errorf("遗留的完整临时块应补完: %v", err)
+	}
+}
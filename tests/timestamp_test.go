@@ -0,0 +1,43 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+func TestTimestampFormat_RoundTrip(t *testing.T) {
+	now := time.Date(2024, 3, 5, 7, 8, 9, 0, time.UTC)
+	for _, format := range []string{"default", "rfc3339", "iso"} {
+		layout := helpers.ResolveTimestampFormat(format)
+		if err := helpers.ValidateTimestampFormat(layout, time.UTC); err != nil {
+			t.Errorf("预置格式 %s 应有效: %v", format, err)
+		}
+		name := helpers.FormatTimestamp(now, format, "UTC")
+		parsed, ok := helpers.ParseTimestampDir(name, layout, time.UTC)
+		if !ok || !parsed.Equal(now) {
+			t.Errorf("格式 %s 生成的目录名 %s 无法解析回原时间", format, name)
+		}
+	}
+
+	// 切换格式后仍能识别默认格式的旧目录
+	if _, ok := helpers.ParseTimestampDir("20240305-070809", helpers.ResolveTimestampFormat("iso"), time.UTC); !ok {
+		t.Errorf("应兼容默认格式的旧目录名")
+	}
+	if _, ok := helpers.ParseTimestampDir("not-a-time", helpers.DefaultTimestampFormat, time.UTC); ok {
+		t.Errorf("非时间戳目录名不应被识别")
+	}
+}
+
+func TestTimestampFormat_Invalid(t *testing.T) {
+	if err := helpers.ValidateTimestampFormat("2006-01-02", time.UTC); err == nil {
+		t.Errorf("缺少时分秒的格式应报错")
+	}
+	if err := helpers.ValidateTimestampFormat("15:04:05 20060102", time.UTC); err == nil {
+		t.Errorf("包含冒号的格式应报错")
+	}
+	if _, err := helpers.LoadTimestampZone("Not/AZone"); err == nil {
+		t.Errorf("未知时区应报错")
+	}
+}
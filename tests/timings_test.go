@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/copy"
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+func TestRepoTimings_RecordedPerRepo(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git 不在 PATH 中，跳过测试")
+	}
+	root := t.TempDir()
+	repos := []string{filepath.Join(root, "a"), filepath.Join(root, "b")}
+	for _, repo := range repos {
+		if err := os.MkdirAll(repo, 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+		initGitRepo(t, repo)
+		createGitignore(t, repo, "*.log\n")
+		createIgnoredFile(t, repo, "debug.log", "日志内容")
+	}
+
+	excluder, err := exclude.NewMatcher([]string{})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	fileChan := make(chan scanner.IgnoredFileInfo, 10)
+	if err := scanner.ScanIgnoredFilesWithProgressStreamConcurrent(root, excluder, nil, fileChan, 2); err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	close(fileChan)
+	var files []scanner.IgnoredFileInfo
+	for file := range fileChan {
+		files = append(files, file)
+	}
+
+	scanTimes := scanner.RepoScanTimes()
+	for _, repo := range repos {
+		if _, ok := scanTimes[repo]; !ok {
+			t.Errorf("缺少仓库 %s 的扫描耗时，实际: %v", repo, scanTimes)
+		}
+	}
+
+	config.InitGlobalConfig(&config.Config{})
+	defer config.InitGlobalConfig(nil)
+	backupRoot := t.TempDir()
+	if _, err := copy.CopyFiles(files, backupRoot, 2, false, excluder); err != nil {
+		t.Fatalf("复制失败: %v", err)
+	}
+	copyTimes := copy.Timer.Snapshot()
+	for _, repo := range repos {
+		if copyTimes[repo].Files != 1 {
+			t.Errorf("仓库 %s 应记录 1 个已处理文件，实际: %+v", repo, copyTimes[repo])
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/ui"
+)
+
+func TestUIWidthAndTruncate(t *testing.T) {
+	if w := ui.Width("abc"); w != 3 {
+		t.Errorf("ASCII 字符串宽度应为 3，实际 %d", w)
+	}
+	if w := ui.Width("进度: 1"); w != 7 {
+		t.Errorf("中文字符应按 2 列计算，实际宽度 %d", w)
+	}
+
+	short := "/tmp/repo"
+	if got := ui.Truncate(short, 20); got != short {
+		t.Errorf("未超长的字符串不应截断，实际 %q", got)
+	}
+	got := ui.Truncate("/项目/仓库/目录/文件.txt", 12)
+	if ui.Width(got) > 12 {
+		t.Errorf("截断后宽度不应超过 12，实际 %q（%d 列）", got, ui.Width(got))
+	}
+	if got != "/项目/仓..." {
+		t.Errorf("截断结果不正确: %q", got)
+	}
+}
+
+func TestUIRenderer_ConcurrentMessages(t *testing.T) {
+	renderer := ui.Start(time.Millisecond, func() string { return "进度" })
+
+	// 多个协程同时输出消息，Stop 应在全部输出后返回，不丢失、不阻塞
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				ui.Printf("")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		renderer.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("停止界面协程超时")
+	}
+
+	// 停止后直接输出，不应阻塞
+	ui.Printf("")
+}
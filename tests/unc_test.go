@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+func TestStripLongPathPrefix(t *testing.T) {
+	cases := map[string]string{
+		`\\?\C:\work\repo`:          `C:\work\repo`,
+		`\\?\UNC\server\share\repo`: `\\server\share\repo`,
+		`\\?\unc\server\share`:      `\\server\share`,
+		`//?/UNC/server/share/repo`: `//server/share/repo`,
+		`\\server\share\repo`:       `\\server\share\repo`,
+		`C:\work`:                   `C:\work`,
+		"/home/user/work":           "/home/user/work",
+		`\\?\Volume{1234}\dir`:      `\\?\Volume{1234}\dir`,
+		`\\?`:                       `\\?`,
+		`\\.\pipe\name`:             `\\.\pipe\name`,
+		`\\?\UNCserver\share`:       `\\?\UNCserver\share`,
+		`\\?\C:`:                    `C:`,
+		`//?/C:/work`:               `C:/work`,
+	}
+	for in, want := range cases {
+		if got := helpers.StripLongPathPrefix(in); got != want {
+			t.Errorf("StripLongPathPrefix(%q) = %q，期望 %q", in, got, want)
+		}
+	}
+}
+
+func TestSplitUNC(t *testing.T) {
+	sep := string(filepath.Separator)
+	cases := []struct {
+		path                string
+		server, share, rest string
+		ok                  bool
+	}{
+		{`\\server\share\dir\repo`, "server", "share", "dir" + sep + "repo", true},
+		{`//server/share`, "server", "share", "", true},
+		{`\\?\UNC\server\share\dir`, "server", "share", "dir", true},
+		{`\\server`, "server", "", "", true},
+		{`C:\work`, "", "", "", false},
+		{"/home/user", "", "", "", false},
+		{`\\\server\share`, "", "", "", false},
+	}
+	for _, c := range cases {
+		server, share, rest, ok := helpers.SplitUNC(c.path)
+		if server != c.server || share != c.share || rest != c.rest || ok != c.ok {
+			t.Errorf("SplitUNC(%q) = %q %q %q %v，期望 %q %q %q %v", c.path, server, share, rest, ok, c.server, c.share, c.rest, c.ok)
+		}
+	}
+}
+
+func TestNormalizeRoot_UNC(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		// 其他系统上只去掉长路径前缀
+		if got := helpers.NormalizeRoot(`\\?\UNC\server\share`); got != `\\server\share` {
+			t.Errorf("应去掉长路径前缀，实际 %q", got)
+		}
+		if err := helpers.ValidateUNCRoot(`//server`); err != nil {
+			t.Errorf("非 Windows 系统上 //server 只是普通路径，不应报错: %v", err)
+		}
+		return
+	}
+
+	// 只有共享名的 UNC 路径补上末尾的分隔符，其下的文件才能计算相对路径
+	root := helpers.NormalizeRoot(`\\?\UNC\server\share`)
+	if root != `\\server\share\` {
+		t.Fatalf("归一化结果 %q，期望 \\\\server\\share\\", root)
+	}
+	if rel, err := filepath.Rel(root, `\\server\share\repo\.env`); err != nil || rel != `repo\.env` {
+		t.Errorf("相对路径 %q %v，期望 repo\\.env", rel, err)
+	}
+	if got := helpers.NormalizeRoot(`//server/share/dir/`); got != `\\server\share\dir` {
+		t.Errorf("正斜杠形式应统一为反斜杠，实际 %q", got)
+	}
+	if err := helpers.ValidateUNCRoot(`\\server`); err == nil {
+		t.Error("缺少共享名的 UNC 路径应报错")
+	}
+}
+
+// TestMatcher_LongPathPrefix 排除规则和被检查的路径带有长路径前缀时，与不带前缀的形式一致
+func TestMatcher_LongPathPrefix(t *testing.T) {
+	m, err := exclude.NewMatcher([]string{`\\?\UNC\server\share\work\tmp`, `\\?\C:\cache`, "*.log"})
+	if err != nil {
+		t.Fatalf("创建排除匹配器失败: %v", err)
+	}
+	excluded := []string{
+		`\\server\share\work\tmp\a.txt`,
+		`\\?\UNC\server\share\work\tmp\b.txt`,
+		`C:\cache\x`,
+		`\\server\share\work\debug.log`,
+	}
+	for _, path := range excluded {
+		if !m.ShouldExclude(path) {
+			t.Errorf("%s 应被排除", path)
+		}
+	}
+	if m.ShouldExclude(`\\server\share\work\src\main.go`) {
+		t.Error("不匹配任何规则的网络路径不应被排除")
+	}
+}
@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/aogg/copy-ignore/src/exclude"
+	"github.com/aogg/copy-ignore/src/helpers"
+	"github.com/aogg/copy-ignore/src/layout"
+	"github.com/aogg/copy-ignore/src/scanner"
+)
+
+// 同一个文件名 "café" 的组合形式（NFC）和分解形式（NFD，macOS 上常见）
+const (
+	cafeNFC = "caf\u00e9"
+	cafeNFD = "cafe\u0301"
+)
+
+func TestNormalizePath(t *testing.T) {
+	if got := helpers.NormalizePath(filepath.Join("repo", cafeNFD, "a.env")); got != filepath.Join("repo", cafeNFC, "a.env") {
+		t.Errorf("NFD 路径应规范化为 NFC，实际 %q", got)
+	}
+	if got := helpers.NormalizePath(cafeNFC); got != cafeNFC {
+		t.Errorf("NFC 路径应保持不变，实际 %q", got)
+	}
+}
+
+func TestMatcherUnicodeForms(t *testing.T) {
+	m, err := exclude.NewMatcher([]string{cafeNFC})
+	if err != nil {
+		t.Fatalf("创建匹配器失败: %v", err)
+	}
+	if !m.ShouldExclude(filepath.Join("/src", "repo", cafeNFD, "a.env")) {
+		t.Errorf("NFC 形式的模式应匹配 NFD 形式的路径")
+	}
+
+	m, _ = exclude.NewMatcher([]string{"*" + cafeNFD + ".log"})
+	if !m.ShouldExclude(filepath.Join("/src", "repo", "x"+cafeNFC+".log")) {
+		t.Errorf("NFD 形式的模式应匹配 NFC 形式的路径")
+	}
+}
+
+func TestLayoutUnicodeForms(t *testing.T) {
+	tempDir := t.TempDir()
+	searchRoot := filepath.Join(tempDir, "src")
+	writeTestFile(t, searchRoot, filepath.Join("repo", cafeNFD, "a.env"), "A=1")
+
+	m, _ := layout.Load(filepath.Join(tempDir, "backup"), layout.LayoutPath)
+	file := scanner.IgnoredFileInfo{
+		AbsPath:      filepath.Join(searchRoot, "repo", cafeNFD, "a.env"),
+		RelativePath: filepath.Join("repo", cafeNFD, "a.env"),
+		RepoRoot:     filepath.Join(searchRoot, "repo"),
+	}
+	rel := m.Resolve(file)
+	if rel != filepath.Join("repo", cafeNFC, "a.env") {
+		t.Errorf("备份路径应为 NFC 形式，实际 %q", rel)
+	}
+	// 反推源文件路径时返回实际存在的 NFD 形式
+	if src := m.Source(rel, searchRoot); src != file.AbsPath {
+		t.Errorf("反推的源文件路径应为实际存在的形式 %q，实际 %q", file.AbsPath, src)
+	}
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/config"
+	"github.com/aogg/copy-ignore/src/logics"
+	"github.com/aogg/copy-ignore/src/manifest"
+)
+
+func TestVerify_Manifest(t *testing.T) {
+	backupRoot := t.TempDir()
+	writeTestFile(t, backupRoot, "web/.env", "secret")
+	writeTestFile(t, backupRoot, "web/app.log", "log")
+	if _, err := manifest.Update(backupRoot, nil, ""); err != nil {
+		t.Fatalf("生成清单失败: %v", err)
+	}
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+
+	if code := logics.RunVerify([]string{"--json", backupRoot}); code != 0 {
+		t.Fatalf("未改动的备份应校验通过，退出码 %d", code)
+	}
+
+	// 内容被改写但修改时间不变，视为损坏
+	envPath := filepath.Join(backupRoot, "web", ".env")
+	info, _ := os.Stat(envPath)
+	os.WriteFile(envPath, []byte("SECRET"), 0644)
+	os.Chtimes(envPath, info.ModTime(), info.ModTime())
+	if code := logics.RunVerify([]string{backupRoot}); code != 1 {
+		t.Errorf("损坏的文件应使退出码为 1，实际 %d", code)
+	}
+
+	if code := logics.RunVerify([]string{t.TempDir()}); code != 3 {
+		t.Errorf("没有清单时应无法完成校验（退出码 3），实际 %d", code)
+	}
+	if code := logics.RunVerify([]string{"--mode", "bogus", backupRoot}); code != 2 {
+		t.Errorf("未知的校验模式应为参数错误，实际 %d", code)
+	}
+}
+
+func TestVerify_SourceAndQuick(t *testing.T) {
+	tempDir := t.TempDir()
+	searchRoot := filepath.Join(tempDir, "src")
+	backupRoot := filepath.Join(tempDir, "backup")
+	repo := filepath.Join(searchRoot, "web")
+	os.MkdirAll(repo, 0755)
+	initGitRepo(t, repo)
+	createGitignore(t, repo, ".env\n")
+	writeTestFile(t, repo, ".env", "secret")
+	writeTestFile(t, backupRoot, "web/.env", "secret")
+	mtime := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(repo, ".env"), mtime, mtime)
+	os.Chtimes(filepath.Join(backupRoot, "web", ".env"), mtime, mtime)
+	defer config.InitGlobalConfig(config.GetGlobalConfig())
+
+	for _, mode := range []string{"source", "quick"} {
+		if code := logics.RunVerify([]string{"--mode", mode, searchRoot, backupRoot}); code != 0 {
+			t.Errorf("%s: 一致的备份应校验通过，退出码 %d", mode, code)
+		}
+	}
+
+	// 源文件内容变化（大小相同、修改时间不变）：只有 source 模式能发现
+	writeTestFile(t, repo, ".env", "SECRET")
+	os.Chtimes(filepath.Join(repo, ".env"), mtime, mtime)
+	if code := logics.RunVerify([]string{"--mode", "quick", searchRoot, backupRoot}); code != 0 {
+		t.Errorf("quick 模式只比较大小和修改时间，退出码应为 0，实际 %d", code)
+	}
+	if code := logics.RunVerify([]string{"--mode", "source", searchRoot, backupRoot}); code != 1 {
+		t.Errorf("source 模式应发现内容不一致，退出码 %d", code)
+	}
+
+	os.Remove(filepath.Join(backupRoot, "web", ".env"))
+	if code := logics.RunVerify([]string{"--mode", "quick", searchRoot, backupRoot}); code != 1 {
+		t.Errorf("备份缺失时退出码应为 1，实际 %d", code)
+	}
+}
@@ -0,0 +1,73 @@
+//go:build linux
+
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aogg/copy-ignore/src/helpers"
+)
+
+// TestWatchTree 报告已有目录和监视开始后新建的目录中的变化，跳过的目录不监视
+func TestWatchTree(t *testing.T) {
+	root := t.TempDir()
+	skipped := filepath.Join(root, "backup")
+	for _, dir := range []string{filepath.Join(root, "repo"), skipped} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("创建目录失败: %v", err)
+		}
+	}
+
+	watcher, err := helpers.WatchTree([]string{root}, func(dir string) bool { return dir == skipped })
+	if err != nil {
+		t.Fatalf("监视失败: %v", err)
+	}
+	changes := make(chan string, 100)
+	done := make(chan struct{})
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- watcher.Run(func(path string) { changes <- path }, func(reason error) {
+			t.Errorf("不应丢失事件: %v", reason)
+		}, done)
+	}()
+
+	// 等待 path 的变化被报告
+	expect := func(path string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case p := <-changes:
+				if p == skipped || filepath.Dir(p) == skipped {
+					t.Errorf("不应报告跳过的目录中的变化: %s", p)
+				}
+				if p == path {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("没有报告 %s 的变化", path)
+			}
+		}
+	}
+
+	writeTestFile(t, skipped, "ignored.txt", "内容")
+	writeTestFile(t, root, "repo/a.log", "内容")
+	expect(filepath.Join(root, "repo", "a.log"))
+
+	// 新建的目录自动加入监视
+	nested := filepath.Join(root, "repo", "node_modules")
+	if err := os.Mkdir(nested, 0755); err != nil {
+		t.Fatalf("创建目录失败: %v", err)
+	}
+	expect(nested)
+	writeTestFile(t, nested, "pkg.js", "内容")
+	expect(filepath.Join(nested, "pkg.js"))
+
+	close(done)
+	if err := <-stopped; err != nil {
+		t.Errorf("停止监视失败: %v", err)
+	}
+}